@@ -2,6 +2,7 @@ import dotenv from "dotenv";
 import mongoose from "mongoose";
 
 import {
+  RegionModel,
   ZoneModel,
   GymModel,
   LoomieTypeModel,
@@ -9,6 +10,9 @@ import {
   BaseLoomieModel,
   ItemModel,
   LoomBallModel,
+  AchievementModel,
+  QuestTemplateModel,
+  GiftTableEntryModel,
 } from "./models/mongoose.js";
 
 import {
@@ -16,6 +20,7 @@ import {
   createRandomLoomieTeam,
   getZoneCoordinatesFromGPS,
   readJsonFromDataFolder,
+  readRegionDataFiles,
 } from "./utils/utils.js";
 
 // Connect to MongoDB
@@ -24,27 +29,15 @@ mongoose.set("strictQuery", true);
 mongoose.connect(process.env.MONGO_URI, { dbName: "loomies" });
 
 // Read data from json files
-const zones = readJsonFromDataFolder("zones");
-const gyms = readJsonFromDataFolder("places");
+const regions = readJsonFromDataFolder("regions");
 const loomies = readJsonFromDataFolder("loomies");
 const items = readJsonFromDataFolder("items");
 const loomieTypes = readJsonFromDataFolder("loomies_types");
 const loomieRarities = readJsonFromDataFolder("loomies_rarities");
 const loomballs = readJsonFromDataFolder("loomballs");
-const staticPlaces = readJsonFromDataFolder("static_places");
-
-// Get the zone coordinates for the upb gyms
-let upbGyms = staticPlaces.map((place) => {
-  const coordinates = getZoneCoordinatesFromGPS(
-    place.latitude,
-    place.longitude
-  );
-
-  return {
-    ...place,
-    coordinates: `${coordinates.x},${coordinates.y}`,
-  };
-});
+const achievements = readJsonFromDataFolder("achievements");
+const questTemplates = readJsonFromDataFolder("quest_templates");
+const giftTable = readJsonFromDataFolder("gift_table");
 
 // Global variables
 const globalLoomiesTypesIds = [];
@@ -198,113 +191,138 @@ for await (const loomie of loomies) {
 // Get the inserted loomies to create the default loomie team for each gym
 console.log("Inserted loomies: ", await BaseLoomieModel.countDocuments(), "\n");
 
-// --- Zones and Gyms ---
-console.log("🏟️ Inserting gyms and zones...");
-const coordinates = { x: 0, y: 0 };
-let currentLongitude;
+// --- Regions, Zones and Gyms ---
+console.log("🏟️ Inserting regions, gyms and zones...");
 
-console.log("Expected zones: ", zones.length);
-console.log("Expected gyms: ", gyms.length + upbGyms.length);
+for await (const region of regions) {
+  const { zones, places: gyms, staticPlaces } = readRegionDataFiles(region);
+  const { name, bounds, grid_step, timezone, spawn_table } = region;
 
-for await (const zone of zones) {
-  let GymMongoId;
-
-  // Initialize currentLongitude
-  if (!currentLongitude) currentLongitude = zone.bottomFrontier;
-
-  // Increment coordinates when longitude changes (New row)
-  if (currentLongitude !== zone.bottomFrontier) {
-    currentLongitude = zone.bottomFrontier;
-    coordinates.x = 0;
-    coordinates.y++;
-  }
-
-  // Get the zone's gym
-  const gym = gyms.findIndex((gym) => gym.zoneIdentifier === zone.identifier);
-
-  // Insert the gym into mongodb and get the id
-  if (gym !== -1) {
-    const { name, latitude, longitude } = gyms[gym];
-    const protectors = await createRandomLoomieTeam(globalCommonLoomies);
-
-    const newGym = new GymModel({
-      name,
-      latitude,
-      longitude,
-      // Initially the gym has no owner
-      owner: null,
-      // Set the default loomie team
-      protectors,
-      // Initially the gym has no rewards until the cronjob runs
-      current_rewards: [],
-      rewards_claimed_by: [],
-    });
+  const { _id: regionId } = await new RegionModel({
+    name,
+    bounds,
+    grid_step,
+    timezone,
+    spawn_table,
+    created_at: Math.floor(Date.now() / 1000),
+  }).save();
+
+  // Get the zone coordinates for the static gyms (Eg. UPB buildings)
+  let upbGyms = staticPlaces.map((place) => {
+    const coordinates = getZoneCoordinatesFromGPS(
+      region,
+      place.latitude,
+      place.longitude
+    );
 
-    const { _id } = await newGym.save();
-    GymMongoId = _id;
-  }
+    return {
+      ...place,
+      coordinates: `${coordinates.x},${coordinates.y}`,
+    };
+  });
 
-  // Insert zone with the gym id
-  const { leftFrontier, rightFrontier, topFrontier, bottomFrontier, number } =
-    zone;
+  console.log(`Region ${name}`);
+  console.log("Expected zones: ", zones.length);
+  console.log("Expected gyms: ", gyms.length + upbGyms.length);
 
-  const zoneGyms = GymMongoId ? [GymMongoId] : [];
+  for await (const zone of zones) {
+    let GymMongoId;
 
-  // Check if there is a upb gym that belongs to this zone
-  for await (const upbGym of upbGyms) {
-    if (upbGym.coordinates === `${coordinates.x},${coordinates.y}`) {
-      console.log(
-        "Inserting upb gym in zone of coordinates: ",
-        `${coordinates.x},${coordinates.y}`
-      );
+    // Use the center of the zone to avoid rounding errors in the frontiers
+    const coordinates = getZoneCoordinatesFromGPS(
+      region,
+      zone.bottomFrontier + grid_step / 2,
+      zone.leftFrontier + grid_step / 2
+    );
 
-      // Insert the gym in the database
-      const { name, latitude, longitude } = upbGym;
+    // Get the zone's gym
+    const gym = gyms.findIndex((gym) => gym.zoneIdentifier === zone.identifier);
 
-      const protectors =
-        upbGym.name === "UPB Edificio K"
-          ? await createHardcoreLoomieTeam(
-              globalRareLoomies,
-              globalNormalLoomies
-            )
-          : await createRandomLoomieTeam(globalCommonLoomies);
+    // Insert the gym into mongodb and get the id
+    if (gym !== -1) {
+      const { name, latitude, longitude } = gyms[gym];
+      const protectors = await createRandomLoomieTeam(globalCommonLoomies);
 
       const newGym = new GymModel({
         name,
         latitude,
         longitude,
+        location: { type: "Point", coordinates: [longitude, latitude] },
+        // Initially the gym has no owner
         owner: null,
+        // Set the default loomie team
         protectors,
+        // Initially the gym has no rewards until the cronjob runs
         current_rewards: [],
         rewards_claimed_by: [],
       });
 
       const { _id } = await newGym.save();
-      zoneGyms.push(_id);
-
-      // Remove the gym from the upbGyms array
-      const index = upbGyms.findIndex((gym) => gym.name === upbGym.name);
-      upbGyms = upbGyms.filter((_, i) => i !== index);
+      GymMongoId = _id;
     }
-  }
 
-  const newZone = new ZoneModel({
-    leftFrontier,
-    rightFrontier,
-    topFrontier,
-    bottomFrontier,
-    number,
-    coordinates: `${coordinates.x},${coordinates.y}`,
-    gyms: zoneGyms,
-    loomies: [], // Empty loomies array
-  });
+    // Insert zone with the gym id
+    const { leftFrontier, rightFrontier, topFrontier, bottomFrontier, number } =
+      zone;
+
+    const zoneGyms = GymMongoId ? [GymMongoId] : [];
+
+    // Check if there is a upb gym that belongs to this zone
+    for await (const upbGym of upbGyms) {
+      if (upbGym.coordinates === `${coordinates.x},${coordinates.y}`) {
+        console.log(
+          "Inserting upb gym in zone of coordinates: ",
+          `${coordinates.x},${coordinates.y}`
+        );
+
+        // Insert the gym in the database
+        const { name, latitude, longitude } = upbGym;
+
+        const protectors =
+          upbGym.name === "UPB Edificio K"
+            ? await createHardcoreLoomieTeam(
+                globalRareLoomies,
+                globalNormalLoomies
+              )
+            : await createRandomLoomieTeam(globalCommonLoomies);
+
+        const newGym = new GymModel({
+          name,
+          latitude,
+          longitude,
+          location: { type: "Point", coordinates: [longitude, latitude] },
+          owner: null,
+          protectors,
+          current_rewards: [],
+          rewards_claimed_by: [],
+        });
+
+        const { _id } = await newGym.save();
+        zoneGyms.push(_id);
+
+        // Remove the gym from the upbGyms array
+        const index = upbGyms.findIndex((gym) => gym.name === upbGym.name);
+        upbGyms = upbGyms.filter((_, i) => i !== index);
+      }
+    }
 
-  await newZone.save();
+    const newZone = new ZoneModel({
+      region: regionId,
+      leftFrontier,
+      rightFrontier,
+      topFrontier,
+      bottomFrontier,
+      number,
+      coordinates: `${coordinates.x},${coordinates.y}`,
+      gyms: zoneGyms,
+      loomies: [], // Empty loomies array
+    });
 
-  // Increment coordinates
-  coordinates.x++;
+    await newZone.save();
+  }
 }
 
+console.log("Regions inserted: ", await RegionModel.countDocuments());
 console.log("Zones inserted: ", await ZoneModel.countDocuments());
 console.log("Gyms inserted: ", await GymModel.countDocuments(), "\n");
 
@@ -374,6 +392,77 @@ for await (const loomball of loomballs) {
 
 console.log("Inserted loomballs: ", await LoomBallModel.countDocuments(), "\n");
 
+// --- Achievements ---
+console.log("🏅 Inserting achievements...");
+
+for await (const achievement of achievements) {
+  const { serial, name, description, badge, counter, goal } = achievement;
+
+  const newAchievement = new AchievementModel({
+    serial,
+    name,
+    description,
+    badge,
+    counter,
+    goal,
+  });
+
+  await newAchievement.save();
+}
+
+console.log(
+  "Inserted achievements: ",
+  await AchievementModel.countDocuments(),
+  "\n"
+);
+
+// --- Quest templates ---
+console.log("📜 Inserting quest templates...");
+
+for await (const template of questTemplates) {
+  const newQuestTemplate = new QuestTemplateModel({
+    serial: template.serial,
+    period: template.period,
+    event: template.event,
+    description: template.description,
+    loomie_type: template.loomie_type,
+    min_goal: template.min_goal,
+    max_goal: template.max_goal,
+    unique: template.unique,
+    rewards: template.rewards,
+  });
+
+  await newQuestTemplate.save();
+}
+
+console.log(
+  "Inserted quest templates: ",
+  await QuestTemplateModel.countDocuments(),
+  "\n"
+);
+
+// --- Gift table ---
+console.log("🎁 Inserting gift table...");
+
+for await (const entry of giftTable) {
+  const newGiftTableEntry = new GiftTableEntryModel({
+    reward_collection: entry.reward_collection,
+    reward_serial: entry.reward_serial,
+    weight: entry.weight,
+    min_quantity: entry.min_quantity,
+    max_quantity: entry.max_quantity,
+  });
+
+  await newGiftTableEntry.save();
+}
+
+console.log(
+  "Inserted gift table entries: ",
+  await GiftTableEntryModel.countDocuments(),
+  "\n"
+);
+
 // Close connection
 await ZoneModel.ensureIndexes();
+await RegionModel.ensureIndexes();
 mongoose.connection.close();
@@ -2,15 +2,19 @@ import dotenv from "dotenv";
 import mongoose from "mongoose";
 import { it, describe, expect } from "vitest";
 import {
+  AchievementModel,
   BaseLoomieModel,
+  GiftTableEntryModel,
   GymModel,
   ItemModel,
   LoomBallModel,
   LoomieRarityModel,
   LoomieTypeModel,
+  QuestTemplateModel,
+  RegionModel,
   ZoneModel,
 } from "./models/mongoose";
-import { readJsonFromDataFolder } from "./utils/utils";
+import { readJsonFromDataFolder, readRegionDataFiles } from "./utils/utils";
 
 // Connect to MongoDB
 dotenv.config();
@@ -18,16 +22,25 @@ mongoose.set("strictQuery", true);
 mongoose.connect(process.env.MONGO_URI, { dbName: "loomies" });
 
 // Read data from json files
-const zones = readJsonFromDataFolder("zones");
-const gyms = readJsonFromDataFolder("places");
+const regions = readJsonFromDataFolder("regions");
+const regionsData = regions.map(readRegionDataFiles);
+const zones = regionsData.flatMap((data) => data.zones);
+const gyms = regionsData.flatMap((data) => data.places);
 const loomies = readJsonFromDataFolder("loomies");
 const items = readJsonFromDataFolder("items");
 const loomieTypes = readJsonFromDataFolder("loomies_types");
 const loomieRarities = readJsonFromDataFolder("loomies_rarities");
 const loomballs = readJsonFromDataFolder("loomballs");
+const achievements = readJsonFromDataFolder("achievements");
+const questTemplates = readJsonFromDataFolder("quest_templates");
+const giftTable = readJsonFromDataFolder("gift_table");
 
 // --- Tests ---
 describe.concurrent("Testing documents count", () => {
+  it(`Should have ${regions.length} regions`, async () => {
+    expect(regions.length).toBe(await RegionModel.countDocuments());
+  });
+
   it(`Should have ${zones.length} zones`, async () => {
     expect(zones.length).toBe(await ZoneModel.countDocuments());
   });
@@ -53,6 +66,20 @@ describe.concurrent("Testing documents count", () => {
   it(`Should have ${items.length} items`, async () => {
     expect(items.length).toBe(await ItemModel.countDocuments());
   });
+
+  it(`Should have ${achievements.length} achievements`, async () => {
+    expect(achievements.length).toBe(await AchievementModel.countDocuments());
+  });
+
+  it(`Should have ${questTemplates.length} quest templates`, async () => {
+    expect(questTemplates.length).toBe(
+      await QuestTemplateModel.countDocuments()
+    );
+  });
+
+  it(`Should have ${giftTable.length} gift table entries`, async () => {
+    expect(giftTable.length).toBe(await GiftTableEntryModel.countDocuments());
+  });
 });
 
 describe(
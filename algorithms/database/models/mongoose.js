@@ -2,8 +2,26 @@ import { Schema, model } from "mongoose";
 
 // -- --- --- --- ---
 // Schemas
+const RegionSchema = new Schema(
+  {
+    name: { type: String, unique: true },
+    bounds: {
+      min_latitude: Number,
+      min_longitude: Number,
+      max_latitude: Number,
+      max_longitude: Number,
+    },
+    grid_step: Number,
+    timezone: String,
+    spawn_table: [{ _id: false, serial: Number, chance: Number }],
+    created_at: Number,
+  },
+  { versionKey: false }
+);
+
 const ZoneSchema = new Schema(
   {
+    region: { type: Schema.Types.ObjectId, ref: "regions" },
     leftFrontier: Number,
     rightFrontier: Number,
     topFrontier: Number,
@@ -19,6 +37,7 @@ const ZoneSchema = new Schema(
 // Create hash and unique index for coordinates
 ZoneSchema.set("autoIndex", false);
 ZoneSchema.index({ coordinates: "hashed" });
+ZoneSchema.index({ region: 1, coordinates: 1 });
 
 // Create a schema for the rewards that can be claimed by players and gym owners
 const sharedRewardSchema = {
@@ -44,6 +63,11 @@ const GymSchema = new Schema(
   {
     latitude: Number,
     longitude: Number,
+    // GeoJSON point used by the 2dsphere index of the API (see the api migrations)
+    location: {
+      type: { type: String, enum: ["Point"] },
+      coordinates: [Number],
+    },
     name: String,
     owner: { type: Schema.Types.ObjectId, ref: "users" },
     protectors: [{ type: Schema.Types.ObjectId, ref: "caught_loomies" }],
@@ -122,6 +146,11 @@ const WildLoomieSchema = new Schema(
     },
     latitude: Number,
     longitude: Number,
+    // GeoJSON point used by the 2dsphere index of the API (see the api migrations)
+    location: {
+      type: { type: String, enum: ["Point"] },
+      coordinates: [Number],
+    },
     generated_at: Number,
   },
   { versionKey: false }
@@ -210,6 +239,112 @@ const LoomBallsSchema = new Schema(
   { versionKey: false }
 );
 
+const AchievementSchema = new Schema(
+  {
+    serial: {
+      type: Number,
+      unique: true,
+    },
+    name: String,
+    description: String,
+    badge: String,
+    // Name of the user counter that tracks the progress
+    counter: {
+      type: String,
+      enum: [
+        "captures",
+        "fusions",
+        "gym_victories",
+        "flawless_victories",
+        "owned_gyms",
+        "claimed_rewards",
+      ],
+    },
+    goal: {
+      type: Number,
+      min: 1,
+    },
+  },
+  { versionKey: false }
+);
+
+const QuestTemplateSchema = new Schema(
+  {
+    serial: {
+      type: Number,
+      unique: true,
+    },
+    period: {
+      type: String,
+      enum: ["daily", "weekly"],
+    },
+    // Name of the game event that increments the progress
+    event: {
+      type: String,
+      enum: [
+        "capture",
+        "defeat_protector",
+        "visit_zone",
+        "fuse",
+        "claim_reward",
+      ],
+    },
+    // The {goal} placeholder is replaced with the generated goal
+    description: String,
+    // Optional name of the loomie type required by the capture quests
+    loomie_type: String,
+    min_goal: {
+      type: Number,
+      min: 1,
+    },
+    max_goal: {
+      type: Number,
+      min: 1,
+    },
+    // Only different values count towards the goal (e.g. different zones)
+    unique: {
+      type: Boolean,
+      default: false,
+    },
+    rewards: [
+      {
+        _id: false,
+        reward_collection: {
+          type: String,
+          enum: ["items", "loom_balls"],
+        },
+        reward_serial: Number,
+        reward_quantity: Number,
+      },
+    ],
+  },
+  { versionKey: false }
+);
+
+// Possible rewards of the gifts between friends, drawn by weight
+const GiftTableEntrySchema = new Schema(
+  {
+    reward_collection: {
+      type: String,
+      enum: ["items", "loom_balls"],
+    },
+    reward_serial: Number,
+    weight: {
+      type: Number,
+      min: 0,
+    },
+    min_quantity: {
+      type: Number,
+      min: 1,
+    },
+    max_quantity: {
+      type: Number,
+      min: 1,
+    },
+  },
+  { versionKey: false }
+);
+
 // user items
 const userItemSchema = {
   type: [
@@ -247,7 +382,8 @@ const UserSchema = new Schema(
 // -- --- --- --- ---
 // Models
 
-// Zone & Gyms
+// Regions, Zones & Gyms
+export const RegionModel = model("regions", RegionSchema);
 export const ZoneModel = model("zones", ZoneSchema);
 export const GymModel = model("gyms", GymSchema);
 // Loomies
@@ -259,5 +395,16 @@ export const CaughtLoomieModel = model("caught_loomies", CaughtLoomieSchema);
 // Collectionables
 export const ItemModel = model("items", ItemsSchema);
 export const LoomBallModel = model("loom_balls", LoomBallsSchema);
+export const AchievementModel = model("achievements", AchievementSchema);
+export const QuestTemplateModel = model(
+  "quest_templates",
+  QuestTemplateSchema
+);
+// The collection name is given to avoid the mongoose pluralization
+export const GiftTableEntryModel = model(
+  "gift_table",
+  GiftTableEntrySchema,
+  "gift_table"
+);
 // User
 export const UserModel = model("users", UserSchema);
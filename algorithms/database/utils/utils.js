@@ -132,16 +132,33 @@ export async function giveAllLoomies(possibleLoomies, ownerId) {
 
 /**
  *
+ * @param {*} region Region with the bounds and the grid step of its zones
  * @param {number} latitude The latitude of the gym
  * @param {number} longitude The longitude of the gym
  * @returns The local coordinates of the zone where the gym is located
  */
-export function getZoneCoordinatesFromGPS(latitude, longitude) {
-  const initialLatitude = 6.9595;
-  const initialLongitude = -73.1696;
-  const zoneSize = 0.0035;
+export function getZoneCoordinatesFromGPS(region, latitude, longitude) {
+  const { min_latitude, min_longitude } = region.bounds;
 
-  const x = Math.floor((longitude - initialLongitude) / zoneSize);
-  const y = Math.floor((latitude - initialLatitude) / zoneSize);
+  const x = Math.floor((longitude - min_longitude) / region.grid_step);
+  const y = Math.floor((latitude - min_latitude) / region.grid_step);
   return { x, y };
 }
+
+/**
+ * Reads the zones and places of a region, the regions created before the files
+ * were configured use the default file names
+ * @param {*} region Region from the regions.json file
+ * @returns The zones, places and static places (if any) of the region
+ */
+export function readRegionDataFiles(region) {
+  const files = region.files || {};
+
+  return {
+    zones: readJsonFromDataFolder(files.zones || `${region.name}_zones`),
+    places: readJsonFromDataFolder(files.places || `${region.name}_places`),
+    staticPlaces: files.static_places
+      ? readJsonFromDataFolder(files.static_places)
+      : [],
+  };
+}
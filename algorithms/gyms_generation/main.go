@@ -1,7 +1,9 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"os"
 	"strconv"
 	"time"
 
@@ -127,14 +129,14 @@ func generatePlacesAndZones(minLat, minLong, maxLat, maxLong, step float64) ([]u
 	return concurrentPlaces.Places, concurrentZones.Zones
 }
 
-func main() {
-	step := 0.0035
+func generateRegion(region utils.Region) {
+	step := region.GridStep
+	bounds := region.Bounds
 	start := time.Now()
-	// Bucaramanga, Floridablanda, Piedecuesta
-	places, zones := generatePlacesAndZones(-73.1696, 6.9595, -73.0031, 7.1728, step)
 
-	// Piedecuesta (center)
-	// places, zones := generatePlacesAndZones(-73.0744, 6.9758, -73.0384, 7.0075, step)
+	log := fmt.Sprintf("ℹ Generating region %s \n", region.Name)
+	color.Blue(log)
+	places, zones := generatePlacesAndZones(bounds.MinLongitude, bounds.MinLatitude, bounds.MaxLongitude, bounds.MaxLatitude, step)
 
 	end := time.Now()
 	elapsed := end.Sub(start)
@@ -142,11 +144,35 @@ func main() {
 	// Remove duplicated places and save places and zones to JSON files
 	fmt.Println("Obtaining unique places...")
 	uniquePlaces := utils.GetUniquePlaces(&places, &zones, step)
-	utils.SaveStructToFile(uniquePlaces, "places.json")
+	zonesFile, placesFile := region.DataFiles()
+	utils.SaveStructToFile(uniquePlaces, placesFile+".json")
 	sortedZones := utils.GetSortedZones(&zones)
-	utils.SaveStructToFile(sortedZones, "zones.json")
+	utils.SaveStructToFile(sortedZones, zonesFile+".json")
 
 	// Log results
-	log := fmt.Sprintf("Obtained %d places and %d zones in %f minutes\n", len(uniquePlaces), len(zones), elapsed.Minutes())
+	log = fmt.Sprintf("Obtained %d places and %d zones for %s in %f minutes\n", len(uniquePlaces), len(zones), region.Name, elapsed.Minutes())
 	color.Green(log)
 }
+
+func main() {
+	name := flag.String("region", "", "Name of the region to generate (all the regions by default)")
+	flag.Parse()
+
+	regions := utils.ReadRegions("regions.json")
+	generated := 0
+
+	for _, region := range regions {
+		if *name != "" && region.Name != *name {
+			continue
+		}
+
+		generateRegion(region)
+		generated++
+	}
+
+	if generated == 0 {
+		log := fmt.Sprintf("✖ Region not found: %s \n", *name)
+		color.Red(log)
+		os.Exit(1)
+	}
+}
@@ -34,6 +34,22 @@ type Zone struct {
 	Number         int     `json:"number"`
 }
 
+// Region is read from the data/regions.json file, the same file is used to insert the regions in the database
+type Region struct {
+	Name   string `json:"name"`
+	Bounds struct {
+		MinLatitude  float64 `json:"min_latitude"`
+		MinLongitude float64 `json:"min_longitude"`
+		MaxLatitude  float64 `json:"max_latitude"`
+		MaxLongitude float64 `json:"max_longitude"`
+	} `json:"bounds"`
+	GridStep float64 `json:"grid_step"`
+	Files    struct {
+		Zones  string `json:"zones"`
+		Places string `json:"places"`
+	} `json:"files"`
+}
+
 type ConcurrentPlaces struct {
 	sync.RWMutex
 	Places []Place
@@ -187,6 +203,38 @@ func GetSortedZones(zones *[]Zone) []Zone {
 	return *zones
 }
 
+// DataFiles returns the names of the zones and places files of the region (without the extension)
+func (region Region) DataFiles() (string, string) {
+	zones, places := region.Files.Zones, region.Files.Places
+
+	if zones == "" {
+		zones = region.Name + "_zones"
+	}
+
+	if places == "" {
+		places = region.Name + "_places"
+	}
+
+	return zones, places
+}
+
+// ReadRegions reads the regions from the given file of the data folder
+func ReadRegions(fileName string) []Region {
+	regions := []Region{}
+	file, err := ioutil.ReadFile("../../data/" + fileName)
+
+	if err == nil {
+		err = json.Unmarshal(file, &regions)
+	}
+
+	if err != nil {
+		log := fmt.Sprintf("✖ Error reading the regions: %s \n", err)
+		color.Red(log)
+	}
+
+	return regions
+}
+
 // SaveStructToFile marshals the given data and saves it to the given file
 func SaveStructToFile(data interface{}, fileName string) {
 	// Marshal data
@@ -0,0 +1,243 @@
+// Package app wires the dependencies of the server (configuration, database, repositories, mailer, combats hub and
+// router)
+// and controls its lifecycle
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/audit"
+	"github.com/PedroChaparro/loomies-backend/combat"
+	"github.com/PedroChaparro/loomies-backend/configuration"
+	"github.com/PedroChaparro/loomies-backend/controllers"
+	"github.com/PedroChaparro/loomies-backend/email"
+	"github.com/PedroChaparro/loomies-backend/middlewares"
+	"github.com/PedroChaparro/loomies-backend/migrations"
+	"github.com/PedroChaparro/loomies-backend/models"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"github.com/PedroChaparro/loomies-backend/routes"
+	"github.com/PedroChaparro/loomies-backend/scheduler"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Timeouts of the http server. The websocket connections are not affected because the deadlines are cleared
+// when the connection is upgraded
+const (
+	readHeaderTimeout = 5 * time.Second
+	readTimeout       = 15 * time.Second
+	writeTimeout      = 30 * time.Second
+	idleTimeout       = 60 * time.Second
+)
+
+// Time given to the running combats and requests to finish when the server is shutting down
+const shutdownTimeout = 20 * time.Second
+
+// Interval to check for new game settings versions when the database doesn't support change streams
+const gameSettingsPollInterval = 30 * time.Second
+
+// App stores the dependencies of the server
+type App struct {
+	Config *configuration.Config
+	// Game balance updated with the versions published by the admins
+	Balance      *configuration.LiveBalance
+	MongoClient  *mongo.Client
+	Repositories repositories.Repositories
+	Mailer       *email.Mailer
+	Hub          *combat.WsHub
+	Engine       *gin.Engine
+	Server       *http.Server
+	// Runs the periodic jobs, nil when it's disabled or there is no database
+	Scheduler *scheduler.Scheduler
+
+	// Stops the background tasks started by Run
+	stopBackground context.CancelFunc
+}
+
+// New creates the app from the given configuration with the mongo repositories and the default router
+func New(config *configuration.Config) (*App, error) {
+	mongoClient, err := configuration.NewMongoClient(config.Mongo)
+	if err != nil {
+		return nil, err
+	}
+
+	// The mongo repositories and the audit log use the collections of the configured database
+	database := mongoClient.Database(config.Mongo.Database)
+	models.UseDatabase(database)
+	audit.UseDatabase(database)
+
+	balance := configuration.NewLiveBalance(config.Game.Balance())
+	return NewWithRepositories(config, balance, mongoClient, models.NewMongoRepositories(config, balance)), nil
+}
+
+// NewWithRepositories creates the app with the given configuration, game balance and repositories, the mongo client
+// can be nil when the repositories don't use it. The repositories should publish the game settings to the same balance
+func NewWithRepositories(config *configuration.Config, balance *configuration.LiveBalance, mongoClient *mongo.Client, repos repositories.Repositories) *App {
+	// Set gin mode to release if in production
+	if config.Environment == "PRODUCTION" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	hub := &combat.WsHub{
+		Combats:             make(map[string]*combat.WsCombat),
+		CachedStrongAgainst: make(map[string][]string),
+	}
+
+	mailer := email.NewMailerFromSettings(config.Email)
+
+	// The handlers, the middlewares and the combat helpers use the injected settings, repositories, mailer and hub
+	controllers.SetConfiguration(config, balance)
+	controllers.SetRepositories(repos)
+	controllers.SetMailer(mailer)
+	middlewares.SetConfiguration(config)
+	middlewares.SetRepositories(repos)
+	combat.GlobalWsHub = hub
+
+	// Setup server and default routes
+	engine := gin.Default()
+	routes.SetupRoutes(engine)
+	routes.SetupWebSocketRoutes(engine)
+
+	return &App{
+		Config:       config,
+		Balance:      balance,
+		MongoClient:  mongoClient,
+		Repositories: repos,
+		Mailer:       mailer,
+		Hub:          hub,
+		Engine:       engine,
+		Server: &http.Server{
+			Addr:              fmt.Sprintf(":%d", config.Server.Port),
+			Handler:           engine,
+			ReadHeaderTimeout: readHeaderTimeout,
+			ReadTimeout:       readTimeout,
+			WriteTimeout:      writeTimeout,
+			IdleTimeout:       idleTimeout,
+		},
+	}
+}
+
+// migrate "private" function to apply the pending migrations before serving the requests (when it's enabled)
+func (app *App) migrate() error {
+	if app.MongoClient == nil || !app.Config.Mongo.MigrateOnStartup {
+		return nil
+	}
+
+	migrator := migrations.New(app.MongoClient.Database(app.Config.Mongo.Database))
+	applied, err := migrator.Up(context.Background())
+
+	for _, migration := range applied {
+		fmt.Println("Applied migration", migration.Version, migration.Name)
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to migrate the database: %w", err)
+	}
+
+	return nil
+}
+
+// Run applies the pending migrations, starts the server and blocks until it fails or an interrupt / terminate
+// signal is received, in the second case the server is gracefully shut down
+func (app *App) Run() error {
+	if err := app.migrate(); err != nil {
+		return err
+	}
+
+	if app.MongoClient != nil && app.Config.Scheduler.Enabled {
+		jobsScheduler, err := app.newScheduler()
+		if err != nil {
+			return err
+		}
+
+		app.Scheduler = jobsScheduler
+	}
+
+	serverErrors := make(chan error, 1)
+
+	// Keep the game balance updated with the versions published by the admins and run the periodic jobs
+	background, stopBackground := context.WithCancel(context.Background())
+	app.stopBackground = stopBackground
+
+	if app.MongoClient != nil {
+		go models.WatchGameSettings(background, app.Balance, gameSettingsPollInterval)
+	}
+
+	if app.Scheduler != nil {
+		app.Scheduler.Start(background)
+	}
+
+	go func() {
+		fmt.Println("Listening and serving HTTP on", app.Server.Addr)
+		serverErrors <- app.Server.ListenAndServe()
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	select {
+	case err := <-serverErrors:
+		stopBackground()
+
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+
+		return err
+	case received := <-signals:
+		fmt.Println("Received", received, "signal, shutting down the server")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return app.Shutdown(ctx)
+}
+
+// Shutdown stops the background tasks (waiting for the running jobs), the combats (notifying the players and storing
+// their outcome), the http server, the emails delivery and the database connections. All the steps are run even if one
+// of them fails
+func (app *App) Shutdown(ctx context.Context) error {
+	var errs []error
+
+	if app.stopBackground != nil {
+		app.stopBackground()
+	}
+
+	if app.Scheduler != nil {
+		if err := app.Scheduler.Wait(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("unable to finish the running jobs: %w", err))
+		}
+	}
+
+	if err := app.Hub.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("unable to finish the combats: %w", err))
+	}
+
+	if err := app.Server.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("unable to stop the http server: %w", err))
+	}
+
+	if err := app.Mailer.Close(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	if app.MongoClient != nil {
+		if err := app.MongoClient.Disconnect(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("unable to disconnect from mongo: %w", err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%v", errs)
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/email"
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/models"
+	"github.com/PedroChaparro/loomies-backend/scheduler"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Names of the periodic jobs, used as the ids of their locks and in the runs history
+const (
+	GymsRewardsJob     = "update_gyms_rewards"
+	OutdatedLoomiesJob = "remove_outdated_loomies"
+	WeeklySummaryJob   = "send_weekly_summary"
+)
+
+// updateGymsRewards "private" function to replace the rewards of all the gyms
+func updateGymsRewards(ctx context.Context) (string, error) {
+	updated, err := models.RegenerateGymsRewards(ctx)
+	return fmt.Sprintf("updated the rewards of %d gyms", updated), err
+}
+
+// removeOutdatedLoomies "private" function to remove the wild loomies older than their (current) time to live
+func (app *App) removeOutdatedLoomies(ctx context.Context) (string, error) {
+	ttl := app.Balance.Get().WildLoomiesTTL
+	deadline := time.Now().Add(-time.Minute * time.Duration(ttl)).Unix()
+
+	removed, err := models.RemoveOutdatedWildLoomies(ctx, deadline)
+	return fmt.Sprintf("removed %d wild loomies", removed), err
+}
+
+// sendWeeklySummary "private" function to email the activity of the last week to the verified users that caught
+// loomies or conquered, lost or own gyms
+func (app *App) sendWeeklySummary(ctx context.Context) (string, error) {
+	since := time.Now().AddDate(0, 0, -7).Unix()
+	summaries, err := models.GetWeeklySummaries(ctx, since)
+	if err != nil {
+		return "", err
+	}
+
+	ids := make([]primitive.ObjectID, len(summaries))
+	for index, summary := range summaries {
+		ids[index] = summary.UserId
+	}
+
+	users, err := models.GetUsersByIds(ids)
+	if err != nil {
+		return "", err
+	}
+
+	byId := make(map[primitive.ObjectID]interfaces.User, len(users))
+	for _, user := range users {
+		byId[user.Id] = user
+	}
+
+	sent := 0
+
+	for _, summary := range summaries {
+		user, ok := byId[summary.UserId]
+		if !ok || !user.IsVerified {
+			continue
+		}
+
+		err := app.Mailer.SendWait(ctx, user.Email, user.Language, email.WeeklySummaryTemplate, email.WeeklySummaryData{
+			Username:      user.Username,
+			CaughtLoomies: summary.CaughtLoomies,
+			ConqueredGyms: summary.ConqueredGyms,
+			LostGyms:      summary.LostGyms,
+			OwnedGyms:     summary.OwnedGyms,
+		})
+
+		if err != nil {
+			return fmt.Sprintf("queued %d weekly summaries", sent), err
+		}
+
+		sent++
+	}
+
+	return fmt.Sprintf("queued %d weekly summaries", sent), nil
+}
+
+// newScheduler "private" function to create the scheduler with the periodic jobs of the settings
+func (app *App) newScheduler() (*scheduler.Scheduler, error) {
+	settings := app.Config.Scheduler
+
+	location, err := time.LoadLocation(settings.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := []struct {
+		name       string
+		expression string
+		run        func(ctx context.Context) (string, error)
+	}{
+		{GymsRewardsJob, settings.GymsRewardsSchedule, updateGymsRewards},
+		{OutdatedLoomiesJob, settings.OutdatedLoomiesSchedule, app.removeOutdatedLoomies},
+		{WeeklySummaryJob, settings.WeeklySummarySchedule, app.sendWeeklySummary},
+	}
+
+	jobsScheduler := scheduler.New(app.MongoClient.Database(app.Config.Mongo.Database), location)
+
+	for _, job := range jobs {
+		schedule, err := scheduler.ParseSchedule(job.expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule of the %s job: %w", job.name, err)
+		}
+
+		jobsScheduler.Add(scheduler.Job{Name: job.name, Schedule: schedule, Run: job.run})
+	}
+
+	return jobsScheduler, nil
+}
@@ -0,0 +1,190 @@
+// Package audit stores an append-only log of the changes made to the game state (inventories, loomies, gyms, accounts
+// and game content) in the `audit_events` collection. The events are written by the mutating functions of the models
+// package, which receive the context of the operation to know who performed the change and the request it belongs to.
+// Bookkeeping writes (verification codes, generation timestamps, wild loomies spawns and combat registers) are not audited.
+// The events of the writes made in a transaction are stored once it's committed, so a failed audit doesn't abort it.
+package audit
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EventsCollection stores the events, it's set by UseDatabase
+var EventsCollection *mongo.Collection
+
+// The before / after snapshots are decoded as maps so they are serialized as JSON objects
+var eventsRegistry = bson.NewRegistryBuilder().RegisterTypeMapEntry(bsontype.EmbeddedDocument, reflect.TypeOf(bson.M{})).Build()
+
+// UseDatabase Sets the events collection of the given database, it must be called before recording the events
+func UseDatabase(database *mongo.Database) {
+	EventsCollection = database.Collection("audit_events")
+}
+
+// Keys set by the middlewares in the gin context, gin.Context implements context.Context and
+// exposes them through the Value method
+const (
+	ActorKey     = "userid"
+	RequestIdKey = "requestid"
+)
+
+type contextKey string
+
+// WithActor returns a copy of the context with the given actor and request id. It's used for the operations
+// that run outside an HTTP request (Eg. the combats)
+func WithActor(ctx context.Context, actorId primitive.ObjectID, requestId string) context.Context {
+	ctx = context.WithValue(ctx, contextKey(ActorKey), actorId.Hex())
+	return context.WithValue(ctx, contextKey(RequestIdKey), requestId)
+}
+
+// "private" function to read a string value from the gin or the standard context
+func getContextValue(ctx context.Context, key string) string {
+	if value, ok := ctx.Value(contextKey(key)).(string); ok {
+		return value
+	}
+
+	value, _ := ctx.Value(key).(string)
+	return value
+}
+
+// NewEvent Returns the event with the actor (when it was not given), the request id and the creation time filled from
+// the context. It's used by the repositories that store the events somewhere else (Eg. in memory)
+func NewEvent(ctx context.Context, event interfaces.AuditEvent) interfaces.AuditEvent {
+	if event.ActorId.IsZero() {
+		event.ActorId, _ = primitive.ObjectIDFromHex(getContextValue(ctx, ActorKey))
+	}
+
+	event.RequestId = getContextValue(ctx, RequestIdKey)
+	event.CreatedAt = time.Now().Unix()
+	return event
+}
+
+// Record Stores the event filled by NewEvent. Errors are logged but not returned, a failed audit must not interrupt
+// the operation. Inside a transaction (See Defer) the event is kept until the transaction is committed
+func Record(ctx context.Context, event interfaces.AuditEvent) {
+	if pending, ok := ctx.Value(pendingKey{}).(*Pending); ok {
+		pending.add(NewEvent(ctx, event))
+		return
+	}
+
+	_, err := EventsCollection.InsertOne(ctx, NewEvent(ctx, event))
+
+	if err != nil {
+		fmt.Println("Unable to store the audit event:", err)
+	}
+}
+
+type pendingKey struct{}
+
+// Pending keeps the events recorded inside a transaction, so they are stored after the commit and a failed audit
+// doesn't abort the transaction
+type Pending struct {
+	events []interfaces.AuditEvent
+	mutex  sync.Mutex
+}
+
+// Defer Returns a copy of the context where the recorded events are kept in the returned pending events instead of
+// being stored
+func Defer(ctx context.Context) (context.Context, *Pending) {
+	pending := &Pending{}
+	return context.WithValue(ctx, pendingKey{}, pending), pending
+}
+
+// "private" method to keep the recorded event
+func (pending *Pending) add(event interfaces.AuditEvent) {
+	pending.mutex.Lock()
+	defer pending.mutex.Unlock()
+	pending.events = append(pending.events, event)
+}
+
+// Discard Drops the kept events (Eg. when the transaction is aborted or retried)
+func (pending *Pending) Discard() {
+	pending.mutex.Lock()
+	defer pending.mutex.Unlock()
+	pending.events = nil
+}
+
+// Flush Stores the kept events, the context must not belong to the transaction. Errors are logged but not returned
+func (pending *Pending) Flush(ctx context.Context) {
+	pending.mutex.Lock()
+	events := pending.events
+	pending.events = nil
+	pending.mutex.Unlock()
+
+	if len(events) == 0 {
+		return
+	}
+
+	documents := make([]interface{}, len(events))
+	for index, event := range events {
+		documents[index] = event
+	}
+
+	if _, err := EventsCollection.InsertMany(ctx, documents); err != nil {
+		fmt.Println("Unable to store the audit events:", err)
+	}
+}
+
+// FindEvents Returns the events matching the filter sorted from the newest to the oldest. The user filter matches
+// both the events made by the user and the events that changed their data
+func FindEvents(filter interfaces.AuditEventsFilter) ([]interfaces.AuditEvent, error) {
+	events := []interfaces.AuditEvent{}
+	query := bson.D{}
+
+	if !filter.UserId.IsZero() {
+		query = append(query, bson.E{Key: "$or", Value: bson.A{
+			bson.D{{Key: "actor_id", Value: filter.UserId}},
+			bson.D{{Key: "user_id", Value: filter.UserId}},
+		}})
+	}
+
+	if filter.Entity != "" {
+		query = append(query, bson.E{Key: "entity", Value: filter.Entity})
+	}
+
+	if !filter.EntityId.IsZero() {
+		query = append(query, bson.E{Key: "entity_id", Value: filter.EntityId})
+	}
+
+	if filter.From > 0 || filter.To > 0 {
+		createdAt := bson.D{}
+
+		if filter.From > 0 {
+			createdAt = append(createdAt, bson.E{Key: "$gte", Value: filter.From})
+		}
+
+		if filter.To > 0 {
+			createdAt = append(createdAt, bson.E{Key: "$lte", Value: filter.To})
+		}
+
+		query = append(query, bson.E{Key: "created_at", Value: createdAt})
+	}
+
+	collection, err := EventsCollection.Clone(options.Collection().SetRegistry(eventsRegistry))
+	if err != nil {
+		return events, err
+	}
+
+	cursor, err := collection.Find(
+		context.TODO(),
+		query,
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).SetLimit(filter.Limit),
+	)
+
+	if err != nil {
+		return events, err
+	}
+
+	err = cursor.All(context.TODO(), &events)
+	return events, err
+}
@@ -0,0 +1,67 @@
+// Command loomies-admin runs the maintenance tasks of the game world and the users
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/PedroChaparro/loomies-backend/configuration"
+	"github.com/PedroChaparro/loomies-backend/world"
+)
+
+const usage = `Usage: loomies-admin <command> [options]
+
+Commands:
+  generate-world    Generate the zones and gyms of a region from OpenStreetMap
+  grant-role        Grant a role (Eg. admin) to the user with the given email: grant-role <email> <role>`
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatal(usage)
+	}
+
+	// The tasks are stopped with Ctrl+C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var err error
+
+	switch os.Args[1] {
+	case "generate-world":
+		err = generateWorld(ctx, os.Args[2:])
+	case "grant-role":
+		err = grantRole(ctx, os.Args[2:])
+	default:
+		err = fmt.Errorf("unknown command %q\n%s", os.Args[1], usage)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// generateWorld runs the generate-world command with its own database connection
+func generateWorld(ctx context.Context, args []string) error {
+	options, err := world.ParseOptions(args)
+	if err != nil {
+		return err
+	}
+
+	config, err := configuration.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		return err
+	}
+
+	client, err := configuration.NewMongoClient(config.Mongo)
+	if err != nil {
+		return err
+	}
+
+	defer client.Disconnect(context.Background())
+
+	store := world.MongoStore{Database: client.Database(config.Mongo.Database)}
+	return world.Run(ctx, store, options, os.Stdout)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/PedroChaparro/loomies-backend/audit"
+	"github.com/PedroChaparro/loomies-backend/configuration"
+	"github.com/PedroChaparro/loomies-backend/models"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// grantRole runs the grant-role command, it's used to give the first admin role since the roles endpoint requires it
+func grantRole(ctx context.Context, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: loomies-admin grant-role <email> <role>")
+	}
+
+	email, role := args[0], args[1]
+
+	if !utils.IsValidRole(role) {
+		return fmt.Errorf("role %q doesn't exist", role)
+	}
+
+	config, err := configuration.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		return err
+	}
+
+	client, err := configuration.NewMongoClient(config.Mongo)
+	if err != nil {
+		return err
+	}
+
+	defer client.Disconnect(context.Background())
+
+	// The change is audited like the ones made with the roles endpoint
+	database := client.Database(config.Mongo.Database)
+	models.UseDatabase(database)
+	audit.UseDatabase(database)
+
+	user, err := models.GetUserByEmail(email)
+	if err == mongo.ErrNoDocuments {
+		return fmt.Errorf("user %s was not found", email)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, userRole := range user.Roles {
+		if userRole == role {
+			fmt.Printf("User %s already has the %s role\n", email, role)
+			return nil
+		}
+	}
+
+	if err := models.UpdateUserRoles(ctx, user.Id, append(user.Roles, role)); err != nil {
+		return err
+	}
+
+	fmt.Printf("Granted the %s role to %s. It will be applied when the user refreshes their access token\n", role, email)
+	return nil
+}
@@ -5,7 +5,7 @@ import (
 	"time"
 
 	"github.com/PedroChaparro/loomies-backend/interfaces"
-	"github.com/PedroChaparro/loomies-backend/models"
+	"github.com/PedroChaparro/loomies-backend/repositories"
 	"github.com/PedroChaparro/loomies-backend/utils"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -83,6 +83,7 @@ func handleSendAttack(combat *WsCombat) {
 
 		// Reduce the alive player loomies count
 		combat.AlivePlayerLoomies--
+		combat.WeakenedPlayerLoomies++
 
 		// Notify the user that the loomie was weakened
 		combat.SendMessage(WsMessage{
@@ -185,7 +186,7 @@ func handleReceiveAttack(combat *WsCombat) {
 	calculatedAttack, isCritical := calculateAttack(playerLoomie, gymLoomie)
 
 	// Check if the gym loomie dodged the attack
-	gymLoomieDodgeProbability := 10
+	gymLoomieDodgeProbability := combat.Balance.Get().GymDodgeProbability
 	luckyNumber := getRandomInt(1, 100)
 
 	if luckyNumber <= gymLoomieDodgeProbability {
@@ -206,6 +207,11 @@ func handleReceiveAttack(combat *WsCombat) {
 		weakenedLoomie := gymLoomie
 		combat.AliveGymLoomies--
 
+		err := combat.Repositories.Quests.TrackQuestProgress(combat.Context(), combat.PlayerID, repositories.QuestDefeatProtectorEvent, "", nil)
+		if err != nil {
+			fmt.Println("Unable to update the quests progress:", err)
+		}
+
 		// Notify the user that the gym loomie was weakened
 		combat.SendMessage(WsMessage{
 			Type:    "GYM_LOOMIE_WEAKENED",
@@ -274,7 +280,8 @@ func handleGymLoomieWeakened(combat *WsCombat, weakenedLoomieId primitive.Object
 	foughtWith := combat.FoughtGymLoomies[weakenedLoomieId]
 
 	// Calculates exp of Loomie weakened and its third part. It is divided in # of Loomies
-	expWeakenedLoomieId := utils.GetRequiredExperience(levelWeakenedLoomieId)
+	balance := combat.Balance.Get()
+	expWeakenedLoomieId := utils.GetRequiredExperience(levelWeakenedLoomieId, balance)
 	experienceToSet := (expWeakenedLoomieId / 3) / float64(len(foughtWith))
 
 	// adds the experience to each Loomie in foughtWith
@@ -285,10 +292,10 @@ func handleGymLoomieWeakened(combat *WsCombat, weakenedLoomieId primitive.Object
 		preLevel := playerLoomiePointer.Level
 
 		// calculates and sets new exp and lvl locally
-		playerLoomiePointer.Experience, playerLoomiePointer.Level = calculateLevelAndExperience(playerLoomiePointer.Experience, experienceToSet, playerLoomiePointer.Level)
+		playerLoomiePointer.Experience, playerLoomiePointer.Level = calculateLevelAndExperience(playerLoomiePointer.Experience, experienceToSet, playerLoomiePointer.Level, balance)
 
 		// updates and sets new exp and lvl in db
-		models.UpdateLoomiesExpAndLvl(combat.PlayerID, playerLoomiePointer)
+		combat.Repositories.Loomies.UpdateLoomiesExpAndLvl(combat.Context(), combat.PlayerID, playerLoomiePointer)
 
 		combat.SendMessage(WsMessage{
 			Type:    "UPDATE_USER_LOOMIE_EXP",
@@ -316,7 +323,7 @@ func handleGymLoomieWeakened(combat *WsCombat, weakenedLoomieId primitive.Object
 // handlePlayerVictory handles the "event" when the player wins the battle
 func handlePlayerVictory(combat *WsCombat) {
 	gymId, _ := primitive.ObjectIDFromHex(combat.GymID)
-	gymInfo, err := models.GetPopulatedGymFromId(gymId, combat.PlayerID)
+	gymInfo, err := combat.Repositories.Gyms.GetPopulatedGymFromId(gymId, combat.PlayerID)
 
 	if err != nil {
 		combat.SendMessage(WsMessage{
@@ -330,18 +337,6 @@ func handlePlayerVictory(combat *WsCombat) {
 		return
 	}
 
-	// Updates the loomie team of the new owner with an empty array
-	err = models.ReplaceLoomieTeam(combat.PlayerID, []primitive.ObjectID{})
-	if err != nil {
-		combat.SendMessage(WsMessage{
-			Type: "ERROR",
-			Payload: map[string]interface{}{
-				"error_type":    "INTERNAL_SERVER_ERROR",
-				"error_message": "Error updating your loomie team.",
-			},
-		})
-	}
-
 	// Obtains ids of new protectors
 	newGymProtectors := []primitive.ObjectID{}
 	currentGymProtectors := []primitive.ObjectID{}
@@ -354,25 +349,8 @@ func handlePlayerVictory(combat *WsCombat) {
 		currentGymProtectors = append(currentGymProtectors, gymLoomie.Id)
 	}
 
-	if gymInfo.Owner != "" {
-		// Updates the gym old protectors
-		err = models.UpdateLoomiesBusyState(currentGymProtectors, false)
-		if err != nil {
-			combat.SendMessage(WsMessage{
-				Type: "ERROR",
-				Payload: map[string]interface{}{
-					"error_type":    "INTERNAL_SERVER_ERROR",
-					"error_message": "Error updating the busy state of the old gym protectors.",
-				},
-			})
-		}
-	} else {
-		// Removes the gym old protectors
-		models.RemoveLoomieTeam(currentGymProtectors)
-	}
-
-	// Updates the gym news protectors and owner
-	err = models.UpdateGymProtectorsAndOwner(gymId, newGymProtectors, combat.PlayerID)
+	// Updates the gym protectors and owner, the old protectors are released and the new ones become busy
+	err = combat.Repositories.Gyms.TakeOverGym(combat.Context(), gymId, combat.PlayerID, newGymProtectors, currentGymProtectors, gymInfo.Owner != "")
 	if err != nil {
 		combat.SendMessage(WsMessage{
 			Type: "ERROR",
@@ -381,20 +359,30 @@ func handlePlayerVictory(combat *WsCombat) {
 				"error_message": "Error updating the gym protectors and owner.",
 			},
 		})
+
+		combat.Close <- true
+		return
 	}
 
-	// Updates the gym news protectors, is_busy propierties
-	err = models.UpdateLoomiesBusyState(newGymProtectors, true)
-	if err != nil {
+	// Let the previous owner know the gym was lost
+	if !gymInfo.OwnerId.IsZero() {
+		notifyGymLost(combat, gymInfo)
+	}
+
+	// Give the trainer the experience of the victory
+	progress, err := combat.Repositories.Users.AddTrainerExperience(combat.Context(), combat.PlayerID, combat.Config.Trainer.GymVictoryExperience)
+	if err == nil {
 		combat.SendMessage(WsMessage{
-			Type: "ERROR",
+			Type:    "TRAINER_EXPERIENCE",
+			Message: fmt.Sprintf("You have earned %v experience", progress.ExperienceGained),
 			Payload: map[string]interface{}{
-				"error_type":    "INTERNAL_SERVER_ERROR",
-				"error_message": "Error updating the busy state of the new gym protectors.",
+				"trainer": progress,
 			},
 		})
 	}
 
+	trackVictoryAchievements(combat)
+
 	combat.Close <- true
 }
 
@@ -433,7 +421,7 @@ func handleUseItem(combat *WsCombat, message WsMessage) {
 	}
 
 	// Check the item exists in the user inventory
-	item, err := models.GetItemFromUserInventory(combat.PlayerID, itemMongoId, false)
+	item, err := combat.Repositories.Items.GetItemFromUserInventory(combat.PlayerID, itemMongoId, false)
 
 	if err != nil {
 		if err.Error() == "USER_DOES_NOT_OWN_ITEM" {
@@ -514,7 +502,7 @@ func handleUseItem(combat *WsCombat, message WsMessage) {
 	}
 
 	// Decrement the item from the user inventory
-	err = models.DecrementItemFromUserInventory(combat.PlayerID, itemMongoId, 1)
+	err = combat.Repositories.Items.DecrementItemFromUserInventory(combat.Context(), combat.PlayerID, itemMongoId, 1)
 
 	if err != nil {
 		combat.SendMessage(WsMessage{
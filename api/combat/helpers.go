@@ -6,8 +6,10 @@ import (
 	"math/rand"
 	"time"
 
+	"github.com/PedroChaparro/loomies-backend/configuration"
+	"github.com/PedroChaparro/loomies-backend/email"
 	"github.com/PedroChaparro/loomies-backend/interfaces"
-	"github.com/PedroChaparro/loomies-backend/models"
+	"github.com/PedroChaparro/loomies-backend/repositories"
 	"github.com/PedroChaparro/loomies-backend/utils"
 )
 
@@ -22,7 +24,9 @@ func getRandomInt(min int, max int) int {
 
 // isTypeStrongAgainst returns true if the atacking type is strong against the defending type
 func isTypeStrongAgainst(atackingType string, defendingTypes []string) bool {
-	for _, strongAgainst := range GlobalWsHub.CachedStrongAgainst[atackingType] {
+	cachedStrongAgainst, _ := GlobalWsHub.GetCachedStrongAgainst(atackingType)
+
+	for _, strongAgainst := range cachedStrongAgainst {
 		for _, defendingType := range defendingTypes {
 			if strongAgainst == defendingType {
 				return true
@@ -38,11 +42,11 @@ func cacheTypeStrongAgainst(loomieTypes []string, combat *WsCombat) {
 	// For each type
 	for _, value := range loomieTypes {
 		// Check if the type was cached before
-		_, cached := GlobalWsHub.CachedStrongAgainst[value]
+		_, cached := GlobalWsHub.GetCachedStrongAgainst(value)
 
 		// If the type was not obtained before, get it from the database and cache it
 		if !cached {
-			typeDetails, err := models.GetLoomieTypeDetailsByName(value)
+			typeDetails, err := combat.Repositories.Content.GetLoomieTypeDetailsByName(value)
 
 			if err != nil {
 				combat.SendMessage(WsMessage{
@@ -53,8 +57,7 @@ func cacheTypeStrongAgainst(loomieTypes []string, combat *WsCombat) {
 				return
 			}
 
-			GlobalWsHub.CachedStrongAgainst[value] = make([]string, 0)
-			GlobalWsHub.CachedStrongAgainst[value] = typeDetails.StrongAgainst
+			GlobalWsHub.SetCachedStrongAgainst(value, typeDetails.StrongAgainst)
 		}
 	}
 }
@@ -92,13 +95,13 @@ func applyItem(combat *WsCombat, item *interfaces.PopulatedInventoryItem, loomie
 	switch item.Serial {
 	// Painkiller
 	case 1:
-		wasApplied := loomie.ApplyPainKillers()
+		wasApplied := loomie.ApplyPainKillers(combat.Balance.Get().PainKillersHeal)
 		if !wasApplied {
 			return fmt.Errorf("USER_ALREADY_HEALED")
 		}
 	// Small aid kit
 	case 2:
-		wasApplied := loomie.ApplySmallAidKit()
+		wasApplied := loomie.ApplySmallAidKit(combat.Balance.Get().SmallAidKitHeal)
 		if !wasApplied {
 			return fmt.Errorf("USER_ALREADY_HEALED")
 		}
@@ -126,7 +129,7 @@ func applyItem(combat *WsCombat, item *interfaces.PopulatedInventoryItem, loomie
 	// Unknown bevarage
 	case 7:
 		loomie.ApplyUnknownBevarage()
-		err := models.IncrementLoomieLevel(combat.PlayerID, loomie.Id, 1)
+		err := combat.Repositories.Loomies.IncrementLoomieLevel(combat.Context(), combat.PlayerID, loomie.Id, 1)
 
 		if err != nil {
 			return fmt.Errorf("SERVER_ERROR")
@@ -140,12 +143,12 @@ func applyItem(combat *WsCombat, item *interfaces.PopulatedInventoryItem, loomie
 
 // TODO generalize?
 // calculateLevelAndExperience calculates what is lvl and experience of a Loomie that weakened another one
-func calculateLevelAndExperience(loomieExperience float64, availableExperience float64, loomieLevel int) (float64, int) {
+func calculateLevelAndExperience(loomieExperience float64, availableExperience float64, loomieLevel int, balance configuration.TGameBalance) (float64, int) {
 	var experienceToAdd, neededExperienceToNextLevel float64
 
 	// Check if the loomie has leveled up
-	for (loomieExperience + availableExperience) >= utils.GetRequiredExperience(loomieLevel+1) {
-		neededExperienceToNextLevel = utils.GetRequiredExperience(loomieLevel+1) - loomieExperience
+	for (loomieExperience + availableExperience) >= utils.GetRequiredExperience(loomieLevel+1, balance) {
+		neededExperienceToNextLevel = utils.GetRequiredExperience(loomieLevel+1, balance) - loomieExperience
 		experienceToAdd = math.Min(availableExperience, neededExperienceToNextLevel)
 		experienceToAdd = utils.FixeFloat(experienceToAdd, 4)
 		loomieLevel++
@@ -187,3 +190,66 @@ func calulateExperienceFactorStats(baseStat int, level int) int {
 	experienceFactor := (1.0 + ((1.0 / 8.0) * (float64(level) - 1.0)))
 	return int(math.Floor(float64(baseStat) * experienceFactor))
 }
+
+// notifyGymLost sends an email to the previous owner of the gym
+func notifyGymLost(combat *WsCombat, gym interfaces.PopulatedGym) {
+	previousOwner, err := combat.Repositories.Users.GetUserById(gym.OwnerId.Hex())
+	if err != nil {
+		return
+	}
+
+	newOwner, err := combat.Repositories.Users.GetUserById(combat.PlayerID.Hex())
+	if err != nil {
+		return
+	}
+
+	// The templates show a generic name when the users blocked each other
+	newOwnerUsername := newOwner.Username
+	if utils.IsBlockedBetween(previousOwner, newOwner) {
+		newOwnerUsername = ""
+	}
+
+	combat.Mailer.Send(previousOwner.Email, previousOwner.Language, email.GymLostTemplate, email.GymLostData{
+		Username: previousOwner.Username,
+		GymName:  gym.Name,
+		NewOwner: newOwnerUsername,
+	})
+}
+
+// trackVictoryAchievements updates the achievements counters of the player after a victory and notifies the unlocked achievements
+func trackVictoryAchievements(combat *WsCombat) {
+	ctx := combat.Context()
+	unlocked := []interfaces.Achievement{}
+
+	achievements, err := combat.Repositories.Achievements.IncrementAchievementCounter(ctx, combat.PlayerID, repositories.AchievementGymVictoriesCounter, 1)
+	unlocked = append(unlocked, achievements...)
+
+	if err == nil && combat.WeakenedPlayerLoomies == 0 {
+		achievements, err = combat.Repositories.Achievements.IncrementAchievementCounter(ctx, combat.PlayerID, repositories.AchievementFlawlessVictoriesCounter, 1)
+		unlocked = append(unlocked, achievements...)
+	}
+
+	if err == nil {
+		var ownedGyms int64
+		ownedGyms, err = combat.Repositories.Gyms.CountGymsByOwner(combat.PlayerID)
+
+		if err == nil {
+			achievements, err = combat.Repositories.Achievements.SetAchievementCounterMax(ctx, combat.PlayerID, repositories.AchievementOwnedGymsCounter, int(ownedGyms))
+			unlocked = append(unlocked, achievements...)
+		}
+	}
+
+	if err != nil {
+		fmt.Println("Unable to update the achievements counters:", err)
+	}
+
+	for _, achievement := range unlocked {
+		combat.SendMessage(WsMessage{
+			Type:    "ACHIEVEMENT_UNLOCKED",
+			Message: fmt.Sprintf("You have unlocked the %s achievement", achievement.Name),
+			Payload: map[string]interface{}{
+				"achievement": achievement,
+			},
+		})
+	}
+}
@@ -1,12 +1,16 @@
 package combat
 
 import (
+	"context"
 	"encoding/json"
+	"sync"
 	"time"
 
+	"github.com/PedroChaparro/loomies-backend/audit"
 	"github.com/PedroChaparro/loomies-backend/configuration"
+	"github.com/PedroChaparro/loomies-backend/email"
 	"github.com/PedroChaparro/loomies-backend/interfaces"
-	"github.com/PedroChaparro/loomies-backend/models"
+	"github.com/PedroChaparro/loomies-backend/repositories"
 	"github.com/gorilla/websocket"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -17,6 +21,14 @@ type WsCombat struct {
 	PlayerID primitive.ObjectID
 	// We keep the gym id to easily remove it from the map when the combat ends
 	GymID string
+	// Id of the request that started the combat, it's stored in the audit events
+	RequestId string
+	// Data access used during the combat, it's injected by the handler that starts the combat
+	Repositories repositories.Repositories
+	// Settings, live game balance and mailer used during the combat, they are injected like the repositories
+	Config  *configuration.Config
+	Balance *configuration.LiveBalance
+	Mailer  *email.Mailer
 	// The connecton to exchange messages with the client
 	Connection *websocket.Conn
 	// Keep track of the last message timestamp to finish the combat if the client is "akf"
@@ -28,6 +40,8 @@ type WsCombat struct {
 	// Keep track of the alive user and gym loomies
 	AlivePlayerLoomies int
 	AliveGymLoomies    int
+	// Player loomies weakened during the combat (even if they were revived)
+	WeakenedPlayerLoomies int
 	// Loomie teams in combat
 	GymLoomies    []interfaces.CombatLoomie
 	PlayerLoomies []interfaces.CombatLoomie
@@ -58,31 +72,82 @@ type WsHub struct {
 	Combats map[string]*WsCombat
 	// Map to store the strong against types
 	CachedStrongAgainst map[string][]string
+	// Protects the cached types, they can be invalidated from the admin endpoints
+	cacheMutex sync.RWMutex
+	// Protects the combats, they are closed from the shutdown goroutine
+	combatsMutex sync.RWMutex
+	// The hub stops accepting combats when the server is shutting down
+	shuttingDown bool
+	// Tracks the combats until their outcome is stored
+	running sync.WaitGroup
 }
 
 // GlobalWsHub is the global hub that stores all the clients
-// This is initialized in the main.go file
+// This is initialized by the app when it's created
 var GlobalWsHub *WsHub
 
 // Includes checks if the hub already has a client for the gym
 func (hub *WsHub) Includes(gym string) bool {
+	hub.combatsMutex.RLock()
+	defer hub.combatsMutex.RUnlock()
+
 	_, ok := hub.Combats[gym]
 	return ok
 }
 
-// Register registers a new client to the hub
+// IsAccepting checks if new combats can be registered (the server is not shutting down)
+func (hub *WsHub) IsAccepting() bool {
+	hub.combatsMutex.RLock()
+	defer hub.combatsMutex.RUnlock()
+	return !hub.shuttingDown
+}
+
+// GetCachedStrongAgainst returns the cached strong against types of the given type
+func (hub *WsHub) GetCachedStrongAgainst(loomieType string) ([]string, bool) {
+	hub.cacheMutex.RLock()
+	defer hub.cacheMutex.RUnlock()
+
+	strongAgainst, ok := hub.CachedStrongAgainst[loomieType]
+	return strongAgainst, ok
+}
+
+// SetCachedStrongAgainst caches the strong against types of the given type
+func (hub *WsHub) SetCachedStrongAgainst(loomieType string, strongAgainst []string) {
+	hub.cacheMutex.Lock()
+	defer hub.cacheMutex.Unlock()
+
+	hub.CachedStrongAgainst[loomieType] = strongAgainst
+}
+
+// InvalidateTypesCache removes the cached types so they are obtained again from the database
+func (hub *WsHub) InvalidateTypesCache() {
+	hub.cacheMutex.Lock()
+	defer hub.cacheMutex.Unlock()
+
+	hub.CachedStrongAgainst = make(map[string][]string)
+}
+
+// Register registers a new client to the hub, returns false if the gym is already in combat or
+// the server is shutting down
 func (hub *WsHub) Register(gym string, combat *WsCombat) bool {
-	if hub.Includes(gym) {
+	hub.combatsMutex.Lock()
+	defer hub.combatsMutex.Unlock()
+
+	if _, ok := hub.Combats[gym]; ok || hub.shuttingDown {
 		return false
 	}
 
 	hub.Combats[gym] = combat
+	hub.running.Add(1)
 	return true
 }
 
 // Unregister removes a client from the hub
 func (hub *WsHub) Unregister(gym string) bool {
-	if !hub.Includes(gym) {
+	hub.combatsMutex.Lock()
+	defer hub.combatsMutex.Unlock()
+
+	if _, ok := hub.Combats[gym]; !ok {
 		return false
 	}
 
@@ -90,6 +155,47 @@ func (hub *WsHub) Unregister(gym string) bool {
 	return true
 }
 
+// Shutdown stops accepting combats, notifies the connected players and waits until the outcome of
+// the running combats is stored or the context is done
+func (hub *WsHub) Shutdown(ctx context.Context) error {
+	hub.combatsMutex.Lock()
+	hub.shuttingDown = true
+	combats := make([]*WsCombat, 0, len(hub.Combats))
+
+	for _, combat := range hub.Combats {
+		combats = append(combats, combat)
+	}
+	hub.combatsMutex.Unlock()
+
+	for _, combat := range combats {
+		combat.SendMessage(WsMessage{
+			Type:    "SERVER_SHUTDOWN",
+			Message: "The server is shutting down, the combat has ended",
+		})
+
+		// Closing the connection stops the listener, so the combat is finished as if the player left
+		combat.Connection.Close()
+	}
+
+	finished := make(chan bool)
+	go func() {
+		hub.running.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Context returns the context used to perform the combat changes in the database on behalf of the player
+func (combat *WsCombat) Context() context.Context {
+	return audit.WithActor(context.Background(), combat.PlayerID, combat.RequestId)
+}
+
 // UpdatedLastReceivedMessageTimestamp updates the timestamp of the last message received from the client
 func (combat *WsCombat) UpdatedLastReceivedMessageTimestamp() {
 	combat.LastMessageTimestamp = time.Now().Unix()
@@ -114,7 +220,9 @@ func (combat *WsCombat) Listen(hub *WsHub) {
 
 		// Mark the combat as closed
 		gymIdMongo, _ := primitive.ObjectIDFromHex(combat.GymID)
-		models.FinishGymChallenge(gymIdMongo, combat.PlayerID)
+		combat.Repositories.Challenges.FinishGymChallenge(gymIdMongo, combat.PlayerID)
+
+		hub.running.Done()
 	}()
 
 	// --- Independent goroutine to check if the client is inactive ---
@@ -144,8 +252,9 @@ func (combat *WsCombat) Listen(hub *WsHub) {
 
 	// --- Independet goroutine to send attacks from the gym to the player ---
 	go func() {
-		minTimeout, maxTimeout := configuration.GetCombatTimeouts()
-		randomSeconds := getRandomInt(minTimeout, maxTimeout)
+		// The timeouts are read on each attack, so the balance changes also apply to the running combats
+		balance := combat.Balance.Get()
+		randomSeconds := getRandomInt(balance.MinCombatAttackTimeout, balance.MaxCombatAttackTimeout)
 		ticker := time.NewTicker(time.Duration(randomSeconds) * time.Second)
 
 		for {
@@ -163,7 +272,8 @@ func (combat *WsCombat) Listen(hub *WsHub) {
 
 			// Reset the ticker and pick a new random interval
 			ticker.Stop()
-			randomSeconds := getRandomInt(minTimeout, maxTimeout)
+			balance := combat.Balance.Get()
+			randomSeconds := getRandomInt(balance.MinCombatAttackTimeout, balance.MaxCombatAttackTimeout)
 			ticker = time.NewTicker(time.Duration(randomSeconds) * time.Second)
 		}
 	}()
@@ -0,0 +1,128 @@
+package configuration
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// balanceVersion stores the game balance published in the database and its version
+type balanceVersion struct {
+	version int
+	balance TGameBalance
+}
+
+// LiveBalance stores the game balance in use: the last version published by the admins or the defaults of the
+// configuration if no version was published. The app creates it and shares it with the repositories (that publish
+// the versions) and the handlers and combats (that read them)
+type LiveBalance struct {
+	defaults TGameBalance
+	// The balance is swapped as a whole, so the readers never see a mix of two versions
+	current atomic.Pointer[balanceVersion]
+}
+
+// NewLiveBalance creates the live balance of the game that uses the given values until a version is published
+func NewLiveBalance(defaults TGameBalance) *LiveBalance {
+	return &LiveBalance{defaults: defaults}
+}
+
+// Balance returns the tuning values of the game stored in the settings
+func (game TGameSettings) Balance() TGameBalance {
+	return TGameBalance{
+		WildLoomiesTTL:              game.WildLoomiesTTL,
+		MinLoomiesGenerationTimeout: game.MinLoomiesGenerationTimeout,
+		MaxLoomiesGenerationTimeout: game.MaxLoomiesGenerationTimeout,
+		MinLoomiesGenerationAmount:  game.MinLoomiesGenerationAmount,
+		MaxLoomiesGenerationAmount:  game.MaxLoomiesGenerationAmount,
+		MinLoomieRequiredExperience: game.MinLoomieRequiredExperience,
+		LoomieExperienceFactor:      game.LoomieExperienceFactor,
+		MinCombatAttackTimeout:      game.MinCombatAttackTimeout,
+		MaxCombatAttackTimeout:      game.MaxCombatAttackTimeout,
+		CombatChallengeTimeout:      game.CombatChallengeTimeout,
+		GymDodgeProbability:         game.GymDodgeProbability,
+		PainKillersHeal:             game.PainKillersHeal,
+		SmallAidKitHeal:             game.SmallAidKitHeal,
+	}
+}
+
+// Validate checks the ranges of the tuning values, returns the problems found
+func (balance TGameBalance) Validate() []string {
+	return validateBalance(balance, "")
+}
+
+// validateBalance "private" function to check the ranges of the tuning values, the prefix is added to the keys
+// in the problems
+func validateBalance(balance TGameBalance, prefix string) []string {
+	var problems []string
+
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, prefix+fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(balance.WildLoomiesTTL > 0, "wild_loomies_ttl must be greater than 0")
+	check(balance.MinLoomiesGenerationTimeout >= 0, "min_loomies_generation_timeout can't be negative")
+	check(balance.MinLoomiesGenerationTimeout <= balance.MaxLoomiesGenerationTimeout, "min_loomies_generation_timeout (%d) must be less than or equal to %smax_loomies_generation_timeout (%d)", balance.MinLoomiesGenerationTimeout, prefix, balance.MaxLoomiesGenerationTimeout)
+	check(balance.MinLoomiesGenerationAmount >= 0, "min_loomies_generation_amount can't be negative")
+	check(balance.MinLoomiesGenerationAmount <= balance.MaxLoomiesGenerationAmount, "min_loomies_generation_amount (%d) must be less than or equal to %smax_loomies_generation_amount (%d)", balance.MinLoomiesGenerationAmount, prefix, balance.MaxLoomiesGenerationAmount)
+	check(balance.MinLoomieRequiredExperience > 0, "loomie_min_required_experience must be greater than 0")
+	check(balance.LoomieExperienceFactor > 0, "loomie_experience_factor must be greater than 0")
+	check(balance.MinCombatAttackTimeout > 0, "combat_minimum_attack_timeout must be greater than 0")
+	check(balance.MinCombatAttackTimeout <= balance.MaxCombatAttackTimeout, "combat_minimum_attack_timeout (%d) must be less than or equal to %scombat_maximum_attack_timeout (%d)", balance.MinCombatAttackTimeout, prefix, balance.MaxCombatAttackTimeout)
+	check(balance.CombatChallengeTimeout >= 0, "combat_challenge_timeout can't be negative")
+	check(balance.GymDodgeProbability >= 0 && balance.GymDodgeProbability <= 100, "combat_gym_dodge_probability must be between 0 and 100")
+	check(balance.PainKillersHeal > 0, "items_painkillers_heal must be greater than 0")
+	check(balance.SmallAidKitHeal > 0, "items_small_aid_kit_heal must be greater than 0")
+
+	return problems
+}
+
+// Get returns the game balance in use: the last version published with Set or the defaults if no version was
+// published
+func (live *LiveBalance) Get() TGameBalance {
+	if current := live.current.Load(); current != nil {
+		return current.balance
+	}
+
+	return live.defaults
+}
+
+// Version returns the version of the game balance in use, 0 means the defaults are used
+func (live *LiveBalance) Version() int {
+	if current := live.current.Load(); current != nil {
+		return current.version
+	}
+
+	return 0
+}
+
+// Defaults returns the game balance used when no version was published
+func (live *LiveBalance) Defaults() TGameBalance {
+	return live.defaults
+}
+
+// Set replaces the game balance in use if it's valid. The older versions are ignored, so the updates received out
+// of order don't revert the balance
+func (live *LiveBalance) Set(version int, balance TGameBalance) error {
+	if problems := balance.Validate(); len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+
+	next := &balanceVersion{version: version, balance: balance}
+
+	for {
+		previous := live.current.Load()
+		if previous != nil && previous.version >= version {
+			return nil
+		}
+
+		if live.current.CompareAndSwap(previous, next) {
+			return nil
+		}
+	}
+}
+
+// Reset goes back to the defaults
+func (live *LiveBalance) Reset() {
+	live.current.Store(nil)
+}
@@ -0,0 +1,45 @@
+package configuration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestGameBalanceSwap tests the published versions replace the configuration values and the older ones are ignored
+func TestGameBalanceSwap(t *testing.T) {
+	c := require.New(t)
+	setRequiredEnvironment(t)
+
+	config, err := Load("")
+	c.NoError(err)
+	live := NewLiveBalance(config.Game.Balance())
+
+	// 1. The configuration values are used until a version is published
+	c.Equal(0, live.Version())
+	c.Equal(config.Game.Balance(), live.Get())
+	c.Equal(10, live.Get().GymDodgeProbability)
+
+	// 2. The new versions are used
+	balance := config.Game.Balance()
+	balance.GymDodgeProbability = 25
+	c.NoError(live.Set(2, balance))
+	c.Equal(2, live.Version())
+	c.Equal(25, live.Get().GymDodgeProbability)
+
+	// 3. The older versions are ignored
+	balance.GymDodgeProbability = 5
+	c.NoError(live.Set(1, balance))
+	c.Equal(25, live.Get().GymDodgeProbability)
+
+	// 4. The invalid versions are rejected
+	balance.MinCombatAttackTimeout = 10
+	err = live.Set(3, balance)
+	c.Error(err)
+	c.Contains(err.Error(), "combat_minimum_attack_timeout (10) must be less than or equal to combat_maximum_attack_timeout (3)")
+	c.Equal(2, live.Version())
+
+	// 5. The configuration values are used again after a reset
+	live.Reset()
+	c.Equal(config.Game.Balance(), live.Get())
+}
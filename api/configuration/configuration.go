@@ -2,259 +2,390 @@ package configuration
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"io/fs"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/yaml.v3"
 )
 
-var Globals = TGlobals{}
+// ConfigError lists all the problems found when loading the configuration
+type ConfigError struct {
+	Problems []string
+}
 
-// load loads the .env file if the environment is not production and create connections
-func load() {
-	environment := os.Getenv("ENVIRONMENT")
+func (err *ConfigError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(err.Problems, "\n  - ")
+}
 
-	// If the environment is PRODUCTION, do not load the .env file
-	if environment == "PRODUCTION" {
-		return
-	}
+// setting is a value of the configuration with the tags of its field
+type setting struct {
+	value reflect.Value
+	field reflect.StructField
+	key   string
+}
 
-	// Load the .env file if the environment is not production
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file")
+// name "private" function to get the name of the setting used in the errors
+func (setting setting) name() string {
+	if env := setting.field.Tag.Get("env"); env != "" {
+		return fmt.Sprintf("%s (%s)", setting.key, env)
 	}
 
-	Globals.Environment = environment
-	Globals.Loaded = true
+	return setting.key
 }
 
-// GetRunningEnvironment returns the value of the ENVIRONMENT environment variable
-func GetRunningEnvironment() string {
-	if Globals.Loaded == false {
-		load()
-	}
+// Prefix of the environment variables of the OpenID Connect providers (OIDC_<NAME>_<SETTING>)
+const oidcEnvironmentPrefix = "OIDC_"
+
+// Scopes requested to the OpenID Connect providers that don't configure them
+const defaultOIDCScopes = "openid email profile"
+
+var dotEnvOnce sync.Once
+var dotEnvError error
 
-	return Globals.Environment
+// loadDotEnv "private" function to load the .env file (once) if the environment is not production.
+// A missing .env file is not an error, the settings can be given by the environment or the configuration file
+func loadDotEnv() error {
+	dotEnvOnce.Do(func() {
+		if os.Getenv("ENVIRONMENT") == "PRODUCTION" {
+			return
+		}
+
+		if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			dotEnvError = fmt.Errorf("unable to load the .env file: %w", err)
+		}
+	})
+
+	return dotEnvError
 }
 
-// GetEnvironmentVariable returns the value of the environment variable with the given name
-func GetEnvironmentVariable(name string) string {
-	if Globals.Loaded == false {
-		load()
+// Load reads the configuration from the defaults, the given file (can be empty) and the environment variables,
+// the returned error is a *ConfigError with all the problems found
+func Load(file string) (*Config, error) {
+	var problems []string
+	config := &Config{}
+	settings := collectSettings(reflect.ValueOf(config).Elem(), "")
+
+	if err := loadDotEnv(); err != nil {
+		problems = append(problems, err.Error())
 	}
 
-	value := os.Getenv(name)
+	// 1. Defaults
+	for _, setting := range settings {
+		if value, ok := setting.field.Tag.Lookup("default"); ok {
+			if err := parseSetting(setting.value, value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s has an invalid default: %s", setting.name(), err))
+			}
+		}
+	}
 
-	if value == "" {
-		log.Fatal(name + " not set")
+	// 2. Configuration file
+	if file != "" {
+		if err := decodeFile(file, config); err != nil {
+			problems = append(problems, err.Error())
+		}
 	}
 
-	return value
-}
+	normalizeOIDCProviders(config)
+
+	// 3. Environment variables
+	for _, setting := range settings {
+		env := setting.field.Tag.Get("env")
+		value := os.Getenv(env)
+
+		if env == "" || value == "" {
+			continue
+		}
 
-// GetAccessTokenSecret returns the value of the ACCESS_TOKEN_SECRET environment variable and update the global variable if it is empty
-func GetAccessTokenSecret() string {
-	if Globals.AccessTokenSecret == "" {
-		accessTokenSecret := GetEnvironmentVariable("ACCESS_TOKEN_SECRET")
-		Globals.AccessTokenSecret = accessTokenSecret
+		if err := parseSetting(setting.value, value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %q %s", setting.name(), value, err))
+		}
 	}
 
-	return Globals.AccessTokenSecret
-}
+	applyOIDCEnvironment(config)
 
-// GetWsTokenSecret returns the value of the WS_TOKEN_SECRET environment variable and update the global variable if it is empty
-func GetWsTokenSecret() string {
-	if Globals.WsTokenSecret == "" {
-		wsTokenSecret := GetEnvironmentVariable("WS_TOKEN_SECRET")
-		Globals.WsTokenSecret = wsTokenSecret
+	for _, setting := range settings {
+		if setting.field.Tag.Get("required") == "true" && setting.value.IsZero() {
+			problems = append(problems, fmt.Sprintf("%s is required", setting.name()))
+		}
 	}
 
-	return Globals.WsTokenSecret
-}
+	applyEmailDefaults(config)
+	applyOIDCDefaults(config)
+	problems = append(problems, validate(config)...)
 
-// GetRefreshTokenSecret returns the value of the REFRESH_TOKEN_SECRET environment variable and update the global variable if it is empty
-func GetRefreshTokenSecret() string {
-	if Globals.RefreshTokenSecret == "" {
-		refreshTokenSecret := GetEnvironmentVariable("REFRESH_TOKEN_SECRET")
-		Globals.RefreshTokenSecret = refreshTokenSecret
+	if len(problems) > 0 {
+		return nil, &ConfigError{Problems: problems}
 	}
 
-	return Globals.RefreshTokenSecret
+	return config, nil
 }
 
-// GetWildLoomiesTTL Returns the value of the GAME_WILD_LOOMIES_TTL environment variable and update the global variable if it is empty
-func GetWildLoomiesTTL() int {
-	if Globals.WildLoomiesTTL == 0 {
-		// Get the value (as a string) from the environment
-		wildLoomiesTTLString := GetEnvironmentVariable("GAME_WILD_LOOMIES_TTL")
+// collectSettings "private" function to get the values of the struct (and the nested structs) as settings
+func collectSettings(value reflect.Value, prefix string) []setting {
+	var settings []setting
 
-		// Convert the string to an integer
-		wildLoomiesTTL, _ := strconv.Atoi(wildLoomiesTTLString)
+	for index := 0; index < value.NumField(); index++ {
+		field := value.Type().Field(index)
+		key := prefix + field.Tag.Get("yaml")
 
-		// Set the value in the globals
-		Globals.WildLoomiesTTL = wildLoomiesTTL
+		if field.Type.Kind() == reflect.Struct {
+			settings = append(settings, collectSettings(value.Field(index), key+".")...)
+			continue
+		}
+
+		settings = append(settings, setting{value: value.Field(index), field: field, key: key})
 	}
 
-	return Globals.WildLoomiesTTL
+	return settings
 }
 
-// GetLoomiesGenerationTimeouts returns the values of the GAME_MIN_LOOMIES_GENERATION_TIMEOUT and GAME_MAX_LOOMIES_GENERATION_TIMEOUT environment variables and update the global variables if they are empty
-func GetLoomiesGenerationTimeouts() (int, int) {
-	if Globals.MinLoomiesGenerationTimeout == 0 || Globals.MaxLoomiesGenerationTimeout == 0 {
-		// Get values (as strings) from the environment
-		minLoomiesGenerationTimeoutString := GetEnvironmentVariable("GAME_MIN_LOOMIES_GENERATION_TIMEOUT")
-		maxLoomiesGenerationTimeoutString := GetEnvironmentVariable("GAME_MAX_LOOMIES_GENERATION_TIMEOUT")
+// parseSetting "private" function to parse the raw value according to the kind of the setting
+func parseSetting(value reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return errors.New("is not a valid integer")
+		}
 
-		// Convert the strings to integers
-		minLoomiesGenerationTimeout, _ := strconv.Atoi(minLoomiesGenerationTimeoutString)
-		maxLoomiesGenerationTimeout, _ := strconv.Atoi(maxLoomiesGenerationTimeoutString)
+		value.SetInt(parsed)
+	case reflect.Float64:
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return errors.New("is not a valid number")
+		}
 
-		// Set the values in the globals
-		Globals.MinLoomiesGenerationTimeout = minLoomiesGenerationTimeout
-		Globals.MaxLoomiesGenerationTimeout = maxLoomiesGenerationTimeout
+		value.SetFloat(parsed)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return errors.New("is not a valid boolean")
+		}
+
+		value.SetBool(parsed)
+	default:
+		return fmt.Errorf("has an unsupported type %s", value.Kind())
 	}
 
-	return Globals.MinLoomiesGenerationTimeout, Globals.MaxLoomiesGenerationTimeout
+	return nil
 }
 
-// GetLoomiesGenerationAmounts returns the values of the GAME_MIN_LOOMIES_GENERATION_AMOUNT and GAME_MAX_LOOMIES_GENERATION_AMOUNT environment variables and update the global variables if they are empty
-func GetLoomiesGenerationAmounts() (int, int) {
-	if Globals.MinLoomiesGenerationAmount == 0 || Globals.MaxLoomiesGenerationAmount == 0 {
-		// Get values (as strings) from the environment
-		minLoomiesGenerationAmountString := GetEnvironmentVariable("GAME_MIN_LOOMIES_GENERATION_AMOUNT")
-		maxLoomiesGenerationAmountString := GetEnvironmentVariable("GAME_MAX_LOOMIES_GENERATION_AMOUNT")
+// decodeFile "private" function to read the YAML or TOML configuration file (according to its extension) over
+// the given configuration. Unknown keys are reported to avoid ignoring typos
+func decodeFile(file string, config *Config) error {
+	content, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("unable to open the configuration file: %w", err)
+	}
+	defer content.Close()
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(content)
+		decoder.KnownFields(true)
+		err = decoder.Decode(config)
 
-		// Convert the strings to integers
-		minLoomiesGenerationAmount, _ := strconv.Atoi(minLoomiesGenerationAmountString)
-		maxLoomiesGenerationAmount, _ := strconv.Atoi(maxLoomiesGenerationAmountString)
+		// An empty file only uses the defaults
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	case ".toml":
+		err = toml.NewDecoder(content).DisallowUnknownFields().Decode(config)
+	default:
+		return fmt.Errorf("the configuration file %s must be a .yaml, .yml or .toml file", file)
+	}
 
-		// Set the values in the globals
-		Globals.MinLoomiesGenerationAmount = minLoomiesGenerationAmount
-		Globals.MaxLoomiesGenerationAmount = maxLoomiesGenerationAmount
+	if err != nil {
+		return fmt.Errorf("unable to read the configuration file %s: %s", file, err)
 	}
 
-	return Globals.MinLoomiesGenerationAmount, Globals.MaxLoomiesGenerationAmount
+	return nil
 }
 
-// GetLoomiesGenerationRadius returns the value of the GAME_LOOMIES_GENERATION_RADIUS environment variable and update the global variable if it is empty
-func GetLoomiesGenerationRadius() float64 {
-	if Globals.LoomiesGenerationRadius == 0 {
-		// Get value (as string) from the environment
-		loomiesGenerationRadiusString := GetEnvironmentVariable("GAME_LOOMIES_GENERATION_RADIUS")
+// applyEmailDefaults "private" function to set the email settings that depend on the environment
+func applyEmailDefaults(config *Config) {
+	config.Email.Driver = strings.ToLower(config.Email.Driver)
 
-		// Convert the string to float64
-		loomiesGenerationRadius, _ := strconv.ParseFloat(loomiesGenerationRadiusString, 64)
+	if config.Email.Driver == "" {
+		config.Email.Driver = "smtp"
 
-		// Set the value in the globals
-		Globals.LoomiesGenerationRadius = loomiesGenerationRadius
+		if config.Environment == "TESTING" {
+			config.Email.Driver = "outbox"
+		}
 	}
 
-	return Globals.LoomiesGenerationRadius
+	if config.Email.Driver == "outbox" && config.Email.OutboxDir == "" {
+		config.Email.OutboxDir = filepath.Join(os.TempDir(), "loomies-outbox")
+	}
 }
 
-// GetMaxLoomiesPerZone returns the value of the GAME_MAX_LOOMIES_PER_ZONE environment variable and update the global variable if it is empty
-func GetMaxLoomiesPerZone() int {
-	if Globals.MaxLoomiesPerZone == 0 {
-		// Get value (as string) from the environment
-		maxLoomiesPerZoneString := GetEnvironmentVariable("GAME_MAX_LOOMIES_PER_ZONE")
+// normalizeOIDCProviders "private" function to store the providers of the configuration file by their name in lower
+// case, so the environment variables (in upper case) override the same provider
+func normalizeOIDCProviders(config *Config) {
+	providers := make(map[string]TOIDCProvider, len(config.OIDC))
 
-		// Convert the string to integer
-		maxLoomiesPerZone, _ := strconv.Atoi(maxLoomiesPerZoneString)
-
-		// Set the value in the globals
-		Globals.MaxLoomiesPerZone = maxLoomiesPerZone
+	for name, provider := range config.OIDC {
+		providers[strings.ToLower(name)] = provider
 	}
 
-	return Globals.MaxLoomiesPerZone
+	config.OIDC = providers
 }
 
-// GetLoomiesExperienceParameters Returns the values of the GAME_LOOMIE_MIN_REQUIRED_EXPERIENCE and GAME_LOOMIE_EXPERIENCE_FACTOR environment variables and update the global variables if they are empty
-func GetLoomiesExperienceParameters() (float64, float64) {
-	if Globals.MinLoomieRequiredExperience == 0 || Globals.LoomieExperienceFactor == 0 {
-		// Get values (as strings) from the environment
-		minLoomieRequiredExperienceString := GetEnvironmentVariable("GAME_LOOMIE_MIN_REQUIRED_EXPERIENCE")
-		loomieExperienceFactorString := GetEnvironmentVariable("GAME_LOOMIE_EXPERIENCE_FACTOR")
+// applyOIDCEnvironment "private" function to read the OIDC_<NAME>_<SETTING> environment variables over the providers
+// of the configuration file. The settings are matched by the env tag of the TOIDCProvider fields
+func applyOIDCEnvironment(config *Config) {
+	fields := reflect.TypeOf(TOIDCProvider{})
+
+	for _, variable := range os.Environ() {
+		key, value, _ := strings.Cut(variable, "=")
+		if !strings.HasPrefix(key, oidcEnvironmentPrefix) || value == "" {
+			continue
+		}
 
-		// Convert the strings to integers
-		minLoomieRequiredExperience, _ := strconv.ParseFloat(minLoomieRequiredExperienceString, 64)
-		loomieExperienceFactor, _ := strconv.ParseFloat(loomieExperienceFactorString, 64)
+		rest := strings.TrimPrefix(key, oidcEnvironmentPrefix)
 
-		// Set the values in the globals
-		Globals.MinLoomieRequiredExperience = minLoomieRequiredExperience
-		Globals.LoomieExperienceFactor = loomieExperienceFactor
-	}
+		for index := 0; index < fields.NumField(); index++ {
+			suffix := "_" + fields.Field(index).Tag.Get("env")
+			if suffix == "_" || len(rest) <= len(suffix) || !strings.HasSuffix(rest, suffix) {
+				continue
+			}
 
-	return Globals.MinLoomieRequiredExperience, Globals.LoomieExperienceFactor
+			name := strings.ToLower(strings.TrimSuffix(rest, suffix))
+			provider := config.OIDC[name]
+			reflect.ValueOf(&provider).Elem().Field(index).SetString(strings.TrimSpace(value))
+			config.OIDC[name] = provider
+			break
+		}
+	}
 }
 
-// GetCombatTimeouts returns the values of the GAME_COMBAT_MINIMUM_ATTACK_TIMEOUT and GAME_COMBAT_MAXIMUM_ATTACK_TIMEOUT environment variables and update the global variables if they are empty
-func GetCombatTimeouts() (int, int) {
-	if Globals.MinCombatAttackTimeout == 0 || Globals.MaxCombatAttackTimeout == 0 {
-		// Get values (as strings) from the environment
-		minCombatAttackTimeoutString := GetEnvironmentVariable("GAME_COMBAT_MINIMUM_ATTACK_TIMEOUT")
-		maxCombatAttackTimeoutString := GetEnvironmentVariable("GAME_COMBAT_MAXIMUM_ATTACK_TIMEOUT")
+// applyOIDCDefaults "private" function to set the name and the default scopes of the OpenID Connect providers
+func applyOIDCDefaults(config *Config) {
+	for name, provider := range config.OIDC {
+		provider.Name = name
 
-		// Convert the strings to integers
-		minCombatAttackTimeout, _ := strconv.Atoi(minCombatAttackTimeoutString)
-		maxCombatAttackTimeout, _ := strconv.Atoi(maxCombatAttackTimeoutString)
+		if provider.Scopes == "" {
+			provider.Scopes = defaultOIDCScopes
+		}
+
+		config.OIDC[name] = provider
+	}
+}
 
-		// Set the values in the globals
-		Globals.MinCombatAttackTimeout = minCombatAttackTimeout
-		Globals.MaxCombatAttackTimeout = maxCombatAttackTimeout
+// isAbsoluteUrl "private" function to check the value is an absolute url, the http(s) scheme is required if secure
+// is true (the redirect uri can use the custom scheme of the app)
+func isAbsoluteUrl(value string, secure bool) bool {
+	parsed, err := url.Parse(value)
+	if err != nil || !parsed.IsAbs() {
+		return false
 	}
 
-	return Globals.MinCombatAttackTimeout, Globals.MaxCombatAttackTimeout
+	return !secure || ((parsed.Scheme == "https" || parsed.Scheme == "http") && parsed.Host != "")
 }
 
-// GetCombatChallengeTimeout returns the value of the GAME_COMBAT_CHALLENGE_TIMEOUT environment variable and update the global variable if it is empty
-func GetCombatChallengeTimeout() int {
-	if Globals.CombatChallengeTimeout == 0 {
-		// Get value (as string) from the environment
-		combatChallengeTimeoutString := GetEnvironmentVariable("GAME_COMBAT_CHALLENGE_TIMEOUT")
+// validate "private" function to check the ranges of the settings, returns the problems found
+func validate(config *Config) []string {
+	var problems []string
 
-		// Convert the string to integer
-		combatChallengeTimeout, _ := strconv.Atoi(combatChallengeTimeoutString)
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
 
-		// Set the value in the globals
-		Globals.CombatChallengeTimeout = combatChallengeTimeout
+	game := config.Game
+	check(config.Server.Port > 0 && config.Server.Port <= 65535, "server.port must be between 1 and 65535")
+	// The distances used to be in degrees, so the values lower than a meter are probably outdated
+	check(game.InteractionRadius >= 1, "game.interaction_radius must be at least 1 (meter)")
+	check(game.VisibilityRadius >= game.InteractionRadius, "game.visibility_radius (%v) must be greater than or equal to game.interaction_radius (%v)", game.VisibilityRadius, game.InteractionRadius)
+	check(game.LoomiesGenerationRadius >= 1, "game.loomies_generation_radius must be at least 1 (meter)")
+	check(game.MaxLoomiesPerZone > 0, "game.max_loomies_per_zone must be greater than 0")
+	problems = append(problems, validateBalance(game.Balance(), "game.")...)
+
+	trainer := config.Trainer
+	check(trainer.BaseExperience > 0, "trainer.base_experience must be greater than 0")
+	check(trainer.ExperienceExponent > 0, "trainer.experience_exponent must be greater than 0")
+	check(trainer.MaxLevel >= 1, "trainer.max_level must be at least 1")
+	check(trainer.CaptureExperience >= 0 && trainer.FuseExperience >= 0 && trainer.GymVictoryExperience >= 0 && trainer.ClaimRewardExperience >= 0, "the trainer experience rewards can't be negative")
+
+	check(config.Trade.MaxDistance >= 1, "trade.max_distance must be at least 1 (meter)")
+	check(config.Trade.ConfirmationTimeout > 0, "trade.confirmation_timeout must be greater than 0")
+	check(config.Trade.TradeTTL > 0, "trade.ttl must be greater than 0")
+
+	gift := config.Gift
+	check(gift.GiftsPerDay >= 0, "gift.gifts_per_day can't be negative")
+	check(gift.MinRewards >= 0, "gift.min_rewards can't be negative")
+	check(gift.MinRewards <= gift.MaxRewards, "gift.min_rewards (%d) must be less than or equal to gift.max_rewards (%d)", gift.MinRewards, gift.MaxRewards)
+
+	email := config.Email
+	check(email.Driver == "smtp" || email.Driver == "outbox", "email.driver (EMAIL_DRIVER) must be \"smtp\" or \"outbox\"")
+
+	if email.Driver == "smtp" {
+		check(email.Sender != "", "email.sender (EMAIL_MAIL) is required by the smtp driver")
+		check(email.Password != "", "email.password (EMAIL_PASSWORD) is required by the smtp driver")
+		check(email.SmtpPort > 0 && email.SmtpPort <= 65535, "email.smtp_port must be between 1 and 65535")
 	}
 
-	return Globals.CombatChallengeTimeout
-}
+	if config.Scheduler.Enabled {
+		_, err := time.LoadLocation(config.Scheduler.Timezone)
+		check(err == nil, "scheduler.timezone (SCHEDULER_TIMEZONE) must be a valid IANA time zone (Eg. America/Bogota)")
+	}
 
-// getMongoClient returns a MongoDB client
-func getMongoClient() *mongo.Client {
-	// Create the connection if it does not exist
-	if Globals.MongoClient == nil {
-		load()
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-
-		// Connect to mongo db using the user and password from the .env file
-		user := GetEnvironmentVariable("MONGO_USER")
-		password := GetEnvironmentVariable("MONGO_PASSWORD")
-		hosts := GetEnvironmentVariable("MONGO_HOSTS")
-		uri := fmt.Sprintf("mongodb://%s:%s@%s", user, password, hosts)
-
-		client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
-		if err != nil {
-			log.Fatal("Error connecting to MongoDB", err)
+	names := make([]string, 0, len(config.OIDC))
+	for name := range config.OIDC {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		provider := config.OIDC[name]
+		key := func(setting string, env string) string {
+			return fmt.Sprintf("oidc.%s.%s (%s%s_%s)", name, setting, oidcEnvironmentPrefix, strings.ToUpper(name), env)
 		}
 
-		Globals.MongoClient = client
+		check(provider.ClientId != "", "%s is required", key("client_id", "CLIENT_ID"))
+		check(isAbsoluteUrl(provider.Issuer, true), "%s must be an http(s) url", key("issuer", "ISSUER"))
+		check(isAbsoluteUrl(provider.AuthorizationEndpoint, true), "%s must be an http(s) url", key("authorization_endpoint", "AUTHORIZATION_ENDPOINT"))
+		check(isAbsoluteUrl(provider.TokenEndpoint, true), "%s must be an http(s) url", key("token_endpoint", "TOKEN_ENDPOINT"))
+		check(isAbsoluteUrl(provider.JwksUri, true), "%s must be an http(s) url", key("jwks_uri", "JWKS_URI"))
+		check(isAbsoluteUrl(provider.RedirectUri, false), "%s must be an absolute url", key("redirect_uri", "REDIRECT_URI"))
 	}
 
-	return Globals.MongoClient
+	return problems
 }
 
-// ConnectToMongoCollection Connects to a MongoDB collection and returns it
-func ConnectToMongoCollection(collectionName string) *mongo.Collection {
-	client := getMongoClient()
-	return client.Database(os.Getenv("MONGO_DATABASE")).Collection(collectionName)
+// OIDCProvider returns the settings of the OpenID Connect provider with the given name and a boolean indicating if
+// the provider is configured
+func (config *Config) OIDCProvider(name string) (TOIDCProvider, bool) {
+	provider, ok := config.OIDC[strings.ToLower(name)]
+	return provider, ok
+}
+
+// NewMongoClient creates a MongoDB client with the given settings, the connection is established lazily
+func NewMongoClient(settings TMongoSettings) (*mongo.Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	uri := fmt.Sprintf("mongodb://%s:%s@%s", settings.User, settings.Password, settings.Hosts)
+	return mongo.Connect(ctx, options.Client().ApplyURI(uri))
 }
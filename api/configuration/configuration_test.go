@@ -0,0 +1,153 @@
+package configuration
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// ## Helper functions
+// setRequiredEnvironment sets the required settings, the emails are written to the outbox
+func setRequiredEnvironment(t *testing.T) {
+	for name, value := range map[string]string{
+		"MONGO_USER":           "root",
+		"MONGO_PASSWORD":       "development",
+		"MONGO_HOSTS":          "localhost:27017",
+		"MONGO_DATABASE":       "loomies",
+		"ACCESS_TOKEN_SECRET":  "access",
+		"REFRESH_TOKEN_SECRET": "refresh",
+		"WS_TOKEN_SECRET":      "ws",
+		"MFA_TOKEN_SECRET":     "mfa",
+		"EMAIL_DRIVER":         "outbox",
+	} {
+		t.Setenv(name, value)
+	}
+}
+
+// writeConfigFile writes the configuration file in a temporary directory and returns its path
+func writeConfigFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+// ## Tests
+
+// TestLoadDefaults tests the optional settings use the defaults
+func TestLoadDefaults(t *testing.T) {
+	c := require.New(t)
+	setRequiredEnvironment(t)
+	t.Setenv("GAME_WILD_LOOMIES_TTL", "")
+	t.Setenv("GAME_TRAINER_MAX_LEVEL", "")
+
+	config, err := Load("")
+	c.NoError(err)
+	c.Equal("root", config.Mongo.User)
+	c.Equal(15, config.Game.WildLoomiesTTL)
+	c.Equal(40, config.Trainer.MaxLevel)
+	c.Equal("outbox", config.Email.Driver)
+	c.NotEmpty(config.Email.OutboxDir)
+}
+
+// TestLoadSourcesPrecedence tests the environment variables override the file and the file overrides the defaults
+func TestLoadSourcesPrecedence(t *testing.T) {
+	c := require.New(t)
+	setRequiredEnvironment(t)
+	t.Setenv("GAME_COMBAT_CHALLENGE_TIMEOUT", "")
+	t.Setenv("GAME_WILD_LOOMIES_TTL", "30")
+
+	yamlFile := writeConfigFile(t, "config.yaml", "game:\n  wild_loomies_ttl: 20\n  combat_challenge_timeout: 0\ngift:\n  gifts_per_day: 5\n")
+	config, err := Load(yamlFile)
+	c.NoError(err)
+	c.Equal(30, config.Game.WildLoomiesTTL)
+	c.Equal(5, config.Gift.GiftsPerDay)
+
+	// A legitimate zero is kept instead of being replaced by the default
+	c.Equal(0, config.Game.CombatChallengeTimeout)
+
+	tomlFile := writeConfigFile(t, "config.toml", "[trade]\nmax_distance = 500.0\n")
+	config, err = Load(tomlFile)
+	c.NoError(err)
+	c.Equal(500.0, config.Trade.MaxDistance)
+	c.Equal(int64(1440), config.Trade.TradeTTL)
+}
+
+// TestLoadErrors tests all the problems are reported at once
+func TestLoadErrors(t *testing.T) {
+	c := require.New(t)
+	setRequiredEnvironment(t)
+	t.Setenv("MONGO_USER", "")
+	t.Setenv("GAME_WILD_LOOMIES_TTL", "fifteen")
+	t.Setenv("GAME_MIN_LOOMIES_GENERATION_TIMEOUT", "20")
+	t.Setenv("GAME_MAX_LOOMIES_GENERATION_TIMEOUT", "10")
+
+	_, err := Load(writeConfigFile(t, "config.yml", "game:\n  unknown_setting: 1\n"))
+	c.Error(err)
+
+	var configError *ConfigError
+	c.True(errors.As(err, &configError))
+	c.Equal(4, len(configError.Problems))
+	c.Contains(err.Error(), "mongo.user (MONGO_USER) is required")
+	c.Contains(err.Error(), "game.wild_loomies_ttl (GAME_WILD_LOOMIES_TTL): \"fifteen\" is not a valid integer")
+	c.Contains(err.Error(), "game.min_loomies_generation_timeout (20) must be less than or equal to game.max_loomies_generation_timeout (10)")
+	c.Contains(err.Error(), "unknown_setting")
+
+	_, err = Load(writeConfigFile(t, "config.json", "{}"))
+	c.Error(err)
+	c.Contains(err.Error(), "must be a .yaml, .yml or .toml file")
+}
+
+// TestLoadOIDCProviders tests the providers are read from the file and the environment and validated
+func TestLoadOIDCProviders(t *testing.T) {
+	c := require.New(t)
+	setRequiredEnvironment(t)
+	t.Setenv("OIDC_GOOGLE_CLIENT_ID", "environment-client")
+	t.Setenv("OIDC_GOOGLE_JWKS_URI", "https://www.googleapis.com/oauth2/v3/certs")
+
+	file := writeConfigFile(t, "config.yaml", `oidc:
+  Google:
+    client_id: file-client
+    issuer: https://accounts.google.com
+    authorization_endpoint: https://accounts.google.com/o/oauth2/v2/auth
+    token_endpoint: https://oauth2.googleapis.com/token
+    redirect_uri: loomies://oidc/callback
+`)
+
+	// 1. The environment variables override the file, the names are case insensitive
+	config, err := Load(file)
+	c.NoError(err)
+
+	provider, ok := config.OIDCProvider("GOOGLE")
+	c.True(ok)
+	c.Equal("google", provider.Name)
+	c.Equal("environment-client", provider.ClientId)
+	c.Equal("https://accounts.google.com", provider.Issuer)
+	c.Equal("openid email profile", provider.Scopes)
+
+	_, ok = config.OIDCProvider("unknown")
+	c.False(ok)
+
+	// 2. The incomplete providers are rejected
+	t.Setenv("OIDC_OTHER_CLIENT_ID", "other-client")
+	t.Setenv("OIDC_OTHER_TOKEN_ENDPOINT", "not an url")
+
+	_, err = Load(file)
+	c.Error(err)
+	c.Contains(err.Error(), "oidc.other.issuer (OIDC_OTHER_ISSUER) must be an http(s) url")
+	c.Contains(err.Error(), "oidc.other.token_endpoint (OIDC_OTHER_TOKEN_ENDPOINT) must be an http(s) url")
+	c.Contains(err.Error(), "oidc.other.redirect_uri (OIDC_OTHER_REDIRECT_URI) must be an absolute url")
+	c.NotContains(err.Error(), "oidc.google")
+}
+
+// TestLoadExampleFile tests the example configuration file is valid
+func TestLoadExampleFile(t *testing.T) {
+	c := require.New(t)
+
+	config, err := Load(filepath.Join("..", "config.example.yaml"))
+	c.NoError(err)
+	c.Equal("loomies", config.Mongo.Database)
+	c.Equal("smtp", config.Email.Driver)
+}
@@ -1,28 +1,165 @@
 package configuration
 
-import (
-	"go.mongodb.org/mongo-driver/mongo"
-)
-
-type TGlobals struct {
-	Environment                 string
-	Loaded                      bool
-	MongoClient                 *mongo.Client
-	AccessTokenSecret           string
-	RefreshTokenSecret          string
-	WsTokenSecret               string
-	WildLoomiesTTL              int
-	MinLoomiesGenerationTimeout int
-	MaxLoomiesGenerationTimeout int
-	MinLoomiesGenerationAmount  int
-	MaxLoomiesGenerationAmount  int
-	LoomiesGenerationRadius     float64
-	MaxLoomiesPerZone           int
+// Config stores the settings of the server. The values are loaded from the defaults, the optional configuration
+// file (CONFIG_FILE) and the environment variables, in that order. The tags are:
+//   - env: Name of the environment variable
+//   - yaml / toml: Key in the configuration file
+//   - default: Value used when the setting is not given
+//   - required: The setting must be given
+type Config struct {
+	Environment string             `env:"ENVIRONMENT" yaml:"environment" toml:"environment"`
+	Server      TServerSettings    `yaml:"server" toml:"server"`
+	Mongo       TMongoSettings     `yaml:"mongo" toml:"mongo"`
+	Tokens      TTokensSettings    `yaml:"tokens" toml:"tokens"`
+	Game        TGameSettings      `yaml:"game" toml:"game"`
+	Trainer     TTrainerSettings   `yaml:"trainer" toml:"trainer"`
+	Trade       TTradeSettings     `yaml:"trade" toml:"trade"`
+	Gift        TGiftSettings      `yaml:"gift" toml:"gift"`
+	Email       TEmailSettings     `yaml:"email" toml:"email"`
+	Scheduler   TSchedulerSettings `yaml:"scheduler" toml:"scheduler"`
+	// OpenID Connect providers by name (in lower case), see TOIDCProvider
+	OIDC map[string]TOIDCProvider `yaml:"oidc" toml:"oidc"`
+}
+
+// TServerSettings stores the settings of the http server
+type TServerSettings struct {
+	Port int `env:"PORT" yaml:"port" toml:"port" default:"8080"`
+}
+
+// TMongoSettings stores the settings to connect to the database
+type TMongoSettings struct {
+	User     string `env:"MONGO_USER" yaml:"user" toml:"user" required:"true"`
+	Password string `env:"MONGO_PASSWORD" yaml:"password" toml:"password" required:"true"`
+	Hosts    string `env:"MONGO_HOSTS" yaml:"hosts" toml:"hosts" required:"true"`
+	Database string `env:"MONGO_DATABASE" yaml:"database" toml:"database" required:"true"`
+	// Apply the pending migrations when the server starts
+	MigrateOnStartup bool `env:"MONGO_MIGRATE_ON_STARTUP" yaml:"migrate_on_startup" toml:"migrate_on_startup" default:"true"`
+}
+
+// TTokensSettings stores the secrets to sign the tokens
+type TTokensSettings struct {
+	AccessTokenSecret  string `env:"ACCESS_TOKEN_SECRET" yaml:"access_token_secret" toml:"access_token_secret" required:"true"`
+	RefreshTokenSecret string `env:"REFRESH_TOKEN_SECRET" yaml:"refresh_token_secret" toml:"refresh_token_secret" required:"true"`
+	WsTokenSecret      string `env:"WS_TOKEN_SECRET" yaml:"ws_token_secret" toml:"ws_token_secret" required:"true"`
+	MfaTokenSecret     string `env:"MFA_TOKEN_SECRET" yaml:"mfa_token_secret" toml:"mfa_token_secret" required:"true"`
+}
+
+// TGameSettings stores the settings of the map, the loomies generation and the combats
+type TGameSettings struct {
+	// Max distance (in meters) between the players and the gyms or wild loomies to interact with them
+	InteractionRadius float64 `env:"GAME_INTERACTION_RADIUS" yaml:"interaction_radius" toml:"interaction_radius" default:"390"`
+	// Max distance (in meters) of the gyms and wild loomies shown to the players
+	VisibilityRadius float64 `env:"GAME_VISIBILITY_RADIUS" yaml:"visibility_radius" toml:"visibility_radius" default:"600"`
+	// The time to live of a loomie (in minutes)
+	WildLoomiesTTL int `env:"GAME_WILD_LOOMIES_TTL" yaml:"wild_loomies_ttl" toml:"wild_loomies_ttl" default:"15"`
+	// Minutes to wait before generating new loomies
+	MinLoomiesGenerationTimeout int `env:"GAME_MIN_LOOMIES_GENERATION_TIMEOUT" yaml:"min_loomies_generation_timeout" toml:"min_loomies_generation_timeout" default:"5"`
+	MaxLoomiesGenerationTimeout int `env:"GAME_MAX_LOOMIES_GENERATION_TIMEOUT" yaml:"max_loomies_generation_timeout" toml:"max_loomies_generation_timeout" default:"12"`
+	// Amount of loomies to generate
+	MinLoomiesGenerationAmount int `env:"GAME_MIN_LOOMIES_GENERATION_AMOUNT" yaml:"min_loomies_generation_amount" toml:"min_loomies_generation_amount" default:"2"`
+	MaxLoomiesGenerationAmount int `env:"GAME_MAX_LOOMIES_GENERATION_AMOUNT" yaml:"max_loomies_generation_amount" toml:"max_loomies_generation_amount" default:"12"`
+	// Radius (in meters) of the circle around the player in which loomies will be generated
+	LoomiesGenerationRadius float64 `env:"GAME_LOOMIES_GENERATION_RADIUS" yaml:"loomies_generation_radius" toml:"loomies_generation_radius" default:"195"`
+	MaxLoomiesPerZone       int     `env:"GAME_MAX_LOOMIES_PER_ZONE" yaml:"max_loomies_per_zone" toml:"max_loomies_per_zone" default:"12"`
 	// Global settings to calculate the experience required to level up
-	MinLoomieRequiredExperience float64
-	LoomieExperienceFactor      float64
-	// Global settings to be used on combats
-	MinCombatAttackTimeout int
-	MaxCombatAttackTimeout int
-	CombatChallengeTimeout int
+	MinLoomieRequiredExperience float64 `env:"GAME_LOOMIE_MIN_REQUIRED_EXPERIENCE" yaml:"loomie_min_required_experience" toml:"loomie_min_required_experience" default:"100"`
+	LoomieExperienceFactor      float64 `env:"GAME_LOOMIE_EXPERIENCE_FACTOR" yaml:"loomie_experience_factor" toml:"loomie_experience_factor" default:"1000"`
+	// Seconds between the attacks of the gym protectors
+	MinCombatAttackTimeout int `env:"GAME_COMBAT_MINIMUM_ATTACK_TIMEOUT" yaml:"combat_minimum_attack_timeout" toml:"combat_minimum_attack_timeout" default:"2"`
+	MaxCombatAttackTimeout int `env:"GAME_COMBAT_MAXIMUM_ATTACK_TIMEOUT" yaml:"combat_maximum_attack_timeout" toml:"combat_maximum_attack_timeout" default:"3"`
+	// Minutes to wait before challenging the same gym again
+	CombatChallengeTimeout int `env:"GAME_COMBAT_CHALLENGE_TIMEOUT" yaml:"combat_challenge_timeout" toml:"combat_challenge_timeout" default:"180"`
+	// Percentage (0 - 100) of the attacks dodged by the gym protectors
+	GymDodgeProbability int `env:"GAME_COMBAT_GYM_DODGE_PROBABILITY" yaml:"combat_gym_dodge_probability" toml:"combat_gym_dodge_probability" default:"10"`
+	// Hp restored by the healing items
+	PainKillersHeal int `env:"GAME_ITEMS_PAINKILLERS_HEAL" yaml:"items_painkillers_heal" toml:"items_painkillers_heal" default:"50"`
+	SmallAidKitHeal int `env:"GAME_ITEMS_SMALL_AID_KIT_HEAL" yaml:"items_small_aid_kit_heal" toml:"items_small_aid_kit_heal" default:"100"`
+}
+
+// TGameBalance stores the tuning values of the game that can be changed while the server is running. The
+// defaults are taken from the game settings and the live values from the game_settings collection
+type TGameBalance struct {
+	WildLoomiesTTL              int     `json:"wild_loomies_ttl" bson:"wild_loomies_ttl"`
+	MinLoomiesGenerationTimeout int     `json:"min_loomies_generation_timeout" bson:"min_loomies_generation_timeout"`
+	MaxLoomiesGenerationTimeout int     `json:"max_loomies_generation_timeout" bson:"max_loomies_generation_timeout"`
+	MinLoomiesGenerationAmount  int     `json:"min_loomies_generation_amount" bson:"min_loomies_generation_amount"`
+	MaxLoomiesGenerationAmount  int     `json:"max_loomies_generation_amount" bson:"max_loomies_generation_amount"`
+	MinLoomieRequiredExperience float64 `json:"loomie_min_required_experience" bson:"loomie_min_required_experience"`
+	LoomieExperienceFactor      float64 `json:"loomie_experience_factor" bson:"loomie_experience_factor"`
+	MinCombatAttackTimeout      int     `json:"combat_minimum_attack_timeout" bson:"combat_minimum_attack_timeout"`
+	MaxCombatAttackTimeout      int     `json:"combat_maximum_attack_timeout" bson:"combat_maximum_attack_timeout"`
+	CombatChallengeTimeout      int     `json:"combat_challenge_timeout" bson:"combat_challenge_timeout"`
+	GymDodgeProbability         int     `json:"combat_gym_dodge_probability" bson:"combat_gym_dodge_probability"`
+	PainKillersHeal             int     `json:"items_painkillers_heal" bson:"items_painkillers_heal"`
+	SmallAidKitHeal             int     `json:"items_small_aid_kit_heal" bson:"items_small_aid_kit_heal"`
+}
+
+// TOIDCProvider stores the settings of an OpenID Connect provider. The providers are given in the oidc section of
+// the configuration file or with the OIDC_<NAME>_<env> environment variables (Eg. OIDC_GOOGLE_CLIENT_ID)
+type TOIDCProvider struct {
+	// Name used in the /session/oidc/<name> urls, taken from the key of the provider
+	Name                  string `yaml:"-" toml:"-"`
+	ClientId              string `env:"CLIENT_ID" yaml:"client_id" toml:"client_id"`
+	ClientSecret          string `env:"CLIENT_SECRET" yaml:"client_secret" toml:"client_secret"`
+	Issuer                string `env:"ISSUER" yaml:"issuer" toml:"issuer"`
+	AuthorizationEndpoint string `env:"AUTHORIZATION_ENDPOINT" yaml:"authorization_endpoint" toml:"authorization_endpoint"`
+	TokenEndpoint         string `env:"TOKEN_ENDPOINT" yaml:"token_endpoint" toml:"token_endpoint"`
+	JwksUri               string `env:"JWKS_URI" yaml:"jwks_uri" toml:"jwks_uri"`
+	RedirectUri           string `env:"REDIRECT_URI" yaml:"redirect_uri" toml:"redirect_uri"`
+	// Space separated scopes, the default is "openid email profile"
+	Scopes string `env:"SCOPES" yaml:"scopes" toml:"scopes"`
+}
+
+// TEmailSettings stores the settings to deliver the emails
+type TEmailSettings struct {
+	// "smtp" or "outbox" (writes the emails to files, used in development and tests). The default is "outbox"
+	// on the TESTING environment and "smtp" otherwise
+	Driver    string `env:"EMAIL_DRIVER" yaml:"driver" toml:"driver"`
+	Sender    string `env:"EMAIL_MAIL" yaml:"sender" toml:"sender"`
+	Password  string `env:"EMAIL_PASSWORD" yaml:"password" toml:"password"`
+	SmtpHost  string `env:"EMAIL_SMTP_HOST" yaml:"smtp_host" toml:"smtp_host" default:"smtp.gmail.com"`
+	SmtpPort  int    `env:"EMAIL_SMTP_PORT" yaml:"smtp_port" toml:"smtp_port" default:"587"`
+	OutboxDir string `env:"EMAIL_OUTBOX_DIR" yaml:"outbox_dir" toml:"outbox_dir"`
+}
+
+// TSchedulerSettings stores the cron expressions of the periodic jobs, see the scheduler package
+type TSchedulerSettings struct {
+	Enabled bool `env:"SCHEDULER_ENABLED" yaml:"enabled" toml:"enabled" default:"true"`
+	// Time zone of the cron expressions (Eg. America/Bogota)
+	Timezone string `env:"SCHEDULER_TIMEZONE" yaml:"timezone" toml:"timezone" default:"UTC"`
+	// Replace the rewards of the gyms and clear the users who claimed them
+	GymsRewardsSchedule string `env:"SCHEDULER_GYMS_REWARDS_SCHEDULE" yaml:"gyms_rewards_schedule" toml:"gyms_rewards_schedule" default:"0 0 * * *"`
+	// Remove the wild loomies older than the game wild_loomies_ttl
+	OutdatedLoomiesSchedule string `env:"SCHEDULER_OUTDATED_LOOMIES_SCHEDULE" yaml:"outdated_loomies_schedule" toml:"outdated_loomies_schedule" default:"30 0 * * *"`
+	// Email the summary of the last week activity to the verified users
+	WeeklySummarySchedule string `env:"SCHEDULER_WEEKLY_SUMMARY_SCHEDULE" yaml:"weekly_summary_schedule" toml:"weekly_summary_schedule" default:"0 9 * * mon"`
+}
+
+// TTrainerSettings stores the trainer levels curve and the experience given by each action
+type TTrainerSettings struct {
+	// Experience required to reach the level L is BaseExperience * (L - 1) ^ ExperienceExponent
+	BaseExperience        float64 `env:"GAME_TRAINER_BASE_EXPERIENCE" yaml:"base_experience" toml:"base_experience" default:"500"`
+	ExperienceExponent    float64 `env:"GAME_TRAINER_EXPERIENCE_EXPONENT" yaml:"experience_exponent" toml:"experience_exponent" default:"1.5"`
+	MaxLevel              int     `env:"GAME_TRAINER_MAX_LEVEL" yaml:"max_level" toml:"max_level" default:"40"`
+	CaptureExperience     float64 `env:"GAME_TRAINER_CAPTURE_EXPERIENCE" yaml:"capture_experience" toml:"capture_experience" default:"100"`
+	FuseExperience        float64 `env:"GAME_TRAINER_FUSE_EXPERIENCE" yaml:"fuse_experience" toml:"fuse_experience" default:"150"`
+	GymVictoryExperience  float64 `env:"GAME_TRAINER_GYM_VICTORY_EXPERIENCE" yaml:"gym_victory_experience" toml:"gym_victory_experience" default:"500"`
+	ClaimRewardExperience float64 `env:"GAME_TRAINER_CLAIM_REWARD_EXPERIENCE" yaml:"claim_reward_experience" toml:"claim_reward_experience" default:"50"`
+}
+
+type TTradeSettings struct {
+	// Max distance (in meters) between the players when they confirm a trade
+	MaxDistance float64 `env:"GAME_TRADE_MAX_DISTANCE" yaml:"max_distance" toml:"max_distance" default:"390"`
+	// Minutes to wait for the confirmation of the other player
+	ConfirmationTimeout int64 `env:"GAME_TRADE_CONFIRMATION_TIMEOUT" yaml:"confirmation_timeout" toml:"confirmation_timeout" default:"5"`
+	// Minutes before the pending trades expire
+	TradeTTL int64 `env:"GAME_TRADE_TTL" yaml:"ttl" toml:"ttl" default:"1440"`
+}
+
+type TGiftSettings struct {
+	// Gifts each player can send per day (UTC)
+	GiftsPerDay int `env:"GAME_GIFTS_PER_DAY" yaml:"gifts_per_day" toml:"gifts_per_day" default:"3"`
+	// Number of different rewards drawn from the gift table for each gift
+	MinRewards int `env:"GAME_GIFT_MIN_REWARDS" yaml:"min_rewards" toml:"min_rewards" default:"1"`
+	MaxRewards int `env:"GAME_GIFT_MAX_REWARDS" yaml:"max_rewards" toml:"max_rewards" default:"3"`
 }
@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// HandleExportUser Handle the request to download all the personal data of the user as a JSON archive
+func HandleExportUser(c *gin.Context) {
+	user, ok := getSessionUser(c)
+	if !ok {
+		return
+	}
+
+	items, loomballs, err := repos.Items.GetInventory(user.Items)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	loomies, err := repos.Loomies.GetLoomiesByIds(user.Loomies, user.Id)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	combats, err := repos.Challenges.GetUserCombats(user.Id)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	gyms, err := repos.Gyms.GetGymsByOwner(user.Id)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	friends, err := repos.Friends.GetUserFriendships(user.Id, repositories.FriendshipAccepted)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	friendRequests, err := repos.Friends.GetUserFriendships(user.Id, repositories.FriendshipPending)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	activeTrades, err := repos.Trades.GetActiveTrades(user.Id)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	tradesHistory, err := repos.Trades.GetTradesHistory(user.Id)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	gifts, err := repos.Gifts.GetUserGifts(user.Id)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	for index := range gifts {
+		gifts[index] = sealGift(gifts[index])
+	}
+
+	// Conquered, lost and claimed gyms
+	gymsHistory, err := repos.Audit.FindEvents(interfaces.AuditEventsFilter{UserId: user.Id, Entity: "gyms"})
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	// The password and the mfa secrets are never exported
+	profile := gin.H{
+		"_id":            user.Id,
+		"username":       user.Username,
+		"email":          user.Email,
+		"language":       user.Language,
+		"level":          user.Level,
+		"trainer_card":   user.Profile,
+		"experience":     user.Experience,
+		"achievements":   user.Achievements,
+		"counters":       user.AchievementCounters,
+		"isVerified":     user.IsVerified,
+		"identities":     user.Identities,
+		"mfa_enabled":    user.Mfa.Enabled,
+		"roles":          user.Roles,
+		"ban":            user.Ban,
+		"mute":           user.Mute,
+		"blocked_users":  user.BlockedUsers,
+		"last_seen_at":   user.LastSeenAt,
+		"last_seen_zone": user.LastSeenZone,
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"loomies-%s.json\"", user.Username))
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":           false,
+		"message":         "User data was exported successfully",
+		"profile":         profile,
+		"items":           items,
+		"loomballs":       loomballs,
+		"loomies":         loomies,
+		"loomie_team":     user.LoomieTeam,
+		"combats":         combats,
+		"gyms":            gyms,
+		"gyms_history":    gymsHistory,
+		"friends":         friends,
+		"friend_requests": friendRequests,
+		"trades":          activeTrades,
+		"trades_history":  tradesHistory,
+		"gifts":           gifts,
+	})
+}
+
+// HandleDeleteUser Handle the request to delete the account of the user and all the linked data
+func HandleDeleteUser(c *gin.Context) {
+	var form interfaces.DeleteAccountReq
+
+	if err := c.BindJSON(&form); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Bad request"})
+		return
+	}
+
+	if form.Password == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Password cannot be empty"})
+		return
+	}
+
+	user, ok := getSessionUser(c)
+	if !ok {
+		return
+	}
+
+	// Accounts created with an external provider have to set a password with the reset flow first
+	if user.Password == "" {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "The account doesn't have a password, reset it to confirm the deletion"})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(form.Password)); err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": true, "message": "Invalid password"})
+		return
+	}
+
+	if user.Mfa.Enabled {
+		if form.Code == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": true, "message": "Two-factor authentication code is required"})
+			return
+		}
+
+		if !verifyMfaActionCode(c, user, form.Code) {
+			return
+		}
+	}
+
+	// The combat would try to update the deleted loomies
+	_, err := repos.Challenges.GetActiveCombatByUserId(user.Id)
+
+	if err == nil {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "The account can't be deleted during a combat"})
+		return
+	}
+
+	if err != mongo.ErrNoDocuments {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	if err := repos.Users.DeleteUserAccount(c, user); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "User was deleted successfully",
+	})
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/middlewares"
+	"github.com/PedroChaparro/loomies-backend/tests"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ## Helper functions
+// setupAccountRouter creates a router with the account endpoints
+func setupAccountRouter() *gin.Engine {
+	router := tests.SetupGinRouter()
+	router.POST("/session/login", HandleLogIn)
+	router.GET("/user/export", middlewares.MustProvideAccessToken(), HandleExportUser)
+	router.DELETE("/user", middlewares.MustProvideAccessToken(), HandleDeleteUser)
+	return router
+}
+
+// ## Tests
+
+// TestExportUser tests the personal data is exported without the secrets
+func TestExportUser(t *testing.T) {
+	c := require.New(t)
+	router := setupAccountRouter()
+	user, accessToken := loginWithRoles(router)
+
+	w, req := tests.SetupGetRequest("/user/export", tests.CustomHeader{Name: "Access-Token", Value: accessToken})
+	router.ServeHTTP(w, req)
+	c.Equal(http.StatusOK, w.Code)
+	c.Contains(w.Header().Get("Content-Disposition"), "attachment")
+	c.Contains(w.Body.String(), user.Email)
+	c.NotContains(w.Body.String(), "password")
+	c.NotContains(w.Body.String(), user.Password)
+
+	for _, key := range []string{"\"profile\"", "\"items\"", "\"loomies\"", "\"combats\"", "\"gyms_history\""} {
+		c.Contains(w.Body.String(), key)
+	}
+
+	tests.DeleteUser(repos, user.Email)
+}
+
+// TestDeleteUser tests the account and the linked data are removed after confirming the password
+func TestDeleteUser(t *testing.T) {
+	ctx := context.Background()
+	c := require.New(t)
+	router := setupAccountRouter()
+	user, password := createVerifiedUser(router)
+
+	code, response := postMfaRequest(router, "/session/login", map[string]string{"email": user.Email, "password": password})
+	c.Equal(http.StatusOK, code)
+	accessToken := response["accessToken"].(string)
+
+	// Give the user a loomie and a gym protected by another loomie
+	loomieId, err := repos.Loomies.InsertInCaughtLoomies(ctx, interfaces.CaughtLoomie{Owner: user.Id, Name: "Deleted", Level: 1})
+	c.NoError(err)
+	protectorId, err := repos.Loomies.InsertInCaughtLoomies(ctx, interfaces.CaughtLoomie{Owner: user.Id, Name: "Protector", Level: 1})
+	c.NoError(err)
+	gymId := primitive.NewObjectID()
+	testStore.Gyms[gymId] = interfaces.Gym{
+		Id:               gymId,
+		Name:             "Account deletion gym",
+		Owner:            user.Id,
+		Protectors:       []primitive.ObjectID{protectorId},
+		RewardsClaimedBy: []primitive.ObjectID{user.Id},
+	}
+
+	// 1. The password is required
+	code, response = sendContentRequest(router, "DELETE", "/user", map[string]string{"password": "wrong-password"}, accessToken)
+	c.Equal(http.StatusUnauthorized, code)
+	c.Equal("Invalid password", response["message"])
+
+	// 2. Delete the account
+	code, response = sendContentRequest(router, "DELETE", "/user", map[string]string{"password": password}, accessToken)
+	c.Equal(http.StatusOK, code)
+	c.Equal("User was deleted successfully", response["message"])
+
+	// 3. Check the linked data was cleaned up
+	_, err = repos.Users.GetUserById(user.Id.Hex())
+	c.Equal(mongo.ErrNoDocuments, err)
+
+	_, ok := testStore.CaughtLoomies[loomieId]
+	c.False(ok)
+
+	protector, ok := testStore.CaughtLoomies[protectorId]
+	c.True(ok)
+	c.True(protector.Owner.IsZero())
+
+	releasedGym, ok := testStore.Gyms[gymId]
+	c.True(ok)
+	c.True(releasedGym.Owner.IsZero())
+	c.Empty(releasedGym.RewardsClaimedBy)
+
+	_, err = getTestAuthenticationCode(user.Email, "ACCOUNT_VERIFICATION")
+	c.Equal(mongo.ErrNoDocuments, err)
+
+	// 4. The access token of the deleted user is no longer useful
+	code, _ = sendContentRequest(router, "DELETE", "/user", map[string]string{"password": password}, accessToken)
+	c.Equal(http.StatusNotFound, code)
+
+	delete(testStore.Gyms, gymId)
+	delete(testStore.CaughtLoomies, protectorId)
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// trackAchievement "private" function to increment an achievement counter of the user and return the unlocked
+// achievements. The errors are only logged to don't fail the action that incremented the counter
+func trackAchievement(c *gin.Context, userId primitive.ObjectID, counter string) []interfaces.Achievement {
+	unlocked, err := repos.Achievements.IncrementAchievementCounter(c, userId, counter, 1)
+
+	if err != nil {
+		fmt.Println("Unable to update the achievement counter:", err)
+	}
+
+	return unlocked
+}
+
+// HandleGetAchievements Handle the request to get all the achievements with the progress of the user
+func HandleGetAchievements(c *gin.Context) {
+	user, ok := getSessionUser(c)
+	if !ok {
+		return
+	}
+
+	achievements, err := repos.Achievements.GetAchievements()
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	unlockedAt := make(map[primitive.ObjectID]int64)
+	for _, unlocked := range user.Achievements {
+		unlockedAt[unlocked.AchievementId] = unlocked.UnlockedAt
+	}
+
+	response := []gin.H{}
+	for _, achievement := range achievements {
+		progress := user.AchievementCounters[achievement.Counter]
+		if progress > achievement.Goal {
+			progress = achievement.Goal
+		}
+
+		timestamp, unlocked := unlockedAt[achievement.Id]
+
+		response = append(response, gin.H{
+			"_id":         achievement.Id,
+			"serial":      achievement.Serial,
+			"name":        achievement.Name,
+			"description": achievement.Description,
+			"badge":       achievement.Badge,
+			"goal":        achievement.Goal,
+			"progress":    progress,
+			"unlocked":    unlocked,
+			"unlocked_at": timestamp,
+		})
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":        false,
+		"message":      "Achievements were retrieved successfully",
+		"achievements": response,
+	})
+}
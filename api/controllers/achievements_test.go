@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/PedroChaparro/loomies-backend/middlewares"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"github.com/PedroChaparro/loomies-backend/tests"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAchievements tests the achievements are unlocked once when the counters reach the goals
+func TestAchievements(t *testing.T) {
+	var response map[string]interface{}
+	ctx := context.Background()
+	c := require.New(t)
+	router := tests.SetupGinRouter()
+	router.POST("/session/login", HandleLogIn)
+	router.GET("/user/achievements", middlewares.MustProvideAccessToken(), HandleGetAchievements)
+	user, accessToken := loginWithRoles(router)
+
+	// 1. The first capture unlocks the "First Steps" achievement (serial 1)
+	unlocked, err := repos.Achievements.IncrementAchievementCounter(ctx, user.Id, repositories.AchievementCapturesCounter, 1)
+	c.NoError(err)
+	c.Equal(1, len(unlocked))
+	c.Equal(1, unlocked[0].Serial)
+
+	// 2. The achievements are unlocked only once
+	unlocked, err = repos.Achievements.IncrementAchievementCounter(ctx, user.Id, repositories.AchievementCapturesCounter, 1)
+	c.NoError(err)
+	c.Empty(unlocked)
+
+	// 3. The record counters never decrease
+	_, err = repos.Achievements.SetAchievementCounterMax(ctx, user.Id, repositories.AchievementOwnedGymsCounter, 3)
+	c.NoError(err)
+	_, err = repos.Achievements.SetAchievementCounterMax(ctx, user.Id, repositories.AchievementOwnedGymsCounter, 1)
+	c.NoError(err)
+
+	// 4. Get the achievements with the progress
+	w, req := tests.SetupGetRequest("/user/achievements", tests.CustomHeader{Name: "Access-Token", Value: accessToken})
+	router.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	c.Equal(http.StatusOK, w.Code)
+
+	achievements := response["achievements"].([]interface{})
+	c.NotEmpty(achievements)
+
+	for _, item := range achievements {
+		achievement := item.(map[string]interface{})
+
+		switch achievement["name"] {
+		case "First Steps":
+			c.Equal(true, achievement["unlocked"])
+			c.Equal(float64(1), achievement["progress"])
+		case "Loomie Collector":
+			c.Equal(false, achievement["unlocked"])
+			c.Equal(float64(2), achievement["progress"])
+		case "Gym Leader":
+			c.Equal(false, achievement["unlocked"])
+			c.Equal(float64(3), achievement["progress"])
+		}
+	}
+
+	tests.DeleteUser(repos, user.Email)
+}
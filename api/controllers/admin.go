@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// HandleUpdateUserRoles Handle the request to replace the roles of an user
+func HandleUpdateUserRoles(c *gin.Context) {
+	var form interfaces.UpdateUserRolesReq
+
+	if err := c.BindJSON(&form); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Bad request"})
+		return
+	}
+
+	userId, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Invalid user id"})
+		return
+	}
+
+	// Validate the roles and remove the duplicated ones
+	roles := []string{}
+	seen := make(map[string]bool)
+
+	for _, role := range form.Roles {
+		if !utils.IsValidRole(role) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Role " + role + " doesn't exist"})
+			return
+		}
+
+		if !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+
+	// Avoid the admins to lock themselves out of the management endpoints
+	actorId, _ := c.Get("userid")
+
+	if actorId.(string) == userId.Hex() && !seen[utils.RoleAdmin] {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "You can't remove your own admin role"})
+		return
+	}
+
+	err = repos.Users.UpdateUserRoles(c, userId, roles)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "User was not found"})
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "User roles were updated successfully. They will be applied when the user refreshes their access token",
+		"roles":   roles,
+	})
+}
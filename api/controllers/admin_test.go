@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/middlewares"
+	"github.com/PedroChaparro/loomies-backend/tests"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+// ## Helper functions
+// setupAdminRouter creates a router with the management endpoints
+func setupAdminRouter() *gin.Engine {
+	router := tests.SetupGinRouter()
+	router.POST("/session/login", HandleLogIn)
+	admin := router.Group("/admin", middlewares.MustProvideAccessToken())
+	admin.PUT("/users/:id/roles", middlewares.RequirePermission(utils.PermissionManageRoles), HandleUpdateUserRoles)
+	return router
+}
+
+// loginWithRoles creates a verified user with the given roles and returns it with its access token
+func loginWithRoles(router *gin.Engine, roles ...string) (interfaces.User, string) {
+	var response map[string]interface{}
+	user, password := createVerifiedUser(router)
+	repos.Users.UpdateUserRoles(context.Background(), user.Id, roles)
+
+	w, req := tests.SetupPayloadedRequest("/session/login", "POST", map[string]string{"email": user.Email, "password": password})
+	router.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &response)
+
+	return user, response["accessToken"].(string)
+}
+
+// ## Tests
+
+// TestAccessTokenPermissions tests the roles and permissions are embedded in the access token
+func TestAccessTokenPermissions(t *testing.T) {
+	c := require.New(t)
+	router := setupAdminRouter()
+	user, accessToken := loginWithRoles(router, utils.RoleModerator)
+
+	claims, err := utils.ValidateAccessToken(config.Tokens, accessToken)
+	c.NoError(err)
+	c.Equal(user.Id.Hex(), claims.UserID)
+	c.Equal([]string{utils.RoleModerator}, claims.Roles)
+	c.Equal([]string{utils.PermissionModerateUsers}, claims.Permissions)
+
+	err = tests.DeleteUser(repos, user.Email)
+	c.NoError(err)
+}
+
+// TestUpdateUserRoles tests only the users with the required permission can update the roles
+func TestUpdateUserRoles(t *testing.T) {
+	var response map[string]interface{}
+	c := require.New(t)
+	router := setupAdminRouter()
+
+	admin, adminToken := loginWithRoles(router, utils.RoleAdmin)
+	player, playerToken := loginWithRoles(router)
+	endpoint := "/admin/users/" + player.Id.Hex() + "/roles"
+
+	// 1. Players can't update the roles
+	w, req := tests.SetupPayloadedRequest(endpoint, "PUT", map[string][]string{"roles": {utils.RoleAdmin}}, tests.CustomHeader{Name: "Access-Token", Value: playerToken})
+	router.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	c.Equal(http.StatusForbidden, w.Code)
+	c.Equal("You don't have permission to perform this action", response["message"])
+
+	// 2. Unknown roles are rejected
+	w, req = tests.SetupPayloadedRequest(endpoint, "PUT", map[string][]string{"roles": {"superuser"}}, tests.CustomHeader{Name: "Access-Token", Value: adminToken})
+	router.ServeHTTP(w, req)
+	c.Equal(http.StatusBadRequest, w.Code)
+
+	// 3. Admins can't remove their own admin role
+	w, req = tests.SetupPayloadedRequest("/admin/users/"+admin.Id.Hex()+"/roles", "PUT", map[string][]string{"roles": {}}, tests.CustomHeader{Name: "Access-Token", Value: adminToken})
+	router.ServeHTTP(w, req)
+	c.Equal(http.StatusConflict, w.Code)
+
+	// 4. Admins can update the roles
+	w, req = tests.SetupPayloadedRequest(endpoint, "PUT", map[string][]string{"roles": {utils.RoleModerator, utils.RoleModerator}}, tests.CustomHeader{Name: "Access-Token", Value: adminToken})
+	router.ServeHTTP(w, req)
+	c.Equal(http.StatusOK, w.Code)
+
+	updatedPlayer, err := repos.Users.GetUserById(player.Id.Hex())
+	c.NoError(err)
+	c.Equal([]string{utils.RoleModerator}, updatedPlayer.Roles)
+
+	err = tests.DeleteUser(repos, admin.Email)
+	c.NoError(err)
+	err = tests.DeleteUser(repos, player.Email)
+	c.NoError(err)
+}
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Maximum amount of events returned by the audit endpoint
+const maxAuditEventsLimit = 200
+
+// parseAuditId "private" function to parse an optional id from the query
+func parseAuditId(c *gin.Context, name string) (primitive.ObjectID, bool) {
+	value := c.Query(name)
+
+	if value == "" {
+		return primitive.NilObjectID, true
+	}
+
+	id, err := primitive.ObjectIDFromHex(value)
+	return id, err == nil
+}
+
+// parseAuditTimestamp "private" function to parse an optional unix timestamp from the query
+func parseAuditTimestamp(c *gin.Context, name string) (int64, bool) {
+	value := c.Query(name)
+
+	if value == "" {
+		return 0, true
+	}
+
+	timestamp, err := strconv.ParseInt(value, 10, 64)
+	return timestamp, err == nil && timestamp > 0
+}
+
+// HandleAdminGetAuditEvents Handle the request to query the audit log by user, entity and time range
+func HandleAdminGetAuditEvents(c *gin.Context) {
+	userId, validUser := parseAuditId(c, "user_id")
+	entityId, validEntity := parseAuditId(c, "entity_id")
+
+	if !validUser || !validEntity {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Invalid user or entity id"})
+		return
+	}
+
+	from, validFrom := parseAuditTimestamp(c, "from")
+	to, validTo := parseAuditTimestamp(c, "to")
+
+	if !validFrom || !validTo || (to > 0 && from > to) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "From and to must be valid unix timestamps and from must be lower or equal than to"})
+		return
+	}
+
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "50"), 10, 64)
+
+	if err != nil || limit <= 0 || limit > maxAuditEventsLimit {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Limit must be between 1 and 200"})
+		return
+	}
+
+	events, err := repos.Audit.FindEvents(interfaces.AuditEventsFilter{
+		UserId:   userId,
+		Entity:   c.Query("entity"),
+		EntityId: entityId,
+		From:     from,
+		To:       to,
+		Limit:    limit,
+	})
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Audit events were retrieved successfully",
+		"events":  events,
+	})
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/middlewares"
+	"github.com/PedroChaparro/loomies-backend/tests"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+// ## Helper functions
+// setupAuditRouter creates a router with the audit endpoint and an endpoint that changes the inventories
+func setupAuditRouter() *gin.Engine {
+	router := tests.SetupGinRouter()
+	router.Use(middlewares.RequestId())
+	router.POST("/session/login", HandleLogIn)
+	admin := router.Group("/admin", middlewares.MustProvideAccessToken())
+	admin.GET("/audit-events", middlewares.RequirePermission(utils.PermissionReadAudit), HandleAdminGetAuditEvents)
+	admin.POST("/users/:id/items/grant", middlewares.RequirePermission(utils.PermissionModerateUsers), HandleAdminGrantItem)
+	return router
+}
+
+// ## Tests
+
+// TestAuditEvents tests the inventory changes are audited with the actor and request id and can be queried
+func TestAuditEvents(t *testing.T) {
+	c := require.New(t)
+	router := setupAuditRouter()
+	admin, adminToken := loginWithRoles(router, utils.RoleAdmin)
+	player, playerToken := loginWithRoles(router)
+	from := time.Now().Unix()
+
+	item := getTestItemBySerial(1)
+
+	// 1. Grant an item to the player
+	code, _ := sendContentRequest(router, "POST", "/admin/users/"+player.Id.Hex()+"/items/grant", map[string]interface{}{"item_id": item.Id.Hex(), "quantity": 2}, adminToken)
+	c.Equal(http.StatusOK, code)
+
+	// 2. Players can't read the audit log
+	code, _ = sendContentRequest(router, "GET", "/admin/audit-events", nil, playerToken)
+	c.Equal(http.StatusForbidden, code)
+
+	// 3. The time range is validated
+	code, _ = sendContentRequest(router, "GET", "/admin/audit-events?from=20&to=10", nil, adminToken)
+	c.Equal(http.StatusBadRequest, code)
+
+	// 4. Query the events of the player
+	code, response := sendContentRequest(router, "GET", fmt.Sprintf("/admin/audit-events?user_id=%s&entity=items&from=%d", player.Id.Hex(), from), nil, adminToken)
+	c.Equal(http.StatusOK, code)
+
+	events := response["events"].([]interface{})
+	c.Equal(1, len(events))
+
+	event := events[0].(map[string]interface{})
+	c.Equal("inventory.add", event["action"])
+	c.Equal(admin.Id.Hex(), event["actor_id"])
+	c.Equal(item.Id.Hex(), event["entity_id"])
+	c.NotEmpty(event["request_id"])
+	c.Equal(float64(0), event["before"].(map[string]interface{})["quantity"])
+	c.Equal(float64(2), event["after"].(map[string]interface{})["quantity"])
+
+	err := tests.DeleteUser(repos, admin.Email)
+	c.NoError(err)
+	err = tests.DeleteUser(repos, player.Email)
+	c.NoError(err)
+}
@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"github.com/PedroChaparro/loomies-backend/configuration"
+	"github.com/PedroChaparro/loomies-backend/email"
+)
+
+// config and gameBalance are the settings used by the handlers, injected by the app (See SetConfiguration)
+var config *configuration.Config
+var gameBalance *configuration.LiveBalance
+
+// mailer delivers the emails sent by the handlers, injected by the app (See SetMailer)
+var mailer *email.Mailer
+
+// SetConfiguration Replaces the settings and the live game balance used by the handlers (and the combats started
+// by them)
+func SetConfiguration(settings *configuration.Config, balance *configuration.LiveBalance) {
+	config = settings
+	gameBalance = balance
+}
+
+// SetMailer Replaces the mailer used by the handlers (and the combats started by them)
+func SetMailer(emailsMailer *email.Mailer) {
+	mailer = emailsMailer
+}
@@ -0,0 +1,590 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/PedroChaparro/loomies-backend/combat"
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ## Helper functions
+
+// getContentId "private" function to get the document id from the url and abort the request if it's not valid
+func getContentId(c *gin.Context) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Invalid id"})
+		return id, false
+	}
+
+	return id, true
+}
+
+// getActorId "private" function to get the id of the user performing the request
+func getActorId(c *gin.Context) primitive.ObjectID {
+	userid, _ := c.Get("userid")
+	actorId, _ := primitive.ObjectIDFromHex(fmt.Sprint(userid))
+	return actorId
+}
+
+// invalidateContentCaches "private" function to remove the types cached by the combats after they change (The
+// repositories invalidate their own caches)
+func invalidateContentCaches() {
+	if combat.GlobalWsHub != nil {
+		combat.GlobalWsHub.InvalidateTypesCache()
+	}
+}
+
+// respondWithContent "private" function to respond with all the documents of a content collection
+func respondWithContent(c *gin.Context, collection string, sortBy string, results interface{}) {
+	err := repos.Content.GetContentDocuments(collection, sortBy, results)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Documents were retrieved successfully",
+		"data":    results,
+	})
+}
+
+// saveContent "private" function to insert (if id is nil) or replace a content document
+func saveContent(c *gin.Context, collection string, id primitive.ObjectID, document interface{}) {
+	var err error
+
+	if id.IsZero() {
+		id, err = repos.Content.InsertContentDocument(c, collection, document)
+	} else {
+		err = repos.Content.ReplaceContentDocument(c, collection, id, document)
+	}
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "Document was not found"})
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Document was saved successfully",
+		"_id":     id,
+	})
+}
+
+// deleteContent "private" function to delete a content document
+func deleteContent(c *gin.Context, collection string, id primitive.ObjectID) bool {
+	err := repos.Content.DeleteContentDocument(c, collection, id)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "Document was not found"})
+			return false
+		}
+
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return false
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Document was deleted successfully",
+	})
+
+	return true
+}
+
+// abortIfInUse "private" function to abort the request if the check fails or returns true
+func abortIfInUse(c *gin.Context, inUse bool, err error, message string) bool {
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return true
+	}
+
+	if inUse {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": message})
+		return true
+	}
+
+	return false
+}
+
+// validateRewardChances "private" function to validate the fields shared by items and loom balls
+func validateRewardChances(name string, serial int, chancePlayer, chanceOwner float64, minQuantity, maxQuantity int) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("Name cannot be empty")
+	}
+
+	if serial <= 0 {
+		return errors.New("Serial must be positive")
+	}
+
+	if chancePlayer < 0 || chancePlayer > 1 || chanceOwner < 0 || chanceOwner > 1 {
+		return errors.New("Reward chances must be between 0 and 1")
+	}
+
+	if minQuantity < 1 || maxQuantity < minQuantity {
+		return errors.New("Reward quantities must be positive and the maximum can't be lower than the minimum")
+	}
+
+	return nil
+}
+
+// ## Base loomies
+
+// parseBaseLoomie "private" function to validate the request and convert the types and rarity names to ids
+func parseBaseLoomie(c *gin.Context, id primitive.ObjectID) (interfaces.BaseLoomies, bool) {
+	var form interfaces.AdminBaseLoomieReq
+
+	if err := c.BindJSON(&form); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Bad request"})
+		return interfaces.BaseLoomies{}, false
+	}
+
+	if strings.TrimSpace(form.Name) == "" || form.Serial <= 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Name cannot be empty and serial must be positive"})
+		return interfaces.BaseLoomies{}, false
+	}
+
+	if form.BaseHp <= 0 || form.BaseAttack <= 0 || form.BaseDefense <= 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Base stats must be positive"})
+		return interfaces.BaseLoomies{}, false
+	}
+
+	if len(form.Types) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Loomies must have at least one type"})
+		return interfaces.BaseLoomies{}, false
+	}
+
+	loomieTypes, err := repos.Content.GetLoomieTypesByNames(form.Types)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return interfaces.BaseLoomies{}, false
+	}
+
+	if len(loomieTypes) != len(form.Types) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Some types don't exist or are duplicated"})
+		return interfaces.BaseLoomies{}, false
+	}
+
+	rarity, err := repos.Content.GetLoomieRarityByName(form.Rarity)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Rarity doesn't exist"})
+			return interfaces.BaseLoomies{}, false
+		}
+
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return interfaces.BaseLoomies{}, false
+	}
+
+	inUse, err := repos.Content.IsContentFieldInUse(repositories.BaseLoomiesContent, "serial", form.Serial, id)
+	if abortIfInUse(c, inUse, err, "Serial is already in use") {
+		return interfaces.BaseLoomies{}, false
+	}
+
+	baseLoomie := interfaces.BaseLoomies{
+		Serial:      form.Serial,
+		Name:        form.Name,
+		Rarity:      rarity.Id,
+		BaseHp:      form.BaseHp,
+		BaseAttack:  form.BaseAttack,
+		BaseDefense: form.BaseDefense,
+	}
+
+	for _, loomieType := range loomieTypes {
+		baseLoomie.Types = append(baseLoomie.Types, loomieType.Id)
+	}
+
+	return baseLoomie, true
+}
+
+// HandleAdminGetBaseLoomies Handle the request to get all the base loomies
+func HandleAdminGetBaseLoomies(c *gin.Context) {
+	respondWithContent(c, repositories.BaseLoomiesContent, "serial", &[]interfaces.BaseLoomies{})
+}
+
+// HandleAdminCreateBaseLoomie Handle the request to create a new base loomie
+func HandleAdminCreateBaseLoomie(c *gin.Context) {
+	baseLoomie, ok := parseBaseLoomie(c, primitive.NilObjectID)
+	if !ok {
+		return
+	}
+
+	saveContent(c, repositories.BaseLoomiesContent, primitive.NilObjectID, baseLoomie)
+}
+
+// HandleAdminUpdateBaseLoomie Handle the request to replace a base loomie
+func HandleAdminUpdateBaseLoomie(c *gin.Context) {
+	id, ok := getContentId(c)
+	if !ok {
+		return
+	}
+
+	baseLoomie, ok := parseBaseLoomie(c, id)
+	if !ok {
+		return
+	}
+
+	saveContent(c, repositories.BaseLoomiesContent, id, baseLoomie)
+}
+
+// HandleAdminDeleteBaseLoomie Handle the request to delete a base loomie (Already caught loomies are not affected)
+func HandleAdminDeleteBaseLoomie(c *gin.Context) {
+	id, ok := getContentId(c)
+	if !ok {
+		return
+	}
+
+	deleteContent(c, repositories.BaseLoomiesContent, id)
+}
+
+// ## Items
+
+// parseItem "private" function to validate the item in the request
+func parseItem(c *gin.Context, id primitive.ObjectID) (interfaces.Item, bool) {
+	var item interfaces.Item
+
+	if err := c.BindJSON(&item); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Bad request"})
+		return item, false
+	}
+
+	err := validateRewardChances(item.Name, item.Serial, item.GymRewardChancePlayer, item.GymRewardChanceOwner, item.MinRewardQuantity, item.MaxRewardQuantity)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": err.Error()})
+		return item, false
+	}
+
+	// Currently items only target loomies
+	if item.Target != "Loomie" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Target must be Loomie"})
+		return item, false
+	}
+
+	inUse, err := repos.Content.IsRewardSerialInUse(item.Serial, id)
+	if abortIfInUse(c, inUse, err, "Serial is already in use") {
+		return item, false
+	}
+
+	item.Id = primitive.NilObjectID
+	return item, true
+}
+
+// HandleAdminGetItems Handle the request to get all the items
+func HandleAdminGetItems(c *gin.Context) {
+	respondWithContent(c, repositories.ItemsContent, "serial", &[]interfaces.Item{})
+}
+
+// HandleAdminCreateItem Handle the request to create a new item
+func HandleAdminCreateItem(c *gin.Context) {
+	item, ok := parseItem(c, primitive.NilObjectID)
+	if !ok {
+		return
+	}
+
+	saveContent(c, repositories.ItemsContent, primitive.NilObjectID, item)
+}
+
+// HandleAdminUpdateItem Handle the request to replace an item
+func HandleAdminUpdateItem(c *gin.Context) {
+	id, ok := getContentId(c)
+	if !ok {
+		return
+	}
+
+	item, ok := parseItem(c, id)
+	if !ok {
+		return
+	}
+
+	saveContent(c, repositories.ItemsContent, id, item)
+}
+
+// HandleAdminDeleteItem Handle the request to delete an item that is not in any inventory or gym reward
+func HandleAdminDeleteItem(c *gin.Context) {
+	id, ok := getContentId(c)
+	if !ok {
+		return
+	}
+
+	inUse, err := repos.Content.IsRewardInUse(id)
+	if abortIfInUse(c, inUse, err, "The item is in some inventories or gym rewards") {
+		return
+	}
+
+	deleteContent(c, repositories.ItemsContent, id)
+}
+
+// ## Loom balls
+
+// parseLoomball "private" function to validate the loom ball in the request
+func parseLoomball(c *gin.Context, id primitive.ObjectID) (interfaces.Loomball, bool) {
+	var loomball interfaces.Loomball
+
+	if err := c.BindJSON(&loomball); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Bad request"})
+		return loomball, false
+	}
+
+	err := validateRewardChances(loomball.Name, loomball.Serial, loomball.GymRewardChancePlayer, loomball.GymRewardChanceOwner, loomball.MinRewardQuantity, loomball.MaxRewardQuantity)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": err.Error()})
+		return loomball, false
+	}
+
+	// The capture chance decreases between both levels (See utils.WasSuccessfulCapture)
+	if loomball.EffectiveUntil < 0 || loomball.EffectiveUntil >= loomball.DecayUntil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Effective until must be positive and lower than decay until"})
+		return loomball, false
+	}
+
+	if loomball.MinimumProbability <= 0 || loomball.MinimumProbability > 1 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Minimum probability must be greater than 0 and lower or equal than 1"})
+		return loomball, false
+	}
+
+	inUse, err := repos.Content.IsRewardSerialInUse(loomball.Serial, id)
+	if abortIfInUse(c, inUse, err, "Serial is already in use") {
+		return loomball, false
+	}
+
+	loomball.Id = primitive.NilObjectID
+	return loomball, true
+}
+
+// HandleAdminGetLoomballs Handle the request to get all the loom balls
+func HandleAdminGetLoomballs(c *gin.Context) {
+	respondWithContent(c, repositories.LoomballsContent, "serial", &[]interfaces.Loomball{})
+}
+
+// HandleAdminCreateLoomball Handle the request to create a new loom ball
+func HandleAdminCreateLoomball(c *gin.Context) {
+	loomball, ok := parseLoomball(c, primitive.NilObjectID)
+	if !ok {
+		return
+	}
+
+	saveContent(c, repositories.LoomballsContent, primitive.NilObjectID, loomball)
+}
+
+// HandleAdminUpdateLoomball Handle the request to replace a loom ball
+func HandleAdminUpdateLoomball(c *gin.Context) {
+	id, ok := getContentId(c)
+	if !ok {
+		return
+	}
+
+	loomball, ok := parseLoomball(c, id)
+	if !ok {
+		return
+	}
+
+	saveContent(c, repositories.LoomballsContent, id, loomball)
+}
+
+// HandleAdminDeleteLoomball Handle the request to delete a loom ball that is not in any inventory or gym reward
+func HandleAdminDeleteLoomball(c *gin.Context) {
+	id, ok := getContentId(c)
+	if !ok {
+		return
+	}
+
+	inUse, err := repos.Content.IsRewardInUse(id)
+	if abortIfInUse(c, inUse, err, "The loom ball is in some inventories or gym rewards") {
+		return
+	}
+
+	deleteContent(c, repositories.LoomballsContent, id)
+}
+
+// ## Loomie types
+
+// parseLoomieType "private" function to validate the type and convert the strong against names to ids
+func parseLoomieType(c *gin.Context, id primitive.ObjectID) (interfaces.LoomieType, bool) {
+	var form interfaces.AdminLoomieTypeReq
+
+	if err := c.BindJSON(&form); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Bad request"})
+		return interfaces.LoomieType{}, false
+	}
+
+	if strings.TrimSpace(form.Name) == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Name cannot be empty"})
+		return interfaces.LoomieType{}, false
+	}
+
+	inUse, err := repos.Content.IsContentFieldInUse(repositories.LoomieTypesContent, "name", form.Name, id)
+	if abortIfInUse(c, inUse, err, "Name is already in use") {
+		return interfaces.LoomieType{}, false
+	}
+
+	strongAgainst, err := repos.Content.GetLoomieTypesByNames(form.StrongAgainst)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return interfaces.LoomieType{}, false
+	}
+
+	if len(strongAgainst) != len(form.StrongAgainst) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Some strong against types don't exist or are duplicated"})
+		return interfaces.LoomieType{}, false
+	}
+
+	loomieType := interfaces.LoomieType{Name: form.Name, StrongAgainst: []primitive.ObjectID{}}
+
+	for _, strongAgainstType := range strongAgainst {
+		loomieType.StrongAgainst = append(loomieType.StrongAgainst, strongAgainstType.Id)
+	}
+
+	return loomieType, true
+}
+
+// HandleAdminGetLoomieTypes Handle the request to get all the loomie types
+func HandleAdminGetLoomieTypes(c *gin.Context) {
+	respondWithContent(c, repositories.LoomieTypesContent, "name", &[]interfaces.LoomieType{})
+}
+
+// HandleAdminCreateLoomieType Handle the request to create a new loomie type
+func HandleAdminCreateLoomieType(c *gin.Context) {
+	loomieType, ok := parseLoomieType(c, primitive.NilObjectID)
+	if !ok {
+		return
+	}
+
+	saveContent(c, repositories.LoomieTypesContent, primitive.NilObjectID, loomieType)
+	invalidateContentCaches()
+}
+
+// HandleAdminUpdateLoomieType Handle the request to replace a loomie type
+func HandleAdminUpdateLoomieType(c *gin.Context) {
+	id, ok := getContentId(c)
+	if !ok {
+		return
+	}
+
+	loomieType, ok := parseLoomieType(c, id)
+	if !ok {
+		return
+	}
+
+	saveContent(c, repositories.LoomieTypesContent, id, loomieType)
+	invalidateContentCaches()
+}
+
+// HandleAdminDeleteLoomieType Handle the request to delete a loomie type that is not used by any base loomie
+func HandleAdminDeleteLoomieType(c *gin.Context) {
+	id, ok := getContentId(c)
+	if !ok {
+		return
+	}
+
+	inUse, err := repos.Content.IsLoomieTypeInUse(id)
+	if abortIfInUse(c, inUse, err, "The type is used by some base loomies") {
+		return
+	}
+
+	if deleteContent(c, repositories.LoomieTypesContent, id) {
+		if err := repos.Content.RemoveLoomieTypeReferences(c, id); err != nil {
+			fmt.Println("Unable to remove the type references:", err)
+		}
+	}
+
+	invalidateContentCaches()
+}
+
+// ## Loomie rarities
+
+// parseLoomieRarity "private" function to validate the rarity in the request
+func parseLoomieRarity(c *gin.Context, id primitive.ObjectID) (interfaces.LoomieRarity, bool) {
+	var rarity interfaces.LoomieRarity
+
+	if err := c.BindJSON(&rarity); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Bad request"})
+		return rarity, false
+	}
+
+	if strings.TrimSpace(rarity.Name) == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Name cannot be empty"})
+		return rarity, false
+	}
+
+	if rarity.SpawnChance <= 0 || rarity.SpawnChance > 1 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Spawn chance must be greater than 0 and lower or equal than 1"})
+		return rarity, false
+	}
+
+	inUse, err := repos.Content.IsContentFieldInUse(repositories.LoomieRaritiesContent, "name", rarity.Name, id)
+	if abortIfInUse(c, inUse, err, "Name is already in use") {
+		return rarity, false
+	}
+
+	rarity.Id = primitive.NilObjectID
+	return rarity, true
+}
+
+// HandleAdminGetLoomieRarities Handle the request to get all the loomie rarities
+func HandleAdminGetLoomieRarities(c *gin.Context) {
+	respondWithContent(c, repositories.LoomieRaritiesContent, "name", &[]interfaces.LoomieRarity{})
+}
+
+// HandleAdminCreateLoomieRarity Handle the request to create a new loomie rarity
+func HandleAdminCreateLoomieRarity(c *gin.Context) {
+	rarity, ok := parseLoomieRarity(c, primitive.NilObjectID)
+	if !ok {
+		return
+	}
+
+	saveContent(c, repositories.LoomieRaritiesContent, primitive.NilObjectID, rarity)
+	invalidateContentCaches()
+}
+
+// HandleAdminUpdateLoomieRarity Handle the request to replace a loomie rarity
+func HandleAdminUpdateLoomieRarity(c *gin.Context) {
+	id, ok := getContentId(c)
+	if !ok {
+		return
+	}
+
+	rarity, ok := parseLoomieRarity(c, id)
+	if !ok {
+		return
+	}
+
+	saveContent(c, repositories.LoomieRaritiesContent, id, rarity)
+	invalidateContentCaches()
+}
+
+// HandleAdminDeleteLoomieRarity Handle the request to delete a loomie rarity that is not used by any base loomie
+func HandleAdminDeleteLoomieRarity(c *gin.Context) {
+	id, ok := getContentId(c)
+	if !ok {
+		return
+	}
+
+	inUse, err := repos.Content.IsLoomieRarityInUse(id)
+	if abortIfInUse(c, inUse, err, "The rarity is used by some base loomies") {
+		return
+	}
+
+	deleteContent(c, repositories.LoomieRaritiesContent, id)
+	invalidateContentCaches()
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/PedroChaparro/loomies-backend/combat"
+	"github.com/PedroChaparro/loomies-backend/middlewares"
+	"github.com/PedroChaparro/loomies-backend/tests"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ## Helper functions
+// setupContentRouter creates a router with the game content endpoints
+func setupContentRouter() *gin.Engine {
+	router := tests.SetupGinRouter()
+	router.POST("/session/login", HandleLogIn)
+	content := router.Group("/admin", middlewares.MustProvideAccessToken(), middlewares.RequirePermission(utils.PermissionManageContent))
+	content.POST("/base-loomies", HandleAdminCreateBaseLoomie)
+	content.POST("/loom-balls", HandleAdminCreateLoomball)
+	content.POST("/loomie-types", HandleAdminCreateLoomieType)
+	content.PUT("/loomie-types/:id", HandleAdminUpdateLoomieType)
+	content.DELETE("/loomie-types/:id", HandleAdminDeleteLoomieType)
+	content.POST("/rarities", HandleAdminCreateLoomieRarity)
+	content.DELETE("/rarities/:id", HandleAdminDeleteLoomieRarity)
+	return router
+}
+
+// sendContentRequest sends a request with the given access token and returns the status code and the parsed response
+func sendContentRequest(router *gin.Engine, method string, endpoint string, payload interface{}, accessToken string) (int, map[string]interface{}) {
+	var response map[string]interface{}
+	w, req := tests.SetupPayloadedRequest(endpoint, method, payload, tests.CustomHeader{Name: "Access-Token", Value: accessToken})
+	router.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response
+}
+
+// ## Tests
+
+// TestContentValidation tests invalid game content is rejected
+func TestContentValidation(t *testing.T) {
+	c := require.New(t)
+	router := setupContentRouter()
+	admin, accessToken := loginWithRoles(router, utils.RoleAdmin)
+	player, playerToken := loginWithRoles(router)
+
+	// 1. Players can't manage the content
+	code, _ := sendContentRequest(router, "POST", "/admin/rarities", map[string]interface{}{"name": "Legendary", "spawn_chance": 0.1}, playerToken)
+	c.Equal(http.StatusForbidden, code)
+
+	// 2. Spawn chances must be positive
+	code, response := sendContentRequest(router, "POST", "/admin/rarities", map[string]interface{}{"name": "Legendary", "spawn_chance": 0}, accessToken)
+	c.Equal(http.StatusBadRequest, code)
+	c.Equal("Spawn chance must be greater than 0 and lower or equal than 1", response["message"])
+
+	// 3. The loom balls effective level must be lower than the decay level
+	code, response = sendContentRequest(router, "POST", "/admin/loom-balls", map[string]interface{}{
+		"name":                     "Broken LoomBall",
+		"serial":                   1000,
+		"effective_until":          30,
+		"decay_until":              15,
+		"minimum_probability":      0.1,
+		"gym_reward_chance_player": 0.1,
+		"gym_reward_chance_owner":  0.1,
+		"min_reward_quantity":      1,
+		"max_reward_quantity":      1,
+	}, accessToken)
+	c.Equal(http.StatusBadRequest, code)
+	c.Equal("Effective until must be positive and lower than decay until", response["message"])
+
+	// 4. Base loomies must have existing types
+	code, _ = sendContentRequest(router, "POST", "/admin/base-loomies", map[string]interface{}{
+		"serial":       1000,
+		"name":         "Unknown Loomie",
+		"types":        []string{"Unknown Type"},
+		"rarity":       "Common",
+		"base_hp":      100,
+		"base_attack":  20,
+		"base_defense": 10,
+	}, accessToken)
+	c.Equal(http.StatusBadRequest, code)
+
+	err := tests.DeleteUser(repos, admin.Email)
+	c.NoError(err)
+	err = tests.DeleteUser(repos, player.Email)
+	c.NoError(err)
+}
+
+// TestContentChanges tests the content changes are audited and invalidate the cached types
+func TestContentChanges(t *testing.T) {
+	c := require.New(t)
+	router := setupContentRouter()
+	admin, accessToken := loginWithRoles(router, utils.RoleAdmin)
+	typeName := "Test Type " + tests.FakerInstance.UUID().V4()
+
+	combat.GlobalWsHub = &combat.WsHub{
+		Combats:             make(map[string]*combat.WsCombat),
+		CachedStrongAgainst: map[string][]string{"Water": {"Fire"}},
+	}
+
+	// 1. Create a type
+	code, response := sendContentRequest(router, "POST", "/admin/loomie-types", map[string]interface{}{"name": typeName, "strong_against": []string{"Water"}}, accessToken)
+	c.Equal(http.StatusOK, code)
+	typeId, _ := primitive.ObjectIDFromHex(response["_id"].(string))
+
+	_, cached := combat.GlobalWsHub.GetCachedStrongAgainst("Water")
+	c.False(cached)
+
+	// 2. Update the type
+	code, _ = sendContentRequest(router, "PUT", "/admin/loomie-types/"+typeId.Hex(), map[string]interface{}{"name": typeName, "strong_against": []string{"Fire", "Rock"}}, accessToken)
+	c.Equal(http.StatusOK, code)
+
+	loomieType := testStore.LoomieTypes[typeId]
+	c.Equal(2, len(loomieType.StrongAgainst))
+
+	// 3. Delete the type
+	code, _ = sendContentRequest(router, "DELETE", "/admin/loomie-types/"+typeId.Hex(), nil, accessToken)
+	c.Equal(http.StatusOK, code)
+
+	// 4. All the changes were audited
+	actions := []string{}
+	for _, event := range testStore.AuditEvents {
+		if event.EntityId == typeId && event.ActorId == admin.Id {
+			actions = append(actions, event.Action)
+		}
+	}
+
+	c.Equal([]string{"content.create", "content.update", "content.delete", "content.remove_references"}, actions)
+
+	err := tests.DeleteUser(repos, admin.Email)
+	c.NoError(err)
+}
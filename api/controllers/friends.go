@@ -0,0 +1,472 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Seconds since the last activity to consider a friend online
+const onlineStatusWindow = 5 * 60
+
+// getVisibleUser "private" function to get the user with the given username. The users that blocked (or were blocked by)
+// the session user are reported as not found to don't reveal the block lists
+func getVisibleUser(c *gin.Context, user interfaces.User, username string) (interfaces.User, bool) {
+	target, err := repos.Users.GetUserByUsername(strings.TrimSpace(username))
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "User was not found"})
+			return target, false
+		}
+
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return target, false
+	}
+
+	if utils.IsBlockedBetween(user, target) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "User was not found"})
+		return target, false
+	}
+
+	return target, true
+}
+
+// getFriendRequest "private" function to get the friend request with the id from the path if the user is part of it
+func getFriendRequest(c *gin.Context, user interfaces.User) (interfaces.Friendship, bool) {
+	friendshipId, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Invalid friend request id"})
+		return interfaces.Friendship{}, false
+	}
+
+	friendship, err := repos.Friends.GetFriendshipById(user.Id, friendshipId)
+
+	if err != nil || friendship.Status != repositories.FriendshipPending {
+		if err == nil || err == mongo.ErrNoDocuments {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "Friend request was not found"})
+			return friendship, false
+		}
+
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return friendship, false
+	}
+
+	return friendship, true
+}
+
+// getOtherUserId "private" function to get the id of the other user of the friendship
+func getOtherUserId(friendship interfaces.Friendship, userId primitive.ObjectID) primitive.ObjectID {
+	if friendship.RequesterId == userId {
+		return friendship.RecipientId
+	}
+
+	return friendship.RequesterId
+}
+
+// getFriendSummary "private" function to get the public information of a friend. The presence is only included
+// if the friend shares it
+func getFriendSummary(friend interfaces.User) gin.H {
+	level := friend.Level
+	if level == 0 {
+		level = 1
+	}
+
+	summary := gin.H{
+		"_id":          friend.Id,
+		"username":     friend.Username,
+		"display_name": getDisplayName(friend),
+		"avatar":       friend.Profile.Avatar,
+		"level":        level,
+	}
+
+	if friend.Profile.ShareOnlineStatus {
+		summary["online"] = time.Now().Unix()-friend.LastSeenAt <= onlineStatusWindow
+		summary["last_seen_at"] = friend.LastSeenAt
+	}
+
+	if friend.Profile.ShareLastSeenZone {
+		summary["last_seen_zone"] = friend.LastSeenZone
+	}
+
+	return summary
+}
+
+// getFriendshipsUsers "private" function to get the other users of the given friendships by id
+func getFriendshipsUsers(friendships []interfaces.Friendship, userId primitive.ObjectID) (map[primitive.ObjectID]interfaces.User, error) {
+	ids := []primitive.ObjectID{}
+	for _, friendship := range friendships {
+		ids = append(ids, getOtherUserId(friendship, userId))
+	}
+
+	users, err := repos.Users.GetUsersByIds(ids)
+	usersById := make(map[primitive.ObjectID]interfaces.User)
+
+	for _, user := range users {
+		usersById[user.Id] = user
+	}
+
+	return usersById, err
+}
+
+// HandleSendFriendRequest Handle the request to send a friend request by username. If the other user already sent
+// a request to the session user, it's accepted instead
+func HandleSendFriendRequest(c *gin.Context) {
+	var form interfaces.UsernameReq
+
+	if err := c.BindJSON(&form); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Bad request"})
+		return
+	}
+
+	if strings.TrimSpace(form.Username) == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Username cannot be empty"})
+		return
+	}
+
+	user, ok := getSessionUser(c)
+	if !ok {
+		return
+	}
+
+	if rejectMutedUser(c, user) {
+		return
+	}
+
+	recipient, ok := getVisibleUser(c, user, form.Username)
+	if !ok {
+		return
+	}
+
+	if recipient.Id == user.Id {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "You can't send a friend request to yourself"})
+		return
+	}
+
+	friendship, err := repos.Friends.GetFriendshipBetween(user.Id, recipient.Id)
+
+	if err != nil && err != mongo.ErrNoDocuments {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	if err == nil {
+		if friendship.Status == repositories.FriendshipAccepted {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "You are already friends"})
+			return
+		}
+
+		if friendship.RequesterId == user.Id {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "Friend request was already sent"})
+			return
+		}
+
+		if _, err := repos.Friends.AcceptFriendRequest(c, friendship); err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, gin.H{"error": false, "message": "Friend request was accepted successfully"})
+		return
+	}
+
+	friendship, err = repos.Friends.CreateFriendRequest(c, user.Id, recipient.Id)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusCreated, gin.H{
+		"error":   false,
+		"message": "Friend request was sent successfully",
+		"request": friendship,
+	})
+}
+
+// HandleGetFriendRequests Handle the request to get the pending friend requests sent and received by the user
+func HandleGetFriendRequests(c *gin.Context) {
+	user, ok := getSessionUser(c)
+	if !ok {
+		return
+	}
+
+	friendships, err := repos.Friends.GetUserFriendships(user.Id, repositories.FriendshipPending)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	users, err := getFriendshipsUsers(friendships, user.Id)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	incoming := []gin.H{}
+	outgoing := []gin.H{}
+
+	for _, friendship := range friendships {
+		other, exists := users[getOtherUserId(friendship, user.Id)]
+		if !exists {
+			continue
+		}
+
+		request := gin.H{
+			"_id":          friendship.Id,
+			"username":     other.Username,
+			"display_name": getDisplayName(other),
+			"created_at":   friendship.CreatedAt,
+		}
+
+		if friendship.RecipientId == user.Id {
+			incoming = append(incoming, request)
+		} else {
+			outgoing = append(outgoing, request)
+		}
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":    false,
+		"message":  "Friend requests were retrieved successfully",
+		"incoming": incoming,
+		"outgoing": outgoing,
+	})
+}
+
+// HandleAcceptFriendRequest Handle the request to accept a received friend request
+func HandleAcceptFriendRequest(c *gin.Context) {
+	user, ok := getSessionUser(c)
+	if !ok {
+		return
+	}
+
+	friendship, ok := getFriendRequest(c, user)
+	if !ok {
+		return
+	}
+
+	if friendship.RecipientId != user.Id {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": true, "message": "Only the recipient can accept the friend request"})
+		return
+	}
+
+	accepted, err := repos.Friends.AcceptFriendRequest(c, friendship)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	if !accepted {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "Friend request was not found"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"error": false, "message": "Friend request was accepted successfully"})
+}
+
+// HandleDeclineFriendRequest Handle the request to decline a received friend request (or cancel a sent one)
+func HandleDeclineFriendRequest(c *gin.Context) {
+	user, ok := getSessionUser(c)
+	if !ok {
+		return
+	}
+
+	friendship, ok := getFriendRequest(c, user)
+	if !ok {
+		return
+	}
+
+	action := "friendship.decline"
+	if friendship.RequesterId == user.Id {
+		action = "friendship.cancel"
+	}
+
+	if err := repos.Friends.DeleteFriendship(c, user.Id, friendship, action); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"error": false, "message": "Friend request was declined successfully"})
+}
+
+// HandleGetFriends Handle the request to get the friends of the user with their presence (if they share it)
+func HandleGetFriends(c *gin.Context) {
+	user, ok := getSessionUser(c)
+	if !ok {
+		return
+	}
+
+	friendships, err := repos.Friends.GetUserFriendships(user.Id, repositories.FriendshipAccepted)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	users, err := getFriendshipsUsers(friendships, user.Id)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	friends := []gin.H{}
+	for _, friendship := range friendships {
+		friend, exists := users[getOtherUserId(friendship, user.Id)]
+		if !exists {
+			continue
+		}
+
+		summary := getFriendSummary(friend)
+		summary["friends_since"] = friendship.AcceptedAt
+		friends = append(friends, summary)
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Friends were retrieved successfully",
+		"friends": friends,
+	})
+}
+
+// HandleRemoveFriend Handle the request to remove a friend by username
+func HandleRemoveFriend(c *gin.Context) {
+	user, ok := getSessionUser(c)
+	if !ok {
+		return
+	}
+
+	friend, ok := getVisibleUser(c, user, c.Param("username"))
+	if !ok {
+		return
+	}
+
+	friendship, err := repos.Friends.GetFriendshipBetween(user.Id, friend.Id)
+
+	if err != nil || friendship.Status != repositories.FriendshipAccepted {
+		if err == nil || err == mongo.ErrNoDocuments {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "You are not friends"})
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	if err := repos.Friends.DeleteFriendship(c, user.Id, friendship, "friendship.remove"); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"error": false, "message": "Friend was removed successfully"})
+}
+
+// HandleBlockUser Handle the request to block a user by username. The friendship and the pending requests
+// between the users are removed
+func HandleBlockUser(c *gin.Context) {
+	var form interfaces.UsernameReq
+
+	if err := c.BindJSON(&form); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Bad request"})
+		return
+	}
+
+	if strings.TrimSpace(form.Username) == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Username cannot be empty"})
+		return
+	}
+
+	user, ok := getSessionUser(c)
+	if !ok {
+		return
+	}
+
+	blocked, err := repos.Users.GetUserByUsername(strings.TrimSpace(form.Username))
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "User was not found"})
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	if blocked.Id == user.Id {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "You can't block yourself"})
+		return
+	}
+
+	if err := repos.Friends.BlockUser(c, user, blocked.Id); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"error": false, "message": "User was blocked successfully"})
+}
+
+// HandleUnblockUser Handle the request to remove a user from the block list
+func HandleUnblockUser(c *gin.Context) {
+	user, ok := getSessionUser(c)
+	if !ok {
+		return
+	}
+
+	blocked, err := repos.Users.GetUserByUsername(strings.TrimSpace(c.Param("username")))
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "User was not found"})
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	if err := repos.Friends.UnblockUser(c, user, blocked.Id); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"error": false, "message": "User was unblocked successfully"})
+}
+
+// HandleGetBlockedUsers Handle the request to get the users blocked by the session user
+func HandleGetBlockedUsers(c *gin.Context) {
+	user, ok := getSessionUser(c)
+	if !ok {
+		return
+	}
+
+	blockedUsers, err := repos.Users.GetUsersByIds(user.BlockedUsers)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	blocked := []gin.H{}
+	for _, blockedUser := range blockedUsers {
+		blocked = append(blocked, gin.H{"_id": blockedUser.Id, "username": blockedUser.Username})
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Blocked users were retrieved successfully",
+		"blocked": blocked,
+	})
+}
@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/middlewares"
+	"github.com/PedroChaparro/loomies-backend/tests"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+// ## Helper functions
+// setupFriendsRouter creates a router with the friends, blocks and profile endpoints
+func setupFriendsRouter() *gin.Engine {
+	router := setupProfileRouter()
+	router.GET("/user/friends", middlewares.MustProvideAccessToken(), HandleGetFriends)
+	router.DELETE("/user/friends/:username", middlewares.MustProvideAccessToken(), HandleRemoveFriend)
+	router.GET("/user/friends/requests", middlewares.MustProvideAccessToken(), HandleGetFriendRequests)
+	router.POST("/user/friends/requests", middlewares.MustProvideAccessToken(), HandleSendFriendRequest)
+	router.POST("/user/friends/requests/:id/accept", middlewares.MustProvideAccessToken(), HandleAcceptFriendRequest)
+	router.POST("/user/friends/requests/:id/decline", middlewares.MustProvideAccessToken(), HandleDeclineFriendRequest)
+	router.POST("/user/blocks", middlewares.MustProvideAccessToken(), HandleBlockUser)
+	router.DELETE("/user/blocks/:username", middlewares.MustProvideAccessToken(), HandleUnblockUser)
+	return router
+}
+
+// getWithToken sends a GET request with the given access token
+func getWithToken(router *gin.Engine, endpoint string, accessToken string) (int, map[string]interface{}) {
+	var response map[string]interface{}
+	w, req := tests.SetupGetRequest(endpoint, tests.CustomHeader{Name: "Access-Token", Value: accessToken})
+	router.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response
+}
+
+// ## Tests
+
+// TestFriendRequests tests the friend requests can be sent, accepted, declined and removed
+func TestFriendRequests(t *testing.T) {
+	c := require.New(t)
+	router := setupFriendsRouter()
+	user, accessToken := loginWithRoles(router)
+	other, otherToken := loginWithRoles(router)
+
+	// 1. Invalid requests
+	code, response := sendContentRequest(router, "POST", "/user/friends/requests", map[string]interface{}{"username": user.Username}, accessToken)
+	c.Equal(http.StatusBadRequest, code)
+	c.Equal("You can't send a friend request to yourself", response["message"])
+
+	code, response = sendContentRequest(router, "POST", "/user/friends/requests", map[string]interface{}{"username": "not-a-real-trainer"}, accessToken)
+	c.Equal(http.StatusNotFound, code)
+	c.Equal("User was not found", response["message"])
+
+	// 2. Send the request, it can't be sent twice
+	code, response = sendContentRequest(router, "POST", "/user/friends/requests", map[string]interface{}{"username": other.Username}, accessToken)
+	c.Equal(http.StatusCreated, code)
+	requestId := response["request"].(map[string]interface{})["_id"].(string)
+
+	code, response = sendContentRequest(router, "POST", "/user/friends/requests", map[string]interface{}{"username": other.Username}, accessToken)
+	c.Equal(http.StatusConflict, code)
+	c.Equal("Friend request was already sent", response["message"])
+
+	// 3. The request is listed for both users
+	_, response = getWithToken(router, "/user/friends/requests", otherToken)
+	c.Equal(1, len(response["incoming"].([]interface{})))
+	c.Equal(user.Username, response["incoming"].([]interface{})[0].(map[string]interface{})["username"])
+
+	_, response = getWithToken(router, "/user/friends/requests", accessToken)
+	c.Equal(1, len(response["outgoing"].([]interface{})))
+
+	// 4. Only the recipient can accept the request
+	code, _ = sendContentRequest(router, "POST", "/user/friends/requests/"+requestId+"/accept", nil, accessToken)
+	c.Equal(http.StatusForbidden, code)
+
+	code, _ = sendContentRequest(router, "POST", "/user/friends/requests/"+requestId+"/accept", nil, otherToken)
+	c.Equal(http.StatusOK, code)
+
+	code, response = sendContentRequest(router, "POST", "/user/friends/requests", map[string]interface{}{"username": other.Username}, accessToken)
+	c.Equal(http.StatusConflict, code)
+	c.Equal("You are already friends", response["message"])
+
+	// 5. The presence is hidden until the friend shares it
+	_, response = getWithToken(router, "/user/friends", accessToken)
+	friends := response["friends"].([]interface{})
+	c.Equal(1, len(friends))
+	c.NotContains(friends[0], "online")
+	c.NotContains(friends[0], "last_seen_zone")
+
+	code, _ = sendContentRequest(router, "PATCH", "/user/profile", map[string]interface{}{"share_online_status": true}, otherToken)
+	c.Equal(http.StatusOK, code)
+	c.NoError(repos.Users.UpdateUserPresence(other.Id, "1,2"))
+
+	_, response = getWithToken(router, "/user/friends", accessToken)
+	friend := response["friends"].([]interface{})[0].(map[string]interface{})
+	c.Equal(true, friend["online"])
+	c.NotContains(friend, "last_seen_zone")
+
+	// 6. The friends can see the hidden fields of the trainer card
+	code, _ = sendContentRequest(router, "PATCH", "/user/profile", map[string]interface{}{"hidden_fields": []string{"level"}}, otherToken)
+	c.Equal(http.StatusOK, code)
+
+	_, response = getTrainerCard(router, other.Username, accessToken)
+	c.Contains(response["card"], "level")
+
+	// 7. Remove the friend
+	code, _ = sendContentRequest(router, "DELETE", "/user/friends/"+user.Username, nil, otherToken)
+	c.Equal(http.StatusOK, code)
+
+	_, response = getWithToken(router, "/user/friends", accessToken)
+	c.Empty(response["friends"])
+
+	_, response = getTrainerCard(router, other.Username, accessToken)
+	c.NotContains(response["card"], "level")
+
+	// 8. Decline a new request
+	_, response = sendContentRequest(router, "POST", "/user/friends/requests", map[string]interface{}{"username": user.Username}, otherToken)
+	requestId = response["request"].(map[string]interface{})["_id"].(string)
+
+	code, _ = sendContentRequest(router, "POST", "/user/friends/requests/"+requestId+"/decline", nil, accessToken)
+	c.Equal(http.StatusOK, code)
+
+	code, _ = sendContentRequest(router, "POST", "/user/friends/requests/"+requestId+"/accept", nil, accessToken)
+	c.Equal(http.StatusNotFound, code)
+
+	tests.DeleteUser(repos, user.Email)
+	tests.DeleteUser(repos, other.Email)
+}
+
+// TestBlockUsers tests the blocked users can't find the user that blocked them
+func TestBlockUsers(t *testing.T) {
+	c := require.New(t)
+	router := setupFriendsRouter()
+	user, accessToken := loginWithRoles(router)
+	other, otherToken := loginWithRoles(router)
+
+	// 1. Become friends
+	_, response := sendContentRequest(router, "POST", "/user/friends/requests", map[string]interface{}{"username": other.Username}, accessToken)
+	requestId := response["request"].(map[string]interface{})["_id"].(string)
+	code, _ := sendContentRequest(router, "POST", "/user/friends/requests/"+requestId+"/accept", nil, otherToken)
+	c.Equal(http.StatusOK, code)
+
+	// 2. Block the user, the friendship is removed
+	code, _ = sendContentRequest(router, "POST", "/user/blocks", map[string]interface{}{"username": user.Username}, otherToken)
+	c.Equal(http.StatusOK, code)
+
+	areFriends, err := repos.Friends.AreFriends(user.Id, other.Id)
+	c.NoError(err)
+	c.False(areFriends)
+
+	// 3. The users can't find each other
+	code, _ = getTrainerCard(router, other.Username, accessToken)
+	c.Equal(http.StatusNotFound, code)
+
+	code, _ = getTrainerCard(router, user.Username, otherToken)
+	c.Equal(http.StatusNotFound, code)
+
+	code, response = sendContentRequest(router, "POST", "/user/friends/requests", map[string]interface{}{"username": other.Username}, accessToken)
+	c.Equal(http.StatusNotFound, code)
+	c.Equal("User was not found", response["message"])
+
+	// 4. The gyms owner is hidden to the blocked user
+	gyms := getTestGyms()
+	gym := gyms[len(gyms)-1]
+	updateTestGym(gym.Id, func(gym *interfaces.Gym) { gym.Owner = other.Id })
+
+	populated, err := repos.Gyms.GetPopulatedGymFromId(gym.Id, user.Id)
+	c.NoError(err)
+	c.Empty(populated.Owner)
+
+	// 5. Unblock the user
+	code, _ = sendContentRequest(router, "DELETE", "/user/blocks/"+user.Username, nil, otherToken)
+	c.Equal(http.StatusOK, code)
+
+	code, _ = getTrainerCard(router, other.Username, accessToken)
+	c.Equal(http.StatusOK, code)
+
+	populated, err = repos.Gyms.GetPopulatedGymFromId(gym.Id, user.Id)
+	c.NoError(err)
+	c.Equal(other.Username, populated.Owner)
+
+	tests.DeleteUser(repos, user.Email)
+	tests.DeleteUser(repos, other.Email)
+}
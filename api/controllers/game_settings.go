@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Maximum amount of versions returned by the game settings endpoint
+const maxGameSettingsVersionsLimit = 100
+
+// respondWithPublishedGameSettings "private" function to respond with the published version or the error
+func respondWithPublishedGameSettings(c *gin.Context, version interfaces.GameSettingsVersion, err error) {
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Game settings were published successfully",
+		"version": version,
+	})
+}
+
+// HandleAdminGetGameSettings Handle the request to get the game balance in use, its defaults and the published versions
+func HandleAdminGetGameSettings(c *gin.Context) {
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "20"), 10, 64)
+
+	if err != nil || limit <= 0 || limit > maxGameSettingsVersionsLimit {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Limit must be between 1 and " + strconv.Itoa(maxGameSettingsVersionsLimit)})
+		return
+	}
+
+	versions, err := repos.GameSettings.GetGameSettingsVersions(limit)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":    false,
+		"message":  "Game settings were found",
+		"version":  gameBalance.Version(),
+		"settings": gameBalance.Get(),
+		"defaults": gameBalance.Defaults(),
+		"versions": versions,
+	})
+}
+
+// HandleAdminPublishGameSettings Handle the request to publish a new version of the game balance
+func HandleAdminPublishGameSettings(c *gin.Context) {
+	var form interfaces.GameSettingsReq
+
+	if err := c.BindJSON(&form); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Bad request"})
+		return
+	}
+
+	if problems := form.Settings.Validate(); len(problems) > 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Invalid game settings: " + strings.Join(problems, ", ")})
+		return
+	}
+
+	version, err := repos.GameSettings.PublishGameSettings(c, form.Settings, strings.TrimSpace(form.Comment), getActorId(c), 0)
+	respondWithPublishedGameSettings(c, version, err)
+}
+
+// HandleAdminRollbackGameSettings Handle the request to publish again a previous version of the game balance
+func HandleAdminRollbackGameSettings(c *gin.Context) {
+	var form interfaces.GameSettingsRollbackReq
+
+	if err := c.BindJSON(&form); err != nil || form.Version <= 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Bad request"})
+		return
+	}
+
+	version, err := repos.GameSettings.RollbackGameSettings(c, form.Version, strings.TrimSpace(form.Comment), getActorId(c))
+
+	if err == mongo.ErrNoDocuments {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "Game settings version was not found"})
+		return
+	}
+
+	respondWithPublishedGameSettings(c, version, err)
+}
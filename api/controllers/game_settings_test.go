@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/PedroChaparro/loomies-backend/middlewares"
+	"github.com/PedroChaparro/loomies-backend/tests"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+// ## Helper functions
+// setupGameSettingsRouter creates a router with the game settings endpoints
+func setupGameSettingsRouter() *gin.Engine {
+	router := tests.SetupGinRouter()
+	router.POST("/session/login", HandleLogIn)
+	content := router.Group("/admin", middlewares.MustProvideAccessToken(), middlewares.RequirePermission(utils.PermissionManageContent))
+	content.GET("/game-settings", HandleAdminGetGameSettings)
+	content.POST("/game-settings", HandleAdminPublishGameSettings)
+	content.POST("/game-settings/rollback", HandleAdminRollbackGameSettings)
+	return router
+}
+
+// ## Tests
+
+// TestGameSettingsValidation tests the invalid game settings are rejected
+func TestGameSettingsValidation(t *testing.T) {
+	c := require.New(t)
+	router := setupGameSettingsRouter()
+	admin, accessToken := loginWithRoles(router, utils.RoleAdmin)
+	player, playerToken := loginWithRoles(router)
+
+	settings := config.Game.Balance()
+	settings.GymDodgeProbability = 150
+
+	// 1. Players can't change the game settings
+	code, _ := sendContentRequest(router, "POST", "/admin/game-settings", map[string]interface{}{"settings": settings}, playerToken)
+	c.Equal(http.StatusForbidden, code)
+
+	// 2. The probabilities must be percentages
+	code, response := sendContentRequest(router, "POST", "/admin/game-settings", map[string]interface{}{"settings": settings}, accessToken)
+	c.Equal(http.StatusBadRequest, code)
+	c.Contains(response["message"], "combat_gym_dodge_probability must be between 0 and 100")
+
+	// 3. Only the published versions can be restored
+	code, _ = sendContentRequest(router, "POST", "/admin/game-settings/rollback", map[string]interface{}{"version": 100000}, accessToken)
+	c.Equal(http.StatusNotFound, code)
+
+	err := tests.DeleteUser(repos, admin.Email)
+	c.NoError(err)
+	err = tests.DeleteUser(repos, player.Email)
+	c.NoError(err)
+}
+
+// TestGameSettingsPublishAndRollback tests the published versions are used immediately and can be rolled back
+func TestGameSettingsPublishAndRollback(t *testing.T) {
+	c := require.New(t)
+	router := setupGameSettingsRouter()
+	admin, accessToken := loginWithRoles(router, utils.RoleAdmin)
+	defer gameBalance.Reset()
+
+	original := gameBalance.Get()
+	settings := original
+	settings.WildLoomiesTTL = original.WildLoomiesTTL + 5
+	settings.PainKillersHeal = 75
+
+	// 1. Publish a new version
+	code, response := sendContentRequest(router, "POST", "/admin/game-settings", map[string]interface{}{"settings": settings, "comment": "Longer loomies"}, accessToken)
+	c.Equal(http.StatusOK, code)
+	published := int(response["version"].(map[string]interface{})["version"].(float64))
+	c.Equal(published, gameBalance.Version())
+	c.Equal(75, gameBalance.Get().PainKillersHeal)
+
+	// 2. Publish other version and roll back to the first one
+	settings.PainKillersHeal = 20
+	code, _ = sendContentRequest(router, "POST", "/admin/game-settings", map[string]interface{}{"settings": settings}, accessToken)
+	c.Equal(http.StatusOK, code)
+	c.Equal(20, gameBalance.Get().PainKillersHeal)
+
+	code, response = sendContentRequest(router, "POST", "/admin/game-settings/rollback", map[string]interface{}{"version": published}, accessToken)
+	c.Equal(http.StatusOK, code)
+	rollback := response["version"].(map[string]interface{})
+	c.Equal(float64(published+2), rollback["version"])
+	c.Equal(float64(published), rollback["rolled_back_to"])
+	c.Equal(75, gameBalance.Get().PainKillersHeal)
+
+	// 3. The history keeps all the versions
+	code, response = sendContentRequest(router, "GET", "/admin/game-settings?limit=3", nil, accessToken)
+	c.Equal(http.StatusOK, code)
+	c.Equal(float64(published+2), response["version"])
+	c.Equal(3, len(response["versions"].([]interface{})))
+
+	testStore.GameSettingsVersions = testStore.GameSettingsVersions[:len(testStore.GameSettingsVersions)-3]
+	err := tests.DeleteUser(repos, admin.Email)
+	c.NoError(err)
+}
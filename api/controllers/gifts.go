@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// sealGift "private" function to hide the rewards of the gifts that weren't opened yet
+func sealGift(gift interfaces.Gift) interfaces.Gift {
+	if !gift.Opened {
+		gift.Rewards = nil
+	}
+
+	return gift
+}
+
+// HandleSendGift Handle the request to send a gift to a friend. The rewards are drawn from the gift table and
+// sealed until the friend opens the gift
+func HandleSendGift(c *gin.Context) {
+	var form interfaces.UsernameReq
+
+	if err := c.BindJSON(&form); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Bad request"})
+		return
+	}
+
+	if strings.TrimSpace(form.Username) == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Username cannot be empty"})
+		return
+	}
+
+	user, ok := getSessionUser(c)
+	if !ok || rejectMutedUser(c, user) {
+		return
+	}
+
+	friend, ok := getVisibleUser(c, user, form.Username)
+	if !ok {
+		return
+	}
+
+	if friend.Id == user.Id {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "You can't send a gift to yourself"})
+		return
+	}
+
+	areFriends, err := repos.Friends.AreFriends(user.Id, friend.Id)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	if !areFriends {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": true, "message": "You can only send gifts to your friends"})
+		return
+	}
+
+	// The days start at the midnight of the user region
+	settings := config.Gift
+	sentToday := utils.GetGiftsSentToday(user, repos.Zones.GetUserLocation(user))
+
+	if sentToday >= settings.GiftsPerDay {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": repositories.ErrGiftsLimitReached.Error()})
+		return
+	}
+
+	rewards, err := repos.Gifts.NewGiftRewards(settings.MinRewards, settings.MaxRewards)
+
+	if err != nil || len(rewards) == 0 {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	gift, err := repos.Gifts.SendGift(c, user.Id, friend.Id, rewards, settings.GiftsPerDay)
+
+	if err != nil {
+		if errors.Is(err, repositories.ErrGiftsLimitReached) || errors.Is(err, repositories.ErrGiftAlreadySent) {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": err.Error()})
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusCreated, gin.H{
+		"error":     false,
+		"message":   "Gift was sent successfully",
+		"gift":      sealGift(gift),
+		"remaining": settings.GiftsPerDay - sentToday - 1,
+	})
+}
+
+// HandleGetGifts Handle the request to get the gifts received by the user that weren't opened yet
+func HandleGetGifts(c *gin.Context) {
+	user, ok := getSessionUser(c)
+	if !ok {
+		return
+	}
+
+	gifts, err := repos.Gifts.GetReceivedGifts(user.Id, false)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	senderIds := []primitive.ObjectID{}
+	for _, gift := range gifts {
+		senderIds = append(senderIds, gift.SenderId)
+	}
+
+	senders, err := repos.Users.GetUsersByIds(senderIds)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	// The usernames of the blocked (or deleted) senders are left empty
+	usernames := make(map[primitive.ObjectID]string)
+	for _, sender := range senders {
+		if !utils.IsBlockedBetween(user, sender) {
+			usernames[sender.Id] = sender.Username
+		}
+	}
+
+	response := []interfaces.GiftRes{}
+	for _, gift := range gifts {
+		response = append(response, interfaces.GiftRes{Gift: sealGift(gift), SenderUsername: usernames[gift.SenderId]})
+	}
+
+	settings := config.Gift
+	sentToday := utils.GetGiftsSentToday(user, repos.Zones.GetUserLocation(user))
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":      false,
+		"message":    "Gifts were retrieved successfully",
+		"gifts":      response,
+		"sent_today": sentToday,
+		"remaining":  settings.GiftsPerDay - sentToday,
+	})
+}
+
+// HandleOpenGift Handle the request to open a received gift adding the rewards to the inventory
+func HandleOpenGift(c *gin.Context) {
+	user, ok := getSessionUser(c)
+	if !ok {
+		return
+	}
+
+	giftId, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Invalid gift id"})
+		return
+	}
+
+	gift, err := repos.Gifts.GetReceivedGiftById(user.Id, giftId)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "Gift was not found"})
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	if gift.Opened {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": repositories.ErrGiftAlreadyOpened.Error()})
+		return
+	}
+
+	gift, err = repos.Gifts.OpenGift(c, gift)
+
+	if err != nil {
+		if errors.Is(err, repositories.ErrGiftAlreadyOpened) {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": err.Error()})
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"error": false, "message": "Gift was opened successfully", "rewards": gift.Rewards})
+}
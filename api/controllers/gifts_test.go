@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/middlewares"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"github.com/PedroChaparro/loomies-backend/tests"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"github.com/stretchr/testify/require"
+)
+
+// ## Tests
+
+// TestGifts tests the gifts can only be sent to friends once per day and opened once by the recipient
+func TestGifts(t *testing.T) {
+	ctx := context.Background()
+	c := require.New(t)
+	router := tests.SetupGinRouter()
+	router.POST("/session/login", HandleLogIn)
+	router.GET("/gifts", middlewares.MustProvideAccessToken(), HandleGetGifts)
+	router.POST("/gifts", middlewares.MustProvideAccessToken(), HandleSendGift)
+	router.POST("/gifts/:id/open", middlewares.MustProvideAccessToken(), HandleOpenGift)
+	user, accessToken := loginWithRoles(router)
+	friend, friendToken := loginWithRoles(router)
+	stranger, _ := loginWithRoles(router)
+
+	friendship, err := repos.Friends.CreateFriendRequest(ctx, user.Id, friend.Id)
+	c.NoError(err)
+	accepted, err := repos.Friends.AcceptFriendRequest(ctx, friendship)
+	c.NoError(err)
+	c.True(accepted)
+
+	// 1. The gifts can only be sent to friends
+	code, response := sendContentRequest(router, "POST", "/gifts", map[string]interface{}{"username": stranger.Username}, accessToken)
+	c.Equal(http.StatusForbidden, code)
+	c.Equal("You can only send gifts to your friends", response["message"])
+
+	// 2. Send the gift, the rewards are sealed
+	code, response = sendContentRequest(router, "POST", "/gifts", map[string]interface{}{"username": friend.Username}, accessToken)
+	c.Equal(http.StatusCreated, code)
+	c.NotContains(response["gift"], "rewards")
+	c.Equal(float64(config.Gift.GiftsPerDay-1), response["remaining"])
+
+	code, response = sendContentRequest(router, "POST", "/gifts", map[string]interface{}{"username": friend.Username}, accessToken)
+	c.Equal(http.StatusConflict, code)
+	c.Equal("You already sent a gift to this friend today", response["message"])
+
+	// 3. The friend receives the gift
+	_, response = getWithToken(router, "/gifts", friendToken)
+	gifts := response["gifts"].([]interface{})
+	c.Equal(1, len(gifts))
+	gift := gifts[0].(map[string]interface{})
+	c.Equal(user.Username, gift["sender_username"])
+	c.NotContains(gift, "rewards")
+	giftId := gift["_id"].(string)
+
+	// 4. Only the recipient can open the gift, once
+	code, _ = sendContentRequest(router, "POST", "/gifts/"+giftId+"/open", nil, accessToken)
+	c.Equal(http.StatusNotFound, code)
+
+	code, response = sendContentRequest(router, "POST", "/gifts/"+giftId+"/open", nil, friendToken)
+	c.Equal(http.StatusOK, code)
+	rewards := response["rewards"].([]interface{})
+	c.NotEmpty(rewards)
+
+	updatedFriend, err := repos.Users.GetUserById(friend.Id.Hex())
+	c.NoError(err)
+	c.Equal(len(rewards), len(updatedFriend.Items))
+
+	code, response = sendContentRequest(router, "POST", "/gifts/"+giftId+"/open", nil, friendToken)
+	c.Equal(http.StatusConflict, code)
+	c.Equal("Gift was already opened", response["message"])
+
+	_, response = getWithToken(router, "/gifts", friendToken)
+	c.Empty(response["gifts"])
+
+	// 5. The daily limit can't be exceeded
+	updatedUser, err := repos.Users.GetUserById(user.Id.Hex())
+	c.NoError(err)
+	updateTestUser(user.Id, func(user *interfaces.User) { user.GiftsSent = config.Gift.GiftsPerDay })
+
+	_, err = repos.Gifts.SendGift(ctx, user.Id, stranger.Id, []interfaces.GymRewardItem{}, config.Gift.GiftsPerDay)
+	c.ErrorIs(err, repositories.ErrGiftsLimitReached)
+	c.Equal(1, utils.GetGiftsSentToday(updatedUser, time.UTC))
+
+	tests.DeleteUser(repos, user.Email)
+	tests.DeleteUser(repos, friend.Email)
+	tests.DeleteUser(repos, stranger.Email)
+}
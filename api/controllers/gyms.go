@@ -1,14 +1,13 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
-	"math"
 	"net/http"
-	"strconv"
 
-	"github.com/PedroChaparro/loomies-backend/configuration"
 	"github.com/PedroChaparro/loomies-backend/interfaces"
-	"github.com/PedroChaparro/loomies-backend/models"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"github.com/PedroChaparro/loomies-backend/utils"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -33,10 +32,8 @@ func HandleClaimReward(c *gin.Context) {
 		return
 	}
 
-	// 1. Validate the user is near (at most the zone radius) to the gym
-	zoneRadiusStr := configuration.GetEnvironmentVariable("GAME_ZONE_RADIUS")
-	zoneRadius, _ := strconv.ParseFloat(zoneRadiusStr, 64)
-	gym, err := models.GetGymFromID(payload.GymID)
+	// 1. Validate the user is near (at most the interaction radius) to the gym
+	gym, err := repos.Gyms.GetGymFromID(payload.GymID)
 
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
@@ -48,13 +45,14 @@ func HandleClaimReward(c *gin.Context) {
 		return
 	}
 
-	if math.Abs(gym.Latitude-payload.Latitude) > zoneRadius || math.Abs(gym.Longitude-payload.Longitude) > zoneRadius {
+	gymCoordinates := interfaces.Coordinates{Latitude: gym.Latitude, Longitude: gym.Longitude}
+	if !utils.IsNear(gymCoordinates, interfaces.Coordinates{Latitude: payload.Latitude, Longitude: payload.Longitude}, config.Game) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "You are too far from the gym"})
 		return
 	}
 
 	// 2. Validate the user has not claimed the reward yet
-	if models.HasUserClaimedReward(gym.RewardsClaimedBy, userIdMongo) {
+	if utils.HasUserClaimedReward(gym.RewardsClaimedBy, userIdMongo) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "You already claimed the rewards for this gym"})
 		return
 	}
@@ -73,17 +71,15 @@ func HandleClaimReward(c *gin.Context) {
 	}
 
 	// 3. Give the reward to the user and add the user to the list of users that have claimed the reward
-	err = models.AddItemsToUserInventory(userIdMongo, playerRewards)
+	err = repos.Gyms.ClaimGymReward(c, gym, userIdMongo, playerRewards)
 
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal error when adding items to user inventory, please try again later"})
-		return
-	}
-
-	err = models.RegisterClaimedReward(gym, userIdMongo)
+		if errors.Is(err, repositories.ErrRewardAlreadyClaimed) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": err.Error()})
+			return
+		}
 
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal error when registering claimed reward, please try again later"})
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal error when claiming the rewards, please try again later"})
 		return
 	}
 
@@ -104,7 +100,7 @@ func HandleClaimReward(c *gin.Context) {
 		rewardsQuantity[reward.RewardId.Hex()] = reward.RewardQuantity
 	}
 
-	items, err := models.GetItemsFromIds(itemsIds)
+	items, err := repos.Items.GetItemsFromIds(itemsIds)
 
 	if err != nil {
 		fmt.Println(err)
@@ -112,7 +108,7 @@ func HandleClaimReward(c *gin.Context) {
 		return
 	}
 
-	loomballs, err := models.GetLoomballsFromIds(loomballsIds)
+	loomballs, err := repos.Items.GetLoomballsFromIds(loomballsIds)
 
 	if err != nil {
 		fmt.Println(err)
@@ -141,7 +137,15 @@ func HandleClaimReward(c *gin.Context) {
 		})
 	}
 
-	c.IndentedJSON(http.StatusOK, gin.H{"error": false, "message": "Reward claimed successfully", "reward": allRewards})
+	trackQuest(c, userIdMongo, repositories.QuestClaimRewardEvent, "", nil)
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":        false,
+		"message":      "Reward claimed successfully",
+		"reward":       allRewards,
+		"trainer":      addTrainerExperience(c, userIdMongo, config.Trainer.ClaimRewardExperience),
+		"achievements": trackAchievement(c, userIdMongo, repositories.AchievementClaimedRewardsCounter),
+	})
 }
 
 // HandleGetGyms Handles the request to get a gym details by id
@@ -162,7 +166,7 @@ func HandleGetGym(c *gin.Context) {
 	}
 
 	// Get Gym from database
-	gym, err := models.GetPopulatedGymFromId(gymIdMongo, userIdMongo)
+	gym, err := repos.Gyms.GetPopulatedGymFromId(gymIdMongo, userIdMongo)
 
 	if err != nil {
 		if err == mongo.ErrNoDocuments || err.Error() == "EMPTY_RESULTS" {
@@ -225,7 +229,7 @@ func HandleUpdateProtectors(c *gin.Context) {
 	}
 
 	// Check the gym exists
-	gymDoc, err := models.GetGymFromID(payload.GymId)
+	gymDoc, err := repos.Gyms.GetGymFromID(payload.GymId)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "The gym was not found"})
@@ -255,7 +259,7 @@ func HandleUpdateProtectors(c *gin.Context) {
 		loomiesMongoIds = append(loomiesMongoIds, loomieMongoId)
 	}
 
-	loomies, err := models.GetLoomiesByIds(loomiesMongoIds, userMongoId)
+	loomies, err := repos.Loomies.GetLoomiesByIds(loomiesMongoIds, userMongoId)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal error when getting loomies, please try again later"})
 		return
@@ -288,28 +292,28 @@ func HandleUpdateProtectors(c *gin.Context) {
 	}
 
 	// --- Update the gym ---
-	err = models.UpdateGymProtectors(gymDoc.Id, loomiesMongoIds)
+	err = repos.Gyms.UpdateGymProtectors(c, gymDoc.Id, loomiesMongoIds)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal error when updating gym, please try again later"})
 		return
 	}
 
 	// --- Update the busy state of the previous protectors ---
-	err = models.UpdateLoomiesBusyState(gymDoc.Protectors, false)
+	err = repos.Loomies.UpdateLoomiesBusyState(c, gymDoc.Protectors, false)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Error updating the busy state of the previous protectors, please try again later"})
 		return
 	}
 
 	// --- Update the loomies ---
-	err = models.UpdateLoomiesBusyState(loomiesMongoIds, true)
+	err = repos.Loomies.UpdateLoomiesBusyState(c, loomiesMongoIds, true)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Error updating the busy state of the new protectors, please try again later"})
 		return
 	}
 
 	// Remove the loomies from the loomie team of the player (just in case)
-	err = models.RemoveFromLoomieTeam(userMongoId, loomiesMongoIds)
+	err = repos.Users.RemoveFromLoomieTeam(c, userMongoId, loomiesMongoIds)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal error when updating the loomie team, please try again later"})
 		return
@@ -7,12 +7,9 @@ import (
 
 	"github.com/PedroChaparro/loomies-backend/interfaces"
 	"github.com/PedroChaparro/loomies-backend/middlewares"
-	"github.com/PedroChaparro/loomies-backend/models"
 	"github.com/PedroChaparro/loomies-backend/tests"
 	"github.com/stretchr/testify/require"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // TestGymDetailsSuccess Tests the `/gyms/:id` endpoint
@@ -26,9 +23,7 @@ func TestGymDetailsSuccess(t *testing.T) {
 	randomUser, loginResponse := loginWithRandomUser()
 
 	// Get an existing gym id from the database
-	var gym interfaces.Gym
-	err := models.GymsCollection.FindOne(ctx, bson.M{}).Decode(&gym)
-	c.NoError(err)
+	gym := getTestGyms()[0]
 
 	// Setup the router
 	router := tests.SetupGinRouter()
@@ -78,10 +73,8 @@ func TestGymDetailsSuccess(t *testing.T) {
 	// 2. Check with the random user as the gym owner
 	// -------------------------
 	// Update the gym owner
-	models.GymsCollection.UpdateByID(ctx, gym.Id, bson.M{
-		"$set": bson.M{
-			"owner": randomUser.Id,
-		},
+	updateTestGym(gym.Id, func(gym *interfaces.Gym) {
+		gym.Owner = randomUser.Id
 	})
 
 	// Make the request
@@ -104,10 +97,8 @@ func TestGymDetailsSuccess(t *testing.T) {
 	// 2. Check with the user claiming the reward
 	// -------------------------
 	// Update the reward field
-	models.GymsCollection.UpdateByID(ctx, gym.Id, bson.M{
-		"$push": bson.M{
-			"rewards_claimed_by": randomUser.Id,
-		},
+	updateTestGym(gym.Id, func(gym *interfaces.Gym) {
+		gym.RewardsClaimedBy = append(append([]primitive.ObjectID{}, gym.RewardsClaimedBy...), randomUser.Id)
 	})
 
 	// Make the request
@@ -127,7 +118,7 @@ func TestGymDetailsSuccess(t *testing.T) {
 	c.True(responseGym["was_reward_claimed"].(bool))
 
 	// Remove the user
-	err = tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err := tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
 
@@ -176,7 +167,7 @@ func TestGymDetailsBadRequest(t *testing.T) {
 	c.Equal("The gym was not found", response["message"])
 
 	// Delete the user
-	err := tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err := tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
 
@@ -191,9 +182,7 @@ func TestClaimGymRewardsSuccess(t *testing.T) {
 	randomUser, loginResponse := loginWithRandomUser()
 
 	// Get an existing gym
-	var gym interfaces.Gym
-	err := models.GymsCollection.FindOne(ctx, bson.M{}).Decode(&gym)
-	c.NoError(err)
+	gym := getTestGyms()[0]
 
 	// -------------------------
 	// 1. Test with user rewards
@@ -241,9 +230,7 @@ func TestClaimGymRewardsSuccess(t *testing.T) {
 
 	// Check if the rewards were added to the user
 	var user interfaces.User
-	err = models.UserCollection.FindOne(ctx, bson.M{
-		"_id": randomUser.Id,
-	}).Decode(&user)
+	user, err := repos.Users.GetUserById(randomUser.Id.Hex())
 	c.NoError(err)
 
 	// Check the rewards quantities
@@ -265,27 +252,19 @@ func TestClaimGymRewardsSuccess(t *testing.T) {
 	}
 
 	// Remove user items
-	_, err = models.UserCollection.UpdateByID(ctx, user.Id, bson.M{
-		"$set": bson.M{
-			"items": []interfaces.InventoryItem{},
-		},
+	updateTestUser(user.Id, func(user *interfaces.User) {
+		user.Items = []interfaces.InventoryItem{}
 	})
-	c.NoError(err)
 
 	// -------------------------
 	// 2. Test with owner rewards
 	// -------------------------
 
 	// Update the gym's owner
-	_, err = models.GymsCollection.UpdateByID(ctx, gym.Id, bson.M{
-		"$set": bson.M{
-			"owner": randomUser.Id,
-		},
-		"$pull": bson.M{
-			"rewards_claimed_by": randomUser.Id,
-		},
+	updateTestGym(gym.Id, func(gym *interfaces.Gym) {
+		gym.Owner = randomUser.Id
+		gym.RewardsClaimedBy = []primitive.ObjectID{}
 	})
-	c.NoError(err)
 
 	// Make the request
 	w, req = tests.SetupPayloadedRequest("/gyms/claim-rewards", "POST", map[string]interface{}{
@@ -327,9 +306,7 @@ func TestClaimGymRewardsSuccess(t *testing.T) {
 	}
 
 	// Check if the rewards were added to the user
-	err = models.UserCollection.FindOne(ctx, bson.M{
-		"_id": randomUser.Id,
-	}).Decode(&user)
+	user, err = repos.Users.GetUserById(randomUser.Id.Hex())
 	c.NoError(err)
 
 	c.Equal(len(rewards), len(user.Items))
@@ -347,15 +324,12 @@ func TestClaimGymRewardsSuccess(t *testing.T) {
 	}
 
 	// Remove user items
-	_, err = models.UserCollection.UpdateByID(ctx, user.Id, bson.M{
-		"$set": bson.M{
-			"items": []interfaces.InventoryItem{},
-		},
+	updateTestUser(user.Id, func(user *interfaces.User) {
+		user.Items = []interfaces.InventoryItem{}
 	})
-	c.NoError(err)
 
 	// Delete the user
-	err = tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err = tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
 
@@ -370,9 +344,7 @@ func TestClaimRewardsBadRequest(t *testing.T) {
 	randomUser, loginResponse := loginWithRandomUser()
 
 	// Get an existing gym
-	var gym interfaces.Gym
-	err := models.GymsCollection.FindOne(ctx, bson.M{}).Decode(&gym)
-	c.NoError(err)
+	gym := getTestGyms()[0]
 
 	// Setup the router
 	router := tests.SetupGinRouter()
@@ -467,16 +439,10 @@ func TestClaimRewardsBadRequest(t *testing.T) {
 	// 5. Check with already claimed rewards
 	// -------------------------
 	// Insert the user in the gym `rewards_claimed_by` array
-	_, err = models.GymsCollection.UpdateOne(ctx, bson.M{
-		"_id": gym.Id,
-	}, bson.M{
-		"$push": bson.M{
-			"rewards_claimed_by": randomUser.Id,
-		},
+	updateTestGym(gym.Id, func(gym *interfaces.Gym) {
+		gym.RewardsClaimedBy = append(append([]primitive.ObjectID{}, gym.RewardsClaimedBy...), randomUser.Id)
 	})
 
-	c.NoError(err)
-
 	// Make the request
 	w, req = tests.SetupPayloadedRequest("/gyms/claim-rewards", "POST", map[string]interface{}{
 		"gym_id":    gym.Id.Hex(),
@@ -495,7 +461,7 @@ func TestClaimRewardsBadRequest(t *testing.T) {
 	c.Equal("You already claimed the rewards for this gym", response["message"])
 
 	// Delete the user
-	err = tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err := tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
 
@@ -510,9 +476,8 @@ func TestUpdateProtectorsErrors(t *testing.T) {
 	randomUser, loginResponse := loginWithRandomUser()
 
 	// Get an existing gym from the end of the database collection
-	var gym interfaces.Gym
-	err := models.GymsCollection.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.M{"$natural": -1})).Decode(&gym)
-	c.NoError(err)
+	gyms := getTestGyms()
+	gym := gyms[len(gyms)-1]
 
 	// Setup the router
 	router := tests.SetupGinRouter()
@@ -629,8 +594,9 @@ func TestUpdateProtectorsErrors(t *testing.T) {
 	// 7. Check with invalid protectors ids
 	// -------------------------
 	// Update the gym owner id
-	_, err = models.GymsCollection.UpdateOne(ctx, bson.M{"_id": gym.Id}, bson.M{"$set": bson.M{"owner": randomUser.Id}})
-	c.NoError(err)
+	updateTestGym(gym.Id, func(gym *interfaces.Gym) {
+		gym.Owner = randomUser.Id
+	})
 
 	w, req = tests.SetupPayloadedRequest("/gyms/update-protectors", "PUT", map[string]interface{}{
 		"gym_id":     gym.Id.Hex(),
@@ -649,16 +615,12 @@ func TestUpdateProtectorsErrors(t *testing.T) {
 	// -------------------------
 	// 8. Check with loomies that are not owned by the user
 	// -------------------------
-	var loomies []interfaces.CaughtLoomie
-	cursor, err := models.CaughtLoomiesCollection.Find(ctx, bson.M{}, options.Find().SetLimit(6))
-	c.NoError(err)
-	err = cursor.All(ctx, &loomies)
-	c.NoError(err)
+	loomies := getTestGyms()[0].Protectors
 	c.Equal(6, len(loomies))
 
 	w, req = tests.SetupPayloadedRequest("/gyms/update-protectors", "PUT", map[string]interface{}{
 		"gym_id":     gym.Id.Hex(),
-		"protectors": []string{loomies[0].Id.Hex(), loomies[1].Id.Hex(), loomies[2].Id.Hex(), loomies[3].Id.Hex(), loomies[4].Id.Hex(), loomies[5].Id.Hex()},
+		"protectors": []string{loomies[0].Hex(), loomies[1].Hex(), loomies[2].Hex(), loomies[3].Hex(), loomies[4].Hex(), loomies[5].Hex()},
 	}, tests.CustomHeader{
 		Name:  "Access-Token",
 		Value: loginResponse["accessToken"],
@@ -673,19 +635,15 @@ func TestUpdateProtectorsErrors(t *testing.T) {
 	// -------------------------
 	// 9. Check with busy loomies
 	// -------------------------
-	// Update the owner of the loomies
-	_, err = models.CaughtLoomiesCollection.UpdateMany(ctx, bson.M{"_id": bson.M{
-		"$in": []primitive.ObjectID{loomies[0].Id, loomies[1].Id, loomies[2].Id, loomies[3].Id, loomies[4].Id, loomies[5].Id},
-	}}, bson.M{"$set": bson.M{"owner": randomUser.Id}})
-	c.NoError(err)
-
-	// Update the busy status of one of the loomies
-	_, err = models.CaughtLoomiesCollection.UpdateOne(ctx, bson.M{"_id": loomies[0].Id}, bson.M{"$set": bson.M{"is_busy": true}})
-	c.NoError(err)
+	// Give loomies to the user, one of them is busy
+	loomies = insertTestLoomies(randomUser.Id, 2, 6, false)
+	updateTestLoomies(loomies[:1], func(loomie *interfaces.CaughtLoomie) {
+		loomie.IsBusy = true
+	})
 
 	w, req = tests.SetupPayloadedRequest("/gyms/update-protectors", "PUT", map[string]interface{}{
 		"gym_id":     gym.Id.Hex(),
-		"protectors": []string{loomies[0].Id.Hex(), loomies[1].Id.Hex(), loomies[2].Id.Hex(), loomies[3].Id.Hex(), loomies[4].Id.Hex(), loomies[5].Id.Hex()},
+		"protectors": []string{loomies[0].Hex(), loomies[1].Hex(), loomies[2].Hex(), loomies[3].Hex(), loomies[4].Hex(), loomies[5].Hex()},
 	}, tests.CustomHeader{
 		Name:  "Access-Token",
 		Value: loginResponse["accessToken"],
@@ -698,7 +656,7 @@ func TestUpdateProtectorsErrors(t *testing.T) {
 	c.Equal("All the loomies must be free to protect the gym", response["message"])
 
 	// Remove the user
-	err = tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err := tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
 
@@ -713,36 +671,24 @@ func TestUpdateProtectorsSuccess(t *testing.T) {
 	randomUser, loginResponse := loginWithRandomUser()
 
 	// Get an existing gym
-	var gym interfaces.Gym
-	err := models.GymsCollection.FindOne(ctx, bson.M{}).Decode(&gym)
-	c.NoError(err)
+	gym := getTestGyms()[0]
 
-	// Get 6 caught loomies
-	var loomies []interfaces.CaughtLoomie
-	cursor, err := models.CaughtLoomiesCollection.Find(ctx, bson.M{}, options.Find().SetLimit(6))
-	c.NoError(err)
-	err = cursor.All(ctx, &loomies)
-	c.NoError(err)
-	c.Equal(6, len(loomies))
-
-	// Update the owner and busy state of the loomies
-	_, err = models.CaughtLoomiesCollection.UpdateMany(ctx,
-		bson.M{"_id": bson.M{
-			"$in": []primitive.ObjectID{loomies[0].Id, loomies[1].Id, loomies[2].Id, loomies[3].Id, loomies[4].Id, loomies[5].Id},
-		}},
-		bson.M{"$set": bson.M{"owner": randomUser.Id, "is_busy": false}})
-	c.NoError(err)
+	// Give 6 loomies to the user
+	loomies := insertTestLoomies(randomUser.Id, 2, 6, false)
 
 	// Update the gym owner
-	_, err = models.GymsCollection.UpdateOne(ctx, bson.M{"_id": gym.Id}, bson.M{"$set": bson.M{"owner": randomUser.Id}})
-	c.NoError(err)
+	updateTestGym(gym.Id, func(gym *interfaces.Gym) {
+		gym.Owner = randomUser.Id
+	})
 
 	// Update the busy state of one of the loomies and set it as a protector
-	_, err = models.CaughtLoomiesCollection.UpdateOne(ctx, bson.M{"_id": loomies[0].Id}, bson.M{"$set": bson.M{"is_busy": true}})
-	c.NoError(err)
+	updateTestLoomies(loomies[:1], func(loomie *interfaces.CaughtLoomie) {
+		loomie.IsBusy = true
+	})
 
-	_, err = models.GymsCollection.UpdateOne(ctx, bson.M{"_id": gym.Id}, bson.M{"$set": bson.M{"protectors": []primitive.ObjectID{loomies[0].Id}}})
-	c.NoError(err)
+	updateTestGym(gym.Id, func(gym *interfaces.Gym) {
+		gym.Protectors = loomies[:1]
+	})
 
 	// Setup the router
 	router := tests.SetupGinRouter()
@@ -753,7 +699,7 @@ func TestUpdateProtectorsSuccess(t *testing.T) {
 	// -------------------------
 	w, req := tests.SetupPayloadedRequest("/gyms/update-protectors", "PUT", map[string]interface{}{
 		"gym_id":     gym.Id.Hex(),
-		"protectors": []string{loomies[0].Id.Hex(), loomies[1].Id.Hex(), loomies[2].Id.Hex(), loomies[3].Id.Hex(), loomies[4].Id.Hex(), loomies[5].Id.Hex()},
+		"protectors": []string{loomies[0].Hex(), loomies[1].Hex(), loomies[2].Hex(), loomies[3].Hex(), loomies[4].Hex(), loomies[5].Hex()},
 	}, tests.CustomHeader{
 		Name:  "Access-Token",
 		Value: loginResponse["accessToken"],
@@ -766,26 +712,20 @@ func TestUpdateProtectorsSuccess(t *testing.T) {
 	c.Equal("Gym protectors were successfully updated", response["message"])
 
 	// Check the gym protectors
-	err = models.GymsCollection.FindOne(ctx, bson.M{"_id": gym.Id}).Decode(&gym)
+	gym, err := repos.Gyms.GetGymFromID(gym.Id.Hex())
 	c.NoError(err)
 	c.Equal(6, len(gym.Protectors))
 
 	for index := range gym.Protectors {
-		c.Equal(loomies[index].Id, gym.Protectors[index])
+		c.Equal(loomies[index], gym.Protectors[index])
 	}
 
 	// Check the loomies busy state
-	cursor, err = models.CaughtLoomiesCollection.Find(ctx, bson.M{"_id": bson.M{"$in": gym.Protectors}})
-	c.NoError(err)
-	err = cursor.All(ctx, &loomies)
-	c.NoError(err)
-	c.Equal(6, len(loomies))
-
-	for index := range loomies {
-		c.Equal(true, loomies[index].IsBusy)
+	for _, protector := range gym.Protectors {
+		c.Equal(true, testStore.CaughtLoomies[protector].IsBusy)
 	}
 
 	// Remove the user
-	err = tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err = tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
@@ -4,7 +4,6 @@ import (
 	"net/http"
 
 	"github.com/PedroChaparro/loomies-backend/interfaces"
-	"github.com/PedroChaparro/loomies-backend/models"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -13,7 +12,7 @@ import (
 // HandleGetItems Handle the request to obtain the user's items
 func HandleGetItems(c *gin.Context) {
 	userid, _ := c.Get("userid")
-	user, err := models.GetUserById(userid.(string))
+	user, err := repos.Users.GetUserById(userid.(string))
 
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
@@ -25,7 +24,7 @@ func HandleGetItems(c *gin.Context) {
 		}
 	}
 
-	items, loomballs, err := models.GetItemById(user.Items)
+	items, loomballs, err := repos.Items.GetInventory(user.Items)
 
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
@@ -76,7 +75,7 @@ func HandleUseItem(c *gin.Context) {
 
 	var item interfaces.PopulatedInventoryItem
 
-	item, err = models.GetItemFromUserInventory(user, itemId, true)
+	item, err = repos.Items.GetItemFromUserInventory(user, itemId, true)
 
 	if err != nil {
 		if err.Error() == "USER_DOES_NOT_OWN_ITEM" {
@@ -106,18 +105,18 @@ func HandleUseItem(c *gin.Context) {
 		return
 	}
 
-	err = models.DecrementItemFromUserInventory(user, itemId, 1)
+	err = repos.Items.DecrementItemFromUserInventory(c, user, itemId, 1)
 
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error decrementing item from user"})
 		return
 	}
 
-	err = models.IncrementLoomieLevel(user, loomieId, 1)
+	err = repos.Loomies.IncrementLoomieLevel(c, user, loomieId, 1)
 
 	if err != nil {
 		// Item quantity is restored to the user
-		models.IncrementItemFromUserInventory(user, itemId, 1)
+		repos.Items.IncrementItemFromUserInventory(c, user, itemId, 1)
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error updating level of Loomie"})
 		return
 	}
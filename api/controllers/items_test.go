@@ -5,12 +5,9 @@ import (
 	"encoding/json"
 	"testing"
 
-	"github.com/PedroChaparro/loomies-backend/interfaces"
 	"github.com/PedroChaparro/loomies-backend/middlewares"
-	"github.com/PedroChaparro/loomies-backend/models"
 	"github.com/PedroChaparro/loomies-backend/tests"
 	"github.com/stretchr/testify/require"
-	"go.mongodb.org/mongo-driver/bson"
 )
 
 // TestGetUserItemsSuccess Test the `/user/items` endpoint
@@ -55,9 +52,7 @@ func TestGetUserItemsSuccess(t *testing.T) {
 	// -------------------------
 
 	// Get an existing gym to claim it's rewards
-	var gym interfaces.Gym
-	err := models.GymsCollection.FindOne(ctx, bson.M{}).Decode(&gym)
-	c.NoError(err)
+	gym := getTestGyms()[0]
 
 	// Claim the gym's rewards
 	w, req = tests.SetupPayloadedRequest("/gyms/claim-rewards", "POST", map[string]interface{}{
@@ -153,7 +148,7 @@ func TestGetUserItemsSuccess(t *testing.T) {
 	}
 
 	// Delete the user
-	err = tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err := tests.DeleteUser(repos, randomUser.Email)
 	c.Nil(err)
 }
 
@@ -168,7 +163,7 @@ func TestGetUserItemsBadRequest(t *testing.T) {
 	randomUser, loginResponse := loginWithRandomUser()
 
 	// Delete the user
-	err := tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err := tests.DeleteUser(repos, randomUser.Email)
 	c.Nil(err)
 
 	// Setup the router
@@ -201,22 +196,12 @@ func TestUseItemErrors(t *testing.T) {
 	randomUser, loginResponse := loginWithRandomUser()
 
 	// Get items from the database
-	var unknownBeverage interfaces.Item
-	err := models.ItemsCollection.FindOne(ctx, bson.M{
-		"serial": 7,
-	}).Decode(&unknownBeverage)
-	c.Nil(err)
+	unknownBeverage := getTestItemBySerial(7)
 
-	var smallAidKit interfaces.Item
-	err = models.ItemsCollection.FindOne(ctx, bson.M{
-		"serial": 2,
-	}).Decode(&smallAidKit)
-	c.Nil(err)
+	smallAidKit := getTestItemBySerial(2)
 
-	// Get one random loomie from the database
-	var loomie interfaces.CaughtLoomie
-	err = models.CaughtLoomiesCollection.FindOne(ctx, bson.M{}).Decode(&loomie)
-	c.Nil(err)
+	// Get the first protector of a gym
+	loomie := testStore.CaughtLoomies[getTestGyms()[0].Protectors[0]]
 
 	// Setup the router
 	router := tests.SetupGinRouter()
@@ -291,18 +276,7 @@ func TestUseItemErrors(t *testing.T) {
 	// Test 5: Test with a non-supported item
 	// -------------------------
 	// Add the item to the user inventory
-	_, err = models.UserCollection.UpdateOne(ctx, bson.M{
-		"_id": randomUser.Id,
-	}, bson.M{
-		"$push": bson.M{
-			"items": bson.M{
-				"item_collection": "items",
-				"item_id":         smallAidKit.Id,
-				"item_quantity":   13,
-			},
-		},
-	})
-	c.NoError(err)
+	addTestInventoryItem(randomUser.Id, "items", smallAidKit.Id, 13)
 
 	// Make the request
 	w, req = tests.SetupPayloadedRequest("/items/use", "POST", map[string]interface{}{
@@ -323,17 +297,7 @@ func TestUseItemErrors(t *testing.T) {
 	// Test 6: Test with not enough items
 	// -------------------------
 	// Add the item to the user inventory
-	_, err = models.UserCollection.UpdateOne(ctx, bson.M{
-		"_id": randomUser.Id,
-	}, bson.M{
-		"$push": bson.M{
-			"items": bson.M{
-				"item_collection": "items",
-				"item_id":         unknownBeverage.Id,
-				"item_quantity":   0,
-			},
-		}})
-	c.NoError(err)
+	addTestInventoryItem(randomUser.Id, "items", unknownBeverage.Id, 0)
 
 	// Make the request
 	w, req = tests.SetupPayloadedRequest("/items/use", "POST", map[string]interface{}{
@@ -351,7 +315,7 @@ func TestUseItemErrors(t *testing.T) {
 	c.Equal("You don't have enough of this item", response["message"])
 
 	// Remove the user
-	err = tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err := tests.DeleteUser(repos, randomUser.Email)
 	c.Nil(err)
 }
 
@@ -366,16 +330,10 @@ func TestUseItemSuccess(t *testing.T) {
 	randomUser, loginResponse := loginWithRandomUser()
 
 	// Get the unknown beverage item
-	var unknownBeverage interfaces.Item
-	err := models.ItemsCollection.FindOne(ctx, bson.M{
-		"serial": 7,
-	}).Decode(&unknownBeverage)
-	c.NoError(err)
+	unknownBeverage := getTestItemBySerial(7)
 
-	// Get a loomie from the database
-	var loomie interfaces.CaughtLoomie
-	err = models.CaughtLoomiesCollection.FindOne(ctx, bson.M{}).Decode(&loomie)
-	c.NoError(err)
+	// Give a loomie to the user
+	loomie := testStore.CaughtLoomies[insertTestLoomies(randomUser.Id, 2, 1, false)[0]]
 
 	// Setup the router
 	router := tests.SetupGinRouter()
@@ -385,38 +343,7 @@ func TestUseItemSuccess(t *testing.T) {
 	// Test 1: Valid request
 	// -------------------------
 	// Add the item to the user inventory
-	_, err = models.UserCollection.UpdateOne(ctx, bson.M{
-		"_id": randomUser.Id,
-	},
-		bson.M{
-			"$push": bson.M{
-				"items": bson.M{
-					"item_collection": "items",
-					"item_id":         unknownBeverage.Id,
-					"item_quantity":   2,
-				},
-			}})
-	c.NoError(err)
-
-	// Add the loomie to the user loomies
-	_, err = models.UserCollection.UpdateOne(ctx, bson.M{
-		"_id": randomUser.Id,
-	}, bson.M{
-		"$push": bson.M{
-			"loomies": loomie.Id,
-		},
-	})
-	c.NoError(err)
-
-	// Update the loomie owner
-	_, err = models.CaughtLoomiesCollection.UpdateOne(ctx, bson.M{
-		"_id": loomie.Id,
-	}, bson.M{
-		"$set": bson.M{
-			"owner": randomUser.Id,
-		},
-	})
-	c.NoError(err)
+	addTestInventoryItem(randomUser.Id, "items", unknownBeverage.Id, 2)
 
 	// Make the request
 	w, req := tests.SetupPayloadedRequest("/items/use", "POST", map[string]interface{}{
@@ -434,24 +361,17 @@ func TestUseItemSuccess(t *testing.T) {
 	c.Equal("Item was successfully used", response["message"])
 
 	// Check the user inventory
-	var user interfaces.User
-	err = models.UserCollection.FindOne(ctx, bson.M{
-		"_id": randomUser.Id,
-	}).Decode(&user)
+	user, err := repos.Users.GetUserById(randomUser.Id.Hex())
 	c.NoError(err)
 	c.Equal(1, len(user.Items))
 	c.Equal(unknownBeverage.Id, user.Items[0].ItemId)
 	c.Equal(1, user.Items[0].ItemQuantity)
 
 	// Check the loomie
-	var finalLoomie interfaces.CaughtLoomie
-	err = models.CaughtLoomiesCollection.FindOne(ctx, bson.M{
-		"_id": loomie.Id,
-	}).Decode(&finalLoomie)
-	c.NoError(err)
+	finalLoomie := testStore.CaughtLoomies[loomie.Id]
 	c.Equal(loomie.Level+1, finalLoomie.Level)
 
 	// Remove the user
-	err = tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err = tests.DeleteUser(repos, randomUser.Email)
 	c.Nil(err)
 }
@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Maximum amount of runs returned by the job runs endpoint
+const maxJobRunsLimit = 200
+
+// HandleAdminGetJobRuns Handle the request to get the last runs of the scheduled jobs
+func HandleAdminGetJobRuns(c *gin.Context) {
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "50"), 10, 64)
+
+	if err != nil || limit <= 0 || limit > maxJobRunsLimit {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Limit must be between 1 and 200"})
+		return
+	}
+
+	runs, err := repos.Jobs.GetJobRuns(c.Query("job"), limit)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Job runs were retrieved successfully",
+		"runs":    runs,
+	})
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/middlewares"
+	"github.com/PedroChaparro/loomies-backend/tests"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ## Helper functions
+// setupJobsRouter creates a router with the job runs endpoint
+func setupJobsRouter() *gin.Engine {
+	router := tests.SetupGinRouter()
+	router.POST("/session/login", HandleLogIn)
+	admin := router.Group("/admin", middlewares.MustProvideAccessToken())
+	admin.GET("/job-runs", middlewares.RequirePermission(utils.PermissionReadAudit), HandleAdminGetJobRuns)
+	return router
+}
+
+// ## Tests
+
+// TestGetJobRuns tests the runs of the scheduled jobs can be listed by the admins
+func TestGetJobRuns(t *testing.T) {
+	c := require.New(t)
+	router := setupJobsRouter()
+	admin, adminToken := loginWithRoles(router, utils.RoleAdmin)
+	player, playerToken := loginWithRoles(router)
+
+	now := time.Now().Unix()
+	testStore.JobRuns = append(testStore.JobRuns, interfaces.JobRun{
+		Id:          primitive.NewObjectID(),
+		Job:         "test_job",
+		Owner:       "test",
+		ScheduledAt: now,
+		StartedAt:   now,
+		FinishedAt:  now,
+		Succeeded:   true,
+		Summary:     "nothing to do",
+	})
+
+	// 1. Players can't read the runs
+	code, _ := sendContentRequest(router, "GET", "/admin/job-runs", nil, playerToken)
+	c.Equal(http.StatusForbidden, code)
+
+	// 2. The limit is validated
+	code, _ = sendContentRequest(router, "GET", "/admin/job-runs?limit=500", nil, adminToken)
+	c.Equal(http.StatusBadRequest, code)
+
+	// 3. Get the runs of the job
+	code, response := sendContentRequest(router, "GET", "/admin/job-runs?job=test_job", nil, adminToken)
+	c.Equal(http.StatusOK, code)
+
+	runs := response["runs"].([]interface{})
+	c.Equal(1, len(runs))
+	c.Equal("nothing to do", runs[0].(map[string]interface{})["summary"])
+	c.Equal(true, runs[0].(map[string]interface{})["succeeded"])
+
+	testStore.JobRuns = testStore.JobRuns[:len(testStore.JobRuns)-1]
+	err := tests.DeleteUser(repos, admin.Email)
+	c.NoError(err)
+	err = tests.DeleteUser(repos, player.Email)
+	c.NoError(err)
+}
@@ -8,9 +8,8 @@ import (
 	"strings"
 	"time"
 
-	"github.com/PedroChaparro/loomies-backend/configuration"
 	"github.com/PedroChaparro/loomies-backend/interfaces"
-	"github.com/PedroChaparro/loomies-backend/models"
+	"github.com/PedroChaparro/loomies-backend/repositories"
 	"github.com/PedroChaparro/loomies-backend/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/mroth/weightedrand/v2"
@@ -25,17 +24,18 @@ func generateLoomies(userId string, userCoordinates interfaces.Coordinates) erro
 		"SERVER_BASE_LOOMIES_ERROR":     errors.New("Error getting the base loomies. Please try again later."),
 		"SERVER_UPDATE_TIMES_ERROR":     errors.New("Error updating the user times. Please try again later."),
 		"SERVER_OUTDATED_LOOMIES_ERROR": errors.New("Error removing the outdated loomies. Please try again later."),
+		"SERVER_REGION_ERROR":           errors.New("Error getting the region. Please try again later."),
 	}
 
 	// Remove the expired loomies before generating new ones
-	err := models.RemoveNearExpiredLoomies(userCoordinates)
+	err := repos.Loomies.RemoveNearExpiredLoomies(userCoordinates)
 
 	if err != nil {
 		return errors["SERVER_OUTDATED_LOOMIES_ERROR"]
 	}
 
 	// 1. Get the user doc from the database to validate the generation times
-	user, err := models.GetUserById(userId)
+	user, err := repos.Users.GetUserById(userId)
 
 	if err != nil {
 		return errors["USER_NOT_FOUND"]
@@ -53,36 +53,67 @@ func generateLoomies(userId string, userCoordinates interfaces.Coordinates) erro
 		return nil
 	}
 
-	// 3. Generate loomies
-	baseLoomies, err := models.GetBaseLoomies() // All the possible loomies to generate
+	// 3. Generate loomies in the region of the user (There are no loomies outside the regions)
+	region, err := repos.Zones.GetRegionFromCoordinates(userCoordinates)
+
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+
+	if err != nil {
+		return errors["SERVER_REGION_ERROR"]
+	}
+
+	baseLoomies, err := repos.Loomies.GetBaseLoomies() // All the possible loomies to generate
 
 	if err != nil {
 		return errors["SERVER_BASE_LOOMIES_ERROR"]
 	}
 
 	// Get the amount of loomies to generate between the min and max
-	minAmount, maxAmount := configuration.GetLoomiesGenerationAmounts()
-	loomiesAmount := utils.GetRandomInt(minAmount, maxAmount)
+	balance := gameBalance.Get()
+	loomiesAmount := utils.GetRandomInt(balance.MinLoomiesGenerationAmount, balance.MaxLoomiesGenerationAmount)
 	weightedChooses := []weightedrand.Choice[interfaces.BaseLoomiesWithPopulatedRarity, int]{}
 
+	// The spawn table of the region replaces the rarities spawn chances
+	regionChances := map[int]float64{}
+	for _, spawn := range region.SpawnTable {
+		regionChances[spawn.Serial] = spawn.Chance
+	}
+
 	// Create the weighted choices
 	// Read: https://pkg.go.dev/github.com/mroth/weightedrand/v2
 	for _, loomie := range baseLoomies {
+		spawnChance := loomie.PopulatedRarity.SpawnChance
+
+		if len(regionChances) > 0 {
+			regionChance, ok := regionChances[loomie.Serial]
+			if !ok {
+				continue
+			}
+
+			spawnChance = regionChance
+		}
+
 		// The chance is a float between 0 and 1, so we multiply it by 100 to get a percentage
-		chance := int(loomie.PopulatedRarity.SpawnChance * 100)
+		chance := int(spawnChance * 100)
 		weightedChooses = append(weightedChooses, weightedrand.NewChoice(loomie, chance))
 	}
 
-	weightedChooser, _ := weightedrand.NewChooser(
+	weightedChooser, err := weightedrand.NewChooser(
 		weightedChooses...,
 	)
 
+	if err != nil {
+		return errors["SERVER_BASE_LOOMIES_ERROR"]
+	}
+
 	// Generate the loomies
 	for i := 0; i < loomiesAmount; i++ {
 		result := weightedChooser.Pick()
 
 		// Get random coordinates to spawn the new loomie
-		randomCoordinates := utils.GetRandomCoordinatesNear(userCoordinates)
+		randomCoordinates := utils.GetRandomCoordinatesNear(userCoordinates, config.Game.LoomiesGenerationRadius)
 
 		/* fmt.Printf("Picked: %v \n", gin.H{
 			"Name":   result.Name,
@@ -106,13 +137,12 @@ func generateLoomies(userId string, userCoordinates interfaces.Coordinates) erro
 		}
 
 		// Insert the new loomie in the database
-		models.InsertWildLoomie(wildLoomie)
+		repos.Loomies.InsertWildLoomie(region, wildLoomie)
 	}
 
 	// 4. Update the generation time and timeout in the user doc
-	minTimeout, maxTimeout := configuration.GetLoomiesGenerationTimeouts()
-	randomTimeout := utils.GetRandomInt(minTimeout, maxTimeout)
-	err = models.UpdateUserGenerationTimes(userId, currentTimestamp, int64(randomTimeout))
+	randomTimeout := utils.GetRandomInt(balance.MinLoomiesGenerationTimeout, balance.MaxLoomiesGenerationTimeout)
+	err = repos.Users.UpdateUserGenerationTimes(userId, currentTimestamp, int64(randomTimeout))
 
 	return nil
 }
@@ -148,7 +178,7 @@ func HandleNearLoomies(c *gin.Context) {
 	}
 
 	// 2. Return the loomies near the user coordinates
-	wildLoomies, err := models.GetNearWildLoomies(coordinates, userMongoId)
+	wildLoomies, err := repos.Loomies.GetNearWildLoomies(coordinates, userMongoId)
 
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
@@ -166,6 +196,17 @@ func HandleNearLoomies(c *gin.Context) {
 		return
 	}
 
+	// The different zones visited by the user count for the quests and the zone is shared with the friends (if allowed)
+	if region, err := repos.Zones.GetRegionFromCoordinates(coordinates); err == nil {
+		zoneX, zoneY := utils.GetZoneCoordinatesFromGPS(region, coordinates)
+		zoneKey := utils.GetZoneKey(region, zoneX, zoneY)
+		trackQuest(c, userMongoId, repositories.QuestVisitZoneEvent, zoneKey, nil)
+
+		if err := repos.Users.UpdateUserPresence(userMongoId, zoneKey); err != nil {
+			fmt.Println("Unable to update the user presence:", err)
+		}
+	}
+
 	c.IndentedJSON(http.StatusOK, gin.H{
 		"error":   false,
 		"message": "Loomies were retrieved successfully",
@@ -176,7 +217,7 @@ func HandleNearLoomies(c *gin.Context) {
 // HandleValidateLoomieExists Handle the request to validate if a loomie exists
 func HandleValidateLoomieExists(c *gin.Context) {
 	loomie_id := c.Param("id")
-	_, err := models.GetWildLoomieById(loomie_id)
+	_, err := repos.Loomies.GetWildLoomieById(loomie_id)
 
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
@@ -217,7 +258,7 @@ func HandleFuseLoomies(c *gin.Context) {
 	}
 
 	// Check the user owns the loomies
-	user, _ := models.GetUserById(userId.(string))
+	user, _ := repos.Users.GetUserById(userId.(string))
 	userMongoId, _ := primitive.ObjectIDFromHex(userId.(string))
 	firstLoomieMongoId, _ := primitive.ObjectIDFromHex(req.LoomieId1)
 	secondLoomieMongoId, _ := primitive.ObjectIDFromHex(req.LoomieId2)
@@ -241,7 +282,7 @@ func HandleFuseLoomies(c *gin.Context) {
 	}
 
 	// Check both loomies are of the same type
-	loomiesDocs, err := models.GetLoomiesByIds([]primitive.ObjectID{firstLoomieMongoId, secondLoomieMongoId}, userMongoId)
+	loomiesDocs, err := repos.Loomies.GetLoomiesByIds([]primitive.ObjectID{firstLoomieMongoId, secondLoomieMongoId}, userMongoId)
 
 	if err != nil || len(loomiesDocs) != 2 {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Error getting the loomies. Please try again later."})
@@ -268,6 +309,7 @@ func HandleFuseLoomies(c *gin.Context) {
 	var loomieToUpdate, loomieToDelete interfaces.UserLoomiesRes
 	var availableExperience float64
 	var minLvl int
+	balance := gameBalance.Get()
 	availableExperience = float64(loomiesDocs[0].Experience) + float64(loomiesDocs[1].Experience)
 
 	// The loomie with the highest level will be the one that will be updated
@@ -282,15 +324,15 @@ func HandleFuseLoomies(c *gin.Context) {
 	}
 
 	// Increment the available experience by 120% of the experience of the loomie with the lowest level
-	availableExperience += utils.GetRequiredExperience(minLvl) * 1.2
+	availableExperience += utils.GetRequiredExperience(minLvl, balance) * 1.2
 
 	// We reset the Loomie experience because that experience is already considered in the availableExperience variable
 	loomieToUpdate.Experience = 0
 	var experienceToAdd, neededExperienceToNextLevel float64
 
 	// Check if the loomie has leveled up
-	for (loomieToUpdate.Experience + availableExperience) >= utils.GetRequiredExperience(loomieToUpdate.Level+1) {
-		neededExperienceToNextLevel = utils.GetRequiredExperience(loomieToUpdate.Level + 1)
+	for (loomieToUpdate.Experience + availableExperience) >= utils.GetRequiredExperience(loomieToUpdate.Level+1, balance) {
+		neededExperienceToNextLevel = utils.GetRequiredExperience(loomieToUpdate.Level+1, balance)
 		experienceToAdd = math.Min(availableExperience, neededExperienceToNextLevel)
 		experienceToAdd = utils.FixeFloat(experienceToAdd, 4)
 		loomieToUpdate.Level++
@@ -305,16 +347,20 @@ func HandleFuseLoomies(c *gin.Context) {
 	loomieToUpdate.Hp = int(maxHp)
 	loomieToUpdate.Attack = int(maxAttack)
 	loomieToUpdate.Defense = int(maxDefense)
-	err = models.FuseLoomies(userMongoId, loomieToUpdate, loomieToDelete)
+	err = repos.Loomies.FuseLoomies(c, userMongoId, loomieToUpdate, loomieToDelete)
 
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Error fusing the loomies. Please try again later."})
 		return
 	}
 
+	trackQuest(c, userMongoId, repositories.QuestFuseEvent, "", nil)
+
 	c.IndentedJSON(http.StatusOK, gin.H{
-		"error":   false,
-		"message": "Loomies fused successfully",
+		"error":        false,
+		"message":      "Loomies fused successfully",
+		"trainer":      addTrainerExperience(c, userMongoId, config.Trainer.FuseExperience),
+		"achievements": trackAchievement(c, userMongoId, repositories.AchievementFusionsCounter),
 	})
 }
 
@@ -337,7 +383,7 @@ func HandleCaptureLoomie(c *gin.Context) {
 	}
 
 	//Check and get the wild loomie
-	loomie, err := models.GetWildLoomieById(loomie_req.LoomieId)
+	loomie, err := repos.Loomies.GetWildLoomieById(loomie_req.LoomieId)
 
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
@@ -356,7 +402,7 @@ func HandleCaptureLoomie(c *gin.Context) {
 	}, interfaces.Coordinates{
 		Latitude:  loomie_req.Latitude,
 		Longitude: loomie_req.Longitude,
-	})
+	}, config.Game)
 
 	if !isNear {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "User is not near the loomie"})
@@ -364,7 +410,7 @@ func HandleCaptureLoomie(c *gin.Context) {
 	}
 
 	//Check and get user
-	user, err := models.GetUserById(userid.(string))
+	user, err := repos.Users.GetUserById(userid.(string))
 
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
@@ -377,7 +423,7 @@ func HandleCaptureLoomie(c *gin.Context) {
 	}
 
 	//Check if user id alrady exists in array UsersAlreadyCapturedIt from wild loomie
-	insertUserInWildLoomie := models.CheckIfUserInArrayOfWildLoomie(loomie, user)
+	insertUserInWildLoomie := utils.CheckIfUserInArrayOfWildLoomie(loomie, user)
 
 	fmt.Println(insertUserInWildLoomie)
 
@@ -394,71 +440,46 @@ func HandleCaptureLoomie(c *gin.Context) {
 		return
 	}
 
-	//Remove the loomBall from inventory
-	err = models.DecrementItemFromUserInventory(user.Id, mongoid, 1)
-
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "The given loomball was not found"})
-		return
-	}
-
-	array_ball := []primitive.ObjectID{}
-
-	array_ball = append(array_ball, mongoid)
-
 	//Get loomBall
-	loomball, err := models.GetLoomballsFromIds(array_ball)
+	loomball, err := repos.Items.GetLoomballsFromIds([]primitive.ObjectID{mongoid})
 
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
 		return
 	}
 
-	//Check if the loomie was caught
-	was_captured := models.WasSuccessfulCapture(loomie, loomball[0])
-
-	if was_captured {
-		//Insert user id in array UsersAlreadyCapturedIt from wild loomie
-		err = models.InsertUserInArrayOfWildLoomie(loomie, user)
-
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "User already caught this loomie"})
-			return
-		}
+	if len(loomball) == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "The given loomball was not found"})
+		return
+	}
 
-		caught_loomies := interfaces.CaughtLoomie{Owner: user.Id,
-			IsBusy:     false,
-			Serial:     loomie.Serial,
-			Name:       loomie.Name,
-			Types:      loomie.Types,
-			Rarity:     loomie.Rarity,
-			HP:         loomie.HP,
-			Attack:     loomie.Attack,
-			Defense:    loomie.Defense,
-			Level:      loomie.Level,
-			Experience: loomie.Experience,
-		}
+	//Check if the loomie was caught before the transaction, so retries don't change the result
+	was_captured := utils.WasSuccessfulCapture(loomie, loomball[0], user.Level)
 
-		//Save the wild loomie in the caught roomie collection
-		caught_loomies_id, err := models.InsertInCaughtLoomies(caught_loomies)
+	//Remove the loomBall from inventory and save the wild loomie on the user
+	_, err = repos.Loomies.CaptureWildLoomie(c, user, loomie, mongoid, was_captured)
 
-		if err != nil {
+	if err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrLoomballNotFound):
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": err.Error()})
+		case errors.Is(err, repositories.ErrLoomieAlreadyCaught):
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": err.Error()})
+		default:
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
-			return
 		}
+		return
+	}
 
-		//Save the wild loomie on the user
-		err = models.AddToUserLoomies(user, caught_loomies_id)
-
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
-			return
-		}
+	if was_captured {
+		trackQuest(c, user.Id, repositories.QuestCaptureEvent, "", loomie.Types)
 
 		c.IndentedJSON(http.StatusOK, gin.H{
 			"error":        false,
 			"was_captured": was_captured,
 			"message":      "The loomie was captured",
+			"trainer":      addTrainerExperience(c, user.Id, config.Trainer.CaptureExperience),
+			"achievements": trackAchievement(c, user.Id, repositories.AchievementCapturesCounter),
 		})
 		return
 	}
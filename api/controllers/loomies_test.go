@@ -7,12 +7,9 @@ import (
 
 	"github.com/PedroChaparro/loomies-backend/interfaces"
 	"github.com/PedroChaparro/loomies-backend/middlewares"
-	"github.com/PedroChaparro/loomies-backend/models"
 	"github.com/PedroChaparro/loomies-backend/tests"
 	"github.com/stretchr/testify/require"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // TestGetNearLoomiesBadRequest Test the `/loomies/near` endpoint with bad request
@@ -30,23 +27,16 @@ func TestGetNearLoomiesBadRequest(t *testing.T) {
 	router.POST("/loomies/near", middlewares.MustProvideAccessToken(), HandleNearLoomies)
 
 	// Get a valid coordinates from some gym in the database
-	var gym interfaces.Gym
-	err := models.GymsCollection.FindOne(ctx, bson.M{}).Decode(&gym)
-	c.NoError(err)
+	gym := getTestGyms()[0]
 
 	// -------------------------
 	// 1. Test with bad user timeout
 	// -------------------------
 
 	// Update the user timeout to 1hr in the database
-	_, err = models.UserCollection.UpdateOne(ctx, bson.M{
-		"_id": randomUser.Id,
-	}, bson.M{
-		"$set": bson.M{
-			"currentLoomiesGenerationTimeout": 3600,
-		},
+	updateTestUser(randomUser.Id, func(user *interfaces.User) {
+		user.CurrentLoomiesGenerationTimeout = 3600
 	})
-	c.NoError(err)
 
 	// Use the gym coordinates to get the near loomies
 	w, req := tests.SetupPayloadedRequest("/loomies/near", "POST", map[string]interface{}{
@@ -87,7 +77,7 @@ func TestGetNearLoomiesBadRequest(t *testing.T) {
 	// 2. Test with non existing user
 	// -------------------------
 	// Remove the user
-	err = tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err := tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 
 	// Send the request
@@ -119,9 +109,7 @@ func TestGetNearLoomiesSuccess(t *testing.T) {
 	randomUser, loginResponse := loginWithRandomUser()
 
 	// Get a valid coordinates from some gym in the database
-	var gym interfaces.Gym
-	err := models.GymsCollection.FindOne(ctx, bson.M{}).Decode(&gym)
-	c.NoError(err)
+	gym := getTestGyms()[0]
 
 	// Setup the router
 	router := tests.SetupGinRouter()
@@ -147,7 +135,7 @@ func TestGetNearLoomiesSuccess(t *testing.T) {
 	c.Greater(len(response["loomies"].([]interface{})), 0)
 
 	// Remove the user
-	err = tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err := tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
 
@@ -161,11 +149,21 @@ func TestLoomieExistenceValidationSuccess(t *testing.T) {
 	// Login with a random user
 	randomUser, loginResponse := loginWithRandomUser()
 
-	// Get a valid loomie from the database
-	var loomie interfaces.WildLoomie
-	err := models.WildLoomiesCollection.FindOne(ctx, bson.M{}).Decode(&loomie)
+	// Insert a wild loomie near a gym
+	gym := getTestGyms()[0]
+	region, err := repos.Zones.GetRegionFromCoordinates(interfaces.Coordinates{Latitude: gym.Latitude, Longitude: gym.Longitude})
 	c.NoError(err)
 
+	loomie, inserted := repos.Loomies.InsertWildLoomie(region, interfaces.WildLoomie{
+		Serial:     2,
+		Name:       "Caterpillar",
+		Latitude:   gym.Latitude,
+		Longitude:  gym.Longitude,
+		Level:      1,
+		CapturedBy: []primitive.ObjectID{},
+	})
+	c.True(inserted)
+
 	// Setup the router
 	router := tests.SetupGinRouter()
 	router.GET("/loomies/exists/:id", middlewares.MustProvideAccessToken(), HandleValidateLoomieExists)
@@ -186,7 +184,7 @@ func TestLoomieExistenceValidationSuccess(t *testing.T) {
 	c.Equal(loomie.Id.Hex(), response["loomie_id"])
 
 	// Remove the user
-	err = tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err = tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
 
@@ -221,7 +219,7 @@ func TestLoomieExistenceNonSuccess(t *testing.T) {
 	c.Equal("Loomie doesn't exists", response["message"])
 
 	// Remove the user
-	err := tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err := tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
 
@@ -235,49 +233,33 @@ func TestLoomieCaptureSuccess(t *testing.T) {
 	// Login with a random user
 	randomUser, loginResponse := loginWithRandomUser()
 
-	// Get the loomballs from the database
+	// Get the loomballs sorted by serial
 	loomballSerials := []int{8, 9, 10}
 	var loomballs []interfaces.Loomball
 
-	// Get the loombals and sort them by serial
-	cursor, err := models.LoomballsCollection.Find(ctx, bson.M{
-		"serial": bson.M{
-			"$in": loomballSerials,
-		},
-	}, options.Find().SetSort(bson.M{
-		"serial": 1,
-	}))
-
-	c.NoError(err)
+	for _, serial := range loomballSerials {
+		for _, loomball := range testStore.Loomballs {
+			if loomball.Serial == serial {
+				loomballs = append(loomballs, loomball)
+			}
+		}
+	}
 
-	err = cursor.All(ctx, &loomballs)
-	c.NoError(err)
 	c.Equal(len(loomballs), len(loomballSerials))
 
 	// Set a lot of loomballs for the user
-	for _, loomball := range loomballs {
-		_, err := models.UserCollection.UpdateOne(ctx, bson.M{
-			"_id": randomUser.Id,
-		}, bson.M{
-			"$push": bson.M{
-				"items": bson.M{
-					"item_collection": "loom_balls",
-					"item_id":         loomball.Id,
-					"item_quantity":   3600,
-				},
-			},
-		},
-		)
-
-		c.NoError(err)
-	}
+	updateTestUser(randomUser.Id, func(user *interfaces.User) {
+		for _, loomball := range loomballs {
+			user.Items = append(user.Items, interfaces.InventoryItem{
+				ItemCollection: "loom_balls",
+				ItemId:         loomball.Id,
+				ItemQuantity:   3600,
+			})
+		}
+	})
 
 	// Get the user again from the database
-	var user interfaces.User
-
-	err = models.UserCollection.FindOne(ctx, bson.M{
-		"_id": randomUser.Id,
-	}).Decode(&user)
+	user, err := repos.Users.GetUserById(randomUser.Id.Hex())
 	c.NoError(err)
 
 	// Check have the expected amount of loomballs
@@ -292,9 +274,7 @@ func TestLoomieCaptureSuccess(t *testing.T) {
 	// 1. Capture loomie with "Basic" loomball
 	// -------------------------
 	// Get a valid coordinates from a gym in the database
-	var gym interfaces.Gym
-	err = models.GymsCollection.FindOne(ctx, bson.M{}).Decode(&gym)
-	c.NoError(err)
+	gym := getTestGyms()[0]
 
 	// Make the requests to generate, at least, 3 wild loomies
 	var loomiesStringIds []string
@@ -350,15 +330,13 @@ func TestLoomieCaptureSuccess(t *testing.T) {
 
 		if response["was_captured"].(bool) {
 			// Check the loomies is in the user loomies array
-			err = models.UserCollection.FindOne(ctx, bson.M{
-				"_id": randomUser.Id,
-			}).Decode(&user)
+			user, err = repos.Users.GetUserById(randomUser.Id.Hex())
 			c.NoError(err)
 			c.Equal(1, len(user.Loomies))
 
 			// Get the loomies details
 			var loomie interfaces.UserLoomiesRes
-			loomies, err := models.GetLoomiesByIds([]primitive.ObjectID{user.Loomies[0]}, user.Id)
+			loomies, err := repos.Loomies.GetLoomiesByIds([]primitive.ObjectID{user.Loomies[0]}, user.Id)
 			c.NoError(err)
 			loomie = loomies[0]
 
@@ -394,15 +372,13 @@ func TestLoomieCaptureSuccess(t *testing.T) {
 
 		if response["was_captured"].(bool) {
 			// Check the loomies is in the user loomies array
-			err = models.UserCollection.FindOne(ctx, bson.M{
-				"_id": randomUser.Id,
-			}).Decode(&user)
+			user, err = repos.Users.GetUserById(randomUser.Id.Hex())
 			c.NoError(err)
 			c.Equal(2, len(user.Loomies))
 
 			// Get the loomies details
 			var loomie interfaces.UserLoomiesRes
-			loomies, err := models.GetLoomiesByIds([]primitive.ObjectID{user.Loomies[1]}, user.Id)
+			loomies, err := repos.Loomies.GetLoomiesByIds([]primitive.ObjectID{user.Loomies[1]}, user.Id)
 			c.NoError(err)
 			loomie = loomies[0]
 
@@ -437,15 +413,13 @@ func TestLoomieCaptureSuccess(t *testing.T) {
 	c.Equal(true, response["was_captured"].(bool))
 
 	// Check the loomies is in the user loomies array
-	err = models.UserCollection.FindOne(ctx, bson.M{
-		"_id": randomUser.Id,
-	}).Decode(&user)
+	user, err = repos.Users.GetUserById(randomUser.Id.Hex())
 	c.NoError(err)
 	c.Equal(3, len(user.Loomies))
 
 	// Get the loomies details
 	var loomie interfaces.UserLoomiesRes
-	loomies, err := models.GetLoomiesByIds([]primitive.ObjectID{user.Loomies[2]}, user.Id)
+	loomies, err := repos.Loomies.GetLoomiesByIds([]primitive.ObjectID{user.Loomies[2]}, user.Id)
 	c.NoError(err)
 	loomie = loomies[0]
 
@@ -455,7 +429,7 @@ func TestLoomieCaptureSuccess(t *testing.T) {
 	c.Equal("The loomie was captured", response["message"])
 
 	// Remove the user
-	err = tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err = tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
 
@@ -469,18 +443,10 @@ func TestFuseLoomiesErrors(t *testing.T) {
 	// Login with a random user
 	randomUser, loginResponse := loginWithRandomUser()
 
-	// Get two random loomies from the database (Limit to 2 to avoid errors)
-	var loomie1, loomie2 interfaces.CaughtLoomie
-
-	err := models.CaughtLoomiesCollection.FindOne(ctx, bson.M{
-		"serial": 2,
-	}).Decode(&loomie1)
-	c.NoError(err)
-
-	err = models.CaughtLoomiesCollection.FindOne(ctx, bson.M{
-		"serial": 7,
-	}).Decode(&loomie2)
-	c.NoError(err)
+	// Insert two loomies of different types owned by another user
+	otherUser := primitive.NewObjectID()
+	loomie1 := insertTestLoomies(otherUser, 2, 1, false)[0]
+	loomie2 := insertTestLoomies(otherUser, 7, 1, false)[0]
 
 	// Setup the router
 	router := tests.SetupGinRouter()
@@ -518,8 +484,8 @@ func TestFuseLoomiesErrors(t *testing.T) {
 	// 3. Try to fuse the same loomie
 	// -------------------------
 	w, req = tests.SetupPayloadedRequest("/loomies/fuse", "POST", map[string]interface{}{
-		"loomie_id_1": loomie1.Id.Hex(),
-		"loomie_id_2": loomie1.Id.Hex(),
+		"loomie_id_1": loomie1.Hex(),
+		"loomie_id_2": loomie1.Hex(),
 	}, tests.CustomHeader{
 		Name:  "Access-Token",
 		Value: loginResponse["accessToken"],
@@ -535,8 +501,8 @@ func TestFuseLoomiesErrors(t *testing.T) {
 	// 4. Try to fuse loomies that the user doesn't own
 	// -------------------------
 	w, req = tests.SetupPayloadedRequest("/loomies/fuse", "POST", map[string]interface{}{
-		"loomie_id_1": loomie1.Id.Hex(),
-		"loomie_id_2": loomie2.Id.Hex(),
+		"loomie_id_1": loomie1.Hex(),
+		"loomie_id_2": loomie2.Hex(),
 	}, tests.CustomHeader{
 		Name:  "Access-Token",
 		Value: loginResponse["accessToken"],
@@ -551,39 +517,14 @@ func TestFuseLoomiesErrors(t *testing.T) {
 	// -------------------------
 	// 5. Try to fuse loomies that are not of the same type
 	// -------------------------
-	// Get another loomie if type 2 (To the next test)
-	var loomie3 interfaces.CaughtLoomie
-	err = models.CaughtLoomiesCollection.FindOne(ctx, bson.M{
-		"serial": 2,
-	}, options.FindOne().SetSkip(1)).Decode(&loomie3)
-	c.NoError(err)
-
-	// Update the loomies owner
-	_, err = models.CaughtLoomiesCollection.UpdateMany(ctx, bson.M{
-		"_id": bson.M{
-			"$in": []primitive.ObjectID{loomie1.Id, loomie2.Id, loomie3.Id},
-		},
-	}, bson.M{
-		"$set": bson.M{
-			"owner": randomUser.Id,
-		},
-	})
-	c.NoError(err)
-
-	// Add the loomies to the user array
-	_, err = models.UserCollection.UpdateOne(ctx, bson.M{
-		"_id": randomUser.Id,
-	}, bson.M{
-		"$push": bson.M{
-			"loomies": bson.M{
-				"$each": []primitive.ObjectID{loomie1.Id, loomie2.Id, loomie3.Id},
-			}},
-	})
-	c.NoError(err)
+	// Give the loomies and another loomie of type 2 (To the next test) to the user
+	loomie1 = insertTestLoomies(randomUser.Id, 2, 1, false)[0]
+	loomie2 = insertTestLoomies(randomUser.Id, 7, 1, false)[0]
+	loomie3 := insertTestLoomies(randomUser.Id, 2, 1, false)[0]
 
 	w, req = tests.SetupPayloadedRequest("/loomies/fuse", "POST", map[string]interface{}{
-		"loomie_id_1": loomie1.Id.Hex(),
-		"loomie_id_2": loomie2.Id.Hex(),
+		"loomie_id_1": loomie1.Hex(),
+		"loomie_id_2": loomie2.Hex(),
 	}, tests.CustomHeader{
 		Name:  "Access-Token",
 		Value: loginResponse["accessToken"],
@@ -598,20 +539,13 @@ func TestFuseLoomiesErrors(t *testing.T) {
 	// -------------------------
 	// 6. Try to fuse loomies that are busy
 	// -------------------------
-	_, err = models.CaughtLoomiesCollection.UpdateMany(ctx, bson.M{
-		"_id": bson.M{
-			"$in": []primitive.ObjectID{loomie1.Id, loomie3.Id},
-		},
-	}, bson.M{
-		"$set": bson.M{
-			"is_busy": true,
-		},
+	updateTestLoomies([]primitive.ObjectID{loomie1, loomie3}, func(loomie *interfaces.CaughtLoomie) {
+		loomie.IsBusy = true
 	})
-	c.NoError(err)
 
 	w, req = tests.SetupPayloadedRequest("/loomies/fuse", "POST", map[string]interface{}{
-		"loomie_id_1": loomie1.Id.Hex(),
-		"loomie_id_2": loomie3.Id.Hex(),
+		"loomie_id_1": loomie1.Hex(),
+		"loomie_id_2": loomie3.Hex(),
 	}, tests.CustomHeader{
 		Name:  "Access-Token",
 		Value: loginResponse["accessToken"],
@@ -624,7 +558,7 @@ func TestFuseLoomiesErrors(t *testing.T) {
 	c.Equal("Both loomies should not be busy", response["message"])
 
 	// Remove the user
-	err = tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err := tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
 
@@ -638,38 +572,8 @@ func TestFuseLoomiesSuccess(t *testing.T) {
 	// Login with a random user
 	randomUser, loginResponse := loginWithRandomUser()
 
-	// Get 2 loomies of the same type
-	var loomies []interfaces.CaughtLoomie
-	res, err := models.CaughtLoomiesCollection.Find(ctx, bson.M{
-		"serial": 2,
-	}, options.Find().SetLimit(2))
-
-	c.NoError(err)
-	c.NoError(res.All(ctx, &loomies))
-
-	// Update the loomies owner
-	_, err = models.CaughtLoomiesCollection.UpdateMany(ctx, bson.M{
-		"_id": bson.M{
-			"$in": []primitive.ObjectID{loomies[0].Id, loomies[1].Id},
-		},
-	}, bson.M{
-		"$set": bson.M{
-			"owner": randomUser.Id,
-		},
-	})
-	c.NoError(err)
-
-	// Add the loomies to the user array
-	_, err = models.UserCollection.UpdateOne(ctx, bson.M{
-		"_id": randomUser.Id,
-	}, bson.M{
-		"$push": bson.M{
-			"loomies": bson.M{
-				"$each": []primitive.ObjectID{loomies[0].Id, loomies[1].Id},
-			},
-		},
-	})
-	c.NoError(err)
+	// Give 2 loomies of the same type to the user
+	loomies := insertTestLoomies(randomUser.Id, 2, 2, false)
 
 	// Setup the router
 	router := tests.SetupGinRouter()
@@ -678,20 +582,9 @@ func TestFuseLoomiesSuccess(t *testing.T) {
 	// -------------------------
 	// 1. Fuse the loomies
 	// -------------------------
-	// Upddate the loomies state
-	_, err = models.CaughtLoomiesCollection.UpdateMany(ctx, bson.M{
-		"_id": bson.M{
-			"$in": []primitive.ObjectID{loomies[0].Id, loomies[1].Id},
-		},
-	}, bson.M{
-		"$set": bson.M{
-			"is_busy": false,
-		},
-	})
-
 	w, req := tests.SetupPayloadedRequest("/loomies/fuse", "POST", map[string]interface{}{
-		"loomie_id_1": loomies[0].Id.Hex(),
-		"loomie_id_2": loomies[1].Id.Hex(),
+		"loomie_id_1": loomies[0].Hex(),
+		"loomie_id_2": loomies[1].Hex(),
 	}, tests.CustomHeader{
 		Name:  "Access-Token",
 		Value: loginResponse["accessToken"],
@@ -704,6 +597,6 @@ func TestFuseLoomiesSuccess(t *testing.T) {
 	c.Equal("Loomies fused successfully", response["message"])
 
 	// Remove the user
-	err = tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err := tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
@@ -0,0 +1,448 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/PedroChaparro/loomies-backend/configuration"
+	"github.com/PedroChaparro/loomies-backend/email"
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/middlewares"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"github.com/PedroChaparro/loomies-backend/repositories/memory"
+	"github.com/PedroChaparro/loomies-backend/world"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Folder with the game content and the regions inserted by the bulk script
+const testDataFolder = "../../data"
+
+// Settings required by the configuration, the test values are used when they aren't set in the environment
+var testEnvironment = map[string]string{
+	"MONGO_USER":           "root",
+	"MONGO_PASSWORD":       "development",
+	"MONGO_HOSTS":          "localhost:27017",
+	"MONGO_DATABASE":       "loomies",
+	"ACCESS_TOKEN_SECRET":  "access",
+	"REFRESH_TOKEN_SECRET": "refresh",
+	"WS_TOKEN_SECRET":      "ws",
+	"MFA_TOKEN_SECRET":     "mfa",
+	"EMAIL_DRIVER":         "outbox",
+}
+
+// testStore contains the documents of the in-memory repositories used by the handlers under test, the tests read and
+// change it directly to prepare the scenarios
+var testStore *memory.Store
+
+// TestMain seeds the in-memory repositories with the game content and injects them into the handlers and the
+// middlewares under test, so the tests don't need a database
+func TestMain(m *testing.M) {
+	for key, value := range testEnvironment {
+		if _, ok := os.LookupEnv(key); !ok {
+			os.Setenv(key, value)
+		}
+	}
+
+	settings, err := configuration.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	outbox, err := os.MkdirTemp("", "loomies-outbox")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	settings.Email.OutboxDir = outbox
+	balance := configuration.NewLiveBalance(settings.Game.Balance())
+	SetConfiguration(settings, balance)
+	SetMailer(email.NewMailerFromSettings(settings.Email))
+	middlewares.SetConfiguration(settings)
+
+	var testRepos repositories.Repositories
+	testRepos, testStore = memory.NewRepositories(settings, balance)
+	if err := seedTestStore(testStore); err != nil {
+		log.Fatal(err)
+	}
+
+	SetRepositories(testRepos)
+	middlewares.SetRepositories(testRepos)
+
+	code := m.Run()
+	os.RemoveAll(outbox)
+	os.Exit(code)
+}
+
+// ## Seed
+
+// testBaseLoomie is a base loomie of the data files, the types and rarity are referenced by name
+type testBaseLoomie struct {
+	Serial   int      `json:"serial"`
+	Name     string   `json:"name"`
+	Types    []string `json:"types"`
+	Rarity   string   `json:"rarity"`
+	ExtraHp  int      `json:"extra_hp"`
+	ExtraDef int      `json:"extra_def"`
+	ExtraAtk int      `json:"extra_atk"`
+}
+
+// testPlace is a static place of a region, every static place has a gym
+type testPlace struct {
+	Name      string  `json:"name"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// readTestData "private" function to decode the given file of the data folder
+func readTestData(name string, results interface{}) error {
+	data, err := os.ReadFile(filepath.Join(testDataFolder, name+".json"))
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, results)
+}
+
+// seedTestStore "private" function to insert the game content, the regions zones and the gyms of the static places
+// like the bulk script does
+func seedTestStore(store *memory.Store) error {
+	// Loomies types and rarities
+	var types []struct {
+		Name          string   `json:"name"`
+		StrongAgainst []string `json:"strong_against"`
+	}
+
+	if err := readTestData("loomies_types", &types); err != nil {
+		return err
+	}
+
+	typesIds := map[string]primitive.ObjectID{}
+	for _, loomieType := range types {
+		typesIds[loomieType.Name] = primitive.NewObjectID()
+	}
+
+	for _, loomieType := range types {
+		strongAgainst := []primitive.ObjectID{}
+		for _, name := range loomieType.StrongAgainst {
+			strongAgainst = append(strongAgainst, typesIds[name])
+		}
+
+		id := typesIds[loomieType.Name]
+		store.LoomieTypes[id] = interfaces.LoomieType{Id: id, Name: loomieType.Name, StrongAgainst: strongAgainst}
+	}
+
+	var rarities []interfaces.LoomieRarity
+	if err := readTestData("loomies_rarities", &rarities); err != nil {
+		return err
+	}
+
+	raritiesIds := map[string]primitive.ObjectID{}
+	for _, rarity := range rarities {
+		rarity.Id = primitive.NewObjectID()
+		raritiesIds[rarity.Name] = rarity.Id
+		store.LoomieRarities[rarity.Id] = rarity
+	}
+
+	// Base loomies, the common ones protect the gyms
+	var baseLoomies []testBaseLoomie
+	if err := readTestData("loomies", &baseLoomies); err != nil {
+		return err
+	}
+
+	commonLoomies := []interfaces.BaseLoomies{}
+	for _, loomie := range baseLoomies {
+		loomieTypes := []primitive.ObjectID{}
+		for _, name := range loomie.Types {
+			loomieTypes = append(loomieTypes, typesIds[name])
+		}
+
+		base := interfaces.BaseLoomies{
+			Id:          primitive.NewObjectID(),
+			Serial:      loomie.Serial,
+			Name:        loomie.Name,
+			Types:       loomieTypes,
+			Rarity:      raritiesIds[loomie.Rarity],
+			BaseHp:      100 + loomie.ExtraHp,
+			BaseAttack:  20 + loomie.ExtraAtk,
+			BaseDefense: 10 + loomie.ExtraDef,
+		}
+
+		store.BaseLoomies[base.Id] = base
+		if loomie.Rarity == "Common" {
+			commonLoomies = append(commonLoomies, base)
+		}
+	}
+
+	// Items and loomballs
+	var items []interfaces.Item
+	if err := readTestData("items", &items); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		item.Id = primitive.NewObjectID()
+		store.Items[item.Id] = item
+	}
+
+	var loomballs []interfaces.Loomball
+	if err := readTestData("loomballs", &loomballs); err != nil {
+		return err
+	}
+
+	for _, loomball := range loomballs {
+		loomball.Id = primitive.NewObjectID()
+		store.Loomballs[loomball.Id] = loomball
+	}
+
+	// Achievements, quests and gifts
+	if err := readTestData("achievements", &store.Achievements); err != nil {
+		return err
+	}
+
+	for i := range store.Achievements {
+		store.Achievements[i].Id = primitive.NewObjectID()
+	}
+
+	if err := readTestData("quest_templates", &store.QuestTemplates); err != nil {
+		return err
+	}
+
+	for i := range store.QuestTemplates {
+		store.QuestTemplates[i].Id = primitive.NewObjectID()
+	}
+
+	if err := readTestData("gift_table", &store.GiftTable); err != nil {
+		return err
+	}
+
+	for i := range store.GiftTable {
+		store.GiftTable[i].Id = primitive.NewObjectID()
+	}
+
+	// Regions with their zones and the gyms of the static places
+	var regions []interfaces.Region
+	if err := readTestData("regions", &regions); err != nil {
+		return err
+	}
+
+	random := rand.New(rand.NewSource(1))
+	for _, region := range regions {
+		region.Id = primitive.NewObjectID()
+		store.Regions[region.Id] = region
+
+		for _, cell := range world.Generate(region, nil, random) {
+			cell.Zone.Id = primitive.NewObjectID()
+			cell.Zone.Loomies = []primitive.ObjectID{}
+			store.Zones[cell.Zone.Id] = cell.Zone
+		}
+
+		var places []testPlace
+		if err := readTestData("static_places", &places); err != nil {
+			return err
+		}
+
+		for i, place := range places {
+			seedTestGym(store, place, commonLoomies, i)
+		}
+	}
+
+	return nil
+}
+
+// seedTestGym "private" function to insert a gym without owner, protected by six common loomies and with rewards for
+// the players and the owners
+func seedTestGym(store *memory.Store, place testPlace, commonLoomies []interfaces.BaseLoomies, index int) {
+	gym := interfaces.Gym{
+		Id:                    primitive.NewObjectID(),
+		Name:                  place.Name,
+		Latitude:              place.Latitude,
+		Longitude:             place.Longitude,
+		Location:              interfaces.Coordinates{Latitude: place.Latitude, Longitude: place.Longitude}.ToGeoPoint(),
+		Protectors:            []primitive.ObjectID{},
+		CurrentPlayersRewards: []interfaces.GymRewardItem{},
+		CurrentOwnerRewards:   []interfaces.GymRewardItem{},
+		RewardsClaimedBy:      []primitive.ObjectID{},
+	}
+
+	for i := 0; i < 6; i++ {
+		base := commonLoomies[(index+i)%len(commonLoomies)]
+		protector := interfaces.CaughtLoomie{
+			Id:      primitive.NewObjectID(),
+			IsBusy:  true,
+			Serial:  base.Serial,
+			Name:    base.Name,
+			Types:   base.Types,
+			Rarity:  base.Rarity,
+			HP:      base.BaseHp,
+			Attack:  base.BaseAttack,
+			Defense: base.BaseDefense,
+			Level:   14 + i,
+		}
+
+		store.CaughtLoomies[protector.Id] = protector
+		gym.Protectors = append(gym.Protectors, protector.Id)
+	}
+
+	for _, item := range store.Items {
+		if item.GymRewardChancePlayer >= 0.5 {
+			gym.CurrentPlayersRewards = append(gym.CurrentPlayersRewards, interfaces.GymRewardItem{
+				RewardCollection: repositories.ItemsContent,
+				RewardId:         item.Id,
+				RewardQuantity:   item.MinRewardQuantity,
+			})
+		}
+	}
+
+	for _, loomball := range store.Loomballs {
+		if loomball.GymRewardChanceOwner >= 0.5 {
+			gym.CurrentOwnerRewards = append(gym.CurrentOwnerRewards, interfaces.GymRewardItem{
+				RewardCollection: repositories.LoomballsContent,
+				RewardId:         loomball.Id,
+				RewardQuantity:   loomball.MaxRewardQuantity,
+			})
+		}
+	}
+
+	store.Gyms[gym.Id] = gym
+}
+
+// ## Helper functions
+
+// getTestGyms returns the gyms of the store sorted by name
+func getTestGyms() []interfaces.Gym {
+	gyms := []interfaces.Gym{}
+	for _, gym := range testStore.Gyms {
+		gyms = append(gyms, gym)
+	}
+
+	sort.Slice(gyms, func(i, j int) bool {
+		return gyms[i].Name < gyms[j].Name
+	})
+
+	return gyms
+}
+
+// getTestLoomiesBySerial returns the caught loomies with the given serial sorted by id
+func getTestLoomiesBySerial(serial int) []interfaces.CaughtLoomie {
+	loomies := []interfaces.CaughtLoomie{}
+	for _, loomie := range testStore.CaughtLoomies {
+		if loomie.Serial == serial {
+			loomies = append(loomies, loomie)
+		}
+	}
+
+	sort.Slice(loomies, func(i, j int) bool {
+		return loomies[i].Id.Hex() < loomies[j].Id.Hex()
+	})
+
+	return loomies
+}
+
+// verifyTestUser verifies the account of the user with the given email and returns the updated user
+func verifyTestUser(email string) interfaces.User {
+	user, _ := repos.Users.GetUserByEmail(email)
+	updateTestUser(user.Id, func(user *interfaces.User) {
+		user.IsVerified = true
+	})
+
+	return testStore.Users[user.Id]
+}
+
+// getTestAuthenticationCode returns the last authentication code of the given type sent to the email
+func getTestAuthenticationCode(email string, codeType string) (interfaces.AuthenticationCode, error) {
+	for i := len(testStore.AuthenticationCodes) - 1; i >= 0; i-- {
+		code := testStore.AuthenticationCodes[i]
+		if code.Email == email && code.Type == codeType {
+			return code, nil
+		}
+	}
+
+	return interfaces.AuthenticationCode{}, mongo.ErrNoDocuments
+}
+
+// getTestItemBySerial returns the item with the given serial
+func getTestItemBySerial(serial int) interfaces.Item {
+	for _, item := range testStore.Items {
+		if item.Serial == serial {
+			return item
+		}
+	}
+
+	return interfaces.Item{}
+}
+
+// addTestInventoryItem adds the item to the inventory of the user
+func addTestInventoryItem(userId primitive.ObjectID, collection string, itemId primitive.ObjectID, quantity int) {
+	updateTestUser(userId, func(user *interfaces.User) {
+		user.Items = append(append([]interfaces.InventoryItem{}, user.Items...), interfaces.InventoryItem{
+			ItemCollection: collection,
+			ItemId:         itemId,
+			ItemQuantity:   quantity,
+		})
+	})
+}
+
+// updateTestUser applies the update to the user with the given id
+func updateTestUser(id primitive.ObjectID, update func(user *interfaces.User)) {
+	user := testStore.Users[id]
+	update(&user)
+	testStore.Users[id] = user
+}
+
+// updateTestGym applies the update to the gym with the given id
+func updateTestGym(id primitive.ObjectID, update func(gym *interfaces.Gym)) {
+	gym := testStore.Gyms[id]
+	update(&gym)
+	testStore.Gyms[id] = gym
+}
+
+// updateTestLoomies applies the update to the caught loomies with the given ids
+func updateTestLoomies(ids []primitive.ObjectID, update func(loomie *interfaces.CaughtLoomie)) {
+	for _, id := range ids {
+		loomie := testStore.CaughtLoomies[id]
+		update(&loomie)
+		testStore.CaughtLoomies[id] = loomie
+	}
+}
+
+// insertTestLoomies inserts loomies of the base loomie with the given serial and gives them to the user
+func insertTestLoomies(userId primitive.ObjectID, serial int, amount int, isBusy bool) []primitive.ObjectID {
+	var base interfaces.BaseLoomies
+	for _, current := range testStore.BaseLoomies {
+		if current.Serial == serial {
+			base = current
+		}
+	}
+
+	ids := []primitive.ObjectID{}
+	for i := 0; i < amount; i++ {
+		loomie := interfaces.CaughtLoomie{
+			Id:      primitive.NewObjectID(),
+			Owner:   userId,
+			IsBusy:  isBusy,
+			Serial:  base.Serial,
+			Name:    base.Name,
+			Types:   base.Types,
+			Rarity:  base.Rarity,
+			HP:      base.BaseHp,
+			Attack:  base.BaseAttack,
+			Defense: base.BaseDefense,
+			Level:   1,
+		}
+
+		testStore.CaughtLoomies[loomie.Id] = loomie
+		ids = append(ids, loomie.Id)
+	}
+
+	updateTestUser(userId, func(user *interfaces.User) {
+		user.Loomies = append(append([]primitive.ObjectID{}, user.Loomies...), ids...)
+	})
+
+	return ids
+}
@@ -0,0 +1,313 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Amount of one-time recovery codes generated when the authenticator is confirmed
+const mfaRecoveryCodesAmount = 10
+
+// Invalid codes accepted for a mfa token or a pending authenticator before it's invalidated, and for the sensitive
+// actions before they are locked
+const mfaMaxFailedAttempts = 5
+
+// Time the sensitive actions confirmed with a code are locked after too many invalid codes
+const mfaLockoutTime = 15 * time.Minute
+
+// verifyMfaCode "private" function to check the given TOTP or recovery code and mark it as used
+func verifyMfaCode(c *gin.Context, user interfaces.User, code string) (bool, error) {
+	step, ok := utils.ValidateTOTPCode(user.Mfa.Secret, code, user.Mfa.LastUsedStep)
+
+	if ok {
+		return repos.Mfa.UseUserMfaStep(user.Id, step)
+	}
+
+	return repos.Mfa.UseUserMfaRecoveryCode(c, user.Id, utils.HashRecoveryCode(code))
+}
+
+// verifyMfaActionCode "private" function to check the code that confirms a sensitive action (Eg. disabling the
+// authenticator) and abort the request if it's not valid. The actions are locked after too many invalid codes
+func verifyMfaActionCode(c *gin.Context, user interfaces.User, code string) bool {
+	if user.Mfa.LockedUntil > time.Now().Unix() {
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": true, "message": "Too many invalid codes. Please try again later"})
+		return false
+	}
+
+	valid, err := verifyMfaCode(c, user, code)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return false
+	}
+
+	if valid {
+		return true
+	}
+
+	locked, err := repos.Mfa.RecordUserMfaActionFailure(c, user.Id, mfaMaxFailedAttempts, time.Now().Add(mfaLockoutTime).Unix())
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return false
+	}
+
+	if locked {
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": true, "message": "Too many invalid codes. Please try again later"})
+		return false
+	}
+
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": true, "message": "Invalid code"})
+	return false
+}
+
+// getSessionUser "private" function to get the user from the access token and abort the request if it fails
+func getSessionUser(c *gin.Context) (interfaces.User, bool) {
+	userid, _ := c.Get("userid")
+	user, err := repos.Users.GetUserById(userid.(string))
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "User was not found"})
+			return user, false
+		}
+
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return user, false
+	}
+
+	return user, true
+}
+
+// HandleMfaEnroll Handle the request to create a new (pending) authenticator for the user
+func HandleMfaEnroll(c *gin.Context) {
+	user, ok := getSessionUser(c)
+	if !ok {
+		return
+	}
+
+	if user.Mfa.Enabled {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret := utils.GenerateTOTPSecret()
+	err := repos.Mfa.SetUserMfaPendingSecret(user.Id, secret)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":       false,
+		"message":     "Authenticator was created successfully. Confirm it with a code to enable the two-factor authentication",
+		"secret":      secret,
+		"otpauth_uri": utils.GetTOTPUri(secret, user.Email),
+	})
+}
+
+// HandleMfaConfirm Handle the request to enable the two-factor authentication with a code of the pending authenticator
+func HandleMfaConfirm(c *gin.Context) {
+	var form interfaces.MfaCodeReq
+
+	if err := c.BindJSON(&form); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Bad request"})
+		return
+	}
+
+	if form.Code == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Code cannot be empty"})
+		return
+	}
+
+	user, ok := getSessionUser(c)
+	if !ok {
+		return
+	}
+
+	if user.Mfa.Enabled {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	if user.Mfa.PendingSecret == "" {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "There is not a pending authenticator"})
+		return
+	}
+
+	step, ok := utils.ValidateTOTPCode(user.Mfa.PendingSecret, form.Code, 0)
+	if !ok {
+		removed, err := repos.Mfa.RecordUserMfaPendingFailure(c, user.Id, user.Mfa.PendingSecret, mfaMaxFailedAttempts)
+
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+			return
+		}
+
+		if removed {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": true, "message": "Too many invalid codes. Please create a new authenticator"})
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": true, "message": "Invalid code"})
+		return
+	}
+
+	// Only the hashes of the recovery codes are stored
+	recoveryCodes := utils.GenerateRecoveryCodes(mfaRecoveryCodesAmount)
+	recoveryCodesHashes := make([]string, len(recoveryCodes))
+
+	for i, code := range recoveryCodes {
+		recoveryCodesHashes[i] = utils.HashRecoveryCode(code)
+	}
+
+	err := repos.Mfa.EnableUserMfa(c, user.Id, user.Mfa.PendingSecret, recoveryCodesHashes, step)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":          false,
+		"message":        "Two-factor authentication was enabled successfully",
+		"recovery_codes": recoveryCodes,
+	})
+}
+
+// HandleMfaDisable Handle the request to disable the two-factor authentication with a TOTP or recovery code
+func HandleMfaDisable(c *gin.Context) {
+	var form interfaces.MfaCodeReq
+
+	if err := c.BindJSON(&form); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Bad request"})
+		return
+	}
+
+	if form.Code == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Code cannot be empty"})
+		return
+	}
+
+	user, ok := getSessionUser(c)
+	if !ok {
+		return
+	}
+
+	if !user.Mfa.Enabled {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	if !verifyMfaActionCode(c, user, form.Code) {
+		return
+	}
+
+	err := repos.Mfa.DisableUserMfa(c, user.Id)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Two-factor authentication was disabled successfully",
+	})
+}
+
+// HandleMfaLogIn Handle the request to exchange the mfa challenge token and a TOTP or recovery code for the session tokens
+func HandleMfaLogIn(c *gin.Context) {
+	var form interfaces.MfaLogInReq
+
+	if err := c.BindJSON(&form); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Bad request"})
+		return
+	}
+
+	if form.MfaToken == "" || form.Code == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Mfa token and code are required"})
+		return
+	}
+
+	userid, challengeId, err := utils.ValidateMfaToken(config.Tokens, form.MfaToken)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": true, "message": err.Error()})
+		return
+	}
+
+	user, err := repos.Users.GetUserById(userid)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "User was not found"})
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	// The user could be banned after the challenge was created
+	if abortIfBanned(c, user) {
+		return
+	}
+
+	// The two-factor authentication could be disabled after the challenge was created
+	if !user.Mfa.Enabled {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	// The token was used, invalidated after too many invalid codes or replaced by a newer login
+	if challengeId == "" || challengeId != user.Mfa.ChallengeId {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": true, "message": "Mfa token is no longer valid. Please log in again"})
+		return
+	}
+
+	valid, err := verifyMfaCode(c, user, form.Code)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	if !valid {
+		invalidated, err := repos.Mfa.RecordUserMfaChallengeFailure(c, user.Id, challengeId, mfaMaxFailedAttempts)
+
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+			return
+		}
+
+		if invalidated {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": true, "message": "Too many invalid codes. Please log in again"})
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": true, "message": "Invalid code"})
+		return
+	}
+
+	// The token can't be used again, even by a concurrent request with other valid code
+	ended, err := repos.Mfa.EndUserMfaChallenge(user.Id, challengeId)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	if !ended {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": true, "message": "Mfa token is no longer valid. Please log in again"})
+		return
+	}
+
+	issueUserSession(c, user)
+}
@@ -0,0 +1,267 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/middlewares"
+	"github.com/PedroChaparro/loomies-backend/tests"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+// ## Helper functions
+// setupMfaRouter creates a router with the two-factor authentication endpoints
+func setupMfaRouter() *gin.Engine {
+	router := tests.SetupGinRouter()
+	router.POST("/session/login", HandleLogIn)
+	router.POST("/session/mfa", HandleMfaLogIn)
+	router.POST("/user/mfa/enroll", middlewares.MustProvideAccessToken(), HandleMfaEnroll)
+	router.POST("/user/mfa/confirm", middlewares.MustProvideAccessToken(), HandleMfaConfirm)
+	router.POST("/user/mfa/disable", middlewares.MustProvideAccessToken(), HandleMfaDisable)
+	return router
+}
+
+// createVerifiedUser inserts a random verified user and returns it with its plain password
+func createVerifiedUser(router *gin.Engine) (interfaces.User, string) {
+	randomUser := tests.GenerateRandomUser()
+	tests.InsertUser(randomUser, tests.SetupGinRouter(), HandleSignUp)
+
+	return verifyTestUser(randomUser.Email), randomUser.Password
+}
+
+// postMfaRequest sends a POST request to the given endpoint and returns the status code and the parsed response
+func postMfaRequest(router *gin.Engine, endpoint string, payload interface{}, headers ...tests.CustomHeader) (int, map[string]interface{}) {
+	var response map[string]interface{}
+	w, req := tests.SetupPayloadedRequest(endpoint, "POST", payload, headers...)
+	router.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response
+}
+
+// enableMfa enrolls and confirms an authenticator for the user and returns the secret and recovery codes
+func enableMfa(c *require.Assertions, router *gin.Engine, accessToken string) (string, []interface{}) {
+	header := tests.CustomHeader{Name: "Access-Token", Value: accessToken}
+
+	code, response := postMfaRequest(router, "/user/mfa/enroll", nil, header)
+	c.Equal(http.StatusOK, code)
+	c.Contains(response["otpauth_uri"], "otpauth://totp/Loomies:")
+	secret := response["secret"].(string)
+
+	totp, _ := utils.GetTOTPCode(secret, utils.GetTOTPStep(time.Now()))
+	code, response = postMfaRequest(router, "/user/mfa/confirm", map[string]string{"code": totp}, header)
+	c.Equal(http.StatusOK, code)
+	c.Equal("Two-factor authentication was enabled successfully", response["message"])
+
+	return secret, response["recovery_codes"].([]interface{})
+}
+
+// ## Tests
+
+// TestMfaLogIn tests the login returns a challenge that can be exchanged with a TOTP code only once
+func TestMfaLogIn(t *testing.T) {
+	c := require.New(t)
+	router := setupMfaRouter()
+	user, password := createVerifiedUser(router)
+	credentials := map[string]string{"email": user.Email, "password": password}
+
+	// 1. Login without two-factor authentication and enable it
+	code, response := postMfaRequest(router, "/session/login", credentials)
+	c.Equal(http.StatusOK, code)
+	secret, recoveryCodes := enableMfa(c, router, response["accessToken"].(string))
+	c.Equal(10, len(recoveryCodes))
+
+	// 2. Login returns the challenge instead of the tokens
+	code, response = postMfaRequest(router, "/session/login", credentials)
+	c.Equal(http.StatusOK, code)
+	c.Equal("mfa_required", response["status"])
+	c.Nil(response["accessToken"])
+	mfaToken := response["mfaToken"].(string)
+
+	// 3. Wrong codes are rejected
+	code, response = postMfaRequest(router, "/session/mfa", map[string]string{"mfaToken": mfaToken, "code": "000000"})
+	c.Equal(http.StatusUnauthorized, code)
+	c.Equal("Invalid code", response["message"])
+
+	// 4. The code of the next step is valid (the current one was used to confirm)
+	totp, _ := utils.GetTOTPCode(secret, utils.GetTOTPStep(time.Now())+1)
+	code, response = postMfaRequest(router, "/session/mfa", map[string]string{"mfaToken": mfaToken, "code": totp})
+	c.Equal(http.StatusOK, code)
+	c.Equal("Successfully logged in", response["message"])
+	c.NotEmpty(response["accessToken"])
+	c.NotEmpty(response["refreshToken"])
+
+	// 5. The same code can't be used again
+	code, _ = postMfaRequest(router, "/session/mfa", map[string]string{"mfaToken": mfaToken, "code": totp})
+	c.Equal(http.StatusUnauthorized, code)
+
+	// 6. Invalid challenge tokens are rejected
+	code, _ = postMfaRequest(router, "/session/mfa", map[string]string{"mfaToken": response["accessToken"].(string), "code": totp})
+	c.Equal(http.StatusUnauthorized, code)
+
+	err := tests.DeleteUser(repos, user.Email)
+	c.NoError(err)
+}
+
+// TestMfaRecoveryCodes tests the recovery codes can be used only once and to disable the two-factor authentication
+func TestMfaRecoveryCodes(t *testing.T) {
+	c := require.New(t)
+	router := setupMfaRouter()
+	user, password := createVerifiedUser(router)
+	credentials := map[string]string{"email": user.Email, "password": password}
+
+	_, response := postMfaRequest(router, "/session/login", credentials)
+	_, recoveryCodes := enableMfa(c, router, response["accessToken"].(string))
+
+	// 1. Login with a recovery code
+	_, response = postMfaRequest(router, "/session/login", credentials)
+	mfaToken := response["mfaToken"].(string)
+	code, response := postMfaRequest(router, "/session/mfa", map[string]string{"mfaToken": mfaToken, "code": recoveryCodes[0].(string)})
+	c.Equal(http.StatusOK, code)
+	accessToken := response["accessToken"].(string)
+
+	// 2. The recovery code can't be used again
+	code, _ = postMfaRequest(router, "/session/mfa", map[string]string{"mfaToken": mfaToken, "code": recoveryCodes[0].(string)})
+	c.Equal(http.StatusUnauthorized, code)
+
+	// 3. Disable the two-factor authentication with other recovery code
+	header := tests.CustomHeader{Name: "Access-Token", Value: accessToken}
+	code, response = postMfaRequest(router, "/user/mfa/disable", map[string]string{"code": recoveryCodes[1].(string)}, header)
+	c.Equal(http.StatusOK, code)
+	c.Equal("Two-factor authentication was disabled successfully", response["message"])
+
+	// 4. Login returns the tokens again
+	_, response = postMfaRequest(router, "/session/login", credentials)
+	c.NotEmpty(response["accessToken"])
+
+	err := tests.DeleteUser(repos, user.Email)
+	c.NoError(err)
+}
+
+// TestMfaFailedAttempts tests the mfa tokens and the pending authenticators are invalidated after too many invalid codes
+func TestMfaFailedAttempts(t *testing.T) {
+	c := require.New(t)
+	router := setupMfaRouter()
+	user, password := createVerifiedUser(router)
+	credentials := map[string]string{"email": user.Email, "password": password}
+
+	_, response := postMfaRequest(router, "/session/login", credentials)
+	header := tests.CustomHeader{Name: "Access-Token", Value: response["accessToken"].(string)}
+
+	// 1. The pending authenticator is removed after too many invalid codes
+	code, response := postMfaRequest(router, "/user/mfa/enroll", nil, header)
+	c.Equal(http.StatusOK, code)
+	secret := response["secret"].(string)
+
+	for i := 1; i < mfaMaxFailedAttempts; i++ {
+		code, response = postMfaRequest(router, "/user/mfa/confirm", map[string]string{"code": "000000"}, header)
+		c.Equal(http.StatusUnauthorized, code)
+		c.Equal("Invalid code", response["message"])
+	}
+
+	code, response = postMfaRequest(router, "/user/mfa/confirm", map[string]string{"code": "000000"}, header)
+	c.Equal(http.StatusUnauthorized, code)
+	c.Equal("Too many invalid codes. Please create a new authenticator", response["message"])
+
+	totp, _ := utils.GetTOTPCode(secret, utils.GetTOTPStep(time.Now()))
+	code, _ = postMfaRequest(router, "/user/mfa/confirm", map[string]string{"code": totp}, header)
+	c.Equal(http.StatusNotFound, code)
+
+	// 2. The mfa token is invalidated after too many invalid codes
+	secret, _ = enableMfa(c, router, header.Value)
+	_, response = postMfaRequest(router, "/session/login", credentials)
+	mfaToken := response["mfaToken"].(string)
+
+	for i := 1; i < mfaMaxFailedAttempts; i++ {
+		code, response = postMfaRequest(router, "/session/mfa", map[string]string{"mfaToken": mfaToken, "code": "000000"})
+		c.Equal(http.StatusUnauthorized, code)
+		c.Equal("Invalid code", response["message"])
+	}
+
+	code, response = postMfaRequest(router, "/session/mfa", map[string]string{"mfaToken": mfaToken, "code": "000000"})
+	c.Equal(http.StatusUnauthorized, code)
+	c.Equal("Too many invalid codes. Please log in again", response["message"])
+
+	totp, _ = utils.GetTOTPCode(secret, utils.GetTOTPStep(time.Now())+1)
+	code, response = postMfaRequest(router, "/session/mfa", map[string]string{"mfaToken": mfaToken, "code": totp})
+	c.Equal(http.StatusUnauthorized, code)
+	c.Equal("Mfa token is no longer valid. Please log in again", response["message"])
+
+	// 3. A new login gives a new token, the previous ones are replaced
+	_, response = postMfaRequest(router, "/session/login", credentials)
+	firstToken := response["mfaToken"].(string)
+	_, response = postMfaRequest(router, "/session/login", credentials)
+
+	code, _ = postMfaRequest(router, "/session/mfa", map[string]string{"mfaToken": firstToken, "code": totp})
+	c.Equal(http.StatusUnauthorized, code)
+
+	code, _ = postMfaRequest(router, "/session/mfa", map[string]string{"mfaToken": response["mfaToken"].(string), "code": totp})
+	c.Equal(http.StatusOK, code)
+
+	err := tests.DeleteUser(repos, user.Email)
+	c.NoError(err)
+}
+
+// TestMfaActionsLockout tests the code confirmed actions are locked after too many invalid codes
+func TestMfaActionsLockout(t *testing.T) {
+	c := require.New(t)
+	router := setupMfaRouter()
+	user, password := createVerifiedUser(router)
+
+	_, response := postMfaRequest(router, "/session/login", map[string]string{"email": user.Email, "password": password})
+	header := tests.CustomHeader{Name: "Access-Token", Value: response["accessToken"].(string)}
+	secret, _ := enableMfa(c, router, header.Value)
+
+	// 1. The invalid codes are counted until the actions are locked
+	for i := 1; i < mfaMaxFailedAttempts; i++ {
+		code, response := postMfaRequest(router, "/user/mfa/disable", map[string]string{"code": "000000"}, header)
+		c.Equal(http.StatusUnauthorized, code)
+		c.Equal("Invalid code", response["message"])
+	}
+
+	code, response := postMfaRequest(router, "/user/mfa/disable", map[string]string{"code": "000000"}, header)
+	c.Equal(http.StatusTooManyRequests, code)
+	c.Equal("Too many invalid codes. Please try again later", response["message"])
+
+	// 2. Even the valid codes are rejected while the actions are locked
+	totp, _ := utils.GetTOTPCode(secret, utils.GetTOTPStep(time.Now())+1)
+	code, _ = postMfaRequest(router, "/user/mfa/disable", map[string]string{"code": totp}, header)
+	c.Equal(http.StatusTooManyRequests, code)
+
+	updated, err := repos.Users.GetUserById(user.Id.Hex())
+	c.NoError(err)
+	c.True(updated.Mfa.Enabled)
+
+	err = tests.DeleteUser(repos, user.Email)
+	c.NoError(err)
+}
+
+// TestMfaLogInBannedUser tests the users banned after the password step don't get the session tokens
+func TestMfaLogInBannedUser(t *testing.T) {
+	c := require.New(t)
+	router := setupMfaRouter()
+	user, password := createVerifiedUser(router)
+	credentials := map[string]string{"email": user.Email, "password": password}
+
+	_, response := postMfaRequest(router, "/session/login", credentials)
+	secret, _ := enableMfa(c, router, response["accessToken"].(string))
+	_, response = postMfaRequest(router, "/session/login", credentials)
+	mfaToken := response["mfaToken"].(string)
+
+	updateTestUser(user.Id, func(user *interfaces.User) {
+		user.Ban = &interfaces.UserSanction{Reason: "Cheating", CreatedAt: time.Now().Unix()}
+	})
+
+	totp, _ := utils.GetTOTPCode(secret, utils.GetTOTPStep(time.Now())+1)
+	code, response := postMfaRequest(router, "/session/mfa", map[string]string{"mfaToken": mfaToken, "code": totp})
+	c.Equal(http.StatusForbidden, code)
+	c.Equal("Your account has been banned", response["message"])
+	c.Nil(response["accessToken"])
+
+	err := tests.DeleteUser(repos, user.Email)
+	c.NoError(err)
+}
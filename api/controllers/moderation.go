@@ -0,0 +1,360 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Maximum amount of users returned by the search endpoint
+const maxUsersSearchLimit = 50
+
+// getModeratedUser "private" function to get the user from the url and abort the request if it fails
+func getModeratedUser(c *gin.Context) (interfaces.User, bool) {
+	if !primitive.IsValidObjectID(c.Param("id")) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Invalid user id"})
+		return interfaces.User{}, false
+	}
+
+	user, err := repos.Users.GetUserById(c.Param("id"))
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "User was not found"})
+			return user, false
+		}
+
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return user, false
+	}
+
+	return user, true
+}
+
+// getUserSummary "private" function to get the public fields of an user to be shown to the moderators
+func getUserSummary(user interfaces.User) gin.H {
+	return gin.H{
+		"_id":        user.Id,
+		"username":   user.Username,
+		"email":      user.Email,
+		"isVerified": user.IsVerified,
+		"roles":      user.Roles,
+		"ban":        user.Ban,
+		"mute":       user.Mute,
+	}
+}
+
+// HandleAdminSearchUsers Handle the request to search users by username or email
+func HandleAdminSearchUsers(c *gin.Context) {
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "20"), 10, 64)
+
+	if err != nil || limit <= 0 || limit > maxUsersSearchLimit {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Limit must be between 1 and 50"})
+		return
+	}
+
+	users, err := repos.Moderation.SearchUsers(strings.TrimSpace(c.Query("search")), limit)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	summaries := []gin.H{}
+	for _, user := range users {
+		summaries = append(summaries, getUserSummary(user))
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Users were retrieved successfully",
+		"users":   summaries,
+	})
+}
+
+// HandleAdminGetUser Handle the request to get the full state of an user
+func HandleAdminGetUser(c *gin.Context) {
+	user, ok := getModeratedUser(c)
+	if !ok {
+		return
+	}
+
+	items, loomballs, err := repos.Items.GetInventory(user.Items)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	loomies, err := repos.Loomies.GetLoomiesByIds(user.Loomies, user.Id)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	gyms, err := repos.Gyms.GetGymsByOwner(user.Id)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":       false,
+		"message":     "User was retrieved successfully",
+		"user":        getUserSummary(user),
+		"items":       items,
+		"loomballs":   loomballs,
+		"loomies":     loomies,
+		"loomie_team": user.LoomieTeam,
+		"gyms":        gyms,
+	})
+}
+
+// handleSetSanction "private" function to ban or mute (field) the user in the url
+func handleSetSanction(c *gin.Context, field string) {
+	var form interfaces.AdminSanctionReq
+
+	if err := c.BindJSON(&form); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Bad request"})
+		return
+	}
+
+	if strings.TrimSpace(form.Reason) == "" || form.DurationMinutes < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Reason cannot be empty and duration cannot be negative"})
+		return
+	}
+
+	user, ok := getModeratedUser(c)
+	if !ok {
+		return
+	}
+
+	actorId := getActorId(c)
+
+	// Moderators can't lock themselves or the admins out
+	if user.Id == actorId {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "You can't sanction yourself"})
+		return
+	}
+
+	for _, role := range user.Roles {
+		if role == utils.RoleAdmin {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "Admins can't be sanctioned"})
+			return
+		}
+	}
+
+	sanction := interfaces.UserSanction{
+		Reason:    form.Reason,
+		ActorId:   actorId,
+		CreatedAt: time.Now().Unix(),
+	}
+
+	if form.DurationMinutes > 0 {
+		sanction.ExpiresAt = time.Now().Add(time.Duration(form.DurationMinutes) * time.Minute).Unix()
+	}
+
+	err := repos.Moderation.SetUserSanction(c, user.Id, field, sanction)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "User sanction was applied successfully",
+		field:     sanction,
+	})
+}
+
+// handleRemoveSanction "private" function to remove the ban or mute (field) of the user in the url
+func handleRemoveSanction(c *gin.Context, field string) {
+	user, ok := getModeratedUser(c)
+	if !ok {
+		return
+	}
+
+	err := repos.Moderation.RemoveUserSanction(c, user.Id, field)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "User sanction was removed successfully",
+	})
+}
+
+// HandleAdminBanUser Handle the request to ban an user (It will be rejected on login and protected endpoints)
+func HandleAdminBanUser(c *gin.Context) {
+	handleSetSanction(c, "ban")
+}
+
+// HandleAdminUnbanUser Handle the request to remove the ban of an user
+func HandleAdminUnbanUser(c *gin.Context) {
+	handleRemoveSanction(c, "ban")
+}
+
+// rejectMutedUser "private" function to abort the request if the user is muted, it's called by the endpoints where
+// the players interact with each other (friend requests, trades, gifts and the public profile)
+func rejectMutedUser(c *gin.Context, user interfaces.User) bool {
+	if !user.Mute.IsActive() {
+		return false
+	}
+
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": true, "message": "Your account has been muted", "reason": user.Mute.Reason, "expires_at": user.Mute.ExpiresAt})
+	return true
+}
+
+// HandleAdminMuteUser Handle the request to mute an user (It won't be able to send friend requests, propose or
+// counter trades, send gifts nor change its public profile)
+func HandleAdminMuteUser(c *gin.Context) {
+	handleSetSanction(c, "mute")
+}
+
+// HandleAdminUnmuteUser Handle the request to remove the mute of an user
+func HandleAdminUnmuteUser(c *gin.Context) {
+	handleRemoveSanction(c, "mute")
+}
+
+// parseInventoryReq "private" function to validate the item and quantity in the request
+func parseInventoryReq(c *gin.Context) (primitive.ObjectID, int, bool) {
+	var form interfaces.AdminInventoryReq
+
+	if err := c.BindJSON(&form); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Bad request"})
+		return primitive.NilObjectID, 0, false
+	}
+
+	itemId, err := primitive.ObjectIDFromHex(form.ItemId)
+
+	if err != nil || form.Quantity <= 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Item id must be valid and quantity must be positive"})
+		return primitive.NilObjectID, 0, false
+	}
+
+	return itemId, form.Quantity, true
+}
+
+// HandleAdminGrantItem Handle the request to add an item or loom ball to the inventory of an user
+func HandleAdminGrantItem(c *gin.Context) {
+	itemId, quantity, ok := parseInventoryReq(c)
+	if !ok {
+		return
+	}
+
+	user, ok := getModeratedUser(c)
+	if !ok {
+		return
+	}
+
+	collection, err := repos.Items.GetRewardCollection(itemId)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "Item was not found"})
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	item := interfaces.GymRewardItem{RewardCollection: collection, RewardId: itemId, RewardQuantity: quantity}
+	err = repos.Items.AddItemToUserInventory(c, user.Id, item)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Item was added to the user inventory successfully",
+	})
+}
+
+// HandleAdminRemoveItem Handle the request to remove an item or loom ball from the inventory of an user
+func HandleAdminRemoveItem(c *gin.Context) {
+	itemId, quantity, ok := parseInventoryReq(c)
+	if !ok {
+		return
+	}
+
+	user, ok := getModeratedUser(c)
+	if !ok {
+		return
+	}
+
+	err := repos.Items.DecrementItemFromUserInventory(c, user.Id, itemId, quantity)
+
+	if err != nil {
+		if errors.Is(err, repositories.ErrItemNotFound) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "The user doesn't have the item"})
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Item was removed from the user inventory successfully",
+	})
+}
+
+// HandleAdminReleaseLoomies Handle the request to set the (stuck) busy loomies of an user as available
+func HandleAdminReleaseLoomies(c *gin.Context) {
+	var form interfaces.AdminReleaseLoomiesReq
+
+	if err := c.BindJSON(&form); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Bad request"})
+		return
+	}
+
+	loomiesIds := []primitive.ObjectID{}
+
+	for _, loomieId := range form.LoomieIds {
+		id, err := primitive.ObjectIDFromHex(loomieId)
+
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Invalid loomie id"})
+			return
+		}
+
+		loomiesIds = append(loomiesIds, id)
+	}
+
+	user, ok := getModeratedUser(c)
+	if !ok {
+		return
+	}
+
+	released, err := repos.Moderation.ReleaseUserBusyLoomies(c, user.Id, loomiesIds)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":    false,
+		"message":  "Loomies were released successfully",
+		"released": released,
+	})
+}
@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/middlewares"
+	"github.com/PedroChaparro/loomies-backend/tests"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ## Helper functions
+// setupModerationRouter creates a router with the moderation endpoints
+func setupModerationRouter() *gin.Engine {
+	router := tests.SetupGinRouter()
+	router.POST("/session/login", HandleLogIn)
+	router.GET("/session/whoami", middlewares.MustProvideAccessToken(), HandleWhoami)
+	moderation := router.Group("/admin/users", middlewares.MustProvideAccessToken(), middlewares.RequirePermission(utils.PermissionModerateUsers))
+	moderation.GET("", HandleAdminSearchUsers)
+	moderation.GET("/:id", HandleAdminGetUser)
+	moderation.POST("/:id/ban", HandleAdminBanUser)
+	moderation.DELETE("/:id/ban", HandleAdminUnbanUser)
+	moderation.POST("/:id/items/grant", HandleAdminGrantItem)
+	moderation.POST("/:id/items/remove", HandleAdminRemoveItem)
+	return router
+}
+
+// ## Tests
+
+// TestBanUser tests banned users are rejected on login and on the protected endpoints
+func TestBanUser(t *testing.T) {
+	var response map[string]interface{}
+	c := require.New(t)
+	router := setupModerationRouter()
+	moderator, moderatorToken := loginWithRoles(router, utils.RoleModerator)
+
+	// Create a player and keep its password to login again
+	player, password := createVerifiedUser(router)
+	credentials := map[string]string{"email": player.Email, "password": password}
+	code, response := postMfaRequest(router, "/session/login", credentials)
+	c.Equal(http.StatusOK, code)
+	playerToken := response["accessToken"].(string)
+
+	// 1. Ban the player
+	code, _ = sendContentRequest(router, "POST", "/admin/users/"+player.Id.Hex()+"/ban", map[string]interface{}{"reason": "Cheating", "duration_minutes": 60}, moderatorToken)
+	c.Equal(http.StatusOK, code)
+
+	// 2. The access token is rejected
+	w, req := tests.SetupGetRequest("/session/whoami", tests.CustomHeader{Name: "Access-Token", Value: playerToken})
+	router.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	c.Equal(http.StatusForbidden, w.Code)
+	c.Equal("Your account has been banned", response["message"])
+	c.Equal("Cheating", response["reason"])
+
+	// 3. The login is rejected
+	code, _ = postMfaRequest(router, "/session/login", credentials)
+	c.Equal(http.StatusForbidden, code)
+
+	// 4. Moderators can't ban themselves
+	code, _ = sendContentRequest(router, "POST", "/admin/users/"+moderator.Id.Hex()+"/ban", map[string]interface{}{"reason": "Testing"}, moderatorToken)
+	c.Equal(http.StatusConflict, code)
+
+	// 5. Unban the player
+	code, _ = sendContentRequest(router, "DELETE", "/admin/users/"+player.Id.Hex()+"/ban", nil, moderatorToken)
+	c.Equal(http.StatusOK, code)
+
+	code, _ = postMfaRequest(router, "/session/login", credentials)
+	c.Equal(http.StatusOK, code)
+
+	err := tests.DeleteUser(repos, moderator.Email)
+	c.NoError(err)
+	err = tests.DeleteUser(repos, player.Email)
+	c.NoError(err)
+}
+
+// TestMuteUser tests muted users can't interact with other players until the mute is removed
+func TestMuteUser(t *testing.T) {
+	c := require.New(t)
+	router := setupModerationRouter()
+	router.POST("/admin/users/:id/mute", middlewares.MustProvideAccessToken(), middlewares.RequirePermission(utils.PermissionModerateUsers), HandleAdminMuteUser)
+	router.DELETE("/admin/users/:id/mute", middlewares.MustProvideAccessToken(), middlewares.RequirePermission(utils.PermissionModerateUsers), HandleAdminUnmuteUser)
+	router.PATCH("/user/profile", middlewares.MustProvideAccessToken(), HandleUpdateProfile)
+	router.POST("/user/friends/requests", middlewares.MustProvideAccessToken(), HandleSendFriendRequest)
+	router.POST("/trades", middlewares.MustProvideAccessToken(), HandleProposeTrade)
+	router.POST("/gifts", middlewares.MustProvideAccessToken(), HandleSendGift)
+
+	moderator, moderatorToken := loginWithRoles(router, utils.RoleModerator)
+	player, playerToken := loginWithRoles(router)
+	other, _ := loginWithRoles(router)
+
+	// 1. Mute the player
+	code, _ := sendContentRequest(router, "POST", "/admin/users/"+player.Id.Hex()+"/mute", map[string]interface{}{"reason": "Spam"}, moderatorToken)
+	c.Equal(http.StatusOK, code)
+
+	// 2. The interactions with other players are rejected
+	interactions := []struct {
+		method   string
+		endpoint string
+		payload  interface{}
+	}{
+		{"POST", "/user/friends/requests", gin.H{"username": other.Username}},
+		{"POST", "/trades", gin.H{"username": other.Username}},
+		{"POST", "/gifts", gin.H{"username": other.Username}},
+		{"PATCH", "/user/profile", gin.H{"bio": "Visit my site"}},
+	}
+
+	for _, interaction := range interactions {
+		code, response := sendContentRequest(router, interaction.method, interaction.endpoint, interaction.payload, playerToken)
+		c.Equal(http.StatusForbidden, code, interaction.endpoint)
+		c.Equal("Your account has been muted", response["message"])
+		c.Equal("Spam", response["reason"])
+	}
+
+	// 3. The privacy settings can still be changed
+	code, _ = sendContentRequest(router, "PATCH", "/user/profile", gin.H{"share_online_status": true}, playerToken)
+	c.Equal(http.StatusOK, code)
+
+	// 4. Unmute the player
+	code, _ = sendContentRequest(router, "DELETE", "/admin/users/"+player.Id.Hex()+"/mute", nil, moderatorToken)
+	c.Equal(http.StatusOK, code)
+
+	code, _ = sendContentRequest(router, "POST", "/user/friends/requests", gin.H{"username": other.Username}, playerToken)
+	c.Equal(http.StatusCreated, code)
+
+	for _, user := range []interfaces.User{moderator, player, other} {
+		c.NoError(tests.DeleteUser(repos, user.Email))
+	}
+}
+
+// TestInspectAndEditUser tests the moderators can search, inspect and edit the inventory of the users
+func TestInspectAndEditUser(t *testing.T) {
+	c := require.New(t)
+	router := setupModerationRouter()
+	moderator, moderatorToken := loginWithRoles(router, utils.RoleModerator)
+	player, _ := createVerifiedUser(router)
+
+	// 1. Search the player
+	code, response := sendContentRequest(router, "GET", "/admin/users?search="+player.Username, nil, moderatorToken)
+	c.Equal(http.StatusOK, code)
+	c.GreaterOrEqual(len(response["users"].([]interface{})), 1)
+
+	// 2. Grant and remove an item
+	item := getTestItemBySerial(1)
+
+	code, _ = sendContentRequest(router, "POST", "/admin/users/"+player.Id.Hex()+"/items/grant", map[string]interface{}{"item_id": item.Id.Hex(), "quantity": 3}, moderatorToken)
+	c.Equal(http.StatusOK, code)
+
+	code, _ = sendContentRequest(router, "POST", "/admin/users/"+player.Id.Hex()+"/items/remove", map[string]interface{}{"item_id": item.Id.Hex(), "quantity": 1}, moderatorToken)
+	c.Equal(http.StatusOK, code)
+
+	// 3. Inspect the player
+	code, response = sendContentRequest(router, "GET", "/admin/users/"+player.Id.Hex(), nil, moderatorToken)
+	c.Equal(http.StatusOK, code)
+	items := response["items"].([]interface{})
+	c.Equal(1, len(items))
+	c.Equal(float64(2), items[0].(map[string]interface{})["quantity"])
+
+	// 4. Unknown users are not found
+	code, response = sendContentRequest(router, "GET", "/admin/users/"+primitive.NewObjectID().Hex(), nil, moderatorToken)
+	c.Equal(http.StatusNotFound, code)
+	c.Equal("User was not found", response["message"])
+
+	err := tests.DeleteUser(repos, moderator.Email)
+	c.NoError(err)
+	err = tests.DeleteUser(repos, player.Email)
+	c.NoError(err)
+}
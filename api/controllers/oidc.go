@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/email"
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var nonUsernameCharacters = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// getAvailableUsername "private" function to generate an unused username from the identity claims
+func getAvailableUsername(claims interfaces.OIDCIdTokenClaims) (string, error) {
+	base := claims.PreferredUsername
+
+	if base == "" {
+		base = strings.Split(claims.Email, "@")[0]
+	}
+
+	base = nonUsernameCharacters.ReplaceAllString(base, "")
+
+	if len(base) < 3 {
+		base = "trainer"
+	}
+
+	candidate := base
+
+	for i := 0; i < 5; i++ {
+		_, err := repos.Users.GetUserByUsername(candidate)
+
+		if err == mongo.ErrNoDocuments {
+			return candidate, nil
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		candidate = fmt.Sprintf("%s_%s", base, utils.GetValidationCode()[:4])
+	}
+
+	return "", fmt.Errorf("Unable to find an available username")
+}
+
+// HandleOIDCAuthorize Handle the request to start the login with an OpenID Connect provider
+func HandleOIDCAuthorize(c *gin.Context) {
+	provider, ok := config.OIDCProvider(c.Param("provider"))
+
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "The identity provider is not supported"})
+		return
+	}
+
+	// Create the state, nonce and PKCE verifier for this authorization request
+	state := interfaces.OIDCState{
+		State:        utils.GetRandomUrlSafeString(24),
+		Provider:     provider.Name,
+		Nonce:        utils.GetRandomUrlSafeString(24),
+		CodeVerifier: utils.GetRandomUrlSafeString(48),
+		ExpiresAt:    time.Now().Add(time.Minute * 10).Unix(),
+	}
+
+	err := repos.Accounts.InsertOIDCState(state)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":             false,
+		"message":           "Authorization url was created successfully",
+		"authorization_url": utils.GetOIDCAuthorizationURL(provider, state.State, state.Nonce, state.CodeVerifier),
+		"state":             state.State,
+	})
+}
+
+// HandleOIDCCallback Handle the request to finish the login with an OpenID Connect provider from the authorization code
+func HandleOIDCCallback(c *gin.Context) {
+	provider, ok := config.OIDCProvider(c.Param("provider"))
+
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "The identity provider is not supported"})
+		return
+	}
+
+	var form interfaces.OIDCCallbackReq
+
+	if err := c.BindJSON(&form); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Bad request"})
+		return
+	}
+
+	if form.Code == "" || form.State == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Code and state are required"})
+		return
+	}
+
+	// 1. Check the state was created by us and was not used before
+	state, err := repos.Accounts.PopOIDCState(provider.Name, form.State)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": true, "message": "The state is invalid or has expired"})
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	// 2. Exchange the code and validate the ID token
+	idToken, err := utils.ExchangeOIDCCode(provider, form.Code, state.CodeVerifier)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": true, "message": err.Error()})
+		return
+	}
+
+	claims, err := utils.ValidateOIDCIdToken(provider, idToken, state.Nonce)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": true, "message": err.Error()})
+		return
+	}
+
+	// 3. Find the linked user, link an existing user by its verified email or create a new one
+	user, err := repos.Accounts.GetUserByIdentity(provider.Name, claims.Subject)
+
+	if err != nil && err != mongo.ErrNoDocuments {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	if err == mongo.ErrNoDocuments {
+		if claims.Email == "" || !claims.EmailVerified {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": true, "message": "The identity provider didn't return a verified email"})
+			return
+		}
+
+		identity := interfaces.UserIdentity{
+			Provider: provider.Name,
+			Subject:  claims.Subject,
+			LinkedAt: time.Now().Unix(),
+		}
+
+		user, err = repos.Users.GetUserByEmail(claims.Email)
+
+		if err != nil && err != mongo.ErrNoDocuments {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+			return
+		}
+
+		// The password of unverified accounts is removed when they are linked
+		if err == nil {
+			err = repos.Accounts.LinkUserIdentity(c, user, identity)
+
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Unable to link the account. Please try again later"})
+				return
+			}
+		} else {
+			username, err := getAvailableUsername(claims)
+
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Unable to create user. Please try again later"})
+				return
+			}
+
+			// The user won't be able to login with a password until it is reset
+			err = repos.Users.InsertUser(c, interfaces.User{
+				Username:   username,
+				Email:      claims.Email,
+				Language:   email.NormalizeLanguage(c.GetHeader("Accept-Language")),
+				IsVerified: true,
+				Identities: []interfaces.UserIdentity{identity},
+			})
+
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Unable to create user. Please try again later"})
+				return
+			}
+		}
+
+		user, err = repos.Accounts.GetUserByIdentity(provider.Name, claims.Subject)
+
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+			return
+		}
+	}
+
+	startUserSession(c, user)
+}
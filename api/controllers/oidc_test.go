@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/PedroChaparro/loomies-backend/tests"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+// ## Helper functions
+// loginWithFakeProvider runs the whole authorization flow against the fake provider and returns the callback response
+func loginWithFakeProvider(router *gin.Engine, provider *tests.FakeOIDCProvider, identity tests.FakeOIDCIdentity) (int, map[string]interface{}) {
+	var authorizeResponse, callbackResponse map[string]interface{}
+
+	// Start the authorization request
+	w, req := tests.SetupGetRequest("/session/oidc/fake/authorize")
+	router.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &authorizeResponse)
+
+	// Log in with the provider and send the code to the callback
+	code := provider.Authorize(authorizeResponse["authorization_url"].(string), identity)
+	w, req = tests.SetupPayloadedRequest("/session/oidc/fake/callback", "POST", map[string]string{
+		"code":  code,
+		"state": authorizeResponse["state"].(string),
+	})
+	router.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &callbackResponse)
+
+	return w.Code, callbackResponse
+}
+
+// setupOIDCRouter creates a router with the social login endpoints
+func setupOIDCRouter() *gin.Engine {
+	router := tests.SetupGinRouter()
+	router.GET("/session/oidc/:provider/authorize", HandleOIDCAuthorize)
+	router.POST("/session/oidc/:provider/callback", HandleOIDCCallback)
+	return router
+}
+
+// ## Tests
+
+// TestOIDCAuthorize tests the authorization url contains the PKCE challenge
+func TestOIDCAuthorize(t *testing.T) {
+	var response map[string]interface{}
+	c := require.New(t)
+	provider := tests.NewFakeOIDCProvider(config, "fake")
+	defer provider.Close()
+	router := setupOIDCRouter()
+
+	// Unknown providers are rejected
+	w, req := tests.SetupGetRequest("/session/oidc/unknown/authorize")
+	router.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	c.Equal(http.StatusNotFound, w.Code)
+	c.Equal("The identity provider is not supported", response["message"])
+
+	// Configured providers return the authorization url
+	w, req = tests.SetupGetRequest("/session/oidc/fake/authorize")
+	router.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	c.Equal(http.StatusOK, w.Code)
+	c.Contains(response["authorization_url"], provider.URL+"/authorize?")
+	c.Contains(response["authorization_url"], "code_challenge_method=S256")
+	c.NotEmpty(response["state"])
+}
+
+// TestOIDCCallbackCreatesUser tests a new user is created from a verified identity and it can login again
+func TestOIDCCallbackCreatesUser(t *testing.T) {
+	c := require.New(t)
+	provider := tests.NewFakeOIDCProvider(config, "fake")
+	defer provider.Close()
+	router := setupOIDCRouter()
+
+	identity := tests.FakeOIDCIdentity{
+		Subject:       tests.FakerInstance.UUID().V4(),
+		Email:         tests.FakerInstance.Internet().Email(),
+		EmailVerified: true,
+	}
+
+	// 1. First login creates the user
+	code, response := loginWithFakeProvider(router, provider, identity)
+	c.Equal(http.StatusOK, code)
+	c.Equal("Successfully logged in", response["message"])
+	c.NotEmpty(response["accessToken"])
+	c.NotEmpty(response["refreshToken"])
+
+	user, err := repos.Users.GetUserByEmail(identity.Email)
+	c.NoError(err)
+	c.True(user.IsVerified)
+	c.Equal(1, len(user.Identities))
+	c.Equal("fake", user.Identities[0].Provider)
+	c.Equal(identity.Subject, user.Identities[0].Subject)
+
+	// 2. Second login uses the same user
+	code, response = loginWithFakeProvider(router, provider, identity)
+	c.Equal(http.StatusOK, code)
+	c.Equal(user.Username, response["user"].(map[string]interface{})["username"])
+
+	count := 0
+	for _, stored := range testStore.Users {
+		if stored.Email == identity.Email {
+			count++
+		}
+	}
+
+	c.Equal(1, count)
+
+	err = tests.DeleteUser(repos, user.Email)
+	c.NoError(err)
+}
+
+// TestOIDCCallbackLinksExistingUser tests an existing account is linked by its verified email
+func TestOIDCCallbackLinksExistingUser(t *testing.T) {
+	c := require.New(t)
+	provider := tests.NewFakeOIDCProvider(config, "fake")
+	defer provider.Close()
+	router := setupOIDCRouter()
+
+	// Create a user with email and password
+	databaseUser, _ := loginWithRandomUser()
+
+	code, response := loginWithFakeProvider(router, provider, tests.FakeOIDCIdentity{
+		Subject:       tests.FakerInstance.UUID().V4(),
+		Email:         databaseUser.Email,
+		EmailVerified: true,
+	})
+
+	c.Equal(http.StatusOK, code)
+	c.Equal(databaseUser.Username, response["user"].(map[string]interface{})["username"])
+
+	user, err := repos.Users.GetUserById(databaseUser.Id.Hex())
+	c.NoError(err)
+	c.Equal(1, len(user.Identities))
+
+	err = tests.DeleteUser(repos, databaseUser.Email)
+	c.NoError(err)
+}
+
+// TestOIDCCallbackLinksUnverifiedUser tests the password of an unverified account is removed when it's linked, so
+// whoever registered the email before its owner can't log in
+func TestOIDCCallbackLinksUnverifiedUser(t *testing.T) {
+	var response map[string]interface{}
+	c := require.New(t)
+	provider := tests.NewFakeOIDCProvider(config, "fake")
+	defer provider.Close()
+	router := setupOIDCRouter()
+	router.POST("/session/login", HandleLogIn)
+
+	// Register the email without verifying it
+	randomUser := tests.GenerateRandomUser()
+	tests.InsertUser(randomUser, router, HandleSignUp)
+
+	code, _ := loginWithFakeProvider(router, provider, tests.FakeOIDCIdentity{
+		Subject:       tests.FakerInstance.UUID().V4(),
+		Email:         randomUser.Email,
+		EmailVerified: true,
+	})
+	c.Equal(http.StatusOK, code)
+
+	user, err := repos.Users.GetUserByEmail(randomUser.Email)
+	c.NoError(err)
+	c.True(user.IsVerified)
+	c.Empty(user.Password)
+	c.Equal(1, len(user.Identities))
+
+	// The password chosen at the registration doesn't work anymore
+	w, req := tests.SetupPayloadedRequest("/session/login", "POST", map[string]string{
+		"email":    randomUser.Email,
+		"password": randomUser.Password,
+	})
+	router.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	c.Equal(http.StatusUnauthorized, w.Code)
+	c.Equal("Wrong Email/Password", response["message"])
+
+	err = tests.DeleteUser(repos, user.Email)
+	c.NoError(err)
+}
+
+// TestOIDCCallbackErrors tests the identities that should not be accepted
+func TestOIDCCallbackErrors(t *testing.T) {
+	var response map[string]interface{}
+	c := require.New(t)
+	provider := tests.NewFakeOIDCProvider(config, "fake")
+	defer provider.Close()
+	router := setupOIDCRouter()
+
+	// 1. Unverified emails can't be linked or registered
+	code, response := loginWithFakeProvider(router, provider, tests.FakeOIDCIdentity{
+		Subject:       tests.FakerInstance.UUID().V4(),
+		Email:         tests.FakerInstance.Internet().Email(),
+		EmailVerified: false,
+	})
+	c.Equal(http.StatusForbidden, code)
+	c.Equal("The identity provider didn't return a verified email", response["message"])
+
+	// 2. Tokens signed with unknown keys are rejected
+	provider.SignWithUnknownKey = true
+	code, response = loginWithFakeProvider(router, provider, tests.FakeOIDCIdentity{
+		Subject:       tests.FakerInstance.UUID().V4(),
+		Email:         tests.FakerInstance.Internet().Email(),
+		EmailVerified: true,
+	})
+	c.Equal(http.StatusUnauthorized, code)
+	c.Equal("Invalid ID token (signature)", response["message"])
+
+	// 3. Unknown states are rejected
+	w, req := tests.SetupPayloadedRequest("/session/oidc/fake/callback", "POST", map[string]string{
+		"code":  "some-code",
+		"state": "unknown-state",
+	})
+	router.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	c.Equal(http.StatusUnauthorized, w.Code)
+	c.Equal("The state is invalid or has expired", response["message"])
+}
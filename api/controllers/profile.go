@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Limits of the profile fields (in characters)
+const (
+	maxDisplayNameLength = 24
+	maxAvatarLength      = 512
+	maxBioLength         = 160
+)
+
+// Fields of the trainer card that can be hidden to the other players
+var hideableProfileFields = []string{"join_date", "level", "loomies_count", "gyms", "best_loomie", "bio"}
+
+// getBestLoomie "private" function to get the loomie with the highest level (experience and stats break the ties)
+func getBestLoomie(loomies []interfaces.UserLoomiesRes) *interfaces.UserLoomiesRes {
+	var best *interfaces.UserLoomiesRes
+
+	for i := range loomies {
+		loomie := &loomies[i]
+
+		if best == nil ||
+			loomie.Level > best.Level ||
+			(loomie.Level == best.Level && loomie.Experience > best.Experience) ||
+			(loomie.Level == best.Level && loomie.Experience == best.Experience &&
+				loomie.Hp+loomie.Attack+loomie.Defense > best.Hp+best.Attack+best.Defense) {
+			best = loomie
+		}
+	}
+
+	return best
+}
+
+// getDisplayName "private" function to get the display name of the trainer (the username by default)
+func getDisplayName(user interfaces.User) string {
+	if user.Profile.DisplayName == "" {
+		return user.Username
+	}
+
+	return user.Profile.DisplayName
+}
+
+// addTrainerExperience "private" function to give experience to the trainer. The errors are only logged
+// to don't fail the action that earned the experience
+func addTrainerExperience(c *gin.Context, userId primitive.ObjectID, amount float64) *interfaces.TrainerProgressRes {
+	progress, err := repos.Users.AddTrainerExperience(c, userId, amount)
+
+	if err != nil {
+		fmt.Println("Unable to add the trainer experience:", err)
+		return nil
+	}
+
+	return &progress
+}
+
+// validateProfile "private" function to check the profile fields and return the error message (if any)
+func validateProfile(profile *interfaces.UserProfile) string {
+	profile.DisplayName = strings.TrimSpace(profile.DisplayName)
+	profile.Avatar = strings.TrimSpace(profile.Avatar)
+	profile.Bio = strings.TrimSpace(profile.Bio)
+
+	if utf8.RuneCountInString(profile.DisplayName) > maxDisplayNameLength {
+		return "Display name is too long"
+	}
+
+	if profile.Avatar != "" {
+		avatar, err := url.ParseRequestURI(profile.Avatar)
+
+		if err != nil || (avatar.Scheme != "https" && avatar.Scheme != "http") || avatar.Host == "" || len(profile.Avatar) > maxAvatarLength {
+			return "Avatar must be a valid http(s) url"
+		}
+	}
+
+	if utf8.RuneCountInString(profile.Bio) > maxBioLength {
+		return "Bio is too long"
+	}
+
+	for _, field := range profile.HiddenFields {
+		valid := false
+
+		for _, hideable := range hideableProfileFields {
+			if field == hideable {
+				valid = true
+				break
+			}
+		}
+
+		if !valid {
+			return "Field " + field + " can't be hidden"
+		}
+	}
+
+	return ""
+}
+
+// HandleGetTrainerCard Handle the request to get the public trainer card of a player by username
+func HandleGetTrainerCard(c *gin.Context) {
+	viewer, ok := getSessionUser(c)
+	if !ok {
+		return
+	}
+
+	user, ok := getVisibleUser(c, viewer, c.Param("username"))
+	if !ok {
+		return
+	}
+
+	loomies, err := repos.Loomies.GetLoomiesByIds(user.Loomies, user.Id)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	ownedGyms, err := repos.Gyms.GetGymsByOwner(user.Id)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	gyms := []gin.H{}
+	for _, gym := range ownedGyms {
+		gyms = append(gyms, gin.H{"_id": gym.Id, "name": gym.Name})
+	}
+
+	level := user.Level
+	if level == 0 {
+		level = 1
+	}
+
+	card := gin.H{
+		"username":      user.Username,
+		"display_name":  getDisplayName(user),
+		"avatar":        user.Profile.Avatar,
+		"bio":           user.Profile.Bio,
+		"join_date":     user.Id.Timestamp().Unix(),
+		"level":         level,
+		"loomies_count": len(loomies),
+		"gyms":          gyms,
+		"best_loomie":   getBestLoomie(loomies),
+	}
+
+	// The owner of the card and their friends can see all the fields
+	if viewer.Id != user.Id {
+		isFriend, err := repos.Friends.AreFriends(viewer.Id, user.Id)
+
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+			return
+		}
+
+		if !isFriend {
+			for _, field := range user.Profile.HiddenFields {
+				delete(card, field)
+			}
+		}
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Trainer card was retrieved successfully",
+		"card":    card,
+	})
+}
+
+// HandleUpdateProfile Handle the request to update the display name, avatar, bio and privacy settings of the user
+func HandleUpdateProfile(c *gin.Context) {
+	var form interfaces.UpdateProfileReq
+
+	if err := c.BindJSON(&form); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Bad request"})
+		return
+	}
+
+	user, ok := getSessionUser(c)
+	if !ok {
+		return
+	}
+
+	// The muted users can still change the privacy settings
+	if (form.DisplayName != nil || form.Avatar != nil || form.Bio != nil) && rejectMutedUser(c, user) {
+		return
+	}
+
+	profile := user.Profile
+
+	if form.DisplayName != nil {
+		profile.DisplayName = *form.DisplayName
+	}
+
+	if form.Avatar != nil {
+		profile.Avatar = *form.Avatar
+	}
+
+	if form.Bio != nil {
+		profile.Bio = *form.Bio
+	}
+
+	if form.HiddenFields != nil {
+		profile.HiddenFields = form.HiddenFields
+	}
+
+	if form.ShareOnlineStatus != nil {
+		profile.ShareOnlineStatus = *form.ShareOnlineStatus
+	}
+
+	if form.ShareLastSeenZone != nil {
+		profile.ShareLastSeenZone = *form.ShareLastSeenZone
+	}
+
+	if message := validateProfile(&profile); message != "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": message})
+		return
+	}
+
+	if err := repos.Users.UpdateUserProfile(c, user, profile); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Profile was updated successfully",
+		"profile": profile,
+	})
+}
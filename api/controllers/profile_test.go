@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/PedroChaparro/loomies-backend/middlewares"
+	"github.com/PedroChaparro/loomies-backend/tests"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+// ## Helper functions
+// setupProfileRouter creates a router with the profile endpoints
+func setupProfileRouter() *gin.Engine {
+	router := tests.SetupGinRouter()
+	router.POST("/session/login", HandleLogIn)
+	router.GET("/users/:username", middlewares.MustProvideAccessToken(), HandleGetTrainerCard)
+	router.PATCH("/user/profile", middlewares.MustProvideAccessToken(), HandleUpdateProfile)
+	return router
+}
+
+// getTrainerCard sends the request to get the trainer card of the given username
+func getTrainerCard(router *gin.Engine, username string, accessToken string) (int, map[string]interface{}) {
+	var response map[string]interface{}
+	w, req := tests.SetupGetRequest("/users/"+username, tests.CustomHeader{Name: "Access-Token", Value: accessToken})
+	router.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response
+}
+
+// ## Tests
+
+// TestUpdateProfileValidation tests invalid profile fields are rejected
+func TestUpdateProfileValidation(t *testing.T) {
+	c := require.New(t)
+	router := setupProfileRouter()
+	user, accessToken := loginWithRoles(router)
+
+	code, response := sendContentRequest(router, "PATCH", "/user/profile", map[string]interface{}{"display_name": strings.Repeat("a", 25)}, accessToken)
+	c.Equal(http.StatusBadRequest, code)
+	c.Equal("Display name is too long", response["message"])
+
+	code, response = sendContentRequest(router, "PATCH", "/user/profile", map[string]interface{}{"avatar": "javascript:alert(1)"}, accessToken)
+	c.Equal(http.StatusBadRequest, code)
+	c.Equal("Avatar must be a valid http(s) url", response["message"])
+
+	code, response = sendContentRequest(router, "PATCH", "/user/profile", map[string]interface{}{"bio": strings.Repeat("a", 161)}, accessToken)
+	c.Equal(http.StatusBadRequest, code)
+	c.Equal("Bio is too long", response["message"])
+
+	code, response = sendContentRequest(router, "PATCH", "/user/profile", map[string]interface{}{"hidden_fields": []string{"email"}}, accessToken)
+	c.Equal(http.StatusBadRequest, code)
+	c.Equal("Field email can't be hidden", response["message"])
+
+	tests.DeleteUser(repos, user.Email)
+}
+
+// TestTrainerCard tests the trainer card shows the profile and respects the privacy settings
+func TestTrainerCard(t *testing.T) {
+	c := require.New(t)
+	router := setupProfileRouter()
+	user, accessToken := loginWithRoles(router)
+	other, otherToken := loginWithRoles(router)
+
+	// 1. Unknown and partial usernames are not found
+	code, _ := getTrainerCard(router, "not-a-real-trainer", otherToken)
+	c.Equal(http.StatusNotFound, code)
+
+	code, _ = getTrainerCard(router, user.Username[1:], otherToken)
+	c.Equal(http.StatusNotFound, code)
+
+	// 2. The display name defaults to the username
+	code, response := getTrainerCard(router, user.Username, otherToken)
+	c.Equal(http.StatusOK, code)
+	card := response["card"].(map[string]interface{})
+	c.Equal(user.Username, card["display_name"])
+	c.Equal(float64(1), card["level"])
+	c.Equal(float64(0), card["loomies_count"])
+	c.Nil(card["best_loomie"])
+
+	// 3. Update the profile and hide the gyms and the level
+	code, response = sendContentRequest(router, "PATCH", "/user/profile", map[string]interface{}{
+		"display_name":  "  Ash  ",
+		"avatar":        "https://loomies.app/avatars/1.png",
+		"bio":           "Gotta catch 'em all",
+		"hidden_fields": []string{"gyms", "level"},
+	}, accessToken)
+	c.Equal(http.StatusOK, code)
+	c.Equal("Ash", response["profile"].(map[string]interface{})["display_name"])
+
+	// 4. Other players can't see the hidden fields
+	_, response = getTrainerCard(router, strings.ToUpper(user.Username), otherToken)
+	card = response["card"].(map[string]interface{})
+	c.Equal("Ash", card["display_name"])
+	c.Equal("https://loomies.app/avatars/1.png", card["avatar"])
+	c.NotContains(card, "gyms")
+	c.NotContains(card, "level")
+	c.Contains(card, "join_date")
+
+	// 5. The owner can see all the fields
+	_, response = getTrainerCard(router, user.Username, accessToken)
+	card = response["card"].(map[string]interface{})
+	c.Contains(card, "gyms")
+	c.Contains(card, "level")
+
+	// 6. Partial updates keep the other fields
+	code, response = sendContentRequest(router, "PATCH", "/user/profile", map[string]interface{}{"bio": ""}, accessToken)
+	c.Equal(http.StatusOK, code)
+	profile := response["profile"].(map[string]interface{})
+	c.Equal("Ash", profile["display_name"])
+	c.Equal("", profile["bio"])
+
+	tests.DeleteUser(repos, user.Email)
+	tests.DeleteUser(repos, other.Email)
+}
+
+// TestTrainerExperience tests the trainers level up and receive the level rewards
+func TestTrainerExperience(t *testing.T) {
+	c := require.New(t)
+	router := setupProfileRouter()
+	user, accessToken := loginWithRoles(router)
+
+	// 1. The experience is added without leveling up
+	progress, err := repos.Users.AddTrainerExperience(context.Background(), user.Id, 100)
+	c.NoError(err)
+	c.Equal(float64(100), progress.Experience)
+	c.Equal(1, progress.Level)
+	c.False(progress.LevelUp)
+	c.Equal(utils.GetTrainerRequiredExperience(2, config.Trainer), progress.NextLevelExperience)
+
+	// 2. Reach the third level at once, the rewards of both levels are given
+	progress, err = repos.Users.AddTrainerExperience(context.Background(), user.Id, utils.GetTrainerRequiredExperience(3, config.Trainer)-100)
+	c.NoError(err)
+	c.Equal(3, progress.Level)
+	c.True(progress.LevelUp)
+	c.NotEmpty(progress.Rewards)
+
+	databaseUser, err := repos.Users.GetUserById(user.Id.Hex())
+	c.NoError(err)
+	c.Equal(3, databaseUser.Level)
+
+	for _, reward := range progress.Rewards {
+		found := false
+
+		for _, item := range databaseUser.Items {
+			if item.ItemId == reward.RewardId {
+				found = true
+				c.Equal(reward.RewardQuantity, item.ItemQuantity)
+			}
+		}
+
+		c.True(found)
+	}
+
+	// 3. The level is shown in the trainer card
+	_, response := getTrainerCard(router, user.Username, accessToken)
+	c.Equal(float64(3), response["card"].(map[string]interface{})["level"])
+
+	tests.DeleteUser(repos, user.Email)
+}
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// trackQuest "private" function to increment the progress of the quests of the user that listen to the given event.
+// The errors are only logged to don't fail the action that triggered the event
+func trackQuest(c *gin.Context, userId primitive.ObjectID, event string, value string, loomieTypes []primitive.ObjectID) {
+	if err := repos.Quests.TrackQuestProgress(c, userId, event, value, loomieTypes); err != nil {
+		fmt.Println("Unable to update the quests progress:", err)
+	}
+}
+
+// HandleGetQuests Handle the request to get the daily and weekly quests of the user
+func HandleGetQuests(c *gin.Context) {
+	user, ok := getSessionUser(c)
+	if !ok {
+		return
+	}
+
+	quests, err := repos.Quests.GetUserQuests(c, user.Id)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Quests were retrieved successfully",
+		"quests":  quests,
+	})
+}
+
+// HandleClaimQuest Handle the request to claim the rewards of a completed quest
+func HandleClaimQuest(c *gin.Context) {
+	questId, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Invalid quest id"})
+		return
+	}
+
+	user, ok := getSessionUser(c)
+	if !ok {
+		return
+	}
+
+	quest, err := repos.Quests.GetUserQuestById(user.Id, questId)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "Quest was not found"})
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	if quest.Claimed {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "Quest was already claimed"})
+		return
+	}
+
+	if quest.ExpiresAt <= time.Now().Unix() {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "Quest has expired"})
+		return
+	}
+
+	if quest.Progress < quest.Goal {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "Quest is not completed yet"})
+		return
+	}
+
+	claimed, err := repos.Quests.ClaimUserQuest(c, quest)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	if !claimed {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "Quest was already claimed"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Quest was claimed successfully",
+		"rewards": quest.Rewards,
+	})
+}
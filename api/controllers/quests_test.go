@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/middlewares"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"github.com/PedroChaparro/loomies-backend/tests"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ## Helper functions
+// getQuests sends the request to get the quests of the user
+func getQuests(router *gin.Engine, accessToken string) (int, []interfaces.UserQuest) {
+	var response struct {
+		Quests []interfaces.UserQuest `json:"quests"`
+	}
+
+	w, req := tests.SetupGetRequest("/quests", tests.CustomHeader{Name: "Access-Token", Value: accessToken})
+	router.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response.Quests
+}
+
+// ## Tests
+
+// TestQuests tests the quests are generated once per period, tracked and claimed only once
+func TestQuests(t *testing.T) {
+	ctx := context.Background()
+	c := require.New(t)
+	router := tests.SetupGinRouter()
+	router.POST("/session/login", HandleLogIn)
+	router.GET("/quests", middlewares.MustProvideAccessToken(), HandleGetQuests)
+	router.POST("/quests/:id/claim", middlewares.MustProvideAccessToken(), HandleClaimQuest)
+	user, accessToken := loginWithRoles(router)
+
+	// 1. The daily and weekly quests are generated on the first request
+	code, quests := getQuests(router, accessToken)
+	c.Equal(http.StatusOK, code)
+	c.Equal(repositories.QuestsPerPeriod["daily"]+repositories.QuestsPerPeriod["weekly"], len(quests))
+
+	// 2. The quests aren't generated again in the same period
+	_, sameQuests := getQuests(router, accessToken)
+	c.Equal(len(quests), len(sameQuests))
+	for index := range quests {
+		c.Equal(quests[index].Id, sameQuests[index].Id)
+	}
+
+	// 3. Invalid, unknown and uncompleted quests can't be claimed
+	code, response := sendContentRequest(router, "POST", "/quests/not-an-id/claim", nil, accessToken)
+	c.Equal(http.StatusBadRequest, code)
+	c.Equal("Invalid quest id", response["message"])
+
+	code, response = sendContentRequest(router, "POST", "/quests/"+primitive.NewObjectID().Hex()+"/claim", nil, accessToken)
+	c.Equal(http.StatusNotFound, code)
+	c.Equal("Quest was not found", response["message"])
+
+	code, response = sendContentRequest(router, "POST", "/quests/"+quests[0].Id.Hex()+"/claim", nil, accessToken)
+	c.Equal(http.StatusConflict, code)
+	c.Equal("Quest is not completed yet", response["message"])
+
+	// 4. The unique quests only count different values
+	for _, quest := range quests {
+		if quest.Unique {
+			c.NoError(repos.Quests.TrackQuestProgress(ctx, user.Id, quest.Event, "0,0", nil))
+			c.NoError(repos.Quests.TrackQuestProgress(ctx, user.Id, quest.Event, "0,0", nil))
+
+			tracked, err := repos.Quests.GetUserQuestById(user.Id, quest.Id)
+			c.NoError(err)
+			c.Equal(1, tracked.Progress)
+		}
+	}
+
+	// 5. Complete all the quests, the progress doesn't exceed the goal
+	for _, quest := range quests {
+		for i := 0; i <= quest.Goal; i++ {
+			err := repos.Quests.TrackQuestProgress(ctx, user.Id, quest.Event, fmt.Sprintf("%d,1", i), []primitive.ObjectID{quest.LoomieTypeId})
+			c.NoError(err)
+		}
+	}
+
+	_, quests = getQuests(router, accessToken)
+	for _, quest := range quests {
+		c.Equal(quest.Goal, quest.Progress)
+	}
+
+	// 6. Claim the quest and check the rewards were added to the inventory
+	code, response = sendContentRequest(router, "POST", "/quests/"+quests[0].Id.Hex()+"/claim", nil, accessToken)
+	c.Equal(http.StatusOK, code)
+	c.NotEmpty(response["rewards"])
+
+	databaseUser, err := repos.Users.GetUserById(user.Id.Hex())
+	c.NoError(err)
+
+	for _, reward := range quests[0].Rewards {
+		found := false
+
+		for _, item := range databaseUser.Items {
+			if item.ItemId == reward.RewardId {
+				found = true
+				c.GreaterOrEqual(item.ItemQuantity, reward.RewardQuantity)
+			}
+		}
+
+		c.True(found)
+	}
+
+	// 7. The quest can't be claimed twice
+	code, response = sendContentRequest(router, "POST", "/quests/"+quests[0].Id.Hex()+"/claim", nil, accessToken)
+	c.Equal(http.StatusConflict, code)
+	c.Equal("Quest was already claimed", response["message"])
+
+	tests.DeleteUser(repos, user.Email)
+}
@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"github.com/gin-gonic/gin"
+)
+
+// The region names are used in the zones identifiers, so they are restricted to lowercase slugs
+var regionNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{1,31}$`)
+
+// validateRegion "private" function to validate the bounds, the grid and the spawn table of a new region
+func validateRegion(form interfaces.RegionReq) error {
+	if !regionNameRegex.MatchString(form.Name) {
+		return errors.New("Name must have between 2 and 32 lowercase letters, numbers, dashes or underscores")
+	}
+
+	bounds := form.Bounds
+	if bounds.MinLatitude < -90 || bounds.MaxLatitude > 90 || bounds.MinLongitude < -180 || bounds.MaxLongitude > 180 {
+		return errors.New("Bounds must be valid latitudes and longitudes")
+	}
+
+	if bounds.MinLatitude >= bounds.MaxLatitude || bounds.MinLongitude >= bounds.MaxLongitude {
+		return errors.New("Min bounds must be lower than the max bounds")
+	}
+
+	if form.GridStep <= 0 || form.GridStep > bounds.MaxLatitude-bounds.MinLatitude || form.GridStep > bounds.MaxLongitude-bounds.MinLongitude {
+		return errors.New("Grid step must be positive and fit in the bounds")
+	}
+
+	if _, err := time.LoadLocation(form.Timezone); err != nil || form.Timezone == "" {
+		return errors.New("Timezone must be a valid IANA time zone (Eg. America/Bogota)")
+	}
+
+	serials := map[int]bool{}
+	for _, spawn := range form.SpawnTable {
+		if spawn.Chance <= 0 || spawn.Chance > 1 {
+			return errors.New("Spawn chances must be greater than 0 and at most 1")
+		}
+
+		if serials[spawn.Serial] {
+			return errors.New("Spawn table can't have repeated loomies")
+		}
+
+		serials[spawn.Serial] = true
+	}
+
+	return nil
+}
+
+// HandleAdminGetRegions Handle the request to get the registered regions
+func HandleAdminGetRegions(c *gin.Context) {
+	regions, err := repos.Zones.GetRegions()
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Regions were retrieved successfully",
+		"regions": regions,
+	})
+}
+
+// HandleAdminCreateRegion Handle the request to register a new region. Its zones and gyms are generated separately
+func HandleAdminCreateRegion(c *gin.Context) {
+	var form interfaces.RegionReq
+
+	if err := c.BindJSON(&form); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Bad request"})
+		return
+	}
+
+	form.Name = strings.TrimSpace(form.Name)
+	if err := validateRegion(form); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": err.Error()})
+		return
+	}
+
+	spawnTable := form.SpawnTable
+	if spawnTable == nil {
+		spawnTable = []interfaces.RegionSpawn{}
+	}
+
+	region, err := repos.Zones.CreateRegion(c, interfaces.Region{
+		Name:       form.Name,
+		Bounds:     form.Bounds,
+		GridStep:   form.GridStep,
+		Timezone:   form.Timezone,
+		SpawnTable: spawnTable,
+	})
+
+	if err != nil {
+		switch err {
+		case repositories.ErrRegionExists, repositories.ErrRegionOverlaps:
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": err.Error()})
+		case repositories.ErrRegionUnknownLoomie:
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": err.Error()})
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		}
+
+		return
+	}
+
+	c.IndentedJSON(http.StatusCreated, gin.H{
+		"error":   false,
+		"message": "Region was registered successfully",
+		"region":  region,
+	})
+}
@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/middlewares"
+	"github.com/PedroChaparro/loomies-backend/tests"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+// ## Helper functions
+// setupRegionsRouter creates a router with the regions endpoints
+func setupRegionsRouter() *gin.Engine {
+	router := tests.SetupGinRouter()
+	router.POST("/session/login", HandleLogIn)
+	content := router.Group("/admin", middlewares.MustProvideAccessToken(), middlewares.RequirePermission(utils.PermissionManageContent))
+	content.GET("/regions", HandleAdminGetRegions)
+	content.POST("/regions", HandleAdminCreateRegion)
+	return router
+}
+
+// newRegionPayload creates the payload of a region in the middle of the atlantic ocean
+func newRegionPayload(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"name": name,
+		"bounds": map[string]float64{
+			"min_latitude":  -30,
+			"min_longitude": -20,
+			"max_latitude":  -29.9,
+			"max_longitude": -19.9,
+		},
+		"grid_step": 0.005,
+		"timezone":  "Atlantic/Azores",
+	}
+}
+
+// ## Tests
+
+// TestRegionsValidation tests the invalid regions are rejected
+func TestRegionsValidation(t *testing.T) {
+	c := require.New(t)
+	router := setupRegionsRouter()
+	admin, accessToken := loginWithRoles(router, utils.RoleAdmin)
+	player, playerToken := loginWithRoles(router)
+
+	// 1. Players can't register regions
+	code, _ := sendContentRequest(router, "POST", "/admin/regions", newRegionPayload("atlantic"), playerToken)
+	c.Equal(http.StatusForbidden, code)
+
+	// 2. The name is used in the zones identifiers
+	code, response := sendContentRequest(router, "POST", "/admin/regions", newRegionPayload("Atlantic Ocean"), accessToken)
+	c.Equal(http.StatusBadRequest, code)
+	c.Contains(response["message"], "Name must have")
+
+	// 3. The grid must fit in the bounds
+	payload := newRegionPayload("atlantic")
+	payload["grid_step"] = 1
+	code, response = sendContentRequest(router, "POST", "/admin/regions", payload, accessToken)
+	c.Equal(http.StatusBadRequest, code)
+	c.Contains(response["message"], "Grid step")
+
+	// 4. The timezone must exist
+	payload = newRegionPayload("atlantic")
+	payload["timezone"] = "Atlantic/Atlantis"
+	code, response = sendContentRequest(router, "POST", "/admin/regions", payload, accessToken)
+	c.Equal(http.StatusBadRequest, code)
+	c.Contains(response["message"], "Timezone")
+
+	// 5. The spawn chances must be between 0 and 1
+	payload = newRegionPayload("atlantic")
+	payload["spawn_table"] = []map[string]interface{}{{"serial": 1, "chance": 2}}
+	code, response = sendContentRequest(router, "POST", "/admin/regions", payload, accessToken)
+	c.Equal(http.StatusBadRequest, code)
+	c.Contains(response["message"], "Spawn chances")
+
+	err := tests.DeleteUser(repos, admin.Email)
+	c.NoError(err)
+	err = tests.DeleteUser(repos, player.Email)
+	c.NoError(err)
+}
+
+// TestRegionsCreate tests the regions are registered and the names and bounds can't be repeated
+func TestRegionsCreate(t *testing.T) {
+	c := require.New(t)
+	router := setupRegionsRouter()
+	admin, accessToken := loginWithRoles(router, utils.RoleAdmin)
+	defer func() {
+		for id, region := range testStore.Regions {
+			if region.Name == "atlantic" || region.Name == "atlantic-east" {
+				delete(testStore.Regions, id)
+			}
+		}
+	}()
+
+	// 1. Register a new region
+	code, response := sendContentRequest(router, "POST", "/admin/regions", newRegionPayload("atlantic"), accessToken)
+	c.Equal(http.StatusCreated, code)
+	c.Equal("atlantic", response["region"].(map[string]interface{})["name"])
+
+	// 2. The coordinates inside the bounds are resolved to the region
+	region, err := repos.Zones.GetRegionFromCoordinates(interfaces.Coordinates{Latitude: -29.95, Longitude: -19.95})
+	c.NoError(err)
+	c.Equal("atlantic", region.Name)
+
+	// 3. The names are unique
+	code, _ = sendContentRequest(router, "POST", "/admin/regions", newRegionPayload("atlantic"), accessToken)
+	c.Equal(http.StatusConflict, code)
+
+	// 4. The bounds can't overlap
+	code, response = sendContentRequest(router, "POST", "/admin/regions", newRegionPayload("atlantic-east"), accessToken)
+	c.Equal(http.StatusConflict, code)
+	c.Contains(response["message"], "overlap")
+
+	// 5. The regions are listed
+	code, response = sendContentRequest(router, "GET", "/admin/regions", nil, accessToken)
+	c.Equal(http.StatusOK, code)
+	c.NotEmpty(response["regions"])
+
+	err = tests.DeleteUser(repos, admin.Email)
+	c.NoError(err)
+}
@@ -0,0 +1,11 @@
+package controllers
+
+import "github.com/PedroChaparro/loomies-backend/repositories"
+
+// repos is the data access used by the handlers, injected by the app (See SetRepositories)
+var repos repositories.Repositories
+
+// SetRepositories Replaces the repositories used by the handlers (and the combats started by them)
+func SetRepositories(repositories repositories.Repositories) {
+	repos = repositories
+}
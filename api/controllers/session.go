@@ -5,7 +5,6 @@ import (
 	"net/mail"
 
 	"github.com/PedroChaparro/loomies-backend/interfaces"
-	"github.com/PedroChaparro/loomies-backend/models"
 	"github.com/PedroChaparro/loomies-backend/utils"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -39,7 +38,7 @@ func HandleLogIn(c *gin.Context) {
 		return
 	}
 
-	user, err = models.GetUserByEmail(form.Email)
+	user, err = repos.Users.GetUserByEmail(form.Email)
 
 	//Check if exists email
 	if err != nil {
@@ -67,8 +66,65 @@ func HandleLogIn(c *gin.Context) {
 		return
 	}
 
-	accessToken, err := utils.CreateAccessToken(user.Id.Hex())
-	refreshToken, err := utils.CreateRefreshToken(user.Id.Hex())
+	startUserSession(c, user)
+}
+
+// abortIfBanned "private" function to abort the request if the user has an active ban, returns true if it was aborted
+func abortIfBanned(c *gin.Context, user interfaces.User) bool {
+	if user.Ban.IsActive() {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": true, "message": "Your account has been banned", "reason": user.Ban.Reason, "expires_at": user.Ban.ExpiresAt})
+		return true
+	}
+
+	return false
+}
+
+// startUserSession "private" function to respond with the session tokens or with the mfa challenge
+// if the user has enabled the two-factor authentication
+func startUserSession(c *gin.Context, user interfaces.User) {
+	if abortIfBanned(c, user) {
+		return
+	}
+
+	if user.Mfa.Enabled {
+		// Only the token of the last challenge is valid
+		challengeId := utils.GetRandomUrlSafeString(16)
+		if err := repos.Mfa.StartUserMfaChallenge(user.Id, challengeId); err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+			return
+		}
+
+		mfaToken, err := utils.CreateMfaToken(config.Tokens, user.Id.Hex(), challengeId)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+			return
+		}
+
+		c.IndentedJSON(http.StatusOK, gin.H{
+			"error":    false,
+			"message":  "Two-factor authentication is required",
+			"status":   "mfa_required",
+			"mfaToken": mfaToken,
+		})
+		return
+	}
+
+	issueUserSession(c, user)
+}
+
+// issueUserSession "private" function to respond with new access and refresh tokens for the user
+func issueUserSession(c *gin.Context, user interfaces.User) {
+	accessToken, err := utils.CreateAccessToken(config.Tokens, user.Id.Hex(), user.Roles)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	refreshToken, err := utils.CreateRefreshToken(config.Tokens, user.Id.Hex())
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
 
 	c.IndentedJSON(http.StatusOK, gin.H{
 		"error":        false,
@@ -82,7 +138,7 @@ func HandleLogIn(c *gin.Context) {
 // HandleWhoami Handle the request to obtain the user's information from the access token
 func HandleWhoami(c *gin.Context) {
 	userid, _ := c.Get("userid")
-	user, err := models.GetUserById(userid.(string))
+	user, err := repos.Users.GetUserById(userid.(string))
 
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
@@ -94,10 +150,23 @@ func HandleWhoami(c *gin.Context) {
 		}
 	}
 
+	level := user.Level
+	if level == 0 {
+		level = 1
+	}
+
 	c.IndentedJSON(http.StatusOK, gin.H{
 		"error":   false,
 		"message": "Successfully retrieved user",
-		"user":    gin.H{"username": user.Username, "email": user.Email},
+		"user": gin.H{
+			"username":              user.Username,
+			"email":                 user.Email,
+			"roles":                 user.Roles,
+			"profile":               user.Profile,
+			"level":                 level,
+			"experience":            user.Experience,
+			"next_level_experience": utils.GetTrainerRequiredExperience(level+1, config.Trainer),
+		},
 	})
 }
 
@@ -105,7 +174,25 @@ func HandleWhoami(c *gin.Context) {
 func HandleRefresh(c *gin.Context) {
 	userid, _ := c.Get("userid")
 
-	accessToken, err := utils.CreateAccessToken(userid.(string))
+	// Get the user again to include its current roles in the new token
+	user, err := repos.Users.GetUserById(userid.(string))
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "User was not found"})
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	if user.Ban.IsActive() {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": true, "message": "Your account has been banned", "reason": user.Ban.Reason, "expires_at": user.Ban.ExpiresAt})
+		return
+	}
+
+	accessToken, err := utils.CreateAccessToken(config.Tokens, user.Id.Hex(), user.Roles)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
 		return
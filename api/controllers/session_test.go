@@ -6,30 +6,23 @@ import (
 	"net/http"
 	"testing"
 
-	"github.com/PedroChaparro/loomies-backend/configuration"
 	"github.com/PedroChaparro/loomies-backend/interfaces"
 	"github.com/PedroChaparro/loomies-backend/middlewares"
-	"github.com/PedroChaparro/loomies-backend/models"
 	"github.com/PedroChaparro/loomies-backend/tests"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/stretchr/testify/require"
-	"go.mongodb.org/mongo-driver/bson"
 )
 
 // ## Helper functions
 // loginWithRandomUser creates a random user, inserts it into the database, verifies it and tries to login with it
 func loginWithRandomUser() (interfaces.User, map[string]string) {
-	ctx := context.Background()
-
 	// Create a random user
 	randomUser := tests.GenerateRandomUser()
 	router := tests.SetupGinRouter()
 	tests.InsertUser(randomUser, router, HandleSignUp)
 
 	// Verify the user and save the database document
-	var databaseUser interfaces.User
-	models.UserCollection.UpdateOne(ctx, bson.D{{Key: "email", Value: randomUser.Email}}, bson.D{{Key: "$set", Value: bson.D{{Key: "isVerified", Value: true}}}})
-	models.UserCollection.FindOne(ctx, bson.D{{Key: "email", Value: randomUser.Email}}).Decode(&databaseUser)
+	databaseUser := verifyTestUser(randomUser.Email)
 
 	// Try to login with the random user
 	var response map[string]string
@@ -76,7 +69,7 @@ func TestLoginForbidden(t *testing.T) {
 	c.Equal("User has not been verified", response["message"])
 
 	// Delete the user from the database
-	err := tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err := tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
 
@@ -93,9 +86,7 @@ func TestLoginSuccess(t *testing.T) {
 	tests.InsertUser(randomUser, router, HandleSignUp)
 
 	// Verify the user and save the database document
-	models.UserCollection.UpdateOne(ctx, bson.D{{Key: "email", Value: randomUser.Email}}, bson.D{{Key: "$set", Value: bson.D{{Key: "isVerified", Value: true}}}})
-	var databaseUser interfaces.User
-	models.UserCollection.FindOne(ctx, bson.D{{Key: "email", Value: randomUser.Email}}).Decode(&databaseUser)
+	databaseUser := verifyTestUser(randomUser.Email)
 
 	// Try to login with the random user
 	loginForm := map[string]string{
@@ -119,12 +110,12 @@ func TestLoginSuccess(t *testing.T) {
 	refreshTokenClaims := jwt.MapClaims{}
 
 	_, err := jwt.ParseWithClaims(response["accessToken"], accessTokenClaims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(configuration.GetAccessTokenSecret()), nil
+		return []byte(config.Tokens.AccessTokenSecret), nil
 	})
 	c.NoError(err)
 
 	_, err = jwt.ParseWithClaims(response["refreshToken"], refreshTokenClaims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(configuration.GetRefreshTokenSecret()), nil
+		return []byte(config.Tokens.RefreshTokenSecret), nil
 	})
 	c.NoError(err)
 
@@ -132,7 +123,7 @@ func TestLoginSuccess(t *testing.T) {
 	c.Equal(databaseUser.Id.Hex(), refreshTokenClaims["userid"])
 
 	// Delete the user from the database
-	err = tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err = tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
 
@@ -178,14 +169,14 @@ func TestRefreshSuccess(t *testing.T) {
 	// 2. Check tokens claims
 	accessTokenClaims := jwt.MapClaims{}
 	_, err := jwt.ParseWithClaims(refreshResponse["accessToken"], accessTokenClaims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(configuration.GetAccessTokenSecret()), nil
+		return []byte(config.Tokens.AccessTokenSecret), nil
 	})
 
 	c.NoError(err)
 	c.Equal(databaseUser.Id.Hex(), accessTokenClaims["userid"])
 
 	// Remove the user from the database
-	err = tests.DeleteUser(databaseUser.Email, databaseUser.Id)
+	err = tests.DeleteUser(repos, databaseUser.Email)
 	c.NoError(err)
 }
 
@@ -232,6 +223,6 @@ func TestWhoamiSuccess(t *testing.T) {
 	c.Equal(databaseUser.Username, whoamiResponseUser["username"])
 
 	// Remove the user from the database
-	err := tests.DeleteUser(databaseUser.Email, databaseUser.Id)
+	err := tests.DeleteUser(repos, databaseUser.Email)
 	c.NoError(err)
 }
@@ -0,0 +1,525 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// getTradeLoomie "private" function to get the loomie offered or requested in a trade. The loomie should be owned
+// by the given user, not be busy (Eg. protecting a gym) and not be the last loomie of the team
+func getTradeLoomie(c *gin.Context, owner interfaces.User, loomieId string, notFoundMessage string) (interfaces.TradeOffer, bool) {
+	var offer interfaces.TradeOffer
+	loomieMongoId, err := primitive.ObjectIDFromHex(loomieId)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Invalid loomie id"})
+		return offer, false
+	}
+
+	loomies, err := repos.Loomies.GetLoomiesByIds([]primitive.ObjectID{loomieMongoId}, owner.Id)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return offer, false
+	}
+
+	if len(loomies) == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": notFoundMessage})
+		return offer, false
+	}
+
+	if loomies[0].IsBusy {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "Busy loomies can't be traded"})
+		return offer, false
+	}
+
+	if len(owner.LoomieTeam) == 1 && owner.LoomieTeam[0] == loomieMongoId {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": repositories.ErrTradeLastTeamLoomie.Error()})
+		return offer, false
+	}
+
+	offer.LoomieId = loomies[0].Id
+	offer.LoomieSerial = loomies[0].Serial
+	offer.LoomieName = loomies[0].Name
+	offer.LoomieLevel = loomies[0].Level
+	offer.Items = []interfaces.InventoryItem{}
+	return offer, true
+}
+
+// getTradeItems "private" function to get the items offered by the user in a trade from the inventory
+func getTradeItems(c *gin.Context, owner interfaces.User, items []interfaces.TradeItemReq) ([]interfaces.InventoryItem, bool) {
+	offered := []interfaces.InventoryItem{}
+	quantities := make(map[primitive.ObjectID]int)
+
+	for _, item := range items {
+		itemId, err := primitive.ObjectIDFromHex(item.ItemId)
+
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Invalid item id"})
+			return offered, false
+		}
+
+		if item.Quantity <= 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Items quantity should be greater than 0"})
+			return offered, false
+		}
+
+		quantities[itemId] += item.Quantity
+	}
+
+	for _, inventoryItem := range owner.Items {
+		quantity, ok := quantities[inventoryItem.ItemId]
+		if !ok {
+			continue
+		}
+
+		if quantity > inventoryItem.ItemQuantity {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "You don't have enough items to offer"})
+			return offered, false
+		}
+
+		offered = append(offered, interfaces.InventoryItem{
+			ItemCollection: inventoryItem.ItemCollection,
+			ItemId:         inventoryItem.ItemId,
+			ItemQuantity:   quantity,
+		})
+	}
+
+	if len(offered) != len(quantities) {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "You don't have enough items to offer"})
+		return offered, false
+	}
+
+	return offered, true
+}
+
+// getTradeOffers "private" function to get the offer of the session user and the loomie requested to the other user
+func getTradeOffers(c *gin.Context, user interfaces.User, other interfaces.User, form interfaces.TradeOfferReq) (interfaces.TradeOffer, interfaces.TradeOffer, bool) {
+	if strings.TrimSpace(form.LoomieId) == "" || strings.TrimSpace(form.RequestedLoomieId) == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Both loomies ids are required"})
+		return interfaces.TradeOffer{}, interfaces.TradeOffer{}, false
+	}
+
+	offer, ok := getTradeLoomie(c, user, form.LoomieId, "You should own the offered loomie")
+	if !ok {
+		return offer, interfaces.TradeOffer{}, false
+	}
+
+	offer.Items, ok = getTradeItems(c, user, form.Items)
+	if !ok {
+		return offer, interfaces.TradeOffer{}, false
+	}
+
+	requested, ok := getTradeLoomie(c, other, form.RequestedLoomieId, "The requested loomie was not found")
+	return offer, requested, ok
+}
+
+// getTrade "private" function to get the active trade with the id from the path if the user participates in it
+func getTrade(c *gin.Context, user interfaces.User) (interfaces.Trade, bool) {
+	tradeId, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Invalid trade id"})
+		return interfaces.Trade{}, false
+	}
+
+	trade, err := repos.Trades.GetTradeById(user.Id, tradeId)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "Trade was not found"})
+			return trade, false
+		}
+
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return trade, false
+	}
+
+	if trade.Status != repositories.TradeProposed && trade.Status != repositories.TradeAccepted {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "Trade is no longer active"})
+		return trade, false
+	}
+
+	if trade.ExpiresAt <= time.Now().Unix() {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "Trade has expired"})
+		return trade, false
+	}
+
+	return trade, true
+}
+
+// getTradesResponse "private" function to add the usernames of the players to the trades. The usernames of the
+// blocked (or deleted) users are left empty
+func getTradesResponse(user interfaces.User, trades []interfaces.Trade) ([]interfaces.TradeRes, error) {
+	response := []interfaces.TradeRes{}
+	ids := []primitive.ObjectID{}
+
+	for _, trade := range trades {
+		ids = append(ids, trade.ProposerId, trade.RecipientId)
+	}
+
+	users, err := repos.Users.GetUsersByIds(ids)
+	if err != nil {
+		return response, err
+	}
+
+	usernames := make(map[primitive.ObjectID]string)
+	for _, other := range users {
+		if other.Id == user.Id || !utils.IsBlockedBetween(user, other) {
+			usernames[other.Id] = other.Username
+		}
+	}
+
+	for _, trade := range trades {
+		response = append(response, interfaces.TradeRes{
+			Trade:             trade,
+			ProposerUsername:  usernames[trade.ProposerId],
+			RecipientUsername: usernames[trade.RecipientId],
+		})
+	}
+
+	return response, nil
+}
+
+// HandleProposeTrade Handle the request to propose a trade of a loomie (and optional items) for a loomie of other user
+func HandleProposeTrade(c *gin.Context) {
+	var form interfaces.ProposeTradeReq
+
+	if err := c.BindJSON(&form); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Bad request"})
+		return
+	}
+
+	if strings.TrimSpace(form.Username) == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Username cannot be empty"})
+		return
+	}
+
+	user, ok := getSessionUser(c)
+	if !ok || rejectMutedUser(c, user) {
+		return
+	}
+
+	recipient, ok := getVisibleUser(c, user, form.Username)
+	if !ok {
+		return
+	}
+
+	if recipient.Id == user.Id {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "You can't trade with yourself"})
+		return
+	}
+
+	offer, requested, ok := getTradeOffers(c, user, recipient, form.TradeOfferReq)
+	if !ok {
+		return
+	}
+
+	settings := config.Trade
+	now := time.Now().Unix()
+
+	trade, err := repos.Trades.CreateTrade(c, interfaces.Trade{
+		ProposerId:     user.Id,
+		RecipientId:    recipient.Id,
+		ProposerOffer:  offer,
+		RecipientOffer: requested,
+		Status:         repositories.TradeProposed,
+		PendingUserId:  recipient.Id,
+		Confirmations:  []interfaces.TradeConfirmation{},
+		MaxDistance:    settings.MaxDistance,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		ExpiresAt:      now + settings.TradeTTL*60,
+	})
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusCreated, gin.H{"error": false, "message": "Trade was proposed successfully", "trade": trade})
+}
+
+// HandleCounterTrade Handle the request to replace the offers of a proposed trade. The user changes the own offer
+// and the requested loomie, the items offered by the other user are kept
+func HandleCounterTrade(c *gin.Context) {
+	var form interfaces.TradeOfferReq
+
+	if err := c.BindJSON(&form); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Bad request"})
+		return
+	}
+
+	user, ok := getSessionUser(c)
+	if !ok || rejectMutedUser(c, user) {
+		return
+	}
+
+	trade, ok := getTrade(c, user)
+	if !ok {
+		return
+	}
+
+	if trade.Status != repositories.TradeProposed {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "Trade was already accepted"})
+		return
+	}
+
+	if trade.PendingUserId != user.Id {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": true, "message": "You should wait for the other trainer to answer"})
+		return
+	}
+
+	otherId := trade.ProposerId
+	if user.Id == trade.ProposerId {
+		otherId = trade.RecipientId
+	}
+
+	other, err := repos.Users.GetUserById(otherId.Hex())
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	offer, requested, ok := getTradeOffers(c, user, other, form)
+	if !ok {
+		return
+	}
+
+	proposerOffer, recipientOffer := offer, requested
+	if user.Id == trade.ProposerId {
+		recipientOffer.Items = trade.RecipientOffer.Items
+	} else {
+		proposerOffer, recipientOffer = requested, offer
+		proposerOffer.Items = trade.ProposerOffer.Items
+	}
+
+	expiresAt := time.Now().Unix() + config.Trade.TradeTTL*60
+	updated, err := repos.Trades.CounterTrade(c, trade, user.Id, proposerOffer, recipientOffer, expiresAt)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	if !updated {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "Trade was updated by the other trainer"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"error": false, "message": "Trade was countered successfully"})
+}
+
+// HandleAcceptTrade Handle the request to accept the current offers of a trade
+func HandleAcceptTrade(c *gin.Context) {
+	user, ok := getSessionUser(c)
+	if !ok {
+		return
+	}
+
+	trade, ok := getTrade(c, user)
+	if !ok {
+		return
+	}
+
+	if trade.Status != repositories.TradeProposed {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "Trade was already accepted"})
+		return
+	}
+
+	if trade.PendingUserId != user.Id {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": true, "message": "You should wait for the other trainer to answer"})
+		return
+	}
+
+	accepted, err := repos.Trades.AcceptTrade(c, trade, user.Id)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	if !accepted {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "Trade was updated by the other trainer"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"error": false, "message": "Trade was accepted successfully"})
+}
+
+// HandleDeclineTrade Handle the request to decline (when the user has to answer) or cancel an active trade
+func HandleDeclineTrade(c *gin.Context) {
+	user, ok := getSessionUser(c)
+	if !ok {
+		return
+	}
+
+	trade, ok := getTrade(c, user)
+	if !ok {
+		return
+	}
+
+	status, message := repositories.TradeCancelled, "Trade was cancelled successfully"
+	if trade.Status == repositories.TradeProposed && trade.PendingUserId == user.Id {
+		status, message = repositories.TradeDeclined, "Trade was declined successfully"
+	}
+
+	closed, err := repos.Trades.CloseTrade(c, trade, user.Id, status)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	if !closed {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "Trade was updated by the other trainer"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"error": false, "message": message})
+}
+
+// HandleConfirmTrade Handle the request to confirm an accepted trade from the current location. The loomies and
+// items are swapped when both players confirm the trade near each other within the confirmation timeout
+func HandleConfirmTrade(c *gin.Context) {
+	var form interfaces.ConfirmTradeReq
+
+	if err := c.BindJSON(&form); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "Bad request"})
+		return
+	}
+
+	user, ok := getSessionUser(c)
+	if !ok {
+		return
+	}
+
+	trade, ok := getTrade(c, user)
+	if !ok {
+		return
+	}
+
+	if trade.Status != repositories.TradeAccepted {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "Trade should be accepted before confirming it"})
+		return
+	}
+
+	confirmation := interfaces.TradeConfirmation{
+		UserId:      user.Id,
+		Coordinates: interfaces.Coordinates{Latitude: form.Latitude, Longitude: form.Longitude},
+		ConfirmedAt: time.Now().Unix(),
+	}
+
+	// Check the confirmation of the other player (if it's recent) was made nearby
+	timeout := config.Trade.ConfirmationTimeout * 60
+	otherConfirmed := false
+
+	for _, previous := range trade.Confirmations {
+		if previous.UserId == user.Id || confirmation.ConfirmedAt-previous.ConfirmedAt > timeout {
+			continue
+		}
+
+		if !utils.IsWithinDistance(confirmation.Coordinates, previous.Coordinates, trade.MaxDistance) {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "You are too far from the other trainer"})
+			return
+		}
+
+		otherConfirmed = true
+	}
+
+	if !otherConfirmed {
+		stored, err := repos.Trades.ConfirmTrade(c, trade, confirmation)
+
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+			return
+		}
+
+		if !stored {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "Trade was updated by the other trainer"})
+			return
+		}
+
+		c.IndentedJSON(http.StatusAccepted, gin.H{"error": false, "message": "Trade was confirmed, waiting for the other trainer"})
+		return
+	}
+
+	trade, err := repos.Trades.CompleteTrade(c, trade, user.Id)
+
+	if err != nil {
+		if errors.Is(err, repositories.ErrTradeNotAccepted) {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "Trade was updated by the other trainer"})
+			return
+		}
+
+		// The offers are no longer valid, so the trade can't be completed anymore
+		if errors.Is(err, repositories.ErrTradeLoomieUnavailable) || errors.Is(err, repositories.ErrTradeItemsUnavailable) || errors.Is(err, repositories.ErrTradeLastTeamLoomie) {
+			repos.Trades.CloseTrade(c, trade, user.Id, repositories.TradeCancelled)
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": err.Error()})
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"error": false, "message": "Trade was completed successfully", "trade": trade})
+}
+
+// HandleGetTrades Handle the request to get the active trades of the user
+func HandleGetTrades(c *gin.Context) {
+	user, ok := getSessionUser(c)
+	if !ok {
+		return
+	}
+
+	trades, err := repos.Trades.GetActiveTrades(user.Id)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	response, err := getTradesResponse(user, trades)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"error": false, "message": "Trades were retrieved successfully", "trades": response})
+}
+
+// HandleGetTradesHistory Handle the request to get the completed, declined, cancelled and expired trades of the user
+func HandleGetTradesHistory(c *gin.Context) {
+	user, ok := getSessionUser(c)
+	if !ok {
+		return
+	}
+
+	trades, err := repos.Trades.GetTradesHistory(user.Id)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	response, err := getTradesResponse(user, trades)
+
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"error": false, "message": "Trades history was retrieved successfully", "trades": response})
+}
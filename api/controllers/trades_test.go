@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/middlewares"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"github.com/PedroChaparro/loomies-backend/tests"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ## Helper functions
+// setupTradesRouter creates a router with the trades endpoints
+func setupTradesRouter() *gin.Engine {
+	router := tests.SetupGinRouter()
+	router.POST("/session/login", HandleLogIn)
+	router.GET("/trades", middlewares.MustProvideAccessToken(), HandleGetTrades)
+	router.POST("/trades", middlewares.MustProvideAccessToken(), HandleProposeTrade)
+	router.GET("/trades/history", middlewares.MustProvideAccessToken(), HandleGetTradesHistory)
+	router.POST("/trades/:id/counter", middlewares.MustProvideAccessToken(), HandleCounterTrade)
+	router.POST("/trades/:id/accept", middlewares.MustProvideAccessToken(), HandleAcceptTrade)
+	router.POST("/trades/:id/decline", middlewares.MustProvideAccessToken(), HandleDeclineTrade)
+	router.POST("/trades/:id/confirm", middlewares.MustProvideAccessToken(), HandleConfirmTrade)
+	return router
+}
+
+// giveTradeLoomies creates loomies for the user, the first ones are added to the loomie team
+func giveTradeLoomies(user interfaces.User, count int, teamSize int) []primitive.ObjectID {
+	ids := insertTestLoomies(user.Id, 1, count, false)
+	updateTestUser(user.Id, func(user *interfaces.User) {
+		user.Loomies = ids
+		user.LoomieTeam = ids[:teamSize]
+	})
+
+	return ids
+}
+
+// ## Tests
+
+// TestTrades tests the trades can be proposed, countered, accepted and confirmed swapping the loomies and items
+func TestTrades(t *testing.T) {
+	c := require.New(t)
+	router := setupTradesRouter()
+	user, accessToken := loginWithRoles(router)
+	other, otherToken := loginWithRoles(router)
+
+	userLoomies := giveTradeLoomies(user, 2, 2)
+	otherLoomies := giveTradeLoomies(other, 2, 1)
+
+	item := getTestItemBySerial(1)
+	addTestInventoryItem(user.Id, repositories.ItemsContent, item.Id, 2)
+
+	propose := func(loomieId primitive.ObjectID, requestedId primitive.ObjectID, items []gin.H) (int, map[string]interface{}) {
+		return sendContentRequest(router, "POST", "/trades", gin.H{
+			"username":            other.Username,
+			"loomie_id":           loomieId.Hex(),
+			"requested_loomie_id": requestedId.Hex(),
+			"items":               items,
+		}, accessToken)
+	}
+
+	// 1. The last loomie of the team, the busy loomies and the missing items can't be traded
+	code, response := propose(userLoomies[0], otherLoomies[0], nil)
+	c.Equal(http.StatusConflict, code)
+	c.Equal("The last loomie of the team can't be traded", response["message"])
+
+	updateTestLoomies(userLoomies[1:2], func(loomie *interfaces.CaughtLoomie) { loomie.IsBusy = true })
+	code, response = propose(userLoomies[1], otherLoomies[1], nil)
+	c.Equal(http.StatusConflict, code)
+	c.Equal("Busy loomies can't be traded", response["message"])
+	updateTestLoomies(userLoomies[1:2], func(loomie *interfaces.CaughtLoomie) { loomie.IsBusy = false })
+
+	code, response = propose(userLoomies[0], otherLoomies[1], []gin.H{{"item_id": item.Id.Hex(), "quantity": 3}})
+	c.Equal(http.StatusConflict, code)
+	c.Equal("You don't have enough items to offer", response["message"])
+
+	// 2. Propose the trade, only the recipient can answer it
+	code, response = propose(userLoomies[0], otherLoomies[1], []gin.H{{"item_id": item.Id.Hex(), "quantity": 1}})
+	c.Equal(http.StatusCreated, code)
+	tradeId := response["trade"].(map[string]interface{})["_id"].(string)
+
+	code, _ = sendContentRequest(router, "POST", "/trades/"+tradeId+"/accept", nil, accessToken)
+	c.Equal(http.StatusForbidden, code)
+
+	// 3. Counter the trade requesting other loomie, the offered items are kept
+	code, _ = sendContentRequest(router, "POST", "/trades/"+tradeId+"/counter", gin.H{
+		"loomie_id":           otherLoomies[1].Hex(),
+		"requested_loomie_id": userLoomies[1].Hex(),
+	}, otherToken)
+	c.Equal(http.StatusOK, code)
+
+	_, response = getWithToken(router, "/trades", accessToken)
+	trades := response["trades"].([]interface{})
+	c.Equal(1, len(trades))
+	trade := trades[0].(map[string]interface{})
+	c.Equal(other.Username, trade["recipient_username"])
+	c.Equal(userLoomies[1].Hex(), trade["proposer_offer"].(map[string]interface{})["loomie_id"])
+	c.Equal(1, len(trade["proposer_offer"].(map[string]interface{})["items"].([]interface{})))
+
+	// 4. Accept and confirm the trade, the players should be near each other
+	code, _ = sendContentRequest(router, "POST", "/trades/"+tradeId+"/accept", nil, accessToken)
+	c.Equal(http.StatusOK, code)
+
+	code, _ = sendContentRequest(router, "POST", "/trades/"+tradeId+"/confirm", gin.H{"latitude": 7.1, "longitude": -73.1}, accessToken)
+	c.Equal(http.StatusAccepted, code)
+
+	code, response = sendContentRequest(router, "POST", "/trades/"+tradeId+"/confirm", gin.H{"latitude": 8.1, "longitude": -73.1}, otherToken)
+	c.Equal(http.StatusConflict, code)
+	c.Equal("You are too far from the other trainer", response["message"])
+
+	code, _ = sendContentRequest(router, "POST", "/trades/"+tradeId+"/confirm", gin.H{"latitude": 7.1, "longitude": -73.1}, otherToken)
+	c.Equal(http.StatusOK, code)
+
+	// 5. Check the loomies and the items were swapped
+	updatedUser, err := repos.Users.GetUserById(user.Id.Hex())
+	c.NoError(err)
+	updatedOther, err := repos.Users.GetUserById(other.Id.Hex())
+	c.NoError(err)
+
+	c.ElementsMatch([]primitive.ObjectID{userLoomies[0], otherLoomies[1]}, updatedUser.Loomies)
+	c.ElementsMatch([]primitive.ObjectID{userLoomies[0]}, updatedUser.LoomieTeam)
+	c.ElementsMatch([]primitive.ObjectID{otherLoomies[0], userLoomies[1]}, updatedOther.Loomies)
+
+	c.Equal(other.Id, testStore.CaughtLoomies[userLoomies[1]].Owner)
+
+	c.Equal(1, len(updatedUser.Items))
+	c.Equal(1, updatedUser.Items[0].ItemQuantity)
+	c.Equal(1, len(updatedOther.Items))
+	c.Equal(1, updatedOther.Items[0].ItemQuantity)
+
+	// 6. The completed trade is in the history and can't be confirmed again
+	_, response = getWithToken(router, "/trades/history", otherToken)
+	c.Equal(repositories.TradeCompleted, response["trades"].([]interface{})[0].(map[string]interface{})["status"])
+
+	code, _ = sendContentRequest(router, "POST", "/trades/"+tradeId+"/confirm", gin.H{"latitude": 7.1, "longitude": -73.1}, otherToken)
+	c.Equal(http.StatusConflict, code)
+
+	// 7. Decline a new trade
+	code, response = propose(userLoomies[0], userLoomies[1], nil)
+	c.Equal(http.StatusConflict, code)
+	c.Equal("The last loomie of the team can't be traded", response["message"])
+
+	code, response = propose(otherLoomies[1], userLoomies[1], nil)
+	c.Equal(http.StatusCreated, code)
+	tradeId = response["trade"].(map[string]interface{})["_id"].(string)
+
+	code, response = sendContentRequest(router, "POST", "/trades/"+tradeId+"/decline", nil, otherToken)
+	c.Equal(http.StatusOK, code)
+	c.Equal("Trade was declined successfully", response["message"])
+
+	_, response = getWithToken(router, "/trades", accessToken)
+	c.Empty(response["trades"])
+
+	tests.DeleteUser(repos, user.Email)
+	tests.DeleteUser(repos, other.Email)
+}
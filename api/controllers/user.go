@@ -5,8 +5,9 @@ import (
 	"net/http"
 	"net/mail"
 
+	"github.com/PedroChaparro/loomies-backend/email"
 	"github.com/PedroChaparro/loomies-backend/interfaces"
-	"github.com/PedroChaparro/loomies-backend/models"
+	"github.com/PedroChaparro/loomies-backend/repositories"
 	"github.com/PedroChaparro/loomies-backend/utils"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -57,7 +58,7 @@ func HandleSignUp(c *gin.Context) {
 		return
 	}
 
-	_, err = models.GetUserByEmail(form.Email)
+	_, err = repos.Users.GetUserByEmail(form.Email)
 
 	//Check if exists email
 	if !(err != nil) {
@@ -68,7 +69,7 @@ func HandleSignUp(c *gin.Context) {
 		}
 	}
 
-	_, err = models.GetUserByUsername(form.Username)
+	_, err = repos.Users.GetUserByUsername(form.Username)
 
 	//Check if exists username
 	if !(err != nil) {
@@ -90,7 +91,7 @@ func HandleSignUp(c *gin.Context) {
 	// Generate validation code
 	validationCode := utils.GetValidationCode()
 
-	err = models.UpdateAccountVerificationCode(form.Email, validationCode)
+	err = repos.Accounts.UpdateAccountVerificationCode(form.Email, validationCode)
 
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Unable to create user. Please try again later"})
@@ -98,12 +99,18 @@ func HandleSignUp(c *gin.Context) {
 	}
 
 	// Creathe the user doc
+	// Use the language of the device if it was not given
+	if form.Language == "" {
+		form.Language = c.GetHeader("Accept-Language")
+	}
+
 	data := interfaces.User{Username: form.Username,
 		Email:      form.Email,
+		Language:   email.NormalizeLanguage(form.Language),
 		Password:   string(hashed),
 		IsVerified: false}
 
-	err = models.InsertUser(data)
+	err = repos.Users.InsertUser(c, data)
 
 	if err != nil {
 		fmt.Println(err)
@@ -112,7 +119,11 @@ func HandleSignUp(c *gin.Context) {
 	}
 
 	//send mail of verification
-	err = utils.SendEmail(form.Email, "Here is your validation code", validationCode)
+	err = mailer.Send(form.Email, data.Language, email.VerificationTemplate, email.CodeData{
+		Username:         data.Username,
+		Code:             validationCode,
+		ExpiresInMinutes: repositories.AuthenticationCodeMinutes,
+	})
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
 		return
@@ -143,7 +154,7 @@ func HandleAccountValidation(c *gin.Context) {
 	}
 
 	// Check the code
-	exists := models.CompareAccountVerificationCode(form.Email, form.ValidationCode)
+	exists := repos.Accounts.CompareAccountVerificationCode(form.Email, form.ValidationCode)
 	if exists {
 		c.IndentedJSON(http.StatusOK, gin.H{"error": false, "message": "Email has been verified"})
 		return
@@ -168,7 +179,7 @@ func HandleAccountValidationCodeRequest(c *gin.Context) {
 		return
 	}
 
-	userDoc, err := models.GetUserByEmail(form.Email)
+	userDoc, err := repos.Users.GetUserByEmail(form.Email)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "This Email has not been registered"})
 		return
@@ -183,7 +194,7 @@ func HandleAccountValidationCodeRequest(c *gin.Context) {
 	validationCode := utils.GetValidationCode()
 
 	//update in database
-	err = models.UpdateAccountVerificationCode(form.Email, validationCode)
+	err = repos.Accounts.UpdateAccountVerificationCode(form.Email, validationCode)
 
 	if err != nil {
 		fmt.Println(err)
@@ -192,7 +203,11 @@ func HandleAccountValidationCodeRequest(c *gin.Context) {
 	}
 
 	//send mail of verification
-	err = utils.SendEmail(form.Email, "Here is validation code requested", validationCode)
+	err = mailer.Send(form.Email, userDoc.Language, email.VerificationTemplate, email.CodeData{
+		Username:         userDoc.Username,
+		Code:             validationCode,
+		ExpiresInMinutes: repositories.AuthenticationCodeMinutes,
+	})
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
 		return
@@ -205,7 +220,7 @@ func HandleAccountValidationCodeRequest(c *gin.Context) {
 func HandleGetLoomies(c *gin.Context) {
 	userid, _ := c.Get("userid")
 
-	user, err := models.GetUserById(userid.(string))
+	user, err := repos.Users.GetUserById(userid.(string))
 
 	// user exists or not
 	if err != nil {
@@ -218,7 +233,7 @@ func HandleGetLoomies(c *gin.Context) {
 		}
 	}
 
-	loomies, err := models.GetLoomiesByIds(user.Loomies, user.Id)
+	loomies, err := repos.Loomies.GetLoomiesByIds(user.Loomies, user.Id)
 
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
@@ -247,7 +262,7 @@ func HandleResetPasswordCodeRequest(c *gin.Context) {
 		return
 	}
 
-	_, err := models.GetUserByEmail(form.Email)
+	userDoc, err := repos.Users.GetUserByEmail(form.Email)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": true, "message": "This Email has not been registered"})
 		return
@@ -256,14 +271,18 @@ func HandleResetPasswordCodeRequest(c *gin.Context) {
 	resetPasswordCode := utils.GetValidationCode()
 
 	//update in database reset password code
-	err = models.UpdatePasswordResetCode(form.Email, resetPasswordCode)
+	err = repos.Accounts.UpdatePasswordResetCode(form.Email, resetPasswordCode)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
 		return
 	}
 
 	//send mail with code to help reset password
-	err = utils.SendEmail(form.Email, "Here is your validation code, to reset your password", resetPasswordCode)
+	err = mailer.Send(form.Email, userDoc.Language, email.PasswordResetTemplate, email.CodeData{
+		Username:         userDoc.Username,
+		Code:             resetPasswordCode,
+		ExpiresInMinutes: repositories.AuthenticationCodeMinutes,
+	})
 
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
@@ -313,7 +332,7 @@ func HandleResetPassword(c *gin.Context) {
 	}
 
 	// code validation
-	match := models.ComparePasswordResetCode(form.Email, form.ResetPassCode)
+	match := repos.Accounts.ComparePasswordResetCode(form.Email, form.ResetPassCode)
 
 	if match {
 		//encrypt password
@@ -324,7 +343,7 @@ func HandleResetPassword(c *gin.Context) {
 			return
 		}
 
-		err = models.UpdatePasword(form.Email, string(hashed))
+		err = repos.Accounts.UpdatePassword(c, form.Email, string(hashed))
 
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
@@ -342,7 +361,7 @@ func HandleResetPassword(c *gin.Context) {
 func HandleGetLoomieTeam(c *gin.Context) {
 	// Get the user
 	userid, _ := c.Get("userid")
-	user, err := models.GetUserById(userid.(string))
+	user, err := repos.Users.GetUserById(userid.(string))
 
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error getting the user"})
@@ -350,7 +369,7 @@ func HandleGetLoomieTeam(c *gin.Context) {
 	}
 
 	// Get the loomies details from the LoomieTeam array
-	loomies, err := models.GetLoomiesByIds(user.LoomieTeam, user.Id)
+	loomies, err := repos.Loomies.GetLoomiesByIds(user.LoomieTeam, user.Id)
 
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error getting the loomies"})
@@ -401,7 +420,7 @@ func HandleUpdateLoomieTeam(c *gin.Context) {
 	}
 
 	// Check the user owns all the loomies
-	loomies, err := models.GetLoomiesByIds(loomiesMongoIds, userIdMongo)
+	loomies, err := repos.Loomies.GetLoomiesByIds(loomiesMongoIds, userIdMongo)
 
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error getting the loomies. Please try again later"})
@@ -422,7 +441,7 @@ func HandleUpdateLoomieTeam(c *gin.Context) {
 	}
 
 	// Update the loomie team
-	err = models.ReplaceLoomieTeam(userIdMongo, loomiesMongoIds)
+	err = repos.Users.ReplaceLoomieTeam(c, userIdMongo, loomiesMongoIds)
 
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error updating the loomie team. Please try again later"})
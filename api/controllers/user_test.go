@@ -10,12 +10,8 @@ import (
 
 	"github.com/PedroChaparro/loomies-backend/interfaces"
 	"github.com/PedroChaparro/loomies-backend/middlewares"
-	"github.com/PedroChaparro/loomies-backend/models"
 	"github.com/PedroChaparro/loomies-backend/tests"
 	"github.com/stretchr/testify/require"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // ### Tests ###
@@ -48,7 +44,7 @@ func TestSignupSuccessAndConflict(t *testing.T) {
 
 	// Check if user was created in the database
 	var user interfaces.User
-	err = models.UserCollection.FindOne(ctx, bson.D{{Key: "email", Value: payload.Email}}).Decode(&user)
+	user, err = repos.Users.GetUserByEmail(payload.Email)
 	c.NoError(err)
 	c.Equal(payload.Email, user.Email)
 	c.Equal(payload.Username, user.Username)
@@ -68,7 +64,6 @@ func TestSignupSuccessAndConflict(t *testing.T) {
 	// 3. Conflict request with the same username
 	// -------------------------
 	oldEmail := payload.Email
-	oldId := payload.Id
 	payload.Email = tests.FakerInstance.Internet().Email()
 	w, req = tests.SetupPayloadedRequest("/user/signup", "POST", payload)
 	router.ServeHTTP(w, req)
@@ -79,9 +74,9 @@ func TestSignupSuccessAndConflict(t *testing.T) {
 	c.Equal("Username already exists", response["message"])
 
 	// Delete users
-	err = tests.DeleteUser(oldEmail, oldId)
+	err = tests.DeleteUser(repos, oldEmail)
 	c.NoError(err)
-	err = tests.DeleteUser(payload.Email, payload.Id)
+	err = tests.DeleteUser(repos, payload.Email)
 	c.NoError(err)
 }
 
@@ -109,7 +104,7 @@ func TestAccountValidationCodeSuccess(t *testing.T) {
 	c.Equal("New Code created and sended", response["message"])
 
 	// Delete user
-	err := tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err := tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
 
@@ -162,7 +157,7 @@ func TestAccountValidationCodeBadRequest(t *testing.T) {
 	// -------------------------
 	// 4. Test with verified email
 	// -------------------------
-	models.UserCollection.UpdateOne(ctx, bson.D{{Key: "email", Value: randomUser.Email}}, bson.D{{Key: "$set", Value: bson.D{{Key: "isVerified", Value: true}}}})
+	verifyTestUser(randomUser.Email)
 	w, req = tests.SetupPayloadedRequest("/user/validate/code", "POST", map[string]string{"email": randomUser.Email})
 	router.ServeHTTP(w, req)
 	json.Unmarshal(w.Body.Bytes(), &response)
@@ -172,7 +167,7 @@ func TestAccountValidationCodeBadRequest(t *testing.T) {
 	c.Equal("This Email has been already verified", response["message"])
 
 	// Delete user
-	err := tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err := tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
 
@@ -190,7 +185,7 @@ func TestAccountValidationSuccess(t *testing.T) {
 
 	// Get the code from the database
 	var code interfaces.AuthenticationCode
-	err := models.AuthenticationCodesCollection.FindOne(ctx, bson.D{{Key: "email", Value: randomUser.Email}, {Key: "type", Value: "ACCOUNT_VERIFICATION"}}).Decode(&code)
+	code, err := getTestAuthenticationCode(randomUser.Email, "ACCOUNT_VERIFICATION")
 	c.NoError(err)
 
 	// Make the request and get the JSON response
@@ -205,7 +200,7 @@ func TestAccountValidationSuccess(t *testing.T) {
 	c.Equal("Email has been verified", response["message"])
 
 	// Delete user
-	err = tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err = tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
 
@@ -223,7 +218,7 @@ func TestAccountValidationBadRequest(t *testing.T) {
 
 	// Get the code from the database
 	var code interfaces.AuthenticationCode
-	err := models.AuthenticationCodesCollection.FindOne(ctx, bson.D{{Key: "email", Value: randomUser.Email}, {Key: "type", Value: "ACCOUNT_VERIFICATION"}}).Decode(&code)
+	code, err := getTestAuthenticationCode(randomUser.Email, "ACCOUNT_VERIFICATION")
 	c.NoError(err)
 	codeNumber, _ := strconv.Atoi(code.Code)
 
@@ -273,7 +268,7 @@ func TestAccountValidationBadRequest(t *testing.T) {
 	c.Equal("Code was incorrect or time has expired", response["message"])
 
 	// Remove the user
-	err = tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err = tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
 
@@ -290,8 +285,7 @@ func TestPasswordResetCodeSuccess(t *testing.T) {
 	tests.InsertUser(randomUser, router, HandleSignUp)
 
 	// Verify the user directly on the database
-	_, err := models.UserCollection.UpdateOne(ctx, bson.D{{Key: "email", Value: randomUser.Email}}, bson.D{{Key: "$set", Value: bson.D{{Key: "isVerified", Value: true}}}})
-	c.NoError(err)
+	verifyTestUser(randomUser.Email)
 
 	// Make the request and get the JSON response
 	router.POST("/user/password/code", HandleResetPasswordCodeRequest)
@@ -305,7 +299,7 @@ func TestPasswordResetCodeSuccess(t *testing.T) {
 	c.Equal("New Code, to reset password, created and sended", response["message"])
 
 	// Remove the user
-	err = tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err := tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
 
@@ -365,8 +359,7 @@ func TestPasswordResetSuccess(t *testing.T) {
 	tests.InsertUser(randomUser, router, HandleSignUp)
 
 	// Verify the user directly on the database
-	_, err := models.UserCollection.UpdateOne(ctx, bson.D{{Key: "email", Value: randomUser.Email}}, bson.D{{Key: "$set", Value: bson.D{{Key: "isVerified", Value: true}}}})
-	c.NoError(err)
+	verifyTestUser(randomUser.Email)
 
 	// Send a request to get a new password reset code
 	router.POST("/user/password/code", HandleResetPasswordCodeRequest)
@@ -376,8 +369,7 @@ func TestPasswordResetSuccess(t *testing.T) {
 	c.Equal(http.StatusOK, w.Code)
 
 	// Get the code from the database
-	var passwordResetCode interfaces.AuthenticationCode
-	err = models.AuthenticationCodesCollection.FindOne(ctx, bson.D{{Key: "email", Value: randomUser.Email}, {Key: "type", Value: "RESET_PASSWORD"}}).Decode(&passwordResetCode)
+	passwordResetCode, err := getTestAuthenticationCode(randomUser.Email, "RESET_PASSWORD")
 	c.NoError(err)
 
 	// -------------------------
@@ -415,7 +407,7 @@ func TestPasswordResetSuccess(t *testing.T) {
 	c.Equal("Wrong Email/Password", response["message"])
 
 	// Remove the user
-	err = tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err = tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
 
@@ -432,8 +424,7 @@ func TestPasswordResetBadRequest(t *testing.T) {
 	tests.InsertUser(randomUser, router, HandleSignUp)
 
 	// Verify the user directly on the database
-	_, err := models.UserCollection.UpdateOne(ctx, bson.D{{Key: "email", Value: randomUser.Email}}, bson.D{{Key: "$set", Value: bson.D{{Key: "isVerified", Value: true}}}})
-	c.NoError(err)
+	verifyTestUser(randomUser.Email)
 
 	// Send a request to get a new password reset code
 	router.POST("/user/password/code", HandleResetPasswordCodeRequest)
@@ -443,8 +434,7 @@ func TestPasswordResetBadRequest(t *testing.T) {
 	c.Equal(http.StatusOK, w.Code)
 
 	// Get the code from the database
-	var passwordResetCode interfaces.AuthenticationCode
-	err = models.AuthenticationCodesCollection.FindOne(ctx, bson.D{{Key: "email", Value: randomUser.Email}, {Key: "type", Value: "RESET_PASSWORD"}}).Decode(&passwordResetCode)
+	passwordResetCode, err := getTestAuthenticationCode(randomUser.Email, "RESET_PASSWORD")
 	c.NoError(err)
 
 	// -------------------------
@@ -539,7 +529,7 @@ func TestPasswordResetBadRequest(t *testing.T) {
 	c.Equal("Password must have at least one special character", response["message"])
 
 	// Remove the user
-	err = tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err = tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
 
@@ -570,27 +560,8 @@ func TestGetLoomiesSuccess(t *testing.T) {
 	// -------------------------
 	// 2. Test with some loomies
 	// -------------------------
-	// Get the first 6 loomies from the caught_loomies collection
-	var loomies []interfaces.CaughtLoomie
-	var loomiesIds []primitive.ObjectID
-	cursor, _ := models.CaughtLoomiesCollection.Find(ctx, bson.D{}, options.Find().SetLimit(6).SetSort(bson.D{{Key: "_id", Value: -1}}))
-	cursor.All(ctx, &loomies)
-	c.Equal(6, len(loomies))
-
-	// Get the ids of the loomies
-	for _, loomie := range loomies {
-		loomiesIds = append(loomiesIds, loomie.Id)
-	}
-
-	// Insert the loomies into the user's loomies
-	models.UserCollection.UpdateOne(ctx, bson.D{{Key: "email", Value: randomUser.Email}}, bson.D{{Key: "$set", Value: bson.D{{Key: "loomies", Value: loomiesIds}}}})
-
-	// Set the user as the loomies in the array owner
-	models.CaughtLoomiesCollection.UpdateMany(ctx, bson.D{
-		{Key: "_id", Value: bson.D{{Key: "$in", Value: loomiesIds}}},
-	}, bson.D{
-		{Key: "$set", Value: bson.D{{Key: "owner", Value: randomUser.Id}}},
-	})
+	// Give 6 loomies to the user
+	insertTestLoomies(randomUser.Id, 2, 6, false)
 
 	// Make the request and get the JSON response
 	w, req = tests.SetupGetRequest("/user/loomies", tests.CustomHeader{Name: "Access-Token", Value: token})
@@ -623,7 +594,7 @@ func TestGetLoomiesSuccess(t *testing.T) {
 	}
 
 	// Remove the user
-	err := tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err := tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
 
@@ -654,36 +625,10 @@ func TestGetLoomieTeamSuccess(t *testing.T) {
 	// -------------------------
 	// 2. Test with some loomies in the team
 	// -------------------------
-	// Get the first 6 loomies from the caught_loomies collection
-	var loomies []interfaces.CaughtLoomie
-	var loomiesIds []primitive.ObjectID
-
-	// Select the last 6 loomies
-	cursor, _ := models.CaughtLoomiesCollection.Find(ctx, bson.D{}, options.Find().SetLimit(6).SetSort(bson.D{{Key: "_id", Value: -1}}))
-	cursor.All(ctx, &loomies)
-	c.Equal(6, len(loomies))
-
-	// Get the ids of the loomies
-	for _, loomie := range loomies {
-		loomiesIds = append(loomiesIds, loomie.Id)
-	}
-
-	// Insert the loomies into the user's loomies
-	models.UserCollection.UpdateOne(ctx, bson.D{{Key: "email", Value: randomUser.Email}}, bson.D{{Key: "$set", Value: bson.D{{Key: "loomies", Value: loomiesIds}}}})
-	models.UserCollection.UpdateOne(ctx, bson.D{{Key: "email", Value: randomUser.Email}}, bson.D{{Key: "$set", Value: bson.D{{Key: "loomie_team", Value: loomiesIds}}}})
-
-	// Set the user as the loomies in the array owner
-	models.CaughtLoomiesCollection.UpdateMany(ctx, bson.D{
-		{Key: "_id", Value: bson.D{{Key: "$in", Value: loomiesIds}}},
-	}, bson.D{
-		{Key: "$set", Value: bson.D{{Key: "owner", Value: randomUser.Id}}},
-	})
-
-	// Set the user as the loomies in the array owner
-	models.CaughtLoomiesCollection.UpdateMany(ctx, bson.D{
-		{Key: "_id", Value: bson.D{{Key: "$in", Value: loomiesIds}}},
-	}, bson.D{
-		{Key: "$set", Value: bson.D{{Key: "owner", Value: randomUser.Id}}},
+	// Give 6 loomies to the user and add them to the team
+	loomiesIds := insertTestLoomies(randomUser.Id, 2, 6, false)
+	updateTestUser(randomUser.Id, func(user *interfaces.User) {
+		user.LoomieTeam = loomiesIds
 	})
 
 	// Make the request and get the JSON response
@@ -715,7 +660,7 @@ func TestGetLoomieTeamSuccess(t *testing.T) {
 	}
 
 	// Remove the user
-	err := tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err := tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
 
@@ -795,16 +740,12 @@ func TestUpdateLoomieTeamErrors(t *testing.T) {
 	// -------------------------
 	// Test 4: Test with loomies that are not owned by the user
 	// -------------------------
-	// Get 6 loomies from the database
-	var loomies []interfaces.CaughtLoomie
-	cursor, err := models.CaughtLoomiesCollection.Find(ctx, bson.D{}, options.Find().SetLimit(6))
-	c.NoError(err)
-	err = cursor.All(ctx, &loomies)
-	c.NoError(err)
-	c.Equal(6, len(loomies))
+	// Use the protectors of a gym
+	protectors := getTestGyms()[0].Protectors
+	c.Equal(6, len(protectors))
 
 	w, req = tests.SetupPayloadedRequest("/user/loomie-team", "PUT", map[string]interface{}{
-		"loomie_team": []interface{}{loomies[0].Id, loomies[1].Id, loomies[2].Id, loomies[3].Id, loomies[4].Id, loomies[5].Id},
+		"loomie_team": protectors,
 	}, tests.CustomHeader{
 		Name:  "Access-Token",
 		Value: loginResponse["accessToken"],
@@ -819,21 +760,11 @@ func TestUpdateLoomieTeamErrors(t *testing.T) {
 	// -------------------------
 	// Test 5: Test with busy loomies
 	// -------------------------
-	// Update the loomies owner
-	_, err = models.CaughtLoomiesCollection.UpdateMany(ctx, bson.M{
-		"_id": bson.M{
-			"$in": []primitive.ObjectID{loomies[0].Id, loomies[1].Id, loomies[2].Id, loomies[3].Id, loomies[4].Id, loomies[5].Id},
-		},
-	}, bson.M{
-		"$set": bson.M{
-			"owner":   randomUser.Id,
-			"is_busy": true,
-		},
-	})
-	c.NoError(err)
+	// Give busy loomies to the user
+	busyLoomies := insertTestLoomies(randomUser.Id, 2, 6, true)
 
 	w, req = tests.SetupPayloadedRequest("/user/loomie-team", "PUT", map[string]interface{}{
-		"loomie_team": []interface{}{loomies[0].Id, loomies[1].Id, loomies[2].Id, loomies[3].Id, loomies[4].Id, loomies[5].Id},
+		"loomie_team": busyLoomies,
 	}, tests.CustomHeader{
 		Name:  "Access-Token",
 		Value: loginResponse["accessToken"],
@@ -846,7 +777,7 @@ func TestUpdateLoomieTeamErrors(t *testing.T) {
 	c.Equal("All the loomies must be available to be added to the team", response["message"])
 
 	// Remove the user
-	err = tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err := tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
 
@@ -860,26 +791,8 @@ func TestUpdateLoomieTeamSuccess(t *testing.T) {
 	// Login with a random user
 	randomUser, loginResponse := loginWithRandomUser()
 
-	// Get 6 loomies from the database
-	var loomies []interfaces.CaughtLoomie
-	cursor, err := models.CaughtLoomiesCollection.Find(ctx, bson.D{}, options.Find().SetLimit(6))
-	c.NoError(err)
-	err = cursor.All(ctx, &loomies)
-	c.NoError(err)
-	c.Equal(6, len(loomies))
-
-	// Update the loomies owner
-	_, err = models.CaughtLoomiesCollection.UpdateMany(ctx, bson.M{
-		"_id": bson.M{
-			"$in": []primitive.ObjectID{loomies[0].Id, loomies[1].Id, loomies[2].Id, loomies[3].Id, loomies[4].Id, loomies[5].Id},
-		},
-	}, bson.M{
-		"$set": bson.M{
-			"owner":   randomUser.Id,
-			"is_busy": false,
-		},
-	})
-	c.NoError(err)
+	// Give 6 loomies to the user
+	loomiesIds := insertTestLoomies(randomUser.Id, 2, 6, false)
 
 	// Setup the router
 	router := tests.SetupGinRouter()
@@ -889,7 +802,7 @@ func TestUpdateLoomieTeamSuccess(t *testing.T) {
 	// Test 1: Update loomie team
 	// -------------------------
 	w, req := tests.SetupPayloadedRequest("/user/loomie-team", "PUT", map[string]interface{}{
-		"loomie_team": []interface{}{loomies[0].Id, loomies[1].Id, loomies[2].Id, loomies[3].Id, loomies[4].Id, loomies[5].Id},
+		"loomie_team": loomiesIds,
 	}, tests.CustomHeader{
 		Name:  "Access-Token",
 		Value: loginResponse["accessToken"],
@@ -902,18 +815,15 @@ func TestUpdateLoomieTeamSuccess(t *testing.T) {
 	c.Equal("The loomie team has been updated successfully", response["message"])
 
 	// Check the loomie team in the database
-	var finalUser interfaces.User
-	err = models.UserCollection.FindOne(ctx, bson.M{
-		"_id": randomUser.Id,
-	}).Decode(&finalUser)
+	finalUser, err := repos.Users.GetUserById(randomUser.Id.Hex())
 	c.NoError(err)
 
 	c.Equal(6, len(finalUser.LoomieTeam))
 	for index := range finalUser.LoomieTeam {
-		c.Equal(loomies[index].Id, finalUser.LoomieTeam[index])
+		c.Equal(loomiesIds[index], finalUser.LoomieTeam[index])
 	}
 
 	// Remove the user
-	err = tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err = tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
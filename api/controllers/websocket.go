@@ -6,9 +6,7 @@ import (
 	"time"
 
 	"github.com/PedroChaparro/loomies-backend/combat"
-	"github.com/PedroChaparro/loomies-backend/configuration"
 	"github.com/PedroChaparro/loomies-backend/interfaces"
-	"github.com/PedroChaparro/loomies-backend/models"
 	"github.com/PedroChaparro/loomies-backend/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
@@ -36,7 +34,7 @@ func HandleCombatRegister(c *gin.Context) {
 	}
 
 	// Check the gym is near the user coordinates
-	gymDoc, err := models.GetGymFromID(payload.GymID)
+	gymDoc, err := repos.Gyms.GetGymFromID(payload.GymID)
 
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Unable to get the gym. Please try again later."})
@@ -50,7 +48,7 @@ func HandleCombatRegister(c *gin.Context) {
 	}, interfaces.Coordinates{
 		Latitude:  payload.Latitude,
 		Longitude: payload.Longitude,
-	}) {
+	}, config.Game) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": true, "message": "You are too far away from the gym"})
 		return
 	}
@@ -58,8 +56,8 @@ func HandleCombatRegister(c *gin.Context) {
 	// Get the user and the gym from the database
 	userID, _ := c.Get("userid")
 	userMongoID, _ := primitive.ObjectIDFromHex(userID.(string))
-	userDoc, _ := models.GetUserById(userID.(string))
-	gymDoc, _ = models.GetGymFromID(payload.GymID)
+	userDoc, _ := repos.Users.GetUserById(userID.(string))
+	gymDoc, _ = repos.Gyms.GetGymFromID(payload.GymID)
 
 	// Check the user is not the gym owner
 	if userDoc.Id == gymDoc.Owner {
@@ -79,7 +77,7 @@ func HandleCombatRegister(c *gin.Context) {
 	}
 
 	// Check the user is not in combat
-	_, err = models.GetActiveCombatByUseId(userMongoID)
+	_, err = repos.Challenges.GetActiveCombatByUserId(userMongoID)
 
 	if err == nil {
 		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": true, "message": "You are already in combat"})
@@ -92,8 +90,8 @@ func HandleCombatRegister(c *gin.Context) {
 	}
 
 	// Check the user has not challenged the gym recently
-	lastUserChallenge, err := models.GetLastGymChallengeTimestamp(gymDoc.Id, userMongoID)
-	gymsChallengesTimeout := configuration.GetCombatChallengeTimeout()
+	lastUserChallenge, err := repos.Challenges.GetLastGymChallenge(gymDoc.Id, userMongoID)
+	gymsChallengesTimeout := gameBalance.Get().CombatChallengeTimeout
 	previousAttackTime := time.Unix(lastUserChallenge.Timestamp, 0)
 	nextValidChallenge := previousAttackTime.Add(time.Duration(gymsChallengesTimeout) * time.Minute)
 
@@ -108,7 +106,7 @@ func HandleCombatRegister(c *gin.Context) {
 	}
 
 	// Create a token to authenticate the user with the websocket endpoint
-	token, err := utils.CreateWsToken(userID.(string), payload.GymID, payload.Latitude, payload.Longitude)
+	token, err := utils.CreateWsToken(config.Tokens, userID.(string), payload.GymID, payload.Latitude, payload.Longitude)
 
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Unable to craete a token for the combat. Please try again later."})
@@ -129,7 +127,7 @@ func HandleCombatInit(c *gin.Context) {
 	}
 
 	// Validate the token and get the claims
-	claims, err := utils.ValidateWsToken(token)
+	claims, err := utils.ValidateWsToken(config.Tokens, token)
 
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": true, "message": "The token is invalid"})
@@ -137,15 +135,21 @@ func HandleCombatInit(c *gin.Context) {
 	}
 
 	// Get the gym from the database
-	gymDoc, err := models.GetGymFromID(claims.GymID)
+	gymDoc, err := repos.Gyms.GetGymFromID(claims.GymID)
 
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Unable to get the gym. Please try again later."})
 		return
 	}
 
-	// Check the gym is not already in combat
+	// Check the server is accepting combats and the gym is not already in combat
 	hub := combat.GlobalWsHub
+
+	if !hub.IsAccepting() {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": true, "message": "The server is shutting down. Please try again later."})
+		return
+	}
+
 	inCombat := hub.Includes(claims.GymID)
 
 	if inCombat {
@@ -155,9 +159,9 @@ func HandleCombatInit(c *gin.Context) {
 
 	// Get the user and gym loomies
 	var userCombatLoomies, gymCombatLoomies []interfaces.CombatLoomie
-	user, _ := models.GetUserById(claims.UserID)
-	userLoomies, _ := models.GetLoomiesByIds(user.LoomieTeam, user.Id)
-	gymLoomies, _ := models.GetLoomiesByIds(gymDoc.Protectors, primitive.NilObjectID)
+	user, _ := repos.Users.GetUserById(claims.UserID)
+	userLoomies, _ := repos.Loomies.GetLoomiesByIds(user.LoomieTeam, user.Id)
+	gymLoomies, _ := repos.Loomies.GetLoomiesByIds(gymDoc.Protectors, primitive.NilObjectID)
 
 	// Uncomment this to see the user and gym loomies
 	// NOTE: This can be removed in further pull requests
@@ -200,6 +204,11 @@ func HandleCombatInit(c *gin.Context) {
 	Combat := &combat.WsCombat{
 		PlayerID:                 user.Id,
 		GymID:                    claims.GymID,
+		RequestId:                c.GetString("requestid"),
+		Repositories:             repos,
+		Config:                   config,
+		Balance:                  gameBalance,
+		Mailer:                   mailer,
 		Connection:               conn,
 		LastMessageTimestamp:     time.Now().Unix(),
 		NextValidAttackTimestamp: 0,
@@ -215,15 +224,25 @@ func HandleCombatInit(c *gin.Context) {
 	}
 
 	// Update the last user challenge
-	err = models.UpdateLastGymChallengeTimestamp(gymDoc.Id, user.Id)
+	err = repos.Challenges.UpdateLastGymChallengeTimestamp(gymDoc.Id, user.Id)
 
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Unable to update the last gym challenge. Please try again later."})
 		return
 	}
 
-	// Register the connection on the hub
-	hub.Register(claims.GymID, Combat)
+	// Register the connection on the hub (another combat could have started or the server could be shutting
+	// down after the previous checks)
+	if !hub.Register(claims.GymID, Combat) {
+		repos.Challenges.FinishGymChallenge(gymDoc.Id, user.Id)
+		Combat.SendMessage(combat.WsMessage{
+			Type:    "COMBAT_REJECTED",
+			Message: "The combat could not be started. Please try again later.",
+		})
+
+		conn.Close()
+		return
+	}
 
 	// Send the initial loomies to the client
 	Combat.SendMessage(combat.WsMessage{
@@ -5,14 +5,14 @@ import (
 	"encoding/json"
 	"testing"
 
+	"github.com/PedroChaparro/loomies-backend/combat"
 	"github.com/PedroChaparro/loomies-backend/interfaces"
 	"github.com/PedroChaparro/loomies-backend/middlewares"
-	"github.com/PedroChaparro/loomies-backend/models"
+	"github.com/PedroChaparro/loomies-backend/repositories/memory"
 	"github.com/PedroChaparro/loomies-backend/tests"
+	"github.com/PedroChaparro/loomies-backend/utils"
 	"github.com/stretchr/testify/require"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // TestRegisterCombatBadRequest Test the error cases for the `/combat/register` endpoint
@@ -47,9 +47,7 @@ func TestRegisterCombatBadRequest(t *testing.T) {
 	// Test 2: Test with far away coordinates
 	// ---- ---- ---- ----
 	// Get a gym from the database
-	var gymDoc interfaces.Gym
-	err := models.GymsCollection.FindOne(ctx, bson.M{}).Decode(&gymDoc)
-	c.NoError(err)
+	gymDoc := getTestGyms()[0]
 
 	// Make the request
 	w, req = tests.SetupPayloadedRequest("/combat/register", "POST", map[string]interface{}{
@@ -71,8 +69,7 @@ func TestRegisterCombatBadRequest(t *testing.T) {
 	// Test 3: Test with a gym owned by the user
 	// ---- ---- ---- ----
 	// Update the gym owner
-	_, err = models.GymsCollection.UpdateOne(ctx, bson.M{"_id": gymDoc.Id}, bson.M{"$set": bson.M{"owner": randomUser.Id}})
-	c.NoError(err)
+	updateTestGym(gymDoc.Id, func(gym *interfaces.Gym) { gym.Owner = randomUser.Id })
 
 	// Make the request
 	w, req = tests.SetupPayloadedRequest("/combat/register", "POST", map[string]interface{}{
@@ -91,8 +88,7 @@ func TestRegisterCombatBadRequest(t *testing.T) {
 	c.Equal("You can't challenge your own gym", response["message"])
 
 	// Reset the gym owner
-	_, err = models.GymsCollection.UpdateOne(ctx, bson.M{"_id": gymDoc.Id}, bson.M{"$set": bson.M{"owner": nil}})
-	c.NoError(err)
+	updateTestGym(gymDoc.Id, func(gym *interfaces.Gym) { gym.Owner = primitive.NilObjectID })
 
 	// ---- ---- ---- ----
 	// Test 4: Test with no loomies in the loomie team
@@ -113,7 +109,7 @@ func TestRegisterCombatBadRequest(t *testing.T) {
 	c.Equal("You must have at least one loomie in your team to start a combat.", response["message"])
 
 	// Remove the user
-	err = tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err := tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
 
@@ -129,17 +125,7 @@ func TestRegisterCombatSuccess(t *testing.T) {
 	c.NotEmpty(loginResponse["accessToken"])
 
 	// Get a gym from the database
-	var gymDoc interfaces.Gym
-	err := models.GymsCollection.FindOne(ctx, bson.M{}).Decode(&gymDoc)
-	c.NoError(err)
-
-	// Get 6 loomies from the end of the caught loomies collection
-	var loomies []interfaces.CaughtLoomie
-	cursor, err := models.CaughtLoomiesCollection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": -1}).SetLimit(6))
-	c.NoError(err)
-	err = cursor.All(ctx, &loomies)
-	c.NoError(err)
-	c.Equal(6, len(loomies))
+	gymDoc := getTestGyms()[0]
 
 	// Setup the router
 	router := tests.SetupGinRouter()
@@ -148,22 +134,9 @@ func TestRegisterCombatSuccess(t *testing.T) {
 	// ---- ---- ----
 	// Test 1: Test with a valid payload
 	// ---- ---- ----
-	// Update the loomies owner
-	_, err = models.CaughtLoomiesCollection.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": []primitive.ObjectID{loomies[0].Id, loomies[1].Id, loomies[2].Id, loomies[3].Id, loomies[4].Id, loomies[5].Id}}}, bson.M{
-		"$set": bson.M{
-			"owner":   randomUser.Id,
-			"is_busy": false,
-		},
-	})
-
-	// Update the user loomie team
-	_, err = models.UserCollection.UpdateOne(ctx, bson.M{"_id": randomUser.Id}, bson.M{
-		"$set": bson.M{
-			"loomies":     []primitive.ObjectID{loomies[0].Id, loomies[1].Id, loomies[2].Id, loomies[3].Id, loomies[4].Id, loomies[5].Id},
-			"loomie_team": []primitive.ObjectID{loomies[0].Id, loomies[1].Id, loomies[2].Id, loomies[3].Id, loomies[4].Id, loomies[5].Id},
-		},
-	})
-	c.NoError(err)
+	// Give 6 loomies to the user and add them to the loomie team
+	loomies := insertTestLoomies(randomUser.Id, 1, 6, false)
+	updateTestUser(randomUser.Id, func(user *interfaces.User) { user.LoomieTeam = loomies })
 
 	// Make the request
 	w, req := tests.SetupPayloadedRequest("/combat/register", "POST", map[string]interface{}{
@@ -183,6 +156,44 @@ func TestRegisterCombatSuccess(t *testing.T) {
 	c.NotEmpty(response["combat_token"])
 
 	// Remove the user
-	err = tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err := tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
+
+// TestCombatInitWhileShuttingDown Test the `/combat` endpoint rejects the combats when the hub is shutting down
+func TestCombatInitWhileShuttingDown(t *testing.T) {
+	var response map[string]interface{}
+	c := require.New(t)
+
+	memoryRepos, store := memory.NewRepositories(config, gameBalance)
+	previousRepos := repos
+	SetRepositories(memoryRepos)
+	defer SetRepositories(previousRepos)
+
+	previousHub := combat.GlobalWsHub
+	combat.GlobalWsHub = &combat.WsHub{
+		Combats:             make(map[string]*combat.WsCombat),
+		CachedStrongAgainst: make(map[string][]string),
+	}
+	defer func() { combat.GlobalWsHub = previousHub }()
+
+	gym := interfaces.Gym{Id: primitive.NewObjectID(), Name: "Memory gym"}
+	store.Gyms[gym.Id] = gym
+
+	// The hub finishes immediately because there are no running combats
+	c.NoError(combat.GlobalWsHub.Shutdown(context.Background()))
+	c.False(combat.GlobalWsHub.IsAccepting())
+
+	router := tests.SetupGinRouter()
+	router.GET("/combat", HandleCombatInit)
+
+	token, err := utils.CreateWsToken(config.Tokens, primitive.NewObjectID().Hex(), gym.Id.Hex(), gym.Latitude, gym.Longitude)
+	c.NoError(err)
+
+	w, req := tests.SetupGetRequest("/combat?token=" + token)
+	router.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	c.Equal(503, w.Code)
+	c.Equal(true, response["error"])
+	c.Equal("The server is shutting down. Please try again later.", response["message"])
+}
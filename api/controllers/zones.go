@@ -4,7 +4,6 @@ import (
 	"net/http"
 
 	"github.com/PedroChaparro/loomies-backend/interfaces"
-	"github.com/PedroChaparro/loomies-backend/models"
 	"github.com/gin-gonic/gin"
 )
 
@@ -17,7 +16,7 @@ func HandleNearGyms(c *gin.Context) {
 		return
 	}
 
-	nearGyms, err := models.GetNearGyms(bodyCoord.Latitude, bodyCoord.Longitude)
+	nearGyms, err := repos.Zones.GetNearGyms(bodyCoord.Latitude, bodyCoord.Longitude)
 
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
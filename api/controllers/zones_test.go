@@ -5,9 +5,12 @@ import (
 	"encoding/json"
 	"testing"
 
+	"github.com/PedroChaparro/loomies-backend/interfaces"
 	"github.com/PedroChaparro/loomies-backend/middlewares"
+	"github.com/PedroChaparro/loomies-backend/repositories/memory"
 	"github.com/PedroChaparro/loomies-backend/tests"
 	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // TestNearGymsErrors Test the error responses for the `/gyms/near` endpoint
@@ -39,7 +42,7 @@ func TestNearGymsErrors(t *testing.T) {
 	c.Equal("JSON payload is invalid or missing", response["message"])
 
 	// Remove the user
-	err := tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err := tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
 
@@ -94,6 +97,40 @@ func TestNearGymsSuccess(t *testing.T) {
 	c.Equal(0, len(response["nearGyms"].([]interface{})))
 
 	// Remove the user
-	err := tests.DeleteUser(randomUser.Email, randomUser.Id)
+	err := tests.DeleteUser(repos, randomUser.Email)
 	c.NoError(err)
 }
+
+// TestNearGymsWithMemoryRepositories Test the `/gyms/near` endpoint with the in-memory repositories
+func TestNearGymsWithMemoryRepositories(t *testing.T) {
+	var response map[string]interface{}
+	c := require.New(t)
+
+	memoryRepos, store := memory.NewRepositories(config, gameBalance)
+	previousRepos := repos
+	SetRepositories(memoryRepos)
+	defer SetRepositories(previousRepos)
+
+	gym := interfaces.Gym{Id: primitive.NewObjectID(), Name: "Memory gym", Latitude: 7.1, Longitude: -73.1}
+	store.Gyms[gym.Id] = gym
+
+	router := tests.SetupGinRouter()
+	router.POST("/gyms/near", HandleNearGyms)
+
+	// ---- ---- ---- ----
+	// Test 1: Test with coordinates inside the visibility radius (~330 meters)
+	// ---- ---- ---- ----
+	w, req := tests.SetupPayloadedRequest("/gyms/near", "POST", map[string]interface{}{"latitude": 7.103, "longitude": -73.1})
+	router.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	c.Equal(200, w.Code)
+	c.Equal(false, response["error"])
+	c.Equal(1, len(response["nearGyms"].([]interface{})))
+
+	// ---- ---- ---- ----
+	// Test 2: Test with coordinates far from the gym (~11 kilometers)
+	// ---- ---- ---- ----
+	w, req = tests.SetupPayloadedRequest("/gyms/near", "POST", map[string]interface{}{"latitude": 7.2, "longitude": -73.1})
+	router.ServeHTTP(w, req)
+	c.Equal(404, w.Code)
+}
@@ -0,0 +1,145 @@
+// Package email renders the localized emails sent to the players and delivers them asynchronously
+// through a Sender (SMTP or a local outbox used in development and tests)
+package email
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Available templates, each one has a html and a text version per language
+const (
+	VerificationTemplate  = "verification"
+	PasswordResetTemplate = "password_reset"
+	GymLostTemplate       = "gym_lost"
+	WeeklySummaryTemplate = "weekly_summary"
+)
+
+// DefaultLanguage is used when the language of the user is not supported
+const DefaultLanguage = "en"
+
+// SupportedLanguages are the languages with translated templates
+var SupportedLanguages = []string{"en", "es"}
+
+//go:embed templates
+var templatesFS embed.FS
+
+// Message is a rendered email ready to be delivered
+type Message struct {
+	To      string
+	Subject string
+	Html    string
+	Text    string
+}
+
+// Sender delivers the rendered emails
+type Sender interface {
+	Send(message Message) error
+}
+
+// CodeData is used by the verification and password reset templates
+type CodeData struct {
+	Username         string
+	Code             string
+	ExpiresInMinutes int
+}
+
+// GymLostData is used by the gym lost template
+type GymLostData struct {
+	Username string
+	GymName  string
+	NewOwner string
+}
+
+// WeeklySummaryData is used by the weekly summary template
+type WeeklySummaryData struct {
+	Username      string
+	CaughtLoomies int
+	ConqueredGyms int
+	LostGyms      int
+	OwnedGyms     int
+}
+
+// NormalizeLanguage returns the supported language of the given language tag (Eg. "es-CO" -> "es"),
+// it also accepts Accept-Language headers and returns the default language if none is supported
+func NormalizeLanguage(language string) string {
+	for _, tag := range strings.Split(language, ",") {
+		tag = strings.ToLower(strings.TrimSpace(strings.Split(tag, ";")[0]))
+		tag = strings.Split(tag, "-")[0]
+
+		for _, supported := range SupportedLanguages {
+			if tag == supported {
+				return supported
+			}
+		}
+	}
+
+	return DefaultLanguage
+}
+
+// Render renders the subject and the html and text bodies of the template in the given language
+func Render(name string, language string, data interface{}) (Message, error) {
+	var subject, text, html bytes.Buffer
+	language = NormalizeLanguage(language)
+	path := "templates/" + language + "/" + name
+
+	textTemplate, err := texttemplate.ParseFS(templatesFS, path+".txt")
+	if err != nil {
+		return Message{}, fmt.Errorf("Unable to parse the %s template: %w", name, err)
+	}
+
+	htmlTemplate, err := htmltemplate.ParseFS(templatesFS, "templates/layout.html", path+".html")
+	if err != nil {
+		return Message{}, fmt.Errorf("Unable to parse the %s template: %w", name, err)
+	}
+
+	if err := textTemplate.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+
+	if err := textTemplate.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return Message{}, err
+	}
+
+	if err := htmlTemplate.ExecuteTemplate(&html, "layout.html", data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()),
+		Html:    html.String(),
+	}, nil
+}
+
+// Send renders the template and queues the email to be delivered in background by the mailer.
+// Only the rendering errors are returned, the delivery is retried by the mailer
+func (mailer *Mailer) Send(to string, language string, name string, data interface{}) error {
+	message, err := Render(name, language, data)
+
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	message.To = to
+	mailer.Enqueue(message)
+	return nil
+}
+
+// SendWait renders the template like Send, but waits until there is room in the delivery queue (See EnqueueWait)
+func (mailer *Mailer) SendWait(ctx context.Context, to string, language string, name string, data interface{}) error {
+	message, err := Render(name, language, data)
+
+	if err != nil {
+		return err
+	}
+
+	message.To = to
+	return mailer.EnqueueWait(ctx, message)
+}
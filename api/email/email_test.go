@@ -0,0 +1,152 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// ## Helper functions
+// fakeSender fails the given amount of attempts before storing the emails
+type fakeSender struct {
+	failures int
+	attempts int
+	sent     []Message
+}
+
+func (sender *fakeSender) Send(message Message) error {
+	sender.attempts++
+
+	if sender.attempts <= sender.failures {
+		return errors.New("SMTP server unavailable")
+	}
+
+	sender.sent = append(sender.sent, message)
+	return nil
+}
+
+// ## Tests
+
+// TestNormalizeLanguage tests the languages tags and headers are mapped to the supported languages
+func TestNormalizeLanguage(t *testing.T) {
+	c := require.New(t)
+	c.Equal("es", NormalizeLanguage("es"))
+	c.Equal("es", NormalizeLanguage("es-CO"))
+	c.Equal("es", NormalizeLanguage("fr-FR;q=0.9, ES-419;q=0.8"))
+	c.Equal("en", NormalizeLanguage("fr"))
+	c.Equal("en", NormalizeLanguage(""))
+}
+
+// TestRenderTemplates tests all the templates are rendered in all the supported languages
+func TestRenderTemplates(t *testing.T) {
+	c := require.New(t)
+	data := map[string]interface{}{
+		VerificationTemplate:  CodeData{Username: "loomies", Code: "ABC123", ExpiresInMinutes: 15},
+		PasswordResetTemplate: CodeData{Username: "loomies", Code: "ABC123", ExpiresInMinutes: 15},
+		GymLostTemplate:       GymLostData{Username: "loomies", GymName: "<Central Park>", NewOwner: "rival"},
+		WeeklySummaryTemplate: WeeklySummaryData{Username: "loomies", CaughtLoomies: 7, ConqueredGyms: 2},
+	}
+
+	for _, language := range SupportedLanguages {
+		for name, templateData := range data {
+			message, err := Render(name, language, templateData)
+			c.NoError(err)
+			c.NotEmpty(message.Subject)
+			c.Contains(message.Text, "loomies")
+			c.Contains(message.Html, "loomies")
+		}
+	}
+
+	// The codes are shown and the html is escaped
+	message, _ := Render(VerificationTemplate, "es-CO", data[VerificationTemplate])
+	c.Equal("Este es tu código de validación", message.Subject)
+	c.Contains(message.Text, "ABC123")
+
+	message, _ = Render(GymLostTemplate, "en", data[GymLostTemplate])
+	c.Contains(message.Html, "&lt;Central Park&gt;")
+	c.Contains(message.Text, "<Central Park>")
+}
+
+// TestMailerRetries tests the failed deliveries are retried in background
+func TestMailerRetries(t *testing.T) {
+	c := require.New(t)
+
+	// 1. The email is delivered after two failures
+	sender := &fakeSender{failures: 2}
+	mailer := NewMailer(sender, 3, time.Millisecond)
+	c.True(mailer.Enqueue(Message{To: "loomies@gmail.com", Subject: "Hi"}))
+	c.NoError(mailer.Close(context.Background()))
+	c.Equal(3, sender.attempts)
+	c.Equal(1, len(sender.sent))
+
+	// 2. The email is dropped after all the retries fail
+	sender = &fakeSender{failures: 10}
+	mailer = NewMailer(sender, 2, time.Millisecond)
+	mailer.Enqueue(Message{To: "loomies@gmail.com", Subject: "Hi"})
+	c.NoError(mailer.Close(context.Background()))
+	c.Equal(3, sender.attempts)
+	c.Equal(0, len(sender.sent))
+}
+
+// TestMailerCloseTimeout tests the mailer stops waiting for the queued emails when the context is done
+func TestMailerCloseTimeout(t *testing.T) {
+	c := require.New(t)
+	sender := &fakeSender{failures: 100}
+	mailer := NewMailer(sender, 5, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		c.True(mailer.Enqueue(Message{To: "loomies@gmail.com", Subject: "Hi"}))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := mailer.Close(ctx)
+	c.ErrorIs(err, context.DeadlineExceeded)
+	c.Less(time.Since(start), time.Second)
+	c.False(mailer.Enqueue(Message{To: "loomies@gmail.com", Subject: "Hi"}))
+}
+
+// TestOutboxSender tests the emails are written in the outbox directory
+func TestOutboxSender(t *testing.T) {
+	c := require.New(t)
+	directory := t.TempDir()
+
+	message, err := Render(PasswordResetTemplate, "en", CodeData{Username: "loomies", Code: "XYZ789", ExpiresInMinutes: 15})
+	c.NoError(err)
+	message.To = "loomies@gmail.com"
+
+	err = NewOutboxSender(directory).Send(message)
+	c.NoError(err)
+
+	files, err := filepath.Glob(filepath.Join(directory, "*-loomies@gmail.com.eml"))
+	c.NoError(err)
+	c.Equal(1, len(files))
+
+	content, err := os.ReadFile(files[0])
+	c.NoError(err)
+	c.True(strings.Contains(string(content), "Subject: Here is your code to reset your password"))
+	c.True(strings.Contains(string(content), "XYZ789"))
+}
+
+// TestMailerEnqueueWait tests the bulk emails wait for room in the queue instead of being dropped
+func TestMailerEnqueueWait(t *testing.T) {
+	c := require.New(t)
+	sender := &fakeSender{}
+	mailer := NewMailer(sender, 0, time.Millisecond)
+
+	for i := 0; i < mailerQueueSize*2; i++ {
+		c.NoError(mailer.SendWait(context.Background(), "loomies@gmail.com", "en", WeeklySummaryTemplate, WeeklySummaryData{Username: "loomies"}))
+	}
+
+	c.NoError(mailer.Close(context.Background()))
+	c.Equal(mailerQueueSize*2, len(sender.sent))
+	c.ErrorIs(mailer.EnqueueWait(context.Background(), Message{To: "loomies@gmail.com"}), ErrMailerClosed)
+}
@@ -0,0 +1,176 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/configuration"
+)
+
+// Amount of emails that can wait to be delivered, new emails are dropped when the queue is full
+const mailerQueueSize = 256
+
+// ErrMailerClosed is returned when an email is sent after the mailer was closed
+var ErrMailerClosed = errors.New("the mailer was closed")
+
+// Settings of the mailers created from the email settings
+const (
+	defaultMailerRetries = 3
+	defaultMailerDelay   = 2 * time.Second
+)
+
+// Mailer delivers the queued emails in background, failed deliveries are retried
+// doubling the delay between each attempt
+type Mailer struct {
+	sender  Sender
+	retries int
+	delay   time.Duration
+	queue   chan Message
+	done    chan bool
+	// Closed when the mailer stops waiting for the queued emails, the pending retries are cancelled
+	stop     chan struct{}
+	stopOnce sync.Once
+	// Protects the queue from being used after the mailer is closed
+	mutex  sync.RWMutex
+	closed bool
+}
+
+// NewMailer creates a mailer and starts delivering the queued emails
+func NewMailer(sender Sender, retries int, delay time.Duration) *Mailer {
+	mailer := &Mailer{
+		sender:  sender,
+		retries: retries,
+		delay:   delay,
+		queue:   make(chan Message, mailerQueueSize),
+		done:    make(chan bool),
+		stop:    make(chan struct{}),
+	}
+
+	go mailer.listen()
+	return mailer
+}
+
+// NewMailerFromSettings creates a mailer with the sender of the configured driver (smtp or outbox)
+func NewMailerFromSettings(settings configuration.TEmailSettings) *Mailer {
+	var sender Sender
+
+	if settings.Driver == "outbox" {
+		sender = NewOutboxSender(settings.OutboxDir)
+	} else {
+		sender = NewSMTPSender(settings.SmtpHost, settings.SmtpPort, settings.Sender, settings.Password)
+	}
+
+	return NewMailer(sender, defaultMailerRetries, defaultMailerDelay)
+}
+
+// Enqueue adds the email to the delivery queue without blocking, returns false if the queue is full
+// or the mailer was closed
+func (mailer *Mailer) Enqueue(message Message) bool {
+	if mailer == nil {
+		fmt.Println("The mailer was closed, dropping the email to", message.To)
+		return false
+	}
+
+	mailer.mutex.RLock()
+	defer mailer.mutex.RUnlock()
+
+	if mailer.closed {
+		fmt.Println("The mailer was closed, dropping the email to", message.To)
+		return false
+	}
+
+	select {
+	case mailer.queue <- message:
+		return true
+	default:
+		fmt.Println("The emails queue is full, dropping the email to", message.To)
+		return false
+	}
+}
+
+// EnqueueWait adds the email to the delivery queue waiting until there is room for it (Eg. for the emails sent in
+// bulk by the periodic jobs), returns an error if the mailer was closed or the context is done first
+func (mailer *Mailer) EnqueueWait(ctx context.Context, message Message) error {
+	mailer.mutex.RLock()
+	defer mailer.mutex.RUnlock()
+
+	if mailer.closed {
+		return ErrMailerClosed
+	}
+
+	select {
+	case mailer.queue <- message:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting emails and waits until the queued ones are delivered or the context is done. In the second
+// case the pending retries are cancelled and the emails left in the queue are dropped (and logged)
+func (mailer *Mailer) Close(ctx context.Context) error {
+	mailer.mutex.Lock()
+	if !mailer.closed {
+		mailer.closed = true
+		close(mailer.queue)
+	}
+	mailer.mutex.Unlock()
+
+	select {
+	case <-mailer.done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	mailer.stopOnce.Do(func() { close(mailer.stop) })
+
+	for message := range mailer.queue {
+		fmt.Println("The mailer was closed, the email to", message.To, "was not delivered")
+	}
+
+	return fmt.Errorf("unable to deliver the queued emails: %w", ctx.Err())
+}
+
+// listen delivers the queued emails until the mailer is closed
+func (mailer *Mailer) listen() {
+	for message := range mailer.queue {
+		select {
+		case <-mailer.stop:
+			fmt.Println("The mailer was closed, the email to", message.To, "was not delivered")
+		default:
+			mailer.deliver(message)
+		}
+	}
+
+	close(mailer.done)
+}
+
+// deliver sends the email retrying the failed attempts
+func (mailer *Mailer) deliver(message Message) error {
+	var err error
+	delay := mailer.delay
+
+	for attempt := 0; attempt <= mailer.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(delay):
+			case <-mailer.stop:
+				fmt.Println("The mailer was closed, the email to", message.To, "was not delivered")
+				return err
+			}
+
+			delay *= 2
+		}
+
+		if err = mailer.sender.Send(message); err == nil {
+			return nil
+		}
+
+		fmt.Printf("Unable to send the email to %s (attempt %d): %s\n", message.To, attempt+1, err)
+	}
+
+	return err
+}
@@ -0,0 +1,73 @@
+package email
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	gomail "gopkg.in/gomail.v2"
+)
+
+// Characters that can't be used in the outbox file names
+var unsafeFileCharacters = regexp.MustCompile(`[^a-zA-Z0-9@._-]`)
+
+// SMTPSender delivers the emails using a SMTP server
+type SMTPSender struct {
+	dialer *gomail.Dialer
+	from   string
+}
+
+// OutboxSender writes the emails as .eml files in a directory instead of delivering them
+type OutboxSender struct {
+	Directory string
+}
+
+// NewSMTPSender creates a sender that authenticates in the SMTP server with the given address and password
+func NewSMTPSender(host string, port int, from string, password string) *SMTPSender {
+	return &SMTPSender{
+		dialer: gomail.NewDialer(host, port, from, password),
+		from:   from,
+	}
+}
+
+// NewOutboxSender creates a sender that writes the emails in the given directory
+func NewOutboxSender(directory string) *OutboxSender {
+	return &OutboxSender{Directory: directory}
+}
+
+// toGomailMessage "private" function to create the multipart (text and html) message
+func toGomailMessage(from string, message Message) *gomail.Message {
+	msg := gomail.NewMessage()
+	msg.SetHeader("From", from)
+	msg.SetHeader("To", message.To)
+	msg.SetHeader("Subject", message.Subject)
+	msg.SetBody("text/plain", message.Text)
+	msg.AddAlternative("text/html", message.Html)
+	return msg
+}
+
+// Send delivers the email
+func (sender *SMTPSender) Send(message Message) error {
+	return sender.dialer.DialAndSend(toGomailMessage(sender.from, message))
+}
+
+// Send writes the email in the outbox directory
+func (sender *OutboxSender) Send(message Message) error {
+	if err := os.MkdirAll(sender.Directory, 0755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), unsafeFileCharacters.ReplaceAllString(message.To, "_"))
+	file, err := os.Create(filepath.Join(sender.Directory, name))
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	_, err = toGomailMessage("loomies@localhost", message).WriteTo(file)
+	return err
+}
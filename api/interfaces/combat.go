@@ -52,25 +52,25 @@ func (normalCaughtLoomie *UserLoomiesRes) ToCombatLoomie() *CombatLoomie {
 	}
 }
 
-// ApplyPainKillers Boosts the hp of the loomie by 50 if the hp is less than the max hp
+// ApplyPainKillers Boosts the hp of the loomie by the given amount (50 by default) if the hp is less than the max hp
 // Returns a boolean indicating if the boost was applied
-func (loomie *CombatLoomie) ApplyPainKillers() bool {
+func (loomie *CombatLoomie) ApplyPainKillers(heal int) bool {
 	if loomie.BoostedHp == loomie.MaxHp {
 		return false
 	}
 
-	loomie.BoostedHp = int(math.Min(float64(loomie.BoostedHp+50), float64(loomie.MaxHp)))
+	loomie.BoostedHp = int(math.Min(float64(loomie.BoostedHp+heal), float64(loomie.MaxHp)))
 	return true
 }
 
-// ApplySmallAidKit Boosts the hp of the loomie by 100 if the hp is less than the max hp
+// ApplySmallAidKit Boosts the hp of the loomie by the given amount (100 by default) if the hp is less than the max hp
 // Returns a boolean indicating if the boost was applied
-func (loomie *CombatLoomie) ApplySmallAidKit() bool {
+func (loomie *CombatLoomie) ApplySmallAidKit(heal int) bool {
 	if loomie.BoostedHp == loomie.MaxHp {
 		return false
 	}
 
-	loomie.BoostedHp = int(math.Min(float64(loomie.BoostedHp+100), float64(loomie.MaxHp)))
+	loomie.BoostedHp = int(math.Min(float64(loomie.BoostedHp+heal), float64(loomie.MaxHp)))
 	return true
 }
 
@@ -1,6 +1,9 @@
 package interfaces
 
 import (
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/configuration"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -9,43 +12,69 @@ type Coordinates struct {
 	Longitude float64 `json:"longitude" bson:"longitude"`
 }
 
-type Zone struct {
-	Id             primitive.ObjectID   `json:"_id" bson:"_id"`
-	LeftFrontier   float64              `json:"leftFrontier" bson:"leftFrontier"`
-	RightFrontier  float64              `json:"rightFrontier" bson:"rightFrontier"`
-	TopFrontier    float64              `json:"topFrontier" bson:"topFrontier"`
-	BottomFrontier float64              `json:"bottomFrontier" bson:"bottomFrontier"`
-	Number         int                  `json:"number" bson:"number"`
-	Coordinates    string               `json:"coordinates" bson:"coordinates"`
-	Gym            primitive.ObjectID   `json:"gym" bson:"gym"`
-	Loomies        []primitive.ObjectID `json:"loomies" bson:"loomies"`
+// GeoPoint is a GeoJSON point, used by the 2dsphere indexes. The coordinates are [longitude, latitude]
+type GeoPoint struct {
+	Type        string    `json:"type" bson:"type"`
+	Coordinates []float64 `json:"coordinates" bson:"coordinates"`
+}
+
+// ToGeoPoint Converts the coordinates to a GeoJSON point
+func (coordinates Coordinates) ToGeoPoint() *GeoPoint {
+	return &GeoPoint{Type: "Point", Coordinates: []float64{coordinates.Longitude, coordinates.Latitude}}
+}
+
+// Region is a city or an area of the world with its own grid of zones. The zones coordinates are counted from the
+// south-west corner of the bounds in steps of GridStep degrees. The daily and weekly periods (quests and gifts limit)
+// of the users last seen in the region start at the midnight of its Timezone
+type Region struct {
+	Id         primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Name       string             `json:"name"          bson:"name"`
+	Bounds     RegionBounds       `json:"bounds"        bson:"bounds"`
+	GridStep   float64            `json:"grid_step"     bson:"grid_step"`
+	Timezone   string             `json:"timezone"      bson:"timezone"`
+	SpawnTable []RegionSpawn      `json:"spawn_table"   bson:"spawn_table"`
+	CreatedAt  int64              `json:"created_at"    bson:"created_at"`
+}
+
+type RegionBounds struct {
+	MinLatitude  float64 `json:"min_latitude"  bson:"min_latitude"`
+	MinLongitude float64 `json:"min_longitude" bson:"min_longitude"`
+	MaxLatitude  float64 `json:"max_latitude"  bson:"max_latitude"`
+	MaxLongitude float64 `json:"max_longitude" bson:"max_longitude"`
+}
+
+// RegionSpawn is the chance (between 0 and 1) of a base loomie in a region. When a region has a spawn table, only the
+// loomies in the table are generated there, otherwise the rarities spawn chances are used
+type RegionSpawn struct {
+	Serial int     `json:"serial" bson:"serial"`
+	Chance float64 `json:"chance" bson:"chance"`
+}
+
+// Contains Returns true if the coordinates are inside the region bounds
+func (region *Region) Contains(coordinates Coordinates) bool {
+	return coordinates.Latitude >= region.Bounds.MinLatitude && coordinates.Latitude < region.Bounds.MaxLatitude &&
+		coordinates.Longitude >= region.Bounds.MinLongitude && coordinates.Longitude < region.Bounds.MaxLongitude
+}
+
+// Overlaps Returns true if the bounds of both regions intersect
+func (region *Region) Overlaps(other RegionBounds) bool {
+	return region.Bounds.MinLatitude < other.MaxLatitude && other.MinLatitude < region.Bounds.MaxLatitude &&
+		region.Bounds.MinLongitude < other.MaxLongitude && other.MinLongitude < region.Bounds.MaxLongitude
 }
 
-type ZoneWithGyms struct {
+type Zone struct {
 	Id             primitive.ObjectID   `json:"_id" bson:"_id"`
+	Region         primitive.ObjectID   `json:"region" bson:"region"`
 	LeftFrontier   float64              `json:"leftFrontier" bson:"leftFrontier"`
 	RightFrontier  float64              `json:"rightFrontier" bson:"rightFrontier"`
 	TopFrontier    float64              `json:"topFrontier" bson:"topFrontier"`
 	BottomFrontier float64              `json:"bottomFrontier" bson:"bottomFrontier"`
 	Number         int                  `json:"number" bson:"number"`
 	Coordinates    string               `json:"coordinates" bson:"coordinates"`
-	Gyms           []Gym                `json:"gyms" bson:"gyms"`
+	Gym            primitive.ObjectID   `json:"gym" bson:"gym"`
 	Loomies        []primitive.ObjectID `json:"loomies" bson:"loomies"`
 }
 
-type ZoneWithPopulatedLoomies struct {
-	Id               primitive.ObjectID   `json:"_id" bson:"_id"`
-	LeftFrontier     float64              `json:"leftFrontier" bson:"leftFrontier"`
-	RightFrontier    float64              `json:"rightFrontier" bson:"rightFrontier"`
-	TopFrontier      float64              `json:"topFrontier" bson:"topFrontier"`
-	BottomFrontier   float64              `json:"bottomFrontier" bson:"bottomFrontier"`
-	Number           int                  `json:"number" bson:"number"`
-	Coordinates      string               `json:"coordinates" bson:"coordinates"`
-	Gym              primitive.ObjectID   `json:"gym" bson:"gym"`
-	Loomies          []primitive.ObjectID `json:"loomies" bson:"loomies"`
-	PopulatedLoomies []WildLoomie         `json:"populated_loomies" bson:"populated_loomies"`
-}
-
 type GymRewardItem struct {
 	RewardCollection string             `json:"reward_collection" bson:"reward_collection"`
 	RewardId         primitive.ObjectID `json:"reward_id" bson:"reward_id"`
@@ -56,6 +85,7 @@ type Gym struct {
 	Id                    primitive.ObjectID   `json:"_id" bson:"_id"`
 	Latitude              float64              `json:"latitude"      bson:"latitude"`
 	Longitude             float64              `json:"longitude"      bson:"longitude"`
+	Location              *GeoPoint            `json:"-"      bson:"location,omitempty"`
 	Name                  string               `json:"name"      bson:"name"`
 	Owner                 primitive.ObjectID   `json:"owner,omitempty"      bson:"owner,omitempty"`
 	Protectors            []primitive.ObjectID `json:"protectors"      bson:"protectors"`
@@ -86,6 +116,7 @@ type PopulatedGym struct {
 	Id               primitive.ObjectID `json:"_id" bson:"_id"`
 	Name             string             `json:"name"      bson:"name"`
 	Owner            string             `json:"owner,omitempty"      bson:"owner,omitempty"`
+	OwnerId          primitive.ObjectID `json:"-"      bson:"-"`
 	Protectors       []GymProtector     `json:"protectors"      bson:"protectors"`
 	WasRewardClaimed bool               `json:"was_reward_claimed"      bson:"was_reward_claimed"`
 	UserOwnsIt       bool               `json:"user_owns_it" bson:"user_owns_it"`
@@ -134,6 +165,7 @@ type User struct {
 	Id                              primitive.ObjectID   `json:"_id,omitempty"       bson:"_id,omitempty"`
 	Username                        string               `json:"username"      bson:"username"`
 	Email                           string               `json:"email"     bson:"email"`
+	Language                        string               `json:"language,omitempty"     bson:"language,omitempty"`
 	Password                        string               `json:"password"  bson:"password"`
 	Items                           []InventoryItem      `json:"items"     bson:"items"`
 	Loomies                         []primitive.ObjectID `json:"loomies"   bson:"loomies"`
@@ -141,6 +173,216 @@ type User struct {
 	IsVerified                      bool                 `json:"isVerified"   bson:"isVerified"`
 	CurrentLoomiesGenerationTimeout int64                `json:"currentLoomiesGenerationTimeout"   bson:"currentLoomiesGenerationTimeout"`
 	LastLoomieGenerationTime        int64                `json:"lastLoomieGenerationTime"   bson:"lastLoomieGenerationTime"`
+	Identities                      []UserIdentity       `json:"identities,omitempty"   bson:"identities,omitempty"`
+	Mfa                             UserMfa              `json:"mfa"   bson:"mfa,omitempty"`
+	Roles                           []string             `json:"roles"   bson:"roles,omitempty"`
+	Ban                             *UserSanction        `json:"ban,omitempty"   bson:"ban,omitempty"`
+	Mute                            *UserSanction        `json:"mute,omitempty"   bson:"mute,omitempty"`
+	Profile                         UserProfile          `json:"profile"   bson:"profile,omitempty"`
+	// Trainer level, zero is the same as the first level (accounts created before the trainer levels)
+	Level      int     `json:"level"   bson:"level,omitempty"`
+	Experience float64 `json:"experience"   bson:"experience,omitempty"`
+	// Progress of the achievements (Eg. "captures": 10) and the unlocked ones
+	AchievementCounters map[string]int    `json:"achievement_counters"   bson:"achievement_counters,omitempty"`
+	Achievements        []UserAchievement `json:"achievements"   bson:"achievements,omitempty"`
+	// Users that can't send friend requests nor see the username of the user
+	BlockedUsers []primitive.ObjectID `json:"blocked_users"   bson:"blocked_users,omitempty"`
+	// Last activity of the user, only shared with the friends if the profile allows it
+	LastSeenAt   int64  `json:"last_seen_at"   bson:"last_seen_at,omitempty"`
+	LastSeenZone string `json:"last_seen_zone"   bson:"last_seen_zone,omitempty"`
+	// Number of gifts sent in the day (Eg. "2023-05-01"), the counter is restarted when the day changes
+	GiftsSentDay string `json:"gifts_sent_day"   bson:"gifts_sent_day,omitempty"`
+	GiftsSent    int    `json:"gifts_sent"   bson:"gifts_sent,omitempty"`
+}
+
+// TrainerLevelReward is an item given to the trainers each time they reach a level multiple of EveryLevels
+type TrainerLevelReward struct {
+	EveryLevels    int
+	Collection     string
+	Serial         int
+	RewardQuantity int
+}
+
+// Achievement is a goal of one of the user achievement counters (Defined in data/achievements.json)
+type Achievement struct {
+	Id          primitive.ObjectID `json:"_id" bson:"_id"`
+	Serial      int                `json:"serial" bson:"serial"`
+	Name        string             `json:"name" bson:"name"`
+	Description string             `json:"description" bson:"description"`
+	Badge       string             `json:"badge" bson:"badge"`
+	Counter     string             `json:"counter" bson:"counter"`
+	Goal        int                `json:"goal" bson:"goal"`
+}
+
+// UserAchievement is an achievement unlocked by the user
+type UserAchievement struct {
+	AchievementId primitive.ObjectID `json:"achievement_id" bson:"achievement_id"`
+	UnlockedAt    int64              `json:"unlocked_at" bson:"unlocked_at"`
+}
+
+// QuestTemplateReward is an item given when a quest is claimed (Referenced by serial)
+type QuestTemplateReward struct {
+	RewardCollection string `json:"reward_collection" bson:"reward_collection"`
+	RewardSerial     int    `json:"reward_serial" bson:"reward_serial"`
+	RewardQuantity   int    `json:"reward_quantity" bson:"reward_quantity"`
+}
+
+// QuestTemplate is used to generate the daily and weekly quests of the users (Defined in data/quest_templates.json)
+type QuestTemplate struct {
+	Id          primitive.ObjectID    `json:"_id" bson:"_id"`
+	Serial      int                   `json:"serial" bson:"serial"`
+	Period      string                `json:"period" bson:"period"`
+	Event       string                `json:"event" bson:"event"`
+	Description string                `json:"description" bson:"description"`
+	LoomieType  string                `json:"loomie_type" bson:"loomie_type,omitempty"`
+	MinGoal     int                   `json:"min_goal" bson:"min_goal"`
+	MaxGoal     int                   `json:"max_goal" bson:"max_goal"`
+	Unique      bool                  `json:"unique" bson:"unique"`
+	Rewards     []QuestTemplateReward `json:"rewards" bson:"rewards"`
+}
+
+// UserQuest is a quest generated from a template for a single user and period
+type UserQuest struct {
+	Id         primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	UserId     primitive.ObjectID `json:"-" bson:"user_id"`
+	TemplateId primitive.ObjectID `json:"template_id" bson:"template_id"`
+	Period     string             `json:"period" bson:"period"`
+	// Identifies the day or week the quest belongs to (Eg. "2023-05-01" or "2023-W18")
+	PeriodKey    string             `json:"-" bson:"period_key"`
+	Slot         int                `json:"-" bson:"slot"`
+	Event        string             `json:"event" bson:"event"`
+	Description  string             `json:"description" bson:"description"`
+	LoomieTypeId primitive.ObjectID `json:"loomie_type_id,omitempty" bson:"loomie_type_id,omitempty"`
+	Unique       bool               `json:"unique" bson:"unique"`
+	// Values already counted by the unique quests (Eg. the visited zones)
+	SeenValues []string        `json:"-" bson:"seen_values"`
+	Goal       int             `json:"goal" bson:"goal"`
+	Progress   int             `json:"progress" bson:"progress"`
+	Rewards    []GymRewardItem `json:"rewards" bson:"rewards"`
+	Claimed    bool            `json:"claimed" bson:"claimed"`
+	CreatedAt  int64           `json:"created_at" bson:"created_at"`
+	ExpiresAt  int64           `json:"expires_at" bson:"expires_at"`
+}
+
+// UserProfile stores the public information of the trainer card
+type UserProfile struct {
+	DisplayName string `json:"display_name"   bson:"display_name,omitempty"`
+	Avatar      string `json:"avatar"   bson:"avatar,omitempty"`
+	Bio         string `json:"bio"   bson:"bio,omitempty"`
+	// Fields of the trainer card hidden to the other players
+	HiddenFields []string `json:"hidden_fields"   bson:"hidden_fields,omitempty"`
+	// Opt-in presence shared with the friends
+	ShareOnlineStatus bool `json:"share_online_status"   bson:"share_online_status,omitempty"`
+	ShareLastSeenZone bool `json:"share_last_seen_zone"   bson:"share_last_seen_zone,omitempty"`
+}
+
+// Friendship is a friend request between two users, it's accepted when both users are friends
+type Friendship struct {
+	Id          primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	RequesterId primitive.ObjectID `json:"requester_id" bson:"requester_id"`
+	RecipientId primitive.ObjectID `json:"recipient_id" bson:"recipient_id"`
+	Status      string             `json:"status" bson:"status"`
+	CreatedAt   int64              `json:"created_at" bson:"created_at"`
+	AcceptedAt  int64              `json:"accepted_at,omitempty" bson:"accepted_at,omitempty"`
+}
+
+// TradeOffer is the loomie (and optional items) given by one of the players of a trade. The loomie name, serial
+// and level are stored to keep the trade history even if the loomie is fused later
+type TradeOffer struct {
+	LoomieId     primitive.ObjectID `json:"loomie_id" bson:"loomie_id"`
+	LoomieSerial int                `json:"loomie_serial" bson:"loomie_serial"`
+	LoomieName   string             `json:"loomie_name" bson:"loomie_name"`
+	LoomieLevel  int                `json:"loomie_level" bson:"loomie_level"`
+	Items        []InventoryItem    `json:"items" bson:"items"`
+}
+
+// TradeConfirmation is the location where a player confirmed an accepted trade
+type TradeConfirmation struct {
+	UserId      primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Coordinates Coordinates        `json:"-" bson:"coordinates"`
+	ConfirmedAt int64              `json:"confirmed_at" bson:"confirmed_at"`
+}
+
+// Trade is a loomies exchange between two players. The offers can be countered until one of the players accepts
+// them, then both players have to confirm the trade near each other
+type Trade struct {
+	Id             primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	ProposerId     primitive.ObjectID `json:"proposer_id" bson:"proposer_id"`
+	RecipientId    primitive.ObjectID `json:"recipient_id" bson:"recipient_id"`
+	ProposerOffer  TradeOffer         `json:"proposer_offer" bson:"proposer_offer"`
+	RecipientOffer TradeOffer         `json:"recipient_offer" bson:"recipient_offer"`
+	Status         string             `json:"status" bson:"status"`
+	// Player that has to accept, counter or decline the current offers
+	PendingUserId primitive.ObjectID  `json:"pending_user_id,omitempty" bson:"pending_user_id,omitempty"`
+	Confirmations []TradeConfirmation `json:"confirmations" bson:"confirmations"`
+	// Max distance (in meters) between the players confirmations
+	MaxDistance float64 `json:"max_distance" bson:"max_distance"`
+	CreatedAt   int64   `json:"created_at" bson:"created_at"`
+	UpdatedAt   int64   `json:"updated_at" bson:"updated_at"`
+	ExpiresAt   int64   `json:"expires_at" bson:"expires_at"`
+	CompletedAt int64   `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+}
+
+// GiftTableEntry is a possible reward of the gifts, the entries are drawn by weight (Defined in data/gift_table.json)
+type GiftTableEntry struct {
+	Id               primitive.ObjectID `json:"_id" bson:"_id"`
+	RewardCollection string             `json:"reward_collection" bson:"reward_collection"`
+	RewardSerial     int                `json:"reward_serial" bson:"reward_serial"`
+	Weight           float64            `json:"weight" bson:"weight"`
+	MinQuantity      int                `json:"min_quantity" bson:"min_quantity"`
+	MaxQuantity      int                `json:"max_quantity" bson:"max_quantity"`
+}
+
+// Gift is a sealed package of items sent between friends, the rewards are drawn when the gift is sent but
+// they are only shown to the recipient when the gift is opened
+type Gift struct {
+	Id          primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	SenderId    primitive.ObjectID `json:"sender_id" bson:"sender_id"`
+	RecipientId primitive.ObjectID `json:"recipient_id" bson:"recipient_id"`
+	Rewards     []GymRewardItem    `json:"rewards,omitempty" bson:"rewards"`
+	Opened      bool               `json:"opened" bson:"opened"`
+	SentAt      int64              `json:"sent_at" bson:"sent_at"`
+	OpenedAt    int64              `json:"opened_at,omitempty" bson:"opened_at,omitempty"`
+}
+
+// UserSanction stores a ban or mute applied by a moderator
+type UserSanction struct {
+	Reason    string             `json:"reason"   bson:"reason"`
+	ActorId   primitive.ObjectID `json:"actor_id"   bson:"actor_id"`
+	CreatedAt int64              `json:"created_at"   bson:"created_at"`
+	// Zero means the sanction doesn't expire
+	ExpiresAt int64 `json:"expires_at"   bson:"expires_at"`
+}
+
+// IsActive returns true if the sanction exists and has not expired
+func (sanction *UserSanction) IsActive() bool {
+	return sanction != nil && (sanction.ExpiresAt == 0 || sanction.ExpiresAt > time.Now().Unix())
+}
+
+// UserMfa stores the two-factor authentication (TOTP) settings of an user
+type UserMfa struct {
+	Enabled       bool   `json:"enabled"   bson:"enabled"`
+	Secret        string `json:"-"   bson:"secret,omitempty"`
+	PendingSecret string `json:"-"   bson:"pending_secret,omitempty"`
+	// Hashes of the remaining one-time recovery codes
+	RecoveryCodes []string `json:"-"   bson:"recovery_codes,omitempty"`
+	// Last TOTP time step used to login, codes can't be reused
+	LastUsedStep int64 `json:"-"   bson:"last_used_step"`
+	// Login challenge of the last mfa token, the older tokens are not valid
+	ChallengeId string `json:"-"   bson:"challenge_id,omitempty"`
+	// Invalid codes sent for the current challenge or pending authenticator
+	FailedAttempts int `json:"-"   bson:"failed_attempts,omitempty"`
+	// Invalid codes sent to confirm the sensitive actions (Eg. disabling the authenticator) and the unix time until
+	// the actions are locked after too many of them
+	ActionFailedAttempts int   `json:"-"   bson:"action_failed_attempts,omitempty"`
+	LockedUntil          int64 `json:"-"   bson:"locked_until,omitempty"`
+}
+
+// UserIdentity links an user account with an external (OpenID Connect) identity
+type UserIdentity struct {
+	Provider string `json:"provider" bson:"provider"`
+	Subject  string `json:"subject"  bson:"subject"`
+	LinkedAt int64  `json:"linked_at" bson:"linked_at"`
 }
 
 type PopulatedIventoryItem struct {
@@ -213,6 +455,7 @@ type WildLoomie struct {
 	ZoneId      primitive.ObjectID   `json:"zone_id"     bson:"zone_id"`
 	Latitude    float64              `json:"latitude"     bson:"latitude"`
 	Longitude   float64              `json:"longitude"     bson:"longitude"`
+	Location    *GeoPoint            `json:"-"     bson:"location,omitempty"`
 	GeneratedAt int64                `json:"generated_at"     bson:"generated_at"`
 	Level       int                  `json:"level"     bson:"level"`
 	Experience  float64              `json:"experience"     bson:"experience"`
@@ -245,6 +488,25 @@ type AuthenticationCode struct {
 	ExpiresAt int64              `json:"expires_at"      bson:"expires_at"`
 }
 
+// OIDCState keeps the PKCE verifier and nonce of an authorization request until the callback is received
+type OIDCState struct {
+	Id           primitive.ObjectID `json:"_id,omitempty"       bson:"_id,omitempty"`
+	State        string             `json:"state"      bson:"state"`
+	Provider     string             `json:"provider"      bson:"provider"`
+	Nonce        string             `json:"nonce"      bson:"nonce"`
+	CodeVerifier string             `json:"code_verifier"      bson:"code_verifier"`
+	ExpiresAt    int64              `json:"expires_at"      bson:"expires_at"`
+}
+
+// OIDCIdTokenClaims are the relevant claims of a validated OpenID Connect ID token
+type OIDCIdTokenClaims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
 type ValidationCode struct {
 	Email             string `json:"email"`
 	ValidationCode    string `json:"validationCode"`
@@ -280,6 +542,70 @@ type GymChallengesRegister struct {
 	IsActive   bool               `json:"is_active"     bson:"is_active"`
 }
 
+// UserWeeklySummary counts the activity of an user in the last week, it's sent in the weekly summary email
+type UserWeeklySummary struct {
+	UserId        primitive.ObjectID
+	CaughtLoomies int
+	ConqueredGyms int
+	LostGyms      int
+	OwnedGyms     int
+}
+
+// AuditEvent stores a change made to the database, the before / after fields are snapshots (or deltas) of the changed data
+type AuditEvent struct {
+	Id        primitive.ObjectID `json:"_id,omitempty"        bson:"_id,omitempty"`
+	ActorId   primitive.ObjectID `json:"actor_id,omitempty"   bson:"actor_id,omitempty"`
+	UserId    primitive.ObjectID `json:"user_id,omitempty"    bson:"user_id,omitempty"`
+	RequestId string             `json:"request_id,omitempty" bson:"request_id,omitempty"`
+	Action    string             `json:"action"               bson:"action"`
+	Entity    string             `json:"entity"               bson:"entity"`
+	EntityId  primitive.ObjectID `json:"entity_id,omitempty"  bson:"entity_id,omitempty"`
+	Before    interface{}        `json:"before,omitempty"     bson:"before,omitempty"`
+	After     interface{}        `json:"after,omitempty"      bson:"after,omitempty"`
+	CreatedAt int64              `json:"created_at"           bson:"created_at"`
+}
+
+// AuditEventsFilter is used to query the audit events, the zero values are ignored
+type AuditEventsFilter struct {
+	UserId   primitive.ObjectID
+	Entity   string
+	EntityId primitive.ObjectID
+	From     int64
+	To       int64
+	Limit    int64
+}
+
+// GameSettingsVersion is a published version of the game balance. The versions are never modified, the one with
+// the highest number is in use and a rollback publishes a copy of an older version
+type GameSettingsVersion struct {
+	Id           primitive.ObjectID         `json:"_id" bson:"_id,omitempty"`
+	Version      int                        `json:"version" bson:"version"`
+	Settings     configuration.TGameBalance `json:"settings" bson:"settings"`
+	Comment      string                     `json:"comment,omitempty" bson:"comment,omitempty"`
+	CreatedBy    primitive.ObjectID         `json:"created_by,omitempty" bson:"created_by,omitempty"`
+	RolledBackTo int                        `json:"rolled_back_to,omitempty" bson:"rolled_back_to,omitempty"`
+	CreatedAt    int64                      `json:"created_at" bson:"created_at"`
+}
+
+// JobRun is a run of a scheduled job, the runs are recorded by the replica that held the job lock
+type JobRun struct {
+	Id          primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Job         string             `json:"job" bson:"job"`
+	Owner       string             `json:"owner" bson:"owner"`
+	ScheduledAt int64              `json:"scheduled_at" bson:"scheduled_at"`
+	StartedAt   int64              `json:"started_at" bson:"started_at"`
+	FinishedAt  int64              `json:"finished_at" bson:"finished_at"`
+	Succeeded   bool               `json:"succeeded" bson:"succeeded"`
+	Summary     string             `json:"summary,omitempty" bson:"summary,omitempty"`
+	Error       string             `json:"error,omitempty" bson:"error,omitempty"`
+}
+
+type AccessTokenClaims struct {
+	UserID      string   `json:"userid"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
 type WsTokenClaims struct {
 	UserID    string  `json:"user_id"`
 	GymID     string  `json:"gym_id"`
@@ -335,6 +661,7 @@ func (aux *PopulatedGymAux) ToPopulatedGym(userId primitive.ObjectID) *Populated
 
 	if len(aux.Owner) == 1 {
 		populatedGym.Owner = aux.Owner[0].Username
+		populatedGym.OwnerId = aux.Owner[0].Id
 		populatedGym.UserOwnsIt = aux.Owner[0].Id == userId
 	}
 
@@ -1,9 +1,12 @@
 package interfaces
 
+import "github.com/PedroChaparro/loomies-backend/configuration"
+
 type SignUpForm struct {
 	Username string `json:"username"`
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	Language string `json:"language"`
 }
 
 type LogInForm struct {
@@ -52,3 +55,113 @@ type UpdateGymProtectorsReq struct {
 	Protectors []string `json:"protectors"`
 	GymId      string   `json:"gym_id"`
 }
+
+type OIDCCallbackReq struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+}
+
+type MfaCodeReq struct {
+	Code string `json:"code"`
+}
+
+type MfaLogInReq struct {
+	MfaToken string `json:"mfaToken"`
+	Code     string `json:"code"`
+}
+
+type UpdateUserRolesReq struct {
+	Roles []string `json:"roles"`
+}
+
+type AdminBaseLoomieReq struct {
+	Serial      int      `json:"serial"`
+	Name        string   `json:"name"`
+	Types       []string `json:"types"`
+	Rarity      string   `json:"rarity"`
+	BaseHp      int      `json:"base_hp"`
+	BaseAttack  int      `json:"base_attack"`
+	BaseDefense int      `json:"base_defense"`
+}
+
+type AdminLoomieTypeReq struct {
+	Name          string   `json:"name"`
+	StrongAgainst []string `json:"strong_against"`
+}
+
+type AdminSanctionReq struct {
+	Reason string `json:"reason"`
+	// Zero means the sanction doesn't expire
+	DurationMinutes int `json:"duration_minutes"`
+}
+
+type AdminInventoryReq struct {
+	ItemId   string `json:"item_id"`
+	Quantity int    `json:"quantity"`
+}
+
+type AdminReleaseLoomiesReq struct {
+	LoomieIds []string `json:"loomie_ids"`
+}
+
+type DeleteAccountReq struct {
+	Password string `json:"password"`
+	// Required when the two-factor authentication is enabled
+	Code string `json:"code"`
+}
+
+// Nil fields are not updated
+type UpdateProfileReq struct {
+	DisplayName  *string  `json:"display_name"`
+	Avatar       *string  `json:"avatar"`
+	Bio          *string  `json:"bio"`
+	HiddenFields []string `json:"hidden_fields"`
+	// Presence settings
+	ShareOnlineStatus *bool `json:"share_online_status"`
+	ShareLastSeenZone *bool `json:"share_last_seen_zone"`
+}
+
+type UsernameReq struct {
+	Username string `json:"username"`
+}
+
+type TradeItemReq struct {
+	ItemId   string `json:"item_id"`
+	Quantity int    `json:"quantity"`
+}
+
+// TradeOfferReq is used to propose and counter the trades. The loomie and the items are given by the session user
+// in exchange for the requested loomie
+type TradeOfferReq struct {
+	LoomieId          string         `json:"loomie_id"`
+	Items             []TradeItemReq `json:"items"`
+	RequestedLoomieId string         `json:"requested_loomie_id"`
+}
+
+type ProposeTradeReq struct {
+	Username string `json:"username"`
+	TradeOfferReq
+}
+
+type ConfirmTradeReq struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type GameSettingsReq struct {
+	Settings configuration.TGameBalance `json:"settings"`
+	Comment  string                     `json:"comment"`
+}
+
+type GameSettingsRollbackReq struct {
+	Version int    `json:"version"`
+	Comment string `json:"comment"`
+}
+
+type RegionReq struct {
+	Name       string        `json:"name"`
+	Bounds     RegionBounds  `json:"bounds"`
+	GridStep   float64       `json:"grid_step"`
+	Timezone   string        `json:"timezone"`
+	SpawnTable []RegionSpawn `json:"spawn_table"`
+}
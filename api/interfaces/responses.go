@@ -88,3 +88,26 @@ func (aux *UserLoomiesResAux) ToUserLoomiesRes(rarity string, types []string) *U
 		Experience: aux.Experience,
 	}
 }
+
+// TrainerProgressRes is the trainer progression after earning experience
+type TrainerProgressRes struct {
+	ExperienceGained    float64         `json:"experience_gained"`
+	Experience          float64         `json:"experience"`
+	Level               int             `json:"level"`
+	NextLevelExperience float64         `json:"next_level_experience"`
+	LevelUp             bool            `json:"level_up"`
+	Rewards             []GymRewardItem `json:"rewards"`
+}
+
+// TradeRes is a trade with the usernames of the players
+type TradeRes struct {
+	Trade
+	ProposerUsername  string `json:"proposer_username"`
+	RecipientUsername string `json:"recipient_username"`
+}
+
+// GiftRes is a received gift with the username of the sender
+type GiftRes struct {
+	Gift
+	SenderUsername string `json:"sender_username"`
+}
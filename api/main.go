@@ -1,31 +1,51 @@
 package main
 
 import (
-	"github.com/PedroChaparro/loomies-backend/combat"
+	"context"
+	"log"
+	"os"
+
+	"github.com/PedroChaparro/loomies-backend/app"
 	"github.com/PedroChaparro/loomies-backend/configuration"
-	"github.com/PedroChaparro/loomies-backend/routes"
-	"github.com/gin-gonic/gin"
+	"github.com/PedroChaparro/loomies-backend/migrations"
 )
 
 func main() {
-	// Setup server and default routes
-	engine := gin.Default()
-	routes.SetupRoutes(engine)
+	// Load the configuration, all the problems are reported at once
+	config, err := configuration.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Manage the database migrations: migrate up | down [n] | status
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrate(config, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	// Create the server and run it until it's stopped
+	server, err := app.New(config)
+	if err != nil {
+		log.Fatal("Error connecting to MongoDB: ", err)
+	}
 
-	// Set gin mode to release if in production
-	if configuration.GetRunningEnvironment() == "PRODUCTION" {
-		gin.SetMode(gin.ReleaseMode)
+	if err := server.Run(); err != nil {
+		log.Fatal(err)
 	}
+}
 
-	// Setup websocket routes
-	hub := combat.WsHub{
-		Combats:             make(map[string]*combat.WsCombat),
-		CachedStrongAgainst: make(map[string][]string),
+// migrate runs the migrate command with its own database connection
+func migrate(config *configuration.Config, args []string) error {
+	client, err := configuration.NewMongoClient(config.Mongo)
+	if err != nil {
+		return err
 	}
 
-	combat.GlobalWsHub = &hub
-	routes.SetupWebSocketRoutes(engine)
+	defer client.Disconnect(context.Background())
 
-	// Start the server
-	engine.Run()
+	migrator := migrations.New(client.Database(config.Mongo.Database))
+	return migrations.RunCommand(context.Background(), migrator, args, os.Stdout)
 }
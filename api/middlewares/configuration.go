@@ -0,0 +1,11 @@
+package middlewares
+
+import "github.com/PedroChaparro/loomies-backend/configuration"
+
+// config is the settings used by the middlewares, injected by the app (See SetConfiguration)
+var config *configuration.Config
+
+// SetConfiguration Replaces the settings used by the middlewares
+func SetConfiguration(settings *configuration.Config) {
+	config = settings
+}
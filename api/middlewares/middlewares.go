@@ -5,6 +5,8 @@ import (
 
 	"github.com/PedroChaparro/loomies-backend/utils"
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // MustProvideAccessToken checks if a valid access token was provided in the header
@@ -19,14 +21,41 @@ func MustProvideAccessToken() gin.HandlerFunc {
 		}
 
 		// Check if access token is valid
-		id, error := utils.ValidateAccessToken(accessToken)
+		claims, error := utils.ValidateAccessToken(config.Tokens, accessToken)
 		if error != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": true, "message": error.Error()})
 			return
 		}
 
-		// Set user id to context
-		c.Set("userid", id)
+		// Check the user was not banned after the token was created
+		// (Missing users are handled by each controller)
+		ban, error := repos.Moderation.GetUserBan(claims.UserID)
+		if error != nil && error != mongo.ErrNoDocuments {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Internal server error"})
+			return
+		}
+
+		if ban.IsActive() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": true, "message": "Your account has been banned", "reason": ban.Reason, "expires_at": ban.ExpiresAt})
+			return
+		}
+
+		// Set user id, roles and permissions to context
+		c.Set("userid", claims.UserID)
+		c.Set("roles", claims.Roles)
+		c.Set("permissions", claims.Permissions)
+	}
+}
+
+// RequirePermission checks the access token grants all the given permissions. It must be used after MustProvideAccessToken
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		granted := c.GetStringSlice("permissions")
+
+		if !utils.HasPermissions(granted, permissions...) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": true, "message": "You don't have permission to perform this action"})
+			return
+		}
 	}
 }
 
@@ -42,7 +71,7 @@ func MustProvideRefreshToken() gin.HandlerFunc {
 		}
 
 		// Check if refresh token is valid
-		id, error := utils.ValidateRefreshToken(refreshToken)
+		id, error := utils.ValidateRefreshToken(config.Tokens, refreshToken)
 		if error != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": true, "message": error.Error()})
 			return
@@ -52,3 +81,13 @@ func MustProvideRefreshToken() gin.HandlerFunc {
 		c.Set("userid", id)
 	}
 }
+
+// RequestId sets an unique id to the request, it's returned in the X-Request-Id header and stored in the audit events
+// to group the changes made by the same request
+func RequestId() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestId := primitive.NewObjectID().Hex()
+		c.Set("requestid", requestId)
+		c.Header("X-Request-Id", requestId)
+	}
+}
@@ -0,0 +1,11 @@
+package middlewares
+
+import "github.com/PedroChaparro/loomies-backend/repositories"
+
+// repos is the data access used by the middlewares, injected by the app (See SetRepositories)
+var repos repositories.Repositories
+
+// SetRepositories Replaces the repositories used by the middlewares
+func SetRepositories(repositories repositories.Repositories) {
+	repos = repositories
+}
@@ -0,0 +1,78 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Usage of the migrate command
+const Usage = `Usage: migrate <command>
+
+Commands:
+  up          Apply all the pending migrations
+  down [n]    Revert the last n applied migrations (1 by default)
+  status      List the migrations and when they were applied`
+
+// RunCommand runs the migrate subcommand with the given arguments (up, down [n] or status) and writes the result
+func RunCommand(ctx context.Context, migrator *Migrator, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing migrate command\n%s", Usage)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			fmt.Fprintf(out, "Applied %d %s\n", migration.Version, migration.Name)
+		}
+
+		if err == nil && len(applied) == 0 {
+			fmt.Fprintln(out, "The database is up to date")
+		}
+
+		return err
+	case "down":
+		steps := 1
+
+		if len(args) > 1 {
+			parsed, err := strconv.Atoi(args[1])
+			if err != nil || parsed <= 0 {
+				return fmt.Errorf("the amount of migrations to revert must be a positive integer\n%s", Usage)
+			}
+
+			steps = parsed
+		}
+
+		reverted, err := migrator.Down(ctx, steps)
+		for _, migration := range reverted {
+			fmt.Fprintf(out, "Reverted %d %s\n", migration.Version, migration.Name)
+		}
+
+		if err == nil && len(reverted) == 0 {
+			fmt.Fprintln(out, "There are no applied migrations")
+		}
+
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied at " + time.Unix(status.AppliedAt, 0).UTC().Format(time.RFC3339)
+			}
+
+			fmt.Fprintf(out, "%4d  %-30s %s\n", status.Version, status.Name, state)
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", args[0], Usage)
+	}
+}
@@ -0,0 +1,59 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Indexes used by the queries of the models
+var initialIndexes = []collectionIndex{
+	// Users are looked up by username and email (ignoring the case) and by their external identities
+	{Collection: "users", Keys: bson.D{{Key: "username", Value: 1}}, Unique: true, CaseInsensitive: true},
+	{Collection: "users", Keys: bson.D{{Key: "email", Value: 1}}, Unique: true, CaseInsensitive: true},
+	{Collection: "users", Keys: bson.D{{Key: "identities.provider", Value: 1}, {Key: "identities.subject", Value: 1}}},
+	{Collection: "authentication_codes", Keys: bson.D{{Key: "email", Value: 1}, {Key: "type", Value: 1}}},
+	{Collection: "oidc_states", Keys: bson.D{{Key: "state", Value: 1}, {Key: "provider", Value: 1}}, Unique: true},
+
+	// Map and combats
+	{Collection: "zones", Keys: bson.D{{Key: "coordinates", Value: "hashed"}}},
+	{Collection: "gyms", Keys: bson.D{{Key: "owner", Value: 1}}},
+	{Collection: "caught_loomies", Keys: bson.D{{Key: "owner", Value: 1}}},
+	{Collection: "gyms_challenges_register", Keys: bson.D{{Key: "attacker_id", Value: 1}, {Key: "gym_id", Value: 1}}},
+	{Collection: "gyms_challenges_register", Keys: bson.D{{Key: "attacker_id", Value: 1}, {Key: "is_active", Value: 1}}},
+
+	// Game content, the serials identify the rewards
+	{Collection: "base_loomies", Keys: bson.D{{Key: "serial", Value: 1}}, Unique: true},
+	{Collection: "items", Keys: bson.D{{Key: "serial", Value: 1}}, Unique: true},
+	{Collection: "loom_balls", Keys: bson.D{{Key: "serial", Value: 1}}, Unique: true},
+	{Collection: "achievements", Keys: bson.D{{Key: "serial", Value: 1}}, Unique: true},
+	{Collection: "quest_templates", Keys: bson.D{{Key: "serial", Value: 1}}, Unique: true},
+	{Collection: "game_settings", Keys: bson.D{{Key: "version", Value: 1}}, Unique: true},
+
+	// Social features
+	{Collection: "user_quests", Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "period_key", Value: 1}}},
+	{Collection: "friendships", Keys: bson.D{{Key: "requester_id", Value: 1}, {Key: "recipient_id", Value: 1}}},
+	{Collection: "friendships", Keys: bson.D{{Key: "recipient_id", Value: 1}, {Key: "status", Value: 1}}},
+	{Collection: "trades", Keys: bson.D{{Key: "proposer_id", Value: 1}, {Key: "updated_at", Value: -1}}},
+	{Collection: "trades", Keys: bson.D{{Key: "recipient_id", Value: 1}, {Key: "updated_at", Value: -1}}},
+	{Collection: "gifts", Keys: bson.D{{Key: "sender_id", Value: 1}, {Key: "sent_at", Value: -1}}},
+	{Collection: "gifts", Keys: bson.D{{Key: "recipient_id", Value: 1}, {Key: "opened", Value: 1}, {Key: "sent_at", Value: -1}}},
+
+	// Audit log queries by user or entity sorted by date
+	{Collection: "audit_events", Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	{Collection: "audit_events", Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	{Collection: "audit_events", Keys: bson.D{{Key: "entity_id", Value: 1}, {Key: "created_at", Value: -1}}},
+}
+
+// createIndexes creates the indexes required by the current queries
+var createIndexes = Migration{
+	Version: 1,
+	Name:    "create_indexes",
+	Up: func(ctx context.Context, database *mongo.Database) error {
+		return ensureIndexes(ctx, database, initialIndexes)
+	},
+	Down: func(ctx context.Context, database *mongo.Database) error {
+		return dropIndexes(ctx, database, initialIndexes)
+	},
+}
@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// The runs of the scheduled jobs are listed by job from the newest to the oldest
+var jobRunsIndexes = []collectionIndex{
+	{Collection: "job_runs", Keys: bson.D{{Key: "job", Value: 1}, {Key: "started_at", Value: -1}}},
+	{Collection: "job_runs", Keys: bson.D{{Key: "started_at", Value: -1}}},
+}
+
+// createJobRuns creates the indexes of the runs history of the scheduler
+var createJobRuns = Migration{
+	Version: 4,
+	Name:    "create_job_runs",
+	Up: func(ctx context.Context, database *mongo.Database) error {
+		return ensureIndexes(ctx, database, jobRunsIndexes)
+	},
+	Down: func(ctx context.Context, database *mongo.Database) error {
+		return dropIndexes(ctx, database, jobRunsIndexes)
+	},
+}
@@ -0,0 +1,178 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The zones created before the regions belong to Bucaramanga, Floridablanca and Piedecuesta
+const (
+	legacyRegionName     = "bucaramanga"
+	legacyRegionGridStep = 0.0035
+	legacyRegionTimezone = "America/Bogota"
+)
+
+// The zones are looked up by region and coordinates, and the regions by name and bounds
+var regionsIndexes = []collectionIndex{
+	{Collection: "regions", Keys: bson.D{{Key: "name", Value: 1}}, Unique: true},
+	{Collection: "regions", Keys: bson.D{{Key: "bounds.min_latitude", Value: 1}, {Key: "bounds.min_longitude", Value: 1}}},
+	{Collection: "zones", Keys: bson.D{{Key: "region", Value: 1}, {Key: "coordinates", Value: 1}}},
+}
+
+// withoutRegion "private" function to get the filter of the zones created before the regions
+func withoutRegion() bson.M {
+	return bson.M{"region": bson.M{"$exists": false}}
+}
+
+// createLegacyRegion "private" function to register the region of the zones without region using their frontiers as
+// bounds, returns a nil id if there are no zones without region
+func createLegacyRegion(ctx context.Context, database *mongo.Database) (primitive.ObjectID, error) {
+	zones := database.Collection("zones")
+
+	cursor, err := zones.Aggregate(ctx, bson.A{
+		bson.M{"$match": withoutRegion()},
+		bson.M{"$group": bson.M{
+			"_id":           nil,
+			"min_latitude":  bson.M{"$min": "$bottomFrontier"},
+			"min_longitude": bson.M{"$min": "$leftFrontier"},
+			"max_latitude":  bson.M{"$max": "$topFrontier"},
+			"max_longitude": bson.M{"$max": "$rightFrontier"},
+		}},
+	})
+
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	bounds := []bson.M{}
+	if err := cursor.All(ctx, &bounds); err != nil || len(bounds) == 0 {
+		return primitive.NilObjectID, err
+	}
+
+	delete(bounds[0], "_id")
+	regions := database.Collection("regions")
+
+	_, err = regions.UpdateOne(ctx, bson.M{"name": legacyRegionName}, bson.M{"$setOnInsert": bson.M{
+		"name":        legacyRegionName,
+		"bounds":      bounds[0],
+		"grid_step":   legacyRegionGridStep,
+		"timezone":    legacyRegionTimezone,
+		"spawn_table": bson.A{},
+		"created_at":  time.Now().Unix(),
+	}}, options.Update().SetUpsert(true))
+
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	var region struct {
+		Id primitive.ObjectID `bson:"_id"`
+	}
+
+	err = regions.FindOne(ctx, bson.M{"name": legacyRegionName}).Decode(&region)
+	return region.Id, err
+}
+
+// prefixZoneKeys "private" function to prefix the zone keys stored before the regions ("x,y") with the name of the
+// legacy region, like the keys of the visited zones and the last seen zones built since then ("name:x,y")
+func prefixZoneKeys(ctx context.Context, database *mongo.Database) error {
+	prefix := legacyRegionName + ":"
+	hasRegion := func(key string) bson.M {
+		return bson.M{"$gte": bson.A{bson.M{"$indexOfCP": bson.A{key, ":"}}, 0}}
+	}
+
+	_, err := database.Collection("user_quests").UpdateMany(ctx, bson.M{"event": "visit_zone"}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"seen_values": bson.M{"$map": bson.M{
+			"input": bson.M{"$ifNull": bson.A{"$seen_values", bson.A{}}},
+			"as":    "key",
+			"in":    bson.M{"$cond": bson.A{hasRegion("$$key"), "$$key", bson.M{"$concat": bson.A{prefix, "$$key"}}}},
+		}}}}},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("users").UpdateMany(ctx, bson.M{"last_seen_zone": bson.M{"$type": "string"}}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"last_seen_zone": bson.M{
+			"$cond": bson.A{hasRegion("$last_seen_zone"), "$last_seen_zone", bson.M{"$concat": bson.A{prefix, "$last_seen_zone"}}},
+		}}}},
+	})
+
+	return err
+}
+
+// unprefixZoneKeys "private" function to restore the zone keys of the legacy region to the format without regions
+func unprefixZoneKeys(ctx context.Context, database *mongo.Database) error {
+	prefix := legacyRegionName + ":"
+	withoutPrefix := func(key string) bson.M {
+		return bson.M{"$cond": bson.A{
+			bson.M{"$eq": bson.A{bson.M{"$indexOfCP": bson.A{key, prefix}}, 0}},
+			bson.M{"$substrCP": bson.A{key, len(prefix), bson.M{"$strLenCP": key}}},
+			key,
+		}}
+	}
+
+	_, err := database.Collection("user_quests").UpdateMany(ctx, bson.M{"event": "visit_zone"}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"seen_values": bson.M{"$map": bson.M{
+			"input": bson.M{"$ifNull": bson.A{"$seen_values", bson.A{}}},
+			"as":    "key",
+			"in":    withoutPrefix("$$key"),
+		}}}}},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection("users").UpdateMany(ctx, bson.M{"last_seen_zone": bson.M{"$type": "string"}}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"last_seen_zone": withoutPrefix("$last_seen_zone")}}},
+	})
+
+	return err
+}
+
+// createRegions creates the regions indexes, moves the existing zones to the legacy region and prefixes their keys
+var createRegions = Migration{
+	Version: 3,
+	Name:    "create_regions",
+	Up: func(ctx context.Context, database *mongo.Database) error {
+		if err := ensureIndexes(ctx, database, regionsIndexes); err != nil {
+			return err
+		}
+
+		regionId, err := createLegacyRegion(ctx, database)
+		if err != nil || regionId.IsZero() {
+			return err
+		}
+
+		_, err = database.Collection("zones").UpdateMany(ctx, withoutRegion(), bson.M{"$set": bson.M{"region": regionId}})
+		if err != nil {
+			return err
+		}
+
+		return prefixZoneKeys(ctx, database)
+	},
+	Down: func(ctx context.Context, database *mongo.Database) error {
+		if err := dropIndexes(ctx, database, regionsIndexes); err != nil {
+			return err
+		}
+
+		_, err := database.Collection("zones").UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"region": ""}})
+		if err != nil {
+			return err
+		}
+
+		if err := unprefixZoneKeys(ctx, database); err != nil {
+			return err
+		}
+
+		_, err = database.Collection("regions").DeleteOne(ctx, bson.M{"name": legacyRegionName})
+		return err
+	},
+}
@@ -0,0 +1,75 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Meters in a degree of latitude, used to convert the max distance of the pending trades
+const metersPerDegree = 111320
+
+// Collections with latitude and longitude fields that are searched by location
+var locatedCollections = []string{"gyms", "wild_loomies"}
+
+// The nearby gyms and wild loomies are searched with $nearSphere and $geoWithin
+var geoIndexes = []collectionIndex{
+	{Collection: "gyms", Keys: bson.D{{Key: "location", Value: "2dsphere"}}},
+	{Collection: "wild_loomies", Keys: bson.D{{Key: "location", Value: "2dsphere"}}},
+}
+
+// pendingTradesFilter "private" function to get the filter of the trades that can still be confirmed
+func pendingTradesFilter() bson.M {
+	return bson.M{"status": bson.M{"$in": bson.A{"proposed", "accepted"}}}
+}
+
+// geoJSONLocations stores the latitude and longitude of the gyms and wild loomies as GeoJSON points with a 2dsphere
+// index, and converts the max distance of the pending trades from degrees to meters
+var geoJSONLocations = Migration{
+	Version: 2,
+	Name:    "geojson_locations",
+	Up: func(ctx context.Context, database *mongo.Database) error {
+		setLocation := bson.A{bson.M{"$set": bson.M{"location": bson.M{
+			"type":        "Point",
+			"coordinates": bson.A{"$longitude", "$latitude"},
+		}}}}
+
+		for _, collection := range locatedCollections {
+			_, err := database.Collection(collection).UpdateMany(ctx, bson.M{"location": bson.M{"$exists": false}}, setLocation)
+			if err != nil {
+				return fmt.Errorf("unable to set the locations of %s: %w", collection, err)
+			}
+		}
+
+		if err := ensureIndexes(ctx, database, geoIndexes); err != nil {
+			return err
+		}
+
+		// The distances lower than a meter are still in degrees, so the migration can be applied again
+		filter := pendingTradesFilter()
+		filter["max_distance"] = bson.M{"$lt": 1}
+
+		_, err := database.Collection("trades").UpdateMany(ctx, filter, bson.M{"$mul": bson.M{"max_distance": metersPerDegree}})
+		return err
+	},
+	Down: func(ctx context.Context, database *mongo.Database) error {
+		if err := dropIndexes(ctx, database, geoIndexes); err != nil {
+			return err
+		}
+
+		for _, collection := range locatedCollections {
+			_, err := database.Collection(collection).UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"location": ""}})
+			if err != nil {
+				return fmt.Errorf("unable to remove the locations of %s: %w", collection, err)
+			}
+		}
+
+		filter := pendingTradesFilter()
+		filter["max_distance"] = bson.M{"$gte": 1}
+
+		_, err := database.Collection("trades").UpdateMany(ctx, filter, bson.M{"$mul": bson.M{"max_distance": 1.0 / metersPerDegree}})
+		return err
+	},
+}
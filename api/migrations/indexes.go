@@ -0,0 +1,154 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Codes of the errors returned when the collection or the index doesn't exist
+const (
+	namespaceNotFoundCode = 26
+	indexNotFoundCode     = 27
+)
+
+// collectionIndex is an index created by the migrations
+type collectionIndex struct {
+	Collection string
+	Keys       bson.D
+	Unique     bool
+	// Compare the strings ignoring the case, like the username and email lookups do
+	CaseInsensitive bool
+}
+
+// name "private" function to get the name mongo gives to the index by default (e.g. gym_id_1_attacker_id_1), so the
+// indexes with the same keys created by other tools (like the bulk script) are reused
+func (index collectionIndex) name() string {
+	parts := []string{}
+
+	for _, key := range index.Keys {
+		parts = append(parts, fmt.Sprintf("%s_%v", key.Key, key.Value))
+	}
+
+	return strings.Join(parts, "_")
+}
+
+// caseInsensitiveCollation compares the strings ignoring the case, it must be the same used by the queries
+var caseInsensitiveCollation = &options.Collation{Locale: "en", Strength: 2}
+
+// duplicatedValue is a value of an unique index shared by several documents
+type duplicatedValue struct {
+	Value interface{}   `bson:"_id"`
+	Ids   []interface{} `bson:"ids"`
+}
+
+// findDuplicates "private" function to get the values of the (single key) index shared by several documents, comparing
+// them with the collation of the index
+func findDuplicates(ctx context.Context, database *mongo.Database, index collectionIndex) ([]duplicatedValue, error) {
+	field := index.Keys[0].Key
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$" + field, "ids": bson.M{"$push": "$_id"}}}},
+		{{Key: "$match", Value: bson.M{"ids.1": bson.M{"$exists": true}}}},
+	}
+
+	aggregateOptions := options.Aggregate()
+	if index.CaseInsensitive {
+		aggregateOptions.SetCollation(caseInsensitiveCollation)
+	}
+
+	cursor, err := database.Collection(index.Collection).Aggregate(ctx, pipeline, aggregateOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	duplicates := []duplicatedValue{}
+	err = cursor.All(ctx, &duplicates)
+	return duplicates, err
+}
+
+// duplicatesError "private" function to list the documents that must be fixed before creating the unique index
+func duplicatesError(index collectionIndex, duplicates []duplicatedValue) error {
+	lines := []string{}
+
+	for _, duplicate := range duplicates {
+		ids := []string{}
+		for _, id := range duplicate.Ids {
+			if objectId, ok := id.(primitive.ObjectID); ok {
+				ids = append(ids, objectId.Hex())
+			} else {
+				ids = append(ids, fmt.Sprint(id))
+			}
+		}
+
+		lines = append(lines, fmt.Sprintf("  %v: %s", duplicate.Value, strings.Join(ids, ", ")))
+	}
+
+	return fmt.Errorf(
+		"unable to create the unique index %s of %s, these values are shared by several documents (ignoring the case: %t), "+
+			"rename or remove them and run the migrations again:\n%s",
+		index.name(), index.Collection, index.CaseInsensitive, strings.Join(lines, "\n"),
+	)
+}
+
+// ensureIndexes "private" function to create the indexes, the existing ones with the same keys and options are kept
+func ensureIndexes(ctx context.Context, database *mongo.Database, indexes []collectionIndex) error {
+	for _, index := range indexes {
+		indexOptions := options.Index().SetName(index.name())
+
+		if index.Unique {
+			indexOptions.SetUnique(true)
+		}
+
+		if index.CaseInsensitive {
+			indexOptions.SetCollation(caseInsensitiveCollation)
+		}
+
+		// The values that only differ in the case can't be in the case insensitive indexes (Eg. users registered
+		// before the indexes existed), they are reported instead of failing with the first one
+		if index.Unique && len(index.Keys) == 1 {
+			duplicates, err := findDuplicates(ctx, database, index)
+			if err != nil {
+				return fmt.Errorf("unable to check the duplicates of the index %s of %s: %w", index.name(), index.Collection, err)
+			}
+
+			if len(duplicates) > 0 {
+				return duplicatesError(index, duplicates)
+			}
+		}
+
+		_, err := database.Collection(index.Collection).Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    index.Keys,
+			Options: indexOptions,
+		})
+
+		if err != nil {
+			return fmt.Errorf("unable to create the index %s of %s: %w", index.name(), index.Collection, err)
+		}
+	}
+
+	return nil
+}
+
+// dropIndexes "private" function to remove the indexes, the missing ones are ignored
+func dropIndexes(ctx context.Context, database *mongo.Database, indexes []collectionIndex) error {
+	for _, index := range indexes {
+		_, err := database.Collection(index.Collection).Indexes().DropOne(ctx, index.name())
+
+		var commandError mongo.CommandError
+		if errors.As(err, &commandError) && (commandError.Code == namespaceNotFoundCode || commandError.Code == indexNotFoundCode) {
+			continue
+		}
+
+		if err != nil {
+			return fmt.Errorf("unable to drop the index %s of %s: %w", index.name(), index.Collection, err)
+		}
+	}
+
+	return nil
+}
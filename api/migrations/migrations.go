@@ -0,0 +1,243 @@
+// Package migrations creates and updates the collections and indexes of the database. The migrations are applied in
+// order and recorded in the schema_migrations collection, so each one runs once. They must be idempotent because
+// several instances can apply them at the same time when they start
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migration is a versioned change of the database. Down reverts the changes of Up
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, database *mongo.Database) error
+	Down    func(ctx context.Context, database *mongo.Database) error
+}
+
+// All the migrations of the server, new migrations must be added at the end with the next version
+var All = []Migration{
+	createIndexes,
+	geoJSONLocations,
+	createRegions,
+	createJobRuns,
+}
+
+// Record is stored in the schema_migrations collection when a migration is applied
+type Record struct {
+	Version   int    `json:"version"    bson:"_id"`
+	Name      string `json:"name"       bson:"name"`
+	AppliedAt int64  `json:"applied_at" bson:"applied_at"`
+}
+
+// Status tells if a migration was applied and when
+type Status struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt int64
+}
+
+// History stores the applied migrations
+type History interface {
+	// Applied returns the records of the applied migrations sorted by version
+	Applied(ctx context.Context) ([]Record, error)
+	// Add records the migration, recording the same version twice is not an error
+	Add(ctx context.Context, record Record) error
+	Remove(ctx context.Context, version int) error
+}
+
+// Migrator applies and reverts the migrations
+type Migrator struct {
+	Database   *mongo.Database
+	Migrations []Migration
+	History    History
+}
+
+// New creates a migrator with all the migrations of the server recorded in the schema_migrations collection
+func New(database *mongo.Database) *Migrator {
+	return &Migrator{
+		Database:   database,
+		Migrations: All,
+		History:    mongoHistory{collection: database.Collection("schema_migrations")},
+	}
+}
+
+// validate "private" function to check the versions of the migrations are positive and in ascending order
+func (migrator *Migrator) validate() error {
+	previous := 0
+
+	for _, migration := range migrator.Migrations {
+		if migration.Version <= previous {
+			return fmt.Errorf("migration %d (%s) is out of order", migration.Version, migration.Name)
+		}
+
+		previous = migration.Version
+	}
+
+	return nil
+}
+
+// applied "private" function to get the applied migrations by version
+func (migrator *Migrator) applied(ctx context.Context) (map[int]Record, error) {
+	records, err := migrator.History.Applied(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the applied migrations: %w", err)
+	}
+
+	applied := make(map[int]Record, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+
+	return applied, nil
+}
+
+// Up applies the pending migrations in order and returns the applied ones. It stops at the first failure
+func (migrator *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	if err := migrator.validate(); err != nil {
+		return nil, err
+	}
+
+	applied, err := migrator.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+
+	for _, migration := range migrator.Migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		if err := migration.Up(ctx, migrator.Database); err != nil {
+			return done, fmt.Errorf("unable to apply migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+
+		record := Record{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now().Unix()}
+		if err := migrator.History.Add(ctx, record); err != nil {
+			return done, fmt.Errorf("unable to record migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// Down reverts the given amount of applied migrations, from the newest to the oldest, and returns the reverted ones
+func (migrator *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if err := migrator.validate(); err != nil {
+		return nil, err
+	}
+
+	applied, err := migrator.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]int, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+	done := []Migration{}
+
+	for _, version := range versions {
+		if len(done) >= steps {
+			break
+		}
+
+		migration, ok := migrator.find(version)
+		if !ok {
+			return done, fmt.Errorf("migration %d (%s) is not known by this version of the server", version, applied[version].Name)
+		}
+
+		if err := migration.Down(ctx, migrator.Database); err != nil {
+			return done, fmt.Errorf("unable to revert migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+
+		if err := migrator.History.Remove(ctx, migration.Version); err != nil {
+			return done, fmt.Errorf("unable to remove the record of migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// Status returns the state of all the migrations sorted by version
+func (migrator *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := migrator.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []Status{}
+
+	for _, migration := range migrator.Migrations {
+		record, ok := applied[migration.Version]
+		statuses = append(statuses, Status{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Applied:   ok,
+			AppliedAt: record.AppliedAt,
+		})
+	}
+
+	return statuses, nil
+}
+
+// find "private" function to get the migration with the given version
+func (migrator *Migrator) find(version int) (Migration, bool) {
+	for _, migration := range migrator.Migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+
+	return Migration{}, false
+}
+
+// mongoHistory stores the applied migrations in a collection
+type mongoHistory struct {
+	collection *mongo.Collection
+}
+
+func (history mongoHistory) Applied(ctx context.Context) ([]Record, error) {
+	records := []Record{}
+
+	cursor, err := history.collection.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return records, err
+	}
+
+	err = cursor.All(ctx, &records)
+	return records, err
+}
+
+func (history mongoHistory) Add(ctx context.Context, record Record) error {
+	_, err := history.collection.InsertOne(ctx, record)
+
+	// Other instance applied the same migration at the same time
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+
+	return err
+}
+
+func (history mongoHistory) Remove(ctx context.Context, version int) error {
+	_, err := history.collection.DeleteOne(ctx, bson.D{{Key: "_id", Value: version}})
+	return err
+}
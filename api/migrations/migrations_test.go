@@ -0,0 +1,185 @@
+package migrations
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ## Helper functions
+// memoryHistory stores the applied migrations in a map
+type memoryHistory struct {
+	records map[int]Record
+}
+
+func (history *memoryHistory) Applied(ctx context.Context) ([]Record, error) {
+	records := []Record{}
+	for _, record := range history.records {
+		records = append(records, record)
+	}
+
+	sort.Slice(records, func(i, j int) bool { return records[i].Version < records[j].Version })
+	return records, nil
+}
+
+func (history *memoryHistory) Add(ctx context.Context, record Record) error {
+	history.records[record.Version] = record
+	return nil
+}
+
+func (history *memoryHistory) Remove(ctx context.Context, version int) error {
+	delete(history.records, version)
+	return nil
+}
+
+// newTestMigrator creates a migrator with the given versions, the calls to the migrations are written to the log
+func newTestMigrator(log *[]string, failing int, versions ...int) *Migrator {
+	migrations := []Migration{}
+
+	for _, version := range versions {
+		version := version
+		migrations = append(migrations, Migration{
+			Version: version,
+			Name:    "migration",
+			Up: func(ctx context.Context, database *mongo.Database) error {
+				if version == failing {
+					return errors.New("failed")
+				}
+
+				*log = append(*log, "up", strconv.Itoa(version))
+				return nil
+			},
+			Down: func(ctx context.Context, database *mongo.Database) error {
+				*log = append(*log, "down", strconv.Itoa(version))
+				return nil
+			},
+		})
+	}
+
+	return &Migrator{Migrations: migrations, History: &memoryHistory{records: map[int]Record{}}}
+}
+
+// ## Tests
+
+// TestMigrationsUpAndDown tests the migrations are applied once in order and reverted from the newest
+func TestMigrationsUpAndDown(t *testing.T) {
+	c := require.New(t)
+	ctx := context.Background()
+	log := []string{}
+	migrator := newTestMigrator(&log, 0, 1, 2, 3)
+
+	// 1. Apply all the migrations
+	applied, err := migrator.Up(ctx)
+	c.NoError(err)
+	c.Equal(3, len(applied))
+	c.Equal([]string{"up", "1", "up", "2", "up", "3"}, log)
+
+	// 2. The applied migrations are skipped
+	applied, err = migrator.Up(ctx)
+	c.NoError(err)
+	c.Empty(applied)
+
+	// 3. Revert the last two migrations
+	reverted, err := migrator.Down(ctx, 2)
+	c.NoError(err)
+	c.Equal(2, len(reverted))
+	c.Equal([]string{"down", "3", "down", "2"}, log[6:])
+
+	statuses, err := migrator.Status(ctx)
+	c.NoError(err)
+	c.True(statuses[0].Applied)
+	c.False(statuses[1].Applied)
+	c.False(statuses[2].Applied)
+
+	// 4. Only the pending migrations are applied again
+	applied, err = migrator.Up(ctx)
+	c.NoError(err)
+	c.Equal(2, len(applied))
+	c.Equal(2, applied[0].Version)
+}
+
+// TestMigrationsErrors tests the failed migrations are not recorded and the versions must be ordered
+func TestMigrationsErrors(t *testing.T) {
+	c := require.New(t)
+	ctx := context.Background()
+	log := []string{}
+
+	// 1. The migrations after the failed one are not applied
+	migrator := newTestMigrator(&log, 2, 1, 2, 3)
+	applied, err := migrator.Up(ctx)
+	c.Error(err)
+	c.Contains(err.Error(), "unable to apply migration 2")
+	c.Equal(1, len(applied))
+
+	statuses, _ := migrator.Status(ctx)
+	c.True(statuses[0].Applied)
+	c.False(statuses[1].Applied)
+
+	// 2. The versions must be in ascending order
+	migrator = newTestMigrator(&log, 0, 1, 3, 2)
+	_, err = migrator.Up(ctx)
+	c.Error(err)
+	c.Contains(err.Error(), "out of order")
+}
+
+// TestMigrateCommand tests the output and the arguments of the migrate command
+func TestMigrateCommand(t *testing.T) {
+	c := require.New(t)
+	ctx := context.Background()
+	log := []string{}
+	migrator := newTestMigrator(&log, 0, 1, 2)
+	out := &bytes.Buffer{}
+
+	c.NoError(RunCommand(ctx, migrator, []string{"up"}, out))
+	c.Contains(out.String(), "Applied 1 migration")
+	c.Contains(out.String(), "Applied 2 migration")
+
+	out.Reset()
+	c.NoError(RunCommand(ctx, migrator, []string{"down"}, out))
+	c.Equal("Reverted 2 migration\n", out.String())
+
+	out.Reset()
+	c.NoError(RunCommand(ctx, migrator, []string{"status"}, out))
+	c.Contains(out.String(), "applied at")
+	c.Contains(out.String(), "pending")
+
+	c.Error(RunCommand(ctx, migrator, []string{"down", "zero"}, out))
+	c.Error(RunCommand(ctx, migrator, []string{"sideways"}, out))
+	c.Error(RunCommand(ctx, migrator, nil, out))
+}
+
+// TestInitialIndexesNames tests the index names match the ones given by mongo
+func TestInitialIndexesNames(t *testing.T) {
+	c := require.New(t)
+	index := collectionIndex{Keys: bson.D{{Key: "attacker_id", Value: 1}, {Key: "gym_id", Value: 1}}}
+	c.Equal("attacker_id_1_gym_id_1", index.name())
+
+	index = collectionIndex{Keys: bson.D{{Key: "coordinates", Value: "hashed"}}}
+	c.Equal("coordinates_hashed", index.name())
+
+	names := map[string]bool{}
+	for _, index := range initialIndexes {
+		key := index.Collection + "." + index.name()
+		c.False(names[key], "duplicated index %s", key)
+		names[key] = true
+	}
+}
+
+// TestDuplicatesError tests the conflicting documents are listed when an unique index can't be created
+func TestDuplicatesError(t *testing.T) {
+	c := require.New(t)
+	index := collectionIndex{Collection: "users", Keys: bson.D{{Key: "email", Value: 1}}, Unique: true, CaseInsensitive: true}
+	first, second := primitive.NewObjectID(), primitive.NewObjectID()
+
+	err := duplicatesError(index, []duplicatedValue{{Value: "Ash@loomies.com", Ids: []interface{}{first, second}}})
+	c.ErrorContains(err, "unique index email_1 of users")
+	c.ErrorContains(err, "Ash@loomies.com: "+first.Hex()+", "+second.Hex())
+}
@@ -0,0 +1,179 @@
+package models
+
+import (
+	"context"
+
+	"github.com/PedroChaparro/loomies-backend/audit"
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetUserCombats Returns the gyms challenges registers of the given user
+func GetUserCombats(userId primitive.ObjectID) ([]interfaces.GymChallengesRegister, error) {
+	combats := []interfaces.GymChallengesRegister{}
+	cursor, err := GymsChallengesCollection.Find(context.TODO(), bson.D{{Key: "attacker_id", Value: userId}})
+
+	if err != nil {
+		return combats, err
+	}
+
+	err = cursor.All(context.TODO(), &combats)
+	return combats, err
+}
+
+// Steps of the account deletion unit of work
+const (
+	DeleteAccountReleaseGymsStep = "delete_account.release_gyms"
+	DeleteAccountDataStep        = "delete_account.delete_data"
+	DeleteAccountUserStep        = "delete_account.delete_user"
+)
+
+// DeleteUserAccount Deletes the user and all the data linked to the account. The owned gyms become neutral,
+// their protectors are kept without owner (like the generated gyms protectors) and the other caught loomies are deleted.
+// The writes are done in a single unit of work, so the account is not partially deleted if one of them fails
+func DeleteUserAccount(ctx context.Context, user interfaces.User) error {
+	return RunUnitOfWork(ctx, func(uow *UnitOfWork) error {
+		gyms := []interfaces.Gym{}
+		protectors := []primitive.ObjectID{}
+
+		err := uow.Step(DeleteAccountReleaseGymsStep, func(ctx context.Context) error {
+			cursor, err := GymsCollection.Find(ctx, bson.D{{Key: "owner", Value: user.Id}})
+			if err != nil {
+				return err
+			}
+
+			if err := cursor.All(ctx, &gyms); err != nil {
+				return err
+			}
+
+			for _, gym := range gyms {
+				protectors = append(protectors, gym.Protectors...)
+			}
+
+			// Release the owned gyms and their protectors
+			_, err = GymsCollection.UpdateMany(
+				ctx,
+				bson.D{{Key: "owner", Value: user.Id}},
+				bson.D{{Key: "$unset", Value: bson.D{{Key: "owner", Value: ""}}}},
+			)
+			if err != nil {
+				return err
+			}
+
+			_, err = CaughtLoomiesCollection.UpdateMany(
+				ctx,
+				bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: protectors}}}},
+				bson.D{{Key: "$unset", Value: bson.D{{Key: "owner", Value: ""}}}},
+			)
+			if err != nil {
+				return err
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		err = uow.Step(DeleteAccountDataStep, func(ctx context.Context) error {
+			var err error
+
+			// Delete the remaining loomies and the references to the user
+			_, err = CaughtLoomiesCollection.DeleteMany(ctx, bson.D{{Key: "owner", Value: user.Id}})
+			if err != nil {
+				return err
+			}
+
+			_, err = WildLoomiesCollection.UpdateMany(
+				ctx,
+				bson.D{{Key: "captured_by", Value: user.Id}},
+				bson.D{{Key: "$pull", Value: bson.D{{Key: "captured_by", Value: user.Id}}}},
+			)
+			if err != nil {
+				return err
+			}
+
+			_, err = GymsCollection.UpdateMany(
+				ctx,
+				bson.D{{Key: "rewards_claimed_by", Value: user.Id}},
+				bson.D{{Key: "$pull", Value: bson.D{{Key: "rewards_claimed_by", Value: user.Id}}}},
+			)
+			if err != nil {
+				return err
+			}
+
+			_, err = GymsChallengesCollection.DeleteMany(ctx, bson.D{{Key: "attacker_id", Value: user.Id}})
+			if err != nil {
+				return err
+			}
+
+			_, err = AuthenticationCodesCollection.DeleteMany(ctx, bson.D{{Key: "email", Value: user.Email}})
+			if err != nil {
+				return err
+			}
+
+			_, err = UserQuestsCollection.DeleteMany(ctx, bson.D{{Key: "user_id", Value: user.Id}})
+			if err != nil {
+				return err
+			}
+
+			_, err = FriendshipsCollection.DeleteMany(ctx, bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: "requester_id", Value: user.Id}},
+				bson.D{{Key: "recipient_id", Value: user.Id}},
+			}}})
+			if err != nil {
+				return err
+			}
+
+			_, err = TradesCollection.DeleteMany(ctx, bson.D{tradeParticipantFilter(user.Id)})
+			if err != nil {
+				return err
+			}
+
+			_, err = GiftsCollection.DeleteMany(ctx, bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: "sender_id", Value: user.Id}},
+				bson.D{{Key: "recipient_id", Value: user.Id}},
+			}}})
+			if err != nil {
+				return err
+			}
+
+			_, err = UserCollection.UpdateMany(
+				ctx,
+				bson.D{{Key: "blocked_users", Value: user.Id}},
+				bson.D{{Key: "$pull", Value: bson.D{{Key: "blocked_users", Value: user.Id}}}},
+			)
+			if err != nil {
+				return err
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		err = uow.Step(DeleteAccountUserStep, func(ctx context.Context) error {
+			_, err := UserCollection.DeleteOne(ctx, bson.D{{Key: "_id", Value: user.Id}})
+			return err
+		})
+
+		if err != nil {
+			return err
+		}
+
+		audit.Record(uow.Context(), interfaces.AuditEvent{
+			ActorId:  user.Id,
+			UserId:   user.Id,
+			Action:   "user.delete",
+			Entity:   "users",
+			EntityId: user.Id,
+			Before:   bson.M{"username": user.Username, "released_gyms": len(gyms), "released_protectors": protectors},
+		})
+
+		return nil
+	})
+}
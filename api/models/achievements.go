@@ -0,0 +1,108 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/audit"
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Counters used by the achievements definitions
+const (
+	AchievementCapturesCounter          = repositories.AchievementCapturesCounter
+	AchievementFusionsCounter           = repositories.AchievementFusionsCounter
+	AchievementGymVictoriesCounter      = repositories.AchievementGymVictoriesCounter
+	AchievementFlawlessVictoriesCounter = repositories.AchievementFlawlessVictoriesCounter
+	AchievementOwnedGymsCounter         = repositories.AchievementOwnedGymsCounter
+	AchievementClaimedRewardsCounter    = repositories.AchievementClaimedRewardsCounter
+)
+
+// GetAchievements Returns all the achievements definitions sorted by serial
+func GetAchievements() ([]interfaces.Achievement, error) {
+	achievements := []interfaces.Achievement{}
+
+	cursor, err := AchievementsCollection.Find(context.TODO(), bson.D{}, options.Find().SetSort(bson.D{{Key: "serial", Value: 1}}))
+	if err != nil {
+		return achievements, err
+	}
+
+	err = cursor.All(context.TODO(), &achievements)
+	return achievements, err
+}
+
+// IncrementAchievementCounter Increments the given counter of the user and returns the achievements unlocked by the change
+func IncrementAchievementCounter(ctx context.Context, userId primitive.ObjectID, counter string, amount int) ([]interfaces.Achievement, error) {
+	return updateAchievementCounter(ctx, userId, bson.D{{Key: "$inc", Value: bson.D{{Key: "achievement_counters." + counter, Value: amount}}}})
+}
+
+// SetAchievementCounterMax Updates the given counter of the user if the value is greater than the current one (Used by
+// the counters that keep a record, like the owned gyms at once) and returns the achievements unlocked by the change
+func SetAchievementCounterMax(ctx context.Context, userId primitive.ObjectID, counter string, value int) ([]interfaces.Achievement, error) {
+	return updateAchievementCounter(ctx, userId, bson.D{{Key: "$max", Value: bson.D{{Key: "achievement_counters." + counter, Value: value}}}})
+}
+
+// updateAchievementCounter "private" function to apply the update to the counters and unlock the reached achievements
+func updateAchievementCounter(ctx context.Context, userId primitive.ObjectID, update bson.D) ([]interfaces.Achievement, error) {
+	var user interfaces.User
+	unlocked := []interfaces.Achievement{}
+
+	err := UserCollection.FindOneAndUpdate(
+		ctx,
+		bson.D{{Key: "_id", Value: userId}},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+
+	if err != nil {
+		return unlocked, err
+	}
+
+	achievements, err := GetAchievements()
+	if err != nil {
+		return unlocked, err
+	}
+
+	for _, achievement := range achievements {
+		if user.AchievementCounters[achievement.Counter] < achievement.Goal {
+			continue
+		}
+
+		// The filter avoids unlocking the same achievement twice on concurrent updates
+		result, err := UserCollection.UpdateOne(
+			ctx,
+			bson.D{
+				{Key: "_id", Value: userId},
+				{Key: "achievements.achievement_id", Value: bson.D{{Key: "$ne", Value: achievement.Id}}},
+			},
+			bson.D{{Key: "$push", Value: bson.D{{Key: "achievements", Value: interfaces.UserAchievement{
+				AchievementId: achievement.Id,
+				UnlockedAt:    time.Now().Unix(),
+			}}}}},
+		)
+
+		if err != nil {
+			return unlocked, err
+		}
+
+		if result.ModifiedCount == 0 {
+			continue
+		}
+
+		audit.Record(ctx, interfaces.AuditEvent{
+			UserId:   userId,
+			Action:   "user.unlock_achievement",
+			Entity:   "users",
+			EntityId: userId,
+			After:    bson.M{"achievement_id": achievement.Id, "name": achievement.Name},
+		})
+
+		unlocked = append(unlocked, achievement)
+	}
+
+	return unlocked, nil
+}
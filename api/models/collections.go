@@ -1,19 +1,57 @@
 package models
 
-import (
-	"github.com/PedroChaparro/loomies-backend/configuration"
-	"go.mongodb.org/mongo-driver/mongo"
+import "go.mongodb.org/mongo-driver/mongo"
+
+// Collections used by the mongo repositories, they are set by UseDatabase
+var (
+	UserCollection                *mongo.Collection
+	CaughtLoomiesCollection       *mongo.Collection
+	ZonesCollection               *mongo.Collection
+	BaseLoomiesCollection         *mongo.Collection
+	WildLoomiesCollection         *mongo.Collection
+	GymsCollection                *mongo.Collection
+	ItemsCollection               *mongo.Collection
+	LoomballsCollection           *mongo.Collection
+	AuthenticationCodesCollection *mongo.Collection
+	LoomieTypesCollection         *mongo.Collection
+	LoomieRaritiesCollection      *mongo.Collection
+	GymsChallengesCollection      *mongo.Collection
+	OIDCStatesCollection          *mongo.Collection
+	AchievementsCollection        *mongo.Collection
+	QuestTemplatesCollection      *mongo.Collection
+	UserQuestsCollection          *mongo.Collection
+	FriendshipsCollection         *mongo.Collection
+	TradesCollection              *mongo.Collection
+	GiftTableCollection           *mongo.Collection
+	GiftsCollection               *mongo.Collection
+	GameSettingsCollection        *mongo.Collection
+	RegionsCollection             *mongo.Collection
+	JobRunsCollection             *mongo.Collection
 )
 
-var UserCollection *mongo.Collection = configuration.ConnectToMongoCollection("users")
-var CaughtLoomiesCollection *mongo.Collection = configuration.ConnectToMongoCollection("caught_loomies")
-var ZonesCollection = configuration.ConnectToMongoCollection("zones")
-var BaseLoomiesCollection = configuration.ConnectToMongoCollection("base_loomies")
-var WildLoomiesCollection = configuration.ConnectToMongoCollection("wild_loomies")
-var GymsCollection = configuration.ConnectToMongoCollection("gyms")
-var ItemsCollection = configuration.ConnectToMongoCollection("items")
-var LoomballsCollection = configuration.ConnectToMongoCollection("loom_balls")
-var AuthenticationCodesCollection = configuration.ConnectToMongoCollection("authentication_codes")
-var LoomieTypesCollection = configuration.ConnectToMongoCollection("loomie_types")
-var LoomieRaritiesCollection = configuration.ConnectToMongoCollection("loomie_rarities")
-var GymsChallengesCollection = configuration.ConnectToMongoCollection("gyms_challenges_register")
+// UseDatabase Sets the collections of the given database, it must be called before using the mongo repositories
+func UseDatabase(database *mongo.Database) {
+	UserCollection = database.Collection("users")
+	CaughtLoomiesCollection = database.Collection("caught_loomies")
+	ZonesCollection = database.Collection("zones")
+	BaseLoomiesCollection = database.Collection("base_loomies")
+	WildLoomiesCollection = database.Collection("wild_loomies")
+	GymsCollection = database.Collection("gyms")
+	ItemsCollection = database.Collection("items")
+	LoomballsCollection = database.Collection("loom_balls")
+	AuthenticationCodesCollection = database.Collection("authentication_codes")
+	LoomieTypesCollection = database.Collection("loomie_types")
+	LoomieRaritiesCollection = database.Collection("loomie_rarities")
+	GymsChallengesCollection = database.Collection("gyms_challenges_register")
+	OIDCStatesCollection = database.Collection("oidc_states")
+	AchievementsCollection = database.Collection("achievements")
+	QuestTemplatesCollection = database.Collection("quest_templates")
+	UserQuestsCollection = database.Collection("user_quests")
+	FriendshipsCollection = database.Collection("friendships")
+	TradesCollection = database.Collection("trades")
+	GiftTableCollection = database.Collection("gift_table")
+	GiftsCollection = database.Collection("gifts")
+	GameSettingsCollection = database.Collection("game_settings")
+	RegionsCollection = database.Collection("regions")
+	JobRunsCollection = database.Collection("job_runs")
+}
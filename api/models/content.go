@@ -0,0 +1,183 @@
+package models
+
+import (
+	"context"
+
+	"github.com/PedroChaparro/loomies-backend/audit"
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetContentDocuments Decodes all the documents of the given game content collection sorted by the given field
+func GetContentDocuments(collection *mongo.Collection, sortBy string, results interface{}) error {
+	cursor, err := collection.Find(
+		context.TODO(),
+		bson.D{},
+		options.Find().SetSort(bson.D{{Key: sortBy, Value: 1}}),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return cursor.All(context.TODO(), results)
+}
+
+// InsertContentDocument Inserts the document in the given game content collection and returns its id
+func InsertContentDocument(ctx context.Context, collection *mongo.Collection, document interface{}) (primitive.ObjectID, error) {
+	result, err := collection.InsertOne(ctx, document)
+
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	id := result.InsertedID.(primitive.ObjectID)
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		Action:   "content.create",
+		Entity:   collection.Name(),
+		EntityId: id,
+		After:    document,
+	})
+
+	return id, nil
+}
+
+// ReplaceContentDocument Replaces the document with the given id, returns mongo.ErrNoDocuments if it doesn't exist
+func ReplaceContentDocument(ctx context.Context, collection *mongo.Collection, id primitive.ObjectID, document interface{}) error {
+	var before bson.M
+	err := collection.FindOneAndReplace(ctx, bson.D{{Key: "_id", Value: id}}, document).Decode(&before)
+
+	if err != nil {
+		return err
+	}
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		Action:   "content.update",
+		Entity:   collection.Name(),
+		EntityId: id,
+		Before:   before,
+		After:    document,
+	})
+
+	return nil
+}
+
+// DeleteContentDocument Deletes the document with the given id, returns mongo.ErrNoDocuments if it doesn't exist
+func DeleteContentDocument(ctx context.Context, collection *mongo.Collection, id primitive.ObjectID) error {
+	var before bson.M
+	err := collection.FindOneAndDelete(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&before)
+
+	if err != nil {
+		return err
+	}
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		Action:   "content.delete",
+		Entity:   collection.Name(),
+		EntityId: id,
+		Before:   before,
+	})
+
+	return nil
+}
+
+// IsContentFieldInUse Returns true if other document (different from exceptId) has the given value in the given field
+func IsContentFieldInUse(collection *mongo.Collection, field string, value interface{}, exceptId primitive.ObjectID) (bool, error) {
+	count, err := collection.CountDocuments(context.TODO(), bson.D{
+		{Key: field, Value: value},
+		{Key: "_id", Value: bson.D{{Key: "$ne", Value: exceptId}}},
+	})
+
+	return count > 0, err
+}
+
+// IsRewardSerialInUse Returns true if other item or loom ball (different from exceptId) has the given serial.
+// Items and loom balls share the serials because both can be gym rewards
+func IsRewardSerialInUse(serial int, exceptId primitive.ObjectID) (bool, error) {
+	inUse, err := IsContentFieldInUse(ItemsCollection, "serial", serial, exceptId)
+
+	if err != nil || inUse {
+		return inUse, err
+	}
+
+	return IsContentFieldInUse(LoomballsCollection, "serial", serial, exceptId)
+}
+
+// GetLoomieTypesByNames Returns the loomie types with the given names
+func GetLoomieTypesByNames(names []string) ([]interfaces.LoomieType, error) {
+	loomieTypes := []interfaces.LoomieType{}
+
+	if len(names) == 0 {
+		return loomieTypes, nil
+	}
+
+	cursor, err := LoomieTypesCollection.Find(context.TODO(), bson.D{{Key: "name", Value: bson.D{{Key: "$in", Value: names}}}})
+
+	if err != nil {
+		return loomieTypes, err
+	}
+
+	err = cursor.All(context.TODO(), &loomieTypes)
+	return loomieTypes, err
+}
+
+// GetLoomieRarityByName Returns the loomie rarity with the given name
+func GetLoomieRarityByName(name string) (interfaces.LoomieRarity, error) {
+	var rarity interfaces.LoomieRarity
+	err := LoomieRaritiesCollection.FindOne(context.TODO(), bson.D{{Key: "name", Value: name}}).Decode(&rarity)
+	return rarity, err
+}
+
+// IsLoomieTypeInUse Returns true if any base loomie has the given type
+func IsLoomieTypeInUse(typeId primitive.ObjectID) (bool, error) {
+	count, err := BaseLoomiesCollection.CountDocuments(context.TODO(), bson.D{{Key: "types", Value: typeId}})
+	return count > 0, err
+}
+
+// IsLoomieRarityInUse Returns true if any base loomie has the given rarity
+func IsLoomieRarityInUse(rarityId primitive.ObjectID) (bool, error) {
+	count, err := BaseLoomiesCollection.CountDocuments(context.TODO(), bson.D{{Key: "rarity", Value: rarityId}})
+	return count > 0, err
+}
+
+// IsRewardInUse Returns true if any user has the reward in its inventory or any gym is giving it as reward
+func IsRewardInUse(rewardId primitive.ObjectID) (bool, error) {
+	count, err := UserCollection.CountDocuments(context.TODO(), bson.D{{Key: "items.item_id", Value: rewardId}})
+
+	if err != nil || count > 0 {
+		return count > 0, err
+	}
+
+	count, err = GymsCollection.CountDocuments(context.TODO(), bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "current_players_rewards.reward_id", Value: rewardId}},
+		bson.D{{Key: "current_owners_rewards.reward_id", Value: rewardId}},
+	}}})
+
+	return count > 0, err
+}
+
+// RemoveLoomieTypeReferences Removes the given type from the strong against types of the other types
+func RemoveLoomieTypeReferences(ctx context.Context, typeId primitive.ObjectID) error {
+	result, err := LoomieTypesCollection.UpdateMany(
+		ctx,
+		bson.D{{Key: "strong_against", Value: typeId}},
+		bson.D{{Key: "$pull", Value: bson.D{{Key: "strong_against", Value: typeId}}}},
+	)
+
+	if err != nil {
+		return err
+	}
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		Action:   "content.remove_references",
+		Entity:   LoomieTypesCollection.Name(),
+		EntityId: typeId,
+		After:    bson.M{"updated_types": result.ModifiedCount},
+	})
+
+	return nil
+}
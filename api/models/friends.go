@@ -0,0 +1,272 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/audit"
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Status of the friendships
+const (
+	FriendshipPending  = repositories.FriendshipPending
+	FriendshipAccepted = repositories.FriendshipAccepted
+)
+
+// friendshipBetweenFilter "private" function to get the filter of the friendship between two users (in any direction)
+func friendshipBetweenFilter(firstUserId primitive.ObjectID, secondUserId primitive.ObjectID) bson.D {
+	return bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "requester_id", Value: firstUserId}, {Key: "recipient_id", Value: secondUserId}},
+		bson.D{{Key: "requester_id", Value: secondUserId}, {Key: "recipient_id", Value: firstUserId}},
+	}}}
+}
+
+// GetFriendshipBetween Returns the friendship (accepted or pending) between two users
+func GetFriendshipBetween(firstUserId primitive.ObjectID, secondUserId primitive.ObjectID) (interfaces.Friendship, error) {
+	var friendship interfaces.Friendship
+	err := FriendshipsCollection.FindOne(context.TODO(), friendshipBetweenFilter(firstUserId, secondUserId)).Decode(&friendship)
+	return friendship, err
+}
+
+// GetFriendshipById Returns the friendship with the given id if the user is part of it
+func GetFriendshipById(userId primitive.ObjectID, friendshipId primitive.ObjectID) (interfaces.Friendship, error) {
+	var friendship interfaces.Friendship
+
+	err := FriendshipsCollection.FindOne(context.TODO(), bson.D{
+		{Key: "_id", Value: friendshipId},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "requester_id", Value: userId}},
+			bson.D{{Key: "recipient_id", Value: userId}},
+		}},
+	}).Decode(&friendship)
+
+	return friendship, err
+}
+
+// AreFriends Returns true if the users accepted a friend request between them
+func AreFriends(firstUserId primitive.ObjectID, secondUserId primitive.ObjectID) (bool, error) {
+	count, err := FriendshipsCollection.CountDocuments(
+		context.TODO(),
+		append(friendshipBetweenFilter(firstUserId, secondUserId), bson.E{Key: "status", Value: FriendshipAccepted}),
+	)
+
+	return count > 0, err
+}
+
+// GetUserFriendships Returns the friendships of the user with the given status sorted by creation date
+func GetUserFriendships(userId primitive.ObjectID, status string) ([]interfaces.Friendship, error) {
+	friendships := []interfaces.Friendship{}
+
+	cursor, err := FriendshipsCollection.Find(
+		context.TODO(),
+		bson.D{
+			{Key: "status", Value: status},
+			{Key: "$or", Value: bson.A{
+				bson.D{{Key: "requester_id", Value: userId}},
+				bson.D{{Key: "recipient_id", Value: userId}},
+			}},
+		},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}),
+	)
+
+	if err != nil {
+		return friendships, err
+	}
+
+	err = cursor.All(context.TODO(), &friendships)
+	return friendships, err
+}
+
+// GetUsersByIds Returns the users with the given ids
+func GetUsersByIds(ids []primitive.ObjectID) ([]interfaces.User, error) {
+	users := []interfaces.User{}
+
+	if len(ids) == 0 {
+		return users, nil
+	}
+
+	cursor, err := UserCollection.Find(context.TODO(), bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}})
+	if err != nil {
+		return users, err
+	}
+
+	err = cursor.All(context.TODO(), &users)
+	return users, err
+}
+
+// CreateFriendRequest Creates a pending friendship from the requester to the recipient
+func CreateFriendRequest(ctx context.Context, requesterId primitive.ObjectID, recipientId primitive.ObjectID) (interfaces.Friendship, error) {
+	friendship := interfaces.Friendship{
+		RequesterId: requesterId,
+		RecipientId: recipientId,
+		Status:      FriendshipPending,
+		CreatedAt:   time.Now().Unix(),
+	}
+
+	result, err := FriendshipsCollection.InsertOne(ctx, friendship)
+	if err != nil {
+		return friendship, err
+	}
+
+	friendship.Id = result.InsertedID.(primitive.ObjectID)
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		UserId:   requesterId,
+		Action:   "friendship.request",
+		Entity:   FriendshipsCollection.Name(),
+		EntityId: friendship.Id,
+		After:    friendship,
+	})
+
+	return friendship, nil
+}
+
+// AcceptFriendRequest Accepts the pending friendship. Returns false if the request was no longer pending
+func AcceptFriendRequest(ctx context.Context, friendship interfaces.Friendship) (bool, error) {
+	acceptedAt := time.Now().Unix()
+
+	// The filter avoids accepting a request that was declined or removed concurrently
+	result, err := FriendshipsCollection.UpdateOne(
+		ctx,
+		bson.D{{Key: "_id", Value: friendship.Id}, {Key: "status", Value: FriendshipPending}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "status", Value: FriendshipAccepted},
+			{Key: "accepted_at", Value: acceptedAt},
+		}}},
+	)
+
+	if err != nil || result.ModifiedCount == 0 {
+		return false, err
+	}
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		UserId:   friendship.RecipientId,
+		Action:   "friendship.accept",
+		Entity:   FriendshipsCollection.Name(),
+		EntityId: friendship.Id,
+		Before:   bson.M{"status": FriendshipPending},
+		After:    bson.M{"status": FriendshipAccepted, "accepted_at": acceptedAt},
+	})
+
+	return true, nil
+}
+
+// DeleteFriendship Deletes the friendship (Used to decline or cancel the requests and to remove the friends)
+func DeleteFriendship(ctx context.Context, userId primitive.ObjectID, friendship interfaces.Friendship, action string) error {
+	_, err := FriendshipsCollection.DeleteOne(ctx, bson.D{{Key: "_id", Value: friendship.Id}})
+	if err != nil {
+		return err
+	}
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		UserId:   userId,
+		Action:   action,
+		Entity:   FriendshipsCollection.Name(),
+		EntityId: friendship.Id,
+		Before:   friendship,
+	})
+
+	return nil
+}
+
+// BlockUser Adds the user to the block list, removes the friendship (or pending requests) and cancels the active
+// trades between them
+func BlockUser(ctx context.Context, user interfaces.User, blockedId primitive.ObjectID) error {
+	_, err := UserCollection.UpdateOne(
+		ctx,
+		bson.D{{Key: "_id", Value: user.Id}},
+		bson.D{{Key: "$addToSet", Value: bson.D{{Key: "blocked_users", Value: blockedId}}}},
+	)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = FriendshipsCollection.DeleteMany(ctx, friendshipBetweenFilter(user.Id, blockedId))
+	if err != nil {
+		return err
+	}
+
+	err = CancelTradesBetween(ctx, user.Id, blockedId)
+	if err != nil {
+		return err
+	}
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		UserId:   user.Id,
+		Action:   "user.block",
+		Entity:   "users",
+		EntityId: user.Id,
+		After:    bson.M{"blocked_id": blockedId},
+	})
+
+	return nil
+}
+
+// UnblockUser Removes the user from the block list
+func UnblockUser(ctx context.Context, user interfaces.User, blockedId primitive.ObjectID) error {
+	_, err := UserCollection.UpdateOne(
+		ctx,
+		bson.D{{Key: "_id", Value: user.Id}},
+		bson.D{{Key: "$pull", Value: bson.D{{Key: "blocked_users", Value: blockedId}}}},
+	)
+
+	if err != nil {
+		return err
+	}
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		UserId:   user.Id,
+		Action:   "user.unblock",
+		Entity:   "users",
+		EntityId: user.Id,
+		Before:   bson.M{"blocked_id": blockedId},
+	})
+
+	return nil
+}
+
+// UpdateUserPresence Stores the last activity time and zone of the user
+func UpdateUserPresence(userId primitive.ObjectID, zoneCoordinates string) error {
+	_, err := UserCollection.UpdateOne(
+		context.TODO(),
+		bson.D{{Key: "_id", Value: userId}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "last_seen_at", Value: time.Now().Unix()},
+			{Key: "last_seen_zone", Value: zoneCoordinates},
+		}}},
+	)
+
+	return err
+}
+
+// IsGymOwnerVisible Returns true if the owner username can be shown to the player. The owner is hidden to the
+// blocked players and, if the owner hides the gyms in the trainer card, to the players that aren't friends
+func IsGymOwnerVisible(owner interfaces.User, playerId primitive.ObjectID) (bool, error) {
+	if owner.Id == playerId {
+		return true, nil
+	}
+
+	player, err := GetUserById(playerId.Hex())
+	if err != nil {
+		return false, err
+	}
+
+	if utils.IsBlockedBetween(owner, player) {
+		return false, nil
+	}
+
+	for _, field := range owner.Profile.HiddenFields {
+		if field == "gyms" {
+			return AreFriends(owner.Id, playerId)
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,198 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/audit"
+	"github.com/PedroChaparro/loomies-backend/configuration"
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Attempts to publish a version when other instance publishes the same version number at the same time
+const publishGameSettingsAttempts = 3
+
+// GetLatestGameSettings Returns the version of the game settings in use, mongo.ErrNoDocuments if none was published
+func GetLatestGameSettings() (interfaces.GameSettingsVersion, error) {
+	var latest interfaces.GameSettingsVersion
+
+	err := GameSettingsCollection.FindOne(
+		context.TODO(),
+		bson.D{},
+		options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}}),
+	).Decode(&latest)
+
+	return latest, err
+}
+
+// GetGameSettingsVersion Returns the given version of the game settings
+func GetGameSettingsVersion(version int) (interfaces.GameSettingsVersion, error) {
+	var document interfaces.GameSettingsVersion
+	err := GameSettingsCollection.FindOne(context.TODO(), bson.D{{Key: "version", Value: version}}).Decode(&document)
+	return document, err
+}
+
+// GetGameSettingsVersions Returns the last published versions of the game settings, from the newest to the oldest
+func GetGameSettingsVersions(limit int64) ([]interfaces.GameSettingsVersion, error) {
+	versions := []interfaces.GameSettingsVersion{}
+
+	cursor, err := GameSettingsCollection.Find(
+		context.TODO(),
+		bson.D{},
+		options.Find().SetLimit(limit).SetSort(bson.D{{Key: "version", Value: -1}}),
+	)
+
+	if err != nil {
+		return versions, err
+	}
+
+	err = cursor.All(context.TODO(), &versions)
+	return versions, err
+}
+
+// PublishGameSettings Inserts a new version of the game settings and starts using it in the live balance of this
+// instance, the other instances receive it from WatchGameSettings. The settings must be valid
+func PublishGameSettings(ctx context.Context, live *configuration.LiveBalance, settings configuration.TGameBalance, comment string, createdBy primitive.ObjectID, rolledBackTo int) (interfaces.GameSettingsVersion, error) {
+	for attempt := 1; ; attempt++ {
+		previous, err := GetLatestGameSettings()
+		if err != nil && err != mongo.ErrNoDocuments {
+			return interfaces.GameSettingsVersion{}, err
+		}
+
+		document := interfaces.GameSettingsVersion{
+			Version:      previous.Version + 1,
+			Settings:     settings,
+			Comment:      comment,
+			CreatedBy:    createdBy,
+			RolledBackTo: rolledBackTo,
+			CreatedAt:    time.Now().Unix(),
+		}
+
+		result, err := GameSettingsCollection.InsertOne(ctx, document)
+
+		// The version number is unique (see the migrations), so other instance published first
+		if mongo.IsDuplicateKeyError(err) && attempt < publishGameSettingsAttempts {
+			continue
+		}
+
+		if err != nil {
+			return interfaces.GameSettingsVersion{}, err
+		}
+
+		document.Id = result.InsertedID.(primitive.ObjectID)
+
+		action := "game_settings.publish"
+		if rolledBackTo > 0 {
+			action = "game_settings.rollback"
+		}
+
+		audit.Record(ctx, interfaces.AuditEvent{
+			ActorId:  createdBy,
+			Action:   action,
+			Entity:   "game_settings",
+			EntityId: document.Id,
+			Before:   previous.Settings,
+			After:    settings,
+		})
+
+		if err := live.Set(document.Version, document.Settings); err != nil {
+			return document, err
+		}
+
+		return document, nil
+	}
+}
+
+// RollbackGameSettings Publishes a copy of the given version, so the history is kept and the rollback can be undone
+func RollbackGameSettings(ctx context.Context, live *configuration.LiveBalance, version int, comment string, createdBy primitive.ObjectID) (interfaces.GameSettingsVersion, error) {
+	target, err := GetGameSettingsVersion(version)
+	if err != nil {
+		return interfaces.GameSettingsVersion{}, err
+	}
+
+	if comment == "" {
+		comment = fmt.Sprintf("Rollback to version %d", version)
+	}
+
+	return PublishGameSettings(ctx, live, target.Settings, comment, createdBy, version)
+}
+
+// loadLatestGameSettings "private" function to start using the latest published version, the configuration values
+// are kept if no version was published
+func loadLatestGameSettings(live *configuration.LiveBalance) error {
+	latest, err := GetLatestGameSettings()
+
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return live.Set(latest.Version, latest.Settings)
+}
+
+// WatchGameSettings Keeps the live balance of this instance updated with the published versions until the context
+// is cancelled. The new versions are received from a change stream, if the database doesn't support them (it's not
+// a replica set) or the stream fails, the latest version is polled every pollInterval
+func WatchGameSettings(ctx context.Context, live *configuration.LiveBalance, pollInterval time.Duration) {
+	// The stream is opened before loading the latest version, so the versions published in between are not missed
+	// (the older ones are ignored by the live balance)
+	pipeline := mongo.Pipeline{bson.D{{Key: "$match", Value: bson.D{{Key: "operationType", Value: "insert"}}}}}
+	stream, err := GameSettingsCollection.Watch(ctx, pipeline)
+
+	if err := loadLatestGameSettings(live); err != nil {
+		fmt.Println("Unable to load the game settings:", err)
+	}
+
+	if err == nil {
+		defer stream.Close(context.Background())
+
+		for stream.Next(ctx) {
+			var event struct {
+				FullDocument interfaces.GameSettingsVersion `bson:"fullDocument"`
+			}
+
+			if err := stream.Decode(&event); err != nil {
+				continue
+			}
+
+			if err := live.Set(event.FullDocument.Version, event.FullDocument.Settings); err != nil {
+				fmt.Println("Ignoring invalid game settings version", event.FullDocument.Version, err)
+			}
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		fmt.Println("The game settings change stream was closed, polling the latest version:", stream.Err())
+
+		// Catch up with the versions published since the stream failed
+		if err := loadLatestGameSettings(live); err != nil {
+			fmt.Println("Unable to load the game settings:", err)
+		}
+	} else {
+		fmt.Println("Change streams are not available, polling the latest game settings version:", err)
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := loadLatestGameSettings(live); err != nil {
+				fmt.Println("Unable to load the game settings:", err)
+			}
+		}
+	}
+}
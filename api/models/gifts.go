@@ -0,0 +1,266 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/audit"
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Errors returned when the gift can't be sent or opened
+var (
+	ErrGiftsLimitReached = repositories.ErrGiftsLimitReached
+	ErrGiftAlreadySent   = repositories.ErrGiftAlreadySent
+	ErrGiftAlreadyOpened = repositories.ErrGiftAlreadyOpened
+)
+
+// GetGiftTable Returns the entries of the gift table
+func GetGiftTable() ([]interfaces.GiftTableEntry, error) {
+	table := []interfaces.GiftTableEntry{}
+
+	cursor, err := GiftTableCollection.Find(context.TODO(), bson.D{{Key: "weight", Value: bson.D{{Key: "$gt", Value: 0}}}})
+	if err != nil {
+		return table, err
+	}
+
+	err = cursor.All(context.TODO(), &table)
+	return table, err
+}
+
+// drawGiftRewards "private" function to draw the given number of rewards from the gift table by weight. Like the
+// gyms rewards, the drawn entries are not repeated in a gift
+func drawGiftRewards(table []interfaces.GiftTableEntry, count int) ([]interfaces.GymRewardItem, error) {
+	rewards := []interfaces.GymRewardItem{}
+	weights := make([]float64, len(table))
+
+	for index, entry := range table {
+		weights[index] = entry.Weight
+	}
+
+	for _, index := range utils.DrawWeighted(weights, count) {
+		entry := table[index]
+
+		rewardId, err := getRewardIdBySerial(entry.RewardCollection, entry.RewardSerial)
+		if err != nil {
+			return rewards, err
+		}
+
+		rewards = append(rewards, interfaces.GymRewardItem{
+			RewardCollection: entry.RewardCollection,
+			RewardId:         rewardId,
+			RewardQuantity:   getRewardQuantity(entry.MinQuantity, entry.MaxQuantity),
+		})
+	}
+
+	return rewards, nil
+}
+
+// NewGiftRewards Draws the rewards of a new gift from the gift table
+func NewGiftRewards(minRewards int, maxRewards int) ([]interfaces.GymRewardItem, error) {
+	table, err := GetGiftTable()
+	if err != nil {
+		return nil, err
+	}
+
+	count := minRewards
+	if maxRewards > minRewards {
+		count = utils.GetRandomInt(minRewards, maxRewards+1)
+	}
+
+	return drawGiftRewards(table, count)
+}
+
+// reserveGiftSlot "private" function to increment the gifts sent by the user in the day if the limit wasn't reached.
+// The counter is restarted when the day changes
+func reserveGiftSlot(ctx context.Context, userId primitive.ObjectID, day string, giftsPerDay int) (bool, error) {
+	if giftsPerDay <= 0 {
+		return false, nil
+	}
+
+	result, err := UserCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "_id", Value: userId},
+			{Key: "gifts_sent_day", Value: day},
+			{Key: "gifts_sent", Value: bson.D{{Key: "$lt", Value: giftsPerDay}}},
+		},
+		bson.D{{Key: "$inc", Value: bson.D{{Key: "gifts_sent", Value: 1}}}},
+	)
+
+	if err != nil || result.MatchedCount > 0 {
+		return err == nil, err
+	}
+
+	result, err = UserCollection.UpdateOne(
+		ctx,
+		bson.D{{Key: "_id", Value: userId}, {Key: "gifts_sent_day", Value: bson.D{{Key: "$ne", Value: day}}}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "gifts_sent_day", Value: day}, {Key: "gifts_sent", Value: 1}}}},
+	)
+
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount > 0, nil
+}
+
+// SendGift Stores the gift from the sender to the recipient. The daily limit and the gift are updated in a single
+// transaction, so the limit is not consumed if the gift can't be stored
+func SendGift(ctx context.Context, senderId primitive.ObjectID, recipientId primitive.ObjectID, rewards []interfaces.GymRewardItem, giftsPerDay int) (interfaces.Gift, error) {
+	now := time.Now()
+
+	// The days start at the midnight of the sender region
+	sender, err := GetUserById(senderId.Hex())
+	if err != nil {
+		return interfaces.Gift{}, err
+	}
+
+	day, tomorrow := utils.GetPeriod("daily", now, GetUserLocation(sender))
+
+	gift := interfaces.Gift{
+		SenderId:    senderId,
+		RecipientId: recipientId,
+		Rewards:     rewards,
+		SentAt:      now.Unix(),
+	}
+
+	err = RunUnitOfWork(ctx, func(uow *UnitOfWork) error {
+		// Only one gift per friend and day
+		sentToFriend, err := GiftsCollection.CountDocuments(uow.Context(), bson.D{
+			{Key: "sender_id", Value: senderId},
+			{Key: "recipient_id", Value: recipientId},
+			{Key: "sent_at", Value: bson.D{{Key: "$gte", Value: tomorrow.AddDate(0, 0, -1).Unix()}}},
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if sentToFriend > 0 {
+			return ErrGiftAlreadySent
+		}
+
+		reserved, err := reserveGiftSlot(uow.Context(), senderId, day, giftsPerDay)
+		if err != nil {
+			return err
+		}
+
+		if !reserved {
+			return ErrGiftsLimitReached
+		}
+
+		result, err := GiftsCollection.InsertOne(uow.Context(), gift)
+		if err != nil {
+			return err
+		}
+
+		gift.Id = result.InsertedID.(primitive.ObjectID)
+
+		audit.Record(uow.Context(), interfaces.AuditEvent{
+			UserId:   senderId,
+			Action:   "gift.send",
+			Entity:   GiftsCollection.Name(),
+			EntityId: gift.Id,
+			After:    gift,
+		})
+
+		return nil
+	})
+
+	return gift, err
+}
+
+// GetReceivedGifts Returns the gifts received by the user sorted from the newest to the oldest
+func GetReceivedGifts(userId primitive.ObjectID, opened bool) ([]interfaces.Gift, error) {
+	gifts := []interfaces.Gift{}
+
+	cursor, err := GiftsCollection.Find(
+		context.TODO(),
+		bson.D{{Key: "recipient_id", Value: userId}, {Key: "opened", Value: opened}},
+		options.Find().SetSort(bson.D{{Key: "sent_at", Value: -1}}),
+	)
+
+	if err != nil {
+		return gifts, err
+	}
+
+	err = cursor.All(context.TODO(), &gifts)
+	return gifts, err
+}
+
+// GetUserGifts Returns the gifts sent or received by the user sorted from the newest to the oldest
+func GetUserGifts(userId primitive.ObjectID) ([]interfaces.Gift, error) {
+	gifts := []interfaces.Gift{}
+
+	cursor, err := GiftsCollection.Find(
+		context.TODO(),
+		bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "sender_id", Value: userId}},
+			bson.D{{Key: "recipient_id", Value: userId}},
+		}}},
+		options.Find().SetSort(bson.D{{Key: "sent_at", Value: -1}}),
+	)
+
+	if err != nil {
+		return gifts, err
+	}
+
+	err = cursor.All(context.TODO(), &gifts)
+	return gifts, err
+}
+
+// GetReceivedGiftById Returns the gift with the given id if it was received by the user
+func GetReceivedGiftById(userId primitive.ObjectID, giftId primitive.ObjectID) (interfaces.Gift, error) {
+	var gift interfaces.Gift
+	err := GiftsCollection.FindOne(context.TODO(), bson.D{{Key: "_id", Value: giftId}, {Key: "recipient_id", Value: userId}}).Decode(&gift)
+	return gift, err
+}
+
+// OpenGift Marks the gift as opened and adds the rewards to the recipient inventory in a single transaction
+func OpenGift(ctx context.Context, gift interfaces.Gift) (interfaces.Gift, error) {
+	err := RunUnitOfWork(ctx, func(uow *UnitOfWork) error {
+		openedAt := time.Now().Unix()
+
+		// The filter avoids opening the gift twice
+		result, err := GiftsCollection.UpdateOne(
+			uow.Context(),
+			bson.D{{Key: "_id", Value: gift.Id}, {Key: "opened", Value: false}},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "opened", Value: true}, {Key: "opened_at", Value: openedAt}}}},
+		)
+
+		if err != nil {
+			return err
+		}
+
+		if result.MatchedCount == 0 {
+			return ErrGiftAlreadyOpened
+		}
+
+		err = AddItemsToUserInventory(uow.Context(), gift.RecipientId, gift.Rewards)
+		if err != nil {
+			return err
+		}
+
+		gift.Opened = true
+		gift.OpenedAt = openedAt
+
+		audit.Record(uow.Context(), interfaces.AuditEvent{
+			UserId:   gift.RecipientId,
+			Action:   "gift.open",
+			Entity:   GiftsCollection.Name(),
+			EntityId: gift.Id,
+			Before:   bson.M{"opened": false},
+			After:    bson.M{"opened": true, "opened_at": openedAt},
+		})
+
+		return nil
+	})
+
+	return gift, err
+}
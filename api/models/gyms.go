@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/PedroChaparro/loomies-backend/audit"
 	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"github.com/PedroChaparro/loomies-backend/utils"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -22,21 +25,62 @@ func GetGymFromID(gymID string) (g interfaces.Gym, e error) {
 	return g, err
 }
 
-// RegisterClaimedReward adds the user to the list of users that have claimed the reward for the given gym
-func RegisterClaimedReward(gym interfaces.Gym, userID primitive.ObjectID) error {
-	_, err := GymsCollection.UpdateOne(context.Background(), bson.M{"_id": gym.Id}, bson.M{"$push": bson.M{"rewards_claimed_by": userID}})
-	return err
+// ErrRewardAlreadyClaimed is returned when the user already claimed the rewards of the gym
+var ErrRewardAlreadyClaimed = repositories.ErrRewardAlreadyClaimed
+
+// Steps of the claim reward and gym takeover units of work
+const (
+	ClaimRegisterStep         = "claim.register"
+	ClaimAddItemsStep         = "claim.add_items"
+	TakeoverClearTeamStep     = "takeover.clear_team"
+	TakeoverOldProtectorsStep = "takeover.release_protectors"
+	TakeoverGymStep           = "takeover.update_gym"
+	TakeoverNewProtectorsStep = "takeover.assign_protectors"
+)
+
+// RegisterClaimedReward adds the user to the list of users that have claimed the reward for the given gym. The
+// filter avoids claiming the same rewards twice
+func RegisterClaimedReward(ctx context.Context, gym interfaces.Gym, userID primitive.ObjectID) error {
+	result, err := GymsCollection.UpdateOne(
+		ctx,
+		bson.M{"_id": gym.Id, "rewards_claimed_by": bson.M{"$ne": userID}},
+		bson.M{"$push": bson.M{"rewards_claimed_by": userID}},
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrRewardAlreadyClaimed
+	}
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		UserId:   userID,
+		Action:   "gym.claim_reward",
+		Entity:   "gyms",
+		EntityId: gym.Id,
+	})
+
+	return nil
 }
 
-// HasUserClaimedReward returns if the user has already claimed the reward for the given gym
-func HasUserClaimedReward(claimed []primitive.ObjectID, userID primitive.ObjectID) bool {
-	for _, userId := range claimed {
-		if userId == userID {
-			return true
+// ClaimGymReward Registers the user claimed the rewards of the gym and adds them to the user inventory in a single
+// unit of work, so the rewards are not lost nor given twice
+func ClaimGymReward(ctx context.Context, gym interfaces.Gym, userID primitive.ObjectID, rewards []interfaces.GymRewardItem) error {
+	return RunUnitOfWork(ctx, func(uow *UnitOfWork) error {
+		err := uow.Step(ClaimRegisterStep, func(ctx context.Context) error {
+			return RegisterClaimedReward(ctx, gym, userID)
+		})
+
+		if err != nil {
+			return err
 		}
-	}
 
-	return false
+		return uow.Step(ClaimAddItemsStep, func(ctx context.Context) error {
+			return AddItemsToUserInventory(ctx, userID, rewards)
+		})
+	})
 }
 
 // GetPopulatedGymFromId Returnd the details for the `/gym/:id` endpoint from the given gym id
@@ -88,7 +132,20 @@ func GetPopulatedGymFromId(GymId, UserId primitive.ObjectID) (gym interfaces.Pop
 
 	// Parse the auxiliar gym into a populated gym
 	GymDoc = *auxiliarGymDoc.ToPopulatedGym(UserId)
-	GymDoc.WasRewardClaimed = HasUserClaimedReward(auxiliarGymDoc.RewardsClaimedBy, UserId)
+	GymDoc.WasRewardClaimed = utils.HasUserClaimedReward(auxiliarGymDoc.RewardsClaimedBy, UserId)
+
+	// The owner id is kept to notify the owner, but the username is hidden according to the privacy settings
+	if len(auxiliarGymDoc.Owner) == 1 {
+		visible, err := IsGymOwnerVisible(auxiliarGymDoc.Owner[0], UserId)
+
+		if err != nil {
+			return interfaces.PopulatedGym{}, err
+		}
+
+		if !visible {
+			GymDoc.Owner = ""
+		}
+	}
 	return GymDoc, nil
 }
 
@@ -129,10 +186,13 @@ func FinishGymChallenge(gymId, playerId primitive.ObjectID) (err error) {
 	return err
 }
 
-// UpdateGymProtectors Updates Gym Protectors (the loomies team of the owner) and new owner
-func UpdateGymProtectorsAndOwner(GymId primitive.ObjectID, loomiesProtectorsIds []primitive.ObjectID, newOwner primitive.ObjectID) (err error) {
-	_, err = GymsCollection.UpdateOne(
-		context.TODO(),
+// UpdateGymProtectors Updates Gym Protectors (the loomies team of the owner) and new owner. The audit event
+// is linked with the previous owner, the new one is the actor
+func UpdateGymProtectorsAndOwner(ctx context.Context, GymId primitive.ObjectID, loomiesProtectorsIds []primitive.ObjectID, newOwner primitive.ObjectID) (err error) {
+	var before interfaces.Gym
+
+	err = GymsCollection.FindOneAndUpdate(
+		ctx,
 		bson.D{{Key: "_id", Value: GymId}},
 		bson.D{
 			{Key: "$set", Value: bson.D{
@@ -140,20 +200,90 @@ func UpdateGymProtectorsAndOwner(GymId primitive.ObjectID, loomiesProtectorsIds
 				{Key: "owner", Value: newOwner},
 			}},
 		},
-	)
-	return err
+	).Decode(&before)
+
+	if err != nil {
+		return err
+	}
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		UserId:   before.Owner,
+		Action:   "gym.update_owner",
+		Entity:   "gyms",
+		EntityId: GymId,
+		Before:   bson.M{"owner": before.Owner, "protectors": before.Protectors},
+		After:    bson.M{"owner": newOwner, "protectors": loomiesProtectorsIds},
+	})
+
+	return nil
+}
+
+// TakeOverGym Gives the gym to the new owner after winning the combat. The loomies of the new owner leave the team
+// to protect the gym and the old protectors are released (or removed if the gym didn't have an owner). All the
+// writes are done in a single unit of work, so the gym is never left without protectors
+func TakeOverGym(ctx context.Context, gymId primitive.ObjectID, newOwner primitive.ObjectID, newProtectors []primitive.ObjectID, oldProtectors []primitive.ObjectID, hadOwner bool) error {
+	return RunUnitOfWork(ctx, func(uow *UnitOfWork) error {
+		// Updates the loomie team of the new owner with an empty array
+		err := uow.Step(TakeoverClearTeamStep, func(ctx context.Context) error {
+			return ReplaceLoomieTeam(ctx, newOwner, []primitive.ObjectID{})
+		})
+
+		if err != nil {
+			return err
+		}
+
+		err = uow.Step(TakeoverOldProtectorsStep, func(ctx context.Context) error {
+			if hadOwner {
+				return UpdateLoomiesBusyState(ctx, oldProtectors, false)
+			}
+
+			return RemoveLoomieTeam(ctx, oldProtectors)
+		})
+
+		if err != nil {
+			return err
+		}
+
+		err = uow.Step(TakeoverGymStep, func(ctx context.Context) error {
+			return UpdateGymProtectorsAndOwner(ctx, gymId, newProtectors, newOwner)
+		})
+
+		if err != nil {
+			return err
+		}
+
+		return uow.Step(TakeoverNewProtectorsStep, func(ctx context.Context) error {
+			return UpdateLoomiesBusyState(ctx, newProtectors, true)
+		})
+	})
 }
 
 // UpdateGymProtectors Updates Gym Protectors
-func UpdateGymProtectors(GymId primitive.ObjectID, protectorsIds []primitive.ObjectID) error {
-	_, err := GymsCollection.UpdateOne(
-		context.TODO(),
+func UpdateGymProtectors(ctx context.Context, GymId primitive.ObjectID, protectorsIds []primitive.ObjectID) error {
+	var before interfaces.Gym
+
+	err := GymsCollection.FindOneAndUpdate(
+		ctx,
 		bson.D{{Key: "_id", Value: GymId}},
 		bson.D{
 			{Key: "$set", Value: bson.D{
 				{Key: "protectors", Value: protectorsIds},
 			}},
 		},
-	)
-	return err
+	).Decode(&before)
+
+	if err != nil {
+		return err
+	}
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		UserId:   before.Owner,
+		Action:   "gym.update_protectors",
+		Entity:   "gyms",
+		EntityId: GymId,
+		Before:   bson.M{"protectors": before.Protectors},
+		After:    bson.M{"protectors": protectorsIds},
+	})
+
+	return nil
 }
@@ -0,0 +1,188 @@
+package models
+
+import (
+	"context"
+
+	"github.com/PedroChaparro/loomies-backend/audit"
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Amount of different rewards given to the players and to the owner of each gym
+const (
+	minGymPlayersRewards = 3
+	maxGymPlayersRewards = 5
+	minGymOwnersRewards  = 4
+	maxGymOwnersRewards  = 6
+)
+
+// Gyms updated in each bulk write when the rewards are regenerated
+const gymsRewardsBatchSize = 500
+
+// gymRewardCandidate "private" struct with an item or loomball that can be given as a gym reward
+type gymRewardCandidate struct {
+	Collection    string
+	Id            primitive.ObjectID
+	PlayersChance float64
+	OwnersChance  float64
+	MinQuantity   int
+	MaxQuantity   int
+}
+
+// getRewardQuantity "private" function to get a random quantity of a reward between min and max (both included)
+func getRewardQuantity(min int, max int) int {
+	if max <= min {
+		return min
+	}
+
+	return utils.GetRandomInt(min, max+1)
+}
+
+// getGymRewardCandidates "private" function to get the items and loomballs with their gym rewards chances
+func getGymRewardCandidates(ctx context.Context) ([]gymRewardCandidate, error) {
+	items := []interfaces.Item{}
+	loomballs := []interfaces.Loomball{}
+
+	cursor, err := ItemsCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+
+	cursor, err = LoomballsCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cursor.All(ctx, &loomballs); err != nil {
+		return nil, err
+	}
+
+	candidates := []gymRewardCandidate{}
+
+	for _, item := range items {
+		candidates = append(candidates, gymRewardCandidate{
+			Collection:    ItemsCollection.Name(),
+			Id:            item.Id,
+			PlayersChance: item.GymRewardChancePlayer,
+			OwnersChance:  item.GymRewardChanceOwner,
+			MinQuantity:   item.MinRewardQuantity,
+			MaxQuantity:   item.MaxRewardQuantity,
+		})
+	}
+
+	for _, loomball := range loomballs {
+		candidates = append(candidates, gymRewardCandidate{
+			Collection:    LoomballsCollection.Name(),
+			Id:            loomball.Id,
+			PlayersChance: loomball.GymRewardChancePlayer,
+			OwnersChance:  loomball.GymRewardChanceOwner,
+			MinQuantity:   loomball.MinRewardQuantity,
+			MaxQuantity:   loomball.MaxRewardQuantity,
+		})
+	}
+
+	return candidates, nil
+}
+
+// drawGymRewards "private" function to draw between min and max different rewards from the candidates using the
+// given chances as weights
+func drawGymRewards(candidates []gymRewardCandidate, chances []float64, min int, max int) []interfaces.GymRewardItem {
+	rewards := []interfaces.GymRewardItem{}
+
+	for _, index := range utils.DrawWeighted(chances, getRewardQuantity(min, max)) {
+		candidate := candidates[index]
+		rewards = append(rewards, interfaces.GymRewardItem{
+			RewardCollection: candidate.Collection,
+			RewardId:         candidate.Id,
+			RewardQuantity:   getRewardQuantity(candidate.MinQuantity, candidate.MaxQuantity),
+		})
+	}
+
+	return rewards
+}
+
+// RegenerateGymsRewards Replaces the rewards of all the gyms with new random rewards and clears the users who
+// claimed the previous ones. Returns the amount of updated gyms
+func RegenerateGymsRewards(ctx context.Context) (int, error) {
+	candidates, err := getGymRewardCandidates(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	playersChances := make([]float64, len(candidates))
+	ownersChances := make([]float64, len(candidates))
+
+	for index, candidate := range candidates {
+		playersChances[index] = candidate.PlayersChance
+		ownersChances[index] = candidate.OwnersChance
+	}
+
+	cursor, err := GymsCollection.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return 0, err
+	}
+
+	defer cursor.Close(ctx)
+	updated := 0
+	writes := []mongo.WriteModel{}
+
+	flush := func() error {
+		if len(writes) == 0 {
+			return nil
+		}
+
+		result, err := GymsCollection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+		if result != nil {
+			updated += int(result.MatchedCount)
+		}
+
+		writes = []mongo.WriteModel{}
+		return err
+	}
+
+	for cursor.Next(ctx) {
+		var gym struct {
+			Id primitive.ObjectID `bson:"_id"`
+		}
+
+		if err := cursor.Decode(&gym); err != nil {
+			return updated, err
+		}
+
+		writes = append(writes, mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": gym.Id}).SetUpdate(bson.M{"$set": bson.M{
+			"current_players_rewards": drawGymRewards(candidates, playersChances, minGymPlayersRewards, maxGymPlayersRewards),
+			"current_owners_rewards":  drawGymRewards(candidates, ownersChances, minGymOwnersRewards, maxGymOwnersRewards),
+			"rewards_claimed_by":      []primitive.ObjectID{},
+		}}))
+
+		if len(writes) >= gymsRewardsBatchSize {
+			if err := flush(); err != nil {
+				return updated, err
+			}
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return updated, err
+	}
+
+	if err := flush(); err != nil {
+		return updated, err
+	}
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		Action: "gym.regenerate_rewards",
+		Entity: GymsCollection.Name(),
+		After:  bson.M{"updated_gyms": updated},
+	})
+
+	return updated, nil
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"context"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetJobRuns Returns the last runs of the scheduled jobs (or of the given job) from the newest to the oldest
+func GetJobRuns(job string, limit int64) ([]interfaces.JobRun, error) {
+	runs := []interfaces.JobRun{}
+	filter := bson.M{}
+
+	if job != "" {
+		filter["job"] = job
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "started_at", Value: -1}}).SetLimit(limit)
+	cursor, err := JobRunsCollection.Find(context.TODO(), filter, findOptions)
+
+	if err != nil {
+		return runs, err
+	}
+
+	err = cursor.All(context.TODO(), &runs)
+	return runs, err
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TestScheduledJobs tests the jobs regenerate the gyms rewards and remove the outdated wild loomies
+func TestScheduledJobs(t *testing.T) {
+	c := require.New(t)
+	ctx := context.Background()
+
+	// 1. Regenerate the rewards of the gyms
+	var gym interfaces.Gym
+	err := GymsCollection.FindOne(ctx, bson.M{}).Decode(&gym)
+	c.NoError(err)
+
+	_, err = GymsCollection.UpdateOne(ctx, bson.M{"_id": gym.Id}, bson.M{"$set": bson.M{"rewards_claimed_by": bson.A{primitive.NewObjectID()}}})
+	c.NoError(err)
+
+	updated, err := RegenerateGymsRewards(ctx)
+	c.NoError(err)
+	c.Greater(updated, 0)
+
+	err = GymsCollection.FindOne(ctx, bson.M{"_id": gym.Id}).Decode(&gym)
+	c.NoError(err)
+	c.Empty(gym.RewardsClaimedBy)
+	c.True(len(gym.CurrentPlayersRewards) >= 3 && len(gym.CurrentPlayersRewards) <= 5)
+	c.True(len(gym.CurrentOwnerRewards) >= 4 && len(gym.CurrentOwnerRewards) <= 6)
+
+	for _, reward := range gym.CurrentPlayersRewards {
+		c.Contains([]string{"items", "loom_balls"}, reward.RewardCollection)
+		c.Greater(reward.RewardQuantity, 0)
+	}
+
+	// 2. Remove an outdated wild loomie and its reference in the zone
+	var zone interfaces.Zone
+	err = ZonesCollection.FindOne(ctx, bson.M{}).Decode(&zone)
+	c.NoError(err)
+
+	deadline := time.Now().Add(-time.Hour).Unix()
+	result, err := WildLoomiesCollection.InsertOne(ctx, interfaces.WildLoomie{ZoneId: zone.Id, GeneratedAt: deadline - 60})
+	c.NoError(err)
+	loomieId := result.InsertedID.(primitive.ObjectID)
+
+	_, err = ZonesCollection.UpdateOne(ctx, bson.M{"_id": zone.Id}, bson.M{"$push": bson.M{"loomies": loomieId}})
+	c.NoError(err)
+
+	removed, err := RemoveOutdatedWildLoomies(ctx, deadline)
+	c.NoError(err)
+	c.GreaterOrEqual(removed, 1)
+
+	count, err := WildLoomiesCollection.CountDocuments(ctx, bson.M{"_id": loomieId})
+	c.NoError(err)
+	c.Equal(int64(0), count)
+
+	count, err = ZonesCollection.CountDocuments(ctx, bson.M{"loomies": loomieId})
+	c.NoError(err)
+	c.Equal(int64(0), count)
+}
@@ -5,16 +5,39 @@ import (
 	"errors"
 	"time"
 
-	"github.com/PedroChaparro/loomies-backend/configuration"
+	"github.com/PedroChaparro/loomies-backend/audit"
 	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/repositories"
 	"github.com/PedroChaparro/loomies-backend/utils"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Errors returned when the wild loomie can't be captured
+var (
+	ErrLoomballNotFound    = repositories.ErrLoomballNotFound
+	ErrLoomieAlreadyCaught = repositories.ErrLoomieAlreadyCaught
+)
+
+// Steps of the capture unit of work
+const (
+	CaptureLoomballStep     = "capture.decrement_loomball"
+	CaptureRegisterStep     = "capture.register"
+	CaptureInsertLoomieStep = "capture.insert_loomie"
+	CaptureAddToUserStep    = "capture.add_to_user"
 )
 
 var memoizedLoomiesTypes map[primitive.ObjectID]string = make(map[primitive.ObjectID]string)
 var memoizedLoomiesRarities map[primitive.ObjectID]string = make(map[primitive.ObjectID]string)
 
+// InvalidateMemoizedContent removes the memoized types and rarities names so they are obtained again from the database
+func InvalidateMemoizedContent() {
+	memoizedLoomiesTypes = make(map[primitive.ObjectID]string)
+	memoizedLoomiesRarities = make(map[primitive.ObjectID]string)
+}
+
 // GetBaseLoomies returns the base loomies
 func GetBaseLoomies() ([]interfaces.BaseLoomiesWithPopulatedRarity, error) {
 	baseLoomies := []interfaces.BaseLoomiesWithPopulatedRarity{}
@@ -59,64 +82,68 @@ func GetBaseLoomies() ([]interfaces.BaseLoomiesWithPopulatedRarity, error) {
 	return baseLoomies, err
 }
 
-// RemoveNearExpiredLoomies remove the expired loomies that are near the user
-func RemoveNearExpiredLoomies(coordinates interfaces.Coordinates) error {
-	zoneLoomies := []interfaces.WildLoomie{}
+// withinVisibilityRadius "private" function to get the filter of the documents with a location at most at the
+// visibility radius (in meters) from the coordinates
+func withinVisibilityRadius(coordinates interfaces.Coordinates, visibilityRadius float64) bson.M {
+	point := coordinates.ToGeoPoint()
+	radius := visibilityRadius / utils.EarthRadius
 
-	// Get the zones that are near the current zone
-	nearZonesCoordinates := utils.GetNearZonesCoordinates(coordinates)
+	return bson.M{"$geoWithin": bson.M{"$centerSphere": bson.A{point.Coordinates, radius}}}
+}
 
-	// Filter
-	zonesFilter := bson.M{"coordinates": bson.M{"$in": nearZonesCoordinates}}
-	matchFilter := bson.M{"$match": zonesFilter}
+// RemoveNearExpiredLoomies remove the expired loomies (older than the time to live in minutes) that are near the user
+func RemoveNearExpiredLoomies(coordinates interfaces.Coordinates, visibilityRadius float64, loomieTTL int) error {
+	deadline := time.Now().Add(-time.Minute * time.Duration(loomieTTL)).Unix()
 
-	// Aggregation to populate zone's loomies
-	lookupIntoLoomies := bson.M{
-		"$lookup": bson.M{
-			"from":         "wild_loomies",
-			"localField":   "loomies",
-			"foreignField": "_id",
-			"as":           "populated_loomies",
-		},
-	}
+	_, err := WildLoomiesCollection.DeleteMany(context.Background(), bson.M{
+		"location":     withinVisibilityRadius(coordinates, visibilityRadius),
+		"generated_at": bson.M{"$lte": deadline},
+	})
 
-	// Make the query
-	cursor, err := ZonesCollection.Aggregate(context.Background(), []bson.M{matchFilter, lookupIntoLoomies})
+	return err
+}
 
-	if err != nil {
-		return err
-	}
+// Wild loomies removed in each batch of RemoveOutdatedWildLoomies
+const outdatedLoomiesBatchSize = 1000
 
-	// Decode the loomies
-	for cursor.Next(context.Background()) {
-		var zone interfaces.ZoneWithPopulatedLoomies
-		cursor.Decode(&zone)
-		zoneLoomies = append(zoneLoomies, zone.PopulatedLoomies...)
-	}
+// RemoveOutdatedWildLoomies removes the wild loomies generated before the deadline (unix time) and their references
+// in the zones. Returns the amount of removed loomies
+func RemoveOutdatedWildLoomies(ctx context.Context, deadline int64) (int, error) {
+	removed := 0
+	findOptions := options.Find().SetProjection(bson.M{"_id": 1}).SetLimit(outdatedLoomiesBatchSize)
 
-	// Get the outdated loomies
-	loomieTTL := configuration.GetWildLoomiesTTL()
-	currentTime := time.Now()
-	expiredLoomies := []primitive.ObjectID{}
+	for {
+		cursor, err := WildLoomiesCollection.Find(ctx, bson.M{"generated_at": bson.M{"$lt": deadline}}, findOptions)
+		if err != nil {
+			return removed, err
+		}
 
-	for _, loomie := range zoneLoomies {
-		loomieDeadline := time.Unix(loomie.GeneratedAt, 0).Add(time.Minute * time.Duration(loomieTTL))
+		loomies := []struct {
+			Id primitive.ObjectID `bson:"_id"`
+		}{}
 
-		if !currentTime.Before(loomieDeadline) {
-			// If the loomie is expired, add it to the list of expired loomies to remove it
-			expiredLoomies = append(expiredLoomies, loomie.Id)
+		if err := cursor.All(ctx, &loomies); err != nil || len(loomies) == 0 {
+			return removed, err
 		}
-	}
 
-	// Remove the outdated loomies
-	if len(expiredLoomies) > 0 {
-		_, err = WildLoomiesCollection.DeleteMany(context.Background(), bson.M{"_id": bson.M{"$in": expiredLoomies}})
+		ids := make([]primitive.ObjectID, len(loomies))
+		for index, loomie := range loomies {
+			ids[index] = loomie.Id
+		}
+
+		// Remove the references first, a zone must not point to a deleted loomie
+		_, err = ZonesCollection.UpdateMany(ctx, bson.M{"loomies": bson.M{"$in": ids}}, bson.M{"$pullAll": bson.M{"loomies": ids}})
 		if err != nil {
-			return err
+			return removed, err
 		}
-	}
 
-	return nil
+		result, err := WildLoomiesCollection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+		if err != nil {
+			return removed, err
+		}
+
+		removed += int(result.DeletedCount)
+	}
 }
 
 // GetLoomiesFromZoneId returns the loomies that are in a zone
@@ -139,17 +166,18 @@ func GetLoomiesFromZoneId(id primitive.ObjectID) ([]interfaces.WildLoomie, error
 	return loomies, err
 }
 
-// InsertWildLoomie inserts a wild loomie into the database if the zone doesn't have the maximum amount of loomies
-func InsertWildLoomie(loomie interfaces.WildLoomie) (interfaces.WildLoomie, bool) {
-	// Get the zone coordinates
-	coordX, coordY := utils.GetZoneCoordinatesFromGPS(interfaces.Coordinates{
-		Latitude:  loomie.Latitude,
-		Longitude: loomie.Longitude,
-	})
+// InsertWildLoomie inserts a wild loomie into the database if it's inside the region and the zone doesn't have the
+// maximum amount of loomies
+func InsertWildLoomie(region interfaces.Region, loomie interfaces.WildLoomie, maxLoomiesPerZone int) (interfaces.WildLoomie, bool) {
+	coordinates := interfaces.Coordinates{Latitude: loomie.Latitude, Longitude: loomie.Longitude}
+
+	if !region.Contains(coordinates) {
+		return interfaces.WildLoomie{}, false
+	}
 
 	// Get the zone from the database
-	// fmt.Println("Getting zone from coordinates", coordX, coordY)
-	zone, err := GetZoneFromCoordinates(coordX, coordY)
+	coordX, coordY := utils.GetZoneCoordinatesFromGPS(region, coordinates)
+	zone, err := GetZoneFromCoordinates(region.Id, coordX, coordY)
 
 	if err != nil {
 		return interfaces.WildLoomie{}, false
@@ -159,13 +187,14 @@ func InsertWildLoomie(loomie interfaces.WildLoomie) (interfaces.WildLoomie, bool
 	currentLoomies, err := GetLoomiesFromZoneId(zone.Id)
 	// fmt.Println("Zone has", len(currentLoomies), "loomies")
 
-	if err != nil || len(currentLoomies) >= configuration.GetMaxLoomiesPerZone() {
+	if err != nil || len(currentLoomies) >= maxLoomiesPerZone {
 		// fmt.Println("Zone has the maximum amount of loomies")
 		return interfaces.WildLoomie{}, false
 	}
 
 	// Insert the wild loomie into the database
 	loomie.ZoneId = zone.Id
+	loomie.Location = coordinates.ToGeoPoint()
 	loomie.GeneratedAt = time.Now().Unix()
 	result, err := WildLoomiesCollection.InsertOne(context.Background(), loomie)
 
@@ -180,59 +209,27 @@ func InsertWildLoomie(loomie interfaces.WildLoomie) (interfaces.WildLoomie, bool
 	return loomie, err == nil
 }
 
-// GetNearWildLoomies returns the wild loomies that are near the coordinates
-func GetNearWildLoomies(coordinates interfaces.Coordinates, userId primitive.ObjectID) ([]interfaces.PopulatedWildLoomie, error) {
+// GetNearWildLoomies returns the wild loomies that are near the coordinates and not older than the time to live
+// (in minutes)
+func GetNearWildLoomies(coordinates interfaces.Coordinates, userId primitive.ObjectID, visibilityRadius float64, loomieTTL int) ([]interfaces.PopulatedWildLoomie, error) {
 	zoneLoomies := []interfaces.WildLoomie{}
 	loomies := []interfaces.PopulatedWildLoomie{}
 
-	// Get the zones that are near the current zone
-	nearZonesCoordinates := utils.GetNearZonesCoordinates(coordinates)
-
-	// Filter
-	zonesFilter := bson.M{"coordinates": bson.M{"$in": nearZonesCoordinates}}
-	matchFilter := bson.M{"$match": zonesFilter}
-
-	// Aggregation to populate zone's loomies
-	lookupIntoLoomies := bson.M{
-		"$lookup": bson.M{
-			"from":         "wild_loomies",
-			"localField":   "loomies",
-			"foreignField": "_id",
-			"as":           "populated_loomies",
-		},
-	}
-
 	// Ignore the loomies that are captured by the user
-	aggProject := bson.M{
-		"$project": bson.M{
-			"populated_loomies": bson.M{
-				"$filter": bson.M{
-					"input": "$populated_loomies",
-					"as":    "loomie",
-					"cond": bson.M{
-						"$not": bson.M{
-							"$in": []interface{}{userId, "$$loomie.captured_by"},
-						},
-					},
-				},
-			},
-		},
+	filter := bson.M{
+		"location":    withinVisibilityRadius(coordinates, visibilityRadius),
+		"captured_by": bson.M{"$ne": userId},
 	}
 
-	// Make the query
-	cursor, err := ZonesCollection.Aggregate(context.Background(), []bson.M{matchFilter, lookupIntoLoomies, aggProject})
-
+	cursor, err := WildLoomiesCollection.Find(context.Background(), filter)
 	if err != nil {
 		return []interfaces.PopulatedWildLoomie{}, err
 	}
 
-	for cursor.Next(context.Background()) {
-		var zone interfaces.ZoneWithPopulatedLoomies
-		cursor.Decode(&zone)
-		zoneLoomies = append(zoneLoomies, zone.PopulatedLoomies...)
+	if err := cursor.All(context.Background(), &zoneLoomies); err != nil {
+		return []interfaces.PopulatedWildLoomie{}, err
 	}
 
-	loomieTTL := configuration.GetWildLoomiesTTL()
 	currentTime := time.Now()
 
 	for _, loomie := range zoneLoomies {
@@ -303,8 +300,8 @@ func GetWildLoomieById(loomie_id string) (interfaces.WildLoomie, error) {
 }
 
 // InsertInCaughtLoomies Insert the loomie in the caught loomies collection
-func InsertInCaughtLoomies(caught_loomie interfaces.CaughtLoomie) (primitive.ObjectID, error) {
-	result, err := CaughtLoomiesCollection.InsertOne(context.TODO(), caught_loomie)
+func InsertInCaughtLoomies(ctx context.Context, caught_loomie interfaces.CaughtLoomie) (primitive.ObjectID, error) {
+	result, err := CaughtLoomiesCollection.InsertOne(ctx, caught_loomie)
 
 	if err != nil {
 		return primitive.NilObjectID, err
@@ -312,53 +309,115 @@ func InsertInCaughtLoomies(caught_loomie interfaces.CaughtLoomie) (primitive.Obj
 
 	id, _ := result.InsertedID.(primitive.ObjectID)
 
+	audit.Record(ctx, interfaces.AuditEvent{
+		UserId:   caught_loomie.Owner,
+		Action:   "loomie.create",
+		Entity:   "caught_loomies",
+		EntityId: id,
+		After:    caught_loomie,
+	})
+
 	return id, err
 }
 
-// WasSuccessfulCapture Check if the loomie was successful capture (Calculate the chance of success)
-func WasSuccessfulCapture(loomie interfaces.WildLoomie, ball interfaces.Loomball) bool {
-	chance := 0
-	capture := utils.GetRandomInt(0, 100)
+// InsertUserInArrayOfWildLoomie insert user id in array CapturedBy from wild loomie. The filter avoids capturing the
+// same wild loomie twice
+func InsertUserInArrayOfWildLoomie(ctx context.Context, loomie interfaces.WildLoomie, user interfaces.User) error {
+	filter := bson.D{{Key: "_id", Value: loomie.Id}, {Key: "captured_by", Value: bson.D{{Key: "$ne", Value: user.Id}}}}
+	update := bson.D{{Key: "$push", Value: bson.D{
+		{Key: "captured_by", Value: user.Id},
+	},
+	}}
+	result, err := WildLoomiesCollection.UpdateOne(ctx, filter, update)
 
-	if loomie.Level >= ball.DecayUntil {
-		chance = int(ball.MinimumProbability * 100)
-	} else if loomie.Level <= int(ball.EffectiveUntil) {
-		chance = 100
-	} else {
-		chance = -((100-int(ball.MinimumProbability*100))/(ball.DecayUntil-ball.EffectiveUntil))*(loomie.Level-ball.EffectiveUntil) + 100
+	if err != nil {
+		return err
 	}
 
-	if capture <= chance {
-		return true
+	if result.MatchedCount == 0 {
+		return ErrLoomieAlreadyCaught
 	}
-	return false
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		UserId:   user.Id,
+		Action:   "wild_loomie.capture",
+		Entity:   "wild_loomies",
+		EntityId: loomie.Id,
+	})
+
+	return nil
 }
 
-// CheckIfUserInArrayOfWildLoomie check if user id alrady exists in array CapturedBy from wild loomie
-func CheckIfUserInArrayOfWildLoomie(loomie interfaces.WildLoomie, user interfaces.User) bool {
-	for _, element := range loomie.CapturedBy {
-		if element == user.Id {
-			return false
+// CaptureWildLoomie Spends the loomball and, if the capture was successful, stores the wild loomie as a caught loomie
+// of the user. All the writes are done in a single unit of work, so the loomball is not lost if the loomie can't
+// be stored. Returns the id of the caught loomie or a nil id if the loomie was not captured
+func CaptureWildLoomie(ctx context.Context, user interfaces.User, loomie interfaces.WildLoomie, loomballId primitive.ObjectID, wasCaptured bool) (primitive.ObjectID, error) {
+	caughtLoomieId := primitive.NilObjectID
+
+	err := RunUnitOfWork(ctx, func(uow *UnitOfWork) error {
+		caughtLoomieId = primitive.NilObjectID
+
+		err := uow.Step(CaptureLoomballStep, func(ctx context.Context) error {
+			err := DecrementItemFromUserInventory(ctx, user.Id, loomballId, 1)
+
+			// Keep the driver errors (and their labels) so the transaction can be retried
+			if errors.Is(err, ErrItemNotFound) || errors.Is(err, mongo.ErrNoDocuments) {
+				return ErrLoomballNotFound
+			}
+
+			return err
+		})
+
+		if err != nil || !wasCaptured {
+			return err
 		}
-	}
 
-	return true
-}
+		err = uow.Step(CaptureRegisterStep, func(ctx context.Context) error {
+			return InsertUserInArrayOfWildLoomie(ctx, loomie, user)
+		})
 
-// InsertUserInArrayOfWildLoomie insert user id in array CapturedBy from wild loomie
-func InsertUserInArrayOfWildLoomie(loomie interfaces.WildLoomie, user interfaces.User) error {
-	filter := bson.D{{Key: "_id", Value: loomie.Id}}
-	update := bson.D{{Key: "$push", Value: bson.D{
-		{Key: "captured_by", Value: user.Id},
-	},
-	}}
-	_, err := WildLoomiesCollection.UpdateOne(context.TODO(), filter, update)
+		if err != nil {
+			return err
+		}
 
-	return err
+		err = uow.Step(CaptureInsertLoomieStep, func(ctx context.Context) error {
+			caughtLoomieId, err = InsertInCaughtLoomies(ctx, interfaces.CaughtLoomie{
+				Owner:      user.Id,
+				IsBusy:     false,
+				Serial:     loomie.Serial,
+				Name:       loomie.Name,
+				Types:      loomie.Types,
+				Rarity:     loomie.Rarity,
+				HP:         loomie.HP,
+				Attack:     loomie.Attack,
+				Defense:    loomie.Defense,
+				Level:      loomie.Level,
+				Experience: loomie.Experience,
+			})
+
+			return err
+		})
+
+		if err != nil {
+			return err
+		}
+
+		return uow.Step(CaptureAddToUserStep, func(ctx context.Context) error {
+			return AddToUserLoomies(ctx, user, caughtLoomieId)
+		})
+	})
+
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	return caughtLoomieId, nil
 }
 
 // IncrementLoomieLevel increment the level of the loomie by the given amount
-func IncrementLoomieLevel(userId primitive.ObjectID, loomieId primitive.ObjectID, amount uint) error {
+func IncrementLoomieLevel(ctx context.Context, userId primitive.ObjectID, loomieId primitive.ObjectID, amount uint) error {
+	var before interfaces.CaughtLoomie
+
 	// Check if and user is owner from a caught_loomie
 	filter := bson.M{
 		"_id":   loomieId,
@@ -371,21 +430,32 @@ func IncrementLoomieLevel(userId primitive.ObjectID, loomieId primitive.ObjectID
 	}
 
 	// Update the increment
-	result := CaughtLoomiesCollection.FindOneAndUpdate(context.Background(), filter, update)
+	err := CaughtLoomiesCollection.FindOneAndUpdate(ctx, filter, update).Decode(&before)
 
 	// Check errors
-	if result.Err() != nil {
-		return result.Err()
+	if err != nil {
+		return err
 	}
 
+	audit.Record(ctx, interfaces.AuditEvent{
+		UserId:   userId,
+		Action:   "loomie.level_up",
+		Entity:   "caught_loomies",
+		EntityId: loomieId,
+		Before:   bson.M{"level": before.Level},
+		After:    bson.M{"level": before.Level + int(amount)},
+	})
+
 	return nil
 }
 
 // UpdateLoomiesExpAndLvl Allows to uptade experience and level of a loomie after weakened a loomie
-func UpdateLoomiesExpAndLvl(userId primitive.ObjectID, loomieToUpdate *interfaces.CombatLoomie) error {
+func UpdateLoomiesExpAndLvl(ctx context.Context, userId primitive.ObjectID, loomieToUpdate *interfaces.CombatLoomie) error {
+	var before interfaces.CaughtLoomie
+
 	// Update the first loomie in the caught loomies collection
-	_, err := CaughtLoomiesCollection.UpdateOne(
-		context.TODO(),
+	err := CaughtLoomiesCollection.FindOneAndUpdate(
+		ctx,
 		bson.D{
 			{Key: "_id", Value: loomieToUpdate.Id},
 		},
@@ -395,35 +465,79 @@ func UpdateLoomiesExpAndLvl(userId primitive.ObjectID, loomieToUpdate *interface
 				{Key: "level", Value: loomieToUpdate.Level},
 			}},
 		},
-	)
+	).Decode(&before)
 
 	if err != nil {
 		return errors.New("Error")
 	}
 
+	audit.Record(ctx, interfaces.AuditEvent{
+		UserId:   userId,
+		Action:   "loomie.update_experience",
+		Entity:   "caught_loomies",
+		EntityId: loomieToUpdate.Id,
+		Before:   bson.M{"experience": before.Experience, "level": before.Level},
+		After:    bson.M{"experience": loomieToUpdate.Experience, "level": loomieToUpdate.Level},
+	})
+
 	return nil
 }
 
 // UpdateLoomiesBusyState Allows to uptade is_busy field of a looser o winner team of loomies (depends of the flag)
-func UpdateLoomiesBusyState(loomiesProtectorsIds []primitive.ObjectID, flag bool) (err error) {
+func UpdateLoomiesBusyState(ctx context.Context, loomiesProtectorsIds []primitive.ObjectID, flag bool) (err error) {
 	_, err = CaughtLoomiesCollection.UpdateMany(
-		context.TODO(),
+		ctx,
 		bson.M{"_id": bson.M{"$in": loomiesProtectorsIds}},
 		bson.D{{Key: "$set", Value: bson.D{
 			{Key: "is_busy", Value: flag},
 		}}},
 	)
 
-	return err
+	if err != nil {
+		return err
+	}
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		Action: "loomie.update_busy_state",
+		Entity: "caught_loomies",
+		After:  bson.M{"loomies": loomiesProtectorsIds, "is_busy": flag},
+	})
+
+	return nil
 }
 
 // RemoveLoomieTeam Removes Loomie Team just when the gym doesnt have owner
-func RemoveLoomieTeam(loomiesProtectorsIds []primitive.ObjectID) (err error) {
-	_, err = CaughtLoomiesCollection.DeleteMany(
-		context.TODO(),
-		bson.M{"_id": bson.M{"$in": loomiesProtectorsIds}},
-	)
-	return err
+func RemoveLoomieTeam(ctx context.Context, loomiesProtectorsIds []primitive.ObjectID) (err error) {
+	removed := []interfaces.CaughtLoomie{}
+	filter := bson.M{"_id": bson.M{"$in": loomiesProtectorsIds}}
+
+	// Keep the removed loomies in the audit log
+	cursor, err := CaughtLoomiesCollection.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	err = cursor.All(ctx, &removed)
+	if err != nil {
+		return err
+	}
+
+	_, err = CaughtLoomiesCollection.DeleteMany(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	for _, loomie := range removed {
+		audit.Record(ctx, interfaces.AuditEvent{
+			UserId:   loomie.Owner,
+			Action:   "loomie.delete",
+			Entity:   "caught_loomies",
+			EntityId: loomie.Id,
+			Before:   loomie,
+		})
+	}
+
+	return nil
 }
 
 // GetLoomieTypeDetails Returns the details of a loomie type
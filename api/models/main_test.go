@@ -0,0 +1,32 @@
+package models
+
+import (
+	"context"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/PedroChaparro/loomies-backend/audit"
+	"github.com/PedroChaparro/loomies-backend/configuration"
+)
+
+// TestMain connects the collections to the database of the test configuration
+func TestMain(m *testing.M) {
+	config, err := configuration.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	client, err := configuration.NewMongoClient(config.Mongo)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	database := client.Database(config.Mongo.Database)
+	UseDatabase(database)
+	audit.UseDatabase(database)
+
+	code := m.Run()
+	client.Disconnect(context.Background())
+	os.Exit(code)
+}
@@ -0,0 +1,254 @@
+package models
+
+import (
+	"context"
+	"errors"
+
+	"github.com/PedroChaparro/loomies-backend/audit"
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SetUserMfaPendingSecret Stores the secret of an authenticator that was not confirmed yet
+func SetUserMfaPendingSecret(userId primitive.ObjectID, secret string) error {
+	_, err := UserCollection.UpdateOne(
+		context.TODO(),
+		bson.D{{Key: "_id", Value: userId}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "mfa.pending_secret", Value: secret},
+			{Key: "mfa.failed_attempts", Value: 0},
+		}}},
+	)
+
+	return err
+}
+
+// EnableUserMfa Enables the two-factor authentication with the (confirmed) pending secret and stores the recovery codes hashes
+func EnableUserMfa(ctx context.Context, userId primitive.ObjectID, secret string, recoveryCodes []string, usedStep int64) error {
+	result, err := UserCollection.UpdateOne(
+		ctx,
+		bson.D{{Key: "_id", Value: userId}, {Key: "mfa.pending_secret", Value: secret}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "mfa", Value: bson.D{
+				{Key: "enabled", Value: true},
+				{Key: "secret", Value: secret},
+				{Key: "recovery_codes", Value: recoveryCodes},
+				{Key: "last_used_step", Value: usedStep},
+			}},
+		}}},
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return errors.New("The pending authenticator has changed")
+	}
+
+	recordMfaEvent(ctx, userId, "mfa.enable")
+	return nil
+}
+
+// DisableUserMfa Removes the two-factor authentication settings of the user
+func DisableUserMfa(ctx context.Context, userId primitive.ObjectID) error {
+	_, err := UserCollection.UpdateOne(
+		ctx,
+		bson.D{{Key: "_id", Value: userId}},
+		bson.D{{Key: "$unset", Value: bson.D{
+			{Key: "mfa", Value: ""},
+		}}},
+	)
+
+	if err != nil {
+		return err
+	}
+
+	recordMfaEvent(ctx, userId, "mfa.disable")
+	return nil
+}
+
+// UseUserMfaStep Marks the TOTP step as used, returns false if it (or a later one) was already used
+func UseUserMfaStep(userId primitive.ObjectID, step int64) (bool, error) {
+	result, err := UserCollection.UpdateOne(
+		context.TODO(),
+		bson.D{
+			{Key: "_id", Value: userId},
+			{Key: "mfa.last_used_step", Value: bson.D{{Key: "$lt", Value: step}}},
+		},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "mfa.last_used_step", Value: step},
+		}}},
+	)
+
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount == 1, nil
+}
+
+// UseUserMfaRecoveryCode Removes the recovery code hash from the user, returns false if it was not found
+func UseUserMfaRecoveryCode(ctx context.Context, userId primitive.ObjectID, codeHash string) (bool, error) {
+	result, err := UserCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "_id", Value: userId},
+			{Key: "mfa.recovery_codes", Value: codeHash},
+		},
+		bson.D{{Key: "$pull", Value: bson.D{
+			{Key: "mfa.recovery_codes", Value: codeHash},
+		}}},
+	)
+
+	if err != nil {
+		return false, err
+	}
+
+	if result.ModifiedCount == 0 {
+		return false, nil
+	}
+
+	recordMfaEvent(ctx, userId, "mfa.use_recovery_code")
+	return true, nil
+}
+
+// StartUserMfaChallenge Stores the challenge of a new mfa token, it replaces the challenge of the previous tokens
+func StartUserMfaChallenge(userId primitive.ObjectID, challengeId string) error {
+	_, err := UserCollection.UpdateOne(
+		context.TODO(),
+		bson.D{{Key: "_id", Value: userId}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "mfa.challenge_id", Value: challengeId},
+			{Key: "mfa.failed_attempts", Value: 0},
+		}}},
+	)
+
+	return err
+}
+
+// EndUserMfaChallenge Removes the challenge so its mfa token can't be used again, returns false if it was already
+// removed or replaced
+func EndUserMfaChallenge(userId primitive.ObjectID, challengeId string) (bool, error) {
+	result, err := UserCollection.UpdateOne(
+		context.TODO(),
+		bson.D{{Key: "_id", Value: userId}, {Key: "mfa.challenge_id", Value: challengeId}},
+		bson.D{{Key: "$unset", Value: bson.D{
+			{Key: "mfa.challenge_id", Value: ""},
+			{Key: "mfa.failed_attempts", Value: ""},
+		}}},
+	)
+
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount == 1, nil
+}
+
+// RecordUserMfaChallengeFailure Counts an invalid code sent with the mfa token of the challenge. The challenge is
+// removed after the given amount of failures, returns true if it was removed
+func RecordUserMfaChallengeFailure(ctx context.Context, userId primitive.ObjectID, challengeId string, maxAttempts int) (bool, error) {
+	return recordMfaFailure(ctx, userId, "mfa.challenge_id", challengeId, maxAttempts)
+}
+
+// RecordUserMfaPendingFailure Counts an invalid code sent to confirm the pending authenticator. The pending
+// authenticator is removed after the given amount of failures, returns true if it was removed
+func RecordUserMfaPendingFailure(ctx context.Context, userId primitive.ObjectID, secret string, maxAttempts int) (bool, error) {
+	return recordMfaFailure(ctx, userId, "mfa.pending_secret", secret, maxAttempts)
+}
+
+// recordMfaFailure "private" function to increment the failed attempts while the field has the given value and remove
+// the field when the attempts reach the maximum
+func recordMfaFailure(ctx context.Context, userId primitive.ObjectID, field string, value string, maxAttempts int) (bool, error) {
+	var user interfaces.User
+	filter := bson.D{{Key: "_id", Value: userId}, {Key: field, Value: value}}
+
+	err := UserCollection.FindOneAndUpdate(
+		ctx,
+		filter,
+		bson.D{{Key: "$inc", Value: bson.D{{Key: "mfa.failed_attempts", Value: 1}}}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.D{{Key: "mfa.failed_attempts", Value: 1}}),
+	).Decode(&user)
+
+	// The challenge or the pending authenticator was already removed
+	if err == mongo.ErrNoDocuments {
+		return true, nil
+	}
+
+	if err != nil || user.Mfa.FailedAttempts < maxAttempts {
+		return false, err
+	}
+
+	_, err = UserCollection.UpdateOne(
+		ctx,
+		filter,
+		bson.D{{Key: "$unset", Value: bson.D{
+			{Key: field, Value: ""},
+			{Key: "mfa.failed_attempts", Value: ""},
+		}}},
+	)
+
+	if err != nil {
+		return false, err
+	}
+
+	recordMfaEvent(ctx, userId, "mfa.too_many_failures")
+	return true, nil
+}
+
+// RecordUserMfaActionFailure Counts an invalid code sent to confirm a sensitive action (Eg. disabling the
+// authenticator). The actions are locked until the given unix time after the given amount of failures, returns true
+// if they were locked
+func RecordUserMfaActionFailure(ctx context.Context, userId primitive.ObjectID, maxAttempts int, lockUntil int64) (bool, error) {
+	var user interfaces.User
+	filter := bson.D{{Key: "_id", Value: userId}, {Key: "mfa.enabled", Value: true}}
+
+	err := UserCollection.FindOneAndUpdate(
+		ctx,
+		filter,
+		bson.D{{Key: "$inc", Value: bson.D{{Key: "mfa.action_failed_attempts", Value: 1}}}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.D{{Key: "mfa.action_failed_attempts", Value: 1}}),
+	).Decode(&user)
+
+	// The authenticator was already disabled
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+
+	if err != nil || user.Mfa.ActionFailedAttempts < maxAttempts {
+		return false, err
+	}
+
+	_, err = UserCollection.UpdateOne(
+		ctx,
+		filter,
+		bson.D{
+			{Key: "$set", Value: bson.D{{Key: "mfa.locked_until", Value: lockUntil}}},
+			{Key: "$unset", Value: bson.D{{Key: "mfa.action_failed_attempts", Value: ""}}},
+		},
+	)
+
+	if err != nil {
+		return false, err
+	}
+
+	recordMfaEvent(ctx, userId, "mfa.locked")
+	return true, nil
+}
+
+// recordMfaEvent "private" function to audit a change on the two-factor authentication settings, the secrets
+// and recovery codes are never stored in the audit log
+func recordMfaEvent(ctx context.Context, userId primitive.ObjectID, action string) {
+	audit.Record(ctx, interfaces.AuditEvent{
+		ActorId:  userId,
+		UserId:   userId,
+		Action:   action,
+		Entity:   "users",
+		EntityId: userId,
+	})
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/PedroChaparro/loomies-backend/audit"
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SearchUsers Returns the users whose username or email contains the given text (case insensitive)
+func SearchUsers(text string, limit int64) ([]interfaces.User, error) {
+	users := []interfaces.User{}
+	pattern := bson.M{"$regex": regexp.QuoteMeta(text), "$options": "i"}
+
+	cursor, err := UserCollection.Find(
+		context.TODO(),
+		bson.M{"$or": bson.A{bson.M{"username": pattern}, bson.M{"email": pattern}}},
+		options.Find().SetLimit(limit).SetSort(bson.D{{Key: "username", Value: 1}}),
+	)
+
+	if err != nil {
+		return users, err
+	}
+
+	err = cursor.All(context.TODO(), &users)
+	return users, err
+}
+
+// GetUserBan Returns the ban of the user with the given id (nil if it's not banned)
+func GetUserBan(userId string) (*interfaces.UserSanction, error) {
+	var user interfaces.User
+
+	mongoid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = UserCollection.FindOne(
+		context.TODO(),
+		bson.D{{Key: "_id", Value: mongoid}},
+		options.FindOne().SetProjection(bson.D{{Key: "ban", Value: 1}}),
+	).Decode(&user)
+
+	return user.Ban, err
+}
+
+// SetUserSanction Sets the ban or mute (field) of the user
+func SetUserSanction(ctx context.Context, userId primitive.ObjectID, field string, sanction interfaces.UserSanction) error {
+	var before bson.M
+
+	err := UserCollection.FindOneAndUpdate(
+		ctx,
+		bson.D{{Key: "_id", Value: userId}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: field, Value: sanction}}}},
+		options.FindOneAndUpdate().SetProjection(bson.D{{Key: field, Value: 1}}),
+	).Decode(&before)
+
+	if err != nil {
+		return err
+	}
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		UserId:   userId,
+		Action:   "user." + field,
+		Entity:   "users",
+		EntityId: userId,
+		Before:   before[field],
+		After:    sanction,
+	})
+
+	return nil
+}
+
+// RemoveUserSanction Removes the ban or mute (field) of the user
+func RemoveUserSanction(ctx context.Context, userId primitive.ObjectID, field string) error {
+	var before bson.M
+
+	err := UserCollection.FindOneAndUpdate(
+		ctx,
+		bson.D{{Key: "_id", Value: userId}},
+		bson.D{{Key: "$unset", Value: bson.D{{Key: field, Value: ""}}}},
+		options.FindOneAndUpdate().SetProjection(bson.D{{Key: field, Value: 1}}),
+	).Decode(&before)
+
+	if err != nil {
+		return err
+	}
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		UserId:   userId,
+		Action:   "user.un" + field,
+		Entity:   "users",
+		EntityId: userId,
+		Before:   before[field],
+	})
+
+	return nil
+}
+
+// CountGymsByOwner Returns the amount of gyms owned by the given user
+func CountGymsByOwner(ownerId primitive.ObjectID) (int64, error) {
+	return GymsCollection.CountDocuments(context.TODO(), bson.D{{Key: "owner", Value: ownerId}})
+}
+
+// GetGymsByOwner Returns the gyms owned by the given user
+func GetGymsByOwner(ownerId primitive.ObjectID) ([]interfaces.Gym, error) {
+	gyms := []interfaces.Gym{}
+	cursor, err := GymsCollection.Find(context.TODO(), bson.D{{Key: "owner", Value: ownerId}})
+
+	if err != nil {
+		return gyms, err
+	}
+
+	err = cursor.All(context.TODO(), &gyms)
+	return gyms, err
+}
+
+// GetRewardCollection Returns the collection (items or loom_balls) of the given reward id
+func GetRewardCollection(rewardId primitive.ObjectID) (string, error) {
+	count, err := ItemsCollection.CountDocuments(context.TODO(), bson.D{{Key: "_id", Value: rewardId}})
+
+	if err != nil || count > 0 {
+		return "items", err
+	}
+
+	count, err = LoomballsCollection.CountDocuments(context.TODO(), bson.D{{Key: "_id", Value: rewardId}})
+
+	if err != nil {
+		return "", err
+	}
+
+	if count == 0 {
+		return "", mongo.ErrNoDocuments
+	}
+
+	return "loom_balls", nil
+}
+
+// ReleaseUserBusyLoomies Sets the given loomies of the user as not busy. If no loomies are given, all the busy loomies
+// that are not protecting a gym are released. Returns the amount of released loomies
+func ReleaseUserBusyLoomies(ctx context.Context, userId primitive.ObjectID, loomiesIds []primitive.ObjectID) (int64, error) {
+	filter := bson.D{{Key: "owner", Value: userId}, {Key: "is_busy", Value: true}}
+
+	if len(loomiesIds) > 0 {
+		filter = append(filter, bson.E{Key: "_id", Value: bson.D{{Key: "$in", Value: loomiesIds}}})
+	} else {
+		gyms, err := GetGymsByOwner(userId)
+		if err != nil {
+			return 0, err
+		}
+
+		protectors := []primitive.ObjectID{}
+		for _, gym := range gyms {
+			protectors = append(protectors, gym.Protectors...)
+		}
+
+		filter = append(filter, bson.E{Key: "_id", Value: bson.D{{Key: "$nin", Value: protectors}}})
+	}
+
+	result, err := CaughtLoomiesCollection.UpdateMany(
+		ctx,
+		filter,
+		bson.D{{Key: "$set", Value: bson.D{{Key: "is_busy", Value: false}}}},
+	)
+
+	if err != nil {
+		return 0, err
+	}
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		UserId: userId,
+		Action: "loomie.release",
+		Entity: "caught_loomies",
+		After:  bson.M{"loomies": loomiesIds, "released": result.ModifiedCount},
+	})
+
+	return result.ModifiedCount, nil
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// InsertOIDCState Saves the state of a new OpenID Connect authorization request
+func InsertOIDCState(state interfaces.OIDCState) error {
+	_, err := OIDCStatesCollection.InsertOne(context.TODO(), state)
+
+	if err != nil {
+		fmt.Println(err)
+		return fmt.Errorf("Error while inserting the authorization state in the database")
+	}
+
+	return nil
+}
+
+// PopOIDCState Returns and removes the (non expired) authorization state for the given provider
+// so it can only be used once
+func PopOIDCState(provider string, state string) (interfaces.OIDCState, error) {
+	var stateDoc interfaces.OIDCState
+
+	err := OIDCStatesCollection.FindOneAndDelete(
+		context.TODO(),
+		bson.D{{Key: "state", Value: state}, {Key: "provider", Value: provider}},
+	).Decode(&stateDoc)
+
+	if err != nil {
+		return interfaces.OIDCState{}, err
+	}
+
+	// Expired states are treated as if they didn't exist
+	if !time.Now().Before(time.Unix(stateDoc.ExpiresAt, 0)) {
+		return interfaces.OIDCState{}, mongo.ErrNoDocuments
+	}
+
+	return stateDoc, nil
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"context"
+
+	"github.com/PedroChaparro/loomies-backend/audit"
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// UpdateUserProfile Replaces the public profile of the user
+func UpdateUserProfile(ctx context.Context, user interfaces.User, profile interfaces.UserProfile) error {
+	_, err := UserCollection.UpdateOne(
+		ctx,
+		bson.D{{Key: "_id", Value: user.Id}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "profile", Value: profile}}}},
+	)
+
+	if err != nil {
+		return err
+	}
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		UserId:   user.Id,
+		Action:   "user.update_profile",
+		Entity:   "users",
+		EntityId: user.Id,
+		Before:   user.Profile,
+		After:    profile,
+	})
+
+	return nil
+}
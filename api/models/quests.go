@@ -0,0 +1,277 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/audit"
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Game events that increment the progress of the quests
+const (
+	QuestCaptureEvent         = repositories.QuestCaptureEvent
+	QuestDefeatProtectorEvent = repositories.QuestDefeatProtectorEvent
+	QuestVisitZoneEvent       = repositories.QuestVisitZoneEvent
+	QuestFuseEvent            = repositories.QuestFuseEvent
+	QuestClaimRewardEvent     = repositories.QuestClaimRewardEvent
+)
+
+// QuestsPerPeriod is the number of quests generated to each user per period
+var QuestsPerPeriod = repositories.QuestsPerPeriod
+
+// GetQuestTemplates Returns the quest templates of the given period sorted by serial
+func GetQuestTemplates(period string) ([]interfaces.QuestTemplate, error) {
+	templates := []interfaces.QuestTemplate{}
+
+	cursor, err := QuestTemplatesCollection.Find(
+		context.TODO(),
+		bson.D{{Key: "period", Value: period}},
+		options.Find().SetSort(bson.D{{Key: "serial", Value: 1}}),
+	)
+
+	if err != nil {
+		return templates, err
+	}
+
+	err = cursor.All(context.TODO(), &templates)
+	return templates, err
+}
+
+// newUserQuest "private" function to create a quest of the user from the given template
+func newUserQuest(template interfaces.QuestTemplate, userId primitive.ObjectID) (interfaces.UserQuest, error) {
+	goal := template.MinGoal
+	if template.MaxGoal > template.MinGoal {
+		goal = utils.GetRandomInt(template.MinGoal, template.MaxGoal+1)
+	}
+
+	quest := interfaces.UserQuest{
+		UserId:      userId,
+		TemplateId:  template.Id,
+		Period:      template.Period,
+		Event:       template.Event,
+		Description: strings.ReplaceAll(template.Description, "{goal}", strconv.Itoa(goal)),
+		Unique:      template.Unique,
+		SeenValues:  []string{},
+		Goal:        goal,
+		Rewards:     []interfaces.GymRewardItem{},
+	}
+
+	if template.LoomieType != "" {
+		loomieTypes, err := GetLoomieTypesByNames([]string{template.LoomieType})
+
+		if err != nil {
+			return quest, err
+		}
+
+		if len(loomieTypes) == 0 {
+			return quest, fmt.Errorf("Loomie type %s was not found", template.LoomieType)
+		}
+
+		quest.LoomieTypeId = loomieTypes[0].Id
+	}
+
+	for _, reward := range template.Rewards {
+		rewardId, err := getRewardIdBySerial(reward.RewardCollection, reward.RewardSerial)
+
+		if err != nil {
+			return quest, err
+		}
+
+		quest.Rewards = append(quest.Rewards, interfaces.GymRewardItem{
+			RewardCollection: reward.RewardCollection,
+			RewardId:         rewardId,
+			RewardQuantity:   reward.RewardQuantity,
+		})
+	}
+
+	return quest, nil
+}
+
+// generateUserQuests "private" function to generate the missing quests of the user in the current period of the
+// given location
+func generateUserQuests(ctx context.Context, userId primitive.ObjectID, period string, now time.Time, location *time.Location) error {
+	periodKey, expiresAt := utils.GetPeriod(period, now, location)
+
+	count, err := UserQuestsCollection.CountDocuments(ctx, bson.D{
+		{Key: "user_id", Value: userId},
+		{Key: "period_key", Value: periodKey},
+	})
+
+	if err != nil || int(count) >= QuestsPerPeriod[period] {
+		return err
+	}
+
+	templates, err := GetQuestTemplates(period)
+	if err != nil {
+		return err
+	}
+
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	r.Shuffle(len(templates), func(i, j int) {
+		templates[i], templates[j] = templates[j], templates[i]
+	})
+
+	for slot := 0; slot < QuestsPerPeriod[period] && slot < len(templates); slot++ {
+		quest, err := newUserQuest(templates[slot], userId)
+		if err != nil {
+			return err
+		}
+
+		quest.PeriodKey = periodKey
+		quest.Slot = slot
+		quest.CreatedAt = now.Unix()
+		quest.ExpiresAt = expiresAt.Unix()
+
+		// The upsert by slot avoids generating the quests twice on concurrent requests
+		_, err = UserQuestsCollection.UpdateOne(
+			ctx,
+			bson.D{
+				{Key: "user_id", Value: userId},
+				{Key: "period_key", Value: periodKey},
+				{Key: "slot", Value: slot},
+			},
+			bson.D{{Key: "$setOnInsert", Value: quest}},
+			options.Update().SetUpsert(true),
+		)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetUserQuests Returns the active quests of the user, generating the ones of the current day and week if needed
+func GetUserQuests(ctx context.Context, userId primitive.ObjectID) ([]interfaces.UserQuest, error) {
+	quests := []interfaces.UserQuest{}
+	now := time.Now()
+
+	// The periods start at the midnight of the user region
+	user, err := GetUserById(userId.Hex())
+	if err != nil {
+		return quests, err
+	}
+
+	location := GetUserLocation(user)
+
+	for _, period := range []string{"daily", "weekly"} {
+		if err := generateUserQuests(ctx, userId, period, now, location); err != nil {
+			return quests, err
+		}
+	}
+
+	cursor, err := UserQuestsCollection.Find(
+		ctx,
+		bson.D{
+			{Key: "user_id", Value: userId},
+			{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: now.Unix()}}},
+		},
+		options.Find().SetSort(bson.D{{Key: "expires_at", Value: 1}, {Key: "slot", Value: 1}}),
+	)
+
+	if err != nil {
+		return quests, err
+	}
+
+	err = cursor.All(ctx, &quests)
+	return quests, err
+}
+
+// GetUserQuestById Returns the quest with the given id if it belongs to the user
+func GetUserQuestById(userId primitive.ObjectID, questId primitive.ObjectID) (interfaces.UserQuest, error) {
+	var quest interfaces.UserQuest
+
+	err := UserQuestsCollection.FindOne(context.TODO(), bson.D{
+		{Key: "_id", Value: questId},
+		{Key: "user_id", Value: userId},
+	}).Decode(&quest)
+
+	return quest, err
+}
+
+// TrackQuestProgress Increments the progress of the active quests of the user that listen to the given event.
+// The value is used by the unique quests (Eg. the zone coordinates) and the loomie types by the capture quests
+func TrackQuestProgress(ctx context.Context, userId primitive.ObjectID, event string, value string, loomieTypes []primitive.ObjectID) error {
+	if loomieTypes == nil {
+		loomieTypes = []primitive.ObjectID{}
+	}
+
+	filter := bson.D{
+		{Key: "user_id", Value: userId},
+		{Key: "event", Value: event},
+		{Key: "claimed", Value: false},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now().Unix()}}},
+		{Key: "$expr", Value: bson.D{{Key: "$lt", Value: bson.A{"$progress", "$goal"}}}},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "loomie_type_id", Value: bson.D{{Key: "$exists", Value: false}}}},
+			bson.D{{Key: "loomie_type_id", Value: bson.D{{Key: "$in", Value: loomieTypes}}}},
+		}},
+	}
+
+	_, err := UserQuestsCollection.UpdateMany(
+		ctx,
+		append(filter, bson.E{Key: "unique", Value: false}),
+		bson.D{{Key: "$inc", Value: bson.D{{Key: "progress", Value: 1}}}},
+	)
+
+	if err != nil || value == "" {
+		return err
+	}
+
+	// The unique quests only count the values that weren't seen before
+	_, err = UserQuestsCollection.UpdateMany(
+		ctx,
+		append(filter,
+			bson.E{Key: "unique", Value: true},
+			bson.E{Key: "seen_values", Value: bson.D{{Key: "$ne", Value: value}}},
+		),
+		bson.D{
+			{Key: "$inc", Value: bson.D{{Key: "progress", Value: 1}}},
+			{Key: "$push", Value: bson.D{{Key: "seen_values", Value: value}}},
+		},
+	)
+
+	return err
+}
+
+// ClaimUserQuest Marks the completed quest as claimed and adds the rewards to the user inventory. Returns false
+// if the quest was already claimed
+func ClaimUserQuest(ctx context.Context, quest interfaces.UserQuest) (bool, error) {
+	// The filter avoids claiming the same quest twice on concurrent requests
+	result, err := UserQuestsCollection.UpdateOne(
+		ctx,
+		bson.D{
+			{Key: "_id", Value: quest.Id},
+			{Key: "user_id", Value: quest.UserId},
+			{Key: "claimed", Value: false},
+			{Key: "$expr", Value: bson.D{{Key: "$gte", Value: bson.A{"$progress", "$goal"}}}},
+		},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "claimed", Value: true}}}},
+	)
+
+	if err != nil || result.ModifiedCount == 0 {
+		return false, err
+	}
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		UserId:   quest.UserId,
+		Action:   "user.claim_quest",
+		Entity:   UserQuestsCollection.Name(),
+		EntityId: quest.Id,
+		Before:   bson.M{"claimed": false},
+		After:    bson.M{"claimed": true, "rewards": quest.Rewards},
+	})
+
+	return true, AddItemsToUserInventory(ctx, quest.UserId, quest.Rewards)
+}
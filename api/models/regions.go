@@ -0,0 +1,128 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/audit"
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Errors returned when the region can't be registered
+var (
+	ErrRegionExists        = repositories.ErrRegionExists
+	ErrRegionOverlaps      = repositories.ErrRegionOverlaps
+	ErrRegionUnknownLoomie = repositories.ErrRegionUnknownLoomie
+)
+
+// GetRegions Returns all the regions sorted by name
+func GetRegions() ([]interfaces.Region, error) {
+	regions := []interfaces.Region{}
+	cursor, err := RegionsCollection.Find(context.TODO(), bson.D{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+
+	if err != nil {
+		return regions, err
+	}
+
+	err = cursor.All(context.TODO(), &regions)
+	return regions, err
+}
+
+// GetRegionFromCoordinates Returns the region that contains the coordinates or mongo.ErrNoDocuments if the
+// coordinates are outside all the regions
+func GetRegionFromCoordinates(coordinates interfaces.Coordinates) (interfaces.Region, error) {
+	var region interfaces.Region
+
+	filter := bson.D{
+		{Key: "bounds.min_latitude", Value: bson.D{{Key: "$lte", Value: coordinates.Latitude}}},
+		{Key: "bounds.max_latitude", Value: bson.D{{Key: "$gt", Value: coordinates.Latitude}}},
+		{Key: "bounds.min_longitude", Value: bson.D{{Key: "$lte", Value: coordinates.Longitude}}},
+		{Key: "bounds.max_longitude", Value: bson.D{{Key: "$gt", Value: coordinates.Longitude}}},
+	}
+
+	err := RegionsCollection.FindOne(context.TODO(), filter).Decode(&region)
+	return region, err
+}
+
+// GetUserLocation Returns the timezone of the region where the user was last seen, UTC if it's unknown. It's used
+// for the boundaries of the daily and weekly periods (Eg. the quests and the gifts limit)
+func GetUserLocation(user interfaces.User) *time.Location {
+	var region interfaces.Region
+	name := utils.GetZoneKeyRegion(user.LastSeenZone)
+
+	if name == "" {
+		return time.UTC
+	}
+
+	err := RegionsCollection.FindOne(context.TODO(), bson.D{{Key: "name", Value: name}}).Decode(&region)
+	if err != nil {
+		return time.UTC
+	}
+
+	return utils.GetLocation(region.Timezone)
+}
+
+// CreateRegion Registers a new region. The names are unique and the bounds can't overlap with the other regions
+func CreateRegion(ctx context.Context, region interfaces.Region) (interfaces.Region, error) {
+	regions, err := GetRegions()
+
+	if err != nil {
+		return region, err
+	}
+
+	for _, other := range regions {
+		if other.Name == region.Name {
+			return region, ErrRegionExists
+		}
+
+		if other.Overlaps(region.Bounds) {
+			return region, ErrRegionOverlaps
+		}
+	}
+
+	// The spawn table must only have existing base loomies
+	if len(region.SpawnTable) > 0 {
+		serials := bson.A{}
+		for _, spawn := range region.SpawnTable {
+			serials = append(serials, spawn.Serial)
+		}
+
+		count, err := BaseLoomiesCollection.CountDocuments(ctx, bson.D{{Key: "serial", Value: bson.D{{Key: "$in", Value: serials}}}})
+
+		if err != nil {
+			return region, err
+		}
+
+		if int(count) != len(region.SpawnTable) {
+			return region, ErrRegionUnknownLoomie
+		}
+	}
+
+	region.CreatedAt = time.Now().Unix()
+	result, err := RegionsCollection.InsertOne(ctx, region)
+
+	if mongo.IsDuplicateKeyError(err) {
+		return region, ErrRegionExists
+	}
+
+	if err != nil {
+		return region, err
+	}
+
+	region.Id = result.InsertedID.(primitive.ObjectID)
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		Action:   "region.create",
+		Entity:   "regions",
+		EntityId: region.Id,
+		After:    region,
+	})
+
+	return region, nil
+}
@@ -0,0 +1,624 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/audit"
+	"github.com/PedroChaparro/loomies-backend/configuration"
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// The mongo repositories are thin wrappers over the functions of this package, so the handlers can depend on the
+// repositories while the rest of the package keeps using the functions directly
+type mongoUsersRepository struct {
+	trainer configuration.TTrainerSettings
+}
+type mongoAccountsRepository struct{}
+type mongoMfaRepository struct{}
+type mongoModerationRepository struct{}
+type mongoFriendsRepository struct{}
+type mongoTradesRepository struct{}
+type mongoGiftsRepository struct{}
+type mongoLoomiesRepository struct {
+	game    configuration.TGameSettings
+	balance *configuration.LiveBalance
+}
+type mongoGymsRepository struct{}
+type mongoItemsRepository struct{}
+type mongoContentRepository struct{}
+type mongoZonesRepository struct {
+	game configuration.TGameSettings
+}
+type mongoChallengesRepository struct{}
+type mongoQuestsRepository struct{}
+type mongoAchievementsRepository struct{}
+type mongoGameSettingsRepository struct {
+	balance *configuration.LiveBalance
+}
+type mongoJobsRepository struct{}
+type mongoAuditRepository struct{}
+
+// NewMongoRepositories Returns the repositories backed by the mongo collections. The game settings are read from
+// the configuration and the live balance, the published versions are applied to the live balance
+func NewMongoRepositories(config *configuration.Config, balance *configuration.LiveBalance) repositories.Repositories {
+	return repositories.Repositories{
+		Users:        mongoUsersRepository{trainer: config.Trainer},
+		Accounts:     mongoAccountsRepository{},
+		Mfa:          mongoMfaRepository{},
+		Moderation:   mongoModerationRepository{},
+		Friends:      mongoFriendsRepository{},
+		Trades:       mongoTradesRepository{},
+		Gifts:        mongoGiftsRepository{},
+		Loomies:      mongoLoomiesRepository{game: config.Game, balance: balance},
+		Gyms:         mongoGymsRepository{},
+		Items:        mongoItemsRepository{},
+		Content:      mongoContentRepository{},
+		Zones:        mongoZonesRepository{game: config.Game},
+		Challenges:   mongoChallengesRepository{},
+		Quests:       mongoQuestsRepository{},
+		Achievements: mongoAchievementsRepository{},
+		GameSettings: mongoGameSettingsRepository{balance: balance},
+		Jobs:         mongoJobsRepository{},
+		Audit:        mongoAuditRepository{},
+	}
+}
+
+// ## Users
+
+func (mongoUsersRepository) GetUserById(id string) (interfaces.User, error) {
+	return GetUserById(id)
+}
+
+func (mongoUsersRepository) GetUserByEmail(email string) (interfaces.User, error) {
+	return GetUserByEmail(email)
+}
+
+func (mongoUsersRepository) GetUserByUsername(username string) (interfaces.User, error) {
+	return GetUserByUsername(username)
+}
+
+func (mongoUsersRepository) GetUsersByIds(ids []primitive.ObjectID) ([]interfaces.User, error) {
+	return GetUsersByIds(ids)
+}
+
+func (mongoUsersRepository) InsertUser(ctx context.Context, user interfaces.User) error {
+	return InsertUser(ctx, user)
+}
+
+func (mongoUsersRepository) UpdateUserGenerationTimes(userId string, lastGenerated int64, newTimeout int64) error {
+	return UpdateUserGenerationTimes(userId, lastGenerated, newTimeout)
+}
+
+func (mongoUsersRepository) ReplaceLoomieTeam(ctx context.Context, userId primitive.ObjectID, loomiesIds []primitive.ObjectID) error {
+	return ReplaceLoomieTeam(ctx, userId, loomiesIds)
+}
+
+func (mongoUsersRepository) RemoveFromLoomieTeam(ctx context.Context, userId primitive.ObjectID, loomiesIds []primitive.ObjectID) error {
+	return RemoveFromLoomieTeam(ctx, userId, loomiesIds)
+}
+
+func (mongoUsersRepository) UpdateUserRoles(ctx context.Context, userId primitive.ObjectID, roles []string) error {
+	return UpdateUserRoles(ctx, userId, roles)
+}
+
+func (mongoUsersRepository) UpdateUserProfile(ctx context.Context, user interfaces.User, profile interfaces.UserProfile) error {
+	return UpdateUserProfile(ctx, user, profile)
+}
+
+func (mongoUsersRepository) UpdateUserPresence(userId primitive.ObjectID, zoneCoordinates string) error {
+	return UpdateUserPresence(userId, zoneCoordinates)
+}
+
+func (repository mongoUsersRepository) AddTrainerExperience(ctx context.Context, userId primitive.ObjectID, amount float64) (interfaces.TrainerProgressRes, error) {
+	return AddTrainerExperience(ctx, userId, amount, repository.trainer)
+}
+
+func (mongoUsersRepository) DeleteUserAccount(ctx context.Context, user interfaces.User) error {
+	return DeleteUserAccount(ctx, user)
+}
+
+// ## Accounts
+
+func (mongoAccountsRepository) UpdateAccountVerificationCode(email string, validationCode string) error {
+	return UpdateAccountVerificationCode(email, validationCode)
+}
+
+func (mongoAccountsRepository) CompareAccountVerificationCode(email string, code string) bool {
+	return CompareAccountVerificationCode(email, code)
+}
+
+func (mongoAccountsRepository) UpdatePasswordResetCode(email string, resetPassCode string) error {
+	return UpdatePasswordResetCode(email, resetPassCode)
+}
+
+func (mongoAccountsRepository) ComparePasswordResetCode(email string, code string) bool {
+	return ComparePasswordResetCode(email, code)
+}
+
+func (mongoAccountsRepository) UpdatePassword(ctx context.Context, email string, password string) error {
+	return UpdatePasword(ctx, email, password)
+}
+
+func (mongoAccountsRepository) GetUserByIdentity(provider string, subject string) (interfaces.User, error) {
+	return GetUserByIdentity(provider, subject)
+}
+
+func (mongoAccountsRepository) LinkUserIdentity(ctx context.Context, user interfaces.User, identity interfaces.UserIdentity) error {
+	return LinkUserIdentity(ctx, user, identity)
+}
+
+func (mongoAccountsRepository) InsertOIDCState(state interfaces.OIDCState) error {
+	return InsertOIDCState(state)
+}
+
+func (mongoAccountsRepository) PopOIDCState(provider string, state string) (interfaces.OIDCState, error) {
+	return PopOIDCState(provider, state)
+}
+
+// ## Mfa
+
+func (mongoMfaRepository) SetUserMfaPendingSecret(userId primitive.ObjectID, secret string) error {
+	return SetUserMfaPendingSecret(userId, secret)
+}
+
+func (mongoMfaRepository) EnableUserMfa(ctx context.Context, userId primitive.ObjectID, secret string, recoveryCodes []string, usedStep int64) error {
+	return EnableUserMfa(ctx, userId, secret, recoveryCodes, usedStep)
+}
+
+func (mongoMfaRepository) DisableUserMfa(ctx context.Context, userId primitive.ObjectID) error {
+	return DisableUserMfa(ctx, userId)
+}
+
+func (mongoMfaRepository) UseUserMfaStep(userId primitive.ObjectID, step int64) (bool, error) {
+	return UseUserMfaStep(userId, step)
+}
+
+func (mongoMfaRepository) UseUserMfaRecoveryCode(ctx context.Context, userId primitive.ObjectID, codeHash string) (bool, error) {
+	return UseUserMfaRecoveryCode(ctx, userId, codeHash)
+}
+
+func (mongoMfaRepository) StartUserMfaChallenge(userId primitive.ObjectID, challengeId string) error {
+	return StartUserMfaChallenge(userId, challengeId)
+}
+
+func (mongoMfaRepository) EndUserMfaChallenge(userId primitive.ObjectID, challengeId string) (bool, error) {
+	return EndUserMfaChallenge(userId, challengeId)
+}
+
+func (mongoMfaRepository) RecordUserMfaChallengeFailure(ctx context.Context, userId primitive.ObjectID, challengeId string, maxAttempts int) (bool, error) {
+	return RecordUserMfaChallengeFailure(ctx, userId, challengeId, maxAttempts)
+}
+
+func (mongoMfaRepository) RecordUserMfaPendingFailure(ctx context.Context, userId primitive.ObjectID, secret string, maxAttempts int) (bool, error) {
+	return RecordUserMfaPendingFailure(ctx, userId, secret, maxAttempts)
+}
+
+func (mongoMfaRepository) RecordUserMfaActionFailure(ctx context.Context, userId primitive.ObjectID, maxAttempts int, lockUntil int64) (bool, error) {
+	return RecordUserMfaActionFailure(ctx, userId, maxAttempts, lockUntil)
+}
+
+// ## Moderation
+
+func (mongoModerationRepository) GetUserBan(userId string) (*interfaces.UserSanction, error) {
+	return GetUserBan(userId)
+}
+
+func (mongoModerationRepository) SetUserSanction(ctx context.Context, userId primitive.ObjectID, field string, sanction interfaces.UserSanction) error {
+	return SetUserSanction(ctx, userId, field, sanction)
+}
+
+func (mongoModerationRepository) RemoveUserSanction(ctx context.Context, userId primitive.ObjectID, field string) error {
+	return RemoveUserSanction(ctx, userId, field)
+}
+
+func (mongoModerationRepository) SearchUsers(text string, limit int64) ([]interfaces.User, error) {
+	return SearchUsers(text, limit)
+}
+
+func (mongoModerationRepository) ReleaseUserBusyLoomies(ctx context.Context, userId primitive.ObjectID, loomiesIds []primitive.ObjectID) (int64, error) {
+	return ReleaseUserBusyLoomies(ctx, userId, loomiesIds)
+}
+
+// ## Friends
+
+func (mongoFriendsRepository) GetFriendshipBetween(firstUserId primitive.ObjectID, secondUserId primitive.ObjectID) (interfaces.Friendship, error) {
+	return GetFriendshipBetween(firstUserId, secondUserId)
+}
+
+func (mongoFriendsRepository) GetFriendshipById(userId primitive.ObjectID, friendshipId primitive.ObjectID) (interfaces.Friendship, error) {
+	return GetFriendshipById(userId, friendshipId)
+}
+
+func (mongoFriendsRepository) AreFriends(firstUserId primitive.ObjectID, secondUserId primitive.ObjectID) (bool, error) {
+	return AreFriends(firstUserId, secondUserId)
+}
+
+func (mongoFriendsRepository) GetUserFriendships(userId primitive.ObjectID, status string) ([]interfaces.Friendship, error) {
+	return GetUserFriendships(userId, status)
+}
+
+func (mongoFriendsRepository) CreateFriendRequest(ctx context.Context, requesterId primitive.ObjectID, recipientId primitive.ObjectID) (interfaces.Friendship, error) {
+	return CreateFriendRequest(ctx, requesterId, recipientId)
+}
+
+func (mongoFriendsRepository) AcceptFriendRequest(ctx context.Context, friendship interfaces.Friendship) (bool, error) {
+	return AcceptFriendRequest(ctx, friendship)
+}
+
+func (mongoFriendsRepository) DeleteFriendship(ctx context.Context, userId primitive.ObjectID, friendship interfaces.Friendship, action string) error {
+	return DeleteFriendship(ctx, userId, friendship, action)
+}
+
+func (mongoFriendsRepository) BlockUser(ctx context.Context, user interfaces.User, blockedId primitive.ObjectID) error {
+	return BlockUser(ctx, user, blockedId)
+}
+
+func (mongoFriendsRepository) UnblockUser(ctx context.Context, user interfaces.User, blockedId primitive.ObjectID) error {
+	return UnblockUser(ctx, user, blockedId)
+}
+
+// ## Trades
+
+func (mongoTradesRepository) GetTradeById(userId primitive.ObjectID, tradeId primitive.ObjectID) (interfaces.Trade, error) {
+	return GetTradeById(userId, tradeId)
+}
+
+func (mongoTradesRepository) GetActiveTrades(userId primitive.ObjectID) ([]interfaces.Trade, error) {
+	return GetActiveTrades(userId)
+}
+
+func (mongoTradesRepository) GetTradesHistory(userId primitive.ObjectID) ([]interfaces.Trade, error) {
+	return GetTradesHistory(userId)
+}
+
+func (mongoTradesRepository) CreateTrade(ctx context.Context, trade interfaces.Trade) (interfaces.Trade, error) {
+	return CreateTrade(ctx, trade)
+}
+
+func (mongoTradesRepository) CounterTrade(ctx context.Context, trade interfaces.Trade, userId primitive.ObjectID, proposerOffer interfaces.TradeOffer, recipientOffer interfaces.TradeOffer, expiresAt int64) (bool, error) {
+	return CounterTrade(ctx, trade, userId, proposerOffer, recipientOffer, expiresAt)
+}
+
+func (mongoTradesRepository) AcceptTrade(ctx context.Context, trade interfaces.Trade, userId primitive.ObjectID) (bool, error) {
+	return AcceptTrade(ctx, trade, userId)
+}
+
+func (mongoTradesRepository) ConfirmTrade(ctx context.Context, trade interfaces.Trade, confirmation interfaces.TradeConfirmation) (bool, error) {
+	return ConfirmTrade(ctx, trade, confirmation)
+}
+
+func (mongoTradesRepository) CompleteTrade(ctx context.Context, trade interfaces.Trade, userId primitive.ObjectID) (interfaces.Trade, error) {
+	return CompleteTrade(ctx, trade, userId)
+}
+
+func (mongoTradesRepository) CloseTrade(ctx context.Context, trade interfaces.Trade, userId primitive.ObjectID, status string) (bool, error) {
+	return CloseTrade(ctx, trade, userId, status)
+}
+
+// ## Gifts
+
+func (mongoGiftsRepository) GetReceivedGifts(userId primitive.ObjectID, opened bool) ([]interfaces.Gift, error) {
+	return GetReceivedGifts(userId, opened)
+}
+
+func (mongoGiftsRepository) GetReceivedGiftById(userId primitive.ObjectID, giftId primitive.ObjectID) (interfaces.Gift, error) {
+	return GetReceivedGiftById(userId, giftId)
+}
+
+func (mongoGiftsRepository) GetUserGifts(userId primitive.ObjectID) ([]interfaces.Gift, error) {
+	return GetUserGifts(userId)
+}
+
+func (mongoGiftsRepository) NewGiftRewards(minRewards int, maxRewards int) ([]interfaces.GymRewardItem, error) {
+	return NewGiftRewards(minRewards, maxRewards)
+}
+
+func (mongoGiftsRepository) SendGift(ctx context.Context, senderId primitive.ObjectID, recipientId primitive.ObjectID, rewards []interfaces.GymRewardItem, giftsPerDay int) (interfaces.Gift, error) {
+	return SendGift(ctx, senderId, recipientId, rewards, giftsPerDay)
+}
+
+func (mongoGiftsRepository) OpenGift(ctx context.Context, gift interfaces.Gift) (interfaces.Gift, error) {
+	return OpenGift(ctx, gift)
+}
+
+// ## Loomies
+
+func (mongoLoomiesRepository) GetLoomiesByIds(ids []primitive.ObjectID, userId primitive.ObjectID) ([]interfaces.UserLoomiesRes, error) {
+	return GetLoomiesByIds(ids, userId)
+}
+
+func (mongoLoomiesRepository) GetWildLoomieById(id string) (interfaces.WildLoomie, error) {
+	return GetWildLoomieById(id)
+}
+
+func (mongoLoomiesRepository) InsertInCaughtLoomies(ctx context.Context, loomie interfaces.CaughtLoomie) (primitive.ObjectID, error) {
+	return InsertInCaughtLoomies(ctx, loomie)
+}
+
+func (mongoLoomiesRepository) CaptureWildLoomie(ctx context.Context, user interfaces.User, loomie interfaces.WildLoomie, loomballId primitive.ObjectID, wasCaptured bool) (primitive.ObjectID, error) {
+	return CaptureWildLoomie(ctx, user, loomie, loomballId, wasCaptured)
+}
+
+func (mongoLoomiesRepository) FuseLoomies(ctx context.Context, userId primitive.ObjectID, loomieToUpdate interfaces.UserLoomiesRes, loomieToDelete interfaces.UserLoomiesRes) error {
+	return FuseLoomies(ctx, userId, loomieToUpdate, loomieToDelete)
+}
+
+func (mongoLoomiesRepository) IncrementLoomieLevel(ctx context.Context, userId primitive.ObjectID, loomieId primitive.ObjectID, amount uint) error {
+	return IncrementLoomieLevel(ctx, userId, loomieId, amount)
+}
+
+func (mongoLoomiesRepository) UpdateLoomiesExpAndLvl(ctx context.Context, userId primitive.ObjectID, loomie *interfaces.CombatLoomie) error {
+	return UpdateLoomiesExpAndLvl(ctx, userId, loomie)
+}
+
+func (mongoLoomiesRepository) UpdateLoomiesBusyState(ctx context.Context, loomiesIds []primitive.ObjectID, busy bool) error {
+	return UpdateLoomiesBusyState(ctx, loomiesIds, busy)
+}
+
+func (mongoLoomiesRepository) GetBaseLoomies() ([]interfaces.BaseLoomiesWithPopulatedRarity, error) {
+	return GetBaseLoomies()
+}
+
+func (repository mongoLoomiesRepository) GetNearWildLoomies(coordinates interfaces.Coordinates, userId primitive.ObjectID) ([]interfaces.PopulatedWildLoomie, error) {
+	return GetNearWildLoomies(coordinates, userId, repository.game.VisibilityRadius, repository.balance.Get().WildLoomiesTTL)
+}
+
+func (repository mongoLoomiesRepository) InsertWildLoomie(region interfaces.Region, loomie interfaces.WildLoomie) (interfaces.WildLoomie, bool) {
+	return InsertWildLoomie(region, loomie, repository.game.MaxLoomiesPerZone)
+}
+
+func (repository mongoLoomiesRepository) RemoveNearExpiredLoomies(coordinates interfaces.Coordinates) error {
+	return RemoveNearExpiredLoomies(coordinates, repository.game.VisibilityRadius, repository.balance.Get().WildLoomiesTTL)
+}
+
+// ## Gyms
+
+func (mongoGymsRepository) GetGymFromID(id string) (interfaces.Gym, error) {
+	return GetGymFromID(id)
+}
+
+func (mongoGymsRepository) GetPopulatedGymFromId(gymId primitive.ObjectID, userId primitive.ObjectID) (interfaces.PopulatedGym, error) {
+	return GetPopulatedGymFromId(gymId, userId)
+}
+
+func (mongoGymsRepository) GetGymsByOwner(ownerId primitive.ObjectID) ([]interfaces.Gym, error) {
+	return GetGymsByOwner(ownerId)
+}
+
+func (mongoGymsRepository) CountGymsByOwner(ownerId primitive.ObjectID) (int64, error) {
+	return CountGymsByOwner(ownerId)
+}
+
+func (mongoGymsRepository) UpdateGymProtectors(ctx context.Context, gymId primitive.ObjectID, protectorsIds []primitive.ObjectID) error {
+	return UpdateGymProtectors(ctx, gymId, protectorsIds)
+}
+
+func (mongoGymsRepository) ClaimGymReward(ctx context.Context, gym interfaces.Gym, userId primitive.ObjectID, rewards []interfaces.GymRewardItem) error {
+	return ClaimGymReward(ctx, gym, userId, rewards)
+}
+
+func (mongoGymsRepository) TakeOverGym(ctx context.Context, gymId primitive.ObjectID, newOwner primitive.ObjectID, newProtectors []primitive.ObjectID, oldProtectors []primitive.ObjectID, hadOwner bool) error {
+	return TakeOverGym(ctx, gymId, newOwner, newProtectors, oldProtectors, hadOwner)
+}
+
+// ## Items
+
+func (mongoItemsRepository) GetItemsFromIds(ids []primitive.ObjectID) ([]interfaces.Item, error) {
+	return GetItemsFromIds(ids)
+}
+
+func (mongoItemsRepository) GetLoomballsFromIds(ids []primitive.ObjectID) ([]interfaces.Loomball, error) {
+	return GetLoomballsFromIds(ids)
+}
+
+func (mongoItemsRepository) GetInventory(inventory []interfaces.InventoryItem) ([]interfaces.UserItemsRes, []interfaces.UserLoomballsRes, error) {
+	return GetItemById(inventory)
+}
+
+func (mongoItemsRepository) GetItemFromUserInventory(userId primitive.ObjectID, itemId primitive.ObjectID, ignoreCombatItems bool) (interfaces.PopulatedInventoryItem, error) {
+	return GetItemFromUserInventory(userId, itemId, ignoreCombatItems)
+}
+
+func (mongoItemsRepository) AddItemToUserInventory(ctx context.Context, userId primitive.ObjectID, item interfaces.GymRewardItem) error {
+	return AddItemToUserInventory(ctx, userId, item)
+}
+
+func (mongoItemsRepository) DecrementItemFromUserInventory(ctx context.Context, userId primitive.ObjectID, itemId primitive.ObjectID, quantity int) error {
+	return DecrementItemFromUserInventory(ctx, userId, itemId, quantity)
+}
+
+func (mongoItemsRepository) IncrementItemFromUserInventory(ctx context.Context, userId primitive.ObjectID, itemId primitive.ObjectID, quantity int) error {
+	return IncrementItemFromUserInventory(ctx, userId, itemId, quantity)
+}
+
+func (mongoItemsRepository) GetRewardCollection(rewardId primitive.ObjectID) (string, error) {
+	return GetRewardCollection(rewardId)
+}
+
+// ## Content
+
+// contentCollection "private" function to get the collection of the given game content
+func contentCollection(name string) *mongo.Collection {
+	switch name {
+	case repositories.BaseLoomiesContent:
+		return BaseLoomiesCollection
+	case repositories.ItemsContent:
+		return ItemsCollection
+	case repositories.LoomballsContent:
+		return LoomballsCollection
+	case repositories.LoomieTypesContent:
+		return LoomieTypesCollection
+	case repositories.LoomieRaritiesContent:
+		return LoomieRaritiesCollection
+	}
+
+	panic("unknown content collection: " + name)
+}
+
+func (mongoContentRepository) GetContentDocuments(collection string, sortBy string, results interface{}) error {
+	return GetContentDocuments(contentCollection(collection), sortBy, results)
+}
+
+// The changes to the content remove the memoized types and rarities, so the next reads see the new documents
+
+func (mongoContentRepository) InsertContentDocument(ctx context.Context, collection string, document interface{}) (primitive.ObjectID, error) {
+	defer InvalidateMemoizedContent()
+	return InsertContentDocument(ctx, contentCollection(collection), document)
+}
+
+func (mongoContentRepository) ReplaceContentDocument(ctx context.Context, collection string, id primitive.ObjectID, document interface{}) error {
+	defer InvalidateMemoizedContent()
+	return ReplaceContentDocument(ctx, contentCollection(collection), id, document)
+}
+
+func (mongoContentRepository) DeleteContentDocument(ctx context.Context, collection string, id primitive.ObjectID) error {
+	defer InvalidateMemoizedContent()
+	return DeleteContentDocument(ctx, contentCollection(collection), id)
+}
+
+func (mongoContentRepository) IsContentFieldInUse(collection string, field string, value interface{}, exceptId primitive.ObjectID) (bool, error) {
+	return IsContentFieldInUse(contentCollection(collection), field, value, exceptId)
+}
+
+func (mongoContentRepository) IsRewardSerialInUse(serial int, exceptId primitive.ObjectID) (bool, error) {
+	return IsRewardSerialInUse(serial, exceptId)
+}
+
+func (mongoContentRepository) IsRewardInUse(rewardId primitive.ObjectID) (bool, error) {
+	return IsRewardInUse(rewardId)
+}
+
+func (mongoContentRepository) GetLoomieTypesByNames(names []string) ([]interfaces.LoomieType, error) {
+	return GetLoomieTypesByNames(names)
+}
+
+func (mongoContentRepository) GetLoomieTypeDetailsByName(typeName string) (interfaces.PopulatedLoomieType, error) {
+	return GetLoomieTypeDetailsByName(typeName)
+}
+
+func (mongoContentRepository) GetLoomieRarityByName(name string) (interfaces.LoomieRarity, error) {
+	return GetLoomieRarityByName(name)
+}
+
+func (mongoContentRepository) IsLoomieTypeInUse(typeId primitive.ObjectID) (bool, error) {
+	return IsLoomieTypeInUse(typeId)
+}
+
+func (mongoContentRepository) IsLoomieRarityInUse(rarityId primitive.ObjectID) (bool, error) {
+	return IsLoomieRarityInUse(rarityId)
+}
+
+func (mongoContentRepository) RemoveLoomieTypeReferences(ctx context.Context, typeId primitive.ObjectID) error {
+	defer InvalidateMemoizedContent()
+	return RemoveLoomieTypeReferences(ctx, typeId)
+}
+
+// ## Zones
+
+func (mongoZonesRepository) GetRegionFromCoordinates(coordinates interfaces.Coordinates) (interfaces.Region, error) {
+	return GetRegionFromCoordinates(coordinates)
+}
+
+func (mongoZonesRepository) GetZoneFromCoordinates(regionId primitive.ObjectID, coordX int, coordY int) (interfaces.Zone, error) {
+	return GetZoneFromCoordinates(regionId, coordX, coordY)
+}
+
+func (repository mongoZonesRepository) GetNearGyms(latitude float64, longitude float64) ([]interfaces.NearGymsRes, error) {
+	return GetNearGyms(latitude, longitude, repository.game.VisibilityRadius)
+}
+
+func (mongoZonesRepository) GetUserLocation(user interfaces.User) *time.Location {
+	return GetUserLocation(user)
+}
+
+func (mongoZonesRepository) GetRegions() ([]interfaces.Region, error) {
+	return GetRegions()
+}
+
+func (mongoZonesRepository) CreateRegion(ctx context.Context, region interfaces.Region) (interfaces.Region, error) {
+	return CreateRegion(ctx, region)
+}
+
+// ## Challenges
+
+func (mongoChallengesRepository) GetActiveCombatByUserId(userId primitive.ObjectID) (interfaces.GymChallengesRegister, error) {
+	return GetActiveCombatByUseId(userId)
+}
+
+func (mongoChallengesRepository) GetLastGymChallenge(gymId primitive.ObjectID, playerId primitive.ObjectID) (interfaces.GymChallengesRegister, error) {
+	return GetLastGymChallengeTimestamp(gymId, playerId)
+}
+
+func (mongoChallengesRepository) UpdateLastGymChallengeTimestamp(gymId primitive.ObjectID, playerId primitive.ObjectID) error {
+	return UpdateLastGymChallengeTimestamp(gymId, playerId)
+}
+
+func (mongoChallengesRepository) FinishGymChallenge(gymId primitive.ObjectID, playerId primitive.ObjectID) error {
+	return FinishGymChallenge(gymId, playerId)
+}
+
+func (mongoChallengesRepository) GetUserCombats(userId primitive.ObjectID) ([]interfaces.GymChallengesRegister, error) {
+	return GetUserCombats(userId)
+}
+
+// ## Quests
+
+func (mongoQuestsRepository) GetUserQuests(ctx context.Context, userId primitive.ObjectID) ([]interfaces.UserQuest, error) {
+	return GetUserQuests(ctx, userId)
+}
+
+func (mongoQuestsRepository) GetUserQuestById(userId primitive.ObjectID, questId primitive.ObjectID) (interfaces.UserQuest, error) {
+	return GetUserQuestById(userId, questId)
+}
+
+func (mongoQuestsRepository) ClaimUserQuest(ctx context.Context, quest interfaces.UserQuest) (bool, error) {
+	return ClaimUserQuest(ctx, quest)
+}
+
+func (mongoQuestsRepository) TrackQuestProgress(ctx context.Context, userId primitive.ObjectID, event string, value string, loomieTypes []primitive.ObjectID) error {
+	return TrackQuestProgress(ctx, userId, event, value, loomieTypes)
+}
+
+// ## Achievements
+
+func (mongoAchievementsRepository) GetAchievements() ([]interfaces.Achievement, error) {
+	return GetAchievements()
+}
+
+func (mongoAchievementsRepository) IncrementAchievementCounter(ctx context.Context, userId primitive.ObjectID, counter string, amount int) ([]interfaces.Achievement, error) {
+	return IncrementAchievementCounter(ctx, userId, counter, amount)
+}
+
+func (mongoAchievementsRepository) SetAchievementCounterMax(ctx context.Context, userId primitive.ObjectID, counter string, value int) ([]interfaces.Achievement, error) {
+	return SetAchievementCounterMax(ctx, userId, counter, value)
+}
+
+// ## Game settings
+
+func (mongoGameSettingsRepository) GetGameSettingsVersions(limit int64) ([]interfaces.GameSettingsVersion, error) {
+	return GetGameSettingsVersions(limit)
+}
+
+func (repository mongoGameSettingsRepository) PublishGameSettings(ctx context.Context, settings configuration.TGameBalance, comment string, createdBy primitive.ObjectID, rolledBackTo int) (interfaces.GameSettingsVersion, error) {
+	return PublishGameSettings(ctx, repository.balance, settings, comment, createdBy, rolledBackTo)
+}
+
+func (repository mongoGameSettingsRepository) RollbackGameSettings(ctx context.Context, version int, comment string, createdBy primitive.ObjectID) (interfaces.GameSettingsVersion, error) {
+	return RollbackGameSettings(ctx, repository.balance, version, comment, createdBy)
+}
+
+// ## Jobs
+
+func (mongoJobsRepository) GetJobRuns(job string, limit int64) ([]interfaces.JobRun, error) {
+	return GetJobRuns(job, limit)
+}
+
+// ## Audit
+
+func (mongoAuditRepository) FindEvents(filter interfaces.AuditEventsFilter) ([]interfaces.AuditEvent, error) {
+	return audit.FindEvents(filter)
+}
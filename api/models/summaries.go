@@ -0,0 +1,99 @@
+package models
+
+import (
+	"context"
+
+	"github.com/PedroChaparro/loomies-backend/audit"
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// GetWeeklySummaries Returns the activity since the given unix time of the users that caught loomies or conquered,
+// lost or own gyms. The captures and the gyms owner changes are counted from the audit log
+func GetWeeklySummaries(ctx context.Context, since int64) ([]interfaces.UserWeeklySummary, error) {
+	summaries := map[primitive.ObjectID]*interfaces.UserWeeklySummary{}
+	getSummary := func(userId primitive.ObjectID) *interfaces.UserWeeklySummary {
+		if _, ok := summaries[userId]; !ok {
+			summaries[userId] = &interfaces.UserWeeklySummary{UserId: userId}
+		}
+
+		return summaries[userId]
+	}
+
+	ownerChanged := func(user string) bson.D {
+		return bson.D{
+			{Key: "action", Value: "gym.update_owner"},
+			{Key: "created_at", Value: bson.D{{Key: "$gte", Value: since}}},
+			{Key: "$expr", Value: bson.D{{Key: "$ne", Value: bson.A{"$user_id", "$after.owner"}}}},
+			{Key: user, Value: bson.D{{Key: "$exists", Value: true}}},
+		}
+	}
+
+	counters := []struct {
+		collection *mongo.Collection
+		match      bson.D
+		groupBy    string
+		add        func(summary *interfaces.UserWeeklySummary, count int)
+	}{
+		{
+			audit.EventsCollection,
+			bson.D{{Key: "action", Value: "wild_loomie.capture"}, {Key: "created_at", Value: bson.D{{Key: "$gte", Value: since}}}},
+			"$user_id",
+			func(summary *interfaces.UserWeeklySummary, count int) { summary.CaughtLoomies = count },
+		},
+		{
+			// The previous owner is the user of the event and the new one is in the after snapshot
+			audit.EventsCollection,
+			ownerChanged("user_id"),
+			"$user_id",
+			func(summary *interfaces.UserWeeklySummary, count int) { summary.LostGyms = count },
+		},
+		{
+			audit.EventsCollection,
+			ownerChanged("after.owner"),
+			"$after.owner",
+			func(summary *interfaces.UserWeeklySummary, count int) { summary.ConqueredGyms = count },
+		},
+		{
+			GymsCollection,
+			bson.D{{Key: "owner", Value: bson.D{{Key: "$exists", Value: true}}}},
+			"$owner",
+			func(summary *interfaces.UserWeeklySummary, count int) { summary.OwnedGyms = count },
+		},
+	}
+
+	for _, counter := range counters {
+		cursor, err := counter.collection.Aggregate(ctx, mongo.Pipeline{
+			{{Key: "$match", Value: counter.match}},
+			{{Key: "$group", Value: bson.D{{Key: "_id", Value: counter.groupBy}, {Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}}}}},
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		var groups []struct {
+			UserId primitive.ObjectID `bson:"_id"`
+			Count  int                `bson:"count"`
+		}
+
+		if err := cursor.All(ctx, &groups); err != nil {
+			return nil, err
+		}
+
+		for _, group := range groups {
+			if !group.UserId.IsZero() {
+				counter.add(getSummary(group.UserId), group.Count)
+			}
+		}
+	}
+
+	result := make([]interfaces.UserWeeklySummary, 0, len(summaries))
+	for _, summary := range summaries {
+		result = append(result, *summary)
+	}
+
+	return result, nil
+}
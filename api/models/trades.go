@@ -0,0 +1,396 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/audit"
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Status of the trades
+const (
+	TradeProposed  = repositories.TradeProposed
+	TradeAccepted  = repositories.TradeAccepted
+	TradeCompleted = repositories.TradeCompleted
+	TradeDeclined  = repositories.TradeDeclined
+	TradeCancelled = repositories.TradeCancelled
+)
+
+// Errors returned when the offers changed since the trade was accepted
+var (
+	ErrTradeLoomieUnavailable = repositories.ErrTradeLoomieUnavailable
+	ErrTradeItemsUnavailable  = repositories.ErrTradeItemsUnavailable
+	ErrTradeLastTeamLoomie    = repositories.ErrTradeLastTeamLoomie
+	ErrTradeNotAccepted       = repositories.ErrTradeNotAccepted
+)
+
+// containsObjectId "private" function to check if the id is in the array
+func containsObjectId(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, current := range ids {
+		if current == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// tradeParticipantFilter "private" function to get the filter of the trades where the user participates
+func tradeParticipantFilter(userId primitive.ObjectID) bson.E {
+	return bson.E{Key: "$or", Value: bson.A{
+		bson.D{{Key: "proposer_id", Value: userId}},
+		bson.D{{Key: "recipient_id", Value: userId}},
+	}}
+}
+
+// findTrades "private" function to get the trades matching the filter sorted from the newest to the oldest
+func findTrades(filter bson.D) ([]interfaces.Trade, error) {
+	trades := []interfaces.Trade{}
+
+	cursor, err := TradesCollection.Find(context.TODO(), filter, options.Find().SetSort(bson.D{{Key: "updated_at", Value: -1}}))
+	if err != nil {
+		return trades, err
+	}
+
+	err = cursor.All(context.TODO(), &trades)
+	return trades, err
+}
+
+// GetTradeById Returns the trade with the given id if the user participates in it
+func GetTradeById(userId primitive.ObjectID, tradeId primitive.ObjectID) (interfaces.Trade, error) {
+	var trade interfaces.Trade
+	err := TradesCollection.FindOne(context.TODO(), bson.D{{Key: "_id", Value: tradeId}, tradeParticipantFilter(userId)}).Decode(&trade)
+	return trade, err
+}
+
+// GetActiveTrades Returns the proposed and accepted trades of the user that haven't expired
+func GetActiveTrades(userId primitive.ObjectID) ([]interfaces.Trade, error) {
+	return findTrades(bson.D{
+		tradeParticipantFilter(userId),
+		{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{TradeProposed, TradeAccepted}}}},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now().Unix()}}},
+	})
+}
+
+// GetTradesHistory Returns the finished (completed, declined, cancelled or expired) trades of the user
+func GetTradesHistory(userId primitive.ObjectID) ([]interfaces.Trade, error) {
+	return findTrades(bson.D{
+		tradeParticipantFilter(userId),
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{TradeCompleted, TradeDeclined, TradeCancelled}}}}},
+			bson.D{{Key: "expires_at", Value: bson.D{{Key: "$lte", Value: time.Now().Unix()}}}},
+		}},
+	})
+}
+
+// CreateTrade Stores the trade proposed by the user
+func CreateTrade(ctx context.Context, trade interfaces.Trade) (interfaces.Trade, error) {
+	result, err := TradesCollection.InsertOne(ctx, trade)
+	if err != nil {
+		return trade, err
+	}
+
+	trade.Id = result.InsertedID.(primitive.ObjectID)
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		UserId:   trade.ProposerId,
+		Action:   "trade.propose",
+		Entity:   TradesCollection.Name(),
+		EntityId: trade.Id,
+		After:    trade,
+	})
+
+	return trade, nil
+}
+
+// updateTrade "private" function to update the trade if it's still in the given status and (if not nil) pending for
+// the given user. Returns false if the trade changed concurrently
+func updateTrade(ctx context.Context, trade interfaces.Trade, status string, pendingUserId *primitive.ObjectID, update bson.D, action string, userId primitive.ObjectID) (bool, error) {
+	filter := bson.D{
+		{Key: "_id", Value: trade.Id},
+		{Key: "status", Value: status},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now().Unix()}}},
+	}
+
+	if pendingUserId != nil {
+		filter = append(filter, bson.E{Key: "pending_user_id", Value: *pendingUserId})
+	}
+
+	var after interfaces.Trade
+	err := TradesCollection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&after)
+
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		UserId:   userId,
+		Action:   action,
+		Entity:   TradesCollection.Name(),
+		EntityId: trade.Id,
+		Before:   trade,
+		After:    after,
+	})
+
+	return true, nil
+}
+
+// CounterTrade Replaces the offers of the proposed trade and gives the turn to the other player
+func CounterTrade(ctx context.Context, trade interfaces.Trade, userId primitive.ObjectID, proposerOffer interfaces.TradeOffer, recipientOffer interfaces.TradeOffer, expiresAt int64) (bool, error) {
+	otherUserId := trade.ProposerId
+	if userId == trade.ProposerId {
+		otherUserId = trade.RecipientId
+	}
+
+	return updateTrade(ctx, trade, TradeProposed, &userId, bson.D{{Key: "$set", Value: bson.D{
+		{Key: "proposer_offer", Value: proposerOffer},
+		{Key: "recipient_offer", Value: recipientOffer},
+		{Key: "pending_user_id", Value: otherUserId},
+		{Key: "updated_at", Value: time.Now().Unix()},
+		{Key: "expires_at", Value: expiresAt},
+	}}}, "trade.counter", userId)
+}
+
+// AcceptTrade Accepts the current offers, then both players have to confirm the trade
+func AcceptTrade(ctx context.Context, trade interfaces.Trade, userId primitive.ObjectID) (bool, error) {
+	return updateTrade(ctx, trade, TradeProposed, &userId, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "status", Value: TradeAccepted},
+			{Key: "confirmations", Value: []interfaces.TradeConfirmation{}},
+			{Key: "updated_at", Value: time.Now().Unix()},
+		}},
+		{Key: "$unset", Value: bson.D{{Key: "pending_user_id", Value: ""}}},
+	}, "trade.accept", userId)
+}
+
+// CloseTrade Declines or cancels the proposed or accepted trade
+func CloseTrade(ctx context.Context, trade interfaces.Trade, userId primitive.ObjectID, status string) (bool, error) {
+	action := "trade.cancel"
+	if status == TradeDeclined {
+		action = "trade.decline"
+	}
+
+	return updateTrade(ctx, trade, trade.Status, nil, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "status", Value: status},
+			{Key: "updated_at", Value: time.Now().Unix()},
+		}},
+		{Key: "$unset", Value: bson.D{{Key: "pending_user_id", Value: ""}}},
+	}, action, userId)
+}
+
+// ConfirmTrade Stores the confirmation of the player in the accepted trade (Replacing the previous one, if any)
+func ConfirmTrade(ctx context.Context, trade interfaces.Trade, confirmation interfaces.TradeConfirmation) (bool, error) {
+	confirmations := []interfaces.TradeConfirmation{confirmation}
+	for _, previous := range trade.Confirmations {
+		if previous.UserId != confirmation.UserId {
+			confirmations = append(confirmations, previous)
+		}
+	}
+
+	// The updated_at filter avoids losing a confirmation stored concurrently
+	result, err := TradesCollection.UpdateOne(ctx, bson.D{
+		{Key: "_id", Value: trade.Id},
+		{Key: "status", Value: TradeAccepted},
+		{Key: "updated_at", Value: trade.UpdatedAt},
+	}, bson.D{{Key: "$set", Value: bson.D{
+		{Key: "confirmations", Value: confirmations},
+		{Key: "updated_at", Value: confirmation.ConfirmedAt},
+	}}})
+
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount > 0, nil
+}
+
+// CancelTradesBetween Cancels the active trades between two users (Used when one of them blocks the other)
+func CancelTradesBetween(ctx context.Context, firstUserId primitive.ObjectID, secondUserId primitive.ObjectID) error {
+	_, err := TradesCollection.UpdateMany(
+		ctx,
+		bson.D{
+			{Key: "$or", Value: bson.A{
+				bson.D{{Key: "proposer_id", Value: firstUserId}, {Key: "recipient_id", Value: secondUserId}},
+				bson.D{{Key: "proposer_id", Value: secondUserId}, {Key: "recipient_id", Value: firstUserId}},
+			}},
+			{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{TradeProposed, TradeAccepted}}}},
+		},
+		bson.D{
+			{Key: "$set", Value: bson.D{{Key: "status", Value: TradeCancelled}, {Key: "updated_at", Value: time.Now().Unix()}}},
+			{Key: "$unset", Value: bson.D{{Key: "pending_user_id", Value: ""}}},
+		},
+	)
+
+	return err
+}
+
+// transferTradeLoomie "private" function to move the loomie between the users. The loomie is removed from the team
+// of the previous owner, it can't be a gym protector nor the last loomie of the team
+func transferTradeLoomie(ctx context.Context, fromId primitive.ObjectID, toId primitive.ObjectID, loomieId primitive.ObjectID) error {
+	var from interfaces.User
+
+	err := UserCollection.FindOne(ctx, bson.D{{Key: "_id", Value: fromId}}).Decode(&from)
+	if err != nil {
+		return err
+	}
+
+	if !containsObjectId(from.Loomies, loomieId) {
+		return ErrTradeLoomieUnavailable
+	}
+
+	if len(from.LoomieTeam) == 1 && from.LoomieTeam[0] == loomieId {
+		return ErrTradeLastTeamLoomie
+	}
+
+	result, err := CaughtLoomiesCollection.UpdateOne(
+		ctx,
+		bson.D{{Key: "_id", Value: loomieId}, {Key: "owner", Value: fromId}, {Key: "is_busy", Value: false}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "owner", Value: toId}}}},
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrTradeLoomieUnavailable
+	}
+
+	_, err = UserCollection.UpdateOne(
+		ctx,
+		bson.D{{Key: "_id", Value: fromId}},
+		bson.D{{Key: "$pull", Value: bson.D{{Key: "loomies", Value: loomieId}, {Key: "loomie_team", Value: loomieId}}}},
+	)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = UserCollection.UpdateOne(
+		ctx,
+		bson.D{{Key: "_id", Value: toId}},
+		bson.D{{Key: "$push", Value: bson.D{{Key: "loomies", Value: loomieId}}}},
+	)
+
+	return err
+}
+
+// transferTradeItems "private" function to move the offered items between the users inventories
+func transferTradeItems(ctx context.Context, fromId primitive.ObjectID, toId primitive.ObjectID, items []interfaces.InventoryItem) error {
+	for _, item := range items {
+		result, err := UserCollection.UpdateOne(
+			ctx,
+			bson.D{
+				{Key: "_id", Value: fromId},
+				{Key: "items", Value: bson.D{{Key: "$elemMatch", Value: bson.D{
+					{Key: "item_id", Value: item.ItemId},
+					{Key: "item_quantity", Value: bson.D{{Key: "$gte", Value: item.ItemQuantity}}},
+				}}}},
+			},
+			bson.D{{Key: "$inc", Value: bson.D{{Key: "items.$.item_quantity", Value: -item.ItemQuantity}}}},
+		)
+
+		if err != nil {
+			return err
+		}
+
+		if result.MatchedCount == 0 {
+			return ErrTradeItemsUnavailable
+		}
+
+		_, err = UserCollection.UpdateOne(
+			ctx,
+			bson.D{{Key: "_id", Value: fromId}},
+			bson.D{{Key: "$pull", Value: bson.D{{Key: "items", Value: bson.D{{Key: "item_quantity", Value: bson.D{{Key: "$lte", Value: 0}}}}}}}},
+		)
+
+		if err != nil {
+			return err
+		}
+
+		err = AddItemToUserInventory(ctx, toId, interfaces.GymRewardItem{
+			RewardCollection: item.ItemCollection,
+			RewardId:         item.ItemId,
+			RewardQuantity:   item.ItemQuantity,
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CompleteTrade Swaps the loomies and items of the accepted trade in a single transaction, so the trade is
+// either fully applied or not applied at all
+func CompleteTrade(ctx context.Context, trade interfaces.Trade, userId primitive.ObjectID) (interfaces.Trade, error) {
+	err := RunUnitOfWork(ctx, func(uow *UnitOfWork) error {
+		completedAt := time.Now().Unix()
+
+		result, err := TradesCollection.UpdateOne(
+			uow.Context(),
+			bson.D{{Key: "_id", Value: trade.Id}, {Key: "status", Value: TradeAccepted}},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "status", Value: TradeCompleted},
+				{Key: "updated_at", Value: completedAt},
+				{Key: "completed_at", Value: completedAt},
+			}}},
+		)
+
+		if err != nil {
+			return err
+		}
+
+		if result.MatchedCount == 0 {
+			return ErrTradeNotAccepted
+		}
+
+		err = transferTradeLoomie(uow.Context(), trade.ProposerId, trade.RecipientId, trade.ProposerOffer.LoomieId)
+		if err != nil {
+			return err
+		}
+
+		err = transferTradeLoomie(uow.Context(), trade.RecipientId, trade.ProposerId, trade.RecipientOffer.LoomieId)
+		if err != nil {
+			return err
+		}
+
+		err = transferTradeItems(uow.Context(), trade.ProposerId, trade.RecipientId, trade.ProposerOffer.Items)
+		if err != nil {
+			return err
+		}
+
+		err = transferTradeItems(uow.Context(), trade.RecipientId, trade.ProposerId, trade.RecipientOffer.Items)
+		if err != nil {
+			return err
+		}
+
+		trade.Status = TradeCompleted
+		trade.UpdatedAt = completedAt
+		trade.CompletedAt = completedAt
+
+		audit.Record(uow.Context(), interfaces.AuditEvent{
+			UserId:   userId,
+			Action:   "trade.complete",
+			Entity:   TradesCollection.Name(),
+			EntityId: trade.Id,
+			After:    trade,
+		})
+
+		return nil
+	})
+
+	return trade, err
+}
@@ -0,0 +1,152 @@
+package models
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/PedroChaparro/loomies-backend/audit"
+	"github.com/PedroChaparro/loomies-backend/configuration"
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TrainerLevelRewards are the items given to the trainers when they level up (by serial)
+var TrainerLevelRewards = repositories.TrainerLevelRewards
+
+// getRewardIdBySerial "private" function to get the id of an item or loomball from its serial
+func getRewardIdBySerial(rewardCollection string, serial int) (primitive.ObjectID, error) {
+	var document struct {
+		Id primitive.ObjectID `bson:"_id"`
+	}
+
+	collection := ItemsCollection
+	if rewardCollection == "loom_balls" {
+		collection = LoomballsCollection
+	}
+
+	err := collection.FindOne(context.TODO(), bson.D{{Key: "serial", Value: serial}}).Decode(&document)
+	return document.Id, err
+}
+
+// getTrainerLevelRewards "private" function to get the rewards of the levels after fromLevel until toLevel (included)
+func getTrainerLevelRewards(fromLevel int, toLevel int) []interfaces.GymRewardItem {
+	rewards := []interfaces.GymRewardItem{}
+
+	for _, reward := range TrainerLevelRewards {
+		quantity := 0
+
+		for level := fromLevel + 1; level <= toLevel; level++ {
+			if level%reward.EveryLevels == 0 {
+				quantity += reward.RewardQuantity
+			}
+		}
+
+		if quantity == 0 {
+			continue
+		}
+
+		rewardId, err := getRewardIdBySerial(reward.Collection, reward.Serial)
+
+		if err != nil {
+			fmt.Printf("Unable to find the %s reward with serial %d: %s\n", reward.Collection, reward.Serial, err)
+			continue
+		}
+
+		rewards = append(rewards, interfaces.GymRewardItem{
+			RewardCollection: reward.Collection,
+			RewardId:         rewardId,
+			RewardQuantity:   quantity,
+		})
+	}
+
+	return rewards
+}
+
+// AddTrainerExperience Adds experience to the trainer and grants the rewards of the reached levels of the given
+// levels curve
+func AddTrainerExperience(ctx context.Context, userId primitive.ObjectID, amount float64, settings configuration.TTrainerSettings) (interfaces.TrainerProgressRes, error) {
+	var user interfaces.User
+
+	err := UserCollection.FindOneAndUpdate(
+		ctx,
+		bson.D{{Key: "_id", Value: userId}},
+		bson.D{{Key: "$inc", Value: bson.D{{Key: "experience", Value: amount}}}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+
+	if err != nil {
+		return interfaces.TrainerProgressRes{}, err
+	}
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		UserId:   userId,
+		Action:   "user.add_experience",
+		Entity:   "users",
+		EntityId: userId,
+		Before:   bson.M{"experience": user.Experience - amount},
+		After:    bson.M{"experience": user.Experience},
+	})
+
+	currentLevel := user.Level
+	if currentLevel == 0 {
+		currentLevel = 1
+	}
+
+	progress := interfaces.TrainerProgressRes{
+		ExperienceGained: amount,
+		Experience:       user.Experience,
+		Level:            currentLevel,
+		Rewards:          []interfaces.GymRewardItem{},
+	}
+
+	newLevel := utils.GetTrainerLevelFromExperience(user.Experience, settings)
+
+	if newLevel > currentLevel {
+		// Only the request that updates the level grants the rewards (Concurrent requests would see the new level)
+		var levelFilter interface{} = user.Level
+		if user.Level == 0 {
+			levelFilter = bson.D{{Key: "$in", Value: bson.A{0, nil}}}
+		}
+
+		result, err := UserCollection.UpdateOne(
+			ctx,
+			bson.D{{Key: "_id", Value: userId}, {Key: "level", Value: levelFilter}},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "level", Value: newLevel}}}},
+		)
+
+		if err != nil {
+			return progress, err
+		}
+
+		if result.ModifiedCount == 1 {
+			rewards := getTrainerLevelRewards(currentLevel, newLevel)
+			err = AddItemsToUserInventory(ctx, userId, rewards)
+			if err != nil {
+				return progress, err
+			}
+
+			audit.Record(ctx, interfaces.AuditEvent{
+				UserId:   userId,
+				Action:   "user.level_up",
+				Entity:   "users",
+				EntityId: userId,
+				Before:   bson.M{"level": currentLevel},
+				After:    bson.M{"level": newLevel, "rewards": rewards},
+			})
+
+			progress.Level = newLevel
+			progress.LevelUp = true
+			progress.Rewards = rewards
+		}
+	}
+
+	if progress.Level < settings.MaxLevel {
+		progress.NextLevelExperience = utils.GetTrainerRequiredExperience(progress.Level+1, settings)
+	}
+
+	return progress, nil
+}
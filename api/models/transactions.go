@@ -0,0 +1,66 @@
+package models
+
+import (
+	"context"
+
+	"github.com/PedroChaparro/loomies-backend/audit"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// UnitOfWork groups the writes of an operation in a single mongo transaction, so they are committed together or not
+// committed at all. The model functions called with the unit of work context take part in the transaction
+type UnitOfWork struct {
+	ctx  mongo.SessionContext
+	hook stepHook
+}
+
+// stepHookKey is the key of the context value with the function called before each step of the units of work
+type stepHookKey struct{}
+
+// stepHook is called with the name of each step before running it, the step fails with the returned error
+type stepHook func(step string) error
+
+// RunUnitOfWork Runs the given function inside a mongo transaction that is committed when the function doesn't return
+// an error. The function can be retried by the driver on transient errors, so it shouldn't have side effects outside
+// the database. The audit events recorded by the function are stored after the commit, outside the transaction
+func RunUnitOfWork(ctx context.Context, fn func(uow *UnitOfWork) error) error {
+	session, err := UserCollection.Database().Client().StartSession()
+	if err != nil {
+		return err
+	}
+
+	defer session.EndSession(ctx)
+	hook, _ := ctx.Value(stepHookKey{}).(stepHook)
+	deferredCtx, events := audit.Defer(ctx)
+
+	_, err = session.WithTransaction(deferredCtx, func(sessionContext mongo.SessionContext) (interface{}, error) {
+		// Drop the events of the previous attempt when the transaction is retried
+		events.Discard()
+		return nil, fn(&UnitOfWork{ctx: sessionContext, hook: hook})
+	})
+
+	if err != nil {
+		events.Discard()
+		return err
+	}
+
+	events.Flush(ctx)
+	return nil
+}
+
+// Context Returns the context of the transaction
+func (uow *UnitOfWork) Context() context.Context {
+	return uow.ctx
+}
+
+// Step Runs a named step of the operation inside the transaction. If the step returns an error, the whole unit of
+// work is rolled back
+func (uow *UnitOfWork) Step(name string, fn func(ctx context.Context) error) error {
+	if uow.hook != nil {
+		if err := uow.hook(name); err != nil {
+			return err
+		}
+	}
+
+	return fn(uow.ctx)
+}
@@ -0,0 +1,270 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/PedroChaparro/loomies-backend/audit"
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ## Helper functions
+// withFailingStep returns a context that makes the named step of the units of work fail
+func withFailingStep(ctx context.Context, step string) context.Context {
+	return context.WithValue(ctx, stepHookKey{}, stepHook(func(name string) error {
+		if name == step {
+			return errors.New("Injected failure")
+		}
+
+		return nil
+	}))
+}
+
+// insertTransactionsUser inserts a verified user without items nor loomies
+func insertTransactionsUser(c *require.Assertions) interfaces.User {
+	user := interfaces.User{
+		Id:         primitive.NewObjectID(),
+		Username:   "transactions_" + primitive.NewObjectID().Hex(),
+		Email:      primitive.NewObjectID().Hex() + "@transactions.test",
+		Items:      []interfaces.InventoryItem{},
+		Loomies:    []primitive.ObjectID{},
+		LoomieTeam: []primitive.ObjectID{},
+		IsVerified: true,
+	}
+
+	_, err := UserCollection.InsertOne(context.Background(), user)
+	c.NoError(err)
+	return user
+}
+
+// giveTransactionsLoomies inserts caught loomies for the user and puts the first ones in the team
+func giveTransactionsLoomies(c *require.Assertions, user interfaces.User, count int, teamSize int) []primitive.ObjectID {
+	ctx := context.Background()
+
+	var rarity interfaces.LoomieRarity
+	c.NoError(LoomieRaritiesCollection.FindOne(ctx, bson.M{}).Decode(&rarity))
+
+	ids := []primitive.ObjectID{}
+	for i := 0; i < count; i++ {
+		id, err := InsertInCaughtLoomies(ctx, interfaces.CaughtLoomie{Owner: user.Id, Name: "Rollback", Serial: i + 1, Rarity: rarity.Id, Level: 1})
+		c.NoError(err)
+		ids = append(ids, id)
+	}
+
+	_, err := UserCollection.UpdateOne(ctx, bson.M{"_id": user.Id}, bson.M{"$set": bson.M{"loomies": ids, "loomie_team": ids[:teamSize]}})
+	c.NoError(err)
+	return ids
+}
+
+// deleteTransactionsUser removes the user, its loomies and its audit events
+func deleteTransactionsUser(c *require.Assertions, user interfaces.User) {
+	ctx := context.Background()
+
+	_, err := CaughtLoomiesCollection.DeleteMany(ctx, bson.M{"owner": user.Id})
+	c.NoError(err)
+	_, err = audit.EventsCollection.DeleteMany(ctx, bson.M{"user_id": user.Id})
+	c.NoError(err)
+	_, err = UserCollection.DeleteOne(ctx, bson.M{"_id": user.Id})
+	c.NoError(err)
+}
+
+// ## Tests
+
+// TestCaptureLoomieRollback Tests the loomball is not spent when a step of the capture fails
+func TestCaptureLoomieRollback(t *testing.T) {
+	c := require.New(t)
+	ctx := context.Background()
+	user := insertTransactionsUser(c)
+
+	var loomball interfaces.Loomball
+	c.NoError(LoomballsCollection.FindOne(ctx, bson.M{}).Decode(&loomball))
+	c.NoError(AddItemToUserInventory(ctx, user.Id, interfaces.GymRewardItem{RewardCollection: "loom_balls", RewardId: loomball.Id, RewardQuantity: 1}))
+
+	result, err := WildLoomiesCollection.InsertOne(ctx, interfaces.WildLoomie{Serial: 1, Name: "Rollback", Level: 1, CapturedBy: []primitive.ObjectID{}})
+	c.NoError(err)
+	wildLoomie, err := GetWildLoomieById(result.InsertedID.(primitive.ObjectID).Hex())
+	c.NoError(err)
+
+	// 1. Fail after storing the caught loomie, nothing is committed
+	for _, step := range []string{CaptureRegisterStep, CaptureInsertLoomieStep, CaptureAddToUserStep} {
+		_, err = CaptureWildLoomie(withFailingStep(ctx, step), user, wildLoomie, loomball.Id, true)
+		c.Error(err)
+
+		updatedUser, err := GetUserById(user.Id.Hex())
+		c.NoError(err)
+		c.Equal(1, len(updatedUser.Items))
+		c.Equal(1, updatedUser.Items[0].ItemQuantity)
+		c.Empty(updatedUser.Loomies)
+
+		caught, err := CaughtLoomiesCollection.CountDocuments(ctx, bson.M{"owner": user.Id})
+		c.NoError(err)
+		c.Equal(int64(0), caught)
+
+		updatedWildLoomie, err := GetWildLoomieById(wildLoomie.Id.Hex())
+		c.NoError(err)
+		c.Empty(updatedWildLoomie.CapturedBy)
+	}
+
+	// 2. Capture the loomie, the loomie can't be captured twice
+	caughtLoomieId, err := CaptureWildLoomie(ctx, user, wildLoomie, loomball.Id, true)
+	c.NoError(err)
+
+	updatedUser, err := GetUserById(user.Id.Hex())
+	c.NoError(err)
+	c.Empty(updatedUser.Items)
+	c.Equal([]primitive.ObjectID{caughtLoomieId}, updatedUser.Loomies)
+
+	c.NoError(AddItemToUserInventory(ctx, user.Id, interfaces.GymRewardItem{RewardCollection: "loom_balls", RewardId: loomball.Id, RewardQuantity: 1}))
+	_, err = CaptureWildLoomie(ctx, user, wildLoomie, loomball.Id, true)
+	c.ErrorIs(err, ErrLoomieAlreadyCaught)
+
+	updatedUser, err = GetUserById(user.Id.Hex())
+	c.NoError(err)
+	c.Equal(1, len(updatedUser.Items))
+
+	WildLoomiesCollection.DeleteOne(ctx, bson.M{"_id": wildLoomie.Id})
+	deleteTransactionsUser(c, user)
+}
+
+// TestFuseLoomiesRollback Tests both loomies are kept when a step of the fusion fails
+func TestFuseLoomiesRollback(t *testing.T) {
+	c := require.New(t)
+	ctx := context.Background()
+	user := insertTransactionsUser(c)
+	loomies := giveTransactionsLoomies(c, user, 2, 2)
+
+	fused := interfaces.UserLoomiesRes{Id: loomies[0], Hp: 100, Attack: 100, Defense: 100, Level: 2}
+
+	for _, step := range []string{FuseRemoveFromUserStep, FuseDeleteLoomieStep} {
+		err := FuseLoomies(withFailingStep(ctx, step), user.Id, fused, interfaces.UserLoomiesRes{Id: loomies[1]})
+		c.Error(err)
+
+		updatedUser, err := GetUserById(user.Id.Hex())
+		c.NoError(err)
+		c.ElementsMatch(loomies, updatedUser.Loomies)
+		c.ElementsMatch(loomies, updatedUser.LoomieTeam)
+
+		var first interfaces.CaughtLoomie
+		c.NoError(CaughtLoomiesCollection.FindOne(ctx, bson.M{"_id": loomies[0]}).Decode(&first))
+		c.Equal(1, first.Level)
+
+		count, err := CaughtLoomiesCollection.CountDocuments(ctx, bson.M{"_id": loomies[1]})
+		c.NoError(err)
+		c.Equal(int64(1), count)
+	}
+
+	c.NoError(FuseLoomies(ctx, user.Id, fused, interfaces.UserLoomiesRes{Id: loomies[1]}))
+
+	updatedUser, err := GetUserById(user.Id.Hex())
+	c.NoError(err)
+	c.Equal([]primitive.ObjectID{loomies[0]}, updatedUser.Loomies)
+
+	count, err := CaughtLoomiesCollection.CountDocuments(ctx, bson.M{"_id": loomies[1]})
+	c.NoError(err)
+	c.Equal(int64(0), count)
+
+	deleteTransactionsUser(c, user)
+}
+
+// TestClaimRewardRollback Tests the reward is not registered as claimed when the items can't be added
+func TestClaimRewardRollback(t *testing.T) {
+	c := require.New(t)
+	ctx := context.Background()
+	user := insertTransactionsUser(c)
+
+	var gym interfaces.Gym
+	c.NoError(GymsCollection.FindOne(ctx, bson.M{"current_players_rewards.0": bson.M{"$exists": true}}).Decode(&gym))
+
+	// 1. Fail adding the items, the reward can be claimed again
+	err := ClaimGymReward(withFailingStep(ctx, ClaimAddItemsStep), gym, user.Id, gym.CurrentPlayersRewards)
+	c.Error(err)
+
+	claimed, err := GymsCollection.CountDocuments(ctx, bson.M{"_id": gym.Id, "rewards_claimed_by": user.Id})
+	c.NoError(err)
+	c.Equal(int64(0), claimed)
+
+	updatedUser, err := GetUserById(user.Id.Hex())
+	c.NoError(err)
+	c.Empty(updatedUser.Items)
+
+	// 2. Claim the reward, it can only be claimed once
+	c.NoError(ClaimGymReward(ctx, gym, user.Id, gym.CurrentPlayersRewards))
+	c.ErrorIs(ClaimGymReward(ctx, gym, user.Id, gym.CurrentPlayersRewards), ErrRewardAlreadyClaimed)
+
+	updatedUser, err = GetUserById(user.Id.Hex())
+	c.NoError(err)
+	c.NotEmpty(updatedUser.Items)
+
+	GymsCollection.UpdateOne(ctx, bson.M{"_id": gym.Id}, bson.M{"$pull": bson.M{"rewards_claimed_by": user.Id}})
+	deleteTransactionsUser(c, user)
+}
+
+// TestGymTakeoverRollback Tests the gym, its protectors and the player team are kept when a step of the takeover fails
+func TestGymTakeoverRollback(t *testing.T) {
+	c := require.New(t)
+	ctx := context.Background()
+	user := insertTransactionsUser(c)
+	loomies := giveTransactionsLoomies(c, user, 2, 2)
+
+	var gym interfaces.Gym
+	c.NoError(GymsCollection.FindOne(ctx, bson.M{"protectors.0": bson.M{"$exists": true}}).Decode(&gym))
+
+	for _, step := range []string{TakeoverOldProtectorsStep, TakeoverGymStep, TakeoverNewProtectorsStep} {
+		err := TakeOverGym(withFailingStep(ctx, step), gym.Id, user.Id, loomies, gym.Protectors, !gym.Owner.IsZero())
+		c.Error(err)
+
+		var updatedGym interfaces.Gym
+		c.NoError(GymsCollection.FindOne(ctx, bson.M{"_id": gym.Id}).Decode(&updatedGym))
+		c.Equal(gym.Owner, updatedGym.Owner)
+		c.Equal(gym.Protectors, updatedGym.Protectors)
+
+		protectors, err := CaughtLoomiesCollection.CountDocuments(ctx, bson.M{"_id": bson.M{"$in": gym.Protectors}})
+		c.NoError(err)
+		c.Equal(int64(len(gym.Protectors)), protectors)
+
+		busy, err := CaughtLoomiesCollection.CountDocuments(ctx, bson.M{"_id": bson.M{"$in": loomies}, "is_busy": true})
+		c.NoError(err)
+		c.Equal(int64(0), busy)
+
+		updatedUser, err := GetUserById(user.Id.Hex())
+		c.NoError(err)
+		c.ElementsMatch(loomies, updatedUser.LoomieTeam)
+	}
+
+	deleteTransactionsUser(c, user)
+}
+
+// TestDeleteAccountRollback Tests the account data is kept when a step of the deletion fails
+func TestDeleteAccountRollback(t *testing.T) {
+	c := require.New(t)
+	ctx := context.Background()
+	user := insertTransactionsUser(c)
+	giveTransactionsLoomies(c, user, 2, 1)
+
+	for _, step := range []string{DeleteAccountDataStep, DeleteAccountUserStep} {
+		err := DeleteUserAccount(withFailingStep(ctx, step), user)
+		c.Error(err)
+
+		_, err = GetUserById(user.Id.Hex())
+		c.NoError(err)
+
+		count, err := CaughtLoomiesCollection.CountDocuments(ctx, bson.M{"owner": user.Id})
+		c.NoError(err)
+		c.Equal(int64(2), count)
+	}
+
+	c.NoError(DeleteUserAccount(ctx, user))
+
+	_, err := GetUserById(user.Id.Hex())
+	c.Error(err)
+
+	count, err := CaughtLoomiesCollection.CountDocuments(ctx, bson.M{"owner": user.Id})
+	c.NoError(err)
+	c.Equal(int64(0), count)
+
+	deleteTransactionsUser(c, user)
+}
@@ -2,17 +2,26 @@ package models
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"time"
 
+	"github.com/PedroChaparro/loomies-backend/audit"
 	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/repositories"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// AuthenticationCodeMinutes is the time to live of the account verification and password reset codes
+const AuthenticationCodeMinutes = repositories.AuthenticationCodeMinutes
+
+// ErrItemNotFound is returned when the item to decrement is not in the user inventory
+var ErrItemNotFound = repositories.ErrItemNotFound
+
 // InsertUser Creates a new user in the database and returns an error if any
-func InsertUser(data interfaces.User) error {
+func InsertUser(ctx context.Context, data interfaces.User) error {
 	// Set the current time as the "last time the user generated loomies"
 	data.LastLoomieGenerationTime = time.Now().Unix()
 
@@ -22,46 +31,76 @@ func InsertUser(data interfaces.User) error {
 	data.LoomieTeam = []primitive.ObjectID{}
 
 	//Insert User in database
-	_, err := UserCollection.InsertOne(context.TODO(), data)
+	result, err := UserCollection.InsertOne(ctx, data)
 
-	return err
+	if err != nil {
+		return err
+	}
+
+	id, _ := result.InsertedID.(primitive.ObjectID)
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		ActorId:  id,
+		UserId:   id,
+		Action:   "user.create",
+		Entity:   "users",
+		EntityId: id,
+		After:    bson.M{"username": data.Username, "email": data.Email},
+	})
+
+	return nil
 }
 
 // UpdatePasword Updates the password of the user with the given email
-func UpdatePasword(email string, password string) error {
+func UpdatePasword(ctx context.Context, email string, password string) error {
+	var user interfaces.User
 	filter := bson.D{{Key: "email", Value: email}}
 	update := bson.D{{Key: "$set", Value: bson.D{
 		{Key: "password", Value: password},
 	},
 	}}
-	_, err := UserCollection.UpdateOne(context.TODO(), filter, update)
+	err := UserCollection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetProjection(bson.D{{Key: "_id", Value: 1}})).Decode(&user)
 	if err != nil {
 		fmt.Println(err)
+		return err
 	}
-	return err
+
+	// The password hashes are not stored in the audit log
+	audit.Record(ctx, interfaces.AuditEvent{
+		ActorId:  user.Id,
+		UserId:   user.Id,
+		Action:   "user.update_password",
+		Entity:   "users",
+		EntityId: user.Id,
+	})
+
+	return nil
 }
 
-// GetUserByEmail Returns a user by its email and an error (if any)
+// caseInsensitive is the collation of the unique username and email indexes, the lookups use it to be served by them
+var caseInsensitive = &options.Collation{Locale: "en", Strength: 2}
+
+// GetUserByEmail Returns a user by its email (case insensitive) and an error (if any)
 func GetUserByEmail(email string) (interfaces.User, error) {
 	var userE interfaces.User
 
-	// Find the user with the given email (case insensitive)
 	err := UserCollection.FindOne(
 		context.TODO(),
-		bson.M{"email": bson.M{"$regex": email, "$options": "i"}},
+		bson.M{"email": email},
+		options.FindOne().SetCollation(caseInsensitive),
 	).Decode(&userE)
 
 	return userE, err
 }
 
-// CheckExistUsername Returns an user by its username and an error (if any)
+// GetUserByUsername Returns an user by its username (case insensitive) and an error (if any)
 func GetUserByUsername(Username string) (interfaces.User, error) {
 	var userU interfaces.User
 
-	//Find the user with the given username (case insensitive)
 	err := UserCollection.FindOne(
 		context.TODO(),
-		bson.M{"username": bson.M{"$regex": Username, "$options": "i"}},
+		bson.M{"username": Username},
+		options.FindOne().SetCollation(caseInsensitive),
 	).Decode(&userU)
 
 	return userU, err
@@ -199,7 +238,7 @@ func UpdateAccountVerificationCode(email string, validationCode string) error {
 		Email:     email,
 		Code:      validationCode,
 		Type:      "ACCOUNT_VERIFICATION",
-		ExpiresAt: time.Now().Add(time.Minute * 15).Unix(),
+		ExpiresAt: time.Now().Add(time.Minute * AuthenticationCodeMinutes).Unix(),
 	})
 
 	if err != nil {
@@ -226,7 +265,7 @@ func UpdatePasswordResetCode(email string, resetPassCode string) error {
 		Email:     email,
 		Code:      resetPassCode,
 		Type:      "RESET_PASSWORD",
-		ExpiresAt: time.Now().Add(time.Minute * 15).Unix(),
+		ExpiresAt: time.Now().Add(time.Minute * AuthenticationCodeMinutes).Unix(),
 	})
 
 	if err != nil {
@@ -238,7 +277,7 @@ func UpdatePasswordResetCode(email string, resetPassCode string) error {
 }
 
 // AddItemToUserInventory Adds an item to the user's inventory
-func AddItemToUserInventory(userId primitive.ObjectID, item interfaces.GymRewardItem) error {
+func AddItemToUserInventory(ctx context.Context, userId primitive.ObjectID, item interfaces.GymRewardItem) error {
 	userInventoryItem := interfaces.InventoryItem{
 		ItemCollection: item.RewardCollection,
 		ItemId:         item.RewardId,
@@ -248,9 +287,10 @@ func AddItemToUserInventory(userId primitive.ObjectID, item interfaces.GymReward
 	// Check if the item already exists in the user's inventory
 	var user interfaces.User
 	var alreadyExists = false
+	var currentQuantity = 0
 
 	err := UserCollection.FindOne(
-		context.TODO(),
+		ctx,
 		bson.D{
 			{Key: "_id", Value: userId},
 		},
@@ -263,6 +303,7 @@ func AddItemToUserInventory(userId primitive.ObjectID, item interfaces.GymReward
 	for _, inventoryItem := range user.Items {
 		if inventoryItem.ItemId == item.RewardId {
 			alreadyExists = true
+			currentQuantity = inventoryItem.ItemQuantity
 			break
 		}
 	}
@@ -270,7 +311,7 @@ func AddItemToUserInventory(userId primitive.ObjectID, item interfaces.GymReward
 	if alreadyExists {
 		// Update the user document to increment the item quantity
 		_, err = UserCollection.UpdateOne(
-			context.TODO(),
+			ctx,
 			bson.D{
 				// Match tue user
 				{Key: "_id", Value: userId},
@@ -290,7 +331,7 @@ func AddItemToUserInventory(userId primitive.ObjectID, item interfaces.GymReward
 	} else {
 		// Update the user document to add the item to the inventory
 		_, err = UserCollection.UpdateOne(
-			context.TODO(),
+			ctx,
 			bson.D{{Key: "_id", Value: userId}},
 			bson.D{
 				{Key: "$push", Value: bson.D{
@@ -300,13 +341,26 @@ func AddItemToUserInventory(userId primitive.ObjectID, item interfaces.GymReward
 		)
 	}
 
-	return err
+	if err != nil {
+		return err
+	}
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		UserId:   userId,
+		Action:   "inventory.add",
+		Entity:   item.RewardCollection,
+		EntityId: item.RewardId,
+		Before:   bson.M{"quantity": currentQuantity},
+		After:    bson.M{"quantity": currentQuantity + item.RewardQuantity},
+	})
+
+	return nil
 }
 
 // AddItemsToUserInventory Adds multiple items to the user's inventory
-func AddItemsToUserInventory(userId primitive.ObjectID, items []interfaces.GymRewardItem) error {
+func AddItemsToUserInventory(ctx context.Context, userId primitive.ObjectID, items []interfaces.GymRewardItem) error {
 	for _, item := range items {
-		err := AddItemToUserInventory(userId, item)
+		err := AddItemToUserInventory(ctx, userId, item)
 		if err != nil {
 			return err
 		}
@@ -390,85 +444,144 @@ func GetLoomiesByIds(loomiesArray []primitive.ObjectID, userId primitive.ObjectI
 	return loomies, err
 }
 
-// FuseLoomies Allows to fuse two loomies updating the first one and deleting the second one
-func FuseLoomies(userId primitive.ObjectID, loomieToUpdate, loomieToDelete interfaces.UserLoomiesRes) error {
-	// Update the first loomie in the caught loomies collection
-	_, err := CaughtLoomiesCollection.UpdateOne(
-		context.TODO(),
-		bson.D{
-			{Key: "_id", Value: loomieToUpdate.Id},
-		},
-		bson.D{
-			{Key: "$set", Value: bson.D{
-				{Key: "hp", Value: loomieToUpdate.Hp},
-				{Key: "attack", Value: loomieToUpdate.Attack},
-				{Key: "defense", Value: loomieToUpdate.Defense},
-				{Key: "experience", Value: loomieToUpdate.Experience},
-				{Key: "level", Value: loomieToUpdate.Level},
-			}},
-		},
-	)
+// Steps of the fuse unit of work
+const (
+	FuseUpdateLoomieStep   = "fuse.update_loomie"
+	FuseRemoveFromUserStep = "fuse.remove_from_user"
+	FuseDeleteLoomieStep   = "fuse.delete_loomie"
+)
 
-	if err != nil {
-		return errors.New("Error updating the first loomie")
-	}
+// FuseLoomies Allows to fuse two loomies updating the first one and deleting the second one. The writes are done in
+// a single unit of work, so the loomies are not lost if the fusion fails
+func FuseLoomies(ctx context.Context, userId primitive.ObjectID, loomieToUpdate, loomieToDelete interfaces.UserLoomiesRes) error {
+	return RunUnitOfWork(ctx, func(uow *UnitOfWork) error {
+		var before interfaces.CaughtLoomie
+
+		// Update the first loomie in the caught loomies collection
+		err := uow.Step(FuseUpdateLoomieStep, func(ctx context.Context) error {
+			err := CaughtLoomiesCollection.FindOneAndUpdate(
+				ctx,
+				bson.D{
+					{Key: "_id", Value: loomieToUpdate.Id},
+				},
+				bson.D{
+					{Key: "$set", Value: bson.D{
+						{Key: "hp", Value: loomieToUpdate.Hp},
+						{Key: "attack", Value: loomieToUpdate.Attack},
+						{Key: "defense", Value: loomieToUpdate.Defense},
+						{Key: "experience", Value: loomieToUpdate.Experience},
+						{Key: "level", Value: loomieToUpdate.Level},
+					}},
+				},
+			).Decode(&before)
 
-	// Delete the second loomie in the user's inventory and the loomie_team
-	fmt.Println(loomieToDelete.Id)
+			if err != nil {
+				return fmt.Errorf("Error updating the first loomie: %w", err)
+			}
 
-	_, err = UserCollection.UpdateOne(
-		context.TODO(),
-		bson.D{
-			{Key: "_id", Value: userId},
-		},
-		bson.D{
-			{Key: "$pull", Value: bson.D{
-				{Key: "loomies", Value: loomieToDelete.Id},
-				{Key: "loomie_team", Value: loomieToDelete.Id},
-			}},
-		},
-	)
+			return nil
+		})
 
-	if err != nil {
-		return errors.New("Error deleting the second loomie from the user's inventory")
-	}
+		if err != nil {
+			return err
+		}
 
-	// Delete the second loomie in the caught loomies collection
-	_, err = CaughtLoomiesCollection.DeleteOne(
-		context.TODO(),
-		bson.D{
-			{Key: "_id", Value: loomieToDelete.Id},
-		},
-	)
+		// Delete the second loomie in the user's inventory and the loomie_team
+		err = uow.Step(FuseRemoveFromUserStep, func(ctx context.Context) error {
+			_, err := UserCollection.UpdateOne(
+				ctx,
+				bson.D{
+					{Key: "_id", Value: userId},
+				},
+				bson.D{
+					{Key: "$pull", Value: bson.D{
+						{Key: "loomies", Value: loomieToDelete.Id},
+						{Key: "loomie_team", Value: loomieToDelete.Id},
+					}},
+				},
+			)
 
-	if err != nil {
-		return errors.New("Error deleting the second loomie from the caught loomies collection")
-	}
+			if err != nil {
+				return fmt.Errorf("Error deleting the second loomie from the user's inventory: %w", err)
+			}
 
-	return nil
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		// Delete the second loomie in the caught loomies collection
+		err = uow.Step(FuseDeleteLoomieStep, func(ctx context.Context) error {
+			_, err := CaughtLoomiesCollection.DeleteOne(
+				ctx,
+				bson.D{
+					{Key: "_id", Value: loomieToDelete.Id},
+				},
+			)
+
+			if err != nil {
+				return fmt.Errorf("Error deleting the second loomie from the caught loomies collection: %w", err)
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		audit.Record(uow.Context(), interfaces.AuditEvent{
+			UserId:   userId,
+			Action:   "loomie.fuse",
+			Entity:   "caught_loomies",
+			EntityId: loomieToUpdate.Id,
+			Before:   bson.M{"loomie": before, "fused_loomie": loomieToDelete},
+			After:    bson.M{"loomie": loomieToUpdate},
+		})
+
+		return nil
+	})
 }
 
 // ReplaceLoomieTeam Replaces the loomie team of the user
-func ReplaceLoomieTeam(userId primitive.ObjectID, loomiesIds []primitive.ObjectID) error {
+func ReplaceLoomieTeam(ctx context.Context, userId primitive.ObjectID, loomiesIds []primitive.ObjectID) error {
+	var before interfaces.User
+
 	// Update the user document to add the item to the inventory
-	_, err := UserCollection.UpdateOne(
-		context.TODO(),
+	err := UserCollection.FindOneAndUpdate(
+		ctx,
 		bson.D{{Key: "_id", Value: userId}},
 		bson.D{
 			{Key: "$set", Value: bson.D{
 				{Key: "loomie_team", Value: loomiesIds},
 			}},
 		},
-	)
+		options.FindOneAndUpdate().SetProjection(bson.D{{Key: "loomie_team", Value: 1}}),
+	).Decode(&before)
 
-	return err
+	if err != nil {
+		return err
+	}
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		UserId:   userId,
+		Action:   "user.update_team",
+		Entity:   "users",
+		EntityId: userId,
+		Before:   bson.M{"loomie_team": before.LoomieTeam},
+		After:    bson.M{"loomie_team": loomiesIds},
+	})
+
+	return nil
 }
 
 // RemoveFromLoomieTeam Removes the given loomies from the loomie team array of the given user
-func RemoveFromLoomieTeam(userId primitive.ObjectID, loomiesIds []primitive.ObjectID) error {
+func RemoveFromLoomieTeam(ctx context.Context, userId primitive.ObjectID, loomiesIds []primitive.ObjectID) error {
 	// Update the user document to add the item to the inventory
 	_, err := UserCollection.UpdateOne(
-		context.TODO(),
+		ctx,
 		bson.D{{Key: "_id", Value: userId}},
 		bson.D{
 			{Key: "$pull", Value: bson.D{
@@ -479,30 +592,53 @@ func RemoveFromLoomieTeam(userId primitive.ObjectID, loomiesIds []primitive.Obje
 		},
 	)
 
-	return err
+	if err != nil {
+		return err
+	}
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		UserId:   userId,
+		Action:   "user.update_team",
+		Entity:   "users",
+		EntityId: userId,
+		After:    bson.M{"removed_loomies": loomiesIds},
+	})
+
+	return nil
 }
 
 // AddToUserLoomies Adds a loomie to the user's loomies
-func AddToUserLoomies(user interfaces.User, loomie_id primitive.ObjectID) error {
+func AddToUserLoomies(ctx context.Context, user interfaces.User, loomie_id primitive.ObjectID) error {
 	filter := bson.D{{Key: "_id", Value: user.Id}}
 	update := bson.D{{Key: "$push", Value: bson.D{
 		{Key: "loomies", Value: loomie_id},
 	},
 	}}
-	_, err := UserCollection.UpdateOne(context.TODO(), filter, update)
+	_, err := UserCollection.UpdateOne(ctx, filter, update)
 
-	return err
+	if err != nil {
+		return err
+	}
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		UserId:   user.Id,
+		Action:   "user.add_loomie",
+		Entity:   "caught_loomies",
+		EntityId: loomie_id,
+	})
+
+	return nil
 }
 
 // DecrementItemFromUserInventory Decrements the quantity of an item from the user's inventory and removes it if the quantity is lower than or equal to 0
-func DecrementItemFromUserInventory(userId primitive.ObjectID, itemId primitive.ObjectID, quantity int) error {
+func DecrementItemFromUserInventory(ctx context.Context, userId primitive.ObjectID, itemId primitive.ObjectID, quantity int) error {
 	var user interfaces.User
 	found := false
 	remove := false
 
 	//Check if user exists
 	err := UserCollection.FindOne(
-		context.TODO(),
+		ctx,
 		bson.D{
 			{Key: "_id", Value: userId},
 		},
@@ -514,16 +650,18 @@ func DecrementItemFromUserInventory(userId primitive.ObjectID, itemId primitive.
 
 	for i := 0; i < len(user.Items); i++ {
 		if user.Items[i].ItemId == itemId {
+			currentQuantity := user.Items[i].ItemQuantity
 			user.Items[i].ItemQuantity = user.Items[i].ItemQuantity - quantity
 			//If there are no more items, remove it from the list.
 			if user.Items[i].ItemQuantity <= 0 {
+				user.Items[i].ItemQuantity = 0
 
 				filter := bson.D{{Key: "_id", Value: user.Id}}
 				update := bson.D{{Key: "$pull", Value: bson.D{
 					{Key: "items", Value: bson.M{"item_id": user.Items[i].ItemId}},
 				},
 				}}
-				_, err = UserCollection.UpdateOne(context.TODO(), filter, update)
+				_, err = UserCollection.UpdateOne(ctx, filter, update)
 
 				remove = true
 			}
@@ -535,28 +673,38 @@ func DecrementItemFromUserInventory(userId primitive.ObjectID, itemId primitive.
 					{Key: "items.$.item_quantity", Value: user.Items[i].ItemQuantity},
 				},
 				}}
-				_, err = UserCollection.UpdateOne(context.TODO(), filter, update)
+				_, err = UserCollection.UpdateOne(ctx, filter, update)
 			}
 
 			if err != nil {
 				return err
 			}
 
+			audit.Record(ctx, interfaces.AuditEvent{
+				UserId:   userId,
+				Action:   "inventory.remove",
+				Entity:   user.Items[i].ItemCollection,
+				EntityId: itemId,
+				Before:   bson.M{"quantity": currentQuantity},
+				After:    bson.M{"quantity": user.Items[i].ItemQuantity},
+			})
+
 			found = true
 		}
 	}
 
 	//Error if item not found
 	if !found {
-		err = errors.New("Item not found")
-		return err
+		return ErrItemNotFound
 	}
 
 	return nil
 }
 
 // IncrementItemFromUserInventory Increment the quantity of an item from the user's inventory
-func IncrementItemFromUserInventory(userId primitive.ObjectID, itemId primitive.ObjectID, quantity int) error {
+func IncrementItemFromUserInventory(ctx context.Context, userId primitive.ObjectID, itemId primitive.ObjectID, quantity int) error {
+	var before interfaces.User
+
 	//Update the number of items in mongo
 	filter := bson.D{{Key: "_id", Value: userId}, {Key: "items.item_id", Value: itemId}}
 	update := bson.D{
@@ -570,12 +718,30 @@ func IncrementItemFromUserInventory(userId primitive.ObjectID, itemId primitive.
 			},
 		},
 	}
-	_, err := UserCollection.UpdateOne(context.TODO(), filter, update)
+	err := UserCollection.FindOneAndUpdate(ctx, filter, update).Decode(&before)
+
+	// Nothing is incremented if the user doesn't have the item
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
 
 	if err != nil {
 		return err
 	}
 
+	for _, item := range before.Items {
+		if item.ItemId == itemId {
+			audit.Record(ctx, interfaces.AuditEvent{
+				UserId:   userId,
+				Action:   "inventory.add",
+				Entity:   item.ItemCollection,
+				EntityId: itemId,
+				Before:   bson.M{"quantity": item.ItemQuantity},
+				After:    bson.M{"quantity": item.ItemQuantity + quantity},
+			})
+		}
+	}
+
 	return nil
 }
 
@@ -590,3 +756,99 @@ func GetActiveCombatByUseId(userId primitive.ObjectID) (interfaces.GymChallenges
 
 	return gymChallengeRegister, err
 }
+
+// GetUserByIdentity Returns the user linked with the given external identity and an error (if any)
+func GetUserByIdentity(provider string, subject string) (interfaces.User, error) {
+	var user interfaces.User
+
+	err := UserCollection.FindOne(
+		context.TODO(),
+		bson.D{{Key: "identities", Value: bson.D{
+			{Key: "$elemMatch", Value: bson.D{
+				{Key: "provider", Value: provider},
+				{Key: "subject", Value: subject},
+			}},
+		}}},
+	).Decode(&user)
+
+	return user, err
+}
+
+// LinkUserIdentity Links an external identity with the given user and marks the account as verified. The password of
+// unverified accounts is removed because it wasn't set by the owner of the email (Eg. someone registered the email
+// before its owner), those accounts can't log in, so they don't have sessions to revoke
+func LinkUserIdentity(ctx context.Context, user interfaces.User, identity interfaces.UserIdentity) error {
+	set := bson.D{{Key: "isVerified", Value: true}}
+	if !user.IsVerified {
+		set = append(set, bson.E{Key: "password", Value: ""})
+	}
+
+	// The verification state is checked again, so the password is not kept if the account changed meanwhile
+	result, err := UserCollection.UpdateOne(
+		ctx,
+		bson.D{{Key: "_id", Value: user.Id}, {Key: "isVerified", Value: user.IsVerified}},
+		bson.D{
+			{Key: "$push", Value: bson.D{
+				{Key: "identities", Value: identity},
+			}},
+			{Key: "$set", Value: set},
+		},
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	if !user.IsVerified {
+		// The pending codes were requested by whoever registered the account
+		_, err = AuthenticationCodesCollection.DeleteMany(ctx, bson.D{{Key: "email", Value: user.Email}})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		ActorId:  user.Id,
+		UserId:   user.Id,
+		Action:   "user.link_identity",
+		Entity:   "users",
+		EntityId: user.Id,
+		After:    bson.M{"identity": identity, "password_removed": !user.IsVerified},
+	})
+
+	return nil
+}
+
+// UpdateUserRoles Replaces the roles of the user with the given id
+func UpdateUserRoles(ctx context.Context, userId primitive.ObjectID, roles []string) error {
+	var before interfaces.User
+
+	err := UserCollection.FindOneAndUpdate(
+		ctx,
+		bson.D{{Key: "_id", Value: userId}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "roles", Value: roles},
+		}}},
+		options.FindOneAndUpdate().SetProjection(bson.D{{Key: "roles", Value: 1}}),
+	).Decode(&before)
+
+	if err != nil {
+		return err
+	}
+
+	audit.Record(ctx, interfaces.AuditEvent{
+		UserId:   userId,
+		Action:   "user.update_roles",
+		Entity:   "users",
+		EntityId: userId,
+		Before:   bson.M{"roles": before.Roles},
+		After:    bson.M{"roles": roles},
+	})
+
+	return nil
+}
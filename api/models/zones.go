@@ -5,52 +5,48 @@ import (
 	"fmt"
 
 	"github.com/PedroChaparro/loomies-backend/interfaces"
-	"github.com/PedroChaparro/loomies-backend/utils"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// GetNearGyms Returns an array of gyms near the current coordinates
-func GetNearGyms(currentLatitude float64, currentLongitude float64) (p []interfaces.NearGymsRes, e error) {
-	mZonesCoord := utils.GetNearZonesCoordinates(interfaces.Coordinates{
-		Latitude:  currentLatitude,
-		Longitude: currentLongitude,
-	})
-
-	// filters for aggregation and lookup into gyms collection
-	zonesFilter := bson.M{"coordinates": bson.M{"$in": mZonesCoord}}
-	matchFilter := bson.M{"$match": zonesFilter}
-	lookupIntoGyms := bson.M{
-		"$lookup": bson.M{
-			"from":         "gyms",
-			"localField":   "gyms",
-			"foreignField": "_id",
-			"as":           "gyms",
+// nearFilter "private" function to get the filter of the documents with a location at most at the given radius (in
+// meters) from the coordinates, sorted from the nearest to the farthest
+func nearFilter(coordinates interfaces.Coordinates, radius float64) bson.M {
+	return bson.M{
+		"location": bson.M{
+			"$nearSphere": bson.M{
+				"$geometry":    coordinates.ToGeoPoint(),
+				"$maxDistance": radius,
+			},
 		},
 	}
+}
 
-	// make the aggregation and get the gym field
-	cursor, err := ZonesCollection.Aggregate(context.TODO(), []bson.M{matchFilter, lookupIntoGyms})
+// GetNearGyms Returns an array of gyms at most at the visibility radius (in meters) of the current coordinates
+func GetNearGyms(currentLatitude float64, currentLongitude float64, visibilityRadius float64) ([]interfaces.NearGymsRes, error) {
+	gyms := []interfaces.NearGymsRes{}
+	filter := nearFilter(interfaces.Coordinates{Latitude: currentLatitude, Longitude: currentLongitude}, visibilityRadius)
 
-	var gyms []interfaces.NearGymsRes
+	cursor, err := GymsCollection.Find(context.TODO(), filter)
+	if err != nil {
+		return gyms, err
+	}
 
-	for cursor.Next(context.Background()) {
-		var surroundZones interfaces.ZoneWithGyms
-		cursor.Decode(&surroundZones)
+	for cursor.Next(context.TODO()) {
+		var gym interfaces.Gym
+		cursor.Decode(&gym)
 
-		for _, gym := range surroundZones.Gyms {
-			// Parse the gym to NearGymsRes (to remove unnecessary fields)
-			nearGymStruct := gym.ToNearGymsRes()
-			gyms = append(gyms, *nearGymStruct)
-		}
+		// Parse the gym to NearGymsRes (to remove unnecessary fields)
+		gyms = append(gyms, *gym.ToNearGymsRes())
 	}
 
-	return gyms, err
+	return gyms, cursor.Err()
 }
 
-// GetZoneFromCoordinates returns a zone from the given coordinates
-func GetZoneFromCoordinates(coordX int, coordY int) (interfaces.Zone, error) {
+// GetZoneFromCoordinates returns the zone of the region with the given coordinates
+func GetZoneFromCoordinates(regionId primitive.ObjectID, coordX int, coordY int) (interfaces.Zone, error) {
 	var zone interfaces.Zone
-	zoneFilter := bson.M{"coordinates": fmt.Sprintf("%v,%v", coordX, coordY)}
+	zoneFilter := bson.M{"region": regionId, "coordinates": fmt.Sprintf("%v,%v", coordX, coordY)}
 	err := ZonesCollection.FindOne(context.Background(), zoneFilter).Decode(&zone)
 	return zone, err
 }
@@ -0,0 +1,574 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ## Accounts
+
+// deleteAuthenticationCodes "private" function to remove the codes of the email with the given type (all the types if
+// it's empty). The store should be locked
+func (store *Store) deleteAuthenticationCodes(email string, codeType string) {
+	codes := []interfaces.AuthenticationCode{}
+
+	for _, code := range store.AuthenticationCodes {
+		if code.Email != email || (codeType != "" && code.Type != codeType) {
+			codes = append(codes, code)
+		}
+	}
+
+	store.AuthenticationCodes = codes
+}
+
+// insertAuthenticationCode "private" function to replace the codes of the email with the given type. The store
+// should be locked
+func (store *Store) insertAuthenticationCode(email string, code string, codeType string) {
+	store.deleteAuthenticationCodes(email, codeType)
+	store.AuthenticationCodes = append(store.AuthenticationCodes, interfaces.AuthenticationCode{
+		Id:        primitive.NewObjectID(),
+		Email:     email,
+		Code:      code,
+		Type:      codeType,
+		ExpiresAt: time.Now().Add(time.Minute * repositories.AuthenticationCodeMinutes).Unix(),
+	})
+}
+
+// compareAuthenticationCode "private" function to check the code of the email with the given type. The expired
+// codes are removed. The store should be locked
+func (store *Store) compareAuthenticationCode(email string, code string, codeType string) bool {
+	for _, current := range store.AuthenticationCodes {
+		if current.Email != email || current.Type != codeType {
+			continue
+		}
+
+		if !time.Now().Before(time.Unix(current.ExpiresAt, 0)) {
+			store.deleteAuthenticationCodes(email, codeType)
+			return false
+		}
+
+		return current.Code == code
+	}
+
+	return false
+}
+
+// getUserIdByEmail "private" function to get the id of the user with the given email. The store should be locked
+func (store *Store) getUserIdByEmail(email string) (primitive.ObjectID, bool) {
+	for id, user := range store.Users {
+		if user.Email == email {
+			return id, true
+		}
+	}
+
+	return primitive.NilObjectID, false
+}
+
+func (repository accountsRepository) UpdateAccountVerificationCode(email string, validationCode string) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+	repository.store.insertAuthenticationCode(email, validationCode, "ACCOUNT_VERIFICATION")
+	return nil
+}
+
+func (repository accountsRepository) CompareAccountVerificationCode(email string, code string) bool {
+	store := repository.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if !store.compareAuthenticationCode(email, code, "ACCOUNT_VERIFICATION") {
+		return false
+	}
+
+	userId, ok := store.getUserIdByEmail(email)
+	if !ok {
+		return false
+	}
+
+	user := store.Users[userId]
+	user.IsVerified = true
+	store.Users[userId] = user
+	store.deleteAuthenticationCodes(email, "")
+	return true
+}
+
+func (repository accountsRepository) UpdatePasswordResetCode(email string, resetPassCode string) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+	repository.store.insertAuthenticationCode(email, resetPassCode, "RESET_PASSWORD")
+	return nil
+}
+
+func (repository accountsRepository) ComparePasswordResetCode(email string, code string) bool {
+	store := repository.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if !store.compareAuthenticationCode(email, code, "RESET_PASSWORD") {
+		return false
+	}
+
+	store.deleteAuthenticationCodes(email, "RESET_PASSWORD")
+	return true
+}
+
+func (repository accountsRepository) UpdatePassword(ctx context.Context, email string, password string) error {
+	store := repository.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	userId, ok := store.getUserIdByEmail(email)
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	user := store.Users[userId]
+	user.Password = password
+	store.Users[userId] = user
+
+	// The password hashes are not stored in the audit log
+	store.record(ctx, interfaces.AuditEvent{
+		ActorId:  userId,
+		UserId:   userId,
+		Action:   "user.update_password",
+		Entity:   "users",
+		EntityId: userId,
+	})
+
+	return nil
+}
+
+func (repository accountsRepository) GetUserByIdentity(provider string, subject string) (interfaces.User, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	for _, user := range repository.store.Users {
+		for _, identity := range user.Identities {
+			if identity.Provider == provider && identity.Subject == subject {
+				return user, nil
+			}
+		}
+	}
+
+	return interfaces.User{}, mongo.ErrNoDocuments
+}
+
+func (repository accountsRepository) LinkUserIdentity(ctx context.Context, user interfaces.User, identity interfaces.UserIdentity) error {
+	store := repository.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	// The verification state is checked again, so the password is not kept if the account changed meanwhile
+	current, ok := store.Users[user.Id]
+	if !ok || current.IsVerified != user.IsVerified {
+		return mongo.ErrNoDocuments
+	}
+
+	current.Identities = append(append([]interfaces.UserIdentity{}, current.Identities...), identity)
+	current.IsVerified = true
+
+	if !user.IsVerified {
+		current.Password = ""
+		store.deleteAuthenticationCodes(user.Email, "")
+	}
+
+	store.Users[user.Id] = current
+	store.record(ctx, interfaces.AuditEvent{
+		ActorId:  user.Id,
+		UserId:   user.Id,
+		Action:   "user.link_identity",
+		Entity:   "users",
+		EntityId: user.Id,
+		After:    bson.M{"identity": identity, "password_removed": !user.IsVerified},
+	})
+
+	return nil
+}
+
+func (repository accountsRepository) InsertOIDCState(state interfaces.OIDCState) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	state.Id = primitive.NewObjectID()
+	repository.store.OIDCStates = append(repository.store.OIDCStates, state)
+	return nil
+}
+
+func (repository accountsRepository) PopOIDCState(provider string, state string) (interfaces.OIDCState, error) {
+	store := repository.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for index, current := range store.OIDCStates {
+		if current.Provider != provider || current.State != state {
+			continue
+		}
+
+		store.OIDCStates = append(append([]interfaces.OIDCState{}, store.OIDCStates[:index]...), store.OIDCStates[index+1:]...)
+
+		// Expired states are treated as if they didn't exist
+		if !time.Now().Before(time.Unix(current.ExpiresAt, 0)) {
+			return interfaces.OIDCState{}, mongo.ErrNoDocuments
+		}
+
+		return current, nil
+	}
+
+	return interfaces.OIDCState{}, mongo.ErrNoDocuments
+}
+
+// ## Mfa
+
+// recordMfaEvent "private" function to audit a change on the two-factor authentication settings. The store should
+// be locked
+func (store *Store) recordMfaEvent(ctx context.Context, userId primitive.ObjectID, action string) {
+	store.record(ctx, interfaces.AuditEvent{
+		ActorId:  userId,
+		UserId:   userId,
+		Action:   action,
+		Entity:   "users",
+		EntityId: userId,
+	})
+}
+
+func (repository mfaRepository) SetUserMfaPendingSecret(userId primitive.ObjectID, secret string) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	if user, ok := repository.store.Users[userId]; ok {
+		user.Mfa.PendingSecret = secret
+		user.Mfa.FailedAttempts = 0
+		repository.store.Users[userId] = user
+	}
+
+	return nil
+}
+
+func (repository mfaRepository) EnableUserMfa(ctx context.Context, userId primitive.ObjectID, secret string, recoveryCodes []string, usedStep int64) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	user, ok := repository.store.Users[userId]
+	if !ok || user.Mfa.PendingSecret != secret {
+		return errors.New("The pending authenticator has changed")
+	}
+
+	user.Mfa = interfaces.UserMfa{
+		Enabled:       true,
+		Secret:        secret,
+		RecoveryCodes: append([]string{}, recoveryCodes...),
+		LastUsedStep:  usedStep,
+	}
+
+	repository.store.Users[userId] = user
+	repository.store.recordMfaEvent(ctx, userId, "mfa.enable")
+	return nil
+}
+
+func (repository mfaRepository) DisableUserMfa(ctx context.Context, userId primitive.ObjectID) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	if user, ok := repository.store.Users[userId]; ok {
+		user.Mfa = interfaces.UserMfa{}
+		repository.store.Users[userId] = user
+	}
+
+	repository.store.recordMfaEvent(ctx, userId, "mfa.disable")
+	return nil
+}
+
+func (repository mfaRepository) UseUserMfaStep(userId primitive.ObjectID, step int64) (bool, error) {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	user, ok := repository.store.Users[userId]
+	if !ok || user.Mfa.LastUsedStep >= step {
+		return false, nil
+	}
+
+	user.Mfa.LastUsedStep = step
+	repository.store.Users[userId] = user
+	return true, nil
+}
+
+func (repository mfaRepository) UseUserMfaRecoveryCode(ctx context.Context, userId primitive.ObjectID, codeHash string) (bool, error) {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	user, ok := repository.store.Users[userId]
+	if !ok {
+		return false, nil
+	}
+
+	codes := []string{}
+	for _, code := range user.Mfa.RecoveryCodes {
+		if code != codeHash {
+			codes = append(codes, code)
+		}
+	}
+
+	if len(codes) == len(user.Mfa.RecoveryCodes) {
+		return false, nil
+	}
+
+	user.Mfa.RecoveryCodes = codes
+	repository.store.Users[userId] = user
+	repository.store.recordMfaEvent(ctx, userId, "mfa.use_recovery_code")
+	return true, nil
+}
+
+func (repository mfaRepository) StartUserMfaChallenge(userId primitive.ObjectID, challengeId string) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	if user, ok := repository.store.Users[userId]; ok {
+		user.Mfa.ChallengeId = challengeId
+		user.Mfa.FailedAttempts = 0
+		repository.store.Users[userId] = user
+	}
+
+	return nil
+}
+
+func (repository mfaRepository) EndUserMfaChallenge(userId primitive.ObjectID, challengeId string) (bool, error) {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	user, ok := repository.store.Users[userId]
+	if !ok || user.Mfa.ChallengeId != challengeId {
+		return false, nil
+	}
+
+	user.Mfa.ChallengeId = ""
+	user.Mfa.FailedAttempts = 0
+	repository.store.Users[userId] = user
+	return true, nil
+}
+
+func (repository mfaRepository) RecordUserMfaChallengeFailure(ctx context.Context, userId primitive.ObjectID, challengeId string, maxAttempts int) (bool, error) {
+	return repository.recordMfaFailure(ctx, userId, maxAttempts, func(mfa *interfaces.UserMfa) *string {
+		if mfa.ChallengeId != challengeId {
+			return nil
+		}
+
+		return &mfa.ChallengeId
+	})
+}
+
+func (repository mfaRepository) RecordUserMfaPendingFailure(ctx context.Context, userId primitive.ObjectID, secret string, maxAttempts int) (bool, error) {
+	return repository.recordMfaFailure(ctx, userId, maxAttempts, func(mfa *interfaces.UserMfa) *string {
+		if mfa.PendingSecret != secret {
+			return nil
+		}
+
+		return &mfa.PendingSecret
+	})
+}
+
+func (repository mfaRepository) RecordUserMfaActionFailure(ctx context.Context, userId primitive.ObjectID, maxAttempts int, lockUntil int64) (bool, error) {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	// The authenticator was already disabled
+	user, ok := repository.store.Users[userId]
+	if !ok || !user.Mfa.Enabled {
+		return false, nil
+	}
+
+	user.Mfa.ActionFailedAttempts++
+	locked := user.Mfa.ActionFailedAttempts >= maxAttempts
+
+	if locked {
+		user.Mfa.LockedUntil = lockUntil
+		user.Mfa.ActionFailedAttempts = 0
+	}
+
+	repository.store.Users[userId] = user
+
+	if locked {
+		repository.store.recordMfaEvent(ctx, userId, "mfa.locked")
+	}
+
+	return locked, nil
+}
+
+// recordMfaFailure "private" function to increment the failed attempts while the field (returned by getField, nil if
+// it changed) has the expected value and remove the field when the attempts reach the maximum
+func (repository mfaRepository) recordMfaFailure(ctx context.Context, userId primitive.ObjectID, maxAttempts int, getField func(mfa *interfaces.UserMfa) *string) (bool, error) {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	// The challenge or the pending authenticator was already removed
+	user, ok := repository.store.Users[userId]
+	field := getField(&user.Mfa)
+	if !ok || field == nil {
+		return true, nil
+	}
+
+	user.Mfa.FailedAttempts++
+	removed := user.Mfa.FailedAttempts >= maxAttempts
+
+	if removed {
+		*field = ""
+		user.Mfa.FailedAttempts = 0
+	}
+
+	repository.store.Users[userId] = user
+
+	if removed {
+		repository.store.recordMfaEvent(ctx, userId, "mfa.too_many_failures")
+	}
+
+	return removed, nil
+}
+
+// ## Moderation
+
+func (repository moderationRepository) GetUserBan(userId string) (*interfaces.UserSanction, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	user, err := repository.store.getUser(userId)
+	return user.Ban, err
+}
+
+func (repository moderationRepository) SetUserSanction(ctx context.Context, userId primitive.ObjectID, field string, sanction interfaces.UserSanction) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	user, ok := repository.store.Users[userId]
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	before := user.Ban
+	if field == "ban" {
+		user.Ban = &sanction
+	} else {
+		before = user.Mute
+		user.Mute = &sanction
+	}
+
+	var beforeSanction interface{}
+	if before != nil {
+		beforeSanction = *before
+	}
+
+	repository.store.Users[userId] = user
+	repository.store.record(ctx, interfaces.AuditEvent{
+		UserId:   userId,
+		Action:   "user." + field,
+		Entity:   "users",
+		EntityId: userId,
+		Before:   beforeSanction,
+		After:    sanction,
+	})
+
+	return nil
+}
+
+func (repository moderationRepository) RemoveUserSanction(ctx context.Context, userId primitive.ObjectID, field string) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	user, ok := repository.store.Users[userId]
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	before := user.Ban
+	if field == "ban" {
+		user.Ban = nil
+	} else {
+		before = user.Mute
+		user.Mute = nil
+	}
+
+	var beforeSanction interface{}
+	if before != nil {
+		beforeSanction = *before
+	}
+
+	repository.store.Users[userId] = user
+	repository.store.record(ctx, interfaces.AuditEvent{
+		UserId:   userId,
+		Action:   "user.un" + field,
+		Entity:   "users",
+		EntityId: userId,
+		Before:   beforeSanction,
+	})
+
+	return nil
+}
+
+func (repository moderationRepository) SearchUsers(text string, limit int64) ([]interfaces.User, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	users := []interfaces.User{}
+	text = strings.ToLower(text)
+
+	for _, user := range repository.store.Users {
+		if strings.Contains(strings.ToLower(user.Username), text) || strings.Contains(strings.ToLower(user.Email), text) {
+			users = append(users, user)
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+
+	if int64(len(users)) > limit {
+		users = users[:limit]
+	}
+
+	return users, nil
+}
+
+func (repository moderationRepository) ReleaseUserBusyLoomies(ctx context.Context, userId primitive.ObjectID, loomiesIds []primitive.ObjectID) (int64, error) {
+	store := repository.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	// If no loomies are given, all the busy loomies that are not protecting a gym are released
+	protectors := []primitive.ObjectID{}
+	for _, gym := range store.Gyms {
+		if gym.Owner == userId {
+			protectors = append(protectors, gym.Protectors...)
+		}
+	}
+
+	released := int64(0)
+	for id, loomie := range store.CaughtLoomies {
+		if loomie.Owner != userId || !loomie.IsBusy {
+			continue
+		}
+
+		if (len(loomiesIds) > 0 && !containsId(loomiesIds, id)) || (len(loomiesIds) == 0 && containsId(protectors, id)) {
+			continue
+		}
+
+		loomie.IsBusy = false
+		store.CaughtLoomies[id] = loomie
+		released++
+	}
+
+	store.record(ctx, interfaces.AuditEvent{
+		UserId: userId,
+		Action: "loomie.release",
+		Entity: "caught_loomies",
+		After:  bson.M{"loomies": loomiesIds, "released": released},
+	})
+
+	return released, nil
+}
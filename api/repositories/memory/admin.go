@@ -0,0 +1,170 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/configuration"
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ## Game settings
+
+// getSortedGameSettingsVersions "private" function to get the versions from the newest to the oldest. The store
+// should be locked
+func (store *Store) getSortedGameSettingsVersions() []interfaces.GameSettingsVersion {
+	versions := append([]interfaces.GameSettingsVersion{}, store.GameSettingsVersions...)
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Version > versions[j].Version
+	})
+
+	return versions
+}
+
+func (repository gameSettingsRepository) GetGameSettingsVersions(limit int64) ([]interfaces.GameSettingsVersion, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	versions := repository.store.getSortedGameSettingsVersions()
+	if limit > 0 && int64(len(versions)) > limit {
+		versions = versions[:limit]
+	}
+
+	return versions, nil
+}
+
+// publishGameSettings "private" function to insert a new version and start using it. The store should be locked
+func (store *Store) publishGameSettings(ctx context.Context, settings configuration.TGameBalance, comment string, createdBy primitive.ObjectID, rolledBackTo int) (interfaces.GameSettingsVersion, error) {
+	var previous interfaces.GameSettingsVersion
+	if versions := store.getSortedGameSettingsVersions(); len(versions) > 0 {
+		previous = versions[0]
+	}
+
+	document := interfaces.GameSettingsVersion{
+		Id:           primitive.NewObjectID(),
+		Version:      previous.Version + 1,
+		Settings:     settings,
+		Comment:      comment,
+		CreatedBy:    createdBy,
+		RolledBackTo: rolledBackTo,
+		CreatedAt:    time.Now().Unix(),
+	}
+
+	store.GameSettingsVersions = append(store.GameSettingsVersions, document)
+
+	action := "game_settings.publish"
+	if rolledBackTo > 0 {
+		action = "game_settings.rollback"
+	}
+
+	store.record(ctx, interfaces.AuditEvent{
+		ActorId:  createdBy,
+		Action:   action,
+		Entity:   "game_settings",
+		EntityId: document.Id,
+		Before:   previous.Settings,
+		After:    settings,
+	})
+
+	if err := store.balance.Set(document.Version, document.Settings); err != nil {
+		return document, err
+	}
+
+	return document, nil
+}
+
+func (repository gameSettingsRepository) PublishGameSettings(ctx context.Context, settings configuration.TGameBalance, comment string, createdBy primitive.ObjectID, rolledBackTo int) (interfaces.GameSettingsVersion, error) {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+	return repository.store.publishGameSettings(ctx, settings, comment, createdBy, rolledBackTo)
+}
+
+func (repository gameSettingsRepository) RollbackGameSettings(ctx context.Context, version int, comment string, createdBy primitive.ObjectID) (interfaces.GameSettingsVersion, error) {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	for _, target := range repository.store.GameSettingsVersions {
+		if target.Version != version {
+			continue
+		}
+
+		if comment == "" {
+			comment = fmt.Sprintf("Rollback to version %d", version)
+		}
+
+		return repository.store.publishGameSettings(ctx, target.Settings, comment, createdBy, version)
+	}
+
+	return interfaces.GameSettingsVersion{}, mongo.ErrNoDocuments
+}
+
+// ## Jobs
+
+func (repository jobsRepository) GetJobRuns(job string, limit int64) ([]interfaces.JobRun, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	runs := []interfaces.JobRun{}
+	for _, run := range repository.store.JobRuns {
+		if job == "" || run.Job == job {
+			runs = append(runs, run)
+		}
+	}
+
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].StartedAt > runs[j].StartedAt
+	})
+
+	if limit > 0 && int64(len(runs)) > limit {
+		runs = runs[:limit]
+	}
+
+	return runs, nil
+}
+
+// ## Audit
+
+func (repository auditRepository) FindEvents(filter interfaces.AuditEventsFilter) ([]interfaces.AuditEvent, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	events := []interfaces.AuditEvent{}
+	for _, event := range repository.store.AuditEvents {
+		if !filter.UserId.IsZero() && event.ActorId != filter.UserId && event.UserId != filter.UserId {
+			continue
+		}
+
+		if filter.Entity != "" && event.Entity != filter.Entity {
+			continue
+		}
+
+		if !filter.EntityId.IsZero() && event.EntityId != filter.EntityId {
+			continue
+		}
+
+		if (filter.From > 0 && event.CreatedAt < filter.From) || (filter.To > 0 && event.CreatedAt > filter.To) {
+			continue
+		}
+
+		events = append(events, event)
+	}
+
+	// The events are recorded in order, so the newest ones are the last of the list
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].CreatedAt > events[j].CreatedAt
+	})
+
+	if filter.Limit > 0 && int64(len(events)) > filter.Limit {
+		events = events[:filter.Limit]
+	}
+
+	return events, nil
+}
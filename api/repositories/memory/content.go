@@ -0,0 +1,412 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var errUnknownContent = errors.New("unknown content collection")
+
+// ## Content
+
+// toContentDocument "private" function to convert a typed document to a generic one, so the fields can be read by
+// their bson name like in the mongo collections
+func toContentDocument(document interface{}) (bson.M, error) {
+	data, err := bson.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+
+	var contentDocument bson.M
+	err = bson.Unmarshal(data, &contentDocument)
+	return contentDocument, err
+}
+
+// getContentDocuments "private" function to get the generic documents of the given collection. The store should be
+// locked
+func (store *Store) getContentDocuments(collection string) (map[primitive.ObjectID]bson.M, error) {
+	typed := map[primitive.ObjectID]interface{}{}
+
+	switch collection {
+	case repositories.BaseLoomiesContent:
+		for id, document := range store.BaseLoomies {
+			typed[id] = document
+		}
+	case repositories.ItemsContent:
+		for id, document := range store.Items {
+			typed[id] = document
+		}
+	case repositories.LoomballsContent:
+		for id, document := range store.Loomballs {
+			typed[id] = document
+		}
+	case repositories.LoomieTypesContent:
+		for id, document := range store.LoomieTypes {
+			typed[id] = document
+		}
+	case repositories.LoomieRaritiesContent:
+		for id, document := range store.LoomieRarities {
+			typed[id] = document
+		}
+	default:
+		return nil, errUnknownContent
+	}
+
+	documents := map[primitive.ObjectID]bson.M{}
+	for id, document := range typed {
+		contentDocument, err := toContentDocument(document)
+		if err != nil {
+			return nil, err
+		}
+
+		documents[id] = contentDocument
+	}
+
+	return documents, nil
+}
+
+// setContentDocument "private" function to decode the document into the type of the given collection and save it
+// with the given id. The store should be locked
+func (store *Store) setContentDocument(collection string, id primitive.ObjectID, document interface{}) error {
+	data, err := bson.Marshal(document)
+	if err != nil {
+		return err
+	}
+
+	switch collection {
+	case repositories.BaseLoomiesContent:
+		var decoded interfaces.BaseLoomies
+		err = bson.Unmarshal(data, &decoded)
+		decoded.Id = id
+		store.BaseLoomies[id] = decoded
+	case repositories.ItemsContent:
+		var decoded interfaces.Item
+		err = bson.Unmarshal(data, &decoded)
+		decoded.Id = id
+		store.Items[id] = decoded
+	case repositories.LoomballsContent:
+		var decoded interfaces.Loomball
+		err = bson.Unmarshal(data, &decoded)
+		decoded.Id = id
+		store.Loomballs[id] = decoded
+	case repositories.LoomieTypesContent:
+		var decoded interfaces.LoomieType
+		err = bson.Unmarshal(data, &decoded)
+		decoded.Id = id
+		store.LoomieTypes[id] = decoded
+	case repositories.LoomieRaritiesContent:
+		var decoded interfaces.LoomieRarity
+		err = bson.Unmarshal(data, &decoded)
+		decoded.Id = id
+		store.LoomieRarities[id] = decoded
+	default:
+		return errUnknownContent
+	}
+
+	return err
+}
+
+// deleteContentDocument "private" function to delete the document with the given id. The store should be locked
+func (store *Store) deleteContentDocument(collection string, id primitive.ObjectID) {
+	switch collection {
+	case repositories.BaseLoomiesContent:
+		delete(store.BaseLoomies, id)
+	case repositories.ItemsContent:
+		delete(store.Items, id)
+	case repositories.LoomballsContent:
+		delete(store.Loomballs, id)
+	case repositories.LoomieTypesContent:
+		delete(store.LoomieTypes, id)
+	case repositories.LoomieRaritiesContent:
+		delete(store.LoomieRarities, id)
+	}
+}
+
+// isContentValueLess "private" function to compare the values of a sort field (numbers or strings)
+func isContentValueLess(a interface{}, b interface{}) bool {
+	toNumber := func(value interface{}) (float64, bool) {
+		switch number := value.(type) {
+		case int32:
+			return float64(number), true
+		case int64:
+			return float64(number), true
+		case float64:
+			return number, true
+		}
+
+		return 0, false
+	}
+
+	numberA, isNumberA := toNumber(a)
+	numberB, isNumberB := toNumber(b)
+	if isNumberA && isNumberB {
+		return numberA < numberB
+	}
+
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
+
+func (repository contentRepository) GetContentDocuments(collection string, sortBy string, results interface{}) error {
+	repository.store.mutex.RLock()
+	documents, err := repository.store.getContentDocuments(collection)
+	repository.store.mutex.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	sorted := []bson.M{}
+	for _, document := range documents {
+		sorted = append(sorted, document)
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return isContentValueLess(sorted[i][sortBy], sorted[j][sortBy])
+	})
+
+	// Decode the documents into the results like the mongo cursors
+	data, err := bson.Marshal(bson.M{"documents": sorted})
+	if err != nil {
+		return err
+	}
+
+	return bson.Raw(data).Lookup("documents").Unmarshal(results)
+}
+
+func (repository contentRepository) InsertContentDocument(ctx context.Context, collection string, document interface{}) (primitive.ObjectID, error) {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	id := primitive.NewObjectID()
+	if err := repository.store.setContentDocument(collection, id, document); err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	repository.store.record(ctx, interfaces.AuditEvent{
+		Action:   "content.create",
+		Entity:   collection,
+		EntityId: id,
+		After:    document,
+	})
+
+	return id, nil
+}
+
+func (repository contentRepository) ReplaceContentDocument(ctx context.Context, collection string, id primitive.ObjectID, document interface{}) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	documents, err := repository.store.getContentDocuments(collection)
+	if err != nil {
+		return err
+	}
+
+	before, ok := documents[id]
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	if err := repository.store.setContentDocument(collection, id, document); err != nil {
+		return err
+	}
+
+	repository.store.record(ctx, interfaces.AuditEvent{
+		Action:   "content.update",
+		Entity:   collection,
+		EntityId: id,
+		Before:   before,
+		After:    document,
+	})
+
+	return nil
+}
+
+func (repository contentRepository) DeleteContentDocument(ctx context.Context, collection string, id primitive.ObjectID) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	documents, err := repository.store.getContentDocuments(collection)
+	if err != nil {
+		return err
+	}
+
+	before, ok := documents[id]
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	repository.store.deleteContentDocument(collection, id)
+	repository.store.record(ctx, interfaces.AuditEvent{
+		Action:   "content.delete",
+		Entity:   collection,
+		EntityId: id,
+		Before:   before,
+	})
+
+	return nil
+}
+
+// isContentFieldInUse "private" function to check if other document has the given value in the given field. The
+// store should be locked
+func (store *Store) isContentFieldInUse(collection string, field string, value interface{}, exceptId primitive.ObjectID) (bool, error) {
+	documents, err := store.getContentDocuments(collection)
+	if err != nil {
+		return false, err
+	}
+
+	for id, document := range documents {
+		if id != exceptId && fmt.Sprint(document[field]) == fmt.Sprint(value) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (repository contentRepository) IsContentFieldInUse(collection string, field string, value interface{}, exceptId primitive.ObjectID) (bool, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+	return repository.store.isContentFieldInUse(collection, field, value, exceptId)
+}
+
+func (repository contentRepository) IsRewardSerialInUse(serial int, exceptId primitive.ObjectID) (bool, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	inUse, err := repository.store.isContentFieldInUse(repositories.ItemsContent, "serial", serial, exceptId)
+	if err != nil || inUse {
+		return inUse, err
+	}
+
+	return repository.store.isContentFieldInUse(repositories.LoomballsContent, "serial", serial, exceptId)
+}
+
+func (repository contentRepository) IsRewardInUse(rewardId primitive.ObjectID) (bool, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	for _, user := range repository.store.Users {
+		if containsInventoryItem(user.Items, rewardId) {
+			return true, nil
+		}
+	}
+
+	for _, gym := range repository.store.Gyms {
+		for _, reward := range append(append([]interfaces.GymRewardItem{}, gym.CurrentPlayersRewards...), gym.CurrentOwnerRewards...) {
+			if reward.RewardId == rewardId {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+func (repository contentRepository) GetLoomieTypesByNames(names []string) ([]interfaces.LoomieType, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	loomieTypes := []interfaces.LoomieType{}
+	for _, loomieType := range repository.store.LoomieTypes {
+		for _, name := range names {
+			if loomieType.Name == name {
+				loomieTypes = append(loomieTypes, loomieType)
+				break
+			}
+		}
+	}
+
+	return loomieTypes, nil
+}
+
+func (repository contentRepository) GetLoomieTypeDetailsByName(typeName string) (interfaces.PopulatedLoomieType, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	var loomieType interfaces.PopulatedLoomieType
+	for _, current := range repository.store.LoomieTypes {
+		if current.Name != typeName {
+			continue
+		}
+
+		loomieType.Id = current.Id
+		loomieType.Name = current.Name
+		for _, strongAgainst := range current.StrongAgainst {
+			if other, ok := repository.store.LoomieTypes[strongAgainst]; ok {
+				loomieType.StrongAgainst = append(loomieType.StrongAgainst, other.Name)
+			}
+		}
+	}
+
+	return loomieType, nil
+}
+
+func (repository contentRepository) GetLoomieRarityByName(name string) (interfaces.LoomieRarity, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	for _, rarity := range repository.store.LoomieRarities {
+		if rarity.Name == name {
+			return rarity, nil
+		}
+	}
+
+	return interfaces.LoomieRarity{}, mongo.ErrNoDocuments
+}
+
+func (repository contentRepository) IsLoomieTypeInUse(typeId primitive.ObjectID) (bool, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	for _, loomie := range repository.store.BaseLoomies {
+		if containsId(loomie.Types, typeId) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (repository contentRepository) IsLoomieRarityInUse(rarityId primitive.ObjectID) (bool, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	for _, loomie := range repository.store.BaseLoomies {
+		if loomie.Rarity == rarityId {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (repository contentRepository) RemoveLoomieTypeReferences(ctx context.Context, typeId primitive.ObjectID) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	updatedTypes := 0
+	for id, loomieType := range repository.store.LoomieTypes {
+		if containsId(loomieType.StrongAgainst, typeId) {
+			loomieType.StrongAgainst = withoutIds(loomieType.StrongAgainst, []primitive.ObjectID{typeId})
+			repository.store.LoomieTypes[id] = loomieType
+			updatedTypes++
+		}
+	}
+
+	repository.store.record(ctx, interfaces.AuditEvent{
+		Action:   "content.remove_references",
+		Entity:   repositories.LoomieTypesContent,
+		EntityId: typeId,
+		After:    bson.M{"updated_types": updatedTypes},
+	})
+
+	return nil
+}
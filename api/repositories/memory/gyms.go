@@ -0,0 +1,428 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// isGymOwnerVisible "private" function to check if the player can see the gym owner. The owner is hidden to the
+// blocked players and, if the owner hides the gyms in the trainer card, to the players that aren't friends. The store
+// should be locked
+func (store *Store) isGymOwnerVisible(owner interfaces.User, playerId primitive.ObjectID) bool {
+	if owner.Id == playerId {
+		return true
+	}
+
+	player, ok := store.Users[playerId]
+	if !ok || utils.IsBlockedBetween(owner, player) {
+		return false
+	}
+
+	for _, field := range owner.Profile.HiddenFields {
+		if field == "gyms" {
+			return store.areFriends(owner.Id, playerId)
+		}
+	}
+
+	return true
+}
+
+// ## Gyms
+
+func (repository gymsRepository) GetGymFromID(id string) (interfaces.Gym, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	mongoId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return interfaces.Gym{}, err
+	}
+
+	gym, ok := repository.store.Gyms[mongoId]
+	if !ok {
+		return interfaces.Gym{}, mongo.ErrNoDocuments
+	}
+
+	return gym, nil
+}
+
+func (repository gymsRepository) GetPopulatedGymFromId(gymId primitive.ObjectID, userId primitive.ObjectID) (interfaces.PopulatedGym, error) {
+	store := repository.store
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	gym, ok := store.Gyms[gymId]
+	if !ok {
+		return interfaces.PopulatedGym{}, fmt.Errorf("EMPTY_RESULTS")
+	}
+
+	aux := interfaces.PopulatedGymAux{Id: gym.Id, Name: gym.Name, RewardsClaimedBy: gym.RewardsClaimedBy}
+	owner, hasOwner := store.Users[gym.Owner]
+	if hasOwner {
+		aux.Owner = []interfaces.User{owner}
+	}
+
+	for _, id := range gym.Protectors {
+		if loomie, ok := store.CaughtLoomies[id]; ok {
+			aux.Protectors = append(aux.Protectors, loomie)
+		}
+	}
+
+	populated := *aux.ToPopulatedGym(userId)
+	populated.WasRewardClaimed = containsId(gym.RewardsClaimedBy, userId)
+
+	// The owner id is kept to notify the owner, but the username is hidden according to the privacy settings
+	if hasOwner && !store.isGymOwnerVisible(owner, userId) {
+		populated.Owner = ""
+	}
+
+	return populated, nil
+}
+
+func (repository gymsRepository) GetGymsByOwner(ownerId primitive.ObjectID) ([]interfaces.Gym, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	gyms := []interfaces.Gym{}
+	for _, gym := range repository.store.Gyms {
+		if gym.Owner == ownerId {
+			gyms = append(gyms, gym)
+		}
+	}
+
+	return gyms, nil
+}
+
+func (repository gymsRepository) CountGymsByOwner(ownerId primitive.ObjectID) (int64, error) {
+	gyms, err := repository.GetGymsByOwner(ownerId)
+	return int64(len(gyms)), err
+}
+
+func (repository gymsRepository) UpdateGymProtectors(ctx context.Context, gymId primitive.ObjectID, protectorsIds []primitive.ObjectID) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	gym, ok := repository.store.Gyms[gymId]
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	before := gym.Protectors
+	gym.Protectors = append([]primitive.ObjectID{}, protectorsIds...)
+	repository.store.Gyms[gymId] = gym
+	repository.store.record(ctx, interfaces.AuditEvent{
+		UserId:   gym.Owner,
+		Action:   "gym.update_protectors",
+		Entity:   "gyms",
+		EntityId: gymId,
+		Before:   bson.M{"protectors": before},
+		After:    bson.M{"protectors": protectorsIds},
+	})
+
+	return nil
+}
+
+func (repository gymsRepository) ClaimGymReward(ctx context.Context, gym interfaces.Gym, userId primitive.ObjectID, rewards []interfaces.GymRewardItem) error {
+	store := repository.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	current, ok := store.Gyms[gym.Id]
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	if containsId(current.RewardsClaimedBy, userId) {
+		return repositories.ErrRewardAlreadyClaimed
+	}
+
+	if _, ok := store.Users[userId]; !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	for _, reward := range rewards {
+		store.addItem(ctx, userId, reward)
+	}
+
+	current.RewardsClaimedBy = withId(current.RewardsClaimedBy, userId)
+	store.Gyms[gym.Id] = current
+	store.record(ctx, interfaces.AuditEvent{
+		UserId:   userId,
+		Action:   "gym.claim_reward",
+		Entity:   "gyms",
+		EntityId: gym.Id,
+	})
+
+	return nil
+}
+
+func (repository gymsRepository) TakeOverGym(ctx context.Context, gymId primitive.ObjectID, newOwner primitive.ObjectID, newProtectors []primitive.ObjectID, oldProtectors []primitive.ObjectID, hadOwner bool) error {
+	store := repository.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	gym, ok := store.Gyms[gymId]
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	owner, ok := store.Users[newOwner]
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	// The new protectors leave the team of the new owner
+	owner.LoomieTeam = []primitive.ObjectID{}
+	store.Users[newOwner] = owner
+
+	// The old protectors go back to their owner or are removed if the gym was neutral
+	if hadOwner {
+		store.setLoomiesBusyState(ctx, oldProtectors, false)
+	} else {
+		store.deleteLoomies(ctx, oldProtectors)
+	}
+
+	before := gym
+	gym.Owner = newOwner
+	gym.Protectors = append([]primitive.ObjectID{}, newProtectors...)
+	store.Gyms[gymId] = gym
+	store.setLoomiesBusyState(ctx, newProtectors, true)
+	store.record(ctx, interfaces.AuditEvent{
+		UserId:   before.Owner,
+		Action:   "gym.update_owner",
+		Entity:   "gyms",
+		EntityId: gymId,
+		Before:   bson.M{"owner": before.Owner, "protectors": before.Protectors},
+		After:    bson.M{"owner": newOwner, "protectors": newProtectors},
+	})
+
+	return nil
+}
+
+// ## Zones
+
+func (repository zonesRepository) GetRegionFromCoordinates(coordinates interfaces.Coordinates) (interfaces.Region, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	for _, region := range repository.store.Regions {
+		if region.Contains(coordinates) {
+			return region, nil
+		}
+	}
+
+	return interfaces.Region{}, mongo.ErrNoDocuments
+}
+
+// getZone "private" function to get the zone of the region with the given coordinates. The store should be locked
+func (store *Store) getZone(regionId primitive.ObjectID, coordX int, coordY int) (interfaces.Zone, bool) {
+	coordinates := fmt.Sprintf("%v,%v", coordX, coordY)
+	for _, zone := range store.Zones {
+		if zone.Region == regionId && zone.Coordinates == coordinates {
+			return zone, true
+		}
+	}
+
+	return interfaces.Zone{}, false
+}
+
+func (repository zonesRepository) GetZoneFromCoordinates(regionId primitive.ObjectID, coordX int, coordY int) (interfaces.Zone, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	zone, ok := repository.store.getZone(regionId, coordX, coordY)
+	if !ok {
+		return interfaces.Zone{}, mongo.ErrNoDocuments
+	}
+
+	return zone, nil
+}
+
+func (repository zonesRepository) GetNearGyms(latitude float64, longitude float64) ([]interfaces.NearGymsRes, error) {
+	store := repository.store
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	var gyms []interfaces.NearGymsRes
+	player := interfaces.Coordinates{Latitude: latitude, Longitude: longitude}
+	radius := store.config.Game.VisibilityRadius
+
+	for _, gym := range store.Gyms {
+		if utils.IsWithinDistance(interfaces.Coordinates{Latitude: gym.Latitude, Longitude: gym.Longitude}, player, radius) {
+			gyms = append(gyms, *gym.ToNearGymsRes())
+		}
+	}
+
+	return gyms, nil
+}
+
+func (repository zonesRepository) GetUserLocation(user interfaces.User) *time.Location {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	return repository.store.getUserLocation(user)
+}
+
+// getUserLocation "private" function to get the timezone of the region where the user was last seen, UTC if it's
+// unknown. The store should be locked
+func (store *Store) getUserLocation(user interfaces.User) *time.Location {
+	name := utils.GetZoneKeyRegion(user.LastSeenZone)
+
+	for _, region := range store.Regions {
+		if name != "" && region.Name == name {
+			return utils.GetLocation(region.Timezone)
+		}
+	}
+
+	return time.UTC
+}
+
+func (repository zonesRepository) GetRegions() ([]interfaces.Region, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	regions := []interfaces.Region{}
+	for _, region := range repository.store.Regions {
+		regions = append(regions, region)
+	}
+
+	sort.Slice(regions, func(i, j int) bool {
+		return regions[i].Name < regions[j].Name
+	})
+
+	return regions, nil
+}
+
+func (repository zonesRepository) CreateRegion(ctx context.Context, region interfaces.Region) (interfaces.Region, error) {
+	store := repository.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for _, other := range store.Regions {
+		if other.Name == region.Name {
+			return region, repositories.ErrRegionExists
+		}
+
+		if other.Overlaps(region.Bounds) {
+			return region, repositories.ErrRegionOverlaps
+		}
+	}
+
+	// The spawn table must only have existing base loomies
+	for _, spawn := range region.SpawnTable {
+		found := false
+		for _, loomie := range store.BaseLoomies {
+			found = found || loomie.Serial == spawn.Serial
+		}
+
+		if !found {
+			return region, repositories.ErrRegionUnknownLoomie
+		}
+	}
+
+	region.Id = primitive.NewObjectID()
+	region.CreatedAt = time.Now().Unix()
+	store.Regions[region.Id] = region
+	store.record(ctx, interfaces.AuditEvent{
+		Action:   "region.create",
+		Entity:   "regions",
+		EntityId: region.Id,
+		After:    region,
+	})
+
+	return region, nil
+}
+
+// ## Challenges
+
+// findChallenge "private" function to get the index of the challenge register or -1. The store should be locked
+func (store *Store) findChallenge(gymId primitive.ObjectID, playerId primitive.ObjectID) int {
+	for index, challenge := range store.Challenges {
+		if challenge.GymId == gymId && challenge.AttackerId == playerId {
+			return index
+		}
+	}
+
+	return -1
+}
+
+func (repository challengesRepository) GetActiveCombatByUserId(userId primitive.ObjectID) (interfaces.GymChallengesRegister, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	for _, challenge := range repository.store.Challenges {
+		if challenge.AttackerId == userId && challenge.IsActive {
+			return challenge, nil
+		}
+	}
+
+	return interfaces.GymChallengesRegister{}, mongo.ErrNoDocuments
+}
+
+func (repository challengesRepository) GetLastGymChallenge(gymId primitive.ObjectID, playerId primitive.ObjectID) (interfaces.GymChallengesRegister, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	index := repository.store.findChallenge(gymId, playerId)
+	if index < 0 {
+		return interfaces.GymChallengesRegister{}, mongo.ErrNoDocuments
+	}
+
+	return repository.store.Challenges[index], nil
+}
+
+func (repository challengesRepository) UpdateLastGymChallengeTimestamp(gymId primitive.ObjectID, playerId primitive.ObjectID) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	currentTimestamp := time.Now().Unix()
+	index := repository.store.findChallenge(gymId, playerId)
+
+	if index < 0 {
+		repository.store.Challenges = append(repository.store.Challenges, interfaces.GymChallengesRegister{
+			GymId:      gymId,
+			AttackerId: playerId,
+			Timestamp:  currentTimestamp,
+			IsActive:   true,
+		})
+
+		return nil
+	}
+
+	repository.store.Challenges[index].Timestamp = currentTimestamp
+	return nil
+}
+
+func (repository challengesRepository) FinishGymChallenge(gymId primitive.ObjectID, playerId primitive.ObjectID) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	if index := repository.store.findChallenge(gymId, playerId); index >= 0 {
+		repository.store.Challenges[index].IsActive = false
+	}
+
+	return nil
+}
+
+func (repository challengesRepository) GetUserCombats(userId primitive.ObjectID) ([]interfaces.GymChallengesRegister, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	combats := []interfaces.GymChallengesRegister{}
+	for _, challenge := range repository.store.Challenges {
+		if challenge.AttackerId == userId {
+			combats = append(combats, challenge)
+		}
+	}
+
+	return combats, nil
+}
@@ -0,0 +1,254 @@
+package memory
+
+import (
+	"context"
+	"errors"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// The errors messages are the same returned by the mongo repositories because the handlers compare them
+var (
+	errUserDoesNotOwnItem = errors.New("USER_DOES_NOT_OWN_ITEM")
+	errUnknownItem        = errors.New("ITEM_NOT_FOUND")
+)
+
+// addItem "private" function to add the item to the user inventory. The store should be locked
+func (store *Store) addItem(ctx context.Context, userId primitive.ObjectID, item interfaces.GymRewardItem) error {
+	user, ok := store.Users[userId]
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	items := append([]interfaces.InventoryItem{}, user.Items...)
+	found := false
+	currentQuantity := 0
+
+	for index := range items {
+		if items[index].ItemId == item.RewardId {
+			currentQuantity = items[index].ItemQuantity
+			item.RewardCollection = items[index].ItemCollection
+			items[index].ItemQuantity += item.RewardQuantity
+			found = true
+		}
+	}
+
+	if !found {
+		items = append(items, interfaces.InventoryItem{
+			ItemCollection: item.RewardCollection,
+			ItemId:         item.RewardId,
+			ItemQuantity:   item.RewardQuantity,
+		})
+	}
+
+	user.Items = items
+	store.Users[userId] = user
+
+	store.record(ctx, interfaces.AuditEvent{
+		UserId:   userId,
+		Action:   "inventory.add",
+		Entity:   item.RewardCollection,
+		EntityId: item.RewardId,
+		Before:   bson.M{"quantity": currentQuantity},
+		After:    bson.M{"quantity": currentQuantity + item.RewardQuantity},
+	})
+
+	return nil
+}
+
+// decrementItem "private" function to decrement the item quantity, the item is removed from the inventory when
+// there are no more units. The store should be locked
+func (store *Store) decrementItem(ctx context.Context, userId primitive.ObjectID, itemId primitive.ObjectID, quantity int) error {
+	user, ok := store.Users[userId]
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	items := []interfaces.InventoryItem{}
+	found := false
+
+	for _, item := range user.Items {
+		if item.ItemId == itemId {
+			found = true
+			currentQuantity := item.ItemQuantity
+			item.ItemQuantity -= quantity
+
+			if item.ItemQuantity < 0 {
+				item.ItemQuantity = 0
+			}
+
+			store.record(ctx, interfaces.AuditEvent{
+				UserId:   userId,
+				Action:   "inventory.remove",
+				Entity:   item.ItemCollection,
+				EntityId: itemId,
+				Before:   bson.M{"quantity": currentQuantity},
+				After:    bson.M{"quantity": item.ItemQuantity},
+			})
+
+			if item.ItemQuantity == 0 {
+				continue
+			}
+		}
+
+		items = append(items, item)
+	}
+
+	if !found {
+		return repositories.ErrItemNotFound
+	}
+
+	user.Items = items
+	store.Users[userId] = user
+	return nil
+}
+
+func (repository itemsRepository) GetItemsFromIds(ids []primitive.ObjectID) ([]interfaces.Item, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	items := []interfaces.Item{}
+	for _, id := range ids {
+		if item, ok := repository.store.Items[id]; ok {
+			items = append(items, item)
+		}
+	}
+
+	return items, nil
+}
+
+func (repository itemsRepository) GetLoomballsFromIds(ids []primitive.ObjectID) ([]interfaces.Loomball, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	loomballs := []interfaces.Loomball{}
+	for _, id := range ids {
+		if loomball, ok := repository.store.Loomballs[id]; ok {
+			loomballs = append(loomballs, loomball)
+		}
+	}
+
+	return loomballs, nil
+}
+
+func (repository itemsRepository) GetInventory(inventory []interfaces.InventoryItem) ([]interfaces.UserItemsRes, []interfaces.UserLoomballsRes, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	var items []interfaces.UserItemsRes
+	var loomballs []interfaces.UserLoomballsRes
+
+	for _, element := range inventory {
+		if element.ItemCollection == "items" {
+			if item, ok := repository.store.Items[element.ItemId]; ok {
+				items = append(items, interfaces.UserItemsRes{Id: item.Id, Name: item.Name, Serial: item.Serial, Description: item.Description, Target: item.Target, Is_combat_item: item.IsCombatItem, Quantity: element.ItemQuantity})
+			}
+		} else if loomball, ok := repository.store.Loomballs[element.ItemId]; ok {
+			loomballs = append(loomballs, interfaces.UserLoomballsRes{Id: loomball.Id, Name: loomball.Name, Serial: loomball.Serial, Quantity: element.ItemQuantity})
+		}
+	}
+
+	return items, loomballs, nil
+}
+
+func (repository itemsRepository) GetItemFromUserInventory(userId primitive.ObjectID, itemId primitive.ObjectID, ignoreCombatItems bool) (interfaces.PopulatedInventoryItem, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	user, ok := repository.store.Users[userId]
+	quantity := -1
+
+	for _, element := range user.Items {
+		if element.ItemId == itemId {
+			quantity = element.ItemQuantity
+		}
+	}
+
+	if !ok || quantity < 0 {
+		return interfaces.PopulatedInventoryItem{}, errUserDoesNotOwnItem
+	}
+
+	item, ok := repository.store.Items[itemId]
+	if !ok || (ignoreCombatItems && item.IsCombatItem) {
+		return interfaces.PopulatedInventoryItem{}, errUnknownItem
+	}
+
+	return interfaces.PopulatedInventoryItem{Id: item.Id, Name: item.Name, Serial: item.Serial, Quantity: quantity}, nil
+}
+
+func (repository itemsRepository) AddItemToUserInventory(ctx context.Context, userId primitive.ObjectID, item interfaces.GymRewardItem) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+	return repository.store.addItem(ctx, userId, item)
+}
+
+func (repository itemsRepository) DecrementItemFromUserInventory(ctx context.Context, userId primitive.ObjectID, itemId primitive.ObjectID, quantity int) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+	return repository.store.decrementItem(ctx, userId, itemId, quantity)
+}
+
+func (repository itemsRepository) IncrementItemFromUserInventory(ctx context.Context, userId primitive.ObjectID, itemId primitive.ObjectID, quantity int) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	// Nothing is incremented if the user doesn't have the item
+	user, ok := repository.store.Users[userId]
+	if !ok || !containsInventoryItem(user.Items, itemId) {
+		return nil
+	}
+
+	return repository.store.addItem(ctx, userId, interfaces.GymRewardItem{RewardId: itemId, RewardQuantity: quantity})
+}
+
+func (repository itemsRepository) GetRewardCollection(rewardId primitive.ObjectID) (string, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	if _, ok := repository.store.Items[rewardId]; ok {
+		return "items", nil
+	}
+
+	if _, ok := repository.store.Loomballs[rewardId]; ok {
+		return "loom_balls", nil
+	}
+
+	return "", mongo.ErrNoDocuments
+}
+
+// getRewardIdBySerial "private" function to get the id of an item or loomball from its serial. The store should be
+// locked
+func (store *Store) getRewardIdBySerial(rewardCollection string, serial int) (primitive.ObjectID, bool) {
+	if rewardCollection == "loom_balls" {
+		for id, loomball := range store.Loomballs {
+			if loomball.Serial == serial {
+				return id, true
+			}
+		}
+
+		return primitive.NilObjectID, false
+	}
+
+	for id, item := range store.Items {
+		if item.Serial == serial {
+			return id, true
+		}
+	}
+
+	return primitive.NilObjectID, false
+}
+
+// containsInventoryItem "private" function to check if the item is in the inventory
+func containsInventoryItem(inventory []interfaces.InventoryItem, itemId primitive.ObjectID) bool {
+	for _, item := range inventory {
+		if item.ItemId == itemId {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,400 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// toUserLoomie "private" function to populate the types and rarity names of the loomie. The store should be locked
+func (store *Store) toUserLoomie(loomie interfaces.CaughtLoomie) interfaces.UserLoomiesRes {
+	types := []string{}
+	for _, loomieType := range loomie.Types {
+		types = append(types, store.LoomieTypes[loomieType].Name)
+	}
+
+	return interfaces.UserLoomiesRes{
+		Id:         loomie.Id,
+		Serial:     loomie.Serial,
+		Name:       loomie.Name,
+		Types:      types,
+		Rarity:     store.LoomieRarities[loomie.Rarity].Name,
+		Hp:         loomie.HP,
+		Attack:     loomie.Attack,
+		IsBusy:     loomie.IsBusy,
+		Defense:    loomie.Defense,
+		Level:      loomie.Level,
+		Experience: loomie.Experience,
+	}
+}
+
+// insertLoomie "private" function to store a new caught loomie. The store should be locked
+func (store *Store) insertLoomie(ctx context.Context, loomie interfaces.CaughtLoomie) primitive.ObjectID {
+	if loomie.Id.IsZero() {
+		loomie.Id = primitive.NewObjectID()
+	}
+
+	if loomie.Types == nil {
+		loomie.Types = []primitive.ObjectID{}
+	}
+
+	store.CaughtLoomies[loomie.Id] = loomie
+
+	store.record(ctx, interfaces.AuditEvent{
+		UserId:   loomie.Owner,
+		Action:   "loomie.create",
+		Entity:   "caught_loomies",
+		EntityId: loomie.Id,
+		After:    loomie,
+	})
+
+	return loomie.Id
+}
+
+// setLoomiesBusyState "private" function to update the busy state of the loomies. The store should be locked
+func (store *Store) setLoomiesBusyState(ctx context.Context, loomiesIds []primitive.ObjectID, busy bool) {
+	for _, id := range loomiesIds {
+		if loomie, ok := store.CaughtLoomies[id]; ok {
+			loomie.IsBusy = busy
+			store.CaughtLoomies[id] = loomie
+		}
+	}
+
+	store.record(ctx, interfaces.AuditEvent{
+		Action: "loomie.update_busy_state",
+		Entity: "caught_loomies",
+		After:  bson.M{"loomies": loomiesIds, "is_busy": busy},
+	})
+}
+
+// deleteLoomies "private" function to remove the caught loomies. The store should be locked
+func (store *Store) deleteLoomies(ctx context.Context, loomiesIds []primitive.ObjectID) {
+	for _, id := range loomiesIds {
+		loomie, ok := store.CaughtLoomies[id]
+		if !ok {
+			continue
+		}
+
+		delete(store.CaughtLoomies, id)
+		store.record(ctx, interfaces.AuditEvent{
+			UserId:   loomie.Owner,
+			Action:   "loomie.delete",
+			Entity:   "caught_loomies",
+			EntityId: id,
+			Before:   loomie,
+		})
+	}
+}
+
+func (repository loomiesRepository) GetLoomiesByIds(ids []primitive.ObjectID, userId primitive.ObjectID) ([]interfaces.UserLoomiesRes, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	var loomies []interfaces.UserLoomiesRes
+
+	// Allow nil owner (At the beginning, the gym loomies doesn't have an owner)
+	for _, id := range ids {
+		loomie, ok := repository.store.CaughtLoomies[id]
+		if ok && (userId.IsZero() || loomie.Owner == userId) {
+			loomies = append(loomies, repository.store.toUserLoomie(loomie))
+		}
+	}
+
+	return loomies, nil
+}
+
+func (repository loomiesRepository) GetWildLoomieById(id string) (interfaces.WildLoomie, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	mongoId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return interfaces.WildLoomie{}, err
+	}
+
+	loomie, ok := repository.store.WildLoomies[mongoId]
+	if !ok {
+		return interfaces.WildLoomie{}, mongo.ErrNoDocuments
+	}
+
+	return loomie, nil
+}
+
+func (repository loomiesRepository) InsertInCaughtLoomies(ctx context.Context, loomie interfaces.CaughtLoomie) (primitive.ObjectID, error) {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+	return repository.store.insertLoomie(ctx, loomie), nil
+}
+
+func (repository loomiesRepository) CaptureWildLoomie(ctx context.Context, user interfaces.User, loomie interfaces.WildLoomie, loomballId primitive.ObjectID, wasCaptured bool) (primitive.ObjectID, error) {
+	store := repository.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	// Check everything before changing the documents, so nothing is changed if the capture fails
+	current, ok := store.Users[user.Id]
+	if !ok || !containsInventoryItem(current.Items, loomballId) {
+		return primitive.NilObjectID, repositories.ErrLoomballNotFound
+	}
+
+	wildLoomie, ok := store.WildLoomies[loomie.Id]
+	if wasCaptured && (!ok || containsId(wildLoomie.CapturedBy, user.Id)) {
+		return primitive.NilObjectID, repositories.ErrLoomieAlreadyCaught
+	}
+
+	store.decrementItem(ctx, user.Id, loomballId, 1)
+
+	if !wasCaptured {
+		return primitive.NilObjectID, nil
+	}
+
+	wildLoomie.CapturedBy = withId(wildLoomie.CapturedBy, user.Id)
+	store.WildLoomies[loomie.Id] = wildLoomie
+	store.record(ctx, interfaces.AuditEvent{
+		UserId:   user.Id,
+		Action:   "wild_loomie.capture",
+		Entity:   "wild_loomies",
+		EntityId: loomie.Id,
+	})
+
+	caughtLoomieId := store.insertLoomie(ctx, interfaces.CaughtLoomie{
+		Owner:      user.Id,
+		Serial:     loomie.Serial,
+		Name:       loomie.Name,
+		Types:      loomie.Types,
+		Rarity:     loomie.Rarity,
+		HP:         loomie.HP,
+		Attack:     loomie.Attack,
+		Defense:    loomie.Defense,
+		Level:      loomie.Level,
+		Experience: loomie.Experience,
+	})
+
+	current = store.Users[user.Id]
+	current.Loomies = withId(current.Loomies, caughtLoomieId)
+	store.Users[user.Id] = current
+	store.record(ctx, interfaces.AuditEvent{
+		UserId:   user.Id,
+		Action:   "user.add_loomie",
+		Entity:   "caught_loomies",
+		EntityId: caughtLoomieId,
+	})
+
+	return caughtLoomieId, nil
+}
+
+func (repository loomiesRepository) FuseLoomies(ctx context.Context, userId primitive.ObjectID, loomieToUpdate interfaces.UserLoomiesRes, loomieToDelete interfaces.UserLoomiesRes) error {
+	store := repository.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	loomie, ok := store.CaughtLoomies[loomieToUpdate.Id]
+	if !ok {
+		return errors.New("Error updating the first loomie")
+	}
+
+	before := loomie
+	loomie.HP = loomieToUpdate.Hp
+	loomie.Attack = loomieToUpdate.Attack
+	loomie.Defense = loomieToUpdate.Defense
+	loomie.Experience = loomieToUpdate.Experience
+	loomie.Level = loomieToUpdate.Level
+	store.CaughtLoomies[loomie.Id] = loomie
+
+	if user, ok := store.Users[userId]; ok {
+		user.Loomies = withoutIds(user.Loomies, []primitive.ObjectID{loomieToDelete.Id})
+		user.LoomieTeam = withoutIds(user.LoomieTeam, []primitive.ObjectID{loomieToDelete.Id})
+		store.Users[userId] = user
+	}
+
+	delete(store.CaughtLoomies, loomieToDelete.Id)
+	store.record(ctx, interfaces.AuditEvent{
+		UserId:   userId,
+		Action:   "loomie.fuse",
+		Entity:   "caught_loomies",
+		EntityId: loomieToUpdate.Id,
+		Before:   bson.M{"loomie": before, "fused_loomie": loomieToDelete},
+		After:    bson.M{"loomie": loomieToUpdate},
+	})
+
+	return nil
+}
+
+func (repository loomiesRepository) IncrementLoomieLevel(ctx context.Context, userId primitive.ObjectID, loomieId primitive.ObjectID, amount uint) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	loomie, ok := repository.store.CaughtLoomies[loomieId]
+	if !ok || loomie.Owner != userId {
+		return mongo.ErrNoDocuments
+	}
+
+	loomie.Level += int(amount)
+	repository.store.CaughtLoomies[loomieId] = loomie
+	repository.store.record(ctx, interfaces.AuditEvent{
+		UserId:   userId,
+		Action:   "loomie.level_up",
+		Entity:   "caught_loomies",
+		EntityId: loomieId,
+		Before:   bson.M{"level": loomie.Level - int(amount)},
+		After:    bson.M{"level": loomie.Level},
+	})
+
+	return nil
+}
+
+func (repository loomiesRepository) UpdateLoomiesExpAndLvl(ctx context.Context, userId primitive.ObjectID, loomieToUpdate *interfaces.CombatLoomie) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	loomie, ok := repository.store.CaughtLoomies[loomieToUpdate.Id]
+	if !ok {
+		return errors.New("Error")
+	}
+
+	before := loomie
+	loomie.Experience = loomieToUpdate.Experience
+	loomie.Level = loomieToUpdate.Level
+	repository.store.CaughtLoomies[loomie.Id] = loomie
+	repository.store.record(ctx, interfaces.AuditEvent{
+		UserId:   userId,
+		Action:   "loomie.update_experience",
+		Entity:   "caught_loomies",
+		EntityId: loomie.Id,
+		Before:   bson.M{"experience": before.Experience, "level": before.Level},
+		After:    bson.M{"experience": loomie.Experience, "level": loomie.Level},
+	})
+
+	return nil
+}
+
+func (repository loomiesRepository) UpdateLoomiesBusyState(ctx context.Context, loomiesIds []primitive.ObjectID, busy bool) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+	repository.store.setLoomiesBusyState(ctx, loomiesIds, busy)
+	return nil
+}
+
+func (repository loomiesRepository) GetBaseLoomies() ([]interfaces.BaseLoomiesWithPopulatedRarity, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	baseLoomies := []interfaces.BaseLoomiesWithPopulatedRarity{}
+	for _, loomie := range repository.store.BaseLoomies {
+		baseLoomies = append(baseLoomies, interfaces.BaseLoomiesWithPopulatedRarity{
+			Id:              loomie.Id,
+			Serial:          loomie.Serial,
+			Name:            loomie.Name,
+			Types:           loomie.Types,
+			BaseHp:          loomie.BaseHp,
+			BaseAttack:      loomie.BaseAttack,
+			BaseDefense:     loomie.BaseDefense,
+			Rarity:          loomie.Rarity,
+			PopulatedRarity: repository.store.LoomieRarities[loomie.Rarity],
+		})
+	}
+
+	sort.Slice(baseLoomies, func(i, j int) bool {
+		return baseLoomies[i].Serial < baseLoomies[j].Serial
+	})
+
+	return baseLoomies, nil
+}
+
+// isWildLoomieVisible "private" function to check if the wild loomie is inside the visibility radius
+func (store *Store) isWildLoomieVisible(loomie interfaces.WildLoomie, coordinates interfaces.Coordinates) bool {
+	location := interfaces.Coordinates{Latitude: loomie.Latitude, Longitude: loomie.Longitude}
+	return utils.IsWithinDistance(location, coordinates, store.config.Game.VisibilityRadius)
+}
+
+func (repository loomiesRepository) GetNearWildLoomies(coordinates interfaces.Coordinates, userId primitive.ObjectID) ([]interfaces.PopulatedWildLoomie, error) {
+	store := repository.store
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	loomies := []interfaces.PopulatedWildLoomie{}
+	loomieTTL := store.balance.Get().WildLoomiesTTL
+	currentTime := time.Now()
+
+	// Ignore the loomies that are captured by the user or expired
+	for _, loomie := range store.WildLoomies {
+		loomieDeadline := time.Unix(loomie.GeneratedAt, 0).Add(time.Minute * time.Duration(loomieTTL))
+
+		if !store.isWildLoomieVisible(loomie, coordinates) || containsId(loomie.CapturedBy, userId) || !currentTime.Before(loomieDeadline) {
+			continue
+		}
+
+		types := []string{}
+		for _, loomieType := range loomie.Types {
+			types = append(types, store.LoomieTypes[loomieType].Name)
+		}
+
+		loomies = append(loomies, *loomie.Populate(types, store.LoomieRarities[loomie.Rarity].Name))
+	}
+
+	return loomies, nil
+}
+
+func (repository loomiesRepository) InsertWildLoomie(region interfaces.Region, loomie interfaces.WildLoomie) (interfaces.WildLoomie, bool) {
+	store := repository.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	coordinates := interfaces.Coordinates{Latitude: loomie.Latitude, Longitude: loomie.Longitude}
+	if !region.Contains(coordinates) {
+		return interfaces.WildLoomie{}, false
+	}
+
+	coordX, coordY := utils.GetZoneCoordinatesFromGPS(region, coordinates)
+	zone, ok := store.getZone(region.Id, coordX, coordY)
+	if !ok {
+		return interfaces.WildLoomie{}, false
+	}
+
+	// Check if the zone has the maximum amount of loomies
+	zoneLoomies := 0
+	for _, current := range store.WildLoomies {
+		if current.ZoneId == zone.Id {
+			zoneLoomies++
+		}
+	}
+
+	if zoneLoomies >= store.config.Game.MaxLoomiesPerZone {
+		return interfaces.WildLoomie{}, false
+	}
+
+	loomie.Id = primitive.NewObjectID()
+	loomie.ZoneId = zone.Id
+	loomie.Location = coordinates.ToGeoPoint()
+	loomie.GeneratedAt = time.Now().Unix()
+	store.WildLoomies[loomie.Id] = loomie
+
+	zone.Loomies = withId(zone.Loomies, loomie.Id)
+	store.Zones[zone.Id] = zone
+	return loomie, true
+}
+
+func (repository loomiesRepository) RemoveNearExpiredLoomies(coordinates interfaces.Coordinates) error {
+	store := repository.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	loomieTTL := store.balance.Get().WildLoomiesTTL
+	deadline := time.Now().Add(-time.Minute * time.Duration(loomieTTL)).Unix()
+
+	for id, loomie := range store.WildLoomies {
+		if store.isWildLoomieVisible(loomie, coordinates) && loomie.GeneratedAt <= deadline {
+			delete(store.WildLoomies, id)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,564 @@
+// Package memory implements the repositories over maps, so the handlers and the combats can be tested without a
+// mongo database. The documents are stored by value, the slices are copied before being modified
+package memory
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/audit"
+	"github.com/PedroChaparro/loomies-backend/configuration"
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Store contains the documents of the in-memory repositories. The maps and lists can be filled before using the
+// repositories, the loomies types and rarities are used to populate the loomies
+type Store struct {
+	mutex sync.RWMutex
+	// Settings of the game, the published game settings versions are applied to the live balance
+	config               *configuration.Config
+	balance              *configuration.LiveBalance
+	Users                map[primitive.ObjectID]interfaces.User
+	CaughtLoomies        map[primitive.ObjectID]interfaces.CaughtLoomie
+	WildLoomies          map[primitive.ObjectID]interfaces.WildLoomie
+	BaseLoomies          map[primitive.ObjectID]interfaces.BaseLoomies
+	LoomieTypes          map[primitive.ObjectID]interfaces.LoomieType
+	LoomieRarities       map[primitive.ObjectID]interfaces.LoomieRarity
+	Gyms                 map[primitive.ObjectID]interfaces.Gym
+	Items                map[primitive.ObjectID]interfaces.Item
+	Loomballs            map[primitive.ObjectID]interfaces.Loomball
+	Regions              map[primitive.ObjectID]interfaces.Region
+	Zones                map[primitive.ObjectID]interfaces.Zone
+	Challenges           []interfaces.GymChallengesRegister
+	Friendships          map[primitive.ObjectID]interfaces.Friendship
+	Trades               map[primitive.ObjectID]interfaces.Trade
+	Gifts                map[primitive.ObjectID]interfaces.Gift
+	GiftTable            []interfaces.GiftTableEntry
+	Achievements         []interfaces.Achievement
+	QuestTemplates       []interfaces.QuestTemplate
+	UserQuests           map[primitive.ObjectID]interfaces.UserQuest
+	AuthenticationCodes  []interfaces.AuthenticationCode
+	OIDCStates           []interfaces.OIDCState
+	GameSettingsVersions []interfaces.GameSettingsVersion
+	JobRuns              []interfaces.JobRun
+	AuditEvents          []interfaces.AuditEvent
+}
+
+// The repositories share the store, so the operations that change several documents are atomic
+type usersRepository struct{ store *Store }
+type loomiesRepository struct{ store *Store }
+type gymsRepository struct{ store *Store }
+type itemsRepository struct{ store *Store }
+type zonesRepository struct{ store *Store }
+type challengesRepository struct{ store *Store }
+type accountsRepository struct{ store *Store }
+type mfaRepository struct{ store *Store }
+type moderationRepository struct{ store *Store }
+type friendsRepository struct{ store *Store }
+type tradesRepository struct{ store *Store }
+type giftsRepository struct{ store *Store }
+type contentRepository struct{ store *Store }
+type questsRepository struct{ store *Store }
+type achievementsRepository struct{ store *Store }
+type gameSettingsRepository struct{ store *Store }
+type jobsRepository struct{ store *Store }
+type auditRepository struct{ store *Store }
+
+// NewStore Creates an empty store that uses the given settings
+func NewStore(config *configuration.Config, balance *configuration.LiveBalance) *Store {
+	return &Store{
+		config:               config,
+		balance:              balance,
+		Users:                make(map[primitive.ObjectID]interfaces.User),
+		CaughtLoomies:        make(map[primitive.ObjectID]interfaces.CaughtLoomie),
+		WildLoomies:          make(map[primitive.ObjectID]interfaces.WildLoomie),
+		BaseLoomies:          make(map[primitive.ObjectID]interfaces.BaseLoomies),
+		LoomieTypes:          make(map[primitive.ObjectID]interfaces.LoomieType),
+		LoomieRarities:       make(map[primitive.ObjectID]interfaces.LoomieRarity),
+		Gyms:                 make(map[primitive.ObjectID]interfaces.Gym),
+		Items:                make(map[primitive.ObjectID]interfaces.Item),
+		Loomballs:            make(map[primitive.ObjectID]interfaces.Loomball),
+		Regions:              make(map[primitive.ObjectID]interfaces.Region),
+		Zones:                make(map[primitive.ObjectID]interfaces.Zone),
+		Challenges:           []interfaces.GymChallengesRegister{},
+		Friendships:          make(map[primitive.ObjectID]interfaces.Friendship),
+		Trades:               make(map[primitive.ObjectID]interfaces.Trade),
+		Gifts:                make(map[primitive.ObjectID]interfaces.Gift),
+		GiftTable:            []interfaces.GiftTableEntry{},
+		Achievements:         []interfaces.Achievement{},
+		QuestTemplates:       []interfaces.QuestTemplate{},
+		UserQuests:           make(map[primitive.ObjectID]interfaces.UserQuest),
+		AuthenticationCodes:  []interfaces.AuthenticationCode{},
+		OIDCStates:           []interfaces.OIDCState{},
+		GameSettingsVersions: []interfaces.GameSettingsVersion{},
+		JobRuns:              []interfaces.JobRun{},
+		AuditEvents:          []interfaces.AuditEvent{},
+	}
+}
+
+// Repositories Returns the repositories backed by the store
+func (store *Store) Repositories() repositories.Repositories {
+	return repositories.Repositories{
+		Users:        usersRepository{store},
+		Accounts:     accountsRepository{store},
+		Mfa:          mfaRepository{store},
+		Moderation:   moderationRepository{store},
+		Friends:      friendsRepository{store},
+		Trades:       tradesRepository{store},
+		Gifts:        giftsRepository{store},
+		Loomies:      loomiesRepository{store},
+		Gyms:         gymsRepository{store},
+		Items:        itemsRepository{store},
+		Content:      contentRepository{store},
+		Zones:        zonesRepository{store},
+		Challenges:   challengesRepository{store},
+		Quests:       questsRepository{store},
+		Achievements: achievementsRepository{store},
+		GameSettings: gameSettingsRepository{store},
+		Jobs:         jobsRepository{store},
+		Audit:        auditRepository{store},
+	}
+}
+
+// NewRepositories Creates an empty store that uses the given settings and returns its repositories
+func NewRepositories(config *configuration.Config, balance *configuration.LiveBalance) (repositories.Repositories, *Store) {
+	store := NewStore(config, balance)
+	return store.Repositories(), store
+}
+
+// containsId "private" function to check if the id is in the given list
+func containsId(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, current := range ids {
+		if current == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// withoutIds "private" function to return a copy of the list without the given ids
+func withoutIds(ids []primitive.ObjectID, removed []primitive.ObjectID) []primitive.ObjectID {
+	result := []primitive.ObjectID{}
+
+	for _, id := range ids {
+		if !containsId(removed, id) {
+			result = append(result, id)
+		}
+	}
+
+	return result
+}
+
+// withId "private" function to return a copy of the list with the given id at the end
+func withId(ids []primitive.ObjectID, id primitive.ObjectID) []primitive.ObjectID {
+	return append(append([]primitive.ObjectID{}, ids...), id)
+}
+
+// record "private" function to store the audit event filled from the context, like the mongo repositories do. The
+// store should be locked
+func (store *Store) record(ctx context.Context, event interfaces.AuditEvent) {
+	event = audit.NewEvent(ctx, event)
+	event.Id = primitive.NewObjectID()
+	store.AuditEvents = append(store.AuditEvents, event)
+}
+
+// getUser "private" function to get the user from its hexadecimal id. The store should be locked
+func (store *Store) getUser(id string) (interfaces.User, error) {
+	mongoId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return interfaces.User{}, err
+	}
+
+	user, ok := store.Users[mongoId]
+	if !ok {
+		return interfaces.User{}, mongo.ErrNoDocuments
+	}
+
+	return user, nil
+}
+
+// ## Users
+
+func (repository usersRepository) GetUserById(id string) (interfaces.User, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+	return repository.store.getUser(id)
+}
+
+func (repository usersRepository) GetUserByEmail(email string) (interfaces.User, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	for _, user := range repository.store.Users {
+		if strings.EqualFold(user.Email, email) {
+			return user, nil
+		}
+	}
+
+	return interfaces.User{}, mongo.ErrNoDocuments
+}
+
+func (repository usersRepository) GetUserByUsername(username string) (interfaces.User, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	for _, user := range repository.store.Users {
+		if strings.EqualFold(user.Username, username) {
+			return user, nil
+		}
+	}
+
+	return interfaces.User{}, mongo.ErrNoDocuments
+}
+
+func (repository usersRepository) GetUsersByIds(ids []primitive.ObjectID) ([]interfaces.User, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	users := []interfaces.User{}
+	for _, id := range ids {
+		if user, ok := repository.store.Users[id]; ok {
+			users = append(users, user)
+		}
+	}
+
+	return users, nil
+}
+
+func (repository usersRepository) InsertUser(ctx context.Context, user interfaces.User) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	if user.Id.IsZero() {
+		user.Id = primitive.NewObjectID()
+	}
+
+	user.LastLoomieGenerationTime = time.Now().Unix()
+	user.Items = []interfaces.InventoryItem{}
+	user.Loomies = []primitive.ObjectID{}
+	user.LoomieTeam = []primitive.ObjectID{}
+	repository.store.Users[user.Id] = user
+	repository.store.record(ctx, interfaces.AuditEvent{
+		ActorId:  user.Id,
+		UserId:   user.Id,
+		Action:   "user.create",
+		Entity:   "users",
+		EntityId: user.Id,
+		After:    bson.M{"username": user.Username, "email": user.Email},
+	})
+
+	return nil
+}
+
+func (repository usersRepository) UpdateUserGenerationTimes(userId string, lastGenerated int64, newTimeout int64) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	user, err := repository.store.getUser(userId)
+	if err != nil {
+		return err
+	}
+
+	user.LastLoomieGenerationTime = lastGenerated
+	user.CurrentLoomiesGenerationTimeout = newTimeout
+	repository.store.Users[user.Id] = user
+	return nil
+}
+
+func (repository usersRepository) ReplaceLoomieTeam(ctx context.Context, userId primitive.ObjectID, loomiesIds []primitive.ObjectID) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	user, ok := repository.store.Users[userId]
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	before := user.LoomieTeam
+	user.LoomieTeam = append([]primitive.ObjectID{}, loomiesIds...)
+	repository.store.Users[userId] = user
+	repository.store.record(ctx, interfaces.AuditEvent{
+		UserId:   userId,
+		Action:   "user.update_team",
+		Entity:   "users",
+		EntityId: userId,
+		Before:   bson.M{"loomie_team": before},
+		After:    bson.M{"loomie_team": loomiesIds},
+	})
+
+	return nil
+}
+
+func (repository usersRepository) RemoveFromLoomieTeam(ctx context.Context, userId primitive.ObjectID, loomiesIds []primitive.ObjectID) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	if user, ok := repository.store.Users[userId]; ok {
+		user.LoomieTeam = withoutIds(user.LoomieTeam, loomiesIds)
+		repository.store.Users[userId] = user
+		repository.store.record(ctx, interfaces.AuditEvent{
+			UserId:   userId,
+			Action:   "user.update_team",
+			Entity:   "users",
+			EntityId: userId,
+			After:    bson.M{"removed_loomies": loomiesIds},
+		})
+	}
+
+	return nil
+}
+
+func (repository usersRepository) UpdateUserRoles(ctx context.Context, userId primitive.ObjectID, roles []string) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	user, ok := repository.store.Users[userId]
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	before := user.Roles
+	user.Roles = append([]string{}, roles...)
+	repository.store.Users[userId] = user
+	repository.store.record(ctx, interfaces.AuditEvent{
+		UserId:   userId,
+		Action:   "user.update_roles",
+		Entity:   "users",
+		EntityId: userId,
+		Before:   bson.M{"roles": before},
+		After:    bson.M{"roles": roles},
+	})
+
+	return nil
+}
+
+func (repository usersRepository) UpdateUserProfile(ctx context.Context, user interfaces.User, profile interfaces.UserProfile) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	current, ok := repository.store.Users[user.Id]
+	if !ok {
+		return nil
+	}
+
+	current.Profile = profile
+	repository.store.Users[user.Id] = current
+	repository.store.record(ctx, interfaces.AuditEvent{
+		UserId:   user.Id,
+		Action:   "user.update_profile",
+		Entity:   "users",
+		EntityId: user.Id,
+		Before:   user.Profile,
+		After:    profile,
+	})
+
+	return nil
+}
+
+func (repository usersRepository) UpdateUserPresence(userId primitive.ObjectID, zoneCoordinates string) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	if user, ok := repository.store.Users[userId]; ok {
+		user.LastSeenAt = time.Now().Unix()
+		user.LastSeenZone = zoneCoordinates
+		repository.store.Users[userId] = user
+	}
+
+	return nil
+}
+
+// getTrainerLevelRewards "private" function to get the rewards of the levels after fromLevel until toLevel (included).
+// The store should be locked
+func (store *Store) getTrainerLevelRewards(fromLevel int, toLevel int) []interfaces.GymRewardItem {
+	rewards := []interfaces.GymRewardItem{}
+
+	for _, reward := range repositories.TrainerLevelRewards {
+		quantity := 0
+
+		for level := fromLevel + 1; level <= toLevel; level++ {
+			if level%reward.EveryLevels == 0 {
+				quantity += reward.RewardQuantity
+			}
+		}
+
+		rewardId, ok := store.getRewardIdBySerial(reward.Collection, reward.Serial)
+		if quantity == 0 || !ok {
+			continue
+		}
+
+		rewards = append(rewards, interfaces.GymRewardItem{
+			RewardCollection: reward.Collection,
+			RewardId:         rewardId,
+			RewardQuantity:   quantity,
+		})
+	}
+
+	return rewards
+}
+
+func (repository usersRepository) AddTrainerExperience(ctx context.Context, userId primitive.ObjectID, amount float64) (interfaces.TrainerProgressRes, error) {
+	store := repository.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	user, ok := store.Users[userId]
+	if !ok {
+		return interfaces.TrainerProgressRes{}, mongo.ErrNoDocuments
+	}
+
+	user.Experience += amount
+	store.Users[userId] = user
+	store.record(ctx, interfaces.AuditEvent{
+		UserId:   userId,
+		Action:   "user.add_experience",
+		Entity:   "users",
+		EntityId: userId,
+		Before:   bson.M{"experience": user.Experience - amount},
+		After:    bson.M{"experience": user.Experience},
+	})
+
+	currentLevel := user.Level
+	if currentLevel == 0 {
+		currentLevel = 1
+	}
+
+	progress := interfaces.TrainerProgressRes{
+		ExperienceGained: amount,
+		Experience:       user.Experience,
+		Level:            currentLevel,
+		Rewards:          []interfaces.GymRewardItem{},
+	}
+
+	newLevel := utils.GetTrainerLevelFromExperience(user.Experience, store.config.Trainer)
+
+	if newLevel > currentLevel {
+		user.Level = newLevel
+		store.Users[userId] = user
+
+		rewards := store.getTrainerLevelRewards(currentLevel, newLevel)
+		for _, reward := range rewards {
+			store.addItem(ctx, userId, reward)
+		}
+
+		store.record(ctx, interfaces.AuditEvent{
+			UserId:   userId,
+			Action:   "user.level_up",
+			Entity:   "users",
+			EntityId: userId,
+			Before:   bson.M{"level": currentLevel},
+			After:    bson.M{"level": newLevel, "rewards": rewards},
+		})
+
+		progress.Level = newLevel
+		progress.LevelUp = true
+		progress.Rewards = rewards
+	}
+
+	if progress.Level < store.config.Trainer.MaxLevel {
+		progress.NextLevelExperience = utils.GetTrainerRequiredExperience(progress.Level+1, store.config.Trainer)
+	}
+
+	return progress, nil
+}
+
+func (repository usersRepository) DeleteUserAccount(ctx context.Context, user interfaces.User) error {
+	store := repository.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	// The owned gyms become neutral and their protectors are kept without owner
+	gyms := 0
+	protectors := []primitive.ObjectID{}
+
+	for id, gym := range store.Gyms {
+		if gym.Owner == user.Id {
+			gyms++
+			protectors = append(protectors, gym.Protectors...)
+			gym.Owner = primitive.NilObjectID
+		}
+
+		if containsId(gym.RewardsClaimedBy, user.Id) {
+			gym.RewardsClaimedBy = withoutIds(gym.RewardsClaimedBy, []primitive.ObjectID{user.Id})
+		}
+
+		store.Gyms[id] = gym
+	}
+
+	for id, loomie := range store.CaughtLoomies {
+		if containsId(protectors, id) {
+			loomie.Owner = primitive.NilObjectID
+			store.CaughtLoomies[id] = loomie
+		} else if loomie.Owner == user.Id {
+			delete(store.CaughtLoomies, id)
+		}
+	}
+
+	for id, loomie := range store.WildLoomies {
+		if containsId(loomie.CapturedBy, user.Id) {
+			loomie.CapturedBy = withoutIds(loomie.CapturedBy, []primitive.ObjectID{user.Id})
+			store.WildLoomies[id] = loomie
+		}
+	}
+
+	challenges := []interfaces.GymChallengesRegister{}
+	for _, challenge := range store.Challenges {
+		if challenge.AttackerId != user.Id {
+			challenges = append(challenges, challenge)
+		}
+	}
+
+	store.Challenges = challenges
+	store.deleteAuthenticationCodes(user.Email, "")
+
+	for id, quest := range store.UserQuests {
+		if quest.UserId == user.Id {
+			delete(store.UserQuests, id)
+		}
+	}
+
+	for id, friendship := range store.Friendships {
+		if friendship.RequesterId == user.Id || friendship.RecipientId == user.Id {
+			delete(store.Friendships, id)
+		}
+	}
+
+	for id, trade := range store.Trades {
+		if trade.ProposerId == user.Id || trade.RecipientId == user.Id {
+			delete(store.Trades, id)
+		}
+	}
+
+	for id, gift := range store.Gifts {
+		if gift.SenderId == user.Id || gift.RecipientId == user.Id {
+			delete(store.Gifts, id)
+		}
+	}
+
+	for id, current := range store.Users {
+		if containsId(current.BlockedUsers, user.Id) {
+			current.BlockedUsers = withoutIds(current.BlockedUsers, []primitive.ObjectID{user.Id})
+			store.Users[id] = current
+		}
+	}
+
+	delete(store.Users, user.Id)
+	store.record(ctx, interfaces.AuditEvent{
+		ActorId:  user.Id,
+		UserId:   user.Id,
+		Action:   "user.delete",
+		Entity:   "users",
+		EntityId: user.Id,
+		Before:   bson.M{"username": user.Username, "released_gyms": gyms, "released_protectors": protectors},
+	})
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/PedroChaparro/loomies-backend/configuration"
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ## Helper functions
+// newTestStore creates a store with an user that owns one loomball and one item
+func newTestStore() (repositories.Repositories, *Store, interfaces.User, interfaces.Loomball, interfaces.Item) {
+	config := &configuration.Config{Game: configuration.TGameSettings{VisibilityRadius: 600, MaxLoomiesPerZone: 12}}
+	repos, store := NewRepositories(config, configuration.NewLiveBalance(config.Game.Balance()))
+	loomball := interfaces.Loomball{Id: primitive.NewObjectID(), Name: "Basic loomball", Serial: 1}
+	item := interfaces.Item{Id: primitive.NewObjectID(), Name: "Small potion", Serial: 1, Target: "Loomie"}
+	store.Loomballs[loomball.Id] = loomball
+	store.Items[item.Id] = item
+
+	user := interfaces.User{Id: primitive.NewObjectID(), Username: "Trainer", Email: "trainer@example.com"}
+	repos.Users.InsertUser(context.Background(), user)
+	repos.Items.AddItemToUserInventory(context.Background(), user.Id, interfaces.GymRewardItem{RewardCollection: "loomballs", RewardId: loomball.Id, RewardQuantity: 1})
+	repos.Items.AddItemToUserInventory(context.Background(), user.Id, interfaces.GymRewardItem{RewardCollection: "items", RewardId: item.Id, RewardQuantity: 2})
+
+	user, _ = repos.Users.GetUserById(user.Id.Hex())
+	return repos, store, user, loomball, item
+}
+
+// ## Tests
+
+// TestCaptureWildLoomie tests the capture uses the loomball and rejects the loomies caught twice
+func TestCaptureWildLoomie(t *testing.T) {
+	c := require.New(t)
+	ctx := context.Background()
+	repos, store, user, loomball, _ := newTestStore()
+
+	wild := interfaces.WildLoomie{Id: primitive.NewObjectID(), Serial: 4, Name: "Wild loomie", Level: 3, CapturedBy: []primitive.ObjectID{}}
+	store.WildLoomies[wild.Id] = wild
+
+	caughtId, err := repos.Loomies.CaptureWildLoomie(ctx, user, wild, loomball.Id, true)
+	c.NoError(err)
+	c.False(caughtId.IsZero())
+
+	user, err = repos.Users.GetUserById(user.Id.Hex())
+	c.NoError(err)
+	c.Equal([]primitive.ObjectID{caughtId}, user.Loomies)
+
+	loomies, err := repos.Loomies.GetLoomiesByIds(user.Loomies, user.Id)
+	c.NoError(err)
+	c.Equal(1, len(loomies))
+	c.Equal("Wild loomie", loomies[0].Name)
+
+	// The loomball was used, so the second capture fails before changing anything
+	_, err = repos.Loomies.CaptureWildLoomie(ctx, user, wild, loomball.Id, true)
+	c.True(errors.Is(err, repositories.ErrLoomballNotFound))
+
+	repos.Items.AddItemToUserInventory(ctx, user.Id, interfaces.GymRewardItem{RewardCollection: "loomballs", RewardId: loomball.Id, RewardQuantity: 1})
+	_, err = repos.Loomies.CaptureWildLoomie(ctx, user, wild, loomball.Id, true)
+	c.True(errors.Is(err, repositories.ErrLoomieAlreadyCaught))
+
+	user, _ = repos.Users.GetUserById(user.Id.Hex())
+	_, loomballs, err := repos.Items.GetInventory(user.Items)
+	c.NoError(err)
+	c.Equal(1, len(loomballs))
+	c.Equal(1, loomballs[0].Quantity)
+}
+
+// TestFuseLoomies tests the fused loomie is removed from the user and the team
+func TestFuseLoomies(t *testing.T) {
+	c := require.New(t)
+	ctx := context.Background()
+	repos, store, user, _, _ := newTestStore()
+
+	first, _ := repos.Loomies.InsertInCaughtLoomies(ctx, interfaces.CaughtLoomie{Owner: user.Id, Name: "First", Level: 1})
+	second, _ := repos.Loomies.InsertInCaughtLoomies(ctx, interfaces.CaughtLoomie{Owner: user.Id, Name: "Second", Level: 1})
+	user.Loomies = []primitive.ObjectID{first, second}
+	store.Users[user.Id] = user
+	c.NoError(repos.Users.ReplaceLoomieTeam(ctx, user.Id, []primitive.ObjectID{first, second}))
+
+	loomies, _ := repos.Loomies.GetLoomiesByIds([]primitive.ObjectID{first, second}, user.Id)
+	c.Equal(2, len(loomies))
+	loomies[0].Level = 2
+
+	c.NoError(repos.Loomies.FuseLoomies(ctx, user.Id, loomies[0], loomies[1]))
+
+	user, _ = repos.Users.GetUserById(user.Id.Hex())
+	c.Equal([]primitive.ObjectID{first}, user.Loomies)
+	c.Equal([]primitive.ObjectID{first}, user.LoomieTeam)
+
+	loomies, _ = repos.Loomies.GetLoomiesByIds([]primitive.ObjectID{first, second}, user.Id)
+	c.Equal(1, len(loomies))
+	c.Equal(2, loomies[0].Level)
+}
+
+// TestClaimGymReward tests the rewards are added to the inventory only once
+func TestClaimGymReward(t *testing.T) {
+	c := require.New(t)
+	ctx := context.Background()
+	repos, store, user, _, item := newTestStore()
+
+	gym := interfaces.Gym{Id: primitive.NewObjectID(), Name: "Gym", RewardsClaimedBy: []primitive.ObjectID{}}
+	store.Gyms[gym.Id] = gym
+	rewards := []interfaces.GymRewardItem{{RewardCollection: "items", RewardId: item.Id, RewardQuantity: 3}}
+
+	c.NoError(repos.Gyms.ClaimGymReward(ctx, gym, user.Id, rewards))
+	err := repos.Gyms.ClaimGymReward(ctx, gym, user.Id, rewards)
+	c.True(errors.Is(err, repositories.ErrRewardAlreadyClaimed))
+
+	inventoryItem, err := repos.Items.GetItemFromUserInventory(user.Id, item.Id, false)
+	c.NoError(err)
+	c.Equal(5, inventoryItem.Quantity)
+
+	populated, err := repos.Gyms.GetPopulatedGymFromId(gym.Id, user.Id)
+	c.NoError(err)
+	c.True(populated.WasRewardClaimed)
+}
+
+// TestTakeOverGym tests the new owner protectors replace the old ones
+func TestTakeOverGym(t *testing.T) {
+	c := require.New(t)
+	ctx := context.Background()
+	repos, store, user, _, _ := newTestStore()
+
+	oldProtector, _ := repos.Loomies.InsertInCaughtLoomies(ctx, interfaces.CaughtLoomie{Name: "Neutral protector"})
+	newProtector, _ := repos.Loomies.InsertInCaughtLoomies(ctx, interfaces.CaughtLoomie{Owner: user.Id, Name: "Protector"})
+	gym := interfaces.Gym{Id: primitive.NewObjectID(), Name: "Gym", Protectors: []primitive.ObjectID{oldProtector}}
+	store.Gyms[gym.Id] = gym
+	c.NoError(repos.Users.ReplaceLoomieTeam(ctx, user.Id, []primitive.ObjectID{newProtector}))
+
+	c.NoError(repos.Gyms.TakeOverGym(ctx, gym.Id, user.Id, []primitive.ObjectID{newProtector}, gym.Protectors, false))
+
+	count, err := repos.Gyms.CountGymsByOwner(user.Id)
+	c.NoError(err)
+	c.Equal(int64(1), count)
+
+	populated, err := repos.Gyms.GetPopulatedGymFromId(gym.Id, user.Id)
+	c.NoError(err)
+	c.True(populated.UserOwnsIt)
+	c.Equal(1, len(populated.Protectors))
+	c.Equal(newProtector, populated.Protectors[0].Id)
+
+	// The neutral protectors are removed and the new ones are busy
+	_, exists := store.CaughtLoomies[oldProtector]
+	c.False(exists)
+	c.True(store.CaughtLoomies[newProtector].IsBusy)
+
+	user, _ = repos.Users.GetUserById(user.Id.Hex())
+	c.Empty(user.LoomieTeam)
+}
+
+// TestGymChallenges tests the challenges registers are created, updated and finished
+func TestGymChallenges(t *testing.T) {
+	c := require.New(t)
+	repos, _, user, _, _ := newTestStore()
+	gymId := primitive.NewObjectID()
+
+	_, err := repos.Challenges.GetActiveCombatByUserId(user.Id)
+	c.Error(err)
+
+	c.NoError(repos.Challenges.UpdateLastGymChallengeTimestamp(gymId, user.Id))
+	active, err := repos.Challenges.GetActiveCombatByUserId(user.Id)
+	c.NoError(err)
+	c.Equal(gymId, active.GymId)
+
+	c.NoError(repos.Challenges.FinishGymChallenge(gymId, user.Id))
+	_, err = repos.Challenges.GetActiveCombatByUserId(user.Id)
+	c.Error(err)
+
+	combats, err := repos.Challenges.GetUserCombats(user.Id)
+	c.NoError(err)
+	c.Equal(1, len(combats))
+}
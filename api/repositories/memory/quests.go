@@ -0,0 +1,327 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ## Quests
+
+// newUserQuest "private" function to create a quest of the user from the given template. The store should be locked
+func (store *Store) newUserQuest(template interfaces.QuestTemplate, userId primitive.ObjectID) (interfaces.UserQuest, error) {
+	goal := template.MinGoal
+	if template.MaxGoal > template.MinGoal {
+		goal = utils.GetRandomInt(template.MinGoal, template.MaxGoal+1)
+	}
+
+	quest := interfaces.UserQuest{
+		UserId:      userId,
+		TemplateId:  template.Id,
+		Period:      template.Period,
+		Event:       template.Event,
+		Description: strings.ReplaceAll(template.Description, "{goal}", strconv.Itoa(goal)),
+		Unique:      template.Unique,
+		SeenValues:  []string{},
+		Goal:        goal,
+		Rewards:     []interfaces.GymRewardItem{},
+	}
+
+	if template.LoomieType != "" {
+		found := false
+		for _, loomieType := range store.LoomieTypes {
+			if loomieType.Name == template.LoomieType {
+				quest.LoomieTypeId = loomieType.Id
+				found = true
+			}
+		}
+
+		if !found {
+			return quest, fmt.Errorf("Loomie type %s was not found", template.LoomieType)
+		}
+	}
+
+	for _, reward := range template.Rewards {
+		rewardId, ok := store.getRewardIdBySerial(reward.RewardCollection, reward.RewardSerial)
+		if !ok {
+			return quest, mongo.ErrNoDocuments
+		}
+
+		quest.Rewards = append(quest.Rewards, interfaces.GymRewardItem{
+			RewardCollection: reward.RewardCollection,
+			RewardId:         rewardId,
+			RewardQuantity:   reward.RewardQuantity,
+		})
+	}
+
+	return quest, nil
+}
+
+// generateUserQuests "private" function to generate the missing quests of the user in the current period of the
+// given location. The store should be locked
+func (store *Store) generateUserQuests(userId primitive.ObjectID, period string, now time.Time, location *time.Location) error {
+	periodKey, expiresAt := utils.GetPeriod(period, now, location)
+
+	usedSlots := map[int]bool{}
+	for _, quest := range store.UserQuests {
+		if quest.UserId == userId && quest.PeriodKey == periodKey {
+			usedSlots[quest.Slot] = true
+		}
+	}
+
+	if len(usedSlots) >= repositories.QuestsPerPeriod[period] {
+		return nil
+	}
+
+	templates := []interfaces.QuestTemplate{}
+	for _, template := range store.QuestTemplates {
+		if template.Period == period {
+			templates = append(templates, template)
+		}
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Serial < templates[j].Serial
+	})
+
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	r.Shuffle(len(templates), func(i, j int) {
+		templates[i], templates[j] = templates[j], templates[i]
+	})
+
+	for slot := 0; slot < repositories.QuestsPerPeriod[period] && slot < len(templates); slot++ {
+		if usedSlots[slot] {
+			continue
+		}
+
+		quest, err := store.newUserQuest(templates[slot], userId)
+		if err != nil {
+			return err
+		}
+
+		quest.Id = primitive.NewObjectID()
+		quest.PeriodKey = periodKey
+		quest.Slot = slot
+		quest.CreatedAt = now.Unix()
+		quest.ExpiresAt = expiresAt.Unix()
+		store.UserQuests[quest.Id] = quest
+	}
+
+	return nil
+}
+
+func (repository questsRepository) GetUserQuests(ctx context.Context, userId primitive.ObjectID) ([]interfaces.UserQuest, error) {
+	store := repository.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	quests := []interfaces.UserQuest{}
+	now := time.Now()
+
+	// The periods start at the midnight of the user region
+	user, ok := store.Users[userId]
+	if !ok {
+		return quests, mongo.ErrNoDocuments
+	}
+
+	location := store.getUserLocation(user)
+
+	for _, period := range []string{"daily", "weekly"} {
+		if err := store.generateUserQuests(userId, period, now, location); err != nil {
+			return quests, err
+		}
+	}
+
+	for _, quest := range store.UserQuests {
+		if quest.UserId == userId && quest.ExpiresAt > now.Unix() {
+			quests = append(quests, quest)
+		}
+	}
+
+	sort.Slice(quests, func(i, j int) bool {
+		if quests[i].ExpiresAt != quests[j].ExpiresAt {
+			return quests[i].ExpiresAt < quests[j].ExpiresAt
+		}
+
+		return quests[i].Slot < quests[j].Slot
+	})
+
+	return quests, nil
+}
+
+func (repository questsRepository) GetUserQuestById(userId primitive.ObjectID, questId primitive.ObjectID) (interfaces.UserQuest, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	quest, ok := repository.store.UserQuests[questId]
+	if !ok || quest.UserId != userId {
+		return interfaces.UserQuest{}, mongo.ErrNoDocuments
+	}
+
+	return quest, nil
+}
+
+func (repository questsRepository) ClaimUserQuest(ctx context.Context, quest interfaces.UserQuest) (bool, error) {
+	store := repository.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	current, ok := store.UserQuests[quest.Id]
+	if !ok || current.UserId != quest.UserId || current.Claimed || current.Progress < current.Goal {
+		return false, nil
+	}
+
+	current.Claimed = true
+	store.UserQuests[quest.Id] = current
+	store.record(ctx, interfaces.AuditEvent{
+		UserId:   quest.UserId,
+		Action:   "user.claim_quest",
+		Entity:   "user_quests",
+		EntityId: quest.Id,
+		Before:   bson.M{"claimed": false},
+		After:    bson.M{"claimed": true, "rewards": quest.Rewards},
+	})
+
+	for _, reward := range quest.Rewards {
+		if err := store.addItem(ctx, quest.UserId, reward); err != nil {
+			return true, err
+		}
+	}
+
+	return true, nil
+}
+
+func (repository questsRepository) TrackQuestProgress(ctx context.Context, userId primitive.ObjectID, event string, value string, loomieTypes []primitive.ObjectID) error {
+	store := repository.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := time.Now().Unix()
+	for id, quest := range store.UserQuests {
+		if quest.UserId != userId || quest.Event != event || quest.Claimed || quest.ExpiresAt <= now || quest.Progress >= quest.Goal {
+			continue
+		}
+
+		if !quest.LoomieTypeId.IsZero() && !containsId(loomieTypes, quest.LoomieTypeId) {
+			continue
+		}
+
+		// The unique quests only count the values that weren't seen before
+		if quest.Unique {
+			seen := false
+			for _, seenValue := range quest.SeenValues {
+				seen = seen || seenValue == value
+			}
+
+			if value == "" || seen {
+				continue
+			}
+
+			quest.SeenValues = append(append([]string{}, quest.SeenValues...), value)
+		}
+
+		quest.Progress++
+		store.UserQuests[id] = quest
+	}
+
+	return nil
+}
+
+// ## Achievements
+
+// getAchievements "private" function to get the achievements sorted by serial. The store should be locked
+func (store *Store) getAchievements() []interfaces.Achievement {
+	achievements := append([]interfaces.Achievement{}, store.Achievements...)
+	sort.Slice(achievements, func(i, j int) bool {
+		return achievements[i].Serial < achievements[j].Serial
+	})
+
+	return achievements
+}
+
+func (repository achievementsRepository) GetAchievements() ([]interfaces.Achievement, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+	return repository.store.getAchievements(), nil
+}
+
+func (repository achievementsRepository) IncrementAchievementCounter(ctx context.Context, userId primitive.ObjectID, counter string, amount int) ([]interfaces.Achievement, error) {
+	return repository.updateAchievementCounter(ctx, userId, counter, func(current int) int {
+		return current + amount
+	})
+}
+
+func (repository achievementsRepository) SetAchievementCounterMax(ctx context.Context, userId primitive.ObjectID, counter string, value int) ([]interfaces.Achievement, error) {
+	return repository.updateAchievementCounter(ctx, userId, counter, func(current int) int {
+		if value > current {
+			return value
+		}
+
+		return current
+	})
+}
+
+// updateAchievementCounter "private" function to apply the update to the counter and unlock the reached achievements
+func (repository achievementsRepository) updateAchievementCounter(ctx context.Context, userId primitive.ObjectID, counter string, update func(current int) int) ([]interfaces.Achievement, error) {
+	store := repository.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	unlocked := []interfaces.Achievement{}
+	user, ok := store.Users[userId]
+	if !ok {
+		return unlocked, mongo.ErrNoDocuments
+	}
+
+	counters := map[string]int{}
+	for name, current := range user.AchievementCounters {
+		counters[name] = current
+	}
+
+	counters[counter] = update(counters[counter])
+	user.AchievementCounters = counters
+
+	for _, achievement := range store.getAchievements() {
+		if counters[achievement.Counter] < achievement.Goal {
+			continue
+		}
+
+		alreadyUnlocked := false
+		for _, userAchievement := range user.Achievements {
+			alreadyUnlocked = alreadyUnlocked || userAchievement.AchievementId == achievement.Id
+		}
+
+		if alreadyUnlocked {
+			continue
+		}
+
+		user.Achievements = append(append([]interfaces.UserAchievement{}, user.Achievements...), interfaces.UserAchievement{
+			AchievementId: achievement.Id,
+			UnlockedAt:    time.Now().Unix(),
+		})
+
+		store.record(ctx, interfaces.AuditEvent{
+			UserId:   userId,
+			Action:   "user.unlock_achievement",
+			Entity:   "users",
+			EntityId: userId,
+			After:    bson.M{"achievement_id": achievement.Id, "name": achievement.Name},
+		})
+
+		unlocked = append(unlocked, achievement)
+	}
+
+	store.Users[userId] = user
+	return unlocked, nil
+}
@@ -0,0 +1,733 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/repositories"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ## Friends
+
+// isBetween "private" function to check if the users are the given ones (in any order)
+func isBetween(firstId primitive.ObjectID, secondId primitive.ObjectID, firstUserId primitive.ObjectID, secondUserId primitive.ObjectID) bool {
+	return (firstId == firstUserId && secondId == secondUserId) || (firstId == secondUserId && secondId == firstUserId)
+}
+
+// getFriendshipBetween "private" function to get the friendship (accepted or pending) between two users. The store
+// should be locked
+func (store *Store) getFriendshipBetween(firstUserId primitive.ObjectID, secondUserId primitive.ObjectID) (interfaces.Friendship, bool) {
+	for _, friendship := range store.Friendships {
+		if isBetween(friendship.RequesterId, friendship.RecipientId, firstUserId, secondUserId) {
+			return friendship, true
+		}
+	}
+
+	return interfaces.Friendship{}, false
+}
+
+// areFriends "private" function to check if the users accepted a friend request between them. The store should be
+// locked
+func (store *Store) areFriends(firstUserId primitive.ObjectID, secondUserId primitive.ObjectID) bool {
+	friendship, ok := store.getFriendshipBetween(firstUserId, secondUserId)
+	return ok && friendship.Status == repositories.FriendshipAccepted
+}
+
+func (repository friendsRepository) GetFriendshipBetween(firstUserId primitive.ObjectID, secondUserId primitive.ObjectID) (interfaces.Friendship, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	friendship, ok := repository.store.getFriendshipBetween(firstUserId, secondUserId)
+	if !ok {
+		return interfaces.Friendship{}, mongo.ErrNoDocuments
+	}
+
+	return friendship, nil
+}
+
+func (repository friendsRepository) GetFriendshipById(userId primitive.ObjectID, friendshipId primitive.ObjectID) (interfaces.Friendship, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	friendship, ok := repository.store.Friendships[friendshipId]
+	if !ok || (friendship.RequesterId != userId && friendship.RecipientId != userId) {
+		return interfaces.Friendship{}, mongo.ErrNoDocuments
+	}
+
+	return friendship, nil
+}
+
+func (repository friendsRepository) AreFriends(firstUserId primitive.ObjectID, secondUserId primitive.ObjectID) (bool, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+	return repository.store.areFriends(firstUserId, secondUserId), nil
+}
+
+func (repository friendsRepository) GetUserFriendships(userId primitive.ObjectID, status string) ([]interfaces.Friendship, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	friendships := []interfaces.Friendship{}
+	for _, friendship := range repository.store.Friendships {
+		if friendship.Status == status && (friendship.RequesterId == userId || friendship.RecipientId == userId) {
+			friendships = append(friendships, friendship)
+		}
+	}
+
+	sort.SliceStable(friendships, func(i, j int) bool {
+		return friendships[i].CreatedAt > friendships[j].CreatedAt
+	})
+
+	return friendships, nil
+}
+
+func (repository friendsRepository) CreateFriendRequest(ctx context.Context, requesterId primitive.ObjectID, recipientId primitive.ObjectID) (interfaces.Friendship, error) {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	friendship := interfaces.Friendship{
+		Id:          primitive.NewObjectID(),
+		RequesterId: requesterId,
+		RecipientId: recipientId,
+		Status:      repositories.FriendshipPending,
+		CreatedAt:   time.Now().Unix(),
+	}
+
+	repository.store.Friendships[friendship.Id] = friendship
+	repository.store.record(ctx, interfaces.AuditEvent{
+		UserId:   requesterId,
+		Action:   "friendship.request",
+		Entity:   "friendships",
+		EntityId: friendship.Id,
+		After:    friendship,
+	})
+
+	return friendship, nil
+}
+
+func (repository friendsRepository) AcceptFriendRequest(ctx context.Context, friendship interfaces.Friendship) (bool, error) {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	current, ok := repository.store.Friendships[friendship.Id]
+	if !ok || current.Status != repositories.FriendshipPending {
+		return false, nil
+	}
+
+	current.Status = repositories.FriendshipAccepted
+	current.AcceptedAt = time.Now().Unix()
+	repository.store.Friendships[friendship.Id] = current
+	repository.store.record(ctx, interfaces.AuditEvent{
+		UserId:   friendship.RecipientId,
+		Action:   "friendship.accept",
+		Entity:   "friendships",
+		EntityId: friendship.Id,
+		Before:   bson.M{"status": repositories.FriendshipPending},
+		After:    bson.M{"status": repositories.FriendshipAccepted, "accepted_at": current.AcceptedAt},
+	})
+
+	return true, nil
+}
+
+func (repository friendsRepository) DeleteFriendship(ctx context.Context, userId primitive.ObjectID, friendship interfaces.Friendship, action string) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	delete(repository.store.Friendships, friendship.Id)
+	repository.store.record(ctx, interfaces.AuditEvent{
+		UserId:   userId,
+		Action:   action,
+		Entity:   "friendships",
+		EntityId: friendship.Id,
+		Before:   friendship,
+	})
+
+	return nil
+}
+
+func (repository friendsRepository) BlockUser(ctx context.Context, user interfaces.User, blockedId primitive.ObjectID) error {
+	store := repository.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	current, ok := store.Users[user.Id]
+	if !ok {
+		return nil
+	}
+
+	if !containsId(current.BlockedUsers, blockedId) {
+		current.BlockedUsers = withId(current.BlockedUsers, blockedId)
+		store.Users[user.Id] = current
+	}
+
+	for id, friendship := range store.Friendships {
+		if isBetween(friendship.RequesterId, friendship.RecipientId, user.Id, blockedId) {
+			delete(store.Friendships, id)
+		}
+	}
+
+	// The active trades between them are cancelled
+	for id, trade := range store.Trades {
+		if isBetween(trade.ProposerId, trade.RecipientId, user.Id, blockedId) && isActiveTradeStatus(trade.Status) {
+			trade.Status = repositories.TradeCancelled
+			trade.UpdatedAt = time.Now().Unix()
+			trade.PendingUserId = primitive.NilObjectID
+			store.Trades[id] = trade
+		}
+	}
+
+	store.record(ctx, interfaces.AuditEvent{
+		UserId:   user.Id,
+		Action:   "user.block",
+		Entity:   "users",
+		EntityId: user.Id,
+		After:    bson.M{"blocked_id": blockedId},
+	})
+
+	return nil
+}
+
+func (repository friendsRepository) UnblockUser(ctx context.Context, user interfaces.User, blockedId primitive.ObjectID) error {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	if current, ok := repository.store.Users[user.Id]; ok {
+		current.BlockedUsers = withoutIds(current.BlockedUsers, []primitive.ObjectID{blockedId})
+		repository.store.Users[user.Id] = current
+	}
+
+	repository.store.record(ctx, interfaces.AuditEvent{
+		UserId:   user.Id,
+		Action:   "user.unblock",
+		Entity:   "users",
+		EntityId: user.Id,
+		Before:   bson.M{"blocked_id": blockedId},
+	})
+
+	return nil
+}
+
+// ## Trades
+
+// isActiveTradeStatus "private" function to check if the trade can still be completed
+func isActiveTradeStatus(status string) bool {
+	return status == repositories.TradeProposed || status == repositories.TradeAccepted
+}
+
+// findTrades "private" function to get the trades of the user that match the filter sorted from the newest to the
+// oldest. The store should be locked
+func (store *Store) findTrades(userId primitive.ObjectID, filter func(trade interfaces.Trade) bool) []interfaces.Trade {
+	trades := []interfaces.Trade{}
+
+	for _, trade := range store.Trades {
+		if (trade.ProposerId == userId || trade.RecipientId == userId) && filter(trade) {
+			trades = append(trades, trade)
+		}
+	}
+
+	sort.SliceStable(trades, func(i, j int) bool {
+		return trades[i].UpdatedAt > trades[j].UpdatedAt
+	})
+
+	return trades
+}
+
+// updateTrade "private" function to update the trade if it's still in the given status and (if not nil) pending for
+// the given user. Returns false if the trade changed concurrently
+func (repository tradesRepository) updateTrade(ctx context.Context, trade interfaces.Trade, status string, pendingUserId *primitive.ObjectID, update func(trade *interfaces.Trade), action string, userId primitive.ObjectID) (bool, error) {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	current, ok := repository.store.Trades[trade.Id]
+	if !ok || current.Status != status || current.ExpiresAt <= time.Now().Unix() {
+		return false, nil
+	}
+
+	if pendingUserId != nil && current.PendingUserId != *pendingUserId {
+		return false, nil
+	}
+
+	update(&current)
+	repository.store.Trades[trade.Id] = current
+	repository.store.record(ctx, interfaces.AuditEvent{
+		UserId:   userId,
+		Action:   action,
+		Entity:   "trades",
+		EntityId: trade.Id,
+		Before:   trade,
+		After:    current,
+	})
+
+	return true, nil
+}
+
+func (repository tradesRepository) GetTradeById(userId primitive.ObjectID, tradeId primitive.ObjectID) (interfaces.Trade, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	trade, ok := repository.store.Trades[tradeId]
+	if !ok || (trade.ProposerId != userId && trade.RecipientId != userId) {
+		return interfaces.Trade{}, mongo.ErrNoDocuments
+	}
+
+	return trade, nil
+}
+
+func (repository tradesRepository) GetActiveTrades(userId primitive.ObjectID) ([]interfaces.Trade, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	now := time.Now().Unix()
+	return repository.store.findTrades(userId, func(trade interfaces.Trade) bool {
+		return isActiveTradeStatus(trade.Status) && trade.ExpiresAt > now
+	}), nil
+}
+
+func (repository tradesRepository) GetTradesHistory(userId primitive.ObjectID) ([]interfaces.Trade, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	now := time.Now().Unix()
+	return repository.store.findTrades(userId, func(trade interfaces.Trade) bool {
+		return !isActiveTradeStatus(trade.Status) || trade.ExpiresAt <= now
+	}), nil
+}
+
+func (repository tradesRepository) CreateTrade(ctx context.Context, trade interfaces.Trade) (interfaces.Trade, error) {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	trade.Id = primitive.NewObjectID()
+	repository.store.Trades[trade.Id] = trade
+	repository.store.record(ctx, interfaces.AuditEvent{
+		UserId:   trade.ProposerId,
+		Action:   "trade.propose",
+		Entity:   "trades",
+		EntityId: trade.Id,
+		After:    trade,
+	})
+
+	return trade, nil
+}
+
+func (repository tradesRepository) CounterTrade(ctx context.Context, trade interfaces.Trade, userId primitive.ObjectID, proposerOffer interfaces.TradeOffer, recipientOffer interfaces.TradeOffer, expiresAt int64) (bool, error) {
+	otherUserId := trade.ProposerId
+	if userId == trade.ProposerId {
+		otherUserId = trade.RecipientId
+	}
+
+	return repository.updateTrade(ctx, trade, repositories.TradeProposed, &userId, func(current *interfaces.Trade) {
+		current.ProposerOffer = proposerOffer
+		current.RecipientOffer = recipientOffer
+		current.PendingUserId = otherUserId
+		current.UpdatedAt = time.Now().Unix()
+		current.ExpiresAt = expiresAt
+	}, "trade.counter", userId)
+}
+
+func (repository tradesRepository) AcceptTrade(ctx context.Context, trade interfaces.Trade, userId primitive.ObjectID) (bool, error) {
+	return repository.updateTrade(ctx, trade, repositories.TradeProposed, &userId, func(current *interfaces.Trade) {
+		current.Status = repositories.TradeAccepted
+		current.Confirmations = []interfaces.TradeConfirmation{}
+		current.UpdatedAt = time.Now().Unix()
+		current.PendingUserId = primitive.NilObjectID
+	}, "trade.accept", userId)
+}
+
+func (repository tradesRepository) CloseTrade(ctx context.Context, trade interfaces.Trade, userId primitive.ObjectID, status string) (bool, error) {
+	action := "trade.cancel"
+	if status == repositories.TradeDeclined {
+		action = "trade.decline"
+	}
+
+	return repository.updateTrade(ctx, trade, trade.Status, nil, func(current *interfaces.Trade) {
+		current.Status = status
+		current.UpdatedAt = time.Now().Unix()
+		current.PendingUserId = primitive.NilObjectID
+	}, action, userId)
+}
+
+func (repository tradesRepository) ConfirmTrade(ctx context.Context, trade interfaces.Trade, confirmation interfaces.TradeConfirmation) (bool, error) {
+	repository.store.mutex.Lock()
+	defer repository.store.mutex.Unlock()
+
+	// The updated_at check avoids losing a confirmation stored concurrently
+	current, ok := repository.store.Trades[trade.Id]
+	if !ok || current.Status != repositories.TradeAccepted || current.UpdatedAt != trade.UpdatedAt {
+		return false, nil
+	}
+
+	confirmations := []interfaces.TradeConfirmation{confirmation}
+	for _, previous := range trade.Confirmations {
+		if previous.UserId != confirmation.UserId {
+			confirmations = append(confirmations, previous)
+		}
+	}
+
+	current.Confirmations = confirmations
+	current.UpdatedAt = confirmation.ConfirmedAt
+	repository.store.Trades[trade.Id] = current
+	return true, nil
+}
+
+// transferTradeLoomie "private" function to move the loomie between the users. The loomie is removed from the team
+// of the previous owner, it can't be a gym protector nor the last loomie of the team. The store should be locked
+func (store *Store) transferTradeLoomie(fromId primitive.ObjectID, toId primitive.ObjectID, loomieId primitive.ObjectID) error {
+	from, ok := store.Users[fromId]
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	if !containsId(from.Loomies, loomieId) {
+		return repositories.ErrTradeLoomieUnavailable
+	}
+
+	if len(from.LoomieTeam) == 1 && from.LoomieTeam[0] == loomieId {
+		return repositories.ErrTradeLastTeamLoomie
+	}
+
+	loomie, ok := store.CaughtLoomies[loomieId]
+	if !ok || loomie.Owner != fromId || loomie.IsBusy {
+		return repositories.ErrTradeLoomieUnavailable
+	}
+
+	loomie.Owner = toId
+	store.CaughtLoomies[loomieId] = loomie
+
+	from.Loomies = withoutIds(from.Loomies, []primitive.ObjectID{loomieId})
+	from.LoomieTeam = withoutIds(from.LoomieTeam, []primitive.ObjectID{loomieId})
+	store.Users[fromId] = from
+
+	if to, ok := store.Users[toId]; ok {
+		to.Loomies = withId(to.Loomies, loomieId)
+		store.Users[toId] = to
+	}
+
+	return nil
+}
+
+// transferTradeItems "private" function to move the offered items between the users inventories. The store should
+// be locked
+func (store *Store) transferTradeItems(ctx context.Context, fromId primitive.ObjectID, toId primitive.ObjectID, items []interfaces.InventoryItem) error {
+	for _, item := range items {
+		available := false
+		for _, owned := range store.Users[fromId].Items {
+			if owned.ItemId == item.ItemId && owned.ItemQuantity >= item.ItemQuantity {
+				available = true
+			}
+		}
+
+		if !available {
+			return repositories.ErrTradeItemsUnavailable
+		}
+
+		if err := store.decrementItem(ctx, fromId, item.ItemId, item.ItemQuantity); err != nil {
+			return err
+		}
+
+		err := store.addItem(ctx, toId, interfaces.GymRewardItem{
+			RewardCollection: item.ItemCollection,
+			RewardId:         item.ItemId,
+			RewardQuantity:   item.ItemQuantity,
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// snapshot "private" function to copy the documents changed by the operations that must be fully applied or not
+// applied at all. The documents are stored by value, so copying the maps is enough. The store should be locked
+func (store *Store) snapshot() func() {
+	users := make(map[primitive.ObjectID]interfaces.User, len(store.Users))
+	for id, user := range store.Users {
+		users[id] = user
+	}
+
+	loomies := make(map[primitive.ObjectID]interfaces.CaughtLoomie, len(store.CaughtLoomies))
+	for id, loomie := range store.CaughtLoomies {
+		loomies[id] = loomie
+	}
+
+	trades := make(map[primitive.ObjectID]interfaces.Trade, len(store.Trades))
+	for id, trade := range store.Trades {
+		trades[id] = trade
+	}
+
+	gifts := make(map[primitive.ObjectID]interfaces.Gift, len(store.Gifts))
+	for id, gift := range store.Gifts {
+		gifts[id] = gift
+	}
+
+	events := store.AuditEvents
+
+	return func() {
+		store.Users = users
+		store.CaughtLoomies = loomies
+		store.Trades = trades
+		store.Gifts = gifts
+		store.AuditEvents = events
+	}
+}
+
+func (repository tradesRepository) CompleteTrade(ctx context.Context, trade interfaces.Trade, userId primitive.ObjectID) (interfaces.Trade, error) {
+	store := repository.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	current, ok := store.Trades[trade.Id]
+	if !ok || current.Status != repositories.TradeAccepted {
+		return trade, repositories.ErrTradeNotAccepted
+	}
+
+	// The trade is either fully applied or not applied at all
+	restore := store.snapshot()
+	err := store.completeTrade(ctx, &trade, userId)
+
+	if err != nil {
+		restore()
+	}
+
+	return trade, err
+}
+
+// completeTrade "private" function to swap the loomies and items of the trade. The store should be locked
+func (store *Store) completeTrade(ctx context.Context, trade *interfaces.Trade, userId primitive.ObjectID) error {
+	completedAt := time.Now().Unix()
+	current := store.Trades[trade.Id]
+	current.Status = repositories.TradeCompleted
+	current.UpdatedAt = completedAt
+	current.CompletedAt = completedAt
+	store.Trades[trade.Id] = current
+
+	if err := store.transferTradeLoomie(trade.ProposerId, trade.RecipientId, trade.ProposerOffer.LoomieId); err != nil {
+		return err
+	}
+
+	if err := store.transferTradeLoomie(trade.RecipientId, trade.ProposerId, trade.RecipientOffer.LoomieId); err != nil {
+		return err
+	}
+
+	if err := store.transferTradeItems(ctx, trade.ProposerId, trade.RecipientId, trade.ProposerOffer.Items); err != nil {
+		return err
+	}
+
+	if err := store.transferTradeItems(ctx, trade.RecipientId, trade.ProposerId, trade.RecipientOffer.Items); err != nil {
+		return err
+	}
+
+	trade.Status = repositories.TradeCompleted
+	trade.UpdatedAt = completedAt
+	trade.CompletedAt = completedAt
+
+	store.record(ctx, interfaces.AuditEvent{
+		UserId:   userId,
+		Action:   "trade.complete",
+		Entity:   "trades",
+		EntityId: trade.Id,
+		After:    *trade,
+	})
+
+	return nil
+}
+
+// ## Gifts
+
+// getRewardQuantity "private" function to get a random quantity between the limits (included)
+func getRewardQuantity(min int, max int) int {
+	if max <= min {
+		return min
+	}
+
+	return utils.GetRandomInt(min, max+1)
+}
+
+// sortGifts "private" function to sort the gifts from the newest to the oldest
+func sortGifts(gifts []interfaces.Gift) []interfaces.Gift {
+	sort.SliceStable(gifts, func(i, j int) bool {
+		return gifts[i].SentAt > gifts[j].SentAt
+	})
+
+	return gifts
+}
+
+func (repository giftsRepository) GetReceivedGifts(userId primitive.ObjectID, opened bool) ([]interfaces.Gift, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	gifts := []interfaces.Gift{}
+	for _, gift := range repository.store.Gifts {
+		if gift.RecipientId == userId && gift.Opened == opened {
+			gifts = append(gifts, gift)
+		}
+	}
+
+	return sortGifts(gifts), nil
+}
+
+func (repository giftsRepository) GetReceivedGiftById(userId primitive.ObjectID, giftId primitive.ObjectID) (interfaces.Gift, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	gift, ok := repository.store.Gifts[giftId]
+	if !ok || gift.RecipientId != userId {
+		return interfaces.Gift{}, mongo.ErrNoDocuments
+	}
+
+	return gift, nil
+}
+
+func (repository giftsRepository) GetUserGifts(userId primitive.ObjectID) ([]interfaces.Gift, error) {
+	repository.store.mutex.RLock()
+	defer repository.store.mutex.RUnlock()
+
+	gifts := []interfaces.Gift{}
+	for _, gift := range repository.store.Gifts {
+		if gift.SenderId == userId || gift.RecipientId == userId {
+			gifts = append(gifts, gift)
+		}
+	}
+
+	return sortGifts(gifts), nil
+}
+
+func (repository giftsRepository) NewGiftRewards(minRewards int, maxRewards int) ([]interfaces.GymRewardItem, error) {
+	store := repository.store
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	table := []interfaces.GiftTableEntry{}
+	weights := []float64{}
+
+	for _, entry := range store.GiftTable {
+		if entry.Weight > 0 {
+			table = append(table, entry)
+			weights = append(weights, entry.Weight)
+		}
+	}
+
+	count := minRewards
+	if maxRewards > minRewards {
+		count = utils.GetRandomInt(minRewards, maxRewards+1)
+	}
+
+	// Like the gyms rewards, the drawn entries are not repeated in a gift
+	rewards := []interfaces.GymRewardItem{}
+	for _, index := range utils.DrawWeighted(weights, count) {
+		entry := table[index]
+
+		rewardId, ok := store.getRewardIdBySerial(entry.RewardCollection, entry.RewardSerial)
+		if !ok {
+			return rewards, mongo.ErrNoDocuments
+		}
+
+		rewards = append(rewards, interfaces.GymRewardItem{
+			RewardCollection: entry.RewardCollection,
+			RewardId:         rewardId,
+			RewardQuantity:   getRewardQuantity(entry.MinQuantity, entry.MaxQuantity),
+		})
+	}
+
+	return rewards, nil
+}
+
+func (repository giftsRepository) SendGift(ctx context.Context, senderId primitive.ObjectID, recipientId primitive.ObjectID, rewards []interfaces.GymRewardItem, giftsPerDay int) (interfaces.Gift, error) {
+	store := repository.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := time.Now()
+
+	// The days start at the midnight of the sender region
+	sender, ok := store.Users[senderId]
+	if !ok {
+		return interfaces.Gift{}, repositories.ErrGiftsLimitReached
+	}
+
+	day, tomorrow := utils.GetPeriod("daily", now, store.getUserLocation(sender))
+
+	gift := interfaces.Gift{
+		Id:          primitive.NewObjectID(),
+		SenderId:    senderId,
+		RecipientId: recipientId,
+		Rewards:     rewards,
+		SentAt:      now.Unix(),
+	}
+
+	// Only one gift per friend and day
+	for _, sent := range store.Gifts {
+		if sent.SenderId == senderId && sent.RecipientId == recipientId && sent.SentAt >= tomorrow.AddDate(0, 0, -1).Unix() {
+			return interfaces.Gift{}, repositories.ErrGiftAlreadySent
+		}
+	}
+
+	// The counter is restarted when the day changes
+	if giftsPerDay <= 0 || (sender.GiftsSentDay == day && sender.GiftsSent >= giftsPerDay) {
+		return interfaces.Gift{}, repositories.ErrGiftsLimitReached
+	}
+
+	if sender.GiftsSentDay != day {
+		sender.GiftsSentDay = day
+		sender.GiftsSent = 0
+	}
+
+	sender.GiftsSent++
+	store.Users[senderId] = sender
+	store.Gifts[gift.Id] = gift
+	store.record(ctx, interfaces.AuditEvent{
+		UserId:   senderId,
+		Action:   "gift.send",
+		Entity:   "gifts",
+		EntityId: gift.Id,
+		After:    gift,
+	})
+
+	return gift, nil
+}
+
+func (repository giftsRepository) OpenGift(ctx context.Context, gift interfaces.Gift) (interfaces.Gift, error) {
+	store := repository.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	// The opened check avoids opening the gift twice
+	current, ok := store.Gifts[gift.Id]
+	if !ok || current.Opened {
+		return gift, repositories.ErrGiftAlreadyOpened
+	}
+
+	restore := store.snapshot()
+	openedAt := time.Now().Unix()
+	current.Opened = true
+	current.OpenedAt = openedAt
+	store.Gifts[gift.Id] = current
+
+	for _, reward := range gift.Rewards {
+		if err := store.addItem(ctx, gift.RecipientId, reward); err != nil {
+			restore()
+			return gift, err
+		}
+	}
+
+	gift.Opened = true
+	gift.OpenedAt = openedAt
+	store.record(ctx, interfaces.AuditEvent{
+		UserId:   gift.RecipientId,
+		Action:   "gift.open",
+		Entity:   "gifts",
+		EntityId: gift.Id,
+		Before:   bson.M{"opened": false},
+		After:    bson.M{"opened": true, "opened_at": openedAt},
+	})
+
+	return gift, nil
+}
@@ -0,0 +1,316 @@
+// Package repositories declares the data access used by the handlers and the combats. The mongo implementation is
+// provided by the models package and the in-memory one by the memory package
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/configuration"
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Errors shared by the implementations, so the handlers can check them regardless of the injected repositories
+var (
+	ErrItemNotFound         = errors.New("Item not found")
+	ErrLoomballNotFound     = errors.New("The given loomball was not found")
+	ErrLoomieAlreadyCaught  = errors.New("User already caught this loomie")
+	ErrRewardAlreadyClaimed = errors.New("You already claimed the rewards for this gym")
+
+	ErrTradeLoomieUnavailable = errors.New("The loomies of the trade are no longer available")
+	ErrTradeItemsUnavailable  = errors.New("The items of the trade are no longer available")
+	ErrTradeLastTeamLoomie    = errors.New("The last loomie of the team can't be traded")
+	ErrTradeNotAccepted       = errors.New("The trade is no longer accepted")
+
+	ErrGiftsLimitReached = errors.New("You already sent all the gifts of the day")
+	ErrGiftAlreadySent   = errors.New("You already sent a gift to this friend today")
+	ErrGiftAlreadyOpened = errors.New("Gift was already opened")
+
+	ErrRegionExists        = errors.New("A region with the same name already exists")
+	ErrRegionOverlaps      = errors.New("The region bounds overlap with other region")
+	ErrRegionUnknownLoomie = errors.New("The spawn table has loomies that don't exist")
+)
+
+// AuthenticationCodeMinutes is the time to live of the account verification and password reset codes
+const AuthenticationCodeMinutes = 15
+
+// Status of the friendships
+const (
+	FriendshipPending  = "pending"
+	FriendshipAccepted = "accepted"
+)
+
+// Status of the trades
+const (
+	TradeProposed  = "proposed"
+	TradeAccepted  = "accepted"
+	TradeCompleted = "completed"
+	TradeDeclined  = "declined"
+	TradeCancelled = "cancelled"
+)
+
+// Game events that increment the progress of the quests
+const (
+	QuestCaptureEvent         = "capture"
+	QuestDefeatProtectorEvent = "defeat_protector"
+	QuestVisitZoneEvent       = "visit_zone"
+	QuestFuseEvent            = "fuse"
+	QuestClaimRewardEvent     = "claim_reward"
+)
+
+// Counters used by the achievements definitions
+const (
+	AchievementCapturesCounter          = "captures"
+	AchievementFusionsCounter           = "fusions"
+	AchievementGymVictoriesCounter      = "gym_victories"
+	AchievementFlawlessVictoriesCounter = "flawless_victories"
+	AchievementOwnedGymsCounter         = "owned_gyms"
+	AchievementClaimedRewardsCounter    = "claimed_rewards"
+)
+
+// TrainerLevelRewards are the items given to the trainers when they level up (by serial)
+var TrainerLevelRewards = []interfaces.TrainerLevelReward{
+	{EveryLevels: 1, Collection: "loom_balls", Serial: 8, RewardQuantity: 5},
+	{EveryLevels: 1, Collection: "items", Serial: 1, RewardQuantity: 2},
+	{EveryLevels: 5, Collection: "loom_balls", Serial: 9, RewardQuantity: 3},
+	{EveryLevels: 5, Collection: "items", Serial: 3, RewardQuantity: 1},
+	{EveryLevels: 10, Collection: "loom_balls", Serial: 10, RewardQuantity: 1},
+}
+
+// QuestsPerPeriod is the number of quests generated to each user per period
+var QuestsPerPeriod = map[string]int{
+	"daily":  3,
+	"weekly": 2,
+}
+
+// Collections of the game content managed by the admins
+const (
+	BaseLoomiesContent    = "base_loomies"
+	ItemsContent          = "items"
+	LoomballsContent      = "loom_balls"
+	LoomieTypesContent    = "loomie_types"
+	LoomieRaritiesContent = "loomie_rarities"
+)
+
+// UsersRepository gives access to the users accounts and loomie teams
+type UsersRepository interface {
+	GetUserById(id string) (interfaces.User, error)
+	GetUserByEmail(email string) (interfaces.User, error)
+	GetUserByUsername(username string) (interfaces.User, error)
+	GetUsersByIds(ids []primitive.ObjectID) ([]interfaces.User, error)
+	InsertUser(ctx context.Context, user interfaces.User) error
+	UpdateUserGenerationTimes(userId string, lastGenerated int64, newTimeout int64) error
+	ReplaceLoomieTeam(ctx context.Context, userId primitive.ObjectID, loomiesIds []primitive.ObjectID) error
+	RemoveFromLoomieTeam(ctx context.Context, userId primitive.ObjectID, loomiesIds []primitive.ObjectID) error
+	UpdateUserRoles(ctx context.Context, userId primitive.ObjectID, roles []string) error
+	UpdateUserProfile(ctx context.Context, user interfaces.User, profile interfaces.UserProfile) error
+	UpdateUserPresence(userId primitive.ObjectID, zoneCoordinates string) error
+	AddTrainerExperience(ctx context.Context, userId primitive.ObjectID, amount float64) (interfaces.TrainerProgressRes, error)
+	DeleteUserAccount(ctx context.Context, user interfaces.User) error
+}
+
+// AccountsRepository gives access to the credentials of the users: verification and reset codes, passwords, linked
+// identities and the pending OpenID Connect logins
+type AccountsRepository interface {
+	UpdateAccountVerificationCode(email string, validationCode string) error
+	CompareAccountVerificationCode(email string, code string) bool
+	UpdatePasswordResetCode(email string, resetPassCode string) error
+	ComparePasswordResetCode(email string, code string) bool
+	UpdatePassword(ctx context.Context, email string, password string) error
+	GetUserByIdentity(provider string, subject string) (interfaces.User, error)
+	LinkUserIdentity(ctx context.Context, user interfaces.User, identity interfaces.UserIdentity) error
+	InsertOIDCState(state interfaces.OIDCState) error
+	PopOIDCState(provider string, state string) (interfaces.OIDCState, error)
+}
+
+// MfaRepository gives access to the two-factor authentication of the users and its login challenges
+type MfaRepository interface {
+	SetUserMfaPendingSecret(userId primitive.ObjectID, secret string) error
+	EnableUserMfa(ctx context.Context, userId primitive.ObjectID, secret string, recoveryCodes []string, usedStep int64) error
+	DisableUserMfa(ctx context.Context, userId primitive.ObjectID) error
+	UseUserMfaStep(userId primitive.ObjectID, step int64) (bool, error)
+	UseUserMfaRecoveryCode(ctx context.Context, userId primitive.ObjectID, codeHash string) (bool, error)
+	StartUserMfaChallenge(userId primitive.ObjectID, challengeId string) error
+	EndUserMfaChallenge(userId primitive.ObjectID, challengeId string) (bool, error)
+	RecordUserMfaChallengeFailure(ctx context.Context, userId primitive.ObjectID, challengeId string, maxAttempts int) (bool, error)
+	RecordUserMfaPendingFailure(ctx context.Context, userId primitive.ObjectID, secret string, maxAttempts int) (bool, error)
+	RecordUserMfaActionFailure(ctx context.Context, userId primitive.ObjectID, maxAttempts int, lockUntil int64) (bool, error)
+}
+
+// ModerationRepository gives access to the sanctions of the users and the tools of the moderators
+type ModerationRepository interface {
+	GetUserBan(userId string) (*interfaces.UserSanction, error)
+	SetUserSanction(ctx context.Context, userId primitive.ObjectID, field string, sanction interfaces.UserSanction) error
+	RemoveUserSanction(ctx context.Context, userId primitive.ObjectID, field string) error
+	SearchUsers(text string, limit int64) ([]interfaces.User, error)
+	ReleaseUserBusyLoomies(ctx context.Context, userId primitive.ObjectID, loomiesIds []primitive.ObjectID) (int64, error)
+}
+
+// FriendsRepository gives access to the friendships and the block lists
+type FriendsRepository interface {
+	GetFriendshipBetween(firstUserId primitive.ObjectID, secondUserId primitive.ObjectID) (interfaces.Friendship, error)
+	GetFriendshipById(userId primitive.ObjectID, friendshipId primitive.ObjectID) (interfaces.Friendship, error)
+	AreFriends(firstUserId primitive.ObjectID, secondUserId primitive.ObjectID) (bool, error)
+	GetUserFriendships(userId primitive.ObjectID, status string) ([]interfaces.Friendship, error)
+	CreateFriendRequest(ctx context.Context, requesterId primitive.ObjectID, recipientId primitive.ObjectID) (interfaces.Friendship, error)
+	AcceptFriendRequest(ctx context.Context, friendship interfaces.Friendship) (bool, error)
+	DeleteFriendship(ctx context.Context, userId primitive.ObjectID, friendship interfaces.Friendship, action string) error
+	BlockUser(ctx context.Context, user interfaces.User, blockedId primitive.ObjectID) error
+	UnblockUser(ctx context.Context, user interfaces.User, blockedId primitive.ObjectID) error
+}
+
+// TradesRepository gives access to the trades between the players
+type TradesRepository interface {
+	GetTradeById(userId primitive.ObjectID, tradeId primitive.ObjectID) (interfaces.Trade, error)
+	GetActiveTrades(userId primitive.ObjectID) ([]interfaces.Trade, error)
+	GetTradesHistory(userId primitive.ObjectID) ([]interfaces.Trade, error)
+	CreateTrade(ctx context.Context, trade interfaces.Trade) (interfaces.Trade, error)
+	CounterTrade(ctx context.Context, trade interfaces.Trade, userId primitive.ObjectID, proposerOffer interfaces.TradeOffer, recipientOffer interfaces.TradeOffer, expiresAt int64) (bool, error)
+	AcceptTrade(ctx context.Context, trade interfaces.Trade, userId primitive.ObjectID) (bool, error)
+	ConfirmTrade(ctx context.Context, trade interfaces.Trade, confirmation interfaces.TradeConfirmation) (bool, error)
+	CompleteTrade(ctx context.Context, trade interfaces.Trade, userId primitive.ObjectID) (interfaces.Trade, error)
+	CloseTrade(ctx context.Context, trade interfaces.Trade, userId primitive.ObjectID, status string) (bool, error)
+}
+
+// GiftsRepository gives access to the gifts between friends and the gift table
+type GiftsRepository interface {
+	GetReceivedGifts(userId primitive.ObjectID, opened bool) ([]interfaces.Gift, error)
+	GetReceivedGiftById(userId primitive.ObjectID, giftId primitive.ObjectID) (interfaces.Gift, error)
+	GetUserGifts(userId primitive.ObjectID) ([]interfaces.Gift, error)
+	NewGiftRewards(minRewards int, maxRewards int) ([]interfaces.GymRewardItem, error)
+	SendGift(ctx context.Context, senderId primitive.ObjectID, recipientId primitive.ObjectID, rewards []interfaces.GymRewardItem, giftsPerDay int) (interfaces.Gift, error)
+	OpenGift(ctx context.Context, gift interfaces.Gift) (interfaces.Gift, error)
+}
+
+// LoomiesRepository gives access to the caught and wild loomies
+type LoomiesRepository interface {
+	GetLoomiesByIds(ids []primitive.ObjectID, userId primitive.ObjectID) ([]interfaces.UserLoomiesRes, error)
+	GetWildLoomieById(id string) (interfaces.WildLoomie, error)
+	InsertInCaughtLoomies(ctx context.Context, loomie interfaces.CaughtLoomie) (primitive.ObjectID, error)
+	CaptureWildLoomie(ctx context.Context, user interfaces.User, loomie interfaces.WildLoomie, loomballId primitive.ObjectID, wasCaptured bool) (primitive.ObjectID, error)
+	FuseLoomies(ctx context.Context, userId primitive.ObjectID, loomieToUpdate interfaces.UserLoomiesRes, loomieToDelete interfaces.UserLoomiesRes) error
+	IncrementLoomieLevel(ctx context.Context, userId primitive.ObjectID, loomieId primitive.ObjectID, amount uint) error
+	UpdateLoomiesExpAndLvl(ctx context.Context, userId primitive.ObjectID, loomie *interfaces.CombatLoomie) error
+	UpdateLoomiesBusyState(ctx context.Context, loomiesIds []primitive.ObjectID, busy bool) error
+	GetBaseLoomies() ([]interfaces.BaseLoomiesWithPopulatedRarity, error)
+	GetNearWildLoomies(coordinates interfaces.Coordinates, userId primitive.ObjectID) ([]interfaces.PopulatedWildLoomie, error)
+	InsertWildLoomie(region interfaces.Region, loomie interfaces.WildLoomie) (interfaces.WildLoomie, bool)
+	RemoveNearExpiredLoomies(coordinates interfaces.Coordinates) error
+}
+
+// GymsRepository gives access to the gyms, their protectors and rewards
+type GymsRepository interface {
+	GetGymFromID(id string) (interfaces.Gym, error)
+	GetPopulatedGymFromId(gymId primitive.ObjectID, userId primitive.ObjectID) (interfaces.PopulatedGym, error)
+	GetGymsByOwner(ownerId primitive.ObjectID) ([]interfaces.Gym, error)
+	CountGymsByOwner(ownerId primitive.ObjectID) (int64, error)
+	UpdateGymProtectors(ctx context.Context, gymId primitive.ObjectID, protectorsIds []primitive.ObjectID) error
+	ClaimGymReward(ctx context.Context, gym interfaces.Gym, userId primitive.ObjectID, rewards []interfaces.GymRewardItem) error
+	TakeOverGym(ctx context.Context, gymId primitive.ObjectID, newOwner primitive.ObjectID, newProtectors []primitive.ObjectID, oldProtectors []primitive.ObjectID, hadOwner bool) error
+}
+
+// ItemsRepository gives access to the items, loomballs and the users inventories
+type ItemsRepository interface {
+	GetItemsFromIds(ids []primitive.ObjectID) ([]interfaces.Item, error)
+	GetLoomballsFromIds(ids []primitive.ObjectID) ([]interfaces.Loomball, error)
+	GetInventory(inventory []interfaces.InventoryItem) ([]interfaces.UserItemsRes, []interfaces.UserLoomballsRes, error)
+	GetItemFromUserInventory(userId primitive.ObjectID, itemId primitive.ObjectID, ignoreCombatItems bool) (interfaces.PopulatedInventoryItem, error)
+	AddItemToUserInventory(ctx context.Context, userId primitive.ObjectID, item interfaces.GymRewardItem) error
+	DecrementItemFromUserInventory(ctx context.Context, userId primitive.ObjectID, itemId primitive.ObjectID, quantity int) error
+	IncrementItemFromUserInventory(ctx context.Context, userId primitive.ObjectID, itemId primitive.ObjectID, quantity int) error
+	GetRewardCollection(rewardId primitive.ObjectID) (string, error)
+}
+
+// ContentRepository gives access to the game content managed by the admins. The documents are identified by the
+// name of their collection (See the content constants)
+type ContentRepository interface {
+	GetContentDocuments(collection string, sortBy string, results interface{}) error
+	InsertContentDocument(ctx context.Context, collection string, document interface{}) (primitive.ObjectID, error)
+	ReplaceContentDocument(ctx context.Context, collection string, id primitive.ObjectID, document interface{}) error
+	DeleteContentDocument(ctx context.Context, collection string, id primitive.ObjectID) error
+	IsContentFieldInUse(collection string, field string, value interface{}, exceptId primitive.ObjectID) (bool, error)
+	IsRewardSerialInUse(serial int, exceptId primitive.ObjectID) (bool, error)
+	IsRewardInUse(rewardId primitive.ObjectID) (bool, error)
+	GetLoomieTypesByNames(names []string) ([]interfaces.LoomieType, error)
+	GetLoomieTypeDetailsByName(typeName string) (interfaces.PopulatedLoomieType, error)
+	GetLoomieRarityByName(name string) (interfaces.LoomieRarity, error)
+	IsLoomieTypeInUse(typeId primitive.ObjectID) (bool, error)
+	IsLoomieRarityInUse(rarityId primitive.ObjectID) (bool, error)
+	RemoveLoomieTypeReferences(ctx context.Context, typeId primitive.ObjectID) error
+}
+
+// ZonesRepository gives access to the map regions and zones
+type ZonesRepository interface {
+	GetRegionFromCoordinates(coordinates interfaces.Coordinates) (interfaces.Region, error)
+	GetZoneFromCoordinates(regionId primitive.ObjectID, coordX int, coordY int) (interfaces.Zone, error)
+	GetNearGyms(latitude float64, longitude float64) ([]interfaces.NearGymsRes, error)
+	GetRegions() ([]interfaces.Region, error)
+	CreateRegion(ctx context.Context, region interfaces.Region) (interfaces.Region, error)
+	GetUserLocation(user interfaces.User) *time.Location
+}
+
+// ChallengesRepository gives access to the gyms challenges registers
+type ChallengesRepository interface {
+	GetActiveCombatByUserId(userId primitive.ObjectID) (interfaces.GymChallengesRegister, error)
+	GetLastGymChallenge(gymId primitive.ObjectID, playerId primitive.ObjectID) (interfaces.GymChallengesRegister, error)
+	UpdateLastGymChallengeTimestamp(gymId primitive.ObjectID, playerId primitive.ObjectID) error
+	FinishGymChallenge(gymId primitive.ObjectID, playerId primitive.ObjectID) error
+	GetUserCombats(userId primitive.ObjectID) ([]interfaces.GymChallengesRegister, error)
+}
+
+// QuestsRepository gives access to the daily and weekly quests of the users
+type QuestsRepository interface {
+	GetUserQuests(ctx context.Context, userId primitive.ObjectID) ([]interfaces.UserQuest, error)
+	GetUserQuestById(userId primitive.ObjectID, questId primitive.ObjectID) (interfaces.UserQuest, error)
+	ClaimUserQuest(ctx context.Context, quest interfaces.UserQuest) (bool, error)
+	TrackQuestProgress(ctx context.Context, userId primitive.ObjectID, event string, value string, loomieTypes []primitive.ObjectID) error
+}
+
+// AchievementsRepository gives access to the achievements and the counters of the users
+type AchievementsRepository interface {
+	GetAchievements() ([]interfaces.Achievement, error)
+	IncrementAchievementCounter(ctx context.Context, userId primitive.ObjectID, counter string, amount int) ([]interfaces.Achievement, error)
+	SetAchievementCounterMax(ctx context.Context, userId primitive.ObjectID, counter string, value int) ([]interfaces.Achievement, error)
+}
+
+// GameSettingsRepository gives access to the versions of the game balance published by the admins
+type GameSettingsRepository interface {
+	GetGameSettingsVersions(limit int64) ([]interfaces.GameSettingsVersion, error)
+	PublishGameSettings(ctx context.Context, settings configuration.TGameBalance, comment string, createdBy primitive.ObjectID, rolledBackTo int) (interfaces.GameSettingsVersion, error)
+	RollbackGameSettings(ctx context.Context, version int, comment string, createdBy primitive.ObjectID) (interfaces.GameSettingsVersion, error)
+}
+
+// JobsRepository gives access to the history of the periodic jobs
+type JobsRepository interface {
+	GetJobRuns(job string, limit int64) ([]interfaces.JobRun, error)
+}
+
+// AuditRepository gives access to the audit log, the events are stored by the implementations of the other
+// repositories when they change the game state
+type AuditRepository interface {
+	FindEvents(filter interfaces.AuditEventsFilter) ([]interfaces.AuditEvent, error)
+}
+
+// Repositories groups the repositories injected into the handlers and the combats
+type Repositories struct {
+	Users        UsersRepository
+	Accounts     AccountsRepository
+	Mfa          MfaRepository
+	Moderation   ModerationRepository
+	Friends      FriendsRepository
+	Trades       TradesRepository
+	Gifts        GiftsRepository
+	Loomies      LoomiesRepository
+	Gyms         GymsRepository
+	Items        ItemsRepository
+	Content      ContentRepository
+	Zones        ZonesRepository
+	Challenges   ChallengesRepository
+	Quests       QuestsRepository
+	Achievements AchievementsRepository
+	GameSettings GameSettingsRepository
+	Jobs         JobsRepository
+	Audit        AuditRepository
+}
@@ -3,10 +3,13 @@ package routes
 import (
 	"github.com/PedroChaparro/loomies-backend/controllers"
 	"github.com/PedroChaparro/loomies-backend/middlewares"
+	"github.com/PedroChaparro/loomies-backend/utils"
 	"github.com/gin-gonic/gin"
 )
 
 func SetupRoutes(engine *gin.Engine) {
+	engine.Use(middlewares.RequestId())
+
 	// User
 	engine.GET("/user/loomies", middlewares.MustProvideAccessToken(), controllers.HandleGetLoomies)
 	engine.GET("/user/loomie-team", middlewares.MustProvideAccessToken(), controllers.HandleGetLoomieTeam)
@@ -16,11 +19,33 @@ func SetupRoutes(engine *gin.Engine) {
 	engine.POST("/user/signup", controllers.HandleSignUp)
 	engine.POST("/user/validate/code", controllers.HandleAccountValidationCodeRequest)
 	engine.POST("/user/validate", controllers.HandleAccountValidation)
+	engine.POST("/user/mfa/enroll", middlewares.MustProvideAccessToken(), controllers.HandleMfaEnroll)
+	engine.POST("/user/mfa/confirm", middlewares.MustProvideAccessToken(), controllers.HandleMfaConfirm)
+	engine.POST("/user/mfa/disable", middlewares.MustProvideAccessToken(), controllers.HandleMfaDisable)
+	engine.GET("/user/export", middlewares.MustProvideAccessToken(), controllers.HandleExportUser)
+	engine.DELETE("/user", middlewares.MustProvideAccessToken(), controllers.HandleDeleteUser)
+	engine.PATCH("/user/profile", middlewares.MustProvideAccessToken(), controllers.HandleUpdateProfile)
+	engine.GET("/user/achievements", middlewares.MustProvideAccessToken(), controllers.HandleGetAchievements)
+	engine.GET("/users/:username", middlewares.MustProvideAccessToken(), controllers.HandleGetTrainerCard)
+
+	// Friends
+	engine.GET("/user/friends", middlewares.MustProvideAccessToken(), controllers.HandleGetFriends)
+	engine.DELETE("/user/friends/:username", middlewares.MustProvideAccessToken(), controllers.HandleRemoveFriend)
+	engine.GET("/user/friends/requests", middlewares.MustProvideAccessToken(), controllers.HandleGetFriendRequests)
+	engine.POST("/user/friends/requests", middlewares.MustProvideAccessToken(), controllers.HandleSendFriendRequest)
+	engine.POST("/user/friends/requests/:id/accept", middlewares.MustProvideAccessToken(), controllers.HandleAcceptFriendRequest)
+	engine.POST("/user/friends/requests/:id/decline", middlewares.MustProvideAccessToken(), controllers.HandleDeclineFriendRequest)
+	engine.GET("/user/blocks", middlewares.MustProvideAccessToken(), controllers.HandleGetBlockedUsers)
+	engine.POST("/user/blocks", middlewares.MustProvideAccessToken(), controllers.HandleBlockUser)
+	engine.DELETE("/user/blocks/:username", middlewares.MustProvideAccessToken(), controllers.HandleUnblockUser)
 
 	// Session
 	engine.POST("/session/login", controllers.HandleLogIn)
+	engine.POST("/session/mfa", controllers.HandleMfaLogIn)
 	engine.GET("/session/whoami", middlewares.MustProvideAccessToken(), controllers.HandleWhoami)
 	engine.GET("/session/refresh", middlewares.MustProvideRefreshToken(), controllers.HandleRefresh)
+	engine.GET("/session/oidc/:provider/authorize", controllers.HandleOIDCAuthorize)
+	engine.POST("/session/oidc/:provider/callback", controllers.HandleOIDCCallback)
 
 	// Gyms
 	engine.POST("/gyms/near", middlewares.MustProvideAccessToken(), controllers.HandleNearGyms)
@@ -37,4 +62,66 @@ func SetupRoutes(engine *gin.Engine) {
 	// Items
 	engine.GET("/user/items", middlewares.MustProvideAccessToken(), controllers.HandleGetItems)
 	engine.POST("/items/use", middlewares.MustProvideAccessToken(), controllers.HandleUseItem)
+
+	// Quests
+	engine.GET("/quests", middlewares.MustProvideAccessToken(), controllers.HandleGetQuests)
+	engine.POST("/quests/:id/claim", middlewares.MustProvideAccessToken(), controllers.HandleClaimQuest)
+
+	// Trades
+	engine.GET("/trades", middlewares.MustProvideAccessToken(), controllers.HandleGetTrades)
+	engine.POST("/trades", middlewares.MustProvideAccessToken(), controllers.HandleProposeTrade)
+	engine.GET("/trades/history", middlewares.MustProvideAccessToken(), controllers.HandleGetTradesHistory)
+	engine.POST("/trades/:id/counter", middlewares.MustProvideAccessToken(), controllers.HandleCounterTrade)
+	engine.POST("/trades/:id/accept", middlewares.MustProvideAccessToken(), controllers.HandleAcceptTrade)
+	engine.POST("/trades/:id/decline", middlewares.MustProvideAccessToken(), controllers.HandleDeclineTrade)
+	engine.POST("/trades/:id/confirm", middlewares.MustProvideAccessToken(), controllers.HandleConfirmTrade)
+
+	// Gifts
+	engine.GET("/gifts", middlewares.MustProvideAccessToken(), controllers.HandleGetGifts)
+	engine.POST("/gifts", middlewares.MustProvideAccessToken(), controllers.HandleSendGift)
+	engine.POST("/gifts/:id/open", middlewares.MustProvideAccessToken(), controllers.HandleOpenGift)
+
+	// Admin
+	admin := engine.Group("/admin", middlewares.MustProvideAccessToken())
+	admin.PUT("/users/:id/roles", middlewares.RequirePermission(utils.PermissionManageRoles), controllers.HandleUpdateUserRoles)
+	admin.GET("/audit-events", middlewares.RequirePermission(utils.PermissionReadAudit), controllers.HandleAdminGetAuditEvents)
+	admin.GET("/job-runs", middlewares.RequirePermission(utils.PermissionReadAudit), controllers.HandleAdminGetJobRuns)
+
+	moderation := admin.Group("/users", middlewares.RequirePermission(utils.PermissionModerateUsers))
+	moderation.GET("", controllers.HandleAdminSearchUsers)
+	moderation.GET("/:id", controllers.HandleAdminGetUser)
+	moderation.POST("/:id/ban", controllers.HandleAdminBanUser)
+	moderation.DELETE("/:id/ban", controllers.HandleAdminUnbanUser)
+	moderation.POST("/:id/mute", controllers.HandleAdminMuteUser)
+	moderation.DELETE("/:id/mute", controllers.HandleAdminUnmuteUser)
+	moderation.POST("/:id/items/grant", controllers.HandleAdminGrantItem)
+	moderation.POST("/:id/items/remove", controllers.HandleAdminRemoveItem)
+	moderation.POST("/:id/loomies/release", controllers.HandleAdminReleaseLoomies)
+
+	content := admin.Group("/", middlewares.RequirePermission(utils.PermissionManageContent))
+	content.GET("/base-loomies", controllers.HandleAdminGetBaseLoomies)
+	content.POST("/base-loomies", controllers.HandleAdminCreateBaseLoomie)
+	content.PUT("/base-loomies/:id", controllers.HandleAdminUpdateBaseLoomie)
+	content.DELETE("/base-loomies/:id", controllers.HandleAdminDeleteBaseLoomie)
+	content.GET("/items", controllers.HandleAdminGetItems)
+	content.POST("/items", controllers.HandleAdminCreateItem)
+	content.PUT("/items/:id", controllers.HandleAdminUpdateItem)
+	content.DELETE("/items/:id", controllers.HandleAdminDeleteItem)
+	content.GET("/loom-balls", controllers.HandleAdminGetLoomballs)
+	content.POST("/loom-balls", controllers.HandleAdminCreateLoomball)
+	content.PUT("/loom-balls/:id", controllers.HandleAdminUpdateLoomball)
+	content.DELETE("/loom-balls/:id", controllers.HandleAdminDeleteLoomball)
+	content.GET("/loomie-types", controllers.HandleAdminGetLoomieTypes)
+	content.POST("/loomie-types", controllers.HandleAdminCreateLoomieType)
+	content.PUT("/loomie-types/:id", controllers.HandleAdminUpdateLoomieType)
+	content.DELETE("/loomie-types/:id", controllers.HandleAdminDeleteLoomieType)
+	content.GET("/rarities", controllers.HandleAdminGetLoomieRarities)
+	content.POST("/rarities", controllers.HandleAdminCreateLoomieRarity)
+	content.PUT("/rarities/:id", controllers.HandleAdminUpdateLoomieRarity)
+	content.DELETE("/rarities/:id", controllers.HandleAdminDeleteLoomieRarity)
+	content.GET("/game-settings", controllers.HandleAdminGetGameSettings)
+	content.POST("/game-settings", controllers.HandleAdminPublishGameSettings)
+	content.POST("/game-settings/rollback", controllers.HandleAdminRollbackGameSettings)
+	content.GET("/regions", controllers.HandleAdminGetRegions)
+	content.POST("/regions", controllers.HandleAdminCreateRegion)
 }
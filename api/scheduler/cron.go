@@ -0,0 +1,191 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression with the minute, hour, day of the month, month and day of the week fields
+type Schedule struct {
+	minutes  uint64
+	hours    uint64
+	days     uint64
+	months   uint64
+	weekdays uint64
+	// When both days fields are restricted, a time matches if any of them matches (like cron)
+	anyDay     bool
+	anyWeekday bool
+}
+
+// Shortcuts of the common expressions
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthsNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var weekdaysNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// The Next search stops after this many years, Eg. for the 30th of February
+const maxSearchYears = 5
+
+// ParseSchedule parses a cron expression with 5 fields (minute, hour, day of the month, month and day of the week)
+// or one of the @yearly, @monthly, @weekly, @daily and @hourly shortcuts. The fields accept *, values, ranges
+// (1-5), steps (*/15 or 1-30/2) and lists (1,15), and the months and days of the week accept their names (jan, mon)
+func ParseSchedule(expression string) (Schedule, error) {
+	expression = strings.TrimSpace(expression)
+	if descriptor, ok := descriptors[strings.ToLower(expression)]; ok {
+		expression = descriptor
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return Schedule{}, fmt.Errorf("invalid cron expression %q, it must have 5 fields", expression)
+	}
+
+	schedule := Schedule{
+		anyDay:     strings.HasPrefix(fields[2], "*"),
+		anyWeekday: strings.HasPrefix(fields[4], "*"),
+	}
+
+	var err error
+	parsers := []struct {
+		bits  *uint64
+		field string
+		min   int
+		max   int
+		names map[string]int
+	}{
+		{&schedule.minutes, fields[0], 0, 59, nil},
+		{&schedule.hours, fields[1], 0, 23, nil},
+		{&schedule.days, fields[2], 1, 31, nil},
+		{&schedule.months, fields[3], 1, 12, monthsNames},
+		// Sunday is 0 or 7
+		{&schedule.weekdays, fields[4], 0, 7, weekdaysNames},
+	}
+
+	for _, parser := range parsers {
+		if *parser.bits, err = parseField(parser.field, parser.min, parser.max, parser.names); err != nil {
+			return Schedule{}, fmt.Errorf("invalid cron expression %q: %w", expression, err)
+		}
+	}
+
+	if schedule.weekdays&(1<<7) != 0 {
+		schedule.weekdays |= 1
+	}
+
+	return schedule, nil
+}
+
+// parseValue "private" function to parse a number or a name of a field
+func parseValue(value string, names map[string]int) (int, error) {
+	if number, ok := names[strings.ToLower(value)]; ok {
+		return number, nil
+	}
+
+	return strconv.Atoi(value)
+}
+
+// parseField "private" function to parse a field of the expression to a set of bits with the allowed values
+func parseField(field string, min int, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		start, end, step := min, max, 1
+
+		if rangePart != "*" {
+			first, last, isRange := strings.Cut(rangePart, "-")
+			value, err := parseValue(first, names)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+
+			start, end = value, value
+			if isRange {
+				if end, err = parseValue(last, names); err != nil {
+					return 0, fmt.Errorf("invalid value %q", part)
+				}
+			} else if hasStep {
+				// 5/10 means from 5 to the maximum in steps of 10
+				end = max
+			}
+		}
+
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+		}
+
+		if start < min || end > max || start > end {
+			return 0, fmt.Errorf("%q is out of the range %d-%d", part, min, max)
+		}
+
+		for value := start; value <= end; value += step {
+			bits |= 1 << value
+		}
+	}
+
+	return bits, nil
+}
+
+// matchesDay "private" function to check the day of the month and the day of the week of the time
+func (schedule Schedule) matchesDay(t time.Time) bool {
+	day := schedule.days&(1<<t.Day()) != 0
+	weekday := schedule.weekdays&(1<<t.Weekday()) != 0
+
+	if schedule.anyDay || schedule.anyWeekday {
+		return day && weekday
+	}
+
+	return day || weekday
+}
+
+// Next returns the first time after the given one that matches the schedule, in the location of the given time. A
+// zero time is returned if there is no such time in the next years
+func (schedule Schedule) Next(after time.Time) time.Time {
+	location := after.Location()
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(maxSearchYears, 0, 0)
+
+	for t.Before(limit) {
+		if schedule.months&(1<<t.Month()) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, location)
+			continue
+		}
+
+		if !schedule.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, location)
+			continue
+		}
+
+		if schedule.hours&(1<<t.Hour()) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, location)
+			continue
+		}
+
+		if schedule.minutes&(1<<t.Minute()) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
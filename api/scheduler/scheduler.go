@@ -0,0 +1,187 @@
+// Package scheduler runs the periodic jobs of the server (Eg. regenerating the gyms rewards) following cron
+// expressions. Every replica of the server runs the scheduler, so each occurrence of a job is locked in the job_locks
+// collection and only the replica that gets the lock runs it. The runs are recorded in the job_runs collection
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Time given to a job when it doesn't set a timeout, the lock expires after it
+const defaultJobTimeout = 10 * time.Minute
+
+// Time given to record the run and release the lock, even if the server is stopping
+const cleanupTimeout = 10 * time.Second
+
+// Job is a task run in the times of its schedule. Run returns a short summary of the work done
+type Job struct {
+	Name     string
+	Schedule Schedule
+	Timeout  time.Duration
+	Run      func(ctx context.Context) (string, error)
+}
+
+// Locker gives the occurrences of the jobs to a single replica
+type Locker interface {
+	// Acquire locks the occurrence of the job scheduled at the given time for the owner until the expiration. It
+	// returns false if other owner holds the lock or the occurrence was already run
+	Acquire(ctx context.Context, job string, owner string, scheduledAt time.Time, expiration time.Time) (bool, error)
+	// Release unlocks the job if the owner holds the lock
+	Release(ctx context.Context, job string, owner string) error
+}
+
+// History stores the runs of the jobs
+type History interface {
+	Add(ctx context.Context, run interfaces.JobRun) error
+}
+
+// Scheduler runs the jobs in the given location (UTC by default)
+type Scheduler struct {
+	Jobs     []Job
+	Locker   Locker
+	History  History
+	Owner    string
+	Location *time.Location
+
+	// Tracks the running loops to wait for them when the scheduler is stopped
+	running sync.WaitGroup
+}
+
+// DefaultOwner identifies the replica by its host name and process id
+func DefaultOwner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}
+
+// New creates a scheduler that stores the locks and the runs in the database
+func New(database *mongo.Database, location *time.Location) *Scheduler {
+	return &Scheduler{
+		Locker:   mongoLocker{collection: database.Collection("job_locks")},
+		History:  mongoHistory{collection: database.Collection("job_runs")},
+		Owner:    DefaultOwner(),
+		Location: location,
+	}
+}
+
+// Add registers a job, it must be called before Start
+func (scheduler *Scheduler) Add(job Job) {
+	scheduler.Jobs = append(scheduler.Jobs, job)
+}
+
+// Start runs each job in its own goroutine until the context is cancelled
+func (scheduler *Scheduler) Start(ctx context.Context) {
+	for _, job := range scheduler.Jobs {
+		scheduler.running.Add(1)
+		go scheduler.loop(ctx, job)
+	}
+}
+
+// Wait blocks until the jobs loops stop (after the context given to Start is cancelled) or the context is done
+func (scheduler *Scheduler) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+
+	go func() {
+		scheduler.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// location "private" function to get the location of the schedules
+func (scheduler *Scheduler) location() *time.Location {
+	if scheduler.Location == nil {
+		return time.UTC
+	}
+
+	return scheduler.Location
+}
+
+// loop "private" function to wait for the next time of the job schedule and execute it, until the context is cancelled
+func (scheduler *Scheduler) loop(ctx context.Context, job Job) {
+	defer scheduler.running.Done()
+
+	for {
+		next := job.Schedule.Next(time.Now().In(scheduler.location()))
+		if next.IsZero() {
+			fmt.Println("The job", job.Name, "has no next run time, it won't be run")
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		run, ran, err := scheduler.Execute(ctx, job, next)
+
+		if err != nil {
+			fmt.Println("Unable to run the job", job.Name+":", err)
+		} else if ran {
+			fmt.Println("Ran the job", job.Name+":", run.Summary)
+		}
+	}
+}
+
+// Execute runs the occurrence of the job scheduled at the given time if the lock is acquired and records the run.
+// It returns false if other replica has the occurrence. The error is the one of the lock, the job or the history
+func (scheduler *Scheduler) Execute(ctx context.Context, job Job, scheduledAt time.Time) (interfaces.JobRun, bool, error) {
+	timeout := job.Timeout
+	if timeout <= 0 {
+		timeout = defaultJobTimeout
+	}
+
+	started := time.Now()
+	acquired, err := scheduler.Locker.Acquire(ctx, job.Name, scheduler.Owner, scheduledAt, started.Add(timeout))
+
+	if err != nil || !acquired {
+		return interfaces.JobRun{}, false, err
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, timeout)
+	summary, jobErr := job.Run(jobCtx)
+	cancel()
+
+	run := interfaces.JobRun{
+		Job:         job.Name,
+		Owner:       scheduler.Owner,
+		ScheduledAt: scheduledAt.Unix(),
+		StartedAt:   started.Unix(),
+		FinishedAt:  time.Now().Unix(),
+		Succeeded:   jobErr == nil,
+		Summary:     summary,
+	}
+
+	if jobErr != nil {
+		run.Error = jobErr.Error()
+	}
+
+	cleanupCtx, cancelCleanup := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancelCleanup()
+
+	if err := scheduler.History.Add(cleanupCtx, run); err != nil {
+		return run, true, fmt.Errorf("unable to record the run: %w", err)
+	}
+
+	if err := scheduler.Locker.Release(cleanupCtx, job.Name, scheduler.Owner); err != nil {
+		return run, true, fmt.Errorf("unable to release the lock: %w", err)
+	}
+
+	return run, true, jobErr
+}
@@ -0,0 +1,187 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/stretchr/testify/require"
+)
+
+// ## Helper functions
+// memoryLock is the state of the lock of a job
+type memoryLock struct {
+	owner       string
+	expiresAt   time.Time
+	scheduledAt time.Time
+}
+
+// memoryLocker stores the locks in a map, like the job_locks collection
+type memoryLocker struct {
+	mutex sync.Mutex
+	locks map[string]memoryLock
+}
+
+func (locker *memoryLocker) Acquire(ctx context.Context, job string, owner string, scheduledAt time.Time, expiration time.Time) (bool, error) {
+	locker.mutex.Lock()
+	defer locker.mutex.Unlock()
+
+	lock, ok := locker.locks[job]
+	if ok && (lock.expiresAt.After(time.Now()) || !lock.scheduledAt.Before(scheduledAt)) {
+		return false, nil
+	}
+
+	locker.locks[job] = memoryLock{owner: owner, expiresAt: expiration, scheduledAt: scheduledAt}
+	return true, nil
+}
+
+func (locker *memoryLocker) Release(ctx context.Context, job string, owner string) error {
+	locker.mutex.Lock()
+	defer locker.mutex.Unlock()
+
+	if lock, ok := locker.locks[job]; ok && lock.owner == owner {
+		lock.expiresAt = time.Now()
+		locker.locks[job] = lock
+	}
+
+	return nil
+}
+
+// memoryHistory stores the runs in a slice
+type memoryHistory struct {
+	runs []interfaces.JobRun
+}
+
+func (history *memoryHistory) Add(ctx context.Context, run interfaces.JobRun) error {
+	history.runs = append(history.runs, run)
+	return nil
+}
+
+// mustParse parses the expression failing the test if it's invalid
+func mustParse(t *testing.T, expression string) Schedule {
+	schedule, err := ParseSchedule(expression)
+	require.NoError(t, err)
+	return schedule
+}
+
+// ## Tests
+
+// TestParseSchedule tests the invalid expressions are rejected
+func TestParseSchedule(t *testing.T) {
+	c := require.New(t)
+
+	for _, expression := range []string{"0 0 * * * *", "* * *", "60 * * * *", "* 24 * * *", "0 0 0 * *", "*/0 * * * *", "5-1 * * * *", "0 0 * foo *", "@every 5m"} {
+		_, err := ParseSchedule(expression)
+		c.Error(err, expression)
+	}
+
+	for _, expression := range []string{"*/15 * * * *", "0 0 1,15 * *", "30 4 * jan-jun mon-fri", "0 0 * * 7", "@daily", "5/20 1-10/3 * * *"} {
+		_, err := ParseSchedule(expression)
+		c.NoError(err, expression)
+	}
+}
+
+// TestScheduleNext tests the next times of the schedules
+func TestScheduleNext(t *testing.T) {
+	c := require.New(t)
+	after := time.Date(2023, time.January, 31, 23, 47, 30, 0, time.UTC)
+
+	c.Equal(time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC), mustParse(t, "@daily").Next(after))
+	c.Equal(time.Date(2023, time.January, 31, 23, 48, 0, 0, time.UTC), mustParse(t, "* * * * *").Next(after))
+	c.Equal(time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC), mustParse(t, "*/15 * * * *").Next(after))
+	c.Equal(time.Date(2023, time.February, 1, 1, 5, 0, 0, time.UTC), mustParse(t, "5/20 1-10/3 * * *").Next(after))
+	c.Equal(time.Date(2023, time.March, 31, 0, 0, 0, 0, time.UTC), mustParse(t, "0 0 31 * *").Next(after))
+
+	// 2023-02-05 is a sunday, when both days are restricted any of them matches
+	c.Equal(time.Date(2023, time.February, 5, 12, 0, 0, 0, time.UTC), mustParse(t, "0 12 * * 7").Next(after))
+	c.Equal(time.Date(2023, time.February, 3, 0, 0, 0, 0, time.UTC), mustParse(t, "0 0 3 * sun").Next(after))
+
+	// The times are in the location of the given time
+	bogota, err := time.LoadLocation("America/Bogota")
+	c.NoError(err)
+	next := mustParse(t, "@daily").Next(after.In(bogota))
+	c.Equal(time.Date(2023, time.February, 1, 5, 0, 0, 0, time.UTC), next.UTC())
+
+	// There is no 30th of February
+	c.True(mustParse(t, "0 0 30 2 *").Next(after).IsZero())
+}
+
+// TestExecute tests each occurrence of a job is run by a single replica and the runs are recorded
+func TestExecute(t *testing.T) {
+	c := require.New(t)
+	ctx := context.Background()
+	locker := &memoryLocker{locks: map[string]memoryLock{}}
+	history := &memoryHistory{}
+	replicas := []*Scheduler{
+		{Locker: locker, History: history, Owner: "first"},
+		{Locker: locker, History: history, Owner: "second"},
+	}
+
+	calls := 0
+	job := Job{Name: "job", Schedule: mustParse(t, "@hourly"), Run: func(ctx context.Context) (string, error) {
+		calls++
+		return "done", nil
+	}}
+
+	// 1. Only the first replica runs the occurrence, the late replica doesn't run it again after the lock is released
+	scheduledAt := time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC)
+	run, ran, err := replicas[0].Execute(ctx, job, scheduledAt)
+	c.NoError(err)
+	c.True(ran)
+	c.Equal("first", run.Owner)
+	c.Equal("done", run.Summary)
+
+	_, ran, err = replicas[1].Execute(ctx, job, scheduledAt)
+	c.NoError(err)
+	c.False(ran)
+	c.Equal(1, calls)
+
+	// 2. The next occurrence can be run by any replica
+	_, ran, err = replicas[1].Execute(ctx, job, scheduledAt.Add(time.Hour))
+	c.NoError(err)
+	c.True(ran)
+	c.Equal(2, calls)
+
+	// 3. The failed runs are recorded with the error
+	failing := Job{Name: "failing", Schedule: mustParse(t, "@hourly"), Run: func(ctx context.Context) (string, error) {
+		return "", errors.New("unable to do it")
+	}}
+
+	_, ran, err = replicas[0].Execute(ctx, failing, scheduledAt)
+	c.Error(err)
+	c.True(ran)
+
+	c.Len(history.runs, 3)
+	c.False(history.runs[2].Succeeded)
+	c.Equal("unable to do it", history.runs[2].Error)
+	c.Equal(scheduledAt.Unix(), history.runs[2].ScheduledAt)
+}
+
+// TestLockExpiration tests a lock held by a replica is not taken until it expires
+func TestLockExpiration(t *testing.T) {
+	c := require.New(t)
+	ctx := context.Background()
+	locker := &memoryLocker{locks: map[string]memoryLock{}}
+	scheduledAt := time.Now().Truncate(time.Minute)
+
+	// The first replica stopped while running the job
+	acquired, err := locker.Acquire(ctx, "job", "first", scheduledAt, time.Now().Add(time.Hour))
+	c.NoError(err)
+	c.True(acquired)
+
+	replica := &Scheduler{Locker: locker, History: &memoryHistory{}, Owner: "second"}
+	job := Job{Name: "job", Run: func(ctx context.Context) (string, error) { return "", nil }}
+
+	_, ran, err := replica.Execute(ctx, job, scheduledAt.Add(time.Minute))
+	c.NoError(err)
+	c.False(ran)
+
+	// The lock of the first replica expired
+	locker.locks["job"] = memoryLock{owner: "first", expiresAt: time.Now().Add(-time.Second), scheduledAt: scheduledAt}
+	_, ran, err = replica.Execute(ctx, job, scheduledAt.Add(time.Minute))
+	c.NoError(err)
+	c.True(ran)
+}
@@ -0,0 +1,56 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoLocker stores a document per job with the owner of the lock, its expiration and the last locked occurrence
+type mongoLocker struct {
+	collection *mongo.Collection
+}
+
+func (locker mongoLocker) Acquire(ctx context.Context, job string, owner string, scheduledAt time.Time, expiration time.Time) (bool, error) {
+	// The lock is free when it expired and the occurrence is newer than the last locked one. When the filter doesn't
+	// match, the upsert tries to insert a second document with the same id and fails
+	filter := bson.M{
+		"_id":          job,
+		"expires_at":   bson.M{"$lte": time.Now()},
+		"scheduled_at": bson.M{"$lt": scheduledAt},
+	}
+
+	update := bson.M{"$set": bson.M{
+		"owner":        owner,
+		"expires_at":   expiration,
+		"scheduled_at": scheduledAt,
+	}}
+
+	_, err := locker.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (locker mongoLocker) Release(ctx context.Context, job string, owner string) error {
+	// The last occurrence is kept so it's not run again by a late replica
+	_, err := locker.collection.UpdateOne(ctx, bson.M{"_id": job, "owner": owner}, bson.M{"$set": bson.M{"expires_at": time.Now()}})
+	return err
+}
+
+// mongoHistory stores the runs of the jobs in a collection
+type mongoHistory struct {
+	collection *mongo.Collection
+}
+
+func (history mongoHistory) Add(ctx context.Context, run interfaces.JobRun) error {
+	_, err := history.collection.InsertOne(ctx, run)
+	return err
+}
@@ -0,0 +1,165 @@
+package tests
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/configuration"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// ### Types / Structs
+type FakeOIDCIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+type fakeOIDCAuthorization struct {
+	identity  FakeOIDCIdentity
+	nonce     string
+	challenge string
+}
+
+// FakeOIDCProvider is a local OpenID Connect provider to test the social login without calling external services
+type FakeOIDCProvider struct {
+	URL      string
+	ClientId string
+	// Sign the ID tokens with a key that is not published in the JWKS
+	SignWithUnknownKey bool
+
+	listener       *httptest.Server
+	key            *rsa.PrivateKey
+	unknownKey     *rsa.PrivateKey
+	authorizations map[string]fakeOIDCAuthorization
+	mutex          sync.Mutex
+}
+
+// NewFakeOIDCProvider starts a fake provider and registers it with the given name in the configuration
+func NewFakeOIDCProvider(config *configuration.Config, name string) *FakeOIDCProvider {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	unknownKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	provider := &FakeOIDCProvider{
+		ClientId:       "loomies-test-client",
+		key:            key,
+		unknownKey:     unknownKey,
+		authorizations: make(map[string]fakeOIDCAuthorization),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", provider.handleToken)
+	mux.HandleFunc("/jwks", provider.handleJwks)
+	provider.listener = httptest.NewServer(mux)
+	provider.URL = provider.listener.URL
+
+	if config.OIDC == nil {
+		config.OIDC = make(map[string]configuration.TOIDCProvider)
+	}
+
+	name = strings.ToLower(name)
+	config.OIDC[name] = configuration.TOIDCProvider{
+		Name:                  name,
+		ClientId:              provider.ClientId,
+		ClientSecret:          "loomies-test-secret",
+		Issuer:                provider.URL,
+		AuthorizationEndpoint: provider.URL + "/authorize",
+		TokenEndpoint:         provider.URL + "/token",
+		JwksUri:               provider.URL + "/jwks",
+		RedirectUri:           "loomies://oidc/callback",
+		Scopes:                "openid email profile",
+	}
+
+	return provider
+}
+
+// Close stops the fake provider
+func (provider *FakeOIDCProvider) Close() {
+	provider.listener.Close()
+}
+
+// Authorize simulates the user logging in with the given identity and returns the authorization code
+func (provider *FakeOIDCProvider) Authorize(authorizationUrl string, identity FakeOIDCIdentity) string {
+	parsedUrl, _ := url.Parse(authorizationUrl)
+	query := parsedUrl.Query()
+	code := base64.RawURLEncoding.EncodeToString([]byte(identity.Subject + time.Now().String()))
+
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	provider.authorizations[code] = fakeOIDCAuthorization{
+		identity:  identity,
+		nonce:     query.Get("nonce"),
+		challenge: query.Get("code_challenge"),
+	}
+
+	return code
+}
+
+func (provider *FakeOIDCProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	provider.mutex.Lock()
+	authorization, ok := provider.authorizations[r.PostForm.Get("code")]
+	delete(provider.authorizations, r.PostForm.Get("code"))
+	provider.mutex.Unlock()
+
+	// Validate the code and the PKCE verifier
+	verifierHash := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	challenge := base64.RawURLEncoding.EncodeToString(verifierHash[:])
+
+	if !ok || challenge != authorization.challenge || r.PostForm.Get("client_id") != provider.ClientId {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            provider.URL,
+		"aud":            provider.ClientId,
+		"sub":            authorization.identity.Subject,
+		"email":          authorization.identity.Email,
+		"email_verified": authorization.identity.EmailVerified,
+		"nonce":          authorization.nonce,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Minute * 5).Unix(),
+	})
+	idToken.Header["kid"] = "fake-key"
+
+	signingKey := provider.key
+	if provider.SignWithUnknownKey {
+		signingKey = provider.unknownKey
+	}
+
+	signedToken, _ := idToken.SignedString(signingKey)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "fake-access-token",
+		"token_type":   "Bearer",
+		"id_token":     signedToken,
+	})
+}
+
+func (provider *FakeOIDCProvider) handleJwks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kid": "fake-key",
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(provider.key.PublicKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(provider.key.PublicKey.E)).Bytes()),
+		}},
+	})
+}
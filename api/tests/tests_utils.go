@@ -8,11 +8,10 @@ import (
 	"net/http/httptest"
 
 	"github.com/PedroChaparro/loomies-backend/interfaces"
-	"github.com/PedroChaparro/loomies-backend/models"
+	"github.com/PedroChaparro/loomies-backend/repositories"
 	"github.com/gin-gonic/gin"
 	"github.com/jaswdr/faker"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // ### Types / Structs
@@ -88,46 +87,17 @@ func InsertUser(user interfaces.User, router *gin.Engine, handler gin.HandlerFun
 	router.ServeHTTP(w, req)
 }
 
-// DeleteUser Deletes a user from the database
-func DeleteUser(email string, id primitive.ObjectID) error {
-	// -------------------------
-	// Remove the user from the users collection
-	// -------------------------
-	_, err := models.UserCollection.DeleteOne(context.Background(), bson.D{{Key: "email", Value: email}})
+// DeleteUser Deletes the user with the given email (if any) and its references
+func DeleteUser(repos repositories.Repositories, email string) error {
+	user, err := repos.Users.GetUserByEmail(email)
 
-	if err != nil {
-		return err
-	}
-
-	// -------------------------
-	// Remove the user references from the authentication codes collection
-	// -------------------------
-
-	_, err = models.AuthenticationCodesCollection.DeleteMany(context.Background(), bson.D{{Key: "email", Value: email}})
-
-	if err != nil {
-		return err
+	if err == mongo.ErrNoDocuments {
+		return nil
 	}
 
-	// -------------------------
-	// Remove the user references from the gyms collection
-	// -------------------------
-
-	// Remove it from the owner field
-	_, err = models.GymsCollection.UpdateMany(context.Background(), bson.D{{Key: "owner", Value: id}}, bson.D{{Key: "$set", Value: bson.D{{Key: "owner", Value: nil}}}})
-
-	if err != nil {
-		return err
-	}
-
-	// Pull it from the rewards_claimed_by array
-	_, err = models.GymsCollection.UpdateMany(context.Background(), bson.M{}, bson.D{{Key: "$pull", Value: bson.D{{Key: "rewards_claimed_by", Value: id}}}})
-
 	if err != nil {
 		return err
 	}
 
-	// Remove the user references from the caught loomies collection
-	_, err = models.CaughtLoomiesCollection.UpdateMany(context.Background(), bson.D{{Key: "owner", Value: id}}, bson.D{{Key: "$set", Value: bson.D{{Key: "owner", Value: nil}}}})
-	return err
+	return repos.Users.DeleteUserAccount(context.Background(), user)
 }
@@ -4,12 +4,13 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
-	"strconv"
+	"strings"
 	"time"
 	"unicode"
 
 	"github.com/PedroChaparro/loomies-backend/configuration"
 	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // CheckPasswordSchema checks if the given password is valid
@@ -44,15 +45,64 @@ func GetRandomFloat(min float64, max float64) float64 {
 	return rand.Float64()*(max-min) + min
 }
 
-// GetRandomCoordinatesNear returns a random coordinates near the given coordinates
-func GetRandomCoordinatesNear(coordinates interfaces.Coordinates) interfaces.Coordinates {
-	radius := configuration.GetLoomiesGenerationRadius()
-	latitude := GetRandomFloat(coordinates.Latitude-radius, coordinates.Latitude+radius)
-	longitude := GetRandomFloat(coordinates.Longitude-radius, coordinates.Longitude+radius)
+// DrawWeighted returns the indexes of up to count weights drawn at random by weight. The drawn weights are removed
+// to avoid repeating the same index, and the weights lower or equal to 0 are never drawn
+func DrawWeighted(weights []float64, count int) []int {
+	drawn := []int{}
+	candidates := []int{}
+
+	for index, weight := range weights {
+		if weight > 0 {
+			candidates = append(candidates, index)
+		}
+	}
+
+	for len(drawn) < count && len(candidates) > 0 {
+		totalWeight := 0.0
+		for _, index := range candidates {
+			totalWeight += weights[index]
+		}
+
+		// Select the candidate where the random weight falls
+		selection := GetRandomFloat(0, totalWeight)
+		position := 0
+
+		for ; position < len(candidates)-1; position++ {
+			selection -= weights[candidates[position]]
+			if selection < 0 {
+				break
+			}
+		}
+
+		drawn = append(drawn, candidates[position])
+		candidates = append(candidates[:position], candidates[position+1:]...)
+	}
+
+	return drawn
+}
+
+// Mean radius of the earth (in meters) used to convert the distances
+const EarthRadius = 6371008.8
+
+// GetRandomCoordinatesNear returns a random coordinates near the given coordinates (at most at the given radius
+// in meters)
+func GetRandomCoordinatesNear(coordinates interfaces.Coordinates, radius float64) interfaces.Coordinates {
+	// Pick a random distance and bearing, the square root spreads the points uniformly in the circle
+	distance := radius * math.Sqrt(GetRandomFloat(0, 1)) / EarthRadius
+	bearing := GetRandomFloat(0, 2*math.Pi)
+	latitude := coordinates.Latitude * math.Pi / 180
+	longitude := coordinates.Longitude * math.Pi / 180
+
+	newLatitude := math.Asin(math.Sin(latitude)*math.Cos(distance) + math.Cos(latitude)*math.Sin(distance)*math.Cos(bearing))
+	newLongitude := longitude + math.Atan2(
+		math.Sin(bearing)*math.Sin(distance)*math.Cos(latitude),
+		math.Cos(distance)-math.Sin(latitude)*math.Sin(newLatitude),
+	)
 
 	return interfaces.Coordinates{
-		Latitude:  latitude,
-		Longitude: longitude,
+		Latitude: newLatitude * 180 / math.Pi,
+		// Normalize the longitude to [-180, 180) when crossing the antimeridian
+		Longitude: math.Mod(newLongitude*180/math.Pi+540, 360) - 180,
 	}
 }
 
@@ -67,59 +117,72 @@ func GetValidationCode() string {
 	return validationCode
 }
 
-// GetZoneCoordinatesFromGPS returns the (x, y) coordinates of the zone that contains the given coordinates
-func GetZoneCoordinatesFromGPS(coordinates interfaces.Coordinates) (int, int) {
-	// initial zones calculations
-	const initialLatitude = 6.9595
-	const initialLongitude = -73.1696
-	const sizeMinZone = 0.0035
-
-	coordX := math.Floor((coordinates.Longitude - initialLongitude) / sizeMinZone)
-	coordY := math.Floor((coordinates.Latitude - initialLatitude) / sizeMinZone)
+// GetZoneCoordinatesFromGPS returns the (x, y) coordinates of the zone that contains the given coordinates in the grid
+// of the region
+func GetZoneCoordinatesFromGPS(region interfaces.Region, coordinates interfaces.Coordinates) (int, int) {
+	coordX := math.Floor((coordinates.Longitude - region.Bounds.MinLongitude) / region.GridStep)
+	coordY := math.Floor((coordinates.Latitude - region.Bounds.MinLatitude) / region.GridStep)
 	return int(coordX), int(coordY)
 }
 
-// IsNear returns true if the target coordinates are near the origin coordinates
-func IsNear(target interfaces.Coordinates, origin interfaces.Coordinates) bool {
-	zoneRadiusStr := configuration.GetEnvironmentVariable("GAME_ZONE_RADIUS")
-	zoneRadius, _ := strconv.ParseFloat(zoneRadiusStr, 64)
+// GetZoneKey returns the identifier of the zone (x, y) in the region, unique across all the regions
+func GetZoneKey(region interfaces.Region, coordX, coordY int) string {
+	return fmt.Sprintf("%s:%d,%d", region.Name, coordX, coordY)
+}
 
-	if math.Abs(target.Latitude-origin.Latitude) > zoneRadius || math.Abs(target.Longitude-origin.Longitude) > zoneRadius {
-		return false
+// GetZoneKeyRegion returns the name of the region of the zone identifier (See GetZoneKey), empty if it doesn't have one
+func GetZoneKeyRegion(zoneKey string) string {
+	separator := strings.LastIndex(zoneKey, ":")
+
+	if separator < 0 {
+		return ""
 	}
 
-	return true
+	return zoneKey[:separator]
 }
 
-// GetNearZonesCoordinates returns the coordinates of the zones near the given coordinates
-func GetNearZonesCoordinates(userCoords interfaces.Coordinates) []string {
-	coordX, coordY := GetZoneCoordinatesFromGPS(userCoords)
+// GetLocation returns the location of the IANA time zone (Eg. America/Bogota), UTC if it's empty or unknown
+func GetLocation(timezone string) *time.Location {
+	location, err := time.LoadLocation(timezone)
 
-	// Get the zones that are near the current zone
-	var nearZonesCoordinates []string
-	nearZonesCoordinates = append(nearZonesCoordinates, fmt.Sprintf("%v,%v", coordX-1, coordY+1)) // Box Top Left
-	nearZonesCoordinates = append(nearZonesCoordinates, fmt.Sprintf("%v,%v", coordX, coordY+1))   // Box Top - North
-	nearZonesCoordinates = append(nearZonesCoordinates, fmt.Sprintf("%v,%v", coordX+1, coordY+1)) // Box Top Right
-	nearZonesCoordinates = append(nearZonesCoordinates, fmt.Sprintf("%v,%v", coordX-1, coordY))   // Box Left
-	nearZonesCoordinates = append(nearZonesCoordinates, fmt.Sprintf("%v,%v", coordX, coordY))     // current zone box
-	nearZonesCoordinates = append(nearZonesCoordinates, fmt.Sprintf("%v,%v", coordX+1, coordY))   // Box Right
-	nearZonesCoordinates = append(nearZonesCoordinates, fmt.Sprintf("%v,%v", coordX-1, coordY-1)) // Box Bottom Left
-	nearZonesCoordinates = append(nearZonesCoordinates, fmt.Sprintf("%v,%v", coordX, coordY-1))   // Box Bottom - South
-	nearZonesCoordinates = append(nearZonesCoordinates, fmt.Sprintf("%v,%v", coordX+1, coordY-1)) // Box Bottom Right
+	if err != nil || timezone == "" {
+		return time.UTC
+	}
+
+	return location
+}
+
+// IsNear returns true if the target coordinates are close enough to the origin coordinates to interact with them
+func IsNear(target interfaces.Coordinates, origin interfaces.Coordinates, game configuration.TGameSettings) bool {
+	return IsWithinDistance(target, origin, game.InteractionRadius)
+}
 
-	return nearZonesCoordinates
+// IsWithinDistance returns true if the target coordinates are at most at the given distance (in meters) from the origin
+func IsWithinDistance(target interfaces.Coordinates, origin interfaces.Coordinates, distance float64) bool {
+	return GetDistance(target, origin) <= distance
+}
+
+// GetDistance returns the great-circle distance (in meters) between the coordinates using the haversine formula
+func GetDistance(target interfaces.Coordinates, origin interfaces.Coordinates) float64 {
+	targetLatitude := target.Latitude * math.Pi / 180
+	originLatitude := origin.Latitude * math.Pi / 180
+	deltaLatitude := targetLatitude - originLatitude
+	deltaLongitude := (target.Longitude - origin.Longitude) * math.Pi / 180
+
+	haversine := math.Pow(math.Sin(deltaLatitude/2), 2) +
+		math.Cos(targetLatitude)*math.Cos(originLatitude)*math.Pow(math.Sin(deltaLongitude/2), 2)
+
+	return 2 * EarthRadius * math.Asin(math.Min(1, math.Sqrt(haversine)))
 }
 
 // GetLoomiesExperience returns the experience needed to reach the given level
-func GetRequiredExperience(level int) float64 {
-	min, factor := configuration.GetLoomiesExperienceParameters()
-	return math.Log10(float64(level))*factor + min
+func GetRequiredExperience(level int, balance configuration.TGameBalance) float64 {
+	return math.Log10(float64(level))*balance.LoomieExperienceFactor + balance.MinLoomieRequiredExperience
 }
 
 // GetLevelFromExperience returns the level of the given experience
-func GetLevelFromExperience(experience float64) int {
-	min, factor := configuration.GetLoomiesExperienceParameters()
-	return int(math.Pow(10, (experience-min)/factor))
+func GetLevelFromExperience(experience float64, balance configuration.TGameBalance) int {
+	return int(math.Pow(10, (experience-balance.MinLoomieRequiredExperience)/balance.LoomieExperienceFactor))
 }
 
 // FixeFloat Returns the given float with the given number of decimals
@@ -141,3 +204,119 @@ func GetRandomLevel() int {
 
 	return level
 }
+
+// GetTrainerRequiredExperience returns the total experience a trainer needs to reach the given level
+func GetTrainerRequiredExperience(level int, settings configuration.TTrainerSettings) float64 {
+	if level <= 1 {
+		return 0
+	}
+
+	return math.Round(settings.BaseExperience * math.Pow(float64(level-1), settings.ExperienceExponent))
+}
+
+// GetTrainerLevelFromExperience returns the trainer level of the given total experience
+func GetTrainerLevelFromExperience(experience float64, settings configuration.TTrainerSettings) int {
+	level := 1
+
+	for level < settings.MaxLevel && experience >= GetTrainerRequiredExperience(level+1, settings) {
+		level++
+	}
+
+	return level
+}
+
+// GetTrainerCaptureBonus returns the extra capture chance (in percentage points) given by the trainer level
+func GetTrainerCaptureBonus(level int) int {
+	if level <= 1 {
+		return 0
+	}
+
+	return int(math.Min(float64(level-1), 20))
+}
+
+// WasSuccessfulCapture Check if the loomie was successful capture (Calculate the chance of success)
+func WasSuccessfulCapture(loomie interfaces.WildLoomie, ball interfaces.Loomball, trainerLevel int) bool {
+	chance := 0
+	capture := GetRandomInt(0, 100)
+
+	if loomie.Level >= ball.DecayUntil {
+		chance = int(ball.MinimumProbability * 100)
+	} else if loomie.Level <= int(ball.EffectiveUntil) {
+		chance = 100
+	} else {
+		chance = -((100-int(ball.MinimumProbability*100))/(ball.DecayUntil-ball.EffectiveUntil))*(loomie.Level-ball.EffectiveUntil) + 100
+	}
+
+	// Experienced trainers have better odds
+	chance += GetTrainerCaptureBonus(trainerLevel)
+
+	if capture <= chance {
+		return true
+	}
+	return false
+}
+
+// CheckIfUserInArrayOfWildLoomie check if user id alrady exists in array CapturedBy from wild loomie
+func CheckIfUserInArrayOfWildLoomie(loomie interfaces.WildLoomie, user interfaces.User) bool {
+	for _, element := range loomie.CapturedBy {
+		if element == user.Id {
+			return false
+		}
+	}
+
+	return true
+}
+
+// HasUserClaimedReward returns if the user has already claimed the reward for the given gym
+func HasUserClaimedReward(claimed []primitive.ObjectID, userID primitive.ObjectID) bool {
+	for _, userId := range claimed {
+		if userId == userID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasBlocked "private" function to check if the user has the other user in the block list
+func hasBlocked(user interfaces.User, otherUserId primitive.ObjectID) bool {
+	for _, blockedId := range user.BlockedUsers {
+		if blockedId == otherUserId {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsBlockedBetween Returns true if any of the users blocked the other one
+func IsBlockedBetween(firstUser interfaces.User, secondUser interfaces.User) bool {
+	return hasBlocked(firstUser, secondUser.Id) || hasBlocked(secondUser, firstUser.Id)
+}
+
+// GetPeriod returns the key and the expiration time of the current "daily" or "weekly" period, the periods start at
+// the midnight of the given location (Eg. the timezone of the user region)
+func GetPeriod(period string, now time.Time, location *time.Location) (string, time.Time) {
+	now = now.In(location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+
+	if period == "weekly" {
+		// The weeks start on monday
+		daysSinceMonday := (int(today.Weekday()) + 6) % 7
+		year, week := today.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week), today.AddDate(0, 0, 7-daysSinceMonday)
+	}
+
+	return today.Format("2006-01-02"), today.AddDate(0, 0, 1)
+}
+
+// GetGiftsSentToday Returns the number of gifts sent by the user in the current day of the given location
+func GetGiftsSentToday(user interfaces.User, location *time.Location) int {
+	day, _ := GetPeriod("daily", time.Now(), location)
+
+	if user.GiftsSentDay != day {
+		return 0
+	}
+
+	return user.GiftsSent
+}
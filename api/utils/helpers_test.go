@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGetDistance tests the distances are the same at any latitude and across the antimeridian
+func TestGetDistance(t *testing.T) {
+	c := require.New(t)
+
+	// A degree of latitude is ~111 kilometers everywhere
+	c.InDelta(111195, GetDistance(interfaces.Coordinates{Latitude: 7, Longitude: -73}, interfaces.Coordinates{Latitude: 8, Longitude: -73}), 1)
+	c.InDelta(111195, GetDistance(interfaces.Coordinates{Latitude: 60, Longitude: 10}, interfaces.Coordinates{Latitude: 61, Longitude: 10}), 1)
+
+	// A degree of longitude shrinks with the latitude
+	c.InDelta(55597, GetDistance(interfaces.Coordinates{Latitude: 60, Longitude: 10}, interfaces.Coordinates{Latitude: 60, Longitude: 11}), 5)
+	c.InDelta(22239, GetDistance(interfaces.Coordinates{Latitude: 0, Longitude: 179.9}, interfaces.Coordinates{Latitude: 0, Longitude: -179.9}), 1)
+
+	origin := interfaces.Coordinates{Latitude: 60, Longitude: 10}
+	c.True(IsWithinDistance(interfaces.Coordinates{Latitude: 60, Longitude: 10.005}, origin, 390))
+	c.False(IsWithinDistance(interfaces.Coordinates{Latitude: 60.005, Longitude: 10}, origin, 390))
+}
+
+// TestGetRandomCoordinatesNear tests the generated coordinates are inside the generation radius
+func TestGetRandomCoordinatesNear(t *testing.T) {
+	c := require.New(t)
+
+	for _, origin := range []interfaces.Coordinates{{Latitude: 7.1, Longitude: -73.1}, {Latitude: 69.6, Longitude: 18.9}, {Latitude: -10, Longitude: 179.999}} {
+		for i := 0; i < 100; i++ {
+			coordinates := GetRandomCoordinatesNear(origin, 195)
+			c.LessOrEqual(GetDistance(coordinates, origin), 195.001)
+			c.True(coordinates.Longitude >= -180 && coordinates.Longitude < 180)
+		}
+	}
+}
+
+// TestGetZoneCoordinatesFromGPS tests the zones are counted from the south-west corner of the region
+func TestGetZoneCoordinatesFromGPS(t *testing.T) {
+	c := require.New(t)
+	region := interfaces.Region{
+		Name:     "bucaramanga",
+		Bounds:   interfaces.RegionBounds{MinLatitude: 6.9595, MinLongitude: -73.1696, MaxLatitude: 7.173, MaxLongitude: -73.0016},
+		GridStep: 0.0035,
+	}
+
+	x, y := GetZoneCoordinatesFromGPS(region, interfaces.Coordinates{Latitude: 6.96125, Longitude: -73.16785})
+	c.Equal(0, x)
+	c.Equal(0, y)
+
+	x, y = GetZoneCoordinatesFromGPS(region, interfaces.Coordinates{Latitude: 7.03825, Longitude: -73.07138})
+	c.Equal(28, x)
+	c.Equal(22, y)
+	c.Equal("bucaramanga:28,22", GetZoneKey(region, x, y))
+	c.True(region.Contains(interfaces.Coordinates{Latitude: 7.03825, Longitude: -73.07138}))
+	c.False(region.Contains(interfaces.Coordinates{Latitude: 7.2, Longitude: -73.07138}))
+}
+
+// TestDrawWeighted tests the drawn indexes are not repeated and the weights lower or equal to 0 are skipped
+func TestDrawWeighted(t *testing.T) {
+	c := require.New(t)
+	weights := []float64{10, 0, 5, -1, 1}
+
+	for i := 0; i < 100; i++ {
+		drawn := DrawWeighted(weights, 2)
+		c.Len(drawn, 2)
+		c.NotEqual(drawn[0], drawn[1])
+
+		for _, index := range drawn {
+			c.Contains([]int{0, 2, 4}, index)
+		}
+	}
+
+	// There are only 3 candidates
+	c.ElementsMatch([]int{0, 2, 4}, DrawWeighted(weights, 5))
+	c.Empty(DrawWeighted([]float64{0, 0}, 1))
+}
+
+// TestGetPeriod tests the daily and weekly periods start at the midnight of the given location
+func TestGetPeriod(t *testing.T) {
+	c := require.New(t)
+	bogota := GetLocation("America/Bogota")
+
+	// Monday 02:00 UTC is still Sunday in Bogota (UTC-5)
+	now := time.Date(2023, 5, 8, 2, 0, 0, 0, time.UTC)
+
+	day, expiresAt := GetPeriod("daily", now, time.UTC)
+	c.Equal("2023-05-08", day)
+	c.Equal(time.Date(2023, 5, 9, 0, 0, 0, 0, time.UTC).Unix(), expiresAt.Unix())
+
+	day, expiresAt = GetPeriod("daily", now, bogota)
+	c.Equal("2023-05-07", day)
+	c.Equal(time.Date(2023, 5, 8, 5, 0, 0, 0, time.UTC).Unix(), expiresAt.Unix())
+
+	week, _ := GetPeriod("weekly", now, time.UTC)
+	c.Equal("2023-W19", week)
+	week, expiresAt = GetPeriod("weekly", now, bogota)
+	c.Equal("2023-W18", week)
+	c.Equal(time.Date(2023, 5, 8, 5, 0, 0, 0, time.UTC).Unix(), expiresAt.Unix())
+
+	// The zones keys have the region name and the unknown timezones fallback to UTC
+	c.Equal("bucaramanga", GetZoneKeyRegion("bucaramanga:28,22"))
+	c.Equal("", GetZoneKeyRegion("28,22"))
+	c.Equal(time.UTC, GetLocation(""))
+	c.Equal(time.UTC, GetLocation("Mars/Olympus"))
+}
@@ -0,0 +1,261 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/configuration"
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// jwksCacheTTL is the time the keys of a JWKS endpoint are kept in memory
+const jwksCacheTTL = 1 * time.Hour
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type cachedJwks struct {
+	keys      map[string]*rsa.PublicKey
+	expiresAt time.Time
+}
+
+var jwksCache = struct {
+	sync.Mutex
+	entries map[string]cachedJwks
+}{entries: make(map[string]cachedJwks)}
+
+var oidcHttpClient = &http.Client{Timeout: 10 * time.Second}
+
+// GetRandomUrlSafeString returns a random base64 (url encoding) string generated from the given amount of bytes
+func GetRandomUrlSafeString(size int) string {
+	buffer := make([]byte, size)
+	rand.Read(buffer)
+	return base64.RawURLEncoding.EncodeToString(buffer)
+}
+
+// GetPKCEChallenge returns the S256 code challenge for the given PKCE code verifier
+func GetPKCEChallenge(verifier string) string {
+	hash := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// GetOIDCAuthorizationURL returns the url the user should visit to authenticate with the provider
+func GetOIDCAuthorizationURL(provider configuration.TOIDCProvider, state string, nonce string, codeVerifier string) string {
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", provider.ClientId)
+	query.Set("redirect_uri", provider.RedirectUri)
+	query.Set("scope", provider.Scopes)
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", GetPKCEChallenge(codeVerifier))
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(provider.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return provider.AuthorizationEndpoint + separator + query.Encode()
+}
+
+// ExchangeOIDCCode exchanges the authorization code for the tokens and returns the ID token
+func ExchangeOIDCCode(provider configuration.TOIDCProvider, code string, codeVerifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", provider.RedirectUri)
+	form.Set("client_id", provider.ClientId)
+	form.Set("code_verifier", codeVerifier)
+
+	if provider.ClientSecret != "" {
+		form.Set("client_secret", provider.ClientSecret)
+	}
+
+	response, err := oidcHttpClient.PostForm(provider.TokenEndpoint, form)
+	if err != nil {
+		fmt.Println(err)
+		return "", errors.New("Unable to reach the identity provider")
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", errors.New("The identity provider rejected the authorization code")
+	}
+
+	var tokens struct {
+		IdToken string `json:"id_token"`
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(&tokens); err != nil || tokens.IdToken == "" {
+		return "", errors.New("The identity provider didn't return an ID token")
+	}
+
+	return tokens.IdToken, nil
+}
+
+// getJwksKeys returns the RSA keys published in the given JWKS endpoint (cached for one hour)
+func getJwksKeys(jwksUri string, forceRefresh bool) (map[string]*rsa.PublicKey, error) {
+	jwksCache.Lock()
+	defer jwksCache.Unlock()
+
+	cached, ok := jwksCache.entries[jwksUri]
+	if ok && !forceRefresh && time.Now().Before(cached.expiresAt) {
+		return cached.keys, nil
+	}
+
+	response, err := oidcHttpClient.Get(jwksUri)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unexpected JWKS status code %d", response.StatusCode)
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(&jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+
+	for _, key := range jwks.Keys {
+		// Only RSA keys are supported
+		if key.Kty != "RSA" {
+			continue
+		}
+
+		modulus, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			continue
+		}
+
+		exponent, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil {
+			continue
+		}
+
+		keys[key.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(modulus),
+			E: int(new(big.Int).SetBytes(exponent).Int64()),
+		}
+	}
+
+	jwksCache.entries[jwksUri] = cachedJwks{keys: keys, expiresAt: time.Now().Add(jwksCacheTTL)}
+	return keys, nil
+}
+
+// hasAudience returns true if the "aud" claim (string or array) contains the given client id
+func hasAudience(claims jwt.MapClaims, clientId string) bool {
+	switch audience := claims["aud"].(type) {
+	case string:
+		return audience == clientId
+	case []interface{}:
+		for _, value := range audience {
+			if value == clientId {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ValidateOIDCIdToken validates the signature (against the provider JWKS), issuer, audience, expiration and nonce of the
+// given ID token and returns its claims
+func ValidateOIDCIdToken(provider configuration.TOIDCProvider, idToken string, nonce string) (interfaces.OIDCIdTokenClaims, error) {
+	claims := jwt.MapClaims{}
+
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, errors.New("Unexpected signing method")
+		}
+
+		kid, _ := token.Header["kid"].(string)
+		keys, err := getJwksKeys(provider.JwksUri, false)
+		if err != nil {
+			return nil, err
+		}
+
+		// The provider could have rotated its keys
+		key, ok := keys[kid]
+		if !ok {
+			keys, err = getJwksKeys(provider.JwksUri, true)
+			if err != nil {
+				return nil, err
+			}
+
+			key, ok = keys[kid]
+		}
+
+		if !ok {
+			return nil, errors.New("Unknown signing key")
+		}
+
+		return key, nil
+	})
+
+	if err != nil {
+		return interfaces.OIDCIdTokenClaims{}, errors.New("Invalid ID token (signature)")
+	}
+
+	if claims["iss"] != provider.Issuer {
+		return interfaces.OIDCIdTokenClaims{}, errors.New("Invalid ID token (issuer)")
+	}
+
+	if !hasAudience(claims, provider.ClientId) {
+		return interfaces.OIDCIdTokenClaims{}, errors.New("Invalid ID token (audience)")
+	}
+
+	// The "exp" claim is required for ID tokens
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return interfaces.OIDCIdTokenClaims{}, errors.New("ID token expired")
+	}
+
+	if claims["nonce"] != nonce {
+		return interfaces.OIDCIdTokenClaims{}, errors.New("Invalid ID token (nonce)")
+	}
+
+	result := interfaces.OIDCIdTokenClaims{}
+	result.Subject, _ = claims["sub"].(string)
+	result.Email, _ = claims["email"].(string)
+	result.Name, _ = claims["name"].(string)
+	result.PreferredUsername, _ = claims["preferred_username"].(string)
+
+	// Some providers (e.g. Apple) send the email_verified claim as a string
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		result.EmailVerified = verified
+	case string:
+		result.EmailVerified = verified == "true"
+	}
+
+	if result.Subject == "" {
+		return interfaces.OIDCIdTokenClaims{}, errors.New("Invalid ID token (subject)")
+	}
+
+	return result, nil
+}
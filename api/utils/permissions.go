@@ -0,0 +1,75 @@
+package utils
+
+import "sort"
+
+// Roles that can be assigned to the users
+const (
+	RoleAdmin     = "admin"
+	RoleModerator = "moderator"
+)
+
+// Permissions checked by the management endpoints
+const (
+	PermissionManageRoles   = "roles:manage"
+	PermissionManageContent = "content:manage"
+	PermissionModerateUsers = "users:moderate"
+	PermissionReadAudit     = "audit:read"
+)
+
+// RolePermissions maps each role with the permissions it grants
+var RolePermissions = map[string][]string{
+	RoleAdmin: {
+		PermissionManageRoles,
+		PermissionManageContent,
+		PermissionModerateUsers,
+		PermissionReadAudit,
+	},
+	RoleModerator: {
+		PermissionModerateUsers,
+	},
+}
+
+// IsValidRole returns true if the given role exists
+func IsValidRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+// GetPermissionsFromRoles returns the (sorted and unique) permissions granted by the given roles
+func GetPermissionsFromRoles(roles []string) []string {
+	unique := make(map[string]bool)
+
+	for _, role := range roles {
+		for _, permission := range RolePermissions[role] {
+			unique[permission] = true
+		}
+	}
+
+	permissions := make([]string, 0, len(unique))
+	for permission := range unique {
+		permissions = append(permissions, permission)
+	}
+
+	sort.Strings(permissions)
+	return permissions
+}
+
+// HasPermissions returns true if all the required permissions are in the granted ones
+func HasPermissions(granted []string, required ...string) bool {
+	for _, permission := range required {
+		found := false
+
+		for _, grantedPermission := range granted {
+			if grantedPermission == permission {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
@@ -9,23 +9,24 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 )
 
-// secret keys
-var JWTKeyAC = configuration.GetAccessTokenSecret()
-var JWTKeyRF = configuration.GetRefreshTokenSecret()
-var JWTKeyWS = configuration.GetWsTokenSecret()
+// CreateAccessToken creates a new access token signed with the access token secret with the roles and permissions of the user
+func CreateAccessToken(secrets configuration.TTokensSettings, userID string, roles []string) (string, error) {
+	if roles == nil {
+		roles = []string{}
+	}
 
-// CreateAccessToken creates a new access token signed with the access token secret
-func CreateAccessToken(userID string) (string, error) {
 	// 30 minutes short lived token
 	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"userid":    userID,
-		"notBefore": time.Now(),
-		"expire":    time.Now().Add(time.Minute * 30),
+		"userid":      userID,
+		"roles":       roles,
+		"permissions": GetPermissionsFromRoles(roles),
+		"notBefore":   time.Now(),
+		"expire":      time.Now().Add(time.Minute * 30),
 	})
 
 	// sign with secret and get encoded token
 	var err error
-	accessTokenString, err := accessToken.SignedString([]byte(JWTKeyAC))
+	accessTokenString, err := accessToken.SignedString([]byte(secrets.AccessTokenSecret))
 	if err != nil {
 		return "", errors.New("Could not create access token")
 	}
@@ -34,7 +35,7 @@ func CreateAccessToken(userID string) (string, error) {
 }
 
 // CreateRefreshToken creates a new refresh token signed with the refresh token secret
-func CreateRefreshToken(userID string) (string, error) {
+func CreateRefreshToken(secrets configuration.TTokensSettings, userID string) (string, error) {
 	// 5 months long lived token
 	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"userid":    userID,
@@ -44,7 +45,7 @@ func CreateRefreshToken(userID string) (string, error) {
 
 	// sign with secret and get encoded token
 	var err error
-	refreshTokenString, err := refreshToken.SignedString([]byte(JWTKeyRF))
+	refreshTokenString, err := refreshToken.SignedString([]byte(secrets.RefreshTokenSecret))
 	if err != nil {
 		return "", errors.New("Could not create refresh token")
 	}
@@ -53,7 +54,7 @@ func CreateRefreshToken(userID string) (string, error) {
 }
 
 // CreateWsToken creates a new websocket token signed with the websocket token secret
-func CreateWsToken(userID string, gymId string, latitude float64, longitude float64) (string, error) {
+func CreateWsToken(secrets configuration.TTokensSettings, userID string, gymId string, latitude float64, longitude float64) (string, error) {
 	wsToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"user_id":   userID,
 		"gym_id":    gymId,
@@ -64,7 +65,7 @@ func CreateWsToken(userID string, gymId string, latitude float64, longitude floa
 	})
 
 	var err error
-	wsTokenString, err := wsToken.SignedString([]byte(JWTKeyWS))
+	wsTokenString, err := wsToken.SignedString([]byte(secrets.WsTokenSecret))
 	if err != nil {
 		return "", errors.New("Could not create websocket token")
 	}
@@ -72,17 +73,37 @@ func CreateWsToken(userID string, gymId string, latitude float64, longitude floa
 	return wsTokenString, nil
 }
 
-// ValidateRefreshToken validates the refresh token is valid and not expired and returns the user id
-func ValidateAccessToken(accessToken string) (string, error) {
+// CreateMfaToken creates a new "mfa_required" token of the challenge signed with the mfa token secret
+func CreateMfaToken(secrets configuration.TTokensSettings, userID string, challengeID string) (string, error) {
+	// 5 minutes to enter the authenticator code
+	mfaToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"userid":    userID,
+		"challenge": challengeID,
+		"purpose":   "mfa_required",
+		"notBefore": time.Now(),
+		"expire":    time.Now().Add(time.Minute * 5),
+	})
+
+	var err error
+	mfaTokenString, err := mfaToken.SignedString([]byte(secrets.MfaTokenSecret))
+	if err != nil {
+		return "", errors.New("Could not create mfa token")
+	}
+
+	return mfaTokenString, nil
+}
+
+// ValidateAccessToken validates the access token is valid and not expired and returns the token claims
+func ValidateAccessToken(secrets configuration.TTokensSettings, accessToken string) (interfaces.AccessTokenClaims, error) {
 	token, err := jwt.Parse(accessToken, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("Unexpected signing method")
 		}
-		return []byte(JWTKeyAC), nil
+		return []byte(secrets.AccessTokenSecret), nil
 	})
 
 	if err != nil {
-		return "", errors.New("Invalid access token (parse)")
+		return interfaces.AccessTokenClaims{}, errors.New("Invalid access token (parse)")
 	}
 
 	// validate token
@@ -90,26 +111,44 @@ func ValidateAccessToken(accessToken string) (string, error) {
 		exp, err := time.Parse(time.RFC3339, claims["expire"].(string))
 
 		if err != nil {
-			return "", errors.New("Invalid access token (expire format)")
+			return interfaces.AccessTokenClaims{}, errors.New("Invalid access token (expire format)")
 		}
 
 		if exp.Before(time.Now()) {
-			return "", errors.New("Access token expired")
+			return interfaces.AccessTokenClaims{}, errors.New("Access token expired")
 		}
 
-		return claims["userid"].(string), nil
+		return interfaces.AccessTokenClaims{
+			UserID:      claims["userid"].(string),
+			Roles:       getStringsClaim(claims, "roles"),
+			Permissions: getStringsClaim(claims, "permissions"),
+		}, nil
 	} else {
-		return "", errors.New("Invalid access token (claims)")
+		return interfaces.AccessTokenClaims{}, errors.New("Invalid access token (claims)")
 	}
 }
 
+// getStringsClaim returns the given array claim as a slice of strings (empty if it doesn't exist)
+func getStringsClaim(claims jwt.MapClaims, name string) []string {
+	values, _ := claims[name].([]interface{})
+	result := make([]string, 0, len(values))
+
+	for _, value := range values {
+		if str, ok := value.(string); ok {
+			result = append(result, str)
+		}
+	}
+
+	return result
+}
+
 // ValidateRefreshToken validates the refresh token is valid and not expired and returns the user id
-func ValidateRefreshToken(refreshToken string) (string, error) {
+func ValidateRefreshToken(secrets configuration.TTokensSettings, refreshToken string) (string, error) {
 	token, err := jwt.Parse(refreshToken, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("Unexpected signing method")
 		}
-		return []byte(JWTKeyRF), nil
+		return []byte(secrets.RefreshTokenSecret), nil
 	})
 
 	if err != nil {
@@ -135,12 +174,12 @@ func ValidateRefreshToken(refreshToken string) (string, error) {
 }
 
 // ValidateWsToken validates the websocket token is valid and not expired and returns the token claims
-func ValidateWsToken(wsToken string) (interfaces.WsTokenClaims, error) {
+func ValidateWsToken(secrets configuration.TTokensSettings, wsToken string) (interfaces.WsTokenClaims, error) {
 	token, err := jwt.Parse(wsToken, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("Unexpected signing method")
 		}
-		return []byte(JWTKeyWS), nil
+		return []byte(secrets.WsTokenSecret), nil
 	})
 
 	if err != nil {
@@ -169,3 +208,35 @@ func ValidateWsToken(wsToken string) (interfaces.WsTokenClaims, error) {
 		return interfaces.WsTokenClaims{}, errors.New("Invalid websocket token (claims)")
 	}
 }
+
+// ValidateMfaToken validates the mfa challenge token is valid and not expired and returns the user id and the challenge id
+func ValidateMfaToken(secrets configuration.TTokensSettings, mfaToken string) (string, string, error) {
+	token, err := jwt.Parse(mfaToken, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("Unexpected signing method")
+		}
+		return []byte(secrets.MfaTokenSecret), nil
+	})
+
+	if err != nil {
+		return "", "", errors.New("Invalid mfa token (parse)")
+	}
+
+	// validate token
+	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid && claims["purpose"] == "mfa_required" {
+		exp, err := time.Parse(time.RFC3339, claims["expire"].(string))
+
+		if err != nil {
+			return "", "", errors.New("Invalid mfa token (expire format)")
+		}
+
+		if exp.Before(time.Now()) {
+			return "", "", errors.New("Mfa token expired")
+		}
+
+		challengeID, _ := claims["challenge"].(string)
+		return claims["userid"].(string), challengeID, nil
+	} else {
+		return "", "", errors.New("Invalid mfa token (claims)")
+	}
+}
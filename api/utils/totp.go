@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	totpIssuer = "Loomies"
+	// Settings supported by most authenticator apps (RFC 6238 defaults)
+	totpPeriod = 30
+	totpDigits = 6
+	// Amount of steps before / after the current one accepted to tolerate clock drift
+	totpSkew = 1
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret returns a random base32 encoded secret for a new authenticator
+func GenerateTOTPSecret() string {
+	buffer := make([]byte, 20)
+	rand.Read(buffer)
+	return totpEncoding.EncodeToString(buffer)
+}
+
+// GetTOTPUri returns the otpauth:// uri to be shown as a QR code in the authenticator app
+func GetTOTPUri(secret string, account string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", totpIssuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+
+	label := url.PathEscape(totpIssuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// GetTOTPStep returns the time step of the given time
+func GetTOTPStep(t time.Time) int64 {
+	return t.Unix() / totpPeriod
+}
+
+// GetTOTPCode returns the code of the given secret for the given time step (RFC 6238)
+func GetTOTPCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation (RFC 4226 section 5.3)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%06d", value%1000000), nil
+}
+
+// ValidateTOTPCode checks the code against the steps near the current time and returns the matched step.
+// Steps lower or equal than lastUsedStep are rejected to avoid codes from being replayed
+func ValidateTOTPCode(secret string, code string, lastUsedStep int64) (int64, bool) {
+	if len(code) != totpDigits {
+		return 0, false
+	}
+
+	current := GetTOTPStep(time.Now())
+
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastUsedStep {
+			continue
+		}
+
+		expected, err := GetTOTPCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// GenerateRecoveryCodes returns the given amount of random one-time recovery codes (xxxxx-xxxxx)
+func GenerateRecoveryCodes(amount int) []string {
+	codes := make([]string, amount)
+
+	for i := range codes {
+		buffer := make([]byte, 7)
+		rand.Read(buffer)
+		code := strings.ToLower(totpEncoding.EncodeToString(buffer))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+
+	return codes
+}
+
+// HashRecoveryCode returns the hash of the recovery code to be stored in the database.
+// The codes are random enough to not require a slow hash like bcrypt
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+	hash := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,133 @@
+package world
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/paulmach/osm/osmapi"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Usage of the generate-world command
+const Usage = `Usage: loomies-admin generate-world -region <name> (-input <file> | -online) [options]
+
+The region must be registered first (POST /admin/regions). The zones are upserted, so the command can run again
+after the region grows, and the zones that already have a gym keep it.
+
+Options:
+  -region <name>      Name of the region to generate
+  -input <file>       Local OpenStreetMap extract (.osm or .osm.pbf)
+  -online             Download the points of interest from the OpenStreetMap API instead
+  -tags <tags>        Comma separated tags of the points used as gyms, like amenity,leisure=park
+  -seed <n>           Seed of the random choices (the current time by default)
+  -dry-run            Print the zones and gyms without saving them
+  -cell-size <deg>    Size of the areas downloaded in each request of the online mode (0.05 by default)
+  -delay <duration>   Time to wait between the requests of the online mode (3s by default)`
+
+// Options of the generate-world command
+type Options struct {
+	Region   string
+	Input    string
+	Online   bool
+	Tags     TagFilter
+	Seed     int64
+	DryRun   bool
+	CellSize float64
+	Delay    time.Duration
+}
+
+// ParseOptions parses the arguments of the generate-world command
+func ParseOptions(args []string) (Options, error) {
+	options := Options{}
+	flags := flag.NewFlagSet("generate-world", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+
+	tags := flags.String("tags", strings.Join(DefaultTags, ","), "")
+	flags.StringVar(&options.Region, "region", "", "")
+	flags.StringVar(&options.Input, "input", "", "")
+	flags.BoolVar(&options.Online, "online", false, "")
+	flags.Int64Var(&options.Seed, "seed", time.Now().UnixNano(), "")
+	flags.BoolVar(&options.DryRun, "dry-run", false, "")
+	flags.Float64Var(&options.CellSize, "cell-size", 0.05, "")
+	flags.DurationVar(&options.Delay, "delay", 3*time.Second, "")
+
+	if err := flags.Parse(args); err != nil {
+		return options, fmt.Errorf("%w\n%s", err, Usage)
+	}
+
+	if options.Region == "" {
+		return options, fmt.Errorf("missing region\n%s", Usage)
+	}
+
+	if (options.Input == "") == !options.Online {
+		return options, fmt.Errorf("either an input file or the online mode must be given\n%s", Usage)
+	}
+
+	if options.CellSize <= 0 || options.CellSize > 0.5 {
+		return options, fmt.Errorf("the cell size must be between 0 and 0.5 degrees\n%s", Usage)
+	}
+
+	var err error
+	if *tags != "" {
+		options.Tags, err = ParseTagFilter(strings.Split(*tags, ","))
+	}
+
+	return options, err
+}
+
+// source "private" function to get the source of the points of interest of the options
+func (options Options) source() Source {
+	if options.Online {
+		return APISource{Client: osmapi.DefaultDatasource, CellSize: options.CellSize, Delay: options.Delay}
+	}
+
+	return FileSource{Path: options.Input}
+}
+
+// Run generates the zones and gyms of the region and saves them (unless it's a dry run)
+func Run(ctx context.Context, store Store, options Options, out io.Writer) error {
+	region, err := store.GetRegion(ctx, options.Region)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return fmt.Errorf("the region %q is not registered", options.Region)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	places, err := options.source().Places(ctx, region.Bounds, options.Tags)
+	if err != nil {
+		return err
+	}
+
+	random := rand.New(rand.NewSource(options.Seed))
+	cells := Generate(region, places, random)
+
+	randomGyms := 0
+	for _, cell := range cells {
+		if cell.Random {
+			randomGyms++
+		}
+	}
+
+	fmt.Fprintf(out, "Found %d places in %s\n", len(places), region.Name)
+	fmt.Fprintf(out, "Generated %d zones, %d gyms in random points (seed %d)\n", len(cells), randomGyms, options.Seed)
+
+	if options.DryRun {
+		for _, cell := range cells {
+			fmt.Fprintf(out, "%-10s %s (%f, %f)\n", cell.Zone.Coordinates, cell.Gym.Name, cell.Gym.Latitude, cell.Gym.Longitude)
+		}
+
+		return nil
+	}
+
+	result, err := Save(ctx, store, cells, random)
+	fmt.Fprintf(out, "Saved %d zones, created %d gyms and kept %d gyms\n", result.Zones, result.CreatedGyms, result.KeptGyms)
+	return err
+}
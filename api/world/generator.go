@@ -0,0 +1,79 @@
+package world
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/PedroChaparro/loomies-backend/utils"
+	"github.com/jaswdr/faker"
+)
+
+// Cell is a zone of the region grid with its gym. Random is true when the zone had no points of interest and the
+// gym was placed in a random point
+type Cell struct {
+	Zone   interfaces.Zone
+	Gym    interfaces.Gym
+	Random bool
+}
+
+// Generate places a gym in every zone of the region grid. The gym is a random place of the zone or, when the zone
+// has no places, a random point away from the zone frontiers with a random street name. The zones are numbered from
+// the south-west corner, row by row
+func Generate(region interfaces.Region, places []Place, random *rand.Rand) []Cell {
+	fake := faker.NewWithSeed(random)
+	step := region.GridStep
+
+	// Group the places by zone
+	zonesPlaces := map[string][]Place{}
+	for _, place := range places {
+		coordX, coordY := utils.GetZoneCoordinatesFromGPS(region, place.Coordinates)
+		key := fmt.Sprintf("%d,%d", coordX, coordY)
+		zonesPlaces[key] = append(zonesPlaces[key], place)
+	}
+
+	cells := []Cell{}
+
+	for coordY := 0; region.Bounds.MinLatitude+float64(coordY)*step < region.Bounds.MaxLatitude; coordY++ {
+		for coordX := 0; region.Bounds.MinLongitude+float64(coordX)*step < region.Bounds.MaxLongitude; coordX++ {
+			zone := interfaces.Zone{
+				Region:         region.Id,
+				LeftFrontier:   region.Bounds.MinLongitude + float64(coordX)*step,
+				RightFrontier:  region.Bounds.MinLongitude + float64(coordX+1)*step,
+				BottomFrontier: region.Bounds.MinLatitude + float64(coordY)*step,
+				TopFrontier:    region.Bounds.MinLatitude + float64(coordY+1)*step,
+				Number:         len(cells) + 1,
+				Coordinates:    fmt.Sprintf("%d,%d", coordX, coordY),
+			}
+
+			var place Place
+			candidates := zonesPlaces[zone.Coordinates]
+
+			if len(candidates) > 0 {
+				place = candidates[random.Intn(len(candidates))]
+			} else {
+				// Reduce the zone to avoid getting points too close to the frontiers
+				place = Place{
+					Name: fake.Address().StreetName(),
+					Coordinates: interfaces.Coordinates{
+						Latitude:  zone.BottomFrontier + step/8 + random.Float64()*step*3/4,
+						Longitude: zone.LeftFrontier + step/8 + random.Float64()*step*3/4,
+					},
+				}
+			}
+
+			cells = append(cells, Cell{
+				Zone: zone,
+				Gym: interfaces.Gym{
+					Name:      place.Name,
+					Latitude:  place.Coordinates.Latitude,
+					Longitude: place.Coordinates.Longitude,
+					Location:  place.Coordinates.ToGeoPoint(),
+				},
+				Random: len(candidates) == 0,
+			})
+		}
+	}
+
+	return cells
+}
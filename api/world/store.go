@@ -0,0 +1,204 @@
+package world
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Amount of loomies protecting the new gyms
+const gymProtectorsAmount = 6
+
+// ErrNoProtectors is returned when there are no common loomies to protect the new gyms
+var ErrNoProtectors = errors.New("there are no common base loomies to protect the gyms, insert the game content first")
+
+// SaveResult counts the saved zones and gyms
+type SaveResult struct {
+	Zones       int
+	CreatedGyms int
+	KeptGyms    int
+}
+
+// Store reads the regions and the gyms protectors and saves the generated zones
+type Store interface {
+	// GetRegion returns the region with the given name or mongo.ErrNoDocuments
+	GetRegion(ctx context.Context, name string) (interfaces.Region, error)
+	// GetProtectorCandidates returns the base loomies that protect the new gyms or ErrNoProtectors
+	GetProtectorCandidates(ctx context.Context) ([]interfaces.BaseLoomies, error)
+	// SaveZone upserts the zone of the cell by region and coordinates. When the zone doesn't have a gym, the gym of the
+	// cell and its protectors are inserted too. The writes of the zone are saved together or not saved at all, returns
+	// true if the gym was created
+	SaveZone(ctx context.Context, cell Cell, protectors []interfaces.CaughtLoomie) (bool, error)
+}
+
+// newProtectors "private" function to create a team of weak common loomies without owner to protect a new gym
+func newProtectors(candidates []interfaces.BaseLoomies, random *rand.Rand) []interfaces.CaughtLoomie {
+	team := []interfaces.CaughtLoomie{}
+
+	for i := 0; i < gymProtectorsAmount; i++ {
+		base := candidates[random.Intn(len(candidates))]
+		team = append(team, interfaces.CaughtLoomie{
+			Id:      primitive.NewObjectID(),
+			IsBusy:  true,
+			Serial:  base.Serial,
+			Name:    base.Name,
+			Types:   base.Types,
+			Rarity:  base.Rarity,
+			HP:      base.BaseHp + random.Intn(7) - 2,
+			Attack:  base.BaseAttack + random.Intn(7) - 2,
+			Defense: base.BaseDefense + random.Intn(7) - 2,
+			Level:   14 + random.Intn(11),
+		})
+	}
+
+	return team
+}
+
+// Save saves the cells in the store, so the generation can run again after the region grows. The zones that already
+// have a gym keep it (with its owner and protectors), the other zones get the generated gym. The result counts the
+// zones saved before an error
+func Save(ctx context.Context, store Store, cells []Cell, random *rand.Rand) (SaveResult, error) {
+	result := SaveResult{}
+
+	candidates, err := store.GetProtectorCandidates(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	for _, cell := range cells {
+		cell.Gym.Id = primitive.NewObjectID()
+		cell.Gym.CurrentPlayersRewards = []interfaces.GymRewardItem{}
+		cell.Gym.CurrentOwnerRewards = []interfaces.GymRewardItem{}
+		cell.Gym.RewardsClaimedBy = []primitive.ObjectID{}
+
+		protectors := newProtectors(candidates, random)
+		cell.Gym.Protectors = []primitive.ObjectID{}
+		for _, protector := range protectors {
+			cell.Gym.Protectors = append(cell.Gym.Protectors, protector.Id)
+		}
+
+		created, err := store.SaveZone(ctx, cell, protectors)
+		if err != nil {
+			return result, err
+		}
+
+		if created {
+			result.CreatedGyms++
+		} else {
+			result.KeptGyms++
+		}
+
+		result.Zones++
+	}
+
+	return result, nil
+}
+
+// MongoStore saves the generated zones and gyms in the database
+type MongoStore struct {
+	Database *mongo.Database
+}
+
+func (store MongoStore) GetRegion(ctx context.Context, name string) (interfaces.Region, error) {
+	var region interfaces.Region
+	err := store.Database.Collection("regions").FindOne(ctx, bson.M{"name": name}).Decode(&region)
+	return region, err
+}
+
+func (store MongoStore) GetProtectorCandidates(ctx context.Context) ([]interfaces.BaseLoomies, error) {
+	var rarity interfaces.LoomieRarity
+	err := store.Database.Collection("loomie_rarities").FindOne(ctx, bson.M{"name": "Common"}).Decode(&rarity)
+
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNoProtectors
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []interfaces.BaseLoomies{}
+	cursor, err := store.Database.Collection("base_loomies").Find(ctx, bson.M{"rarity": rarity.Id})
+
+	if err == nil {
+		err = cursor.All(ctx, &candidates)
+	}
+
+	if err == nil && len(candidates) == 0 {
+		err = ErrNoProtectors
+	}
+
+	return candidates, err
+}
+
+func (store MongoStore) SaveZone(ctx context.Context, cell Cell, protectors []interfaces.CaughtLoomie) (bool, error) {
+	session, err := store.Database.Client().StartSession()
+	if err != nil {
+		return false, err
+	}
+
+	defer session.EndSession(ctx)
+
+	created, err := session.WithTransaction(ctx, func(sessionContext mongo.SessionContext) (interface{}, error) {
+		return store.saveZone(sessionContext, cell, protectors)
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	return created.(bool), nil
+}
+
+// saveZone "private" function to save the zone inside the transaction of SaveZone
+func (store MongoStore) saveZone(ctx context.Context, cell Cell, protectors []interfaces.CaughtLoomie) (bool, error) {
+	zones := store.Database.Collection("zones")
+	filter := bson.M{"region": cell.Zone.Region, "coordinates": cell.Zone.Coordinates}
+
+	var existing interfaces.Zone
+	err := zones.FindOne(ctx, filter).Decode(&existing)
+
+	if err != nil && err != mongo.ErrNoDocuments {
+		return false, err
+	}
+
+	fields := bson.M{
+		"leftFrontier":   cell.Zone.LeftFrontier,
+		"rightFrontier":  cell.Zone.RightFrontier,
+		"topFrontier":    cell.Zone.TopFrontier,
+		"bottomFrontier": cell.Zone.BottomFrontier,
+		"number":         cell.Zone.Number,
+	}
+
+	created := existing.Gym.IsZero()
+
+	if created {
+		team := []interface{}{}
+		for _, protector := range protectors {
+			team = append(team, protector)
+		}
+
+		if _, err := store.Database.Collection("caught_loomies").InsertMany(ctx, team); err != nil {
+			return false, err
+		}
+
+		if _, err := store.Database.Collection("gyms").InsertOne(ctx, cell.Gym); err != nil {
+			return false, err
+		}
+
+		fields["gym"] = cell.Gym.Id
+	}
+
+	_, err = zones.UpdateOne(ctx, filter, bson.M{
+		"$set":         fields,
+		"$setOnInsert": bson.M{"loomies": bson.A{}},
+	}, options.Update().SetUpsert(true))
+
+	return created, err
+}
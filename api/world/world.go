@@ -0,0 +1,184 @@
+// Package world generates the zones and gyms of a region from the points of interest of OpenStreetMap. The points
+// are read from a local .osm (XML) or .osm.pbf extract, or downloaded from the OpenStreetMap API, and every zone of
+// the region grid gets a gym in one of its points (or in a random point when the zone has none)
+package world
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/paulmach/osm"
+	"github.com/paulmach/osm/osmapi"
+	"github.com/paulmach/osm/osmpbf"
+	"github.com/paulmach/osm/osmxml"
+)
+
+// DefaultTags are the tags of the points of interest used as gyms when no tags are given
+var DefaultTags = []string{"amenity", "leisure", "tourism", "historic", "shop"}
+
+// Place is a named point of interest
+type Place struct {
+	Id          int64
+	Name        string
+	Coordinates interfaces.Coordinates
+}
+
+// Tag matches the points with the key and, when it's not empty, the value
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// TagFilter matches the points with at least one of the tags. An empty filter matches all the named points
+type TagFilter []Tag
+
+// ParseTagFilter parses a list of tags like amenity or leisure=park
+func ParseTagFilter(tags []string) (TagFilter, error) {
+	filter := TagFilter{}
+
+	for _, tag := range tags {
+		key, value, _ := strings.Cut(strings.TrimSpace(tag), "=")
+
+		if key == "" {
+			return nil, fmt.Errorf("invalid tag %q, tags must be key or key=value", tag)
+		}
+
+		filter = append(filter, Tag{Key: key, Value: value})
+	}
+
+	return filter, nil
+}
+
+// Matches returns true if the tags have one of the filter tags
+func (filter TagFilter) Matches(tags osm.Tags) bool {
+	if len(filter) == 0 {
+		return true
+	}
+
+	for _, tag := range filter {
+		value := tags.Find(tag.Key)
+
+		if value != "" && (tag.Value == "" || tag.Value == value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Source gives the points of interest inside the bounds of a region
+type Source interface {
+	Places(ctx context.Context, bounds interfaces.RegionBounds, filter TagFilter) ([]Place, error)
+}
+
+// toPlace "private" function to convert the node to a place if it's a named point inside the bounds with the tags
+func toPlace(node *osm.Node, bounds interfaces.RegionBounds, filter TagFilter) (Place, bool) {
+	name := strings.TrimSpace(node.Tags.Find("name"))
+	region := interfaces.Region{Bounds: bounds}
+	coordinates := interfaces.Coordinates{Latitude: node.Lat, Longitude: node.Lon}
+
+	if name == "" || !region.Contains(coordinates) || !filter.Matches(node.Tags) {
+		return Place{}, false
+	}
+
+	return Place{Id: int64(node.ID), Name: name, Coordinates: coordinates}, true
+}
+
+// FileSource reads the points of interest from a local extract. The files ending with .pbf are read as protocol
+// buffers and the others as XML. Only the nodes are used, the ways and relations are ignored
+type FileSource struct {
+	Path string
+}
+
+func (source FileSource) Places(ctx context.Context, bounds interfaces.RegionBounds, filter TagFilter) ([]Place, error) {
+	file, err := os.Open(source.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	var scanner osm.Scanner
+	if strings.HasSuffix(source.Path, ".pbf") {
+		pbfScanner := osmpbf.New(ctx, file, runtime.GOMAXPROCS(0))
+		pbfScanner.SkipWays = true
+		pbfScanner.SkipRelations = true
+		scanner = pbfScanner
+	} else {
+		scanner = osmxml.New(ctx, file)
+	}
+
+	defer scanner.Close()
+	places := []Place{}
+
+	for scanner.Scan() {
+		node, ok := scanner.Object().(*osm.Node)
+		if !ok {
+			continue
+		}
+
+		if place, ok := toPlace(node, bounds, filter); ok {
+			places = append(places, place)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", source.Path, err)
+	}
+
+	return places, nil
+}
+
+// Client downloads the map data inside a bounding box, it's implemented by *osmapi.Datasource
+type Client interface {
+	Map(ctx context.Context, bounds *osm.Bounds, opts ...osmapi.FeatureOption) (*osm.OSM, error)
+}
+
+// APISource downloads the points of interest from the OpenStreetMap API. The API limits the size of the requests,
+// so the bounds are downloaded in cells of CellSize degrees waiting Delay between the requests to avoid being blocked
+type APISource struct {
+	Client   Client
+	CellSize float64
+	Delay    time.Duration
+}
+
+func (source APISource) Places(ctx context.Context, bounds interfaces.RegionBounds, filter TagFilter) ([]Place, error) {
+	places := []Place{}
+	found := map[int64]bool{}
+
+	for minLatitude := bounds.MinLatitude; minLatitude < bounds.MaxLatitude; minLatitude += source.CellSize {
+		for minLongitude := bounds.MinLongitude; minLongitude < bounds.MaxLongitude; minLongitude += source.CellSize {
+			data, err := source.Client.Map(ctx, &osm.Bounds{
+				MinLat: minLatitude,
+				MinLon: minLongitude,
+				MaxLat: minLatitude + source.CellSize,
+				MaxLon: minLongitude + source.CellSize,
+			})
+
+			if err != nil {
+				return nil, fmt.Errorf("unable to download the map at (%f, %f): %w", minLatitude, minLongitude, err)
+			}
+
+			// The nodes in the border of the cells are returned twice
+			for _, node := range data.Nodes {
+				if place, ok := toPlace(node, bounds, filter); ok && !found[place.Id] {
+					found[place.Id] = true
+					places = append(places, place)
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(source.Delay):
+			}
+		}
+	}
+
+	return places, nil
+}
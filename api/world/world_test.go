@@ -0,0 +1,277 @@
+package world
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/PedroChaparro/loomies-backend/interfaces"
+	"github.com/paulmach/osm"
+	"github.com/paulmach/osm/osmapi"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ## Helper functions
+// fixtureClient replaces the OpenStreetMap API with the nodes of a fixture file
+type fixtureClient struct {
+	data     *osm.OSM
+	requests int
+}
+
+func newFixtureClient(t *testing.T, path string) *fixtureClient {
+	file, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	data := &osm.OSM{}
+	require.NoError(t, xml.Unmarshal(file, data))
+	return &fixtureClient{data: data}
+}
+
+// Map returns the nodes inside the bounds, including the borders like the API does
+func (client *fixtureClient) Map(ctx context.Context, bounds *osm.Bounds, opts ...osmapi.FeatureOption) (*osm.OSM, error) {
+	client.requests++
+	result := &osm.OSM{}
+
+	for _, node := range client.data.Nodes {
+		if node.Lat >= bounds.MinLat && node.Lat <= bounds.MaxLat && node.Lon >= bounds.MinLon && node.Lon <= bounds.MaxLon {
+			result.Nodes = append(result.Nodes, node)
+		}
+	}
+
+	return result, nil
+}
+
+// memoryStore keeps the saved zones, gyms and protectors in memory
+type memoryStore struct {
+	regions    []interfaces.Region
+	zones      map[string]interfaces.Zone
+	gyms       map[primitive.ObjectID]interfaces.Gym
+	protectors map[primitive.ObjectID]interfaces.CaughtLoomie
+	failAt     string
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		regions:    []interfaces.Region{testRegion},
+		zones:      map[string]interfaces.Zone{},
+		gyms:       map[primitive.ObjectID]interfaces.Gym{},
+		protectors: map[primitive.ObjectID]interfaces.CaughtLoomie{},
+	}
+}
+
+func (store *memoryStore) GetRegion(ctx context.Context, name string) (interfaces.Region, error) {
+	for _, region := range store.regions {
+		if region.Name == name {
+			return region, nil
+		}
+	}
+
+	return interfaces.Region{}, mongo.ErrNoDocuments
+}
+
+func (store *memoryStore) GetProtectorCandidates(ctx context.Context) ([]interfaces.BaseLoomies, error) {
+	return []interfaces.BaseLoomies{{Serial: 1, Name: "Common", BaseHp: 10, BaseAttack: 10, BaseDefense: 10}}, nil
+}
+
+// SaveZone fails before writing anything when the coordinates of the cell are the failAt ones, like an aborted transaction
+func (store *memoryStore) SaveZone(ctx context.Context, cell Cell, protectors []interfaces.CaughtLoomie) (bool, error) {
+	if cell.Zone.Coordinates == store.failAt {
+		return false, errors.New("Injected failure")
+	}
+
+	key := cell.Zone.Region.Hex() + "/" + cell.Zone.Coordinates
+	zone, exists := store.zones[key]
+	if !exists {
+		zone = interfaces.Zone{Id: primitive.NewObjectID(), Region: cell.Zone.Region, Coordinates: cell.Zone.Coordinates}
+	}
+
+	zone.LeftFrontier, zone.RightFrontier = cell.Zone.LeftFrontier, cell.Zone.RightFrontier
+	zone.TopFrontier, zone.BottomFrontier = cell.Zone.TopFrontier, cell.Zone.BottomFrontier
+	zone.Number = cell.Zone.Number
+
+	created := zone.Gym.IsZero()
+	if created {
+		for _, protector := range protectors {
+			store.protectors[protector.Id] = protector
+		}
+
+		store.gyms[cell.Gym.Id] = cell.Gym
+		zone.Gym = cell.Gym.Id
+	}
+
+	store.zones[key] = zone
+	return created, nil
+}
+
+// testRegion has 2 x 2 zones, the fixture nodes are inside it except one
+var testRegion = interfaces.Region{
+	Id:       primitive.NewObjectID(),
+	Name:     "bucaramanga",
+	Bounds:   interfaces.RegionBounds{MinLatitude: 6.9595, MinLongitude: -73.1696, MaxLatitude: 6.9665, MaxLongitude: -73.1626},
+	GridStep: 0.0035,
+}
+
+// placesNames returns the names of the places
+func placesNames(places []Place) []string {
+	names := []string{}
+	for _, place := range places {
+		names = append(names, place.Name)
+	}
+
+	return names
+}
+
+// ## Tests
+
+// TestTagFilter tests the points are filtered by key and value
+func TestTagFilter(t *testing.T) {
+	c := require.New(t)
+
+	filter, err := ParseTagFilter([]string{"amenity", "leisure=park"})
+	c.NoError(err)
+	c.True(filter.Matches(osm.Tags{{Key: "amenity", Value: "cafe"}}))
+	c.True(filter.Matches(osm.Tags{{Key: "leisure", Value: "park"}}))
+	c.False(filter.Matches(osm.Tags{{Key: "leisure", Value: "pitch"}}))
+	c.True(TagFilter{}.Matches(osm.Tags{{Key: "highway", Value: "primary"}}))
+
+	_, err = ParseTagFilter([]string{"=park"})
+	c.Error(err)
+}
+
+// TestFileSource tests the named points inside the region with the tags are read from the extract
+func TestFileSource(t *testing.T) {
+	c := require.New(t)
+	filter, _ := ParseTagFilter(DefaultTags)
+
+	places, err := FileSource{Path: "testdata/bucaramanga.osm"}.Places(context.Background(), testRegion.Bounds, filter)
+	c.NoError(err)
+	c.ElementsMatch([]string{"Parque San Pio", "Biblioteca Municipal", "Museo de Arte Moderno"}, placesNames(places))
+
+	// Without tags all the named points are used
+	places, err = FileSource{Path: "testdata/bucaramanga.osm"}.Places(context.Background(), testRegion.Bounds, TagFilter{})
+	c.NoError(err)
+	c.Equal(4, len(places))
+
+	_, err = FileSource{Path: "testdata/missing.osm"}.Places(context.Background(), testRegion.Bounds, filter)
+	c.Error(err)
+}
+
+// TestAPISource tests the region is downloaded by cells and the repeated nodes are ignored
+func TestAPISource(t *testing.T) {
+	c := require.New(t)
+	client := newFixtureClient(t, "testdata/bucaramanga.osm")
+	filter, _ := ParseTagFilter(DefaultTags)
+
+	places, err := APISource{Client: client, CellSize: 0.0035}.Places(context.Background(), testRegion.Bounds, filter)
+	c.NoError(err)
+	c.Equal(4, client.requests)
+	c.ElementsMatch([]string{"Parque San Pio", "Biblioteca Municipal", "Museo de Arte Moderno"}, placesNames(places))
+}
+
+// TestGenerate tests every zone gets a gym inside it, using the places when the zone has them
+func TestGenerate(t *testing.T) {
+	c := require.New(t)
+	filter, _ := ParseTagFilter(DefaultTags)
+	places, err := FileSource{Path: "testdata/bucaramanga.osm"}.Places(context.Background(), testRegion.Bounds, filter)
+	c.NoError(err)
+
+	cells := Generate(testRegion, places, rand.New(rand.NewSource(1)))
+	c.Equal(4, len(cells))
+	c.Equal([]string{"0,0", "1,0", "0,1", "1,1"}, []string{cells[0].Zone.Coordinates, cells[1].Zone.Coordinates, cells[2].Zone.Coordinates, cells[3].Zone.Coordinates})
+
+	for i, cell := range cells {
+		c.Equal(i+1, cell.Zone.Number)
+		c.NotEmpty(cell.Gym.Name)
+		c.True(cell.Gym.Latitude >= cell.Zone.BottomFrontier && cell.Gym.Latitude < cell.Zone.TopFrontier)
+		c.True(cell.Gym.Longitude >= cell.Zone.LeftFrontier && cell.Gym.Longitude < cell.Zone.RightFrontier)
+		c.Equal([]float64{cell.Gym.Longitude, cell.Gym.Latitude}, cell.Gym.Location.Coordinates)
+	}
+
+	// The first zone has two places, the second one has none and the last one has the museum
+	c.Contains([]string{"Parque San Pio", "Biblioteca Municipal"}, cells[0].Gym.Name)
+	c.False(cells[0].Random)
+	c.True(cells[1].Random)
+	c.Equal("Museo de Arte Moderno", cells[3].Gym.Name)
+
+	// The same seed generates the same world
+	again := Generate(testRegion, places, rand.New(rand.NewSource(1)))
+	c.Equal(cells, again)
+}
+
+// TestParseOptions tests the arguments of the generate-world command
+func TestParseOptions(t *testing.T) {
+	c := require.New(t)
+
+	options, err := ParseOptions([]string{"-region", "bucaramanga", "-input", "extract.osm.pbf", "-tags", "amenity,leisure=park", "-seed", "7"})
+	c.NoError(err)
+	c.Equal("bucaramanga", options.Region)
+	c.Equal(TagFilter{{Key: "amenity"}, {Key: "leisure", Value: "park"}}, options.Tags)
+	c.Equal(FileSource{Path: "extract.osm.pbf"}, options.source())
+
+	_, err = ParseOptions([]string{"-input", "extract.osm"})
+	c.ErrorContains(err, "missing region")
+
+	_, err = ParseOptions([]string{"-region", "bucaramanga", "-input", "extract.osm", "-online"})
+	c.ErrorContains(err, "either an input file or the online mode")
+
+	_, err = ParseOptions([]string{"-region", "bucaramanga"})
+	c.Error(err)
+}
+
+// TestRunAgain tests running the generator again over the saved zones keeps their gyms
+func TestRunAgain(t *testing.T) {
+	c := require.New(t)
+	ctx := context.Background()
+	store := newMemoryStore()
+	options := Options{Region: "bucaramanga", Input: "testdata/bucaramanga.osm", Seed: 1}
+
+	// 1. The first run creates every zone with its gym and protectors
+	out := &bytes.Buffer{}
+	c.NoError(Run(ctx, store, options, out))
+	c.Contains(out.String(), "Saved 4 zones, created 4 gyms and kept 0 gyms")
+	c.Equal(4, len(store.zones))
+	c.Equal(4, len(store.gyms))
+	c.Equal(4*gymProtectorsAmount, len(store.protectors))
+
+	for _, zone := range store.zones {
+		gym := store.gyms[zone.Gym]
+		c.Equal(gymProtectorsAmount, len(gym.Protectors))
+		for _, protector := range gym.Protectors {
+			c.True(store.protectors[protector].IsBusy)
+		}
+	}
+
+	// 2. A player takes over a gym, running again with another seed keeps the gyms and their owner
+	zone := store.zones[testRegion.Id.Hex()+"/0,0"]
+	gym := store.gyms[zone.Gym]
+	gym.Owner = primitive.NewObjectID()
+	store.gyms[gym.Id] = gym
+
+	options.Seed = 2
+	out.Reset()
+	c.NoError(Run(ctx, store, options, out))
+	c.Contains(out.String(), "Saved 4 zones, created 0 gyms and kept 4 gyms")
+	c.Equal(4, len(store.zones))
+	c.Equal(4, len(store.gyms))
+	c.Equal(4*gymProtectorsAmount, len(store.protectors))
+	c.Equal(gym.Id, store.zones[testRegion.Id.Hex()+"/0,0"].Gym)
+	c.Equal(gym.Owner, store.gyms[gym.Id].Owner)
+
+	// 3. The zones saved before an error are counted and the error is returned
+	store = newMemoryStore()
+	store.failAt = "0,1"
+	out.Reset()
+	c.Error(Run(ctx, store, options, out))
+	c.Contains(out.String(), "Saved 2 zones, created 2 gyms and kept 0 gyms")
+	c.Equal(2, len(store.zones))
+
+	// 4. The region must be registered
+	options.Region = "medellin"
+	c.ErrorContains(Run(ctx, store, options, out), "is not registered")
+}